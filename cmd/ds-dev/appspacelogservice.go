@@ -46,6 +46,10 @@ type appspaceLogServiceInternal struct {
 // incoming commands:
 const subscribeAppspaceLogCmd = 11
 const subscribeAppLogCmd = 12
+const queryAppspaceLogCmd = 14
+
+// reply to query
+const queryResultCmd = 14
 
 // outgoing commands, ref to 11:
 const statusSubCmd = 11
@@ -62,6 +66,8 @@ func (s *appspaceLogServiceInternal) HandleMessage(m twine.ReceivedMessageI) {
 		s.handleSubscribeAppspace(m)
 	case subscribeAppLogCmd:
 		s.handleSubscribeApp(m)
+	case queryAppspaceLogCmd:
+		s.handleQueryAppspace(m)
 	default:
 		m.SendError("command not recognized")
 	}
@@ -117,6 +123,33 @@ func (s *appspaceLogServiceInternal) handleSubscribeApp(m twine.ReceivedMessageI
 	ls.start()
 }
 
+// handleQueryAppspace replies with the entries of the appspace log
+// that match the query. Payload is the query plus appspace_id.
+func (s *appspaceLogServiceInternal) handleQueryAppspace(m twine.ReceivedMessageI) {
+	appspace, err := s.getMessageAppspace(m)
+	if err != nil {
+		return
+	}
+	var q domain.LogQuery
+	err = json.Unmarshal(m.Payload(), &q)
+	if err != nil {
+		m.SendError(err.Error())
+		return
+	}
+
+	res, err := s.AppspaceLogger.Get(appspace.AppspaceID).Query(q)
+	if err != nil {
+		m.SendError(err.Error())
+		return
+	}
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		m.SendError("Failed to marshal JSON")
+		return
+	}
+	m.Reply(queryResultCmd, bytes)
+}
+
 func (s *appspaceLogServiceInternal) getMessageAppspace(m twine.ReceivedMessageI) (domain.Appspace, error) {
 	var incoming IncomingSubscribeAppspace
 	err := json.Unmarshal(m.Payload(), &incoming)
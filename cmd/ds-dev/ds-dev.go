@@ -8,7 +8,6 @@ import (
 	"path/filepath"
 	"time"
 
-	"github.com/elazarl/goproxy"
 	"github.com/teleclimber/DropServer/cmd/ds-host/appops"
 	"github.com/teleclimber/DropServer/cmd/ds-host/appspacelogger"
 	"github.com/teleclimber/DropServer/cmd/ds-host/appspacemetadb"
@@ -18,6 +17,8 @@ import (
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
 	"github.com/teleclimber/DropServer/cmd/ds-host/events"
 	"github.com/teleclimber/DropServer/cmd/ds-host/models/appfilesmodel"
+	"github.com/teleclimber/DropServer/cmd/ds-host/ratelimit"
+	"github.com/teleclimber/DropServer/cmd/ds-host/sandbox"
 	"github.com/teleclimber/DropServer/cmd/ds-host/sandboxproxy"
 	"github.com/teleclimber/DropServer/cmd/ds-host/sandboxservices"
 	"github.com/teleclimber/DropServer/denosandboxcode"
@@ -117,7 +118,12 @@ func main() {
 		}
 	}
 
-	err = os.WriteFile(filepath.Join(runtimeConfig.Exec.RuntimeFilesPath, "goproxy-ca-cert.pem"), goproxy.CA_CERT, 0644)
+	// per-session CA so outbound HTTPS from app code can be inspected
+	mitmCA, err := sandbox.NewMITMCA()
+	if err != nil {
+		panic(err)
+	}
+	err = mitmCA.WriteCert(filepath.Join(runtimeConfig.Exec.RuntimeFilesPath, "goproxy-ca-cert.pem"))
 	if err != nil {
 		panic(err)
 	}
@@ -137,6 +143,7 @@ func main() {
 	appspaceStatusEvents := &events.AppspaceStatusEvents{}
 	appGetterEvents := &events.AppGetterEvents{}
 	routeHitEvents := &events.AppspaceRouteHitEvents{}
+	outboundEvents := &OutboundEvents{}
 
 	appLocation2Path := &AppLocation2Path{
 		AppMetaDir: appMetaDir,
@@ -210,6 +217,8 @@ func main() {
 		SandboxStatusEvents:   sandboxStatusEvents,
 		AppLocation2Path:      appLocation2Path,
 		AppspaceLocation2Path: appspaceLocation2Path,
+		OutboundEvents:        outboundEvents,
+		MITMCA:                mitmCA,
 	}
 	devSandboxManager.Init()
 	appGetter.SandboxManager = devSandboxManager
@@ -317,10 +326,15 @@ func main() {
 	sandboxProxy := &sandboxproxy.SandboxProxy{
 		SandboxManager: devSandboxManager}
 
+	loginLimiter := &ratelimit.Limiter{
+		Config: runtimeConfig}
+	loginLimiter.Init()
+
 	dropserverRoutes := &appspacerouter.DropserverRoutes{
 		V0DropServerRoutes: &appspacerouter.V0DropserverRoutes{
 			AppspaceModel: devAppspaceModel,
 			Authenticator: devAuth,
+			LoginLimiter:  loginLimiter,
 		},
 	}
 
@@ -384,6 +398,9 @@ func main() {
 		RouteHitEvents:     routeHitEvents,
 		AppspaceUsersModel: appspaceUserModel}
 
+	outboundService := &OutboundService{
+		OutboundEvents: outboundEvents}
+
 	migrationJobTwine := &MigrationJobService{
 		AppspaceModel:      devAppspaceModel,
 		MigrationJobModel:  devMigrationJobModel,
@@ -413,6 +430,7 @@ func main() {
 		AppRoutesService:      appRoutesService,
 		UserService:           userService,
 		RouteHitService:       routeHitService,
+		OutboundService:       outboundService,
 		AppspaceLogService:    appspaceLogTwine,
 		MigrationJobService:   migrationJobTwine}
 	dsDevHandler.SetPaths(appOrigin, appspaceSourceDir)
@@ -19,6 +19,7 @@ const appRoutesService = 16      // keeps a live list of app routes
 const userControlService = 17    //incoming / outgoing
 const appspaceStatusService = 18 // in/out? or just out? I think just out.
 const sandboxControlService = 19 // in/out, inspect, kill sandbox
+const outboundService = 20       // out: requests made by app code
 
 type twineService interface {
 	Start(*twine.Twine)
@@ -63,6 +64,7 @@ type DropserverDevServer struct {
 	AppRoutesService      twineService `checkinject:"required"`
 	UserService           twineService `checkinject:"required"`
 	RouteHitService       twineService `checkinject:"required"`
+	OutboundService       twineService `checkinject:"required"`
 
 	// Services:
 	MigrationJobService domain.TwineService2 `checkinject:"required"`
@@ -112,6 +114,7 @@ func (s *DropserverDevServer) StartLivedata(res http.ResponseWriter, req *http.R
 	go s.AppRoutesService.Start(t)
 	go s.UserService.Start(t)
 	go s.RouteHitService.Start(t)
+	go s.OutboundService.Start(t)
 
 	migrationJobTwine := s.MigrationJobService.Start(ownerID, t)
 	appspaceLogTwine := s.AppspaceLogService.Start(ownerID, t)
@@ -2,6 +2,8 @@ package main
 
 import (
 	"sync"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
 )
 
 // PureEvent pushes data-free events to subscriber channels
@@ -210,3 +212,42 @@ func (e *SandboxStatusEvents) removeSubscriber(ch <-chan SandboxStatus) {
 		}
 	}
 }
+
+// OutboundEvents relays outbound requests captured by the sandbox
+type OutboundEvents struct {
+	subLock     sync.Mutex
+	subscribers []chan<- domain.OutboundExchange
+}
+
+// Send an outbound exchange to subscribers
+func (e *OutboundEvents) Send(exchange domain.OutboundExchange) {
+	e.subLock.Lock()
+	defer e.subLock.Unlock()
+	for _, ch := range e.subscribers {
+		ch <- exchange
+	}
+}
+
+// Subscribe to outbound exchanges
+func (e *OutboundEvents) Subscribe(ch chan<- domain.OutboundExchange) {
+	e.subLock.Lock()
+	defer e.subLock.Unlock()
+	e.removeSubscriber(ch)
+	e.subscribers = append(e.subscribers, ch)
+}
+
+// Unsubscribe from outbound exchanges
+func (e *OutboundEvents) Unsubscribe(ch chan<- domain.OutboundExchange) {
+	e.subLock.Lock()
+	defer e.subLock.Unlock()
+	e.removeSubscriber(ch)
+}
+
+func (e *OutboundEvents) removeSubscriber(ch chan<- domain.OutboundExchange) {
+	for i, c := range e.subscribers {
+		if c == ch {
+			e.subscribers[i] = e.subscribers[len(e.subscribers)-1]
+			e.subscribers = e.subscribers[:len(e.subscribers)-1]
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/twine-go/twine"
+)
+
+const outboundExchangeCmd = 11
+
+// OutboundService forwards outbound requests made by the sandbox to twine
+type OutboundService struct {
+	OutboundEvents interface {
+		Subscribe(ch chan<- domain.OutboundExchange)
+		Unsubscribe(ch chan<- domain.OutboundExchange)
+	} `checkinject:"required"`
+}
+
+func (s *OutboundService) Start(t *twine.Twine) {
+	outboundChan := make(chan domain.OutboundExchange)
+	s.OutboundEvents.Subscribe(outboundChan)
+	go func() {
+		for e := range outboundChan {
+			go s.sendExchange(t, e)
+		}
+	}()
+
+	t.WaitClose()
+
+	s.OutboundEvents.Unsubscribe(outboundChan)
+	close(outboundChan)
+}
+
+// HandleMessage is a no-op, error producing function in outbound service
+func (s *OutboundService) HandleMessage(m twine.ReceivedMessageI) {
+	panic("did not expect a message to outbound service.")
+}
+
+func (s *OutboundService) sendExchange(twine *twine.Twine, e domain.OutboundExchange) {
+	bytes, err := json.Marshal(e)
+	if err != nil {
+		fmt.Println("sendExchange json Marshal Error: " + err.Error())
+		return
+	}
+	_, err = twine.SendBlock(outboundService, outboundExchangeCmd, bytes)
+	if err != nil {
+		fmt.Println("sendExchange SendBlock Error: " + err.Error())
+	}
+}
@@ -37,7 +37,8 @@ type RouteHitService struct {
 
 func (s *RouteHitService) Start(t *twine.Twine) {
 
-	routeEventsChan := make(chan *domain.AppspaceRouteHitEvent)
+	// buffered because hits are dropped rather than block a request
+	routeEventsChan := make(chan *domain.AppspaceRouteHitEvent, 100)
 	s.RouteHitEvents.Subscribe(routeEventsChan)
 	go func() {
 		for routeEvent := range routeEventsChan {
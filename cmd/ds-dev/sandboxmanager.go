@@ -18,6 +18,7 @@ type DevSandboxManager struct {
 	SandboxRuns interface {
 		Create(run domain.SandboxRunIDs, start time.Time) (int, error)
 		End(sandboxID int, end time.Time, data domain.SandboxRunData) error
+		AppspaceSums(ownerID domain.UserID, appspaceID domain.AppspaceID, from time.Time, to time.Time) (domain.SandboxRunData, error)
 	} `checkinject:"required"`
 	AppLogger interface {
 		Get(string) domain.LoggerI
@@ -46,7 +47,12 @@ type DevSandboxManager struct {
 		Avatars(string) string
 		DenoDir(string) string
 	} `checkinject:"required"`
+	OutboundEvents interface {
+		Send(domain.OutboundExchange)
+	} `checkinject:"required"`
 	Config *domain.RuntimeConfig `checkinject:"required"`
+	// MITMCA is the session CA used to intercept outbound HTTPS
+	MITMCA *sandbox.MITMCA `checkinject:"required"`
 
 	appSb       domain.SandboxI
 	migrationSb domain.SandboxI
@@ -99,6 +105,7 @@ func (m *DevSandboxManager) startSandbox(appVersion *domain.AppVersion, appspace
 	s.Config = m.Config
 	s.SetInspect(m.inspect)
 	s.SetImportMapExtras(m.importMapExtras)
+	s.SetMITM(m.MITMCA, m.OutboundEvents.Send)
 	m.appspaceSb = s
 
 	statCh := s.SubscribeStatus()
@@ -135,6 +142,7 @@ func (m *DevSandboxManager) ForApp(appVersion *domain.AppVersion) (domain.Sandbo
 	s.Config = m.Config
 	s.SetInspect(m.inspect)
 	s.SetImportMapExtras(m.importMapExtras)
+	s.SetMITM(m.MITMCA, m.OutboundEvents.Send)
 
 	m.appSb = s
 
@@ -175,6 +183,7 @@ func (m *DevSandboxManager) ForMigration(appVersion domain.AppVersion, appspace
 	s.Config = m.Config
 	s.SetInspect(m.inspect)
 	s.SetImportMapExtras(m.importMapExtras)
+	s.SetMITM(m.MITMCA, m.OutboundEvents.Send)
 
 	m.migrationSb = s
 
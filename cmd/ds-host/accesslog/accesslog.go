@@ -0,0 +1,182 @@
+package accesslog
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+)
+
+const flushSize = 100
+
+var flushInterval = 5 * time.Second
+
+// AccessLog records requests to appspaces that have their access log enabled.
+// Route hits are buffered and written in batches
+// so that requests are not held up by the DB.
+type AccessLog struct {
+	Config         *domain.RuntimeConfig `checkinject:"required"`
+	RouteHitEvents interface {
+		Subscribe(ch chan<- *domain.AppspaceRouteHitEvent)
+		Unsubscribe(ch chan<- *domain.AppspaceRouteHitEvent)
+	} `checkinject:"required"`
+	AccessLogModel interface {
+		GetSettings(appspaceID domain.AppspaceID) (domain.AccessLogSettings, error)
+		SetSettings(appspaceID domain.AppspaceID, settings domain.AccessLogSettings) error
+		Insert(entries []domain.AccessLogEntry) error
+		DeleteExpired(now time.Time, defaultKeepDays int) error
+	} `checkinject:"required"`
+
+	enabledMux sync.Mutex
+	enabled    map[domain.AppspaceID]bool
+
+	hitCh   chan *domain.AppspaceRouteHitEvent
+	pending []domain.AccessLogEntry
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// Start recording route hits. Expired entries are deleted now and every hour.
+func (a *AccessLog) Start() {
+	a.enabled = make(map[domain.AppspaceID]bool)
+	a.hitCh = make(chan *domain.AppspaceRouteHitEvent, 1000)
+	a.stop = make(chan struct{})
+	a.stopped = make(chan struct{})
+	a.RouteHitEvents.Subscribe(a.hitCh)
+
+	go func() {
+		defer close(a.stopped)
+		flushTicker := time.NewTicker(flushInterval)
+		defer flushTicker.Stop()
+		pruneTicker := time.NewTicker(time.Hour)
+		defer pruneTicker.Stop()
+
+		a.prune()
+		for {
+			select {
+			case <-a.stop:
+				a.drain()
+				return
+			case e := <-a.hitCh:
+				a.add(e)
+			case <-flushTicker.C:
+				a.flush()
+			case <-pruneTicker.C:
+				a.prune()
+			}
+		}
+	}()
+}
+
+// Stop recording and write pending entries
+func (a *AccessLog) Stop() {
+	a.RouteHitEvents.Unsubscribe(a.hitCh)
+	close(a.stop)
+	<-a.stopped
+}
+
+// SetSettings saves the access log settings of the appspace
+func (a *AccessLog) SetSettings(appspaceID domain.AppspaceID, settings domain.AccessLogSettings) error {
+	err := a.AccessLogModel.SetSettings(appspaceID, settings)
+	if err != nil {
+		return err
+	}
+	a.enabledMux.Lock()
+	defer a.enabledMux.Unlock()
+	a.enabled[appspaceID] = settings.Enabled
+	return nil
+}
+
+func (a *AccessLog) isEnabled(appspaceID domain.AppspaceID) bool {
+	a.enabledMux.Lock()
+	defer a.enabledMux.Unlock()
+	enabled, ok := a.enabled[appspaceID]
+	if !ok {
+		settings, err := a.AccessLogModel.GetSettings(appspaceID)
+		if err != nil {
+			// try again next time
+			return false
+		}
+		enabled = settings.Enabled
+		a.enabled[appspaceID] = enabled
+	}
+	return enabled
+}
+
+func (a *AccessLog) add(e *domain.AppspaceRouteHitEvent) {
+	if e.AppspaceID == domain.AppspaceID(0) || !a.isEnabled(e.AppspaceID) {
+		return
+	}
+	a.pending = append(a.pending, entryFromHit(e))
+	if len(a.pending) >= flushSize {
+		a.flush()
+	}
+}
+
+// drain records the hits still in the channel then flushes
+func (a *AccessLog) drain() {
+	for {
+		select {
+		case e := <-a.hitCh:
+			a.add(e)
+		default:
+			a.flush()
+			return
+		}
+	}
+}
+
+func (a *AccessLog) flush() {
+	if len(a.pending) == 0 {
+		return
+	}
+	err := a.AccessLogModel.Insert(a.pending)
+	if err != nil {
+		// drop entries rather than let them pile up
+		a.getLogger("flush()").Log("dropping access log entries: " + err.Error())
+	}
+	a.pending = nil
+}
+
+func (a *AccessLog) prune() {
+	a.AccessLogModel.DeleteExpired(time.Now(), a.Config.AccessLog.DefaultKeepDays)
+}
+
+func entryFromHit(e *domain.AppspaceRouteHitEvent) domain.AccessLogEntry {
+	entry := domain.AccessLogEntry{
+		AppspaceID: e.AppspaceID,
+		Time:       e.Timestamp,
+		Method:     e.Request.Method,
+		Path:       e.Request.URL.Path,
+		ProxyID:    e.Credentials.ProxyID,
+		Authorized: e.Authorized,
+		Status:     e.Status}
+	if entry.Status == 0 {
+		entry.Status = http.StatusOK
+	}
+	if e.RouteConfig != nil && e.RouteConfig.Path.Path != "" {
+		entry.RouteID = e.RouteConfig.ID
+		entry.RouteType = e.RouteConfig.Type
+		entry.RoutePath = routePattern(e.RouteConfig.Path)
+	}
+	return entry
+}
+
+// routePattern shows wildcard routes as /path/**
+func routePattern(p domain.AppRoutePath) string {
+	if p.End {
+		return p.Path
+	}
+	return strings.TrimSuffix(p.Path, "/") + "/**"
+}
+
+func (a *AccessLog) getLogger(note string) *record.DsLogger {
+	l := record.NewDsLogger().AddNote("AccessLog")
+	if note != "" {
+		l.AddNote(note)
+	}
+	return l
+}
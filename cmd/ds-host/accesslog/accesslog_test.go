@@ -0,0 +1,99 @@
+package accesslog
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/events"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+)
+
+func TestEntryFromHit(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/api/items/3?x=y", nil)
+	e := &domain.AppspaceRouteHitEvent{
+		Timestamp:  time.Now(),
+		AppspaceID: domain.AppspaceID(7),
+		Request:    req,
+		RouteConfig: &domain.AppRoute{
+			ID:   "items",
+			Type: "function",
+			Path: domain.AppRoutePath{Path: "/api/", End: false}},
+		Authorized: true}
+	e.Credentials.ProxyID = domain.ProxyID("abc")
+
+	entry := entryFromHit(e)
+	if entry.Path != "/api/items/3" || entry.RoutePath != "/api/**" || entry.RouteID != "items" {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+	if entry.Status != http.StatusOK {
+		t.Errorf("expected status 0 to be recorded as 200, got %v", entry.Status)
+	}
+	if entry.ProxyID != domain.ProxyID("abc") {
+		t.Errorf("unexpected proxy id: %v", entry.ProxyID)
+	}
+
+	e.RouteConfig = &domain.AppRoute{}
+	e.Status = http.StatusNotFound
+	entry = entryFromHit(e)
+	if entry.RoutePath != "" || entry.Status != http.StatusNotFound {
+		t.Errorf("unexpected entry for unmatched route: %v", entry)
+	}
+}
+
+func TestRoutePattern(t *testing.T) {
+	cases := []struct {
+		p        domain.AppRoutePath
+		expected string
+	}{
+		{domain.AppRoutePath{Path: "/", End: true}, "/"},
+		{domain.AppRoutePath{Path: "/", End: false}, "/**"},
+		{domain.AppRoutePath{Path: "/api", End: false}, "/api/**"},
+		{domain.AppRoutePath{Path: "/a/b", End: true}, "/a/b"},
+	}
+	for _, c := range cases {
+		if p := routePattern(c.p); p != c.expected {
+			t.Errorf("%v: expected %v, got %v", c.p, c.expected, p)
+		}
+	}
+}
+
+func TestRecordEnabledOnly(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	cfg := &domain.RuntimeConfig{}
+	cfg.AccessLog.DefaultKeepDays = 14
+
+	model := testmocks.NewMockAccessLogModel(mockCtrl)
+	model.EXPECT().DeleteExpired(gomock.Any(), 14).Return(nil)
+	model.EXPECT().GetSettings(domain.AppspaceID(7)).Return(domain.AccessLogSettings{Enabled: true}, nil)
+	model.EXPECT().GetSettings(domain.AppspaceID(8)).Return(domain.AccessLogSettings{}, nil)
+	model.EXPECT().Insert(gomock.Any()).DoAndReturn(func(entries []domain.AccessLogEntry) error {
+		if len(entries) != 2 {
+			t.Errorf("expected 2 entries, got %v", entries)
+		}
+		for _, e := range entries {
+			if e.AppspaceID != domain.AppspaceID(7) {
+				t.Errorf("unexpected appspace id %v", e.AppspaceID)
+			}
+		}
+		return nil
+	})
+
+	hitEvents := &events.AppspaceRouteHitEvents{}
+	a := &AccessLog{
+		Config:         cfg,
+		RouteHitEvents: hitEvents,
+		AccessLogModel: model}
+	a.Start()
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	for _, id := range []domain.AppspaceID{7, 8, 7} {
+		hitEvents.Send(&domain.AppspaceRouteHitEvent{AppspaceID: id, Request: req, Status: 200})
+	}
+
+	a.Stop()
+}
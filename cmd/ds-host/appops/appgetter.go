@@ -79,6 +79,9 @@ type AppGetter struct {
 	AppGetterEvents interface {
 		Send(domain.AppGetEvent)
 	} `checkinject:"required"`
+	Metrics interface {
+		AppGet(outcome string)
+	} `checkinject:"optional"`
 
 	keysMux sync.Mutex
 	keys    map[domain.AppGetKey]appGetData
@@ -1147,9 +1150,21 @@ func (g *AppGetter) sendEvent(getData appGetData, ev domain.AppGetEvent) {
 
 	ev.OwnerID = getData.userID
 
+	// Done can be sent more than once, so only count the first one
+	prev, hasPrev := g.lastEvent[ev.Key]
+	firstDone := ev.Done && !(hasPrev && prev.Done)
+
 	g.lastEvent[ev.Key] = ev
 
 	g.AppGetterEvents.Send(ev)
+
+	if firstDone && g.Metrics != nil {
+		if g.resultHasError(ev.Key) {
+			g.Metrics.AppGet("error")
+		} else {
+			g.Metrics.AppGet("ok")
+		}
+	}
 }
 
 func (g *AppGetter) getLogger(note string) *record.DsLogger {
@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
 )
 
 func TestSetKey(t *testing.T) {
@@ -38,6 +40,40 @@ func TestGetMeta(t *testing.T) {
 	}
 }
 
+func TestSendEventMetrics(t *testing.T) {
+	metrics := &testMetrics{}
+	g := &AppGetter{
+		AppGetterEvents: &testEvents{},
+		Metrics:         metrics,
+	}
+	g.Init()
+
+	keyData := g.set(appGetData{})
+	g.sendEvent(keyData, domain.AppGetEvent{Step: "Processing"})
+	g.appendErrorResult(keyData.key, "some error")
+	g.sendEvent(keyData, domain.AppGetEvent{Done: true})
+	g.sendEvent(keyData, domain.AppGetEvent{Done: true})
+
+	okData := g.set(appGetData{})
+	g.sendEvent(okData, domain.AppGetEvent{Done: true})
+
+	if len(metrics.outcomes) != 2 || metrics.outcomes[0] != "error" || metrics.outcomes[1] != "ok" {
+		t.Errorf("unexpected outcomes: %v", metrics.outcomes)
+	}
+}
+
+type testEvents struct{}
+
+func (e *testEvents) Send(domain.AppGetEvent) {}
+
+type testMetrics struct {
+	outcomes []string
+}
+
+func (m *testMetrics) AppGet(outcome string) {
+	m.outcomes = append(m.outcomes, outcome)
+}
+
 func TestValidatePackageFile(t *testing.T) {
 	cases := []struct {
 		input    string
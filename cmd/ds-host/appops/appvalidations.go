@@ -87,6 +87,11 @@ func validateManifest(manifest domain.AppVersionManifest) (domain.AppVersionMani
 	}
 	warnings = addWarning(warnings, warns...)
 
+	// outbound hosts
+	cleanOutbound, warns := validateOutbound(manifest.Outbound)
+	warnings = addWarning(warnings, warns...)
+	manifest.Outbound = cleanOutbound
+
 	warning = validateLicenseFields(manifest.License, manifest.LicenseFile)
 	warnings = addWarning(warnings, warning)
 
@@ -361,6 +366,27 @@ func validateVersionSequence(version domain.Version, schema int, appVersions []a
 	return
 }
 
+// validateOutbound normalizes outbound hosts and drops invalid ones and duplicates
+func validateOutbound(hosts []string) ([]string, []domain.ProcessWarning) {
+	warnings := make([]domain.ProcessWarning, 0)
+	ret := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		h = validator.NormalizeOutboundHost(h)
+		if err := validator.OutboundHost(h); err != nil {
+			warnings = append(warnings, domain.ProcessWarning{
+				Field:    "outbound",
+				Problem:  domain.ProblemInvalid,
+				Message:  fmt.Sprintf("Outbound host is invalid: %s", h),
+				BadValue: h})
+			continue
+		}
+		if !slices.Contains(ret, h) {
+			ret = append(ret, h)
+		}
+	}
+	return ret, warnings
+}
+
 func validateAccentColor(color string) (string, bool) {
 	if color == "" {
 		return "", true
@@ -140,6 +140,16 @@ func TestBadURLRemoved(t *testing.T) {
 	}
 }
 
+func TestValidateOutbound(t *testing.T) {
+	hosts, warns := validateOutbound([]string{"API.example.com", "bad host", "*.example.org:443", "api.example.com"})
+	if len(warns) != 1 || warns[0].Field != "outbound" {
+		t.Errorf("expected one outbound warning: %v", warns)
+	}
+	if len(hosts) != 2 || hosts[0] != "api.example.com" || hosts[1] != "*.example.org:443" {
+		t.Errorf("unexpected hosts: %v", hosts)
+	}
+}
+
 func TestHasWarnings(t *testing.T) {
 	warnings := []domain.ProcessWarning{
 		{Field: "abc", Problem: domain.ProblemBig},
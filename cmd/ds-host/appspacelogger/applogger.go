@@ -15,6 +15,9 @@ type AppLogger struct {
 		Meta(string) string
 	} `checkinject:"required"`
 
+	// Config sets log rotation. Logs are not rotated if nil.
+	Config *domain.RuntimeConfig
+
 	loggersMux sync.Mutex
 	loggers    map[string]*Logger // by location key
 }
@@ -83,6 +86,9 @@ func (l *AppLogger) getLogger(locationKey string, open bool) (logger *Logger) {
 		// should really check with AppFileModel that the location key exists.
 		logger = &Logger{
 			logPath: filepath.Join(l.AppLocation2Path.Meta(locationKey), "log.txt")}
+		if l.Config != nil {
+			logger.rotation = rotationFromConfig(l.Config, domain.LogRetention{})
+		}
 		l.loggers[locationKey] = logger
 	}
 
@@ -1,6 +1,7 @@
 package appspacelogger
 
 import (
+	"errors"
 	"path/filepath"
 	"sync"
 
@@ -16,6 +17,10 @@ type AppspaceLogger struct {
 	AppspaceStatus interface {
 		IsLockedClosed(domain.AppspaceID) bool
 	} `checkinject:"required"`
+	AppspaceLogModel interface {
+		GetRetention(appspaceID domain.AppspaceID) (domain.LogRetention, error)
+		SetRetention(appspaceID domain.AppspaceID, ret domain.LogRetention) error
+	} `checkinject:"optional"`
 
 	Config *domain.RuntimeConfig
 
@@ -78,6 +83,41 @@ func (l *AppspaceLogger) Forget(appspaceID domain.AppspaceID) {
 	delete(l.loggers, appspaceID)
 }
 
+// SetRetention saves the retention of archived log segments
+// for the appspace and prunes archives accordingly
+func (l *AppspaceLogger) SetRetention(appspaceID domain.AppspaceID, ret domain.LogRetention) error {
+	if l.AppspaceLogModel == nil {
+		return errors.New("unable to set log retention: no model")
+	}
+	err := l.AppspaceLogModel.SetRetention(appspaceID, ret)
+	if err != nil {
+		return err
+	}
+	logger := l.getLogger(appspaceID, false)
+	if logger != nil {
+		logger.setRotation(rotationFromConfig(l.Config, ret))
+	}
+	return nil
+}
+
+// Archives returns the archived log segments of the appspace, oldest first
+func (l *AppspaceLogger) Archives(appspaceID domain.AppspaceID) ([]domain.LogArchive, error) {
+	logger := l.getLogger(appspaceID, false)
+	if logger == nil {
+		return nil, errors.New("unable to get logger for appspace")
+	}
+	return logger.Archives()
+}
+
+// ArchivePath returns the path of the archived log segment of the appspace
+func (l *AppspaceLogger) ArchivePath(appspaceID domain.AppspaceID, name string) (string, error) {
+	logger := l.getLogger(appspaceID, false)
+	if logger == nil {
+		return "", errors.New("unable to get logger for appspace")
+	}
+	return logger.ArchivePath(name)
+}
+
 func (l *AppspaceLogger) getLogger(appspaceID domain.AppspaceID, open bool) *Logger {
 	l.loggersMux.Lock()
 	defer l.loggersMux.Unlock()
@@ -90,8 +130,18 @@ func (l *AppspaceLogger) getLogger(appspaceID domain.AppspaceID, open bool) *Log
 			return nil
 		}
 
+		var ret domain.LogRetention
+		if l.AppspaceLogModel != nil {
+			ret, err = l.AppspaceLogModel.GetRetention(appspaceID)
+			if err != nil {
+				// fall back to host defaults
+				l.getHostLogger("getLogger AppspaceLogModel.GetRetention").AppspaceID(appspaceID).Error(err)
+			}
+		}
+
 		logger = &Logger{
-			logPath: filepath.Join(l.Config.Exec.AppspacesPath, appspace.LocationKey, "data", "logs", "log.txt")}
+			logPath:  filepath.Join(l.Config.Exec.AppspacesPath, appspace.LocationKey, "data", "logs", "log.txt"),
+			rotation: rotationFromConfig(l.Config, ret)}
 
 		l.loggers[appspaceID] = logger
 	}
@@ -2,9 +2,13 @@ package appspacelogger
 
 import (
 	"bytes"
+	"compress/gzip"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -19,7 +23,36 @@ func sanitizeMessage(m string) string {
 	return m
 }
 
+// rotation sets when a log is rotated and how long archives are kept.
+// Zero values disable each.
+type rotation struct {
+	maxSize      int64
+	maxAge       time.Duration
+	keepArchives int
+	keepAge      time.Duration
+}
+
+func rotationFromConfig(config *domain.RuntimeConfig, ret domain.LogRetention) rotation {
+	c := config.AppspaceLogs
+	r := rotation{
+		maxSize:      int64(c.MaxSizeKb) * 1024,
+		maxAge:       time.Duration(c.MaxAgeDays) * 24 * time.Hour,
+		keepArchives: c.KeepArchives,
+		keepAge:      time.Duration(c.KeepDays) * 24 * time.Hour}
+	if ret.KeepArchives != 0 {
+		r.keepArchives = ret.KeepArchives
+	}
+	if ret.KeepDays != 0 {
+		r.keepAge = time.Duration(ret.KeepDays) * 24 * time.Hour
+	}
+	return r
+}
+
+const archiveTimeFormat = "20060102T150405.000Z"
+
 // Logger system sufficiently genric to support app and appspace logs
+// The log file is rotated into gzipped archives next to it
+// when it gets too big or too old.
 type Logger struct {
 	fd *os.File
 
@@ -27,6 +60,9 @@ type Logger struct {
 
 	logMux sync.Mutex
 
+	rotation rotation
+	started  time.Time // time of first entry in current log file
+
 	statusSubMux sync.Mutex
 	statusSubs   []chan bool
 
@@ -40,9 +76,20 @@ func (l *Logger) open() error {
 		return err
 	}
 	l.fd = f
+	l.started = segmentStart(f)
+	l.prune(time.Now())
 	l.sendStatusEvent(true)
 	return nil
 }
+
+func (l *Logger) setRotation(r rotation) {
+	l.logMux.Lock()
+	defer l.logMux.Unlock()
+	l.rotation = r
+	if l.fd != nil {
+		l.prune(time.Now())
+	}
+}
 func (l *Logger) close() error {
 	l.logMux.Lock()
 	defer l.logMux.Unlock()
@@ -72,6 +119,15 @@ func (l *Logger) writeEntry(entry string) error {
 		return errors.New("unable to write to log: log file is not open")
 	}
 
+	now := time.Now()
+	if l.needsRotate(now, int64(len(entry)+1)) {
+		err := l.rotate(now)
+		if err != nil {
+			// keep logging to the current file rather than lose entries
+			l.getHostLogger("writeEntry rotate()").Error(err)
+		}
+	}
+
 	_, err := l.fd.WriteString(entry + "\n")
 	if err != nil {
 		return err
@@ -82,21 +138,244 @@ func (l *Logger) writeEntry(entry string) error {
 	return nil
 }
 
-// GetLastBytes returns the log lines found in the last n bytes of the file.
+func (l *Logger) needsRotate(now time.Time, entryLen int64) bool {
+	fStat, err := l.fd.Stat()
+	if err != nil || fStat.Size() == 0 {
+		return false
+	}
+	if l.rotation.maxSize != 0 && fStat.Size()+entryLen > l.rotation.maxSize {
+		return true
+	}
+	return l.rotation.maxAge != 0 && now.Sub(l.started) >= l.rotation.maxAge
+}
+
+// rotate compresses the current log file into an archive
+// and truncates it. Caller must hold logMux.
+func (l *Logger) rotate(now time.Time) error {
+	fStat, err := l.fd.Stat()
+	if err != nil {
+		return err
+	}
+	archivePath := filepath.Join(filepath.Dir(l.logPath), l.archiveName(now))
+	tmpPath := archivePath + ".tmp"
+	out, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0664)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, io.NewSectionReader(l.fd, 0, fStat.Size()))
+	if err == nil {
+		err = gz.Close()
+	}
+	if cErr := out.Close(); err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, archivePath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// file is opened O_APPEND so writes continue at the new end
+	err = l.fd.Truncate(0)
+	if err != nil {
+		return err
+	}
+	l.started = now
+	l.prune(now)
+	return nil
+}
+
+// prune removes archives that are too old or too many.
+// Caller must hold logMux.
+func (l *Logger) prune(now time.Time) {
+	archives, err := l.archives()
+	if err != nil {
+		l.getHostLogger("prune archives()").Error(err)
+		return
+	}
+	dir := filepath.Dir(l.logPath)
+	for i, a := range archives {
+		tooOld := l.rotation.keepAge != 0 && now.Sub(a.Rotated) > l.rotation.keepAge
+		tooMany := l.rotation.keepArchives != 0 && len(archives)-i > l.rotation.keepArchives
+		if !tooOld && !tooMany {
+			continue
+		}
+		err = os.Remove(filepath.Join(dir, a.Name))
+		if err != nil && !os.IsNotExist(err) {
+			l.getHostLogger("prune os.Remove()").Error(err)
+		}
+	}
+}
+
+// archiveName is the log file name with the rotation time inserted
+// like log-20060102T150405.000Z.txt.gz
+func (l *Logger) archiveName(t time.Time) string {
+	base := filepath.Base(l.logPath)
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "-" + t.UTC().Format(archiveTimeFormat) + ext + ".gz"
+}
+
+// archives returns the archived segments of the log, oldest first
+func (l *Logger) archives() ([]domain.LogArchive, error) {
+	base := filepath.Base(l.logPath)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+	suffix := ext + ".gz"
+
+	entries, err := os.ReadDir(filepath.Dir(l.logPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []domain.LogArchive{}, nil
+		}
+		return nil, err
+	}
+	ret := []domain.LogArchive{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		t, err := time.Parse(archiveTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix))
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		ret = append(ret, domain.LogArchive{Name: name, Size: info.Size(), Rotated: t})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Rotated.Before(ret[j].Rotated) })
+	return ret, nil
+}
+
+// Archives returns the archived segments of the log, oldest first
+func (l *Logger) Archives() ([]domain.LogArchive, error) {
+	l.logMux.Lock()
+	defer l.logMux.Unlock()
+	return l.archives()
+}
+
+// ArchivePath returns the path to the archive with name
+// or os.ErrNotExist if there is no such archive.
+func (l *Logger) ArchivePath(name string) (string, error) {
+	archives, err := l.Archives()
+	if err != nil {
+		return "", err
+	}
+	for _, a := range archives {
+		if a.Name == name {
+			return filepath.Join(filepath.Dir(l.logPath), name), nil
+		}
+	}
+	return "", os.ErrNotExist
+}
+
+// GetLastBytes returns the log lines found in the last n bytes of the log,
+// including archived segments if the current log file is smaller than n.
 func (l *Logger) GetLastBytes(n int64) (domain.LogChunk, error) {
+	l.logMux.Lock()
+	defer l.logMux.Unlock()
+
 	if l.fd == nil {
 		return domain.LogChunk{}, errors.New("trying to get Last Bytes of closed log")
 	}
 
-	chunk, err := getChunk(l.fd, -n, 0)
+	chunk, err := l.lastBytes(n)
 	if err != nil {
-		l.getHostLogger("GetLastBytes getChunk()").Error(err)
+		l.getHostLogger("GetLastBytes lastBytes()").Error(err)
 		return domain.LogChunk{}, err
 	}
 
 	return chunk, nil
 }
 
+// lastBytes gets the end of the log and prepends lines
+// from archives if needed. Caller must hold logMux.
+func (l *Logger) lastBytes(n int64) (domain.LogChunk, error) {
+	chunk, err := getChunk(l.fd, -n, 0)
+	if err != nil {
+		return domain.LogChunk{}, err
+	}
+	fStat, err := l.fd.Stat()
+	if err != nil {
+		return domain.LogChunk{}, err
+	}
+	if fStat.Size() >= n {
+		return chunk, nil
+	}
+
+	prev, err := l.archivedTail(n - fStat.Size())
+	if err != nil {
+		// archives are a bonus, return what we have
+		l.getHostLogger("lastBytes archivedTail()").Error(err)
+		return chunk, nil
+	}
+	if len(prev) == 0 {
+		return chunk, nil
+	}
+	return domain.LogChunk{
+		From:    -int64(len(prev)),
+		To:      chunk.To,
+		Content: string(prev) + chunk.Content}, nil
+}
+
+// archivedTail returns the whole lines found in the last n bytes
+// of the archived segments taken together.
+func (l *Logger) archivedTail(n int64) ([]byte, error) {
+	archives, err := l.archives()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(l.logPath)
+	var buf []byte
+	for i := len(archives) - 1; i >= 0 && int64(len(buf)) <= n; i-- {
+		data, err := readArchive(filepath.Join(dir, archives[i].Name))
+		if err != nil {
+			return nil, err
+		}
+		buf = append(data, buf...)
+	}
+	if int64(len(buf)) <= n {
+		return buf, nil
+	}
+	// keep the byte before the cut to know if the first line is whole
+	buf = buf[int64(len(buf))-n-1:]
+	return buf[bytes.Index(buf, []byte("\n"))+1:], nil
+}
+
+func readArchive(p string) ([]byte, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	return io.ReadAll(gz)
+}
+
+// segmentStart returns the time of the first entry in the log file,
+// or now if it is empty or the time can't be read.
+func segmentStart(fd *os.File) time.Time {
+	buf := make([]byte, 64)
+	n, _ := fd.ReadAt(buf, 0)
+	first := string(buf[:n])
+	if i := strings.IndexByte(first, ' '); i != -1 {
+		t, err := time.Parse(time.RFC3339, first[:i])
+		if err == nil {
+			return t
+		}
+	}
+	return time.Now()
+}
+
 func (l *Logger) SubscribeEntries(n int64) (domain.LogChunk, <-chan string, error) {
 	// here a lock seems appropirate.
 	// May need to split the GetLAstBytes fn. into high level / low level.
@@ -108,9 +387,9 @@ func (l *Logger) SubscribeEntries(n int64) (domain.LogChunk, <-chan string, erro
 		return domain.LogChunk{}, nil, errors.New("Log is closed")
 	}
 
-	chunk, err := getChunk(l.fd, -n, 0)
+	chunk, err := l.lastBytes(n)
 	if err != nil {
-		l.getHostLogger("SubscribeEntries getChunk()").Error(err)
+		l.getHostLogger("SubscribeEntries lastBytes()").Error(err)
 		return domain.LogChunk{}, nil, err
 	}
 
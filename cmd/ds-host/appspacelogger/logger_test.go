@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/google/go-cmp/cmp"
@@ -139,3 +140,156 @@ func TestGetLast(t *testing.T) {
 		t.Error(cmp.Diff(chunk, expected))
 	}
 }
+
+func TestRotateSize(t *testing.T) {
+	dir := t.TempDir()
+
+	l := Logger{
+		logPath:  filepath.Join(dir, "log.txt"),
+		rotation: rotation{maxSize: 30}}
+	err := l.open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.close()
+
+	l.writeEntry("first entry is long-ish")
+	l.writeEntry("second entry")
+	l.writeEntry("third")
+
+	archives, err := l.Archives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archives) != 1 {
+		t.Fatalf("expected one archive, got %v", archives)
+	}
+	data, err := readArchive(filepath.Join(dir, archives[0].Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "first entry is long-ish\n" {
+		t.Errorf("unexpected archive content: %v", string(data))
+	}
+	content, _ := os.ReadFile(l.logPath)
+	if string(content) != "second entry\nthird\n" {
+		t.Errorf("unexpected log content: %v", string(content))
+	}
+}
+
+func TestRotateAge(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "log.txt")
+	old := time.Now().Add(-48 * time.Hour).Format(time.RFC3339)
+	err := os.WriteFile(logFile, []byte(old+" ds-host old entry\n"), 0664)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := Logger{
+		logPath:  logFile,
+		rotation: rotation{maxAge: 24 * time.Hour}}
+	err = l.open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.close()
+
+	l.Log("ds-host", "new entry")
+
+	archives, _ := l.Archives()
+	if len(archives) != 1 {
+		t.Fatalf("expected one archive, got %v", archives)
+	}
+}
+
+func TestGetLastAcrossArchives(t *testing.T) {
+	dir := t.TempDir()
+
+	l := Logger{
+		logPath:  filepath.Join(dir, "log.txt"),
+		rotation: rotation{maxSize: 10}}
+	err := l.open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.close()
+
+	for _, e := range []string{"aaaa", "bbbb", "cccc", "dddd", "eeee"} {
+		err = l.writeEntry(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// archive names have millisecond resolution
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	cases := []struct {
+		n        int64
+		expected domain.LogChunk
+	}{
+		{5, domain.LogChunk{From: 0, To: 5, Content: "eeee\n"}},
+		{10, domain.LogChunk{From: -5, To: 5, Content: "dddd\neeee\n"}},
+		{14, domain.LogChunk{From: -5, To: 5, Content: "dddd\neeee\n"}},
+		{15, domain.LogChunk{From: -10, To: 5, Content: "cccc\ndddd\neeee\n"}},
+		{100, domain.LogChunk{From: -20, To: 5, Content: "aaaa\nbbbb\ncccc\ndddd\neeee\n"}},
+	}
+	for _, c := range cases {
+		chunk, err := l.GetLastBytes(c.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(chunk, c.expected) {
+			t.Errorf("n: %v: %v", c.n, cmp.Diff(chunk, c.expected))
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	l := Logger{
+		logPath:  filepath.Join(dir, "log.txt"),
+		rotation: rotation{keepArchives: 2, keepAge: 10 * 24 * time.Hour}}
+
+	now := time.Now()
+	for _, d := range []int{20, 5, 3, 1} {
+		p := filepath.Join(dir, l.archiveName(now.Add(-time.Duration(d)*24*time.Hour)))
+		err := os.WriteFile(p, []byte{}, 0664)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(dir, "other.txt.gz"), []byte{}, 0664)
+
+	l.prune(now)
+
+	archives, err := l.archives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archives) != 2 || archives[0].Name != l.archiveName(now.Add(-3*24*time.Hour)) {
+		t.Errorf("unexpected archives: %v", archives)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "other.txt.gz")); err != nil {
+		t.Error("other file should not be pruned")
+	}
+}
+
+func TestArchivePath(t *testing.T) {
+	dir := t.TempDir()
+	l := Logger{logPath: filepath.Join(dir, "log.txt")}
+	name := l.archiveName(time.Now())
+	os.WriteFile(filepath.Join(dir, name), []byte{}, 0664)
+
+	p, err := l.ArchivePath(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != filepath.Join(dir, name) {
+		t.Errorf("unexpected path %v", p)
+	}
+	_, err = l.ArchivePath("../" + name)
+	if !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+package appspacelogger
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+const defaultQueryLimit = 100
+const maxQueryLimit = 1000
+
+// Query returns the log entries that match q, newest first.
+// It reads the current log file, then archives from newest to oldest,
+// skipping archives that can't have entries in the queried time range.
+func (l *Logger) Query(q domain.LogQuery) (domain.LogQueryResult, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	if limit > maxQueryLimit {
+		limit = maxQueryLimit
+	}
+	c, err := parseCursor(q.Before)
+	if err != nil {
+		return domain.LogQueryResult{}, err
+	}
+
+	current, archives, err := l.segments()
+	if err != nil {
+		l.getHostLogger("Query segments()").Error(err)
+		return domain.LogQueryResult{}, err
+	}
+
+	s := &logScan{q: q, c: c, limit: limit, text: strings.ToLower(q.Text)}
+	s.scan(current)
+
+	// entries in an archive are between the previous archive's rotation and its own
+	upper := q.To
+	if !c.t.IsZero() && (upper.IsZero() || c.t.Before(upper)) {
+		upper = c.t.Add(time.Second)
+	}
+	dir := filepath.Dir(l.logPath)
+	for i := len(archives) - 1; i >= 0 && !s.done(); i-- {
+		if !q.From.IsZero() && archives[i].Rotated.Before(q.From) {
+			break
+		}
+		if i > 0 && !upper.IsZero() && upper.Before(archives[i-1].Rotated.Truncate(time.Second)) {
+			continue
+		}
+		data, err := readArchive(filepath.Join(dir, archives[i].Name))
+		if os.IsNotExist(err) {
+			continue // pruned since we listed them
+		}
+		if err != nil {
+			l.getHostLogger("Query readArchive()").Error(err)
+			return domain.LogQueryResult{}, err
+		}
+		s.scan(data)
+	}
+
+	return s.result(), nil
+}
+
+// segments returns the content of the current log file
+// and the list of archives
+func (l *Logger) segments() ([]byte, []domain.LogArchive, error) {
+	l.logMux.Lock()
+	defer l.logMux.Unlock()
+
+	var current []byte
+	var err error
+	if l.fd != nil {
+		var fStat os.FileInfo
+		fStat, err = l.fd.Stat()
+		if err != nil {
+			return nil, nil, err
+		}
+		current = make([]byte, fStat.Size())
+		_, err = l.fd.ReadAt(current, 0)
+	} else {
+		current, err = os.ReadFile(l.logPath)
+		if os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	archives, err := l.archives()
+	if err != nil {
+		return nil, nil, err
+	}
+	return current, archives, nil
+}
+
+// logScan collects matching entries across segments
+type logScan struct {
+	q     domain.LogQuery
+	c     cursor
+	limit int
+	text  string
+
+	entries []domain.LogEntry
+	next    cursor
+
+	// entries seen at the current time, for the cursor
+	curTime  time.Time
+	curCount int
+	// count of last matched entry
+	lastCount int
+}
+
+func (s *logScan) done() bool {
+	return !s.next.t.IsZero()
+}
+
+func (s *logScan) scan(data []byte) {
+	lines := bytes.Split(data, []byte("\n"))
+	for i := len(lines) - 1; i >= 0 && !s.done(); i-- {
+		e, ok := parseEntry(string(lines[i]))
+		if !ok {
+			continue
+		}
+		if !e.Time.Equal(s.curTime) {
+			s.curTime = e.Time
+			s.curCount = 0
+		}
+		s.curCount++
+
+		if !s.c.t.IsZero() {
+			if e.Time.After(s.c.t) || (e.Time.Equal(s.c.t) && s.curCount <= s.c.skip) {
+				continue
+			}
+		}
+		if !s.match(e) {
+			continue
+		}
+		if len(s.entries) == s.limit {
+			last := s.entries[len(s.entries)-1]
+			s.next = cursor{t: last.Time, skip: s.lastCount}
+			return
+		}
+		s.entries = append(s.entries, e)
+		s.lastCount = s.curCount
+	}
+}
+
+func (s *logScan) match(e domain.LogEntry) bool {
+	if !s.q.From.IsZero() && e.Time.Before(s.q.From) {
+		return false
+	}
+	if !s.q.To.IsZero() && !e.Time.Before(s.q.To) {
+		return false
+	}
+	if s.q.Source != "" && !strings.HasPrefix(e.Source, s.q.Source) {
+		return false
+	}
+	return s.text == "" || strings.Contains(strings.ToLower(e.Message), s.text)
+}
+
+func (s *logScan) result() domain.LogQueryResult {
+	ret := domain.LogQueryResult{Entries: s.entries}
+	if ret.Entries == nil {
+		ret.Entries = []domain.LogEntry{}
+	}
+	if s.done() {
+		ret.Next = s.next.String()
+	}
+	return ret
+}
+
+// parseEntry splits a log line written by Log
+func parseEntry(line string) (domain.LogEntry, bool) {
+	pieces := strings.SplitN(line, " ", 3)
+	if len(pieces) < 2 {
+		return domain.LogEntry{}, false
+	}
+	t, err := time.Parse(time.RFC3339, pieces[0])
+	if err != nil {
+		return domain.LogEntry{}, false
+	}
+	e := domain.LogEntry{Time: t, Source: pieces[1]}
+	if len(pieces) == 3 {
+		e.Message = pieces[2]
+	}
+	return e, true
+}
+
+// cursor points to the last entry returned by a query:
+// entries with time t, of which skip were already returned or passed over.
+type cursor struct {
+	t    time.Time
+	skip int
+}
+
+func (c cursor) String() string {
+	return fmt.Sprintf("%s,%d", c.t.UTC().Format(time.RFC3339), c.skip)
+}
+
+func parseCursor(str string) (cursor, error) {
+	if str == "" {
+		return cursor{}, nil
+	}
+	pieces := strings.SplitN(str, ",", 2)
+	if len(pieces) != 2 {
+		return cursor{}, domain.ErrLogCursor
+	}
+	t, err := time.Parse(time.RFC3339, pieces[0])
+	if err != nil {
+		return cursor{}, domain.ErrLogCursor
+	}
+	skip, err := strconv.Atoi(pieces[1])
+	if err != nil || skip < 0 {
+		return cursor{}, domain.ErrLogCursor
+	}
+	return cursor{t: t, skip: skip}, nil
+}
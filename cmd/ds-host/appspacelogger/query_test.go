@@ -0,0 +1,127 @@
+package appspacelogger
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+func TestParseEntry(t *testing.T) {
+	e, ok := parseEntry("2026-10-17T04:16:42Z sandbox-3-stdout hello world")
+	if !ok {
+		t.Fatal("expected ok")
+	}
+	if e.Source != "sandbox-3-stdout" || e.Message != "hello world" || e.Time.Unix() != 1792210602 {
+		t.Errorf("unexpected entry: %v", e)
+	}
+	_, ok = parseEntry("not a log line")
+	if ok {
+		t.Error("expected not ok")
+	}
+	_, ok = parseEntry("")
+	if ok {
+		t.Error("expected not ok for empty line")
+	}
+}
+
+func TestCursor(t *testing.T) {
+	c := cursor{t: time.Date(2026, time.October, 17, 4, 16, 42, 0, time.UTC), skip: 3}
+	c2, err := parseCursor(c.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c2.t.Equal(c.t) || c2.skip != 3 {
+		t.Errorf("cursor changed: %v", c2)
+	}
+	for _, s := range []string{"abc", "2026-10-17T04:16:42Z", "2026-10-17T04:16:42Z,-1"} {
+		if _, err := parseCursor(s); err == nil {
+			t.Errorf("expected error for %v", s)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	dir := t.TempDir()
+	l := Logger{logPath: filepath.Join(dir, "log.txt")}
+
+	archived := "2026-10-17T01:00:00Z ds-host starting\n" +
+		"2026-10-17T01:00:01Z sandbox-1-stdout request one\n"
+	writeArchive(t, dir, l.archiveName(time.Date(2026, time.October, 17, 1, 30, 0, 0, time.UTC)), archived)
+
+	current := "2026-10-17T02:00:00Z sandbox-2-stdout request two\n" +
+		"2026-10-17T02:00:00Z sandbox-2-stderr Error in request\n" +
+		"2026-10-17T02:00:00Z ds-host pausing\n" +
+		"2026-10-17T02:00:05Z sandbox-2-stdout request three\n"
+	err := os.WriteFile(l.logPath, []byte(current), 0664)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := l.Query(domain.LogQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Entries) != 6 || res.Next != "" {
+		t.Fatalf("expected all 6 entries: %v", res)
+	}
+	if res.Entries[0].Message != "request three" || res.Entries[5].Message != "starting" {
+		t.Errorf("expected newest first: %v", res.Entries)
+	}
+
+	res, _ = l.Query(domain.LogQuery{Source: "sandbox", Text: "REQUEST"})
+	if len(res.Entries) != 4 {
+		t.Errorf("expected 4 sandbox request entries: %v", res.Entries)
+	}
+
+	res, _ = l.Query(domain.LogQuery{
+		From: time.Date(2026, time.October, 17, 2, 0, 0, 0, time.UTC),
+		To:   time.Date(2026, time.October, 17, 2, 0, 5, 0, time.UTC)})
+	if len(res.Entries) != 3 {
+		t.Errorf("expected 3 entries in range: %v", res.Entries)
+	}
+
+	// page through entries that share a time
+	var msgs []string
+	q := domain.LogQuery{Limit: 2}
+	for i := 0; i < 5; i++ {
+		res, err = l.Query(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range res.Entries {
+			msgs = append(msgs, e.Message)
+		}
+		if res.Next == "" {
+			break
+		}
+		q.Before = res.Next
+	}
+	expected := []string{"request three", "pausing", "Error in request", "request two", "request one", "starting"}
+	if len(msgs) != len(expected) {
+		t.Fatalf("unexpected paged entries: %v", msgs)
+	}
+	for i, m := range expected {
+		if msgs[i] != m {
+			t.Errorf("unexpected paged entries: %v", msgs)
+			break
+		}
+	}
+}
+
+func writeArchive(t *testing.T, dir string, name string, content string) {
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte(content))
+	err = gz.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
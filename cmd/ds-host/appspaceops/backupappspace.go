@@ -9,15 +9,29 @@ import (
 
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
 	"github.com/teleclimber/DropServer/cmd/ds-host/record"
-	"github.com/teleclimber/DropServer/internal/zipfns"
+	"github.com/teleclimber/DropServer/internal/snapshot"
 )
 
 // Do we have a db table of backups, or do we just have zip files in a dedicated folder?
 // -> definitely files. Probably zipped for space and ease of organization.
 // Maybe they could all live in the same location?
+// Backups are now snapshots: a manifest file in the backups dir
+// with the file contents deduplicated in the appspace's backup chunk store.
+// Older backups are zip files, and they can still be restored.
 
 var fileDateFormat = "2006-01-02_1504"
 
+const zipExt = ".zip"
+const snapshotExt = ".snapshot"
+
+// scheduledMarker is appended to the date of backups created by the
+// BackupScheduler. Only backups that carry it are pruned by the retention policy.
+const scheduledMarker = "_auto"
+
+// encZipExt is the extension of an encrypted zip backup.
+// Encrypted backups are only created for export.
+const encZipExt = ".zip.enc"
+
 type BackupAppspace struct {
 	AppspaceModel interface {
 		GetFromID(appspaceID domain.AppspaceID) (*domain.Appspace, error)
@@ -41,7 +55,11 @@ type BackupAppspace struct {
 		Data(string) string
 		Backups(string) string
 		Backup(string, string) string
+		BackupChunks(string) string
 	} `checkinject:"required"`
+	RemoteBackups interface {
+		Queue(appspaceID domain.AppspaceID, backupFile string)
+	} `checkinject:"optional"`
 }
 
 // CreateBackup everything that an appspace might need to be re-created somewhere.
@@ -54,12 +72,26 @@ func (e *BackupAppspace) CreateBackup(appspaceID domain.AppspaceID) (string, err
 
 	e.AppspaceLogger.Log(appspaceID, "ds-host", "backing up appspace data")
 
-	zipFile, err := e.createZip(appspaceID)
+	backupFile, err := e.createSnapshot(appspaceID, "")
 
-	return zipFile, err
+	return backupFile, err
 }
 
-// BackupNoPause creates a backup zip file like CreateBackup
+// CreateScheduledBackup creates a backup like CreateBackup
+// but marks it as created by the backup scheduler
+// so that it can be pruned by the retention policy.
+func (e *BackupAppspace) CreateScheduledBackup(appspaceID domain.AppspaceID) (string, error) {
+	pauseCh := e.AppspaceStatus.WaitTempPaused(appspaceID, "backup")
+	defer close(pauseCh)
+
+	e.AppspaceLogger.Log(appspaceID, "ds-host", "backing up appspace data (scheduled)")
+
+	backupFile, err := e.createSnapshot(appspaceID, scheduledMarker)
+
+	return backupFile, err
+}
+
+// BackupNoPause creates a backup like CreateBackup
 // but it expects the appspace to be already paused
 func (e *BackupAppspace) BackupNoPause(appspaceID domain.AppspaceID) (string, error) {
 	if !e.AppspaceStatus.IsTempPaused(appspaceID) {
@@ -68,9 +100,9 @@ func (e *BackupAppspace) BackupNoPause(appspaceID domain.AppspaceID) (string, er
 
 	e.AppspaceLogger.Log(appspaceID, "ds-host", "backing up appspace data")
 
-	zipFile, err := e.createZip(appspaceID)
+	backupFile, err := e.createSnapshot(appspaceID, "")
 
-	return zipFile, err
+	return backupFile, err
 }
 
 // closeAll closes appspace files that might remain open after an appspace has been paused/stopped.
@@ -91,26 +123,34 @@ func (e *BackupAppspace) closeAll(appspaceID domain.AppspaceID) error {
 	return nil
 }
 
-func (e *BackupAppspace) getZipFilename(dir string) (string, error) {
+// getBackupFilename returns a file name for a new backup with marker and extension ext
+// A name is not reused even if the existing backup has a different extension.
+func (e *BackupAppspace) getBackupFilename(dir string, marker string, ext string) (string, error) {
 	dateStr := time.Now().Format(fileDateFormat)
 	increment := 0
 	incStr := ""
 	for {
-		_, err := os.Stat(filepath.Join(dir, dateStr+incStr+".zip"))
-		if os.IsNotExist(err) {
+		exists := false
+		for _, x := range []string{zipExt, snapshotExt} {
+			_, err := os.Stat(filepath.Join(dir, dateStr+incStr+marker+x))
+			if err == nil {
+				exists = true
+			} else if !os.IsNotExist(err) {
+				e.getLogger("getBackupFilename, os.Stat").Error(err)
+				return "", err
+			}
+		}
+		if !exists {
 			break
-		} else if err != nil {
-			e.getLogger("getZipFilename, os.Stat").Error(err)
-			return "", err
 		}
 		increment++
 		incStr = fmt.Sprintf("_%d", increment)
 	}
 
-	return dateStr + incStr + ".zip", nil
+	return dateStr + incStr + marker + ext, nil
 }
 
-func (e *BackupAppspace) createZip(appspaceID domain.AppspaceID) (string, error) {
+func (e *BackupAppspace) createSnapshot(appspaceID domain.AppspaceID, marker string) (string, error) {
 
 	// generate additional data and save to data dir
 	// (big json meta data and users list)
@@ -131,24 +171,35 @@ func (e *BackupAppspace) createZip(appspaceID domain.AppspaceID) (string, error)
 	// copy files / zip them...
 	appspace, err := e.AppspaceModel.GetFromID(appspaceID)
 	if err != nil {
-		e.getLogger("createZip, get appspace").Error(err)
+		e.getLogger("createSnapshot, get appspace").Error(err)
 		return "", err
 	}
 
 	dataDir := e.AppspaceLocation2Path.Data(appspace.LocationKey)
 	backupsDir := e.AppspaceLocation2Path.Backups(appspace.LocationKey)
-	zipFile, err := e.getZipFilename(backupsDir)
+	backupFile, err := e.getBackupFilename(backupsDir, marker, snapshotExt)
+	if err != nil {
+		return "", err
+	}
+
+	store := snapshot.Store{Dir: e.AppspaceLocation2Path.BackupChunks(appspace.LocationKey)}
+	manifest, err := store.Create(dataDir)
 	if err != nil {
+		e.getLogger("createSnapshot, store.Create").AppspaceID(appspaceID).Error(err)
 		return "", err
 	}
 
-	err = zipfns.Zip(dataDir, e.AppspaceLocation2Path.Backup(appspace.LocationKey, zipFile))
+	err = snapshot.WriteManifest(manifest, e.AppspaceLocation2Path.Backup(appspace.LocationKey, backupFile))
 	if err != nil {
-		e.getLogger("createZip, zipfns.Zip").Error(err)
+		e.getLogger("createSnapshot, snapshot.WriteManifest").AppspaceID(appspaceID).Error(err)
 		return "", err
 	}
 
-	return zipFile, nil
+	if e.RemoteBackups != nil {
+		e.RemoteBackups.Queue(appspaceID, backupFile)
+	}
+
+	return backupFile, nil
 }
 
 func (e *BackupAppspace) getLogger(note string) *record.DsLogger {
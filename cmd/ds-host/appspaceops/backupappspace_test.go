@@ -4,7 +4,13 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/runtimeconfig"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
 )
 
 func TestGetZipFilename(t *testing.T) {
@@ -15,7 +21,7 @@ func TestGetZipFilename(t *testing.T) {
 	defer os.RemoveAll(dir)
 
 	e := &BackupAppspace{}
-	_, err = e.getZipFilename(dir)
+	_, err = e.getBackupFilename(dir, "", snapshotExt)
 	if err != nil {
 		t.Error(err)
 	}
@@ -28,7 +34,9 @@ func TestGetZipFilenameDupe(t *testing.T) {
 	}
 	defer os.RemoveAll(dir)
 
+	origFormat := fileDateFormat
 	fileDateFormat = "abc"
+	defer func() { fileDateFormat = origFormat }()
 
 	emptyFile, err := os.Create(filepath.Join(dir, "abc.zip"))
 	if err != nil {
@@ -38,11 +46,92 @@ func TestGetZipFilenameDupe(t *testing.T) {
 
 	e := &BackupAppspace{}
 
-	fn, err := e.getZipFilename(dir)
+	fn, err := e.getBackupFilename(dir, "", snapshotExt)
+	if err != nil {
+		t.Error(err)
+	}
+	if fn != "abc_1.snapshot" {
+		t.Error("unexpected file name: " + fn)
+	}
+
+	fn, err = e.getBackupFilename(dir, scheduledMarker, snapshotExt)
 	if err != nil {
 		t.Error(err)
 	}
-	if fn != "abc_1.zip" {
+	if fn != "abc_auto.snapshot" {
 		t.Error("unexpected file name: " + fn)
 	}
 }
+
+func TestSnapshotBackupRestore(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	dir := t.TempDir()
+	cfg := &domain.RuntimeConfig{}
+	cfg.Exec.AppspacesPath = dir
+	l2p := &runtimeconfig.AppspaceLocation2Path{Config: cfg}
+
+	asID := domain.AppspaceID(7)
+	loc := "as7"
+	appspace := &domain.Appspace{AppspaceID: asID, LocationKey: loc}
+
+	err := os.MkdirAll(l2p.Backups(loc), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.MkdirAll(l2p.Files(loc), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(l2p.Files(loc), "hello.txt"), []byte("hello"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appspaceStatus := testmocks.NewMockAppspaceStatus(mockCtrl)
+	appspaceStatus.EXPECT().LockClosed(asID).Return(make(chan struct{}), true)
+	sandboxManager := testmocks.NewMockSandboxManager(mockCtrl)
+	sandboxManager.EXPECT().StopAppspace(asID)
+	appspaceMetaDB := testmocks.NewMockAppspaceMetaDB(mockCtrl)
+	appspaceMetaDB.EXPECT().CloseConn(asID).Return(nil)
+	appspaceLogger := testmocks.NewMockAppspaceLogger(mockCtrl)
+	appspaceLogger.EXPECT().Close(asID)
+	appspaceModel := testmocks.NewMockAppspaceModel(mockCtrl)
+	appspaceModel.EXPECT().GetFromID(asID).Return(appspace, nil).Times(2)
+
+	b := &BackupAppspace{
+		AppspaceModel:         appspaceModel,
+		SandboxManager:        sandboxManager,
+		AppspaceStatus:        appspaceStatus,
+		AppspaceMetaDB:        appspaceMetaDB,
+		AppspaceLogger:        appspaceLogger,
+		AppspaceLocation2Path: l2p,
+	}
+	backupFile, err := b.createSnapshot(asID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(backupFile, snapshotExt) {
+		t.Errorf("expected snapshot file, got %v", backupFile)
+	}
+
+	r := &RestoreAppspace{
+		AppspaceModel:         appspaceModel,
+		AppspaceLocation2Path: l2p,
+	}
+	r.Init()
+	tok, err := r.PrepareBackup(asID, backupFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.delete(tok)
+
+	data, err := os.ReadFile(filepath.Join(r.tokens[tok].tempDir, "files", "hello.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("unexpected restored data: %s", data)
+	}
+}
@@ -0,0 +1,248 @@
+package appspaceops
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+	"github.com/teleclimber/DropServer/internal/schedule"
+)
+
+// BackupScheduler creates appspace backups according to their schedules
+// and prunes old backups according to the retention policy.
+// Scheduled backups are run one at a time.
+type BackupScheduler struct {
+	BackupScheduleModel interface {
+		GetAllEnabled() ([]domain.BackupSchedule, error)
+		SetLastRun(domain.AppspaceID, time.Time) error
+	} `checkinject:"required"`
+	AppspaceModel interface {
+		GetFromID(domain.AppspaceID) (*domain.Appspace, error)
+	} `checkinject:"required"`
+	AppspaceFilesModel interface {
+		GetBackups(locationKey string) ([]string, error)
+		DeleteBackup(locationKey string, filename string) error
+	} `checkinject:"required"`
+	BackupAppspace interface {
+		CreateScheduledBackup(appspaceID domain.AppspaceID) (string, error)
+	} `checkinject:"required"`
+	AppspaceLogger interface {
+		Log(appspaceID domain.AppspaceID, source string, message string)
+	} `checkinject:"required"`
+
+	ticker  *time.Ticker
+	stop    chan struct{}
+	stopped chan struct{}
+	runMux  sync.Mutex
+}
+
+// Start checks for due backups every minute
+func (s *BackupScheduler) Start() {
+	s.stop = make(chan struct{})
+	s.stopped = make(chan struct{})
+	s.ticker = time.NewTicker(time.Minute)
+
+	go func() {
+		defer close(s.stopped)
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-s.ticker.C:
+				s.runDue(time.Now())
+			}
+		}
+	}()
+}
+
+// Stop prevents further backups from starting
+// and waits for an ongoing backup to finish.
+func (s *BackupScheduler) Stop() {
+	s.ticker.Stop()
+	close(s.stop)
+	<-s.stopped
+}
+
+// NextRun returns the time of the next scheduled backup
+func (s *BackupScheduler) NextRun(bs domain.BackupSchedule) (time.Time, error) {
+	sched, err := schedule.Parse(bs.Schedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return sched.Next(scheduleBase(bs)), nil
+}
+
+// runDue runs the backups that are due at now
+func (s *BackupScheduler) runDue(now time.Time) {
+	s.runMux.Lock()
+	defer s.runMux.Unlock()
+
+	schedules, err := s.BackupScheduleModel.GetAllEnabled()
+	if err != nil {
+		return
+	}
+
+	for _, bs := range schedules {
+		if s.isStopping() {
+			return
+		}
+		next, err := s.NextRun(bs)
+		if err != nil {
+			s.getLogger("runDue() NextRun").AppspaceID(bs.AppspaceID).Error(err)
+			continue
+		}
+		if next.IsZero() || next.After(now) {
+			continue
+		}
+		s.runSchedule(bs, now)
+	}
+}
+
+func (s *BackupScheduler) runSchedule(bs domain.BackupSchedule, now time.Time) {
+	appspaceID := bs.AppspaceID
+
+	// Set last run first so that a failing backup is not retried every minute.
+	err := s.BackupScheduleModel.SetLastRun(appspaceID, now)
+	if err != nil {
+		s.getLogger("runSchedule() SetLastRun").AppspaceID(appspaceID).Error(err)
+		return
+	}
+
+	appspace, err := s.AppspaceModel.GetFromID(appspaceID)
+	if err != nil {
+		s.getLogger("runSchedule() GetFromID").AppspaceID(appspaceID).Error(err)
+		return
+	}
+
+	_, err = s.BackupAppspace.CreateScheduledBackup(appspaceID)
+	if err != nil {
+		s.AppspaceLogger.Log(appspaceID, "ds-host", "scheduled backup failed: "+err.Error())
+		return
+	}
+
+	backups, err := s.AppspaceFilesModel.GetBackups(appspace.LocationKey)
+	if err != nil {
+		s.getLogger("runSchedule() GetBackups").AppspaceID(appspaceID).Error(err)
+		return
+	}
+	prune := backupsToPrune(backups, bs)
+	for _, b := range prune {
+		err = s.AppspaceFilesModel.DeleteBackup(appspace.LocationKey, b)
+		if err != nil {
+			s.getLogger("runSchedule() DeleteBackup").AppspaceID(appspaceID).Error(err)
+		}
+	}
+	if len(prune) != 0 {
+		s.AppspaceLogger.Log(appspaceID, "ds-host", fmt.Sprintf("removed old backups: %s", strings.Join(prune, ", ")))
+	}
+}
+
+func (s *BackupScheduler) isStopping() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *BackupScheduler) getLogger(note string) *record.DsLogger {
+	r := record.NewDsLogger().AddNote("BackupScheduler")
+	if note != "" {
+		r.AddNote(note)
+	}
+	return r
+}
+
+// scheduleBase returns the time from which the next run is calculated.
+// Schedules are evaluated in local time.
+func scheduleBase(bs domain.BackupSchedule) time.Time {
+	if bs.LastRun.Valid {
+		return bs.LastRun.Time.In(time.Local)
+	}
+	return bs.Created.In(time.Local)
+}
+
+type datedBackup struct {
+	filename string
+	date     time.Time
+}
+
+// backupsToPrune returns the backup files that fall outside the retention policy.
+// Only backups created by the scheduler are considered,
+// so manual backups and files that are not named like backups are never pruned.
+// If the policy keeps nothing, nothing is pruned.
+func backupsToPrune(backups []string, bs domain.BackupSchedule) []string {
+	if bs.KeepLast == 0 && bs.KeepDaily == 0 && bs.KeepWeekly == 0 && bs.KeepMonthly == 0 {
+		return nil
+	}
+
+	dated := make([]datedBackup, 0, len(backups))
+	for _, b := range backups {
+		if len(b) < len(fileDateFormat) || !isScheduledBackup(b) {
+			continue
+		}
+		date, err := time.ParseInLocation(fileDateFormat, b[:len(fileDateFormat)], time.Local)
+		if err != nil {
+			continue
+		}
+		dated = append(dated, datedBackup{filename: b, date: date})
+	}
+	// newest first. Within a minute "_1_auto.zip" is newer than "_auto.zip".
+	// The marker is left out of the comparison so that it sorts like "_1.zip" and ".zip".
+	sort.Slice(dated, func(i, j int) bool {
+		if dated[i].date.Equal(dated[j].date) {
+			return strings.Replace(dated[i].filename, scheduledMarker, "", 1) > strings.Replace(dated[j].filename, scheduledMarker, "", 1)
+		}
+		return dated[i].date.After(dated[j].date)
+	})
+
+	keep := make(map[string]bool)
+	for i := 0; i < bs.KeepLast && i < len(dated); i++ {
+		keep[dated[i].filename] = true
+	}
+	keepBuckets(dated, keep, bs.KeepDaily, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepBuckets(dated, keep, bs.KeepWeekly, func(t time.Time) string {
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%d-%d", y, w)
+	})
+	keepBuckets(dated, keep, bs.KeepMonthly, func(t time.Time) string {
+		return t.Format("2006-01")
+	})
+
+	prune := []string{}
+	for _, d := range dated {
+		if !keep[d.filename] {
+			prune = append(prune, d.filename)
+		}
+	}
+	return prune
+}
+
+// isScheduledBackup returns true if the backup file was created by the scheduler
+func isScheduledBackup(backupFile string) bool {
+	base := strings.TrimSuffix(strings.TrimSuffix(backupFile, snapshotExt), zipExt)
+	return strings.HasSuffix(base, scheduledMarker)
+}
+
+// keepBuckets keeps the newest backup of each of the num most recent buckets
+func keepBuckets(dated []datedBackup, keep map[string]bool, num int, bucket func(time.Time) string) {
+	seen := make(map[string]bool)
+	for _, d := range dated {
+		if len(seen) >= num {
+			return
+		}
+		b := bucket(d.date)
+		if seen[b] {
+			continue
+		}
+		seen[b] = true
+		keep[d.filename] = true
+	}
+}
@@ -0,0 +1,111 @@
+package appspaceops
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+	"github.com/teleclimber/DropServer/internal/nulltypes"
+)
+
+func TestBackupsToPrune(t *testing.T) {
+	backups := []string{
+		"2024-03-13_0300_1_auto.zip",
+		"2024-03-13_0300_auto.zip",
+		"2024-03-12_0300_auto.zip",
+		"2024-03-11_1500_auto.zip",
+		"2024-03-11_0300_auto.zip",
+		"2024-03-04_0300_auto.zip",
+		"2024-02-20_0300_auto.zip",
+		"2024-01-20_0300_auto.zip",
+		"2024-03-13_0200.snapshot",
+		"2024-01-01_0300.zip",
+		"not-a-backup.zip",
+	}
+	cases := []struct {
+		desc     string
+		schedule domain.BackupSchedule
+		prune    []string
+	}{{
+		desc:     "keep nothing prunes nothing",
+		schedule: domain.BackupSchedule{},
+		prune:    nil,
+	}, {
+		desc:     "keep last 2",
+		schedule: domain.BackupSchedule{KeepLast: 2},
+		prune:    []string{"2024-03-12_0300_auto.zip", "2024-03-11_1500_auto.zip", "2024-03-11_0300_auto.zip", "2024-03-04_0300_auto.zip", "2024-02-20_0300_auto.zip", "2024-01-20_0300_auto.zip"},
+	}, {
+		desc:     "keep daily 3",
+		schedule: domain.BackupSchedule{KeepDaily: 3},
+		prune:    []string{"2024-03-13_0300_auto.zip", "2024-03-11_0300_auto.zip", "2024-03-04_0300_auto.zip", "2024-02-20_0300_auto.zip", "2024-01-20_0300_auto.zip"},
+	}, {
+		desc:     "keep weekly 2",
+		schedule: domain.BackupSchedule{KeepWeekly: 2},
+		prune:    []string{"2024-03-13_0300_auto.zip", "2024-03-12_0300_auto.zip", "2024-03-11_1500_auto.zip", "2024-03-11_0300_auto.zip", "2024-02-20_0300_auto.zip", "2024-01-20_0300_auto.zip"},
+	}, {
+		desc:     "keep last 1 and monthly 3",
+		schedule: domain.BackupSchedule{KeepLast: 1, KeepMonthly: 3},
+		prune:    []string{"2024-03-13_0300_auto.zip", "2024-03-12_0300_auto.zip", "2024-03-11_1500_auto.zip", "2024-03-11_0300_auto.zip", "2024-03-04_0300_auto.zip"},
+	}}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			prune := backupsToPrune(backups, c.schedule)
+			if !reflect.DeepEqual(prune, c.prune) {
+				t.Errorf("expected %v, got %v", c.prune, prune)
+			}
+		})
+	}
+}
+
+func TestRunDue(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	now := time.Date(2024, 3, 13, 3, 0, 30, 0, time.Local)
+	dueID := domain.AppspaceID(7)
+	notDueID := domain.AppspaceID(11)
+	locationKey := "as7"
+
+	scheduleModel := testmocks.NewMockBackupScheduleModel(mockCtrl)
+	scheduleModel.EXPECT().GetAllEnabled().Return([]domain.BackupSchedule{{
+		AppspaceID: dueID,
+		Enabled:    true,
+		Schedule:   "daily",
+		KeepLast:   1,
+		LastRun:    nulltypes.NewTime(now.Add(-24*time.Hour), true),
+	}, {
+		AppspaceID: notDueID,
+		Enabled:    true,
+		Schedule:   "weekly",
+		Created:    now.Add(-time.Hour),
+	}}, nil)
+	scheduleModel.EXPECT().SetLastRun(dueID, now).Return(nil)
+
+	appspaceModel := testmocks.NewMockAppspaceModel(mockCtrl)
+	appspaceModel.EXPECT().GetFromID(dueID).Return(&domain.Appspace{AppspaceID: dueID, LocationKey: locationKey}, nil)
+
+	backupAppspace := testmocks.NewMockBackupAppspace(mockCtrl)
+	backupAppspace.EXPECT().CreateScheduledBackup(dueID).Return("2024-03-13_0300_auto.snapshot", nil)
+
+	filesModel := testmocks.NewMockAppspaceFilesModel(mockCtrl)
+	filesModel.EXPECT().GetBackups(locationKey).Return([]string{"2024-03-13_0300_auto.snapshot", "2024-03-12_0300_auto.snapshot", "2024-03-11_0300.snapshot"}, nil)
+	filesModel.EXPECT().DeleteBackup(locationKey, "2024-03-12_0300_auto.snapshot").Return(nil)
+
+	appspaceLogger := testmocks.NewMockAppspaceLogger(mockCtrl)
+	appspaceLogger.EXPECT().Log(dueID, "ds-host", gomock.Any())
+
+	s := &BackupScheduler{
+		BackupScheduleModel: scheduleModel,
+		AppspaceModel:       appspaceModel,
+		AppspaceFilesModel:  filesModel,
+		BackupAppspace:      backupAppspace,
+		AppspaceLogger:      appspaceLogger,
+		stop:                make(chan struct{}),
+	}
+
+	s.runDue(now)
+}
@@ -35,6 +35,9 @@ type CreateAppspace struct {
 	MigrationJobController interface {
 		WakeUp()
 	} `checkinject:"required"`
+	OutboundPolicy interface {
+		Approve(appspace domain.Appspace, version domain.Version, hosts []string) error
+	} `checkinject:"required"`
 }
 
 // Shouldn't we also have appspace status in here?
@@ -43,27 +46,19 @@ type CreateAppspace struct {
 // - domain not available
 
 // Create a new appspace
-func (c *CreateAppspace) Create(dropID domain.DropID, appVersion domain.AppVersion, baseDomain, subDomain string) (domain.AppspaceID, domain.JobID, error) {
-
-	// Possible race condition here. If you check domain is available then later actually register it.
-	// It would be nice if CheckAppspaceDomain also reserved that name temporarily
-	check, err := c.DomainController.CheckAppspaceDomain(dropID.UserID, baseDomain, subDomain)
+// outbound is the list of hosts the owner approves the appspace to connect to.
+func (c *CreateAppspace) Create(dropID domain.DropID, appVersion domain.AppVersion, baseDomain, subDomain string, outbound []string) (domain.AppspaceID, domain.JobID, error) {
+	appspace, err := c.create(dropID, appVersion.AppID, "", baseDomain, subDomain)
 	if err != nil {
 		return domain.AppspaceID(0), domain.JobID(0), err
 	}
-	if !check.Valid || !check.Available { // validitiy should be checked at route handler?
-		return domain.AppspaceID(0), domain.JobID(0), errors.New("domain invalid or unavailable") // this is unhelpful.
-	}
-
-	fullDomain := baseDomain
-	if subDomain != "" {
-		fullDomain = subDomain + "." + baseDomain
-	}
 
-	dropIDStr := validator.JoinDropID(dropID.Handle, dropID.Domain)
-
-	locationKey, err := c.AppspaceFilesModel.CreateLocation()
+	// Set the approved hosts before the migration job can start a sandbox,
+	// even if there are none, so that the appspace is restricted to them.
+	err = c.OutboundPolicy.Approve(*appspace, appVersion.Version, outbound)
 	if err != nil {
+		c.AppspaceFilesModel.DeleteLocation(appspace.LocationKey)
+		c.AppspaceModel.Delete(appspace.AppspaceID)
 		return domain.AppspaceID(0), domain.JobID(0), err
 	}
 
@@ -72,33 +67,11 @@ func (c *CreateAppspace) Create(dropID domain.DropID, appVersion domain.AppVersi
 		return domain.AppspaceID(0), domain.JobID(0), err
 	}
 
-	inAppspace := domain.Appspace{
-		OwnerID:     dropID.UserID,
-		AppID:       appVersion.AppID,
-		AppVersion:  "",
-		DomainName:  fullDomain,
-		DropID:      dropIDStr,
-		LocationKey: locationKey,
-	}
-
-	appspace, err := c.AppspaceModel.Create(inAppspace)
-	if err != nil {
-		c.AppspaceFilesModel.DeleteLocation(locationKey)
-		return domain.AppspaceID(0), domain.JobID(0), err
-	}
-
-	err = c.AppspaceMetaDB.Create(appspace.AppspaceID)
-	if err != nil {
-		c.AppspaceFilesModel.DeleteLocation(locationKey)
-		c.AppspaceModel.Delete(appspace.AppspaceID)
-		return domain.AppspaceID(0), domain.JobID(0), err
-	}
-
 	// Create owner user
 	auths := make([]domain.EditAppspaceUserAuth, 0)
 	auths = append(auths, domain.EditAppspaceUserAuth{ // TODO only do dropid if it exists
 		Type:       "dropid",
-		Identifier: dropIDStr,
+		Identifier: appspace.DropID,
 		Operation:  domain.EditOperationAdd})
 	if user.TSNetIdentifier != "" {
 		auths = append(auths, domain.EditAppspaceUserAuth{
@@ -122,10 +95,67 @@ func (c *CreateAppspace) Create(dropID domain.DropID, appVersion domain.AppVersi
 
 	c.MigrationJobController.WakeUp()
 
-	err = c.DomainController.StartManaging(fullDomain)
+	err = c.DomainController.StartManaging(appspace.DomainName)
 	if err != nil {
 		return domain.AppspaceID(0), domain.JobID(0), err
 	}
 
 	return appspace.AppspaceID, job.JobID, nil
 }
+
+// CreateImported creates an appspace for imported data
+// that is already at appVersion.
+// No owner user is created and no migration is run,
+// and the domain is not managed yet:
+// the caller is expected to replace the data then start managing the domain.
+func (c *CreateAppspace) CreateImported(dropID domain.DropID, appVersion domain.AppVersion, baseDomain, subDomain string) (*domain.Appspace, error) {
+	return c.create(dropID, appVersion.AppID, appVersion.Version, baseDomain, subDomain)
+}
+
+func (c *CreateAppspace) create(dropID domain.DropID, appID domain.AppID, version domain.Version, baseDomain, subDomain string) (*domain.Appspace, error) {
+	// Possible race condition here. If you check domain is available then later actually register it.
+	// It would be nice if CheckAppspaceDomain also reserved that name temporarily
+	check, err := c.DomainController.CheckAppspaceDomain(dropID.UserID, baseDomain, subDomain)
+	if err != nil {
+		return nil, err
+	}
+	if !check.Valid || !check.Available { // validitiy should be checked at route handler?
+		return nil, errors.New("domain invalid or unavailable") // this is unhelpful.
+	}
+
+	fullDomain := baseDomain
+	if subDomain != "" {
+		fullDomain = subDomain + "." + baseDomain
+	}
+
+	dropIDStr := validator.JoinDropID(dropID.Handle, dropID.Domain)
+
+	locationKey, err := c.AppspaceFilesModel.CreateLocation()
+	if err != nil {
+		return nil, err
+	}
+
+	inAppspace := domain.Appspace{
+		OwnerID:     dropID.UserID,
+		AppID:       appID,
+		AppVersion:  version,
+		DomainName:  fullDomain,
+		DropID:      dropIDStr,
+		LocationKey: locationKey,
+	}
+
+	appspace, err := c.AppspaceModel.Create(inAppspace)
+	if err != nil {
+		c.AppspaceFilesModel.DeleteLocation(locationKey)
+		return nil, err
+	}
+
+	err = c.AppspaceMetaDB.Create(appspace.AppspaceID)
+	if err != nil {
+		c.AppspaceFilesModel.DeleteLocation(locationKey)
+		c.AppspaceModel.Delete(appspace.AppspaceID)
+		return nil, err
+	}
+
+	return appspace, nil
+}
@@ -23,6 +23,24 @@ type DeleteAppspace struct {
 	AppspaceTSNet interface {
 		Delete(appspaceID domain.AppspaceID) error
 	} `checkinject:"required"`
+	BackupScheduleModel interface {
+		Delete(domain.AppspaceID) error
+	} `checkinject:"required"`
+	BackupKeyModel interface {
+		Delete(domain.AppspaceID) error
+	} `checkinject:"required"`
+	AppspaceQuotaModel interface {
+		Delete(domain.AppspaceID) error
+	} `checkinject:"required"`
+	AppspaceLogModel interface {
+		Delete(domain.AppspaceID) error
+	} `checkinject:"required"`
+	AccessLogModel interface {
+		Delete(domain.AppspaceID) error
+	} `checkinject:"required"`
+	AppspaceOutboundModel interface {
+		Delete(domain.AppspaceID) error
+	} `checkinject:"required"`
 	DomainController interface {
 		StopManaging(string)
 	} `checkinject:"required"`
@@ -35,6 +53,9 @@ type DeleteAppspace struct {
 	AppspaceLogger interface {
 		Forget(domain.AppspaceID)
 	} `checkinject:"required"`
+	Metrics interface {
+		ForgetAppspace(domain.AppspaceID)
+	} `checkinject:"optional"`
 }
 
 // Delete permanently deletes all data associated with an appspace
@@ -54,6 +75,18 @@ func (d *DeleteAppspace) Delete(appspace domain.Appspace) error {
 
 	d.AppspaceTSNet.Delete(appspace.AppspaceID)
 
+	d.BackupScheduleModel.Delete(appspace.AppspaceID)
+
+	d.BackupKeyModel.Delete(appspace.AppspaceID)
+
+	d.AppspaceQuotaModel.Delete(appspace.AppspaceID)
+
+	d.AppspaceLogModel.Delete(appspace.AppspaceID)
+
+	d.AccessLogModel.Delete(appspace.AppspaceID)
+
+	d.AppspaceOutboundModel.Delete(appspace.AppspaceID)
+
 	// Delete from cookies table?
 
 	err := d.MigrationJobModel.DeleteForAppspace(appspace.AppspaceID)
@@ -68,6 +101,10 @@ func (d *DeleteAppspace) Delete(appspace domain.Appspace) error {
 
 	d.AppspaceLogger.Forget(appspace.AppspaceID)
 
+	if d.Metrics != nil {
+		d.Metrics.ForgetAppspace(appspace.AppspaceID)
+	}
+
 	// then delete the files
 	err = d.AppspaceFilesModel.DeleteLocation(appspace.LocationKey)
 	if err != nil {
@@ -0,0 +1,221 @@
+package appspaceops
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+	"github.com/teleclimber/DropServer/internal/backupcrypt"
+)
+
+// An export bundle is a zip file that holds everything needed
+// to re-create an appspace on another host:
+// - bundle.json: domain.AppspaceBundleMeta
+// - app.tar.gz: the app package of the appspace's app version
+// - data.zip (or data.zip.enc if the appspace has a backup key): the appspace data
+
+const bundleVersion = 1
+
+const bundleMetaFile = "bundle.json"
+const bundlePackageFile = "app.tar.gz"
+const bundleDataFile = "data.zip"
+
+// ExportAppspace writes appspace export bundles
+type ExportAppspace struct {
+	AppspaceModel interface {
+		GetFromID(appspaceID domain.AppspaceID) (*domain.Appspace, error)
+	} `checkinject:"required"`
+	AppModel interface {
+		GetVersion(domain.AppID, domain.Version) (domain.AppVersion, error)
+		GetVersionForUI(appID domain.AppID, version domain.Version) (domain.AppVersionUI, error)
+	} `checkinject:"required"`
+	AppFilesModel interface {
+		OpenPackage(locationKey string) (*os.File, error)
+	} `checkinject:"required"`
+	AppspaceUserModel interface {
+		GetAll(appspaceID domain.AppspaceID) ([]domain.AppspaceUser, error)
+	} `checkinject:"required"`
+	AppspaceTSNetModel interface {
+		Get(domain.AppspaceID) (domain.AppspaceTSNet, error)
+	} `checkinject:"required"`
+	AppspaceOutboundModel interface {
+		Get(appspaceID domain.AppspaceID) ([]string, error)
+	} `checkinject:"required"`
+	BackupKeyModel interface {
+		GetRecipient(domain.AppspaceID) (backupcrypt.Recipient, bool, error)
+	} `checkinject:"required"`
+	AppspaceFilesModel interface {
+		WriteBackupZip(locationKey string, filename string, w io.Writer) error
+	} `checkinject:"required"`
+}
+
+// WriteBundle writes an export bundle of the appspace to w
+// using the data from the local backup file.
+// The data is encrypted if the appspace has a backup key.
+func (e *ExportAppspace) WriteBundle(appspaceID domain.AppspaceID, backupFile string, w io.Writer) error {
+	return e.writeBundle(appspaceID, backupFile, true, w)
+}
+
+// WriteTransferBundle writes a bundle like WriteBundle,
+// but the data is never encrypted. It is meant for direct transfer
+// to another host over an authenticated connection.
+func (e *ExportAppspace) WriteTransferBundle(appspaceID domain.AppspaceID, backupFile string, w io.Writer) error {
+	return e.writeBundle(appspaceID, backupFile, false, w)
+}
+
+func (e *ExportAppspace) writeBundle(appspaceID domain.AppspaceID, backupFile string, allowEncrypt bool, w io.Writer) error {
+	log := e.getLogger("writeBundle").AppspaceID(appspaceID).Clone
+
+	if strings.HasSuffix(backupFile, encZipExt) {
+		return errors.New("bad input: can not bundle an encrypted backup")
+	}
+
+	appspace, err := e.AppspaceModel.GetFromID(appspaceID)
+	if err != nil {
+		return err
+	}
+	appVersion, err := e.AppModel.GetVersion(appspace.AppID, appspace.AppVersion)
+	if err != nil {
+		log().AddNote("GetVersion").Error(err)
+		return err
+	}
+	versionUI, err := e.AppModel.GetVersionForUI(appspace.AppID, appspace.AppVersion)
+	if err != nil {
+		log().AddNote("GetVersionForUI").Error(err)
+		return err
+	}
+	users, err := e.AppspaceUserModel.GetAll(appspaceID)
+	if err != nil {
+		log().AddNote("GetAll users").Error(err)
+		return err
+	}
+	recipient, encrypt, err := e.BackupKeyModel.GetRecipient(appspaceID)
+	if err != nil {
+		return err
+	}
+	encrypt = encrypt && allowEncrypt
+
+	pkgHash, err := e.hashPackage(appVersion.LocationKey)
+	if err != nil {
+		return err
+	}
+
+	meta := domain.AppspaceBundleMeta{
+		BundleVersion: bundleVersion,
+		Created:       time.Now(),
+		DomainName:    appspace.DomainName,
+		DropID:        appspace.DropID,
+		AppName:       versionUI.Name,
+		AppVersion:    appVersion.Version,
+		Schema:        appVersion.Schema,
+		PackageSHA256: pkgHash,
+		Encrypted:     encrypt,
+		Users:         users,
+	}
+	tsnet, err := e.AppspaceTSNetModel.Get(appspaceID)
+	if err == nil {
+		meta.TSNet = &tsnet.TSNetCommon
+	} else if err != domain.ErrNoRowsInResultSet {
+		log().AddNote("AppspaceTSNetModel.Get").Error(err)
+		return err
+	}
+	meta.Outbound, err = e.AppspaceOutboundModel.Get(appspaceID)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+
+	metaBytes, err := json.MarshalIndent(meta, "", "\t")
+	if err != nil {
+		return err
+	}
+	fw, err := zw.Create(bundleMetaFile)
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(metaBytes)
+	if err != nil {
+		return err
+	}
+
+	// package and data are already compressed, so they are stored as-is.
+	fw, err = zw.CreateHeader(&zip.FileHeader{Name: bundlePackageFile, Method: zip.Store, Modified: meta.Created})
+	if err != nil {
+		return err
+	}
+	pkg, err := e.AppFilesModel.OpenPackage(appVersion.LocationKey)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, pkg)
+	pkg.Close()
+	if err != nil {
+		return err
+	}
+
+	dataFile := bundleDataFile
+	if encrypt {
+		dataFile += ".enc"
+	}
+	fw, err = zw.CreateHeader(&zip.FileHeader{Name: dataFile, Method: zip.Store, Modified: meta.Created})
+	if err != nil {
+		return err
+	}
+	var dw io.Writer = fw
+	var ew io.WriteCloser
+	if encrypt {
+		ew, err = backupcrypt.Encrypt(fw, recipient)
+		if err != nil {
+			return err
+		}
+		dw = ew
+	}
+	err = e.AppspaceFilesModel.WriteBackupZip(appspace.LocationKey, backupFile, dw)
+	if err != nil {
+		log().AddNote("WriteBackupZip").Error(err)
+		return err
+	}
+	if ew != nil {
+		err = ew.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func (e *ExportAppspace) hashPackage(locationKey string) (string, error) {
+	f, err := e.AppFilesModel.OpenPackage(locationKey)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return hashReader(f)
+}
+
+func hashReader(r io.Reader) (string, error) {
+	h := sha256.New()
+	_, err := io.Copy(h, r)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (e *ExportAppspace) getLogger(note string) *record.DsLogger {
+	r := record.NewDsLogger().AddNote("ExportAppspace")
+	if note != "" {
+		r.AddNote(note)
+	}
+	return r
+}
@@ -0,0 +1,635 @@
+package appspaceops
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+	"github.com/teleclimber/DropServer/internal/validator"
+)
+
+// maxBundleMetaSize limits the size of bundle.json we are willing to read
+const maxBundleMetaSize = 10 << 20
+
+// installTimeout is how long we wait for the app package to be processed
+var installTimeout = 5 * time.Minute
+
+type importTokenData struct {
+	userID      domain.UserID
+	dir         string
+	meta        domain.AppspaceBundleMeta
+	timer       *time.Timer
+	cancelTimer chan struct{}
+}
+
+// ImportAppspace creates an appspace from an export bundle
+// made by ExportAppspace, installing the app if necessary.
+type ImportAppspace struct {
+	AppModel interface {
+		GetForOwner(domain.UserID) ([]*domain.App, error)
+		GetVersion(domain.AppID, domain.Version) (domain.AppVersion, error)
+	} `checkinject:"required"`
+	AppFilesModel interface {
+		SavePackage(io.Reader) (string, error)
+		OpenPackage(locationKey string) (*os.File, error)
+	} `checkinject:"required"`
+	AppGetter interface {
+		InstallPackage(userID domain.UserID, locationKey string, appIDs ...domain.AppID) (domain.AppGetKey, error)
+		GetLastEvent(key domain.AppGetKey) (domain.AppGetEvent, bool)
+		GetResults(domain.AppGetKey) (domain.AppGetMeta, bool)
+		Commit(domain.AppGetKey) (domain.AppID, domain.Version, error)
+		Delete(domain.AppGetKey)
+		DeleteKeyData(domain.AppGetKey)
+	} `checkinject:"required"`
+	CreateAppspace interface {
+		CreateImported(dropID domain.DropID, appVersion domain.AppVersion, baseDomain, subDomain string) (*domain.Appspace, error)
+	} `checkinject:"required"`
+	DeleteAppspace interface {
+		Delete(domain.Appspace) error
+	} `checkinject:"required"`
+	RestoreAppspace interface {
+		Prepare(reader io.Reader) (string, error)
+		IsEncrypted(tok string) (bool, error)
+		Decrypt(tok string, passphrase string) error
+		CheckAppspaceDataValid(tok string) error
+		GetMetaInfo(tok string) (domain.AppspaceMetaInfo, error)
+		ReplaceData(tok string, appspaceID domain.AppspaceID) error
+		Delete(tok string)
+	} `checkinject:"required"`
+	UserModel interface {
+		GetFromID(domain.UserID) (domain.User, error)
+	} `checkinject:"required"`
+	AppspaceUserModel interface {
+		Create(appspaceID domain.AppspaceID, displayName string, avatar string, auths []domain.EditAppspaceUserAuth) (domain.ProxyID, error)
+		GetByAuth(appspaceID domain.AppspaceID, authType string, identifier string) (domain.AppspaceUser, error)
+		UpdateAuth(appspaceID domain.AppspaceID, proxyID domain.ProxyID, auth domain.EditAppspaceUserAuth) error
+	} `checkinject:"required"`
+	AppspaceTSNetModel interface {
+		CreateOrUpdate(appspaceID domain.AppspaceID, controlURL string, hostname string, connect bool) error
+	} `checkinject:"required"`
+	DomainController interface {
+		StartManaging(dom string) error
+	} `checkinject:"required"`
+	OutboundPolicy interface {
+		GetDeclared(appID domain.AppID, version domain.Version) ([]string, error)
+		Approve(appspace domain.Appspace, version domain.Version, hosts []string) error
+	} `checkinject:"required"`
+
+	tokensMux sync.Mutex
+	tokens    map[string]importTokenData
+}
+
+func (i *ImportAppspace) Init() {
+	i.tokens = make(map[string]importTokenData)
+}
+
+// Prepare saves the bundle and reads its metadata.
+// The returned token is used by the user to import the bundle.
+func (i *ImportAppspace) Prepare(userID domain.UserID, reader io.Reader) (string, domain.AppspaceBundleMeta, error) {
+	dir, err := os.MkdirTemp(os.TempDir(), "ds-temp-*")
+	if err != nil {
+		i.getLogger("Prepare, os.MkdirTemp()").Error(err)
+		return "", domain.AppspaceBundleMeta{}, err
+	}
+	bundleFile := filepath.Join(dir, "bundle.zip")
+	f, err := os.Create(bundleFile)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", domain.AppspaceBundleMeta{}, err
+	}
+	_, err = io.Copy(f, reader)
+	f.Close()
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", domain.AppspaceBundleMeta{}, err
+	}
+
+	meta, err := readBundleMeta(bundleFile)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", domain.AppspaceBundleMeta{}, err
+	}
+
+	tok := i.newToken(userID, dir, meta)
+
+	return tok, meta, nil
+}
+
+func readBundleMeta(bundleFile string) (domain.AppspaceBundleMeta, error) {
+	meta := domain.AppspaceBundleMeta{}
+	zr, err := zip.OpenReader(bundleFile)
+	if err != nil {
+		return meta, fmt.Errorf("bad input: bundle is not a zip file: %w", err)
+	}
+	defer zr.Close()
+
+	mf, err := openBundleFile(&zr.Reader, bundleMetaFile)
+	if err != nil {
+		return meta, err
+	}
+	defer mf.Close()
+	metaBytes, err := io.ReadAll(io.LimitReader(mf, maxBundleMetaSize))
+	if err != nil {
+		return meta, fmt.Errorf("bad input: unable to read bundle metadata: %w", err)
+	}
+	err = json.Unmarshal(metaBytes, &meta)
+	if err != nil {
+		return meta, fmt.Errorf("bad input: unable to read bundle metadata: %w", err)
+	}
+	if meta.BundleVersion != bundleVersion {
+		return meta, fmt.Errorf("bad input: unsupported bundle version %v", meta.BundleVersion)
+	}
+	if meta.AppVersion == "" || meta.PackageSHA256 == "" {
+		return meta, domain.ErrBadBundle
+	}
+
+	for _, name := range []string{bundlePackageFile, bundleDataName(meta)} {
+		_, err = zr.Open(name)
+		if err != nil {
+			return meta, domain.ErrBadBundle
+		}
+	}
+
+	return meta, nil
+}
+
+func bundleDataName(meta domain.AppspaceBundleMeta) string {
+	if meta.Encrypted {
+		return bundleDataFile + ".enc"
+	}
+	return bundleDataFile
+}
+
+func openBundleFile(zr *zip.Reader, name string) (io.ReadCloser, error) {
+	f, err := zr.Open(name)
+	if err != nil {
+		return nil, domain.ErrBadBundle
+	}
+	return f, nil
+}
+
+// GetMeta returns the metadata of a prepared bundle
+func (i *ImportAppspace) GetMeta(userID domain.UserID, tok string) (domain.AppspaceBundleMeta, error) {
+	tokData, ok := i.getToken(userID, tok)
+	if !ok {
+		return domain.AppspaceBundleMeta{}, domain.ErrTokenNotFound
+	}
+	return tokData.meta, nil
+}
+
+// Import creates a new appspace from the prepared bundle.
+// The app version is installed from the bundle if the user doesn't have it.
+// The passphrase is only needed if the bundle data is encrypted.
+func (i *ImportAppspace) Import(tok string, dropID domain.DropID, baseDomain, subDomain string, passphrase string) (domain.AppspaceID, error) {
+	log := i.getLogger("Import").UserID(dropID.UserID).Clone
+
+	tokData, ok := i.getToken(dropID.UserID, tok)
+	if !ok {
+		return domain.AppspaceID(0), domain.ErrTokenNotFound
+	}
+	meta := tokData.meta
+
+	zr, err := zip.OpenReader(filepath.Join(tokData.dir, "bundle.zip"))
+	if err != nil {
+		log().AddNote("zip.OpenReader").Error(err)
+		return domain.AppspaceID(0), err
+	}
+	defer zr.Close()
+
+	// Check the data first, so that a bad passphrase
+	// or bad data don't leave a needlessly installed app behind.
+	dataTok, err := i.prepareData(&zr.Reader, meta, passphrase)
+	if err != nil {
+		return domain.AppspaceID(0), err
+	}
+	// ReplaceData consumes the data token, so this is a no-op on success.
+	defer i.RestoreAppspace.Delete(dataTok)
+
+	appVersion, found, err := i.findAppVersion(dropID.UserID, meta)
+	if err != nil {
+		return domain.AppspaceID(0), err
+	}
+	if !found {
+		appVersion, err = i.installApp(dropID.UserID, &zr.Reader, meta)
+		if err != nil {
+			return domain.AppspaceID(0), err
+		}
+	}
+	if appVersion.Schema != meta.Schema {
+		return domain.AppspaceID(0), domain.ErrBadBundle
+	}
+
+	appspace, err := i.CreateAppspace.CreateImported(dropID, appVersion, baseDomain, subDomain)
+	if err != nil {
+		return domain.AppspaceID(0), err
+	}
+
+	err = i.finishImport(*appspace, dataTok, dropID, meta)
+	if err != nil {
+		// Don't leave a half-imported appspace behind.
+		delErr := i.DeleteAppspace.Delete(*appspace)
+		if delErr != nil {
+			log().AddNote("DeleteAppspace.Delete").Error(delErr)
+		}
+		return domain.AppspaceID(0), err
+	}
+
+	i.delete(tok)
+
+	return appspace.AppspaceID, nil
+}
+
+// finishImport restores the data and sets up the newly created appspace.
+func (i *ImportAppspace) finishImport(appspace domain.Appspace, dataTok string, dropID domain.DropID, meta domain.AppspaceBundleMeta) error {
+	log := i.getLogger("finishImport").AppspaceID(appspace.AppspaceID).Clone
+
+	err := i.RestoreAppspace.ReplaceData(dataTok, appspace.AppspaceID)
+	if err != nil {
+		log().AddNote("ReplaceData").Error(err)
+		return err
+	}
+
+	err = i.approveOutbound(appspace, meta)
+	if err != nil {
+		log().AddNote("approveOutbound").Error(err)
+		return err
+	}
+
+	err = i.ensureOwner(appspace, dropID, meta)
+	if err != nil {
+		log().AddNote("ensureOwner").Error(err)
+		return err
+	}
+
+	// The tailnet node identity is not part of the bundle,
+	// and the appspace is likely still connected on the source host,
+	// so the config is restored but left disconnected.
+	if meta.TSNet != nil {
+		err = i.AppspaceTSNetModel.CreateOrUpdate(appspace.AppspaceID, meta.TSNet.ControlURL, meta.TSNet.Hostname, false)
+		if err != nil {
+			log().AddNote("AppspaceTSNetModel.CreateOrUpdate").Error(err)
+			return err
+		}
+	}
+
+	err = i.DomainController.StartManaging(appspace.DomainName)
+	if err != nil {
+		log().AddNote("StartManaging").Error(err)
+		return err
+	}
+	return nil
+}
+
+// approveOutbound carries over the hosts approved on the source host.
+// If they were never approved there, all declared hosts are approved,
+// as that is what the appspace could reach on the source host.
+func (i *ImportAppspace) approveOutbound(appspace domain.Appspace, meta domain.AppspaceBundleMeta) error {
+	declared, err := i.OutboundPolicy.GetDeclared(appspace.AppID, appspace.AppVersion)
+	if err != nil {
+		return err
+	}
+	hosts := declared
+	if meta.Outbound != nil {
+		hosts = make([]string, 0, len(meta.Outbound))
+		for _, h := range meta.Outbound {
+			if slices.Contains(declared, h) {
+				hosts = append(hosts, h)
+			}
+		}
+	}
+	return i.OutboundPolicy.Approve(appspace, appspace.AppVersion, hosts)
+}
+
+// prepareData hands the bundle's data to RestoreAppspace and checks it.
+func (i *ImportAppspace) prepareData(zr *zip.Reader, meta domain.AppspaceBundleMeta, passphrase string) (string, error) {
+	df, err := openBundleFile(zr, bundleDataName(meta))
+	if err != nil {
+		return "", err
+	}
+	dataTok, err := i.RestoreAppspace.Prepare(df)
+	df.Close()
+	if err != nil {
+		return "", err
+	}
+	err = i.checkData(dataTok, meta, passphrase)
+	if err != nil {
+		i.RestoreAppspace.Delete(dataTok)
+		return "", err
+	}
+	return dataTok, nil
+}
+
+// checkData decrypts the prepared data if needed and checks it matches the bundle.
+func (i *ImportAppspace) checkData(dataTok string, meta domain.AppspaceBundleMeta, passphrase string) error {
+	encrypted, err := i.RestoreAppspace.IsEncrypted(dataTok)
+	if err != nil {
+		return err
+	}
+	if encrypted {
+		if passphrase == "" {
+			return domain.ErrBackupEncrypted
+		}
+		err = i.RestoreAppspace.Decrypt(dataTok, passphrase)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = i.RestoreAppspace.CheckAppspaceDataValid(dataTok)
+	if err != nil {
+		return err
+	}
+	info, err := i.RestoreAppspace.GetMetaInfo(dataTok)
+	if err != nil {
+		return err
+	}
+	if info.Schema != meta.Schema {
+		return fmt.Errorf("bad input: appspace data schema %v does not match app schema %v", info.Schema, meta.Schema)
+	}
+	return nil
+}
+
+// findAppVersion looks for an app version of the user's
+// that was installed from the same package as the bundle's.
+func (i *ImportAppspace) findAppVersion(userID domain.UserID, meta domain.AppspaceBundleMeta) (domain.AppVersion, bool, error) {
+	apps, err := i.AppModel.GetForOwner(userID)
+	if err != nil {
+		return domain.AppVersion{}, false, err
+	}
+	for _, app := range apps {
+		appVersion, err := i.AppModel.GetVersion(app.AppID, meta.AppVersion)
+		if err == domain.ErrNoRowsInResultSet {
+			continue
+		}
+		if err != nil {
+			return domain.AppVersion{}, false, err
+		}
+		f, err := i.AppFilesModel.OpenPackage(appVersion.LocationKey)
+		if err != nil {
+			continue // missing package, can't compare
+		}
+		hash, err := hashReader(f)
+		f.Close()
+		if err != nil {
+			return domain.AppVersion{}, false, err
+		}
+		if hash == meta.PackageSHA256 {
+			return appVersion, true, nil
+		}
+	}
+	return domain.AppVersion{}, false, nil
+}
+
+// installApp installs the bundle's app package as a new app.
+// Processing warnings do not require confirmation:
+// the app was already in use on the source host.
+func (i *ImportAppspace) installApp(userID domain.UserID, zr *zip.Reader, meta domain.AppspaceBundleMeta) (domain.AppVersion, error) {
+	log := i.getLogger("installApp").UserID(userID).Clone
+
+	pf, err := openBundleFile(zr, bundlePackageFile)
+	if err != nil {
+		return domain.AppVersion{}, err
+	}
+	hash, err := hashReader(pf)
+	pf.Close()
+	if err != nil {
+		return domain.AppVersion{}, err
+	}
+	if hash != meta.PackageSHA256 {
+		return domain.AppVersion{}, domain.ErrBadBundle
+	}
+
+	pf, err = openBundleFile(zr, bundlePackageFile)
+	if err != nil {
+		return domain.AppVersion{}, err
+	}
+	locationKey, err := i.AppFilesModel.SavePackage(pf)
+	pf.Close()
+	if err != nil {
+		return domain.AppVersion{}, err
+	}
+
+	key, err := i.AppGetter.InstallPackage(userID, locationKey)
+	if err != nil {
+		log().AddNote("InstallPackage").Error(err)
+		return domain.AppVersion{}, err
+	}
+
+	err = i.waitAppGetter(key)
+	if err != nil {
+		i.AppGetter.Delete(key)
+		return domain.AppVersion{}, err
+	}
+
+	results, ok := i.AppGetter.GetResults(key)
+	if !ok {
+		return domain.AppVersion{}, errors.New("app getter results not found")
+	}
+	if len(results.Errors) != 0 {
+		i.AppGetter.Delete(key)
+		return domain.AppVersion{}, fmt.Errorf("bad input: error processing app: %v", strings.Join(results.Errors, ", "))
+	}
+	if results.VersionManifest.Version != meta.AppVersion {
+		i.AppGetter.Delete(key)
+		return domain.AppVersion{}, domain.ErrBadBundle
+	}
+
+	appID, version, err := i.AppGetter.Commit(key)
+	i.AppGetter.DeleteKeyData(key)
+	if err != nil {
+		return domain.AppVersion{}, err
+	}
+
+	return i.AppModel.GetVersion(appID, version)
+}
+
+// waitAppGetter polls the app getter until processing is
+// done or it awaits the commit.
+func (i *ImportAppspace) waitAppGetter(key domain.AppGetKey) error {
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(installTimeout)
+	for {
+		ev, ok := i.AppGetter.GetLastEvent(key)
+		if !ok {
+			return errors.New("app getter key not found")
+		}
+		if ev.Done || ev.Input == "commit" {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-timeout:
+			return errors.New("timed out processing app package")
+		}
+	}
+}
+
+// ensureOwner makes sure the importing user can get into the appspace.
+// The imported data holds the users of the source host,
+// so the owner's dropid and tsnet identity are added
+// to the source owner's user, or to a new user if it's gone.
+func (i *ImportAppspace) ensureOwner(appspace domain.Appspace, dropID domain.DropID, meta domain.AppspaceBundleMeta) error {
+	user, err := i.UserModel.GetFromID(dropID.UserID)
+	if err != nil {
+		return err
+	}
+
+	auths := make([]domain.EditAppspaceUserAuth, 0)
+	used, err := i.authUsed(appspace.AppspaceID, "dropid", appspace.DropID)
+	if err != nil {
+		return err
+	}
+	if !used {
+		auths = append(auths, domain.EditAppspaceUserAuth{
+			Type:       "dropid",
+			Identifier: appspace.DropID,
+			Operation:  domain.EditOperationAdd})
+	}
+	if user.TSNetIdentifier != "" {
+		used, err = i.authUsed(appspace.AppspaceID, "tsnetid", user.TSNetIdentifier)
+		if err != nil {
+			return err
+		}
+		if !used {
+			auths = append(auths, domain.EditAppspaceUserAuth{
+				Type:       "tsnetid",
+				Identifier: user.TSNetIdentifier,
+				ExtraName:  user.TSNetExtraName,
+				Operation:  domain.EditOperationAdd})
+		}
+	}
+	if len(auths) == 0 {
+		return nil
+	}
+
+	owner, ok := bundleOwner(meta)
+	if !ok {
+		_, err = i.AppspaceUserModel.Create(appspace.AppspaceID, dropID.DisplayName, "", auths)
+		return err
+	}
+	for _, auth := range auths {
+		err = i.AppspaceUserModel.UpdateAuth(appspace.AppspaceID, owner.ProxyID, auth)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *ImportAppspace) authUsed(appspaceID domain.AppspaceID, authType string, identifier string) (bool, error) {
+	_, err := i.AppspaceUserModel.GetByAuth(appspaceID, authType, identifier)
+	if err == domain.ErrNoRowsInResultSet {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// bundleOwner returns the user that the owner of the appspace
+// logged in as on the source host
+func bundleOwner(meta domain.AppspaceBundleMeta) (domain.AppspaceUser, bool) {
+	if meta.DropID == "" {
+		return domain.AppspaceUser{}, false
+	}
+	for _, u := range meta.Users {
+		for _, a := range u.Auths {
+			if a.Type == "dropid" && validator.NormalizeDropIDFull(a.Identifier) == validator.NormalizeDropIDFull(meta.DropID) {
+				return u, true
+			}
+		}
+	}
+	return domain.AppspaceUser{}, false
+}
+
+func (i *ImportAppspace) newToken(userID domain.UserID, dir string, meta domain.AppspaceBundleMeta) string {
+	i.tokensMux.Lock()
+	defer i.tokensMux.Unlock()
+	var tok string
+	for {
+		tok = randomToken()
+		_, found := i.tokens[tok]
+		if !found {
+			break
+		}
+	}
+
+	t := time.NewTimer(15 * time.Minute)
+	ct := make(chan struct{})
+	i.tokens[tok] = importTokenData{
+		userID:      userID,
+		dir:         dir,
+		meta:        meta,
+		timer:       t,
+		cancelTimer: ct,
+	}
+
+	go func() {
+		select {
+		case <-t.C:
+			i.delete(tok)
+		case <-ct:
+		}
+	}()
+
+	return tok
+}
+
+func (i *ImportAppspace) getToken(userID domain.UserID, tok string) (importTokenData, bool) {
+	i.tokensMux.Lock()
+	defer i.tokensMux.Unlock()
+	tokData, ok := i.tokens[tok]
+	if !ok || tokData.userID != userID {
+		return importTokenData{}, false
+	}
+	return tokData, true
+}
+
+func (i *ImportAppspace) delete(tok string) {
+	i.tokensMux.Lock()
+	defer i.tokensMux.Unlock()
+	tokData, ok := i.tokens[tok]
+	if !ok {
+		return
+	}
+
+	delete(i.tokens, tok)
+	if tokData.timer.Stop() {
+		close(tokData.cancelTimer)
+	}
+
+	err := os.RemoveAll(tokData.dir)
+	if err != nil {
+		i.getLogger("delete token, os.RemoveAll()").Error(err)
+	}
+}
+
+// DeleteAll removes all prepared bundles
+func (i *ImportAppspace) DeleteAll() {
+	i.tokensMux.Lock()
+	toks := make([]string, 0, len(i.tokens))
+	for tok := range i.tokens {
+		toks = append(toks, tok)
+	}
+	i.tokensMux.Unlock()
+	for _, tok := range toks {
+		i.delete(tok)
+	}
+}
+
+func (i *ImportAppspace) getLogger(note string) *record.DsLogger {
+	l := record.NewDsLogger().AddNote("ImportAppspace")
+	if note != "" {
+		l.AddNote(note)
+	}
+	return l
+}
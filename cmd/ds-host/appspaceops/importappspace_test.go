@@ -0,0 +1,341 @@
+package appspaceops
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/models/appspacefilesmodel"
+	"github.com/teleclimber/DropServer/cmd/ds-host/runtimeconfig"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+	"github.com/teleclimber/DropServer/internal/backupcrypt"
+	"github.com/teleclimber/DropServer/internal/snapshot"
+)
+
+func TestExportImportBundle(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	dir := t.TempDir()
+	cfg := &domain.RuntimeConfig{}
+	cfg.Exec.AppspacesPath = filepath.Join(dir, "appspaces")
+	l2p := &runtimeconfig.AppspaceLocation2Path{Config: cfg}
+
+	userID := domain.UserID(11)
+	asID := domain.AppspaceID(7)
+	loc := "as7"
+	appspace := &domain.Appspace{
+		AppspaceID:  asID,
+		AppID:       domain.AppID(3),
+		AppVersion:  domain.Version("0.1.0"),
+		LocationKey: loc,
+		DomainName:  "as.example.com",
+		DropID:      "alice@old.example.com"}
+	appVersion := domain.AppVersion{AppID: domain.AppID(3), Version: domain.Version("0.1.0"), Schema: 2, LocationKey: "app3"}
+
+	// make a snapshot backup
+	srcDir := filepath.Join(dir, "src")
+	err := os.MkdirAll(filepath.Join(srcDir, "files"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(srcDir, "files", "hello.txt"), []byte("hello"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := snapshot.Store{Dir: l2p.BackupChunks(loc)}
+	m, err := store.Create(srcDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.MkdirAll(l2p.Backups(loc), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backupFile := "2024-03-13_0300.snapshot"
+	err = snapshot.WriteManifest(m, l2p.Backup(loc, backupFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgPath := filepath.Join(dir, "package.tar.gz")
+	err = os.WriteFile(pkgPath, []byte("not really a package"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owner := domain.AppspaceUser{
+		AppspaceID:  asID,
+		ProxyID:     domain.ProxyID("abc"),
+		DisplayName: "Alice",
+		Auths:       []domain.AppspaceUserAuth{{Type: "dropid", Identifier: "alice@old.example.com"}}}
+
+	appspaceModel := testmocks.NewMockAppspaceModel(mockCtrl)
+	appspaceModel.EXPECT().GetFromID(asID).Return(appspace, nil)
+	appModel := testmocks.NewMockAppModel(mockCtrl)
+	appModel.EXPECT().GetVersion(appVersion.AppID, appVersion.Version).Return(appVersion, nil).AnyTimes()
+	appModel.EXPECT().GetVersionForUI(appVersion.AppID, appVersion.Version).Return(domain.AppVersionUI{Name: "Test App"}, nil)
+	appModel.EXPECT().GetForOwner(userID).Return([]*domain.App{{AppID: appVersion.AppID}}, nil).AnyTimes()
+	appFilesModel := testmocks.NewMockAppFilesModel(mockCtrl)
+	appFilesModel.EXPECT().OpenPackage("app3").DoAndReturn(func(string) (*os.File, error) {
+		return os.Open(pkgPath)
+	}).AnyTimes()
+	appspaceUserModel := testmocks.NewMockAppspaceUserModel(mockCtrl)
+	appspaceUserModel.EXPECT().GetAll(asID).Return([]domain.AppspaceUser{owner}, nil)
+	appspaceTSNetModel := testmocks.NewMockAppspaceTSNetModel(mockCtrl)
+	appspaceTSNetModel.EXPECT().Get(asID).Return(domain.AppspaceTSNet{
+		AppspaceID:  asID,
+		TSNetCommon: domain.TSNetCommon{Hostname: "alice-as", Connect: true}}, nil)
+	appspaceOutboundModel := testmocks.NewMockAppspaceOutboundModel(mockCtrl)
+	appspaceOutboundModel.EXPECT().Get(asID).Return([]string{"api.example.com"}, nil)
+	backupKeyModel := testmocks.NewMockBackupKeyModel(mockCtrl)
+	backupKeyModel.EXPECT().GetRecipient(asID).Return(backupcrypt.Recipient{}, false, nil)
+
+	e := &ExportAppspace{
+		AppspaceModel:         appspaceModel,
+		AppModel:              appModel,
+		AppFilesModel:         appFilesModel,
+		AppspaceUserModel:     appspaceUserModel,
+		AppspaceTSNetModel:    appspaceTSNetModel,
+		AppspaceOutboundModel: appspaceOutboundModel,
+		BackupKeyModel:        backupKeyModel,
+		AppspaceFilesModel:    &appspacefilesmodel.AppspaceFilesModel{Config: cfg},
+	}
+	var buf bytes.Buffer
+	err = e.WriteBundle(asID, backupFile, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the bundled data is a regular backup zip
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	df, err := zr.Open(bundleDataFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataZip, _ := io.ReadAll(df)
+	dzr, err := zip.NewReader(bytes.NewReader(dataZip), int64(len(dataZip)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hf, err := dzr.Open("files/hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hello, _ := io.ReadAll(hf)
+	if string(hello) != "hello" {
+		t.Errorf("unexpected data: %s", hello)
+	}
+
+	i := &ImportAppspace{
+		AppModel:      appModel,
+		AppFilesModel: appFilesModel,
+	}
+	i.Init()
+	defer i.DeleteAll()
+
+	tok, meta, err := i.Prepare(userID, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.AppName != "Test App" || meta.AppVersion != appVersion.Version || meta.Schema != 2 || meta.Encrypted {
+		t.Errorf("unexpected meta: %v", meta)
+	}
+	if meta.TSNet == nil || meta.TSNet.Hostname != "alice-as" {
+		t.Errorf("expected tsnet config: %v", meta.TSNet)
+	}
+	if len(meta.Outbound) != 1 || meta.Outbound[0] != "api.example.com" {
+		t.Errorf("expected approved outbound hosts: %v", meta.Outbound)
+	}
+	bOwner, ok := bundleOwner(meta)
+	if !ok || bOwner.ProxyID != owner.ProxyID {
+		t.Errorf("expected to find owner: %v", bOwner)
+	}
+
+	_, err = i.GetMeta(domain.UserID(12), tok)
+	if err != domain.ErrTokenNotFound {
+		t.Errorf("expected other user to not find token, got %v", err)
+	}
+
+	found, ok, err := i.findAppVersion(userID, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || found != appVersion {
+		t.Errorf("expected to find app version: %v", found)
+	}
+
+	meta.PackageSHA256 = "abc"
+	_, ok, err = i.findAppVersion(userID, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("expected app version with a different package to not be found")
+	}
+}
+
+func TestPrepareBadBundle(t *testing.T) {
+	i := &ImportAppspace{}
+	i.Init()
+
+	_, _, err := i.Prepare(domain.UserID(11), strings.NewReader("not a zip"))
+	if err == nil || !strings.HasPrefix(err.Error(), "bad input:") {
+		t.Errorf("expected bad input error, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	fw, _ := zw.Create(bundleMetaFile)
+	fw.Write([]byte(`{"bundle_version":1,"app_version":"0.1.0","package_sha256":"abc"}`))
+	zw.Close()
+	_, _, err = i.Prepare(domain.UserID(11), &buf)
+	if err != domain.ErrBadBundle {
+		t.Errorf("expected ErrBadBundle for missing parts, got %v", err)
+	}
+	if len(i.tokens) != 0 {
+		t.Error("expected no tokens")
+	}
+}
+
+func TestImportApproveOutbound(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	appspace := domain.Appspace{AppspaceID: domain.AppspaceID(7), AppID: domain.AppID(3), AppVersion: domain.Version("0.1.0")}
+	outboundPolicy := testmocks.NewMockOutboundPolicy(mockCtrl)
+	outboundPolicy.EXPECT().GetDeclared(appspace.AppID, appspace.AppVersion).Return([]string{"api.example.com", "*.example.org"}, nil).Times(3)
+
+	i := &ImportAppspace{OutboundPolicy: outboundPolicy}
+
+	// never approved on the source host: all declared hosts
+	outboundPolicy.EXPECT().Approve(appspace, appspace.AppVersion, []string{"api.example.com", "*.example.org"}).Return(nil)
+	err := i.approveOutbound(appspace, domain.AppspaceBundleMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// approved hosts that are declared are carried over
+	outboundPolicy.EXPECT().Approve(appspace, appspace.AppVersion, []string{"api.example.com"}).Return(nil)
+	err = i.approveOutbound(appspace, domain.AppspaceBundleMeta{Outbound: []string{"api.example.com", "evil.example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// none approved stays none
+	outboundPolicy.EXPECT().Approve(appspace, appspace.AppVersion, []string{}).Return(nil)
+	err = i.approveOutbound(appspace, domain.AppspaceBundleMeta{Outbound: []string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+type testCreateAppspace struct {
+	appspace domain.Appspace
+}
+
+func (c *testCreateAppspace) CreateImported(dropID domain.DropID, appVersion domain.AppVersion, baseDomain, subDomain string) (*domain.Appspace, error) {
+	a := c.appspace
+	return &a, nil
+}
+
+type testDeleteAppspace struct {
+	deleted []domain.Appspace
+}
+
+func (d *testDeleteAppspace) Delete(appspace domain.Appspace) error {
+	d.deleted = append(d.deleted, appspace)
+	return nil
+}
+
+func TestImportCleanup(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	dir := t.TempDir()
+	pkg := []byte("not really a package")
+	pkgPath := filepath.Join(dir, "package.tar.gz")
+	err := os.WriteFile(pkgPath, pkg, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgHash, _ := hashReader(bytes.NewReader(pkg))
+
+	userID := domain.UserID(11)
+	appVersion := domain.AppVersion{AppID: domain.AppID(3), Version: domain.Version("0.1.0"), Schema: 2, LocationKey: "app3"}
+	appspace := domain.Appspace{AppspaceID: domain.AppspaceID(7), DomainName: "as.example.com"}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	fw, _ := zw.Create(bundleMetaFile)
+	fw.Write([]byte(`{"bundle_version":1,"app_version":"0.1.0","schema":2,"package_sha256":"` + pkgHash + `"}`))
+	fw, _ = zw.Create(bundlePackageFile)
+	fw.Write(pkg)
+	fw, _ = zw.Create(bundleDataFile)
+	fw.Write([]byte("data"))
+	zw.Close()
+
+	appModel := testmocks.NewMockAppModel(mockCtrl)
+	appModel.EXPECT().GetForOwner(userID).Return([]*domain.App{{AppID: appVersion.AppID}}, nil).AnyTimes()
+	appModel.EXPECT().GetVersion(appVersion.AppID, appVersion.Version).Return(appVersion, nil).AnyTimes()
+	appFilesModel := testmocks.NewMockAppFilesModel(mockCtrl)
+	appFilesModel.EXPECT().OpenPackage("app3").DoAndReturn(func(string) (*os.File, error) {
+		return os.Open(pkgPath)
+	}).AnyTimes()
+	restoreAppspace := testmocks.NewMockRestoreAppspace(mockCtrl)
+	deleteAppspace := &testDeleteAppspace{}
+
+	i := &ImportAppspace{
+		AppModel:        appModel,
+		AppFilesModel:   appFilesModel,
+		CreateAppspace:  &testCreateAppspace{appspace: appspace},
+		DeleteAppspace:  deleteAppspace,
+		RestoreAppspace: restoreAppspace,
+	}
+	i.Init()
+	defer i.DeleteAll()
+
+	tok, _, err := i.Prepare(userID, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropID := domain.DropID{UserID: userID}
+
+	// bad data: the data token is deleted, no appspace is created
+	restoreAppspace.EXPECT().Prepare(gomock.Any()).Return("dtok", nil)
+	restoreAppspace.EXPECT().IsEncrypted("dtok").Return(false, nil)
+	restoreAppspace.EXPECT().CheckAppspaceDataValid("dtok").Return(domain.ErrBadBundle)
+	restoreAppspace.EXPECT().Delete("dtok")
+	_, err = i.Import(tok, dropID, "example.com", "as", "")
+	if err != domain.ErrBadBundle {
+		t.Errorf("expected ErrBadBundle, got %v", err)
+	}
+
+	// failure after the appspace is created: the appspace is deleted
+	restoreAppspace.EXPECT().Prepare(gomock.Any()).Return("dtok", nil)
+	restoreAppspace.EXPECT().IsEncrypted("dtok").Return(false, nil)
+	restoreAppspace.EXPECT().CheckAppspaceDataValid("dtok").Return(nil)
+	restoreAppspace.EXPECT().GetMetaInfo("dtok").Return(domain.AppspaceMetaInfo{Schema: 2}, nil)
+	restoreAppspace.EXPECT().ReplaceData("dtok", appspace.AppspaceID).Return(domain.ErrStorageExceeded)
+	restoreAppspace.EXPECT().Delete("dtok")
+	_, err = i.Import(tok, dropID, "example.com", "as", "")
+	if err != domain.ErrStorageExceeded {
+		t.Errorf("expected ErrStorageExceeded, got %v", err)
+	}
+	if len(deleteAppspace.deleted) != 1 || deleteAppspace.deleted[0].AppspaceID != appspace.AppspaceID {
+		t.Errorf("expected the appspace to be deleted: %v", deleteAppspace.deleted)
+	}
+	_, err = i.GetMeta(userID, tok)
+	if err != nil {
+		t.Errorf("expected the bundle token to remain after a failed import: %v", err)
+	}
+}
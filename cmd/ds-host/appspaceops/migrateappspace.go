@@ -50,6 +50,12 @@ type MigrationJobController struct {
 		StopAppspace(domain.AppspaceID)
 		ForMigration(appVersion domain.AppVersion, appspace *domain.Appspace) (domain.SandboxI, error)
 	} `checkinject:"required"`
+	Metrics interface {
+		MigrationJob(ok bool, dur time.Duration)
+	} `checkinject:"optional"`
+	Mailer interface {
+		SendToUser(domain.UserID, string, any) error
+	} `checkinject:"optional"`
 
 	runningJobs map[domain.JobID]*runningJob
 	runningMux  sync.Mutex
@@ -122,6 +128,9 @@ func (c *MigrationJobController) eventManifold() { // eventBus?
 
 		// Clean up:
 		if d.status == domain.MigrationFinished {
+			if d.errString.Valid && c.Mailer != nil {
+				go c.notifyFailed(*d.origJob, d.errString.String)
+			}
 			err := c.MigrationJobModel.SetFinished(d.origJob.JobID, d.errString)
 			if err != nil {
 				c.getLogger("eventManifold").AddNote("MigrationJobModel.SetFinished error: ").Debug(err.Error())
@@ -141,6 +150,24 @@ func (c *MigrationJobController) eventManifold() { // eventBus?
 	}
 }
 
+// notifyFailed emails the owner of the appspace that the migration failed
+func (c *MigrationJobController) notifyFailed(job domain.MigrationJob, errStr string) {
+	data := struct {
+		AppspaceID domain.AppspaceID
+		Appspace   string
+		ToVersion  domain.Version
+		Error      string
+	}{job.AppspaceID, fmt.Sprintf("appspace %d", job.AppspaceID), job.ToVersion, errStr}
+	appspace, err := c.AppspaceModel.GetFromID(job.AppspaceID)
+	if err == nil {
+		data.Appspace = appspace.DomainName
+	}
+	err = c.Mailer.SendToUser(job.OwnerID, "migration-failed", data)
+	if err != nil {
+		c.getLogger("notifyFailed()").AppspaceID(job.AppspaceID).Error(err)
+	}
+}
+
 // WakeUp tells the job controller to immediately look for a job to process
 // Call this after inserting a new job with high priority to start that job right away
 // (if possible, depending on load and other jobs in the queue)
@@ -210,6 +237,13 @@ func (c *MigrationJobController) createRunningJob(job *domain.MigrationJob) *run
 func (c *MigrationJobController) runJob(job *runningJob) {
 	defer job.setStatus(domain.MigrationFinished)
 
+	if c.Metrics != nil {
+		start := time.Now()
+		defer func() {
+			c.Metrics.MigrationJob(!job.errStr.Valid, time.Since(start))
+		}()
+	}
+
 	appspaceID := job.migrationJob.AppspaceID
 
 	tempPausedCh := c.AppspaceStatus.WaitTempPaused(appspaceID, "migrating")
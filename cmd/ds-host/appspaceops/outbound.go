@@ -0,0 +1,106 @@
+package appspaceops
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/internal/validator"
+)
+
+// OutboundPolicy determines which hosts a sandbox can connect to.
+// App code can only reach hosts declared in its manifest,
+// and appspace code only those the owner also approved.
+// Appspaces whose owner never approved hosts, like those created
+// before outbound hosts were declared, can reach all declared hosts.
+type OutboundPolicy struct {
+	AppModel interface {
+		GetVersionManifest(appID domain.AppID, version domain.Version) (domain.AppVersionManifest, error)
+	} `checkinject:"required"`
+	AppspaceOutboundModel interface {
+		Get(appspaceID domain.AppspaceID) ([]string, error)
+		Set(appspaceID domain.AppspaceID, hosts []string) error
+	} `checkinject:"required"`
+	SandboxManager interface {
+		StopAppspace(domain.AppspaceID)
+	} `checkinject:"required"`
+	AppspaceLogger interface {
+		Log(appspaceID domain.AppspaceID, source string, message string)
+	} `checkinject:"required"`
+}
+
+// GetAllowed returns the host patterns a sandbox for the app version
+// and appspace can connect to. appspace is nil for app-only sandboxes.
+func (p *OutboundPolicy) GetAllowed(appVersion domain.AppVersion, appspace *domain.Appspace) ([]string, error) {
+	declared, err := p.GetDeclared(appVersion.AppID, appVersion.Version)
+	if err != nil {
+		return nil, err
+	}
+	if appspace == nil {
+		return declared, nil
+	}
+	approved, err := p.AppspaceOutboundModel.Get(appspace.AppspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if approved == nil {
+		return declared, nil
+	}
+	ret := make([]string, 0, len(approved))
+	for _, h := range approved {
+		if slices.Contains(declared, h) {
+			ret = append(ret, h)
+		}
+	}
+	return ret, nil
+}
+
+// GetDeclared returns the outbound hosts declared by the app version
+func (p *OutboundPolicy) GetDeclared(appID domain.AppID, version domain.Version) ([]string, error) {
+	manifest, err := p.AppModel.GetVersionManifest(appID, version)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Outbound == nil {
+		return []string{}, nil
+	}
+	return manifest.Outbound, nil
+}
+
+// Validate checks that hosts are all declared by the app version
+// and returns them normalized.
+func (p *OutboundPolicy) Validate(appID domain.AppID, version domain.Version, hosts []string) ([]string, error) {
+	declared, err := p.GetDeclared(appID, version)
+	if err != nil {
+		return nil, err
+	}
+	clean := make([]string, len(hosts))
+	for i, h := range hosts {
+		clean[i] = validator.NormalizeOutboundHost(h)
+		if !slices.Contains(declared, clean[i]) {
+			return nil, domain.ErrOutboundNotDeclared
+		}
+	}
+	return clean, nil
+}
+
+// Approve sets the hosts the owner approves for the appspace
+// The hosts must be declared by the app version passed.
+// Running sandboxes are stopped so the new policy takes effect.
+func (p *OutboundPolicy) Approve(appspace domain.Appspace, version domain.Version, hosts []string) error {
+	clean, err := p.Validate(appspace.AppID, version, hosts)
+	if err != nil {
+		return err
+	}
+	err = p.AppspaceOutboundModel.Set(appspace.AppspaceID, clean)
+	if err != nil {
+		return err
+	}
+	logStr := "none"
+	if len(clean) != 0 {
+		logStr = strings.Join(clean, ", ")
+	}
+	p.AppspaceLogger.Log(appspace.AppspaceID, "ds-host", "Outbound hosts approved: "+logStr)
+	p.SandboxManager.StopAppspace(appspace.AppspaceID)
+	return nil
+}
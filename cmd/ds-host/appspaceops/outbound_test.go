@@ -0,0 +1,87 @@
+package appspaceops
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+)
+
+func TestGetAllowed(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	appVersion := domain.AppVersion{AppID: domain.AppID(11), Version: domain.Version("0.1.0")}
+	appspace := &domain.Appspace{AppspaceID: domain.AppspaceID(7), AppID: appVersion.AppID}
+
+	appModel := testmocks.NewMockAppModel(mockCtrl)
+	appModel.EXPECT().GetVersionManifest(appVersion.AppID, appVersion.Version).Return(domain.AppVersionManifest{
+		Outbound: []string{"api.example.com", "*.example.org"}}, nil).Times(3)
+	outboundModel := testmocks.NewMockAppspaceOutboundModel(mockCtrl)
+	outboundModel.EXPECT().Get(appspace.AppspaceID).Return([]string{"api.example.com", "old.example.net"}, nil)
+
+	p := &OutboundPolicy{
+		AppModel:              appModel,
+		AppspaceOutboundModel: outboundModel}
+
+	allowed, err := p.GetAllowed(appVersion, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allowed) != 2 {
+		t.Errorf("expected app-only sandbox to get declared hosts: %v", allowed)
+	}
+
+	allowed, err = p.GetAllowed(appVersion, appspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allowed) != 1 || allowed[0] != "api.example.com" {
+		t.Errorf("expected approved and declared hosts only: %v", allowed)
+	}
+
+	// never approved: limited to declared hosts
+	outboundModel.EXPECT().Get(appspace.AppspaceID).Return(nil, nil)
+	allowed, err = p.GetAllowed(appVersion, appspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allowed) != 2 {
+		t.Errorf("expected declared hosts: %v", allowed)
+	}
+}
+
+func TestApproveNotDeclared(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	appspace := domain.Appspace{AppspaceID: domain.AppspaceID(7), AppID: domain.AppID(11)}
+	version := domain.Version("0.2.0")
+
+	appModel := testmocks.NewMockAppModel(mockCtrl)
+	appModel.EXPECT().GetVersionManifest(appspace.AppID, version).Return(domain.AppVersionManifest{
+		Outbound: []string{"api.example.com"}}, nil).Times(2)
+	outboundModel := testmocks.NewMockAppspaceOutboundModel(mockCtrl)
+	outboundModel.EXPECT().Set(appspace.AppspaceID, []string{"api.example.com"}).Return(nil)
+	appspaceLogger := testmocks.NewMockAppspaceLogger(mockCtrl)
+	appspaceLogger.EXPECT().Log(appspace.AppspaceID, "ds-host", gomock.Any())
+	sandboxManager := testmocks.NewMockSandboxManager(mockCtrl)
+	sandboxManager.EXPECT().StopAppspace(appspace.AppspaceID)
+
+	p := &OutboundPolicy{
+		AppModel:              appModel,
+		AppspaceOutboundModel: outboundModel,
+		AppspaceLogger:        appspaceLogger,
+		SandboxManager:        sandboxManager}
+
+	err := p.Approve(appspace, version, []string{"evil.example.com"})
+	if err != domain.ErrOutboundNotDeclared {
+		t.Errorf("expected not declared error, got %v", err)
+	}
+
+	err = p.Approve(appspace, version, []string{"API.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,509 @@
+package appspaceops
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+	"github.com/teleclimber/DropServer/internal/validator"
+)
+
+// Appspace transfer, receiving side:
+// The owner creates a ticket on this host, which fixes the domain and dropid
+// of the appspace to be created. The ticket code is a URL that includes
+// a secret in its fragment. The owner gives that code to the sending host.
+// The sending host opens a session (see transferMAC), uploads the bundle
+// in chunks, then asks this host to import it. Data received is tied to
+// the bundle's hash, so a sender can open a new session and resume the upload.
+
+// ticketDuration is how long a ticket can be used to start a transfer
+var ticketDuration = 24 * time.Hour
+
+// maxTransferSize limits the size of a transferred bundle
+var maxTransferSize int64 = 20 << 30
+
+// maxTransferSessions limits the number of sessions a sender can open on a ticket.
+// Each session's nonce is kept to prevent replays.
+const maxTransferSessions = 1000
+
+const (
+	transferWaiting   = "waiting"
+	transferReceiving = "receiving"
+	transferImporting = "importing"
+	transferDone      = "done"
+	transferFailed    = "failed"
+)
+
+type transferTicket struct {
+	lock       sync.Mutex
+	data       domain.AppspaceTransferTicket
+	dropID     domain.DropID
+	baseDomain string
+	subDomain  string
+	secret     []byte
+	session    string
+	nonces     map[string]struct{}
+	bundleHash string
+	dir        string
+	offset     int64
+	uploading  bool
+	url        string
+	timer      *time.Timer
+}
+
+// ReceiveTransfer creates appspaces from bundles pushed by other hosts
+type ReceiveTransfer struct {
+	Config           *domain.RuntimeConfig `checkinject:"required"`
+	DomainController interface {
+		CheckAppspaceDomain(userID domain.UserID, dom string, subdomain string) (domain.DomainCheckResult, error)
+	} `checkinject:"required"`
+	ImportAppspace interface {
+		Prepare(userID domain.UserID, reader io.Reader) (string, domain.AppspaceBundleMeta, error)
+		Import(tok string, dropID domain.DropID, baseDomain, subDomain string, passphrase string) (domain.AppspaceID, error)
+	} `checkinject:"required"`
+
+	ticketsMux sync.Mutex
+	tickets    map[string]*transferTicket
+}
+
+func (r *ReceiveTransfer) Init() {
+	r.tickets = make(map[string]*transferTicket)
+}
+
+// CreateTicket creates a ticket that lets a remote host push an appspace
+// to this host. The returned ticket includes the code that the remote host needs.
+func (r *ReceiveTransfer) CreateTicket(dropID domain.DropID, baseDomain, subDomain string) (domain.AppspaceTransferTicket, error) {
+	check, err := r.DomainController.CheckAppspaceDomain(dropID.UserID, baseDomain, subDomain)
+	if err != nil {
+		return domain.AppspaceTransferTicket{}, err
+	}
+	if !check.Valid || !check.Available {
+		return domain.AppspaceTransferTicket{}, errors.New("bad input: domain invalid or unavailable")
+	}
+
+	ticketID, err := randomHex(16)
+	if err != nil {
+		return domain.AppspaceTransferTicket{}, err
+	}
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return domain.AppspaceTransferTicket{}, err
+	}
+
+	fullDomain := baseDomain
+	if subDomain != "" {
+		fullDomain = subDomain + "." + baseDomain
+	}
+	now := time.Now()
+	t := &transferTicket{
+		data: domain.AppspaceTransferTicket{
+			TicketID:   ticketID,
+			DomainName: fullDomain,
+			DropID:     validator.JoinDropID(dropID.Handle, dropID.Domain),
+			Created:    now,
+			Expires:    now.Add(ticketDuration),
+			State:      transferWaiting},
+		dropID:     dropID,
+		baseDomain: baseDomain,
+		subDomain:  subDomain,
+		secret:     secret,
+		nonces:     make(map[string]struct{}),
+		url:        fmt.Sprintf("%s://%s%s", r.Config.ExternalAccess.Scheme, fullDomain, r.Config.Exec.PortString),
+	}
+
+	t.timer = time.AfterFunc(ticketDuration, func() {
+		r.expire(ticketID)
+	})
+
+	r.ticketsMux.Lock()
+	r.tickets[ticketID] = t
+	r.ticketsMux.Unlock()
+
+	ret := t.data
+	ret.Code = fmt.Sprintf("%s://%s%s/.dropserver/v0/transfer/%s#%s",
+		r.Config.ExternalAccess.Scheme, r.Config.Exec.UserRoutesDomain, r.Config.Exec.PortString,
+		ticketID, hex.EncodeToString(secret))
+
+	return ret, nil
+}
+
+// GetTickets returns the user's tickets
+func (r *ReceiveTransfer) GetTickets(userID domain.UserID) []domain.AppspaceTransferTicket {
+	r.ticketsMux.Lock()
+	defer r.ticketsMux.Unlock()
+	ret := make([]domain.AppspaceTransferTicket, 0)
+	for _, t := range r.tickets {
+		if t.dropID.UserID == userID {
+			t.lock.Lock()
+			ret = append(ret, t.data)
+			t.lock.Unlock()
+		}
+	}
+	return ret
+}
+
+// DeleteTicket removes the ticket and any partially uploaded bundle.
+// A ticket can not be deleted while its bundle is being imported.
+func (r *ReceiveTransfer) DeleteTicket(userID domain.UserID, ticketID string) error {
+	t, ok := r.getTicket(ticketID)
+	if !ok || t.dropID.UserID != userID {
+		return domain.ErrTokenNotFound
+	}
+	t.lock.Lock()
+	importing := t.data.State == transferImporting
+	t.lock.Unlock()
+	if importing {
+		return errors.New("bad input: transfer is being imported")
+	}
+	r.delete(ticketID)
+	return nil
+}
+
+// Hello opens a session on the ticket for the sending host.
+// The sender must prove it knows the ticket secret before anything changes.
+// Data received so far is kept if the sender declares the same bundle hash,
+// and discarded if it declares a different one.
+func (r *ReceiveTransfer) Hello(ticketID string, hello domain.V0TransferHello) (domain.V0TransferHelloResp, error) {
+	t, ok := r.getTicket(ticketID)
+	if !ok {
+		return domain.V0TransferHelloResp{}, domain.ErrTokenNotFound
+	}
+	if len(hello.Nonce) < 32 {
+		return domain.V0TransferHelloResp{}, errors.New("bad input: nonce too short")
+	}
+	if hello.SHA256 != "" && !validSHA256(hello.SHA256) {
+		return domain.V0TransferHelloResp{}, errors.New("bad input: invalid bundle hash")
+	}
+	nonce, err := randomHex(32)
+	if err != nil {
+		return domain.V0TransferHelloResp{}, err
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !hmac.Equal([]byte(hello.Proof), []byte(transferMAC(t.secret, "sender", hello.Nonce, hello.SHA256))) {
+		return domain.V0TransferHelloResp{}, domain.ErrBadAuth
+	}
+	// a replayed hello could take over the session
+	if _, used := t.nonces[hello.Nonce]; used {
+		return domain.V0TransferHelloResp{}, domain.ErrBadAuth
+	}
+	if len(t.nonces) >= maxTransferSessions {
+		return domain.V0TransferHelloResp{}, errors.New("bad input: too many sessions")
+	}
+	t.nonces[hello.Nonce] = struct{}{}
+
+	if t.data.State != transferWaiting && t.data.State != transferReceiving {
+		return domain.V0TransferHelloResp{}, errors.New("bad input: transfer already completed")
+	}
+	t.session = transferMAC(t.secret, "session", hello.Nonce, nonce)
+	if hello.SHA256 != "" && hello.SHA256 != t.bundleHash {
+		// a different bundle, so start over.
+		t.bundleHash = hello.SHA256
+		t.offset = 0
+		t.data.State = transferWaiting
+	}
+
+	return domain.V0TransferHelloResp{
+		Nonce: nonce,
+		Proof: transferMAC(t.secret, "target", hello.Nonce, nonce),
+	}, nil
+}
+
+// Authorize returns true if the session token is valid for the ticket
+func (r *ReceiveTransfer) Authorize(ticketID string, session string) bool {
+	t, ok := r.getTicket(ticketID)
+	if !ok {
+		return false
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.session != "" && hmac.Equal([]byte(t.session), []byte(session))
+}
+
+// GetOffset returns the number of bytes of the bundle received so far
+func (r *ReceiveTransfer) GetOffset(ticketID string) (int64, error) {
+	t, ok := r.getTicket(ticketID)
+	if !ok {
+		return 0, domain.ErrTokenNotFound
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.offset, nil
+}
+
+// WriteChunk appends the data to the bundle.
+// The offset must match the number of bytes received so far.
+// It returns the new offset, which is correct even if the write failed partway.
+// Only one chunk can be uploaded at a time.
+func (r *ReceiveTransfer) WriteChunk(ticketID string, offset int64, reader io.Reader) (int64, error) {
+	t, ok := r.getTicket(ticketID)
+	if !ok {
+		return 0, domain.ErrTokenNotFound
+	}
+	dir, bundleHash, err := r.startChunk(t, offset)
+	if err != nil {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+		return t.offset, err
+	}
+
+	// The upload can take a while, so the ticket is not locked during the copy.
+	n, err := r.writeChunk(filepath.Join(dir, "bundle.zip"), offset, reader)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.uploading = false
+	if t.dir != dir || t.bundleHash != bundleHash || t.offset != offset {
+		// the ticket was deleted or the sender started over with another bundle
+		return t.offset, domain.ErrTransferOffset
+	}
+	if offset+n > maxTransferSize {
+		return t.offset, domain.ErrStorageExceeded
+	}
+	t.offset += n
+	return t.offset, err
+}
+
+// startChunk checks the chunk can be written at offset and marks the upload in progress.
+// It returns the directory of the bundle file and the hash of the bundle.
+func (r *ReceiveTransfer) startChunk(t *transferTicket, offset int64) (string, string, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.data.State != transferWaiting && t.data.State != transferReceiving {
+		return "", "", errors.New("bad input: transfer is no longer receiving data")
+	}
+	if t.bundleHash == "" {
+		return "", "", errors.New("bad input: no bundle declared")
+	}
+	if t.uploading {
+		return "", "", errors.New("bad input: a chunk is already being uploaded")
+	}
+	if offset != t.offset {
+		return "", "", domain.ErrTransferOffset
+	}
+	if t.dir == "" {
+		dir, err := os.MkdirTemp(os.TempDir(), "ds-temp-*")
+		if err != nil {
+			r.getLogger("startChunk, os.MkdirTemp()").Error(err)
+			return "", "", err
+		}
+		t.dir = dir
+	}
+	t.data.State = transferReceiving
+	t.uploading = true
+	return t.dir, t.bundleHash, nil
+}
+
+// writeChunk writes the data to the bundle file at offset,
+// dropping any partial write left after offset by a failed chunk.
+func (r *ReceiveTransfer) writeChunk(bundleFile string, offset int64, reader io.Reader) (int64, error) {
+	f, err := os.OpenFile(bundleFile, os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		r.getLogger("writeChunk, os.OpenFile()").Error(err)
+		return 0, err
+	}
+	defer f.Close()
+	err = f.Truncate(offset)
+	if err != nil {
+		return 0, err
+	}
+	_, err = f.Seek(offset, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	return io.Copy(f, io.LimitReader(reader, maxTransferSize-offset+1))
+}
+
+// Import checks the uploaded bundle against the size and hash
+// sent by the remote, then imports it in the background.
+func (r *ReceiveTransfer) Import(ticketID string, data domain.V0TransferImport) error {
+	t, ok := r.getTicket(ticketID)
+	if !ok {
+		return domain.ErrTokenNotFound
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.data.State != transferReceiving {
+		return errors.New("bad input: no bundle to import")
+	}
+	if t.uploading {
+		return errors.New("bad input: a chunk is still being uploaded")
+	}
+	if data.SHA256 != t.bundleHash {
+		return errors.New("bad input: bundle hash does not match the declared hash")
+	}
+	if t.offset != data.Size {
+		return fmt.Errorf("bad input: expected %v bytes, received %v", data.Size, t.offset)
+	}
+	bundleFile := filepath.Join(t.dir, "bundle.zip")
+	f, err := os.Open(bundleFile)
+	if err != nil {
+		return err
+	}
+	hash, err := hashReader(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if hash != data.SHA256 {
+		return errors.New("bad input: bundle hash mismatch")
+	}
+
+	t.data.State = transferImporting
+	go r.importBundle(t, bundleFile)
+
+	return nil
+}
+
+func (r *ReceiveTransfer) importBundle(t *transferTicket, bundleFile string) {
+	log := r.getLogger("importBundle").AddNote(t.data.TicketID).Clone
+
+	appspaceID, err := func() (domain.AppspaceID, error) {
+		f, err := os.Open(bundleFile)
+		if err != nil {
+			return domain.AppspaceID(0), err
+		}
+		defer f.Close()
+		tok, _, err := r.ImportAppspace.Prepare(t.dropID.UserID, f)
+		if err != nil {
+			return domain.AppspaceID(0), err
+		}
+		return r.ImportAppspace.Import(tok, t.dropID, t.baseDomain, t.subDomain, "")
+	}()
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if err != nil {
+		log().Error(err)
+		t.data.State = transferFailed
+		t.data.Error = err.Error()
+	} else {
+		t.data.State = transferDone
+		t.data.AppspaceID = appspaceID
+	}
+	// The bundle is no longer needed either way.
+	// The ticket itself is kept until it expires so the remote can get the result.
+	err = os.RemoveAll(t.dir)
+	if err != nil {
+		log().AddNote("os.RemoveAll").Error(err)
+	}
+	t.dir = ""
+}
+
+// GetStatus returns the state of the transfer for the remote host
+func (r *ReceiveTransfer) GetStatus(ticketID string) (domain.V0TransferStatus, error) {
+	t, ok := r.getTicket(ticketID)
+	if !ok {
+		return domain.V0TransferStatus{}, domain.ErrTokenNotFound
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ret := domain.V0TransferStatus{
+		State: t.data.State,
+		Error: t.data.Error}
+	if t.data.State == transferDone {
+		ret.AppspaceURL = t.url
+	}
+	return ret, nil
+}
+
+func (r *ReceiveTransfer) getTicket(ticketID string) (*transferTicket, bool) {
+	r.ticketsMux.Lock()
+	defer r.ticketsMux.Unlock()
+	t, ok := r.tickets[ticketID]
+	return t, ok
+}
+
+func (r *ReceiveTransfer) expire(ticketID string) {
+	t, ok := r.getTicket(ticketID)
+	if !ok {
+		return
+	}
+	t.lock.Lock()
+	importing := t.data.State == transferImporting
+	t.lock.Unlock()
+	if importing {
+		// check back later
+		t.timer.Reset(time.Minute)
+		return
+	}
+	r.delete(ticketID)
+}
+
+func (r *ReceiveTransfer) delete(ticketID string) {
+	r.ticketsMux.Lock()
+	t, ok := r.tickets[ticketID]
+	delete(r.tickets, ticketID)
+	r.ticketsMux.Unlock()
+	if !ok {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.timer.Stop()
+	if t.dir != "" {
+		err := os.RemoveAll(t.dir)
+		if err != nil {
+			r.getLogger("delete, os.RemoveAll()").Error(err)
+		}
+		t.dir = ""
+	}
+}
+
+// DeleteAll removes all tickets and uploaded data
+func (r *ReceiveTransfer) DeleteAll() {
+	r.ticketsMux.Lock()
+	ids := make([]string, 0, len(r.tickets))
+	for id := range r.tickets {
+		ids = append(ids, id)
+	}
+	r.ticketsMux.Unlock()
+	for _, id := range ids {
+		r.delete(id)
+	}
+}
+
+func (r *ReceiveTransfer) getLogger(note string) *record.DsLogger {
+	l := record.NewDsLogger().AddNote("ReceiveTransfer")
+	if note != "" {
+		l.AddNote(note)
+	}
+	return l
+}
+
+func validSHA256(h string) bool {
+	if len(h) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(h)
+	return err == nil && strings.ToLower(h) == h
+}
+
+// parseTransferCode splits a ticket code into the ticket's base URL and secret
+func parseTransferCode(code string) (string, []byte, error) {
+	u, err := url.Parse(code)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", nil, errors.New("bad input: invalid transfer code")
+	}
+	secret, err := hex.DecodeString(u.Fragment)
+	if err != nil || len(secret) != 32 {
+		return "", nil, errors.New("bad input: invalid transfer code secret")
+	}
+	u.Fragment = ""
+	return u.String(), secret, nil
+}
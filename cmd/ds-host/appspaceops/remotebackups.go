@@ -0,0 +1,439 @@
+package appspaceops
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+	"github.com/teleclimber/DropServer/internal/backupcrypt"
+	"github.com/teleclimber/DropServer/internal/objstore"
+	"github.com/teleclimber/DropServer/internal/snapshot"
+	"github.com/teleclimber/DropServer/internal/validator"
+)
+
+// Layout of an appspace's backups on a remote:
+// <appspace domain>/backups/<backup file>
+// <appspace domain>/chunks/<xx>/<hash>
+// Chunks are copied as-is from the local chunk store
+// so snapshots stay deduplicated on the remote.
+// Remote backups are never deleted by ds-host.
+// Use the storage's own retention features if needed.
+// If the appspace has a backup key, backups are uploaded
+// as a single encrypted zip (<backup name>.zip.enc) instead.
+
+type remoteUpload struct {
+	appspaceID domain.AppspaceID
+	backupFile string
+}
+
+// RemoteBackups copies appspace backups to the backup remotes
+// set in the runtime config, and fetches them back for restore.
+type RemoteBackups struct {
+	Config        *domain.RuntimeConfig `checkinject:"required"`
+	AppspaceModel interface {
+		GetFromID(appspaceID domain.AppspaceID) (*domain.Appspace, error)
+	} `checkinject:"required"`
+	AppspaceLogger interface {
+		Log(appspaceID domain.AppspaceID, source string, message string)
+	} `checkinject:"required"`
+	AppspaceLocation2Path interface {
+		Backup(string, string) string
+		BackupChunks(string) string
+	} `checkinject:"required"`
+	AppspaceFilesModel interface {
+		WriteBackupZip(locationKey string, filename string, w io.Writer) error
+	} `checkinject:"required"`
+	BackupKeyModel interface {
+		GetRecipient(domain.AppspaceID) (backupcrypt.Recipient, bool, error)
+	} `checkinject:"required"`
+
+	names   []string
+	remotes map[string]objstore.Store
+
+	queueMux sync.Mutex
+	queue    []remoteUpload
+	wake     chan struct{}
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// Init creates the stores for the configured remotes
+func (r *RemoteBackups) Init() {
+	r.names = []string{}
+	r.remotes = make(map[string]objstore.Store)
+	for _, c := range r.Config.Backups.Remotes {
+		var store objstore.Store
+		switch c.Type {
+		case "dir":
+			store = objstore.Dir{Path: c.Dir}
+		case "s3":
+			store = &objstore.S3{
+				Endpoint:        c.S3.Endpoint,
+				Region:          c.S3.Region,
+				Bucket:          c.S3.Bucket,
+				Prefix:          c.S3.Prefix,
+				AccessKeyID:     c.S3.AccessKeyID,
+				SecretAccessKey: c.S3.SecretAccessKey,
+				PathStyle:       c.S3.PathStyle}
+		default:
+			continue
+		}
+		r.names = append(r.names, c.Name)
+		r.remotes[c.Name] = store
+	}
+}
+
+// Start the upload worker
+func (r *RemoteBackups) Start() {
+	r.wake = make(chan struct{}, 1)
+	r.stop = make(chan struct{})
+	r.stopped = make(chan struct{})
+	go func() {
+		defer close(r.stopped)
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-r.wake:
+				r.uploadQueued()
+			}
+		}
+	}()
+}
+
+// Stop waits for the ongoing upload to finish.
+// Backups still in the queue are not uploaded.
+func (r *RemoteBackups) Stop() {
+	close(r.stop)
+	<-r.stopped
+}
+
+// Queue a backup for upload to all remotes
+func (r *RemoteBackups) Queue(appspaceID domain.AppspaceID, backupFile string) {
+	if len(r.names) == 0 {
+		return
+	}
+	r.queueMux.Lock()
+	r.queue = append(r.queue, remoteUpload{appspaceID, backupFile})
+	r.queueMux.Unlock()
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *RemoteBackups) uploadQueued() {
+	for {
+		select {
+		case <-r.stop:
+			return
+		default:
+		}
+		r.queueMux.Lock()
+		if len(r.queue) == 0 {
+			r.queueMux.Unlock()
+			return
+		}
+		u := r.queue[0]
+		r.queue = r.queue[1:]
+		r.queueMux.Unlock()
+
+		for _, name := range r.names {
+			err := r.Upload(u.appspaceID, name, u.backupFile)
+			if err != nil {
+				r.AppspaceLogger.Log(u.appspaceID, "ds-host", fmt.Sprintf("failed to upload backup %s to remote %s: %s", u.backupFile, name, err))
+			} else {
+				r.AppspaceLogger.Log(u.appspaceID, "ds-host", fmt.Sprintf("uploaded backup %s to remote %s", u.backupFile, name))
+			}
+		}
+	}
+}
+
+// Upload copies a local backup to the remote
+func (r *RemoteBackups) Upload(appspaceID domain.AppspaceID, remote string, backupFile string) error {
+	log := r.getLogger("Upload").AppspaceID(appspaceID).Clone
+	store, ok := r.remotes[remote]
+	if !ok {
+		return domain.ErrRemoteBackupNotFound
+	}
+	appspace, err := r.AppspaceModel.GetFromID(appspaceID)
+	if err != nil {
+		log().AddNote("GetFromID").Error(err)
+		return err
+	}
+	backupPath := r.AppspaceLocation2Path.Backup(appspace.LocationKey, backupFile)
+
+	recipient, encrypt, err := r.BackupKeyModel.GetRecipient(appspaceID)
+	if err != nil {
+		return err
+	}
+	if encrypt {
+		err = r.uploadEncrypted(store, appspace, backupFile, recipient)
+		if err != nil {
+			log().AddNote("uploadEncrypted").Error(err)
+		}
+		return err
+	}
+
+	if strings.HasSuffix(backupFile, snapshotExt) {
+		// ReadManifest validates chunk hashes before they are used as keys
+		manifest, err := snapshot.ReadManifest(backupPath)
+		if err != nil {
+			log().AddNote("ReadManifest").Error(err)
+			return err
+		}
+		err = r.uploadChunks(store, appspace, manifest)
+		if err != nil {
+			log().AddNote("uploadChunks").Error(err)
+			return err
+		}
+	}
+
+	// the backup file goes last so that a listed snapshot is always complete
+	err = putFile(store, remoteBackupKey(appspace.DomainName, backupFile), backupPath)
+	if err != nil {
+		log().AddNote("putFile").Error(err)
+		return err
+	}
+	return nil
+}
+
+func (r *RemoteBackups) uploadChunks(store objstore.Store, appspace *domain.Appspace, manifest snapshot.Manifest) error {
+	prefix := path.Join(appspace.DomainName, "chunks") + "/"
+	existing, err := store.List(prefix)
+	if err != nil {
+		return err
+	}
+	have := make(map[string]struct{}, len(existing))
+	for _, k := range existing {
+		have[path.Base(k)] = struct{}{}
+	}
+
+	local := snapshot.Store{Dir: r.AppspaceLocation2Path.BackupChunks(appspace.LocationKey)}
+	for _, hash := range manifest.ChunkHashes() {
+		if _, ok := have[hash]; ok {
+			continue
+		}
+		f, size, err := local.OpenChunk(hash)
+		if err != nil {
+			return err
+		}
+		err = store.Put(remoteChunkKey(appspace.DomainName, hash), f, size)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uploadEncrypted uploads the backup as an encrypted zip.
+// It is written to a temp file first because the size must be known to upload.
+func (r *RemoteBackups) uploadEncrypted(store objstore.Store, appspace *domain.Appspace, backupFile string, recipient backupcrypt.Recipient) error {
+	tmp, err := os.CreateTemp(os.TempDir(), "ds-temp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	ew, err := backupcrypt.Encrypt(tmp, recipient)
+	if err != nil {
+		return err
+	}
+	err = r.AppspaceFilesModel.WriteBackupZip(appspace.LocationKey, backupFile, ew)
+	if err != nil {
+		return err
+	}
+	err = ew.Close()
+	if err != nil {
+		return err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	encFile := strings.TrimSuffix(strings.TrimSuffix(backupFile, snapshotExt), zipExt) + encZipExt
+	return store.Put(remoteBackupKey(appspace.DomainName, encFile), tmp, size)
+}
+
+// List returns the backups on each remote
+// An error listing a remote is reported in the result
+// rather than failing the whole listing.
+func (r *RemoteBackups) List(appspaceID domain.AppspaceID) ([]domain.RemoteBackupList, error) {
+	appspace, err := r.AppspaceModel.GetFromID(appspaceID)
+	if err != nil {
+		r.getLogger("List, GetFromID").AppspaceID(appspaceID).Error(err)
+		return nil, err
+	}
+	ret := []domain.RemoteBackupList{}
+	for _, name := range r.names {
+		l := domain.RemoteBackupList{Remote: name, Backups: []string{}}
+		keys, err := r.remotes[name].List(path.Join(appspace.DomainName, "backups") + "/")
+		if err != nil {
+			r.getLogger("List, store.List").AppspaceID(appspaceID).AddNote(name).Error(err)
+			l.Error = err.Error()
+		}
+		for _, k := range keys {
+			f := path.Base(k)
+			if validator.AppspaceBackupFile(f) == nil {
+				l.Backups = append(l.Backups, f)
+			}
+		}
+		ret = append(ret, l)
+	}
+	return ret, nil
+}
+
+// Download fetches a remote backup into destDir and returns the path of the backup file.
+// Snapshot chunks that are missing locally are added to the appspace's chunk store.
+func (r *RemoteBackups) Download(appspaceID domain.AppspaceID, remote string, backupFile string, destDir string) (string, error) {
+	log := r.getLogger("Download").AppspaceID(appspaceID).Clone
+	store, ok := r.remotes[remote]
+	if !ok {
+		return "", domain.ErrRemoteBackupNotFound
+	}
+	err := validator.AppspaceBackupFile(backupFile)
+	if err != nil {
+		return "", err
+	}
+	appspace, err := r.AppspaceModel.GetFromID(appspaceID)
+	if err != nil {
+		log().AddNote("GetFromID").Error(err)
+		return "", err
+	}
+
+	destPath := filepath.Join(destDir, backupFile)
+	err = getFile(store, remoteBackupKey(appspace.DomainName, backupFile), destPath)
+	if err == objstore.ErrNotFound {
+		return "", domain.ErrRemoteBackupNotFound
+	}
+	if err != nil {
+		log().AddNote("getFile").Error(err)
+		return "", err
+	}
+
+	if !strings.HasSuffix(backupFile, snapshotExt) {
+		return destPath, nil
+	}
+
+	// The remote is not trusted: ReadManifest rejects malformed chunk hashes
+	// and file paths before anything touches the local store.
+	manifest, err := snapshot.ReadManifest(destPath)
+	if err != nil {
+		log().AddNote("ReadManifest").Error(err)
+		os.Remove(destPath)
+		return "", err
+	}
+	local := snapshot.Store{Dir: r.AppspaceLocation2Path.BackupChunks(appspace.LocationKey)}
+	for _, hash := range manifest.ChunkHashes() {
+		if local.HasChunk(hash) {
+			continue
+		}
+		rc, err := store.Get(remoteChunkKey(appspace.DomainName, hash))
+		if err != nil {
+			log().AddNote("store.Get chunk").Error(err)
+			return "", err
+		}
+		err = local.ImportChunk(hash, rc)
+		rc.Close()
+		if err != nil {
+			log().AddNote("ImportChunk").Error(err)
+			return "", err
+		}
+	}
+	return destPath, nil
+}
+
+// WriteZip writes a remote backup to w as a zip file
+func (r *RemoteBackups) WriteZip(appspaceID domain.AppspaceID, remote string, backupFile string, w io.Writer) error {
+	dir, err := os.MkdirTemp(os.TempDir(), "ds-temp-*")
+	if err != nil {
+		r.getLogger("WriteZip, MkdirTemp").Error(err)
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	p, err := r.Download(appspaceID, remote, backupFile, dir)
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasSuffix(backupFile, snapshotExt) {
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	}
+
+	appspace, err := r.AppspaceModel.GetFromID(appspaceID)
+	if err != nil {
+		return err
+	}
+	manifest, err := snapshot.ReadManifest(p) // validated
+	if err != nil {
+		return err
+	}
+	local := snapshot.Store{Dir: r.AppspaceLocation2Path.BackupChunks(appspace.LocationKey)}
+	return local.WriteZip(manifest, w)
+}
+
+func (r *RemoteBackups) getLogger(note string) *record.DsLogger {
+	l := record.NewDsLogger().AddNote("RemoteBackups")
+	if note != "" {
+		l.AddNote(note)
+	}
+	return l
+}
+
+func remoteBackupKey(domainName string, backupFile string) string {
+	return path.Join(domainName, "backups", backupFile)
+}
+
+func remoteChunkKey(domainName string, hash string) string {
+	return path.Join(domainName, "chunks", hash[:2], hash)
+}
+
+func putFile(store objstore.Store, key string, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return store.Put(key, f, info.Size())
+}
+
+func getFile(store objstore.Store, key string, p string) error {
+	rc, err := store.Get(key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, rc)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,247 @@
+package appspaceops
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/models/appspacefilesmodel"
+	"github.com/teleclimber/DropServer/cmd/ds-host/runtimeconfig"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+	"github.com/teleclimber/DropServer/internal/backupcrypt"
+	"github.com/teleclimber/DropServer/internal/snapshot"
+)
+
+func TestRemoteBackupUploadDownload(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	dir := t.TempDir()
+	cfg := &domain.RuntimeConfig{}
+	cfg.Exec.AppspacesPath = filepath.Join(dir, "appspaces")
+	cfg.Backups.Remotes = []domain.BackupRemote{{Name: "nas", Type: "dir", Dir: filepath.Join(dir, "remote")}}
+	l2p := &runtimeconfig.AppspaceLocation2Path{Config: cfg}
+
+	asID := domain.AppspaceID(7)
+	loc := "as7"
+	appspace := &domain.Appspace{AppspaceID: asID, LocationKey: loc, DomainName: "as.example.com"}
+
+	// make a snapshot backup
+	srcDir := filepath.Join(dir, "src")
+	err := os.MkdirAll(srcDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(srcDir, "hello.txt"), []byte("hello"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := snapshot.Store{Dir: l2p.BackupChunks(loc)}
+	m, err := store.Create(srcDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.MkdirAll(l2p.Backups(loc), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backupFile := "2024-03-13_0300.snapshot"
+	err = snapshot.WriteManifest(m, l2p.Backup(loc, backupFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appspaceModel := testmocks.NewMockAppspaceModel(mockCtrl)
+	appspaceModel.EXPECT().GetFromID(asID).Return(appspace, nil).AnyTimes()
+
+	backupKeyModel := testmocks.NewMockBackupKeyModel(mockCtrl)
+	backupKeyModel.EXPECT().GetRecipient(asID).Return(backupcrypt.Recipient{}, false, nil)
+
+	r := &RemoteBackups{
+		Config:                cfg,
+		AppspaceModel:         appspaceModel,
+		AppspaceLocation2Path: l2p,
+		AppspaceFilesModel:    &appspacefilesmodel.AppspaceFilesModel{Config: cfg},
+		BackupKeyModel:        backupKeyModel,
+	}
+	r.Init()
+
+	err = r.Upload(asID, "nas", backupFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lists, err := r.List(asID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 1 || lists[0].Remote != "nas" || len(lists[0].Backups) != 1 || lists[0].Backups[0] != backupFile {
+		t.Errorf("unexpected remote backup list: %v", lists)
+	}
+
+	// lose the local chunks, then restore them from the remote
+	err = os.RemoveAll(l2p.BackupChunks(loc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	destDir := filepath.Join(dir, "dest")
+	err = os.MkdirAll(destDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := r.Download(asID, "nas", backupFile, destDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = snapshot.ReadManifest(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Restore(m, filepath.Join(dir, "restored"), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "restored", "hello.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("unexpected restored data: %s", data)
+	}
+
+	// a tampered manifest on the remote is rejected before any chunk is fetched
+	tampered := "2024-03-15_0300.snapshot"
+	badHash := "../../../../../../../../../../../../../../../../../../../../escape"
+	err = os.WriteFile(filepath.Join(dir, "remote", appspace.DomainName, "backups", tampered),
+		[]byte(`{"version":1,"files":[{"path":"a.txt","chunks":["`+badHash+`"]}]}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.Download(asID, "nas", tampered, destDir)
+	if err == nil || err == domain.ErrRemoteBackupNotFound {
+		t.Errorf("expected error for tampered manifest, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, tampered)); !os.IsNotExist(err) {
+		t.Error("expected tampered manifest to be removed")
+	}
+
+	_, err = r.Download(asID, "nas", "2024-03-14_0300.snapshot", destDir)
+	if err != domain.ErrRemoteBackupNotFound {
+		t.Errorf("expected ErrRemoteBackupNotFound, got %v", err)
+	}
+	_, err = r.Download(asID, "other", backupFile, destDir)
+	if err != domain.ErrRemoteBackupNotFound {
+		t.Errorf("expected ErrRemoteBackupNotFound, got %v", err)
+	}
+}
+
+func TestRemoteBackupEncrypted(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	dir := t.TempDir()
+	cfg := &domain.RuntimeConfig{}
+	cfg.Exec.AppspacesPath = filepath.Join(dir, "appspaces")
+	cfg.Backups.Remotes = []domain.BackupRemote{{Name: "nas", Type: "dir", Dir: filepath.Join(dir, "remote")}}
+	l2p := &runtimeconfig.AppspaceLocation2Path{Config: cfg}
+
+	asID := domain.AppspaceID(7)
+	loc := "as7"
+	appspace := &domain.Appspace{AppspaceID: asID, LocationKey: loc, DomainName: "as.example.com"}
+
+	srcDir := filepath.Join(dir, "src")
+	err := os.MkdirAll(filepath.Join(srcDir, "files"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(srcDir, "files", "hello.txt"), []byte("hello"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := snapshot.Store{Dir: l2p.BackupChunks(loc)}
+	m, err := store.Create(srcDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.MkdirAll(l2p.Backups(loc), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = snapshot.WriteManifest(m, l2p.Backup(loc, "2024-03-13_0300.snapshot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recipient, err := backupcrypt.NewRecipient("correct horse battery", backupcrypt.Params{Time: 1, Memory: 64, Threads: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appspaceModel := testmocks.NewMockAppspaceModel(mockCtrl)
+	appspaceModel.EXPECT().GetFromID(asID).Return(appspace, nil).AnyTimes()
+	backupKeyModel := testmocks.NewMockBackupKeyModel(mockCtrl)
+	backupKeyModel.EXPECT().GetRecipient(asID).Return(recipient, true, nil)
+
+	r := &RemoteBackups{
+		Config:                cfg,
+		AppspaceModel:         appspaceModel,
+		AppspaceLocation2Path: l2p,
+		AppspaceFilesModel:    &appspacefilesmodel.AppspaceFilesModel{Config: cfg},
+		BackupKeyModel:        backupKeyModel,
+	}
+	r.Init()
+
+	err = r.Upload(asID, "nas", "2024-03-13_0300.snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lists, err := r.List(asID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists[0].Backups) != 1 || lists[0].Backups[0] != "2024-03-13_0300.zip.enc" {
+		t.Fatalf("expected only the encrypted backup on the remote: %v", lists)
+	}
+
+	restore := &RestoreAppspace{
+		AppspaceModel:         appspaceModel,
+		AppspaceLocation2Path: l2p,
+		RemoteBackups:         r,
+	}
+	restore.Init()
+	tok, err := restore.PrepareRemoteBackup(asID, "nas", "2024-03-13_0300.zip.enc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore.delete(tok)
+
+	encrypted, err := restore.IsEncrypted(tok)
+	if err != nil || !encrypted {
+		t.Fatalf("expected encrypted: %v %v", encrypted, err)
+	}
+	err = restore.CheckAppspaceDataValid(tok)
+	if err != domain.ErrBackupEncrypted {
+		t.Errorf("expected ErrBackupEncrypted, got %v", err)
+	}
+	err = restore.Decrypt(tok, "wrong passphrase")
+	if err != backupcrypt.ErrBadPassphrase {
+		t.Errorf("expected ErrBadPassphrase, got %v", err)
+	}
+	err = restore.Decrypt(tok, "correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, _ = restore.IsEncrypted(tok)
+	if encrypted {
+		t.Error("expected decrypted")
+	}
+	data, err := os.ReadFile(filepath.Join(restore.tokens[tok].tempDir, "files", "hello.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("unexpected restored data: %s", data)
+	}
+}
@@ -7,11 +7,14 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
 	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+	"github.com/teleclimber/DropServer/internal/backupcrypt"
+	"github.com/teleclimber/DropServer/internal/snapshot"
 	"github.com/teleclimber/DropServer/internal/validator"
 	"github.com/teleclimber/DropServer/internal/zipfns"
 )
@@ -19,6 +22,7 @@ import (
 type tokenData struct {
 	tempZip     string
 	tempDir     string
+	encFile     string // set while an encrypted backup awaits its passphrase
 	timer       *time.Timer
 	cancelTimer chan struct{}
 }
@@ -53,7 +57,11 @@ type RestoreAppspace struct {
 	} `checkinject:"required"`
 	AppspaceLocation2Path interface {
 		Backup(string, string) string
+		BackupChunks(string) string
 	} `checkinject:"required"`
+	RemoteBackups interface {
+		Download(appspaceID domain.AppspaceID, remote string, backupFile string, destDir string) (string, error)
+	} `checkinject:"optional"`
 
 	tokensMux sync.Mutex
 	tokens    map[string]tokenData
@@ -92,7 +100,7 @@ func (r *RestoreAppspace) Prepare(reader io.Reader) (string, error) {
 		return "", err //internal error
 	}
 
-	err = r.unzipFile(tok, zipFile)
+	err = r.prepareFile(tok, zipFile)
 	if err != nil {
 		return "", err
 	}
@@ -100,6 +108,113 @@ func (r *RestoreAppspace) Prepare(reader io.Reader) (string, error) {
 	return tok, nil
 }
 
+// prepareFile unzips the file, unless it is encrypted.
+// Encrypted files are decrypted and unzipped once the passphrase is provided.
+func (r *RestoreAppspace) prepareFile(tok string, filePath string) error {
+	encrypted, err := isEncryptedFile(filePath)
+	if err != nil {
+		r.getLogger("prepareFile, isEncryptedFile()").Error(err)
+		return err
+	}
+	if !encrypted {
+		return r.unzipFile(tok, filePath)
+	}
+	r.tokensMux.Lock()
+	defer r.tokensMux.Unlock()
+	tokData, ok := r.tokens[tok]
+	if !ok {
+		return domain.ErrTokenNotFound
+	}
+	tokData.encFile = filePath
+	r.tokens[tok] = tokData
+	return nil
+}
+
+// IsEncrypted returns true if the prepared backup
+// needs a passphrase before it can be used.
+func (r *RestoreAppspace) IsEncrypted(tok string) (bool, error) {
+	r.tokensMux.Lock()
+	defer r.tokensMux.Unlock()
+	tokData, ok := r.tokens[tok]
+	if !ok {
+		return false, domain.ErrTokenNotFound
+	}
+	return tokData.encFile != "", nil
+}
+
+// Decrypt decrypts the prepared backup with the passphrase and unzips it.
+// It returns backupcrypt.ErrBadPassphrase if the passphrase is incorrect,
+// in which case it can be tried again.
+func (r *RestoreAppspace) Decrypt(tok string, passphrase string) error {
+	r.tokensMux.Lock()
+	tokData, ok := r.tokens[tok]
+	r.tokensMux.Unlock()
+	if !ok {
+		return domain.ErrTokenNotFound
+	}
+	if tokData.encFile == "" {
+		return errors.New("backup is not encrypted")
+	}
+
+	in, err := os.Open(tokData.encFile)
+	if err != nil {
+		r.getLogger("Decrypt, os.Open()").Error(err)
+		return err
+	}
+	defer in.Close()
+	dr, err := backupcrypt.Decrypt(in, passphrase)
+	if err == backupcrypt.ErrBadPassphrase {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("input error: %w", err)
+	}
+
+	zipFile := filepath.Join(filepath.Dir(tokData.encFile), "decrypted.zip")
+	out, err := os.Create(zipFile)
+	if err != nil {
+		r.getLogger("Decrypt, os.Create()").Error(err)
+		return err
+	}
+	n, err := io.Copy(out, io.LimitReader(dr, domain.ZipBackupExtractedPackageMaxSize+1))
+	out.Close()
+	if err != nil {
+		return fmt.Errorf("input error: %w", err)
+	}
+	if n > domain.ZipBackupExtractedPackageMaxSize {
+		return domain.ErrStorageExceeded
+	}
+
+	err = r.unzipFile(tok, zipFile)
+	if err != nil {
+		return err
+	}
+
+	r.tokensMux.Lock()
+	defer r.tokensMux.Unlock()
+	tokData, ok = r.tokens[tok]
+	if !ok {
+		return domain.ErrTokenNotFound
+	}
+	tokData.encFile = ""
+	r.tokens[tok] = tokData
+	return nil
+}
+
+func isEncryptedFile(p string) (bool, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	header := make([]byte, 16)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	return backupcrypt.IsEncrypted(header[:n]), nil
+}
+
 // PrepareBackup prepares an appspace's existing backup to restore
 func (r *RestoreAppspace) PrepareBackup(appspaceID domain.AppspaceID, backupFile string) (string, error) {
 	tok := r.newToken()
@@ -117,7 +232,12 @@ func (r *RestoreAppspace) PrepareBackup(appspaceID domain.AppspaceID, backupFile
 		return "", err
 	}
 
-	err = r.unzipFile(tok, r.AppspaceLocation2Path.Backup(appspace.LocationKey, backupFile))
+	backupPath := r.AppspaceLocation2Path.Backup(appspace.LocationKey, backupFile)
+	if strings.HasSuffix(backupFile, snapshotExt) {
+		err = r.restoreSnapshot(tok, backupPath, r.AppspaceLocation2Path.BackupChunks(appspace.LocationKey))
+	} else {
+		err = r.unzipFile(tok, backupPath)
+	}
 	if err != nil {
 		return "", err
 	}
@@ -125,25 +245,105 @@ func (r *RestoreAppspace) PrepareBackup(appspaceID domain.AppspaceID, backupFile
 	return tok, nil
 }
 
-// unzipFile takes a file (assumed zip) and unzips it
-// into a temporary directory and verifies its contents
-// it returns a token that can be used to commit the restore
-func (r *RestoreAppspace) unzipFile(tok string, filePath string) error {
+// PrepareRemoteBackup downloads a backup from a backup remote and prepares it to restore
+func (r *RestoreAppspace) PrepareRemoteBackup(appspaceID domain.AppspaceID, remote string, backupFile string) (string, error) {
+	if r.RemoteBackups == nil {
+		return "", errors.New("no backup remotes")
+	}
+	err := validator.AppspaceBackupFile(backupFile)
+	if err != nil {
+		r.getLogger("PrepareRemoteBackup, validator.AppspaceBackupFile").Error(err)
+		return "", err
+	}
+	appspace, err := r.AppspaceModel.GetFromID(appspaceID)
+	if err != nil {
+		r.getLogger("PrepareRemoteBackup, get appspace").Error(err)
+		return "", err
+	}
+
+	tok := r.newToken()
+
 	dir, err := os.MkdirTemp(os.TempDir(), "ds-temp-*")
 	if err != nil {
-		r.getLogger("unzipFile, os.MkdirTemp()").Error(err)
-		return err //internal error
+		r.getLogger("PrepareRemoteBackup, os.MkdirTemp()").Error(err)
+		return "", err //internal error
+	}
+	r.tokensMux.Lock()
+	tokData := r.tokens[tok]
+	tokData.tempZip = dir
+	r.tokens[tok] = tokData
+	r.tokensMux.Unlock()
+
+	backupPath, err := r.RemoteBackups.Download(appspaceID, remote, backupFile, dir)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.HasSuffix(backupFile, snapshotExt) {
+		err = r.restoreSnapshot(tok, backupPath, r.AppspaceLocation2Path.BackupChunks(appspace.LocationKey))
+	} else {
+		err = r.prepareFile(tok, backupPath)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return tok, nil
+}
+
+// restoreSnapshot recreates the files of a snapshot backup
+// into a temporary directory.
+func (r *RestoreAppspace) restoreSnapshot(tok string, manifestPath string, chunksDir string) error {
+	dir, err := r.setTempDir(tok)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := snapshot.ReadManifest(manifestPath)
+	if err != nil {
+		r.getLogger("restoreSnapshot, snapshot.ReadManifest()").Error(err)
+		return err
+	}
+
+	store := snapshot.Store{Dir: chunksDir}
+	err = store.Restore(manifest, dir, domain.ZipBackupExtractedPackageMaxSize)
+	if err != nil {
+		r.getLogger("restoreSnapshot, store.Restore()").Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// setTempDir creates a temporary directory for the token's data
+func (r *RestoreAppspace) setTempDir(tok string) (string, error) {
+	dir, err := os.MkdirTemp(os.TempDir(), "ds-temp-*")
+	if err != nil {
+		r.getLogger("setTempDir, os.MkdirTemp()").Error(err)
+		return "", err //internal error
 	}
 
 	r.tokensMux.Lock()
+	defer r.tokensMux.Unlock()
 	tokData, ok := r.tokens[tok]
 	if !ok {
-		r.tokensMux.Unlock()
-		return domain.ErrTokenNotFound
+		os.RemoveAll(dir)
+		return "", domain.ErrTokenNotFound
 	}
 	tokData.tempDir = dir
 	r.tokens[tok] = tokData
-	r.tokensMux.Unlock()
+
+	return dir, nil
+}
+
+// unzipFile takes a file (assumed zip) and unzips it
+// into a temporary directory and verifies its contents
+// it returns a token that can be used to commit the restore
+func (r *RestoreAppspace) unzipFile(tok string, filePath string) error {
+	dir, err := r.setTempDir(tok)
+	if err != nil {
+		return err
+	}
 
 	// then unzip
 	err = zipfns.Unzip(filePath, dir, domain.ZipBackupExtractedPackageMaxSize) // hard coded for now.
@@ -165,6 +365,9 @@ func (r *RestoreAppspace) CheckAppspaceDataValid(tok string) error {
 	if !ok {
 		return domain.ErrTokenNotFound
 	}
+	if tokData.encFile != "" {
+		return domain.ErrBackupEncrypted
+	}
 	err := r.AppspaceFilesModel.CheckDataFiles(tokData.tempDir)
 	return err
 }
@@ -178,6 +381,9 @@ func (r *RestoreAppspace) GetMetaInfo(tok string) (domain.AppspaceMetaInfo, erro
 	if !ok {
 		return domain.AppspaceMetaInfo{}, domain.ErrTokenNotFound
 	}
+	if tokData.encFile != "" {
+		return domain.AppspaceMetaInfo{}, domain.ErrBackupEncrypted
+	}
 
 	// after zip, read appspace meta data
 	metaInfo, err := r.InfoModel.GetAppspaceMetaInfo(tokData.tempDir)
@@ -232,6 +438,9 @@ func (r *RestoreAppspace) ReplaceData(tok string, appspaceID domain.AppspaceID)
 		log().AddNote("r.tokens[tok]").Error(domain.ErrTokenNotFound)
 		return domain.ErrTokenNotFound
 	}
+	if tokData.encFile != "" {
+		return domain.ErrBackupEncrypted
+	}
 
 	err = r.AppspaceFilesModel.ReplaceData(*appspace, tokData.tempDir)
 	if err != nil {
@@ -298,6 +507,12 @@ func (r *RestoreAppspace) newToken() string {
 	return tok
 }
 
+// Delete removes the token and its prepared files.
+// It does nothing if the token is not found.
+func (r *RestoreAppspace) Delete(tok string) {
+	r.delete(tok)
+}
+
 func (r *RestoreAppspace) delete(tok string) (err error) {
 	r.tokensMux.Lock()
 	defer r.tokensMux.Unlock()
@@ -0,0 +1,458 @@
+package appspaceops
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+)
+
+// Appspace transfer, sending side:
+// - open a session with the receiving host, each side proving it knows the ticket secret
+// - pause the appspace and back it up, and write a transfer bundle
+// - declare the bundle's hash in a new session, so the receiver keeps any data it has of it
+// - upload the bundle in chunks, resuming from the receiver's offset after failures
+// - ask the receiver to import and wait for the result
+// - on success the appspace is paused for good, and optionally redirects to its new address.
+// If anything fails the appspace is unpaused and left as it was.
+
+// transferChunkSize is the size of each upload request
+var transferChunkSize int64 = 8 << 20
+
+// transferRetries is the number of consecutive failed requests tolerated
+var transferRetries = 8
+
+// transferRetryDelay is the base delay between retries. It doubles with each failure.
+var transferRetryDelay = 2 * time.Second
+
+// transferImportTimeout is how long we wait for the receiver to import the bundle
+var transferImportTimeout = 30 * time.Minute
+
+const (
+	transferStepConnect = "connect"
+	transferStepBackup  = "backup"
+	transferStepUpload  = "upload"
+	transferStepImport  = "import"
+	transferStepDone    = "done"
+	transferStepFailed  = "failed"
+)
+
+// TransferAppspace pushes an appspace to another host
+type TransferAppspace struct {
+	AppspaceModel interface {
+		SetRedirect(appspaceID domain.AppspaceID, redirect string) error
+	} `checkinject:"required"`
+	AppspaceStatus interface {
+		WaitTempPaused(appspaceID domain.AppspaceID, reason string) chan struct{}
+	} `checkinject:"required"`
+	BackupAppspace interface {
+		BackupNoPause(appspaceID domain.AppspaceID) (string, error)
+	} `checkinject:"required"`
+	ExportAppspace interface {
+		WriteTransferBundle(appspaceID domain.AppspaceID, backupFile string, w io.Writer) error
+	} `checkinject:"required"`
+	PauseAppspace interface {
+		Pause(appspaceID domain.AppspaceID, pause bool) error
+	} `checkinject:"required"`
+	AppspaceLogger interface {
+		Log(appspaceID domain.AppspaceID, source string, message string)
+	} `checkinject:"required"`
+	DS2DS interface {
+		GetClient() *http.Client
+	} `checkinject:"required"`
+
+	transfersMux sync.Mutex
+	transfers    map[domain.AppspaceID]domain.AppspaceTransferStatus
+}
+
+func (t *TransferAppspace) Init() {
+	t.transfers = make(map[domain.AppspaceID]domain.AppspaceTransferStatus)
+}
+
+// Start begins the transfer of the appspace to the host that issued the code.
+// The transfer runs in the background, use GetStatus to follow it.
+func (t *TransferAppspace) Start(appspaceID domain.AppspaceID, code string, redirect bool) error {
+	baseURL, secret, err := parseTransferCode(code)
+	if err != nil {
+		return err
+	}
+
+	t.transfersMux.Lock()
+	defer t.transfersMux.Unlock()
+	cur, ok := t.transfers[appspaceID]
+	if ok && cur.Step != transferStepDone && cur.Step != transferStepFailed {
+		return errors.New("bad input: a transfer is already in progress")
+	}
+	t.transfers[appspaceID] = domain.AppspaceTransferStatus{
+		AppspaceID: appspaceID,
+		Step:       transferStepConnect,
+		Redirect:   redirect,
+		Started:    time.Now()}
+
+	go t.run(appspaceID, baseURL, secret, redirect)
+
+	return nil
+}
+
+// GetStatus returns the status of the latest transfer of the appspace
+func (t *TransferAppspace) GetStatus(appspaceID domain.AppspaceID) (domain.AppspaceTransferStatus, bool) {
+	t.transfersMux.Lock()
+	defer t.transfersMux.Unlock()
+	s, ok := t.transfers[appspaceID]
+	return s, ok
+}
+
+func (t *TransferAppspace) update(appspaceID domain.AppspaceID, f func(*domain.AppspaceTransferStatus)) {
+	t.transfersMux.Lock()
+	defer t.transfersMux.Unlock()
+	s := t.transfers[appspaceID]
+	f(&s)
+	t.transfers[appspaceID] = s
+}
+
+func (t *TransferAppspace) setStep(appspaceID domain.AppspaceID, step string) {
+	t.update(appspaceID, func(s *domain.AppspaceTransferStatus) {
+		s.Step = step
+	})
+}
+
+func (t *TransferAppspace) run(appspaceID domain.AppspaceID, baseURL string, secret []byte, redirect bool) {
+	appspaceURL, err := t.transfer(appspaceID, baseURL, secret, redirect)
+	if err != nil {
+		t.getLogger("run").AppspaceID(appspaceID).Error(err)
+		t.AppspaceLogger.Log(appspaceID, "ds-host", "appspace transfer failed: "+err.Error())
+		t.update(appspaceID, func(s *domain.AppspaceTransferStatus) {
+			s.Step = transferStepFailed
+			s.Error = err.Error()
+			s.Finished = time.Now()
+		})
+		return
+	}
+	t.AppspaceLogger.Log(appspaceID, "ds-host", "appspace transferred to "+appspaceURL)
+	t.update(appspaceID, func(s *domain.AppspaceTransferStatus) {
+		s.Step = transferStepDone
+		s.AppspaceURL = appspaceURL
+		s.Finished = time.Now()
+	})
+}
+
+func (t *TransferAppspace) transfer(appspaceID domain.AppspaceID, baseURL string, secret []byte, redirect bool) (string, error) {
+	c := &transferClient{
+		client:  t.DS2DS.GetClient(),
+		baseURL: baseURL,
+		secret:  secret}
+	// Check the receiver before pausing the appspace.
+	err := c.hello("")
+	if err != nil {
+		return "", err
+	}
+
+	t.setStep(appspaceID, transferStepBackup)
+	t.AppspaceLogger.Log(appspaceID, "ds-host", "pausing appspace for transfer")
+	pauseCh := t.AppspaceStatus.WaitTempPaused(appspaceID, "transfer")
+	defer close(pauseCh)
+
+	backupFile, err := t.BackupAppspace.BackupNoPause(appspaceID)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(os.TempDir(), "ds-transfer-*.zip")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	h := sha256.New()
+	err = t.ExportAppspace.WriteTransferBundle(appspaceID, backupFile, io.MultiWriter(f, h))
+	if err != nil {
+		return "", err
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+
+	bundleHash := hex.EncodeToString(h.Sum(nil))
+	err = c.hello(bundleHash)
+	if err != nil {
+		return "", err
+	}
+
+	t.update(appspaceID, func(s *domain.AppspaceTransferStatus) {
+		s.Step = transferStepUpload
+		s.BytesTotal = size
+	})
+	err = c.upload(f, size, func(sent int64) {
+		t.update(appspaceID, func(s *domain.AppspaceTransferStatus) {
+			s.BytesSent = sent
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	t.setStep(appspaceID, transferStepImport)
+	appspaceURL, err := c.importBundle(domain.V0TransferImport{
+		Size:   size,
+		SHA256: bundleHash})
+	if err != nil {
+		return "", err
+	}
+
+	// The appspace lives on the other host now.
+	// Pause it for good before the temp pause is released.
+	err = t.PauseAppspace.Pause(appspaceID, true)
+	if err != nil {
+		return "", err
+	}
+	if redirect {
+		err = t.AppspaceModel.SetRedirect(appspaceID, appspaceURL)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return appspaceURL, nil
+}
+
+func (t *TransferAppspace) getLogger(note string) *record.DsLogger {
+	r := record.NewDsLogger().AddNote("TransferAppspace")
+	if note != "" {
+		r.AddNote(note)
+	}
+	return r
+}
+
+// transferClient makes the ds2ds requests of a transfer
+type transferClient struct {
+	client     *http.Client
+	baseURL    string
+	secret     []byte
+	session    string
+	bundleHash string
+}
+
+// errTransferSession is returned when the receiver no longer accepts the session
+var errTransferSession = errors.New("transfer session rejected by remote")
+
+// hello opens the session and checks that the receiver knows the secret.
+// bundleHash declares the bundle to upload. The receiver keeps
+// the data it already has if it matches a previous session's bundle.
+func (c *transferClient) hello(bundleHash string) error {
+	nonce, err := randomHex(32)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(domain.V0TransferHello{
+		Nonce:  nonce,
+		Proof:  transferMAC(c.secret, "sender", nonce, bundleHash),
+		SHA256: bundleHash})
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Post(c.baseURL+"/hello", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("transfer hello: remote returned %v", resp.Status)
+	}
+	var helloResp domain.V0TransferHelloResp
+	err = json.NewDecoder(resp.Body).Decode(&helloResp)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(helloResp.Proof), []byte(transferMAC(c.secret, "target", nonce, helloResp.Nonce))) {
+		return errors.New("transfer hello: remote host failed to prove it issued the code")
+	}
+	c.session = transferMAC(c.secret, "session", nonce, helloResp.Nonce)
+	c.bundleHash = bundleHash
+	return nil
+}
+
+func (c *transferClient) do(method, path string, body io.Reader, headers map[string]string) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.session)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{resp.Body, cancel}
+	return resp, nil
+}
+
+// getOffset asks the receiver how much of the bundle it has
+func (c *transferClient) getOffset() (int64, error) {
+	resp, err := c.do(http.MethodHead, "/bundle", nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		return 0, errTransferSession
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("transfer offset: remote returned %v", resp.Status)
+	}
+	return strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+}
+
+// upload sends the file in chunks. After a failure it asks the receiver
+// for its offset and resumes from there. If the session was lost
+// it opens a new one for the same bundle and resumes the same way.
+func (c *transferClient) upload(f *os.File, size int64, progress func(int64)) error {
+	failures := 0
+	var lastErr error
+	for {
+		if failures > transferRetries {
+			return fmt.Errorf("transfer upload: too many failures: %w", lastErr)
+		}
+		if failures > 0 {
+			time.Sleep(transferRetryDelay * time.Duration(1<<(failures-1)))
+		}
+		offset, err := c.getOffset()
+		if err == errTransferSession {
+			err = c.hello(c.bundleHash)
+			if err == nil {
+				offset, err = c.getOffset()
+			}
+		}
+		if err != nil {
+			failures++
+			lastErr = err
+			continue
+		}
+		progress(offset)
+		if offset == size {
+			return nil
+		}
+		if offset > size {
+			return errors.New("transfer upload: remote has more data than the bundle")
+		}
+		n := transferChunkSize
+		if size-offset < n {
+			n = size - offset
+		}
+		resp, err := c.do(http.MethodPatch, "/bundle", io.NewSectionReader(f, offset, n), map[string]string{
+			"Upload-Offset": strconv.FormatInt(offset, 10),
+			"Content-Type":  "application/octet-stream"})
+		if err != nil {
+			failures++
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			failures++
+			lastErr = fmt.Errorf("remote returned %v", resp.Status)
+			continue
+		}
+		failures = 0
+	}
+}
+
+// importBundle asks the receiver to import the bundle
+// and waits for the result. It returns the address of the new appspace.
+func (c *transferClient) importBundle(data domain.V0TransferImport) (string, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.do(http.MethodPost, "/import", bytes.NewReader(body), map[string]string{"Content-Type": "application/json"})
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("transfer import: remote returned %v", resp.Status)
+	}
+
+	deadline := time.Now().Add(transferImportTimeout)
+	failures := 0
+	for time.Now().Before(deadline) {
+		time.Sleep(transferRetryDelay)
+		status, err := c.getStatus()
+		if err != nil {
+			failures++
+			if failures > transferRetries {
+				return "", err
+			}
+			continue
+		}
+		failures = 0
+		switch status.State {
+		case transferDone:
+			return status.AppspaceURL, nil
+		case transferFailed:
+			return "", errors.New("remote failed to import appspace: " + status.Error)
+		}
+	}
+	return "", errors.New("transfer import: timed out waiting for remote")
+}
+
+func (c *transferClient) getStatus() (domain.V0TransferStatus, error) {
+	var status domain.V0TransferStatus
+	resp, err := c.do(http.MethodGet, "/status", nil, nil)
+	if err != nil {
+		return status, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return status, fmt.Errorf("transfer status: remote returned %v", resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&status)
+	return status, err
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// transferMAC is how both hosts prove they know the ticket secret
+// without sending it. The sender and the receiver each contribute a nonce,
+// and the session token is derived from the secret and both nonces.
+func transferMAC(secret []byte, label string, senderNonce string, receiverNonce string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(label + "|" + senderNonce + "|" + receiverNonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,357 @@
+package appspaceops
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+)
+
+func TestParseTransferCode(t *testing.T) {
+	secret := strings.Repeat("ab", 32)
+	base, s, err := parseTransferCode("https://dash.example.com:5050/.dropserver/v0/transfer/abc#" + secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base != "https://dash.example.com:5050/.dropserver/v0/transfer/abc" {
+		t.Errorf("unexpected base url: %v", base)
+	}
+	if len(s) != 32 || s[0] != 0xab {
+		t.Errorf("unexpected secret: %v", s)
+	}
+
+	bad := []string{
+		"",
+		"dash.example.com/abc#" + secret,
+		"ftp://dash.example.com/abc#" + secret,
+		"https://dash.example.com/abc",
+		"https://dash.example.com/abc#abcd",
+	}
+	for _, c := range bad {
+		_, _, err = parseTransferCode(c)
+		if err == nil || !strings.HasPrefix(err.Error(), "bad input:") {
+			t.Errorf("expected bad input error for %v, got %v", c, err)
+		}
+	}
+}
+
+func TestTransferHelloWrongSecret(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	r, ticket := makeTestReceiver(mockCtrl, nil)
+	defer r.DeleteAll()
+	server := httptest.NewServer(testTransferHandler(r, 0))
+	defer server.Close()
+
+	c := &transferClient{
+		client:  server.Client(),
+		baseURL: server.URL + "/transfer/" + ticket.TicketID,
+		secret:  bytes.Repeat([]byte{1}, 32)}
+	err := c.hello("")
+	if err == nil {
+		t.Error("expected an error with the wrong secret")
+	}
+	if r.Authorize(ticket.TicketID, c.session) {
+		t.Error("session should not be authorized")
+	}
+}
+
+func TestTransferHelloResume(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	r, ticket := makeTestReceiver(mockCtrl, nil)
+	defer r.DeleteAll()
+	_, secret, err := parseTransferCode(ticket.Code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := strings.Repeat("ab", 32)
+	nonce := strings.Repeat("cd", 16)
+	hello := domain.V0TransferHello{
+		Nonce:  nonce,
+		Proof:  transferMAC(secret, "sender", nonce, hash),
+		SHA256: hash}
+	_, err = r.Hello(ticket.TicketID, hello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.WriteChunk(ticket.TicketID, 0, bytes.NewReader(make([]byte, 500)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a replayed or unauthenticated hello changes nothing
+	_, err = r.Hello(ticket.TicketID, hello)
+	if err != domain.ErrBadAuth {
+		t.Errorf("expected ErrBadAuth for replayed hello, got %v", err)
+	}
+	_, err = r.Hello(ticket.TicketID, domain.V0TransferHello{Nonce: strings.Repeat("ef", 16), SHA256: hash})
+	if err != domain.ErrBadAuth {
+		t.Errorf("expected ErrBadAuth without proof, got %v", err)
+	}
+	offset, _ := r.GetOffset(ticket.TicketID)
+	if offset != 500 {
+		t.Errorf("expected offset to be kept, got %v", offset)
+	}
+
+	// a new session for the same bundle resumes
+	nonce = strings.Repeat("12", 16)
+	_, err = r.Hello(ticket.TicketID, domain.V0TransferHello{
+		Nonce:  nonce,
+		Proof:  transferMAC(secret, "sender", nonce, hash),
+		SHA256: hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset, _ = r.GetOffset(ticket.TicketID)
+	if offset != 500 {
+		t.Errorf("expected offset to be kept for the same bundle, got %v", offset)
+	}
+
+	// a different bundle starts over
+	hash = strings.Repeat("cd", 32)
+	nonce = strings.Repeat("34", 16)
+	_, err = r.Hello(ticket.TicketID, domain.V0TransferHello{
+		Nonce:  nonce,
+		Proof:  transferMAC(secret, "sender", nonce, hash),
+		SHA256: hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset, _ = r.GetOffset(ticket.TicketID)
+	if offset != 0 {
+		t.Errorf("expected offset to be reset for a different bundle, got %v", offset)
+	}
+}
+
+func TestTransferChunkInProgress(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	r, ticket := makeTestReceiver(mockCtrl, nil)
+	defer r.DeleteAll()
+	_, secret, err := parseTransferCode(ticket.Code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := strings.Repeat("ab", 32)
+	nonce := strings.Repeat("cd", 16)
+	_, err = r.Hello(ticket.TicketID, domain.V0TransferHello{
+		Nonce:  nonce,
+		Proof:  transferMAC(secret, "sender", nonce, hash),
+		SHA256: hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan int64)
+	go func() {
+		offset, _ := r.WriteChunk(ticket.TicketID, 0, pr)
+		done <- offset
+	}()
+	pw.Write(make([]byte, 100))
+
+	// a stalled upload does not block the ticket
+	offset, err := r.GetOffset(ticket.TicketID)
+	if err != nil || offset != 0 {
+		t.Errorf("expected offset 0 during upload, got %v %v", offset, err)
+	}
+	_, err = r.WriteChunk(ticket.TicketID, 0, bytes.NewReader(make([]byte, 10)))
+	if err == nil {
+		t.Error("expected error for concurrent chunk")
+	}
+
+	pw.Write(make([]byte, 100))
+	pw.Close()
+	offset = <-done
+	if offset != 200 {
+		t.Errorf("expected offset 200, got %v", offset)
+	}
+}
+
+func TestTransferAppspace(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	origChunk, origDelay := transferChunkSize, transferRetryDelay
+	transferChunkSize, transferRetryDelay = 1000, time.Millisecond
+	defer func() { transferChunkSize, transferRetryDelay = origChunk, origDelay }()
+
+	bundle := make([]byte, 2500)
+	rand.Read(bundle)
+
+	appspaceID := domain.AppspaceID(7)
+	newAppspaceID := domain.AppspaceID(44)
+
+	importAppspace := testmocks.NewMockImportAppspace(mockCtrl)
+	importAppspace.EXPECT().Prepare(domain.UserID(11), gomock.Any()).DoAndReturn(func(userID domain.UserID, reader io.Reader) (string, domain.AppspaceBundleMeta, error) {
+		data, _ := io.ReadAll(reader)
+		if !bytes.Equal(data, bundle) {
+			t.Error("received bundle is different")
+		}
+		return "tok", domain.AppspaceBundleMeta{}, nil
+	})
+	importAppspace.EXPECT().Import("tok", gomock.Any(), "example.com", "as", "").Return(newAppspaceID, nil)
+
+	r, ticket := makeTestReceiver(mockCtrl, importAppspace)
+	defer r.DeleteAll()
+	// the first chunk upload fails partway through
+	server := httptest.NewServer(testTransferHandler(r, 1))
+	defer server.Close()
+
+	pauseCh := make(chan struct{})
+	appspaceStatus := testmocks.NewMockAppspaceStatus(mockCtrl)
+	appspaceStatus.EXPECT().WaitTempPaused(appspaceID, "transfer").Return(pauseCh)
+	backupAppspace := testmocks.NewMockBackupAppspace(mockCtrl)
+	backupAppspace.EXPECT().BackupNoPause(appspaceID).Return("b.snapshot", nil)
+	exportAppspace := testmocks.NewMockExportAppspace(mockCtrl)
+	exportAppspace.EXPECT().WriteTransferBundle(appspaceID, "b.snapshot", gomock.Any()).DoAndReturn(func(_ domain.AppspaceID, _ string, w io.Writer) error {
+		_, err := w.Write(bundle)
+		return err
+	})
+	pauseAppspace := testmocks.NewMockPauseAppspace(mockCtrl)
+	pauseAppspace.EXPECT().Pause(appspaceID, true).Return(nil)
+	appspaceModel := testmocks.NewMockAppspaceModel(mockCtrl)
+	appspaceModel.EXPECT().SetRedirect(appspaceID, "https://as.example.com").Return(nil)
+	appspaceLogger := testmocks.NewMockAppspaceLogger(mockCtrl)
+	appspaceLogger.EXPECT().Log(appspaceID, "ds-host", gomock.Any()).AnyTimes()
+	ds2ds := testmocks.NewMockDS2DS(mockCtrl)
+	ds2ds.EXPECT().GetClient().Return(server.Client())
+
+	ta := &TransferAppspace{
+		AppspaceModel:  appspaceModel,
+		AppspaceStatus: appspaceStatus,
+		BackupAppspace: backupAppspace,
+		ExportAppspace: exportAppspace,
+		PauseAppspace:  pauseAppspace,
+		AppspaceLogger: appspaceLogger,
+		DS2DS:          ds2ds,
+	}
+	ta.Init()
+
+	code := strings.Replace(ticket.Code, "https://dash.example.com/.dropserver/v0", server.URL, 1)
+	err := ta.Start(appspaceID, code, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var status domain.AppspaceTransferStatus
+	for i := 0; i < 500; i++ {
+		status, _ = ta.GetStatus(appspaceID)
+		if status.Step == transferStepDone || status.Step == transferStepFailed {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status.Step != transferStepDone {
+		t.Fatalf("expected transfer to be done: %v", status)
+	}
+	if status.BytesSent != 2500 || status.BytesTotal != 2500 {
+		t.Errorf("unexpected byte counts: %v", status)
+	}
+	if status.AppspaceURL != "https://as.example.com" {
+		t.Errorf("unexpected appspace url: %v", status.AppspaceURL)
+	}
+	select {
+	case <-pauseCh:
+	default:
+		t.Error("expected temp pause to be released")
+	}
+
+	tickets := r.GetTickets(domain.UserID(11))
+	if len(tickets) != 1 || tickets[0].State != transferDone || tickets[0].AppspaceID != newAppspaceID {
+		t.Errorf("unexpected tickets: %v", tickets)
+	}
+}
+
+func makeTestReceiver(mockCtrl *gomock.Controller, importAppspace *testmocks.MockImportAppspace) (*ReceiveTransfer, domain.AppspaceTransferTicket) {
+	cfg := &domain.RuntimeConfig{}
+	cfg.ExternalAccess.Scheme = "https"
+	cfg.Exec.UserRoutesDomain = "dash.example.com"
+
+	dropID := domain.DropID{UserID: domain.UserID(11), Handle: "alice", Domain: "example.com"}
+	domainController := testmocks.NewMockDomainController(mockCtrl)
+	domainController.EXPECT().CheckAppspaceDomain(dropID.UserID, "example.com", "as").Return(domain.DomainCheckResult{Valid: true, Available: true}, nil)
+
+	r := &ReceiveTransfer{
+		Config:           cfg,
+		DomainController: domainController,
+		ImportAppspace:   importAppspace,
+	}
+	r.Init()
+	ticket, err := r.CreateTicket(dropID, "example.com", "as")
+	if err != nil {
+		panic(err)
+	}
+	return r, ticket
+}
+
+// testTransferHandler stands in for the ds2ds routes of the receiving host.
+// The first failChunks chunk uploads are cut off halfway.
+func testTransferHandler(r *ReceiveTransfer, failChunks int) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /transfer/{ticket}/hello", func(w http.ResponseWriter, req *http.Request) {
+		var hello domain.V0TransferHello
+		json.NewDecoder(req.Body).Decode(&hello)
+		resp, err := r.Hello(req.PathValue("ticket"), hello)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+	auth := func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			if !r.Authorize(req.PathValue("ticket"), strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")) {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			f(w, req)
+		}
+	}
+	mux.HandleFunc("HEAD /transfer/{ticket}/bundle", auth(func(w http.ResponseWriter, req *http.Request) {
+		offset, _ := r.GetOffset(req.PathValue("ticket"))
+		w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	}))
+	mux.HandleFunc("PATCH /transfer/{ticket}/bundle", auth(func(w http.ResponseWriter, req *http.Request) {
+		offset, _ := strconv.ParseInt(req.Header.Get("Upload-Offset"), 10, 64)
+		var body io.Reader = req.Body
+		fail := failChunks > 0
+		if fail {
+			failChunks--
+			body = io.LimitReader(req.Body, 500)
+		}
+		_, err := r.WriteChunk(req.PathValue("ticket"), offset, body)
+		if err != nil || fail {
+			http.Error(w, "failed", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	mux.HandleFunc("POST /transfer/{ticket}/import", auth(func(w http.ResponseWriter, req *http.Request) {
+		var data domain.V0TransferImport
+		json.NewDecoder(req.Body).Decode(&data)
+		err := r.Import(req.PathValue("ticket"), data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	mux.HandleFunc("GET /transfer/{ticket}/status", auth(func(w http.ResponseWriter, req *http.Request) {
+		status, _ := r.GetStatus(req.PathValue("ticket"))
+		json.NewEncoder(w).Encode(status)
+	}))
+	return mux
+}
@@ -2,11 +2,14 @@ package appspacerouter
 
 import (
 	"errors"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
@@ -37,10 +40,16 @@ type AppspaceRouter struct {
 	AppRoutes interface {
 		Match(appID domain.AppID, version domain.Version, method string, reqPath string) (domain.AppRoute, error)
 	} `checkinject:"required"`
-	SandboxProxy   http.Handler `checkinject:"required"` // versioned?
+	SandboxProxy  http.Handler `checkinject:"required"` // versioned?
+	ResponseCache interface {
+		Serve(w http.ResponseWriter, r *http.Request, next http.Handler)
+	} `checkinject:"optional"`
 	RouteHitEvents interface {
 		Send(*domain.AppspaceRouteHitEvent)
 	} `checkinject:"optional"`
+	Metrics interface {
+		AppspaceRequest(appspaceID domain.AppspaceID, status int, dur time.Duration)
+	} `checkinject:"optional"`
 	AppLocation2Path interface {
 		Files(string) string
 	} `checkinject:"required"`
@@ -63,7 +72,9 @@ func (a *AppspaceRouter) Init() {
 }
 
 func (a *AppspaceRouter) BuildRoutes(mux *chi.Mux) {
+	mux.Use(a.recordMetrics)
 	mux.Use(a.errorPage)
+	mux.Use(a.redirectTransferred)
 	mux.Use(a.appspaceAvailable, a.countRequest)
 	mux.Use(a.loadApp)
 	mux.Mount("/.dropserver", a.DropserverRoutes.Router())
@@ -74,6 +85,44 @@ func (a *AppspaceRouter) BuildRoutes(mux *chi.Mux) {
 	})
 }
 
+// recordMetrics times each request and records its status
+func (a *AppspaceRouter) recordMetrics(next http.Handler) http.Handler {
+	if a.Metrics == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		appspace, ok := domain.CtxAppspaceData(r.Context())
+		if !ok {
+			panic("recordMetrics: expected appspace to exist on request context")
+		}
+		start := time.Now()
+		statusW := statusRecorder{w, 0}
+		next.ServeHTTP(&statusW, r)
+		status := statusW.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		a.Metrics.AppspaceRequest(appspace.AppspaceID, status, time.Since(start))
+	})
+}
+
+// redirectTransferred sends visitors to the new address
+// of an appspace that was transferred to another host.
+// ds2ds routes are left alone.
+func (a *AppspaceRouter) redirectTransferred(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		appspace, ok := domain.CtxAppspaceData(r.Context())
+		if !ok {
+			panic("redirectTransferred: expected appspace to exist on request context")
+		}
+		if appspace.Redirect == "" || strings.HasPrefix(r.URL.Path, "/.dropserver/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		http.Redirect(w, r, strings.TrimSuffix(appspace.Redirect, "/")+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+	})
+}
+
 func (a *AppspaceRouter) appspaceAvailable(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		appspace, ok := domain.CtxAppspaceData(r.Context())
@@ -109,7 +158,7 @@ func (a *AppspaceRouter) loadApp(next http.Handler) http.Handler {
 
 		app, err := a.AppModel.GetFromID(appspace.AppID)
 		if err != nil { // do we differentiate between empty result vs other errors? -> No, if any kind of DB error occurs, the DB or model will log it.
-			a.getLogger(appspace.AppspaceID).AddNote("AppModel.GetFromID").Error(err)
+			a.getLogger(appspace.AppspaceID).Ctx(r.Context()).AddNote("AppModel.GetFromID").Error(err)
 			http.Error(w, "internal server error", http.StatusInternalServerError)
 			return
 		}
@@ -117,7 +166,7 @@ func (a *AppspaceRouter) loadApp(next http.Handler) http.Handler {
 
 		appVersion, err := a.AppModel.GetVersion(appspace.AppID, appspace.AppVersion)
 		if err != nil {
-			a.getLogger(appspace.AppspaceID).AddNote("AppModel.GetVersion").Error(err)
+			a.getLogger(appspace.AppspaceID).Ctx(r.Context()).AddNote("AppModel.GetVersion").Error(err)
 			http.Error(w, "App Version not found", http.StatusInternalServerError)
 			return
 		}
@@ -385,26 +434,105 @@ func (a *AppspaceRouter) authorizeRoute(next http.Handler) http.Handler {
 // ServeHTTP handles http traffic to the appspace
 func (a *AppspaceRouter) handleRoute(w http.ResponseWriter, r *http.Request) {
 	routeConfig, _ := domain.CtxRouteConfig(r.Context())
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+	a.getLogger(appspace.AppspaceID).Ctx(r.Context()).Debug(r.Method + " " + r.URL.Path + " route: " + routeConfig.ID + " " + routeConfig.Type)
 	switch routeConfig.Type {
 	case "function":
-		a.SandboxProxy.ServeHTTP(w, r)
+		if routeConfig.Options.Cache && a.ResponseCache != nil {
+			a.ResponseCache.Serve(w, r, a.SandboxProxy)
+		} else {
+			a.SandboxProxy.ServeHTTP(w, r)
+		}
 	case "static":
 		a.serveFile(w, r)
+	case "redirect":
+		a.redirect(w, r)
+	case "proxy":
+		a.proxyRoute(w, r)
 	default:
-		appspace, _ := domain.CtxAppspaceData(r.Context())
-		a.getLogger(appspace.AppspaceID).Log("route type not implemented: " + routeConfig.Type)
+		a.getLogger(appspace.AppspaceID).Ctx(r.Context()).Log("route type not implemented: " + routeConfig.Type)
 		http.Error(w, "route type not implemented", http.StatusInternalServerError)
 	}
 }
 
+// redirect sends the client to the route's target
+func (a *AppspaceRouter) redirect(w http.ResponseWriter, r *http.Request) {
+	routeConfig, _ := domain.CtxRouteConfig(r.Context())
+	tail := (&url.URL{Path: routeTail(r, routeConfig)}).EscapedPath()
+	target := joinTarget(routeConfig.Options.Target, tail)
+	if strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		// a protocol-relative target would send the client to another host
+		http.Error(w, "bad redirect target", http.StatusBadRequest)
+		return
+	}
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	status := routeConfig.Options.Status
+	if status == 0 {
+		status = http.StatusFound
+	}
+	http.Redirect(w, r, target, status)
+}
+
+// proxyRoute handles the request as if it was made to the route's target path.
+// The target route is authorized separately, and can not be another proxy route.
+func (a *AppspaceRouter) proxyRoute(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	appspace, _ := domain.CtxAppspaceData(ctx)
+	routeConfig, _ := domain.CtxRouteConfig(ctx)
+	targetPath := joinTarget(routeConfig.Options.Target, routeTail(r, routeConfig))
+
+	route, err := a.AppRoutes.Match(appspace.AppID, appspace.AppVersion, r.Method, targetPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if route == (domain.AppRoute{}) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if route.Type == "proxy" {
+		a.getLogger(appspace.AppspaceID).Ctx(ctx).Log("proxy route target is another proxy route: " + route.ID)
+		http.Error(w, "proxy route loop", http.StatusInternalServerError)
+		return
+	}
+
+	r2 := r.Clone(domain.CtxWithRouteConfig(ctx, route))
+	r2.URL.Path = targetPath
+	r2.URL.RawPath = ""
+	a.authorizeRoute(http.HandlerFunc(a.handleRoute)).ServeHTTP(w, r2)
+}
+
+// routeTail returns the part of the request path
+// that is beyond the path of a wildcard route
+func routeTail(r *http.Request, routeConfig domain.AppRoute) string {
+	if routeConfig.Path.End {
+		return ""
+	}
+	return strings.TrimPrefix(r.URL.Path, strings.TrimSuffix(routeConfig.Path.Path, "/"))
+}
+
+// joinTarget appends the tail to the target path.
+// All leading slashes are removed from the tail so that it can never
+// turn a relative target into a protocol-relative URL.
+func joinTarget(target, tail string) string {
+	if tail == "" || tail == "/" && strings.HasSuffix(target, "/") {
+		return target
+	}
+	return strings.TrimSuffix(target, "/") + "/" + strings.TrimLeft(tail, "/")
+}
+
 // serveFile serves a file based on route config and the path of the request
 // Possible scenarios:
 // - config points to file -> serve that file
 // - config points to dir:
 //   - join request wildcard path (if config request is wildcard)
 //   - if this points to file, serve file
-//   - if dir, look for index.html? or ...?
+//   - if dir, serve the index file or a dir listing if enabled
 func (a *AppspaceRouter) serveFile(w http.ResponseWriter, r *http.Request) {
+	routeConfig, _ := domain.CtxRouteConfig(r.Context())
+	opts := routeConfig.Options
 	p, err := a.getConfigPath(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -418,7 +546,7 @@ func (a *AppspaceRouter) serveFile(w http.ResponseWriter, r *http.Request) {
 
 	// If the config points to a file, then always just serve that.
 	if !fileinfo.IsDir() {
-		serveFile(w, r, p)
+		serveFile(w, r, p, opts)
 		return
 	}
 
@@ -434,14 +562,31 @@ func (a *AppspaceRouter) serveFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//....
 	if fileinfo.IsDir() {
-		// either append index.html to p or send dir listing
+		// relative links in index files and listings need the trailing slash
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			target := r.URL.Path + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+		indexPath := filepath.Join(p, indexFile(opts))
+		indexInfo, err := os.Stat(indexPath)
+		if err == nil && !indexInfo.IsDir() {
+			serveFile(w, r, indexPath, opts)
+			return
+		}
+		if opts.DirListing {
+			serveDirListing(w, r, p, opts)
+			return
+		}
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
-	serveFile(w, r, p)
+	serveFile(w, r, p, opts)
 }
 
 // getConfigPath returns the actual path that the route config options intends to serve
@@ -474,7 +619,7 @@ func (a *AppspaceRouter) getConfigPath(r *http.Request) (string, error) {
 		root = a.AppLocation2Path.Files(appVersion.LocationKey)
 	} else {
 		appspace, _ := domain.CtxAppspaceData(r.Context())
-		a.getLogger(appspace.AppspaceID).Log("getFilePath() Path prefix not recognized: " + p) // This should be logged to appspace log, not general log
+		a.getLogger(appspace.AppspaceID).Ctx(r.Context()).Log("getFilePath() Path prefix not recognized: " + p) // This should be logged to appspace log, not general log
 		return "", errors.New("path prefix not recognized")
 	}
 
@@ -482,7 +627,7 @@ func (a *AppspaceRouter) getConfigPath(r *http.Request) (string, error) {
 	configPath := filepath.Join(root, filepath.FromSlash(p))
 	if !strings.HasPrefix(configPath, root) {
 		appspace, _ := domain.CtxAppspaceData(r.Context())
-		a.getLogger(appspace.AppspaceID).Log("getFilePath() route config path out of bounds: " + root)
+		a.getLogger(appspace.AppspaceID).Ctx(r.Context()).Log("getFilePath() route config path out of bounds: " + root)
 		return "", errors.New("route config path out of bounds")
 	}
 
@@ -507,8 +652,10 @@ func (a *AppspaceRouter) joinBaseToRequest(basePath string, r *http.Request) (st
 	return joinedPath, nil
 }
 
-func serveFile(w http.ResponseWriter, r *http.Request, p string) {
-	f, err := os.Open(p)
+func serveFile(w http.ResponseWriter, r *http.Request, p string, opts domain.AppRouteOptions) {
+	servePath, encoding := precompressedSibling(w, r, p)
+
+	f, err := os.Open(servePath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			w.WriteHeader(http.StatusNotFound)
@@ -517,10 +664,28 @@ func serveFile(w http.ResponseWriter, r *http.Request, p string) {
 		http.Error(w, "file open error", http.StatusInternalServerError)
 		return
 	}
+	defer f.Close()
 
 	fileinfo, err := f.Stat()
 	if err != nil {
 		http.Error(w, "file stat error", http.StatusInternalServerError)
+		return
+	}
+
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+		// Content-Type is based on the original file, not the compressed one
+		ctype := mime.TypeByExtension(filepath.Ext(p))
+		if ctype == "" {
+			ctype = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", ctype)
+	}
+	if opts.ETag {
+		w.Header().Set("ETag", fileETag(fileinfo, encoding))
+	}
+	if opts.CacheControl != "" {
+		w.Header().Set("Cache-Control", opts.CacheControl)
 	}
 
 	http.ServeContent(w, r, p, fileinfo.ModTime(), f)
@@ -538,6 +703,12 @@ func (rec *statusRecorder) WriteHeader(code int) {
 	rec.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer
+// so that the sandbox proxy can flush and hijack connections.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
 func (a *AppspaceRouter) getLogger(appspaceID domain.AppspaceID) *record.DsLogger {
 	return record.NewDsLogger().AppspaceID(appspaceID).AddNote("AppspaceRouter")
 }
@@ -5,7 +5,9 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
@@ -84,6 +86,86 @@ func TestAppspaceAvailable(t *testing.T) {
 
 // Somehow we can't find the app referred to by appspace.
 // server 500 and log error
+func TestRedirectTransferred(t *testing.T) {
+	appspaceRouter := &AppspaceRouter{}
+
+	cases := []struct {
+		redirect string
+		path     string
+		location string
+	}{
+		{"", "/abc", ""},
+		{"https://new.example.com", "/abc?d=e", "https://new.example.com/abc?d=e"},
+		{"https://new.example.com/", "/", "https://new.example.com/"},
+		{"https://new.example.com", "/.dropserver/v0/logout", ""},
+	}
+
+	for _, c := range cases {
+		nextCalled := false
+		handler := appspaceRouter.redirectTransferred(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			nextCalled = true
+		}))
+
+		req, err := http.NewRequest("GET", c.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = req.WithContext(domain.CtxWithAppspaceData(req.Context(), domain.Appspace{AppspaceID: domain.AppspaceID(7), Redirect: c.redirect}))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if c.location == "" {
+			if !nextCalled {
+				t.Errorf("%v: expected next to be called", c.path)
+			}
+			continue
+		}
+		if nextCalled {
+			t.Errorf("%v: next got called when it should not have", c.path)
+		}
+		if rr.Code != http.StatusTemporaryRedirect {
+			t.Errorf("%v: expected redirect, got %d", c.path, rr.Code)
+		}
+		if loc := rr.Header().Get("Location"); loc != c.location {
+			t.Errorf("%v: unexpected location: %v", c.path, loc)
+		}
+	}
+}
+
+func TestRecordMetrics(t *testing.T) {
+	metrics := &testMetrics{}
+	appspaceRouter := &AppspaceRouter{Metrics: metrics}
+
+	handler := appspaceRouter.recordMetrics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+
+	for _, p := range []string{"/", "/missing"} {
+		req, err := http.NewRequest("GET", p, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req = req.WithContext(domain.CtxWithAppspaceData(req.Context(), domain.Appspace{AppspaceID: domain.AppspaceID(7)}))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if len(metrics.statuses) != 2 || metrics.statuses[0] != http.StatusOK || metrics.statuses[1] != http.StatusNotFound {
+		t.Errorf("unexpected statuses recorded: %v", metrics.statuses)
+	}
+}
+
+type testMetrics struct {
+	statuses []int
+}
+
+func (m *testMetrics) AppspaceRequest(appspaceID domain.AppspaceID, status int, dur time.Duration) {
+	m.statuses = append(m.statuses, status)
+}
+
 // func TestServeHTTPBadApp(t *testing.T) {
 
 // 	mockCtrl := gomock.NewController(t)
@@ -571,6 +653,120 @@ func TestServeFileOverlapPath(t *testing.T) {
 	}
 }
 
+func TestServeFileStatic(t *testing.T) {
+	dir := t.TempDir()
+	appVersion := domain.AppVersion{LocationKey: "app-version-123"}
+	ar := &AppspaceRouter{
+		AppLocation2Path: &l2p{appFiles: dir},
+	}
+
+	p := filepath.Join(dir, "app-version-123", "app", "static-files")
+	os.MkdirAll(filepath.Join(p, "with-index"), 0755)
+	os.MkdirAll(filepath.Join(p, "no-index", "sub"), 0755)
+	os.WriteFile(filepath.Join(p, "with-index", "index.html"), []byte("index"), 0644)
+	os.WriteFile(filepath.Join(p, "no-index", "a.txt"), []byte("aaa"), 0644)
+	os.WriteFile(filepath.Join(p, "no-index", ".hidden"), []byte("h"), 0644)
+	os.WriteFile(filepath.Join(p, "app.js"), []byte("plain"), 0644)
+	os.WriteFile(filepath.Join(p, "app.js.gz"), []byte("gzipped"), 0644)
+	os.WriteFile(filepath.Join(p, "app.js.br"), []byte("brotli"), 0644)
+
+	cases := []struct {
+		desc     string
+		url      string
+		opts     domain.AppRouteOptions
+		header   map[string]string
+		status   int
+		body     string
+		contains string
+		respH    map[string]string
+	}{
+		{desc: "index file", url: "/some-files/with-index/", status: 200, body: "index"},
+		{desc: "redirect to slash", url: "/some-files/with-index", status: http.StatusMovedPermanently,
+			respH: map[string]string{"Location": "/some-files/with-index/"}},
+		{desc: "no listing", url: "/some-files/no-index/", status: 404},
+		{desc: "listing", url: "/some-files/no-index/", opts: domain.AppRouteOptions{DirListing: true}, status: 200, contains: `<a href="a.txt">a.txt</a>`},
+		{desc: "custom index", url: "/some-files/no-index/", opts: domain.AppRouteOptions{Index: "a.txt"}, status: 200, body: "aaa"},
+		{desc: "cache control", url: "/some-files/no-index/a.txt", opts: domain.AppRouteOptions{CacheControl: "max-age=60", ETag: true}, status: 200, body: "aaa",
+			respH: map[string]string{"Cache-Control": "max-age=60"}},
+		{desc: "no accept-encoding", url: "/some-files/app.js", status: 200, body: "plain",
+			respH: map[string]string{"Vary": "Accept-Encoding", "Content-Encoding": ""}},
+		{desc: "gzip", url: "/some-files/app.js", header: map[string]string{"Accept-Encoding": "gzip"}, status: 200, body: "gzipped",
+			respH: map[string]string{"Content-Encoding": "gzip", "Content-Type": "text/javascript; charset=utf-8"}},
+		{desc: "br preferred", url: "/some-files/app.js", header: map[string]string{"Accept-Encoding": "gzip, br"}, status: 200, body: "brotli",
+			respH: map[string]string{"Content-Encoding": "br"}},
+		{desc: "br refused", url: "/some-files/app.js", header: map[string]string{"Accept-Encoding": "gzip, br;q=0"}, status: 200, body: "gzipped"},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			c.opts.Path = "@app/static-files/"
+			routeConfig := domain.AppRoute{
+				Path:    domain.AppRoutePath{Path: "/some-files", End: false},
+				Type:    "static",
+				Options: c.opts}
+			req, _ := http.NewRequest("GET", c.url, nil)
+			for k, v := range c.header {
+				req.Header.Set(k, v)
+			}
+			ctx := domain.CtxWithAppVersionData(req.Context(), appVersion)
+			ctx = domain.CtxWithRouteConfig(ctx, routeConfig)
+			rr := httptest.NewRecorder()
+			ar.serveFile(rr, req.WithContext(ctx))
+
+			if rr.Code != c.status {
+				t.Errorf("expected status %v, got %v", c.status, rr.Code)
+			}
+			if c.body != "" && rr.Body.String() != c.body {
+				t.Errorf("expected body %v, got %v", c.body, rr.Body.String())
+			}
+			if c.contains != "" && !strings.Contains(rr.Body.String(), c.contains) {
+				t.Errorf("expected body to contain %v, got %v", c.contains, rr.Body.String())
+			}
+			if strings.Contains(rr.Body.String(), ".hidden") {
+				t.Error("hidden file listed")
+			}
+			for k, v := range c.respH {
+				if rr.Header().Get(k) != v {
+					t.Errorf("expected header %v: %v, got %v", k, v, rr.Header().Get(k))
+				}
+			}
+		})
+	}
+}
+
+func TestServeFileETag(t *testing.T) {
+	dir := t.TempDir()
+	appVersion := domain.AppVersion{LocationKey: "app-version-123"}
+	ar := &AppspaceRouter{
+		AppLocation2Path: &l2p{appFiles: dir},
+	}
+	p := filepath.Join(dir, "app-version-123", "app")
+	os.MkdirAll(p, 0755)
+	os.WriteFile(filepath.Join(p, "a.txt"), []byte("aaa"), 0644)
+
+	routeConfig := domain.AppRoute{
+		Path:    domain.AppRoutePath{Path: "/a.txt", End: true},
+		Type:    "static",
+		Options: domain.AppRouteOptions{Path: "@app/a.txt", ETag: true}}
+
+	req, _ := http.NewRequest("GET", "/a.txt", nil)
+	ctx := domain.CtxWithAppVersionData(req.Context(), appVersion)
+	ctx = domain.CtxWithRouteConfig(ctx, routeConfig)
+	rr := httptest.NewRecorder()
+	ar.serveFile(rr, req.WithContext(ctx))
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an etag")
+	}
+
+	req, _ = http.NewRequest("GET", "/a.txt", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	ar.serveFile(rr, req.WithContext(ctx))
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("expected not modified, got %v", rr.Code)
+	}
+}
+
 type l2p struct {
 	appFiles string
 }
@@ -578,3 +774,110 @@ type l2p struct {
 func (l *l2p) Files(loc string) string {
 	return filepath.Join(l.appFiles, loc, "app")
 }
+
+func TestRedirectRoute(t *testing.T) {
+	cases := []struct {
+		route    domain.AppRoute
+		url      string
+		status   int
+		location string
+	}{
+		{domain.AppRoute{Path: domain.AppRoutePath{Path: "/old", End: true}, Options: domain.AppRouteOptions{Target: "/new"}},
+			"/old", http.StatusFound, "/new"},
+		{domain.AppRoute{Path: domain.AppRoutePath{Path: "/old", End: false}, Options: domain.AppRouteOptions{Target: "/new", Status: 301}},
+			"/old/a/b?c=d", http.StatusMovedPermanently, "/new/a/b?c=d"},
+		{domain.AppRoute{Path: domain.AppRoutePath{Path: "/", End: false}, Options: domain.AppRouteOptions{Target: "https://example.com/"}},
+			"/a%20b", http.StatusFound, "https://example.com/a%20b"},
+		{domain.AppRoute{Path: domain.AppRoutePath{Path: "/old", End: false}, Options: domain.AppRouteOptions{Target: "/"}},
+			"/old//evil.com", http.StatusFound, "/evil.com"},
+		{domain.AppRoute{Path: domain.AppRoutePath{Path: "/old", End: false}, Options: domain.AppRouteOptions{Target: "/"}},
+			"/old/%2F%2Fevil.com", http.StatusFound, "/evil.com"},
+		{domain.AppRoute{Path: domain.AppRoutePath{Path: "/old", End: true}, Options: domain.AppRouteOptions{Target: "/\\evil.com"}},
+			"/old", http.StatusBadRequest, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.url, func(t *testing.T) {
+			c.route.Type = "redirect"
+			req, _ := http.NewRequest(http.MethodGet, c.url, nil)
+			req = req.WithContext(domain.CtxWithRouteConfig(req.Context(), c.route))
+			rr := httptest.NewRecorder()
+			ar := &AppspaceRouter{}
+			ar.redirect(rr, req)
+			if rr.Code != c.status {
+				t.Errorf("expected status %v, got %v", c.status, rr.Code)
+			}
+			if rr.Header().Get("Location") != c.location {
+				t.Errorf("expected location %v, got %v", c.location, rr.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+type fakeAppRoutes struct {
+	routes map[string]domain.AppRoute
+}
+
+func (f *fakeAppRoutes) Match(appID domain.AppID, version domain.Version, method string, reqPath string) (domain.AppRoute, error) {
+	return f.routes[reqPath], nil
+}
+
+func TestProxyRoute(t *testing.T) {
+	dir := t.TempDir()
+	appVersion := domain.AppVersion{LocationKey: "app-version-123"}
+	p := filepath.Join(dir, "app-version-123", "app")
+	os.MkdirAll(p, 0755)
+	os.WriteFile(filepath.Join(p, "a.txt"), []byte("aaa"), 0644)
+
+	ar := &AppspaceRouter{
+		AppLocation2Path: &l2p{appFiles: dir},
+		AppRoutes: &fakeAppRoutes{routes: map[string]domain.AppRoute{
+			"/files/a.txt": {
+				Path:    domain.AppRoutePath{Path: "/files", End: false},
+				Auth:    domain.AppspaceRouteAuth{Allow: "public"},
+				Type:    "static",
+				Options: domain.AppRouteOptions{Path: "@app/"}},
+			"/private/a.txt": {
+				Path:    domain.AppRoutePath{Path: "/private", End: false},
+				Auth:    domain.AppspaceRouteAuth{Allow: "authorized"},
+				Type:    "static",
+				Options: domain.AppRouteOptions{Path: "@app/"}},
+			"/loop/a.txt": {
+				Path:    domain.AppRoutePath{Path: "/loop", End: false},
+				Auth:    domain.AppspaceRouteAuth{Allow: "public"},
+				Type:    "proxy",
+				Options: domain.AppRouteOptions{Target: "/files"}},
+		}},
+	}
+
+	cases := []struct {
+		target string
+		status int
+		body   string
+	}{
+		{"/files", http.StatusOK, "aaa"},
+		{"/private", http.StatusForbidden, ""},
+		{"/loop", http.StatusInternalServerError, ""},
+		{"/nothing", http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.target, func(t *testing.T) {
+			route := domain.AppRoute{
+				Path:    domain.AppRoutePath{Path: "/alias", End: false},
+				Auth:    domain.AppspaceRouteAuth{Allow: "public"},
+				Type:    "proxy",
+				Options: domain.AppRouteOptions{Target: c.target}}
+			req, _ := http.NewRequest(http.MethodGet, "/alias/a.txt", nil)
+			ctx := domain.CtxWithAppVersionData(req.Context(), appVersion)
+			ctx = domain.CtxWithAppspaceData(ctx, domain.Appspace{AppspaceID: domain.AppspaceID(7)})
+			ctx = domain.CtxWithRouteConfig(ctx, route)
+			rr := httptest.NewRecorder()
+			ar.proxyRoute(rr, req.WithContext(ctx))
+			if rr.Code != c.status {
+				t.Errorf("expected status %v, got %v", c.status, rr.Code)
+			}
+			if c.body != "" && rr.Body.String() != c.body {
+				t.Errorf("expected body %v, got %v", c.body, rr.Body.String())
+			}
+		})
+	}
+}
@@ -168,7 +168,7 @@ func TestLoginToken(t *testing.T) {
 	v0TokenManager.EXPECT().CheckToken(appspaceID, "abcd").Return(domain.V0AppspaceLoginToken{AppspaceID: appspaceID, ProxyID: proxyID}, true)
 
 	authenticator := testmocks.NewMockAuthenticator(mockCtrl)
-	authenticator.EXPECT().SetForAppspace(gomock.Any(), proxyID, appspaceID, domainName).Return("cid", nil)
+	authenticator.EXPECT().SetForAppspace(gomock.Any(), gomock.Any(), proxyID, appspaceID, domainName).Return("cid", nil)
 
 	r := &FromPublic{
 		V0TokenManager: v0TokenManager,
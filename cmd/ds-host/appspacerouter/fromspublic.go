@@ -11,7 +11,7 @@ import (
 type FromPublic struct {
 	Authenticator interface {
 		AppspaceUserProxyID(http.Handler) http.Handler
-		SetForAppspace(http.ResponseWriter, domain.ProxyID, domain.AppspaceID, string) (string, error)
+		SetForAppspace(http.ResponseWriter, *http.Request, domain.ProxyID, domain.AppspaceID, string) (string, error)
 	} `checkinject:"required"`
 	V0TokenManager interface {
 		CheckToken(appspaceID domain.AppspaceID, token string) (domain.V0AppspaceLoginToken, bool)
@@ -86,7 +86,7 @@ func (f *FromPublic) processLoginToken(next http.Handler) http.Handler {
 			return
 		}
 
-		cookieID, err := f.Authenticator.SetForAppspace(w, token.ProxyID, token.AppspaceID, appspace.DomainName)
+		cookieID, err := f.Authenticator.SetForAppspace(w, r, token.ProxyID, token.AppspaceID, appspace.DomainName)
 		if err != nil {
 			http.Error(w, "internal server error", http.StatusInternalServerError)
 			return
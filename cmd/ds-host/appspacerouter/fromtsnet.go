@@ -47,12 +47,12 @@ func (f *FromTSNet) loadAppspace(next http.Handler) http.Handler {
 		}
 		appspace, err := f.AppspaceModel.GetFromID(appspaceID)
 		if err != nil {
-			f.getLogger(appspaceID).AddNote("AppspaceModel.GetFromID").Error(err)
+			f.getLogger(appspaceID).Ctx(r.Context()).AddNote("AppspaceModel.GetFromID").Error(err)
 			http.Error(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		if appspace == nil {
-			f.getLogger(appspaceID).AddNote("AppspaceModel.GetFromID").Log("no appspace returned")
+			f.getLogger(appspaceID).Ctx(r.Context()).AddNote("AppspaceModel.GetFromID").Log("no appspace returned")
 			// this is an internal error because tsnet should have shut down before the appspace got deleted
 			http.Error(w, "internal error", http.StatusInternalServerError)
 			return
@@ -68,16 +68,16 @@ func (f *FromTSNet) getProxyID(next http.Handler) http.Handler {
 		appspace, _ := domain.CtxAppspaceData(ctx)
 		tsUserID, ok := domain.CtxTSNetUserID(ctx)
 		if !ok {
-			f.getLogger(appspace.AppspaceID).Debug("getProxyID() no tsnet user id")
+			f.getLogger(appspace.AppspaceID).Ctx(ctx).Debug("getProxyID() no tsnet user id")
 			next.ServeHTTP(w, r)
 		}
 
 		u, err := f.AppspaceUserModel.GetByAuth(appspace.AppspaceID, "tsnetid", tsUserID)
 		if err == domain.ErrNoRowsInResultSet {
-			f.getLogger(appspace.AppspaceID).Debug("getProxyID() no sql rows for tsnetid")
+			f.getLogger(appspace.AppspaceID).Ctx(ctx).Debug("getProxyID() no sql rows for tsnetid")
 		}
 		if err != nil {
-			f.getLogger(appspace.AppspaceID).AddNote("AppspaceUserModel.GetByAuth").Error(err)
+			f.getLogger(appspace.AppspaceID).Ctx(ctx).AddNote("AppspaceUserModel.GetByAuth").Error(err)
 		}
 
 		next.ServeHTTP(w, r.WithContext(domain.CtxWithAppspaceUserProxyID(ctx, u.ProxyID)))
@@ -0,0 +1,145 @@
+package appspacerouter
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+const defaultIndexFile = "index.html"
+
+// precompressed siblings in order of preference
+var precompressedEncodings = []struct {
+	encoding string
+	ext      string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+func indexFile(opts domain.AppRouteOptions) string {
+	if opts.Index == "" {
+		return defaultIndexFile
+	}
+	return opts.Index
+}
+
+// precompressedSibling returns the path of a compressed version of p
+// that the client accepts, and its content encoding.
+// If there is none it returns p and an empty encoding.
+func precompressedSibling(w http.ResponseWriter, r *http.Request, p string) (string, string) {
+	accepted := acceptedEncodings(r.Header.Get("Accept-Encoding"))
+	found := false
+	for _, e := range precompressedEncodings {
+		fileinfo, err := os.Stat(p + e.ext)
+		if err != nil || !fileinfo.Mode().IsRegular() {
+			continue
+		}
+		if !found {
+			// response depends on Accept-Encoding whether or not we use the sibling
+			w.Header().Add("Vary", "Accept-Encoding")
+			found = true
+		}
+		if accepted[e.encoding] {
+			return p + e.ext, e.encoding
+		}
+	}
+	return p, ""
+}
+
+// acceptedEncodings parses an Accept-Encoding header.
+// Encodings with q=0 are not accepted.
+func acceptedEncodings(header string) map[string]bool {
+	ret := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				continue
+			}
+		}
+		ret[name] = true
+	}
+	return ret
+}
+
+// fileETag returns a strong ETag based on the file's size and mod time
+func fileETag(fileinfo fs.FileInfo, encoding string) string {
+	tag := fmt.Sprintf("%x-%x", fileinfo.ModTime().UnixNano(), fileinfo.Size())
+	if encoding != "" {
+		tag += "-" + encoding
+	}
+	return `"` + tag + `"`
+}
+
+type dirListingEntry struct {
+	Name string
+	Href string
+	Size int64
+	Dir  bool
+}
+
+var dirListingTemplate = template.Must(template.New("dir").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Path}}</title></head>
+<body>
+<h1>{{.Path}}</h1>
+<ul>
+<li><a href="../">../</a></li>
+{{range .Entries}}<li><a href="{{.Href}}">{{.Name}}{{if .Dir}}/{{end}}</a>{{if not .Dir}} ({{.Size}} bytes){{end}}</li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+// serveDirListing sends an HTML list of the files in dir.
+// Hidden files are not listed.
+func serveDirListing(w http.ResponseWriter, r *http.Request, dir string, opts domain.AppRouteOptions) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		http.Error(w, "dir read error", http.StatusInternalServerError)
+		return
+	}
+	entries := make([]dirListingEntry, 0, len(dirEntries))
+	for _, d := range dirEntries {
+		if strings.HasPrefix(d.Name(), ".") {
+			continue
+		}
+		e := dirListingEntry{
+			Name: d.Name(),
+			Href: (&url.URL{Path: d.Name()}).String(),
+			Dir:  d.IsDir()}
+		if e.Dir {
+			e.Href += "/"
+		} else if info, err := d.Info(); err == nil {
+			e.Size = info.Size()
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	if opts.CacheControl != "" {
+		w.Header().Set("Cache-Control", opts.CacheControl)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodHead {
+		return
+	}
+	dirListingTemplate.Execute(w, struct {
+		Path    string
+		Entries []dirListingEntry
+	}{path.Clean(r.URL.Path), entries})
+}
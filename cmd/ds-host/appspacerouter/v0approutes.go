@@ -4,7 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
 	"strings"
+	"unicode"
 
 	pathToRegexp "github.com/soongo/path-to-regexp"
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
@@ -136,14 +140,86 @@ func (r *AppRoutes) validateStoredRoute(route domain.AppRoute) error {
 		// need to return a good erro
 		return fmt.Errorf("failed to turn path %v into regex: %w", route.Path, err)
 	}
-	if route.Type == "static" && len(tokens) != 0 {
-		return fmt.Errorf("static route %v can not have path parameters", route.Path)
+	if route.Type != "function" && len(tokens) != 0 {
+		return fmt.Errorf("%v route %v can not have path parameters", route.Type, route.Path)
+	}
+	if route.Type != "function" && route.Options.Cache {
+		return fmt.Errorf("%v route %v can not be cached", route.Type, route.Path)
+	}
+	switch route.Type {
+	case "function":
+	case "static":
+		err = validateStaticOptions(route.Options)
+	case "redirect":
+		err = validateRedirectOptions(route.Options)
+	case "proxy":
+		err = validateProxyOptions(route.Options)
+	default:
+		return fmt.Errorf("route %v has unknown type: %v", route.Path, route.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("%v route %v: %w", route.Type, route.Path, err)
 	}
 	// - check permissions required for routes match declared permissions, or is that done elsewhere?
 
 	return nil
 }
 
+func validateStaticOptions(opts domain.AppRouteOptions) error {
+	if opts.Index != "" {
+		if opts.Index == "." || opts.Index == ".." || strings.ContainsAny(opts.Index, "/\\") {
+			return fmt.Errorf("index must be a file name: %v", opts.Index)
+		}
+	}
+	if len(opts.CacheControl) > 256 {
+		return errors.New("cache control is too long")
+	}
+	for _, c := range opts.CacheControl {
+		if c < 0x20 || c == 0x7f {
+			return errors.New("cache control has invalid characters")
+		}
+	}
+	return nil
+}
+
+var redirectStatuses = []int{
+	http.StatusMovedPermanently,
+	http.StatusFound,
+	http.StatusSeeOther,
+	http.StatusTemporaryRedirect,
+	http.StatusPermanentRedirect}
+
+func validateRedirectOptions(opts domain.AppRouteOptions) error {
+	if opts.Status != 0 && !slices.Contains(redirectStatuses, opts.Status) {
+		return fmt.Errorf("invalid redirect status: %v", opts.Status)
+	}
+	if isAppspacePath(opts.Target) {
+		return nil
+	}
+	u, err := url.Parse(opts.Target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("redirect target must be a path or an http(s) URL: %v", opts.Target)
+	}
+	return nil
+}
+
+func validateProxyOptions(opts domain.AppRouteOptions) error {
+	if !isAppspacePath(opts.Target) {
+		return fmt.Errorf("proxy target must be a path: %v", opts.Target)
+	}
+	if opts.Status != 0 {
+		return errors.New("proxy route can not have a status")
+	}
+	return nil
+}
+
+// isAppspacePath returns true if p is an absolute path
+// with no host, query or fragment
+func isAppspacePath(p string) bool {
+	return strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "//") &&
+		!strings.ContainsAny(p, "?#\\") && !strings.ContainsFunc(p, unicode.IsControl)
+}
+
 func (r *AppRoutes) getMatchFn(route domain.AppRoute) (func(string) (*pathToRegexp.MatchResult, error), error) {
 	options := getOptions(route)
 	matchFn, err := pathToRegexp.Match(route.Path.Path, &options)
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	pathToRegexp "github.com/soongo/path-to-regexp"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
 )
 
 func TestP2R(t *testing.T) {
@@ -114,3 +115,52 @@ func TestP2RMatch(t *testing.T) {
 
 ///////////////
 // Need actual tests for V0approutes
+
+func TestValidateStaticOptions(t *testing.T) {
+	cases := []struct {
+		opts domain.AppRouteOptions
+		ok   bool
+	}{
+		{domain.AppRouteOptions{}, true},
+		{domain.AppRouteOptions{Index: "index.htm", CacheControl: "public, max-age=3600"}, true},
+		{domain.AppRouteOptions{Index: "../index.html"}, false},
+		{domain.AppRouteOptions{Index: ".."}, false},
+		{domain.AppRouteOptions{CacheControl: "no-cache\r\nX-Evil: yes"}, false},
+	}
+	for _, c := range cases {
+		err := validateStaticOptions(c.opts)
+		if (err == nil) != c.ok {
+			t.Errorf("%v: unexpected result %v", c.opts, err)
+		}
+	}
+}
+
+func TestValidateStoredRouteTypes(t *testing.T) {
+	cases := []struct {
+		route domain.AppRoute
+		ok    bool
+	}{
+		{domain.AppRoute{Type: "redirect", Options: domain.AppRouteOptions{Target: "/new"}}, true},
+		{domain.AppRoute{Type: "redirect", Options: domain.AppRouteOptions{Target: "https://example.com/x", Status: 308}}, true},
+		{domain.AppRoute{Type: "redirect", Options: domain.AppRouteOptions{Target: "/new", Status: 200}}, false},
+		{domain.AppRoute{Type: "redirect", Options: domain.AppRouteOptions{Target: "//evil.com"}}, false},
+		{domain.AppRoute{Type: "redirect", Options: domain.AppRouteOptions{Target: "javascript:alert(1)"}}, false},
+		{domain.AppRoute{Type: "proxy", Options: domain.AppRouteOptions{Target: "/api"}}, true},
+		{domain.AppRoute{Type: "proxy", Options: domain.AppRouteOptions{Target: "https://example.com"}}, false},
+		{domain.AppRoute{Type: "proxy", Options: domain.AppRouteOptions{Target: "/api?x=1"}}, false},
+		{domain.AppRoute{Type: "nonsense"}, false},
+		{domain.AppRoute{Type: "function", Options: domain.AppRouteOptions{Name: "abc", Cache: true}}, true},
+		{domain.AppRoute{Type: "static", Options: domain.AppRouteOptions{Path: "@app/", Cache: true}}, false},
+		{domain.AppRoute{Type: "redirect", Path: domain.AppRoutePath{Path: "/:id", End: true}, Options: domain.AppRouteOptions{Target: "/new"}}, false},
+	}
+	r := &AppRoutes{}
+	for _, c := range cases {
+		if c.route.Path.Path == "" {
+			c.route.Path = domain.AppRoutePath{Path: "/abc", End: true}
+		}
+		err := r.validateStoredRoute(c.route)
+		if (err == nil) != c.ok {
+			t.Errorf("%v: unexpected result %v", c.route, err)
+		}
+	}
+}
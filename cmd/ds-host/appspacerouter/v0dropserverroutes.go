@@ -6,9 +6,11 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/ratelimit"
 	"github.com/teleclimber/DropServer/cmd/ds-host/record"
 	"github.com/teleclimber/DropServer/internal/validator"
 )
@@ -35,16 +37,20 @@ type V0DropserverRoutes struct {
 	V0TokenManager interface {
 		SendLoginToken(appspaceID domain.AppspaceID, dropID string, ref string) error
 	} `checkinject:"required"`
+	LoginLimiter interface {
+		Middleware(route string, account func(*http.Request) string, blocked func(http.ResponseWriter, *http.Request, time.Duration)) func(http.Handler) http.Handler
+	} `checkinject:"required"`
 }
 
 func (d *V0DropserverRoutes) subRouter() http.Handler {
 	mux := chi.NewRouter()
 
 	mux.Get("/login-token-request", notFound) // we answer "get" with "not found" so that typical browser and bot requests that hit this end point don't see anything useful.
-	mux.Post("/login-token-request", d.loginTokenRequest)
+	// bad requests to these count as failed attempts for the requester's IP
+	mux.With(d.LoginLimiter.Middleware("login-token-request", nil, nil)).Post("/login-token-request", d.loginTokenRequest)
 
 	mux.Get("/login-token", notFound)
-	mux.Post("/login-token", d.loginTokenResponse)
+	mux.With(d.LoginLimiter.Middleware("login-token", nil, nil)).Post("/login-token", d.loginTokenResponse)
 
 	mux.Get("/logout", d.logout)
 
@@ -55,6 +61,7 @@ func (d *V0DropserverRoutes) loginTokenRequest(w http.ResponseWriter, r *http.Re
 	var data domain.V0LoginTokenRequest
 	err := readJSON(r, &data)
 	if err != nil {
+		ratelimit.Ignore(r)
 		http.Error(w, "unable to parse JSON", http.StatusBadRequest)
 		return
 	}
@@ -93,6 +100,7 @@ func (d *V0DropserverRoutes) loginTokenResponse(w http.ResponseWriter, r *http.R
 	var data domain.V0LoginTokenResponse
 	err := readJSON(r, &data)
 	if err != nil {
+		ratelimit.Ignore(r)
 		http.Error(w, "unable to parse JSON", http.StatusBadRequest)
 		return
 	}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"time"
 
@@ -13,6 +14,10 @@ import (
 
 const cookieExpMinutes = 30
 const appspaceExpMinutes = 14 * 24 * 60 // temporary: two week lifetime for appspace sessions
+const pendingExpMinutes = 5             // time to enter a second factor after the password
+const maxUserAgentLength = 256
+
+var sweepInterval = time.Hour
 
 // Authenticator contains middleware functions for performing authentication
 type Authenticator struct {
@@ -22,17 +27,72 @@ type Authenticator struct {
 		Create(domain.Cookie) (string, error)
 		UpdateExpires(cookieID string, exp time.Time) error
 		Delete(cookieID string) error
+		DeleteExpired() error
 	} `checkinject:"required"`
+	// ClientAddr returns the IP of the client, taking trusted proxies into account.
+	// Without it the remote address of the request is used.
+	ClientAddr interface {
+		ClientAddr(*http.Request) string
+	} `checkinject:"optional"`
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// Start periodically sweeps expired cookies from the DB
+func (a *Authenticator) Start() {
+	a.stop = make(chan struct{})
+	a.stopped = make(chan struct{})
+	go func() {
+		defer close(a.stopped)
+		ticker := time.NewTicker(sweepInterval)
+		defer ticker.Stop()
+		a.CookieModel.DeleteExpired()
+		for {
+			select {
+			case <-ticker.C:
+				a.CookieModel.DeleteExpired()
+			case <-a.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops sweeping expired cookies
+func (a *Authenticator) Stop() {
+	close(a.stop)
+	<-a.stopped
 }
 
 // SetForAccount creates a cookie and sends it down
 // It is for access to the user account only
-func (a *Authenticator) SetForAccount(w http.ResponseWriter, userID domain.UserID) error {
-	cookie := domain.Cookie{
-		UserID:      userID,
-		UserAccount: true,
-		DomainName:  a.Config.Exec.UserRoutesDomain,
-		Expires:     time.Now().Add(cookieExpMinutes * time.Minute)}
+func (a *Authenticator) SetForAccount(w http.ResponseWriter, r *http.Request, userID domain.UserID) error {
+	cookie := a.newCookie(r)
+	cookie.UserID = userID
+	cookie.UserAccount = true
+	cookie.DomainName = a.Config.Exec.UserRoutesDomain
+	cookie.Expires = time.Now().Add(cookieExpMinutes * time.Minute)
+	cookieID, err := a.CookieModel.Create(cookie)
+	if err != nil {
+		return err
+	}
+
+	a.setCookie(w, cookieID, cookie.Expires, cookie.DomainName)
+
+	return nil
+}
+
+// SetPendingSecondFactor creates a short-lived cookie for a user
+// who entered their password but has yet to pass their second factor.
+// The cookie does not authenticate the user.
+func (a *Authenticator) SetPendingSecondFactor(w http.ResponseWriter, r *http.Request, userID domain.UserID) error {
+	cookie := a.newCookie(r)
+	cookie.UserID = userID
+	cookie.UserAccount = true
+	cookie.SecondFactorPending = true
+	cookie.DomainName = a.Config.Exec.UserRoutesDomain
+	cookie.Expires = time.Now().Add(pendingExpMinutes * time.Minute)
 	cookieID, err := a.CookieModel.Create(cookie)
 	if err != nil {
 		return err
@@ -43,19 +103,46 @@ func (a *Authenticator) SetForAccount(w http.ResponseWriter, userID domain.UserI
 	return nil
 }
 
+// PendingSecondFactor returns the user of the request's
+// second factor pending cookie, if any.
+func (a *Authenticator) PendingSecondFactor(r *http.Request) (domain.UserID, bool) {
+	cookie, err := a.getCookie(r)
+	if err != nil || !cookie.UserAccount || !cookie.SecondFactorPending {
+		return domain.UserID(0), false
+	}
+	return cookie.UserID, true
+}
+
+// CompleteSecondFactor replaces the second factor pending cookie
+// with a cookie that authenticates the user.
+func (a *Authenticator) CompleteSecondFactor(w http.ResponseWriter, r *http.Request) error {
+	cookie, err := a.getCookie(r)
+	if err != nil {
+		return err
+	}
+	if !cookie.UserAccount || !cookie.SecondFactorPending {
+		return errors.New("cookie is not pending a second factor")
+	}
+	err = a.CookieModel.Delete(cookie.CookieID)
+	if err != nil {
+		return err
+	}
+	return a.SetForAccount(w, r, cookie.UserID)
+}
+
 // SetForAppspace creates a cookie and sends it down
 // It is for access to the appspace only
-func (a *Authenticator) SetForAppspace(w http.ResponseWriter, proxyID domain.ProxyID, appspaceID domain.AppspaceID, dom string) (string, error) {
+func (a *Authenticator) SetForAppspace(w http.ResponseWriter, r *http.Request, proxyID domain.ProxyID, appspaceID domain.AppspaceID, dom string) (string, error) {
 	if dom == "" {
 		return "", errors.New("domain can't be blank")
 	}
 
-	cookie := domain.Cookie{
-		ProxyID:     proxyID,
-		AppspaceID:  appspaceID,
-		UserAccount: false,
-		DomainName:  dom,
-		Expires:     time.Now().Add(appspaceExpMinutes * time.Minute)}
+	cookie := a.newCookie(r)
+	cookie.ProxyID = proxyID
+	cookie.AppspaceID = appspaceID
+	cookie.UserAccount = false
+	cookie.DomainName = dom
+	cookie.Expires = time.Now().Add(appspaceExpMinutes * time.Minute)
 	cookieID, err := a.CookieModel.Create(cookie)
 	if err != nil {
 		return "", err
@@ -84,6 +171,11 @@ func (a *Authenticator) AccountUser(next http.Handler) http.Handler {
 				http.Error(w, "internal server error", http.StatusInternalServerError)
 				return
 			}
+			if cookie.SecondFactorPending {
+				// not authenticated yet
+				next.ServeHTTP(w, r)
+				return
+			}
 			ctx := domain.CtxWithAuthUserID(r.Context(), cookie.UserID)
 			ctx = domain.CtxWithSessionID(ctx, cookie.CookieID)
 			r = r.WithContext(ctx)
@@ -141,6 +233,26 @@ func (a *Authenticator) Unset(w http.ResponseWriter, r *http.Request) {
 
 var errNoCookie = errors.New("no valid cookie")
 
+// newCookie returns a cookie with the details
+// that let users recognize the session later
+func (a *Authenticator) newCookie(r *http.Request) domain.Cookie {
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgentLength {
+		ua = ua[:maxUserAgentLength]
+	}
+	var ip string
+	if a.ClientAddr != nil {
+		ip = a.ClientAddr.ClientAddr(r)
+	} else if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	} else {
+		ip = r.RemoteAddr
+	}
+	return domain.Cookie{
+		UserAgent: ua,
+		IP:        ip}
+}
+
 func (a *Authenticator) getCookie(r *http.Request) (domain.Cookie, error) {
 	c, err := r.Cookie("session_token")
 	if err != nil {
@@ -267,15 +267,23 @@ func TestSetForAccount(t *testing.T) {
 	userID := domain.UserID(1)
 
 	cm := testmocks.NewMockCookieModel(mockCtrl)
-	cm.EXPECT().Create(gomock.Any()).Return("abc", nil)
+	cm.EXPECT().Create(gomock.Any()).DoAndReturn(func(c domain.Cookie) (string, error) {
+		if c.UserAgent != "Firefox" || c.IP != "192.0.2.1" {
+			t.Errorf("expected session details on cookie: %v", c)
+		}
+		return "abc", nil
+	})
 
 	a := Authenticator{
 		Config:      getConfig(),
 		CookieModel: cm}
 
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("User-Agent", "Firefox")
 	rr := httptest.NewRecorder()
 
-	dsErr := a.SetForAccount(rr, userID)
+	dsErr := a.SetForAccount(rr, req, userID)
 	if dsErr != nil {
 		t.Error(dsErr)
 	}
@@ -316,3 +324,105 @@ func getConfig() *domain.RuntimeConfig {
 
 	return &rtc
 }
+
+func TestAccountUserSecondFactorPending(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	cm := testmocks.NewMockCookieModel(mockCtrl)
+	cm.EXPECT().Get("abc").Return(domain.Cookie{
+		CookieID:            "abc",
+		UserID:              domain.UserID(1),
+		Expires:             time.Now().Add(time.Hour),
+		UserAccount:         true,
+		SecondFactorPending: true,
+	}, nil)
+
+	a := &Authenticator{
+		Config:      getConfig(),
+		CookieModel: cm}
+
+	nextCalled := false
+	handler := a.AccountUser(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, ok := domain.CtxAuthUserID(r.Context())
+		if ok {
+			t.Error("there should not be an auth user while second factor is pending")
+		}
+		nextCalled = true
+	}))
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: "abc"})
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	if !nextCalled {
+		t.Error("next was not called")
+	}
+	if len(rr.Result().Cookies()) != 0 {
+		t.Error("pending cookie should not be refreshed")
+	}
+}
+
+func TestCompleteSecondFactor(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	cm := testmocks.NewMockCookieModel(mockCtrl)
+	cm.EXPECT().Get("abc").Return(domain.Cookie{
+		CookieID:            "abc",
+		UserID:              domain.UserID(1),
+		Expires:             time.Now().Add(time.Minute),
+		UserAccount:         true,
+		SecondFactorPending: true,
+	}, nil).Times(2)
+	cm.EXPECT().Delete("abc").Return(nil)
+	cm.EXPECT().Create(gomock.Any()).DoAndReturn(func(c domain.Cookie) (string, error) {
+		if c.UserID != domain.UserID(1) || c.SecondFactorPending || !c.UserAccount {
+			t.Errorf("unexpected cookie %v", c)
+		}
+		return "def", nil
+	})
+
+	a := &Authenticator{
+		Config:      getConfig(),
+		CookieModel: cm}
+
+	req, _ := http.NewRequest("POST", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: "abc"})
+
+	userID, ok := a.PendingSecondFactor(req)
+	if !ok || userID != domain.UserID(1) {
+		t.Errorf("expected pending user 1, got %v %v", userID, ok)
+	}
+
+	rr := httptest.NewRecorder()
+	err := a.CompleteSecondFactor(rr, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != "def" {
+		t.Errorf("expected new session cookie, got %v", cookies)
+	}
+}
+
+func TestSweepExpired(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	swept := make(chan struct{}, 1)
+	cm := testmocks.NewMockCookieModel(mockCtrl)
+	cm.EXPECT().DeleteExpired().DoAndReturn(func() error {
+		swept <- struct{}{}
+		return nil
+	})
+
+	a := &Authenticator{
+		Config:      getConfig(),
+		CookieModel: cm}
+	a.Start()
+	<-swept
+	a.Stop()
+}
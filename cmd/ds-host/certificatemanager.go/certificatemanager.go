@@ -4,13 +4,17 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/caddyserver/certmagic"
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
 )
 
 // There are probably some situations where the admin will have to delete all the certs and manage them again.
@@ -19,11 +23,20 @@ import (
 
 type CertficateManager struct {
 	Config *domain.RuntimeConfig `checkinject:"required"`
+	Mailer interface {
+		SendToAdmins(string, any) error
+	} `checkinject:"optional"`
 
 	magic  *certmagic.Config
 	issuer *certmagic.ACMEIssuer
+
+	notifiedMux sync.Mutex
+	notified    map[string]time.Time // last failure notification per domain
 }
 
+// renewals are retried often, so admins are notified at most this often per domain
+const failNotifyInterval = 24 * time.Hour
+
 func (c *CertficateManager) Init() {
 	cfg := c.Config.ManageTLSCertificates
 
@@ -34,6 +47,9 @@ func (c *CertficateManager) Init() {
 		certmagic.Default.OCSP = certmagic.OCSPConfig{DisableStapling: true}
 	}
 
+	c.notified = make(map[string]time.Time)
+	certmagic.Default.OnEvent = c.onEvent
+
 	c.magic = certmagic.NewDefault()
 
 	issuer := certmagic.ACMEIssuer{
@@ -84,6 +100,48 @@ func (c *CertficateManager) GetHTTPChallengeHandler(handler http.Handler) http.H
 	return c.issuer.HTTPChallengeHandler(handler)
 }
 
+// onEvent tells admins when a certificate fails to renew
+func (c *CertficateManager) onEvent(ctx context.Context, event string, data map[string]any) error {
+	if event != "cert_failed" || c.Mailer == nil {
+		return nil
+	}
+	if renewal, _ := data["renewal"].(bool); !renewal {
+		return nil // failures to obtain a certificate are returned to the caller of StartManaging
+	}
+	d, _ := data["identifier"].(string)
+
+	c.notifiedMux.Lock()
+	if time.Since(c.notified[d]) < failNotifyInterval {
+		c.notifiedMux.Unlock()
+		return nil
+	}
+	c.notified[d] = time.Now()
+	c.notifiedMux.Unlock()
+
+	mailData := struct {
+		Domain    string
+		Remaining string
+		Error     string
+	}{Domain: d}
+	if remaining, ok := data["remaining"].(time.Duration); ok && remaining > 0 {
+		if remaining > 48*time.Hour {
+			mailData.Remaining = fmt.Sprintf("%d days", int(remaining.Hours()/24))
+		} else {
+			mailData.Remaining = fmt.Sprintf("%d hours", int(remaining.Hours()))
+		}
+	}
+	if err, ok := data["error"].(error); ok {
+		mailData.Error = err.Error()
+	}
+	go func() {
+		err := c.Mailer.SendToAdmins("certificate-failed", mailData)
+		if err != nil {
+			record.NewDsLogger().AddNote("CertficateManager").AddNote("onEvent()").Error(err)
+		}
+	}()
+	return nil
+}
+
 // ResumeManaging manages the passed domain but returns immediately
 func (c *CertficateManager) ResumeManaging(d []string) error {
 	err := c.magic.ManageAsync(context.TODO(), d)
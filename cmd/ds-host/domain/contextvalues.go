@@ -187,3 +187,17 @@ func CtxRouteConfig(ctx context.Context) (AppRoute, bool) {
 	t, ok := ctx.Value(routeConfigDataCtxKey).(AppRoute)
 	return t, ok
 }
+
+// Request ID
+const requestIDCtxKey = ctxKey("request ID")
+
+// CtxWithRequestID sets the ID used to trace the request in logs
+func CtxWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey, requestID)
+}
+
+// CtxRequestID gets the request ID from the context
+func CtxRequestID(ctx context.Context) (string, bool) {
+	t, ok := ctx.Value(requestIDCtxKey).(string)
+	return t, ok
+}
@@ -68,7 +68,57 @@ type RuntimeConfig struct {
 		MemoryHighMb int `json:"memory-high-mb"`
 		Num          int `json:"num"`
 	} `json:"sandbox"`
-	Log        string `json:"log"`
+	Backups struct {
+		// Remotes are off-host destinations that backups are copied to
+		Remotes []BackupRemote `json:"remotes"`
+	} `json:"backups"`
+	Usage struct {
+		// KeepRunsDays is how long individual sandbox runs are kept
+		// after they are rolled up. 0 keeps them forever.
+		KeepRunsDays int `json:"keep-runs-days"`
+	} `json:"usage"`
+	AccessLog struct {
+		// DefaultKeepDays is how long access log entries are kept
+		// when the owner hasn't set it. MaxKeepDays caps what owners can set.
+		DefaultKeepDays int `json:"default-keep-days"`
+		MaxKeepDays     int `json:"max-keep-days"`
+	} `json:"access-log"`
+	// LoginLimits throttles failed logins by client IP and by account
+	LoginLimits struct {
+		// TrustedProxies are IPs or CIDR ranges of reverse proxies
+		// whose X-Forwarded-For header gives the client IP.
+		TrustedProxies []string `json:"trusted-proxies"`
+	} `json:"login-limits"`
+	// AppspaceLogs sets when appspace and app logs are rotated
+	// and how long archived segments are kept. 0 disables each.
+	AppspaceLogs struct {
+		MaxSizeKb    int `json:"max-size-kb"`
+		MaxAgeDays   int `json:"max-age-days"`
+		KeepArchives int `json:"keep-archives"`
+		KeepDays     int `json:"keep-days"`
+	} `json:"appspace-logs"`
+	// Mail is how ds-host sends email: invitations, password resets and alerts.
+	// Type is "smtp", "maildir" to write messages to a directory
+	// (useful for testing), or empty to not send email.
+	Mail struct {
+		Type string `json:"type"`
+		From string `json:"from"`
+		SMTP struct {
+			Host     string `json:"host"`
+			Port     uint16 `json:"port"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+			// TLS is "starttls", "tls" for implicit TLS (usually port 465),
+			// or "none" which should only be used with a local relay.
+			TLS string `json:"tls"`
+		} `json:"smtp"`
+		Dir string `json:"dir"` // for maildir type
+	} `json:"mail"`
+	Log string `json:"log"`
+	// LogLevel is one of debug, info, warn, error
+	LogLevel string `json:"log-level"`
+	// LogFormat is text or json
+	LogFormat  string `json:"log-format"`
 	Prometheus struct {
 		Enable bool   `json:"enable"`
 		Port   uint16 `json:"port"`
@@ -90,6 +140,24 @@ type RuntimeConfig struct {
 	}
 }
 
+// BackupRemote is an off-host destination for appspace backups.
+// Type is "dir" or "s3". A "dir" remote can be any mounted
+// file system, including a remote one (sshfs, NFS, ...).
+type BackupRemote struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Dir  string `json:"dir"`
+	S3   struct {
+		Endpoint        string `json:"endpoint"`
+		Region          string `json:"region"`
+		Bucket          string `json:"bucket"`
+		Prefix          string `json:"prefix"`
+		AccessKeyID     string `json:"access-key-id"`
+		SecretAccessKey string `json:"secret-access-key"`
+		PathStyle       bool   `json:"path-style"`
+	} `json:"s3"`
+}
+
 // DB is the global host database handler
 // OK, but it does not need to be wrapped in a struct!
 type DB struct {
@@ -131,8 +199,18 @@ type SandboxRunIDs struct {
 }
 
 // CGroupLimits specifies the limits to appply to sandboxes via cgroup controllers
+// Zero values other than MemoryHigh mean no limit.
 type CGroupLimits struct {
 	MemoryHigh int
+	MemoryMax  int
+	// CPUMaxPercent is the share of one CPU the sandbox can use
+	CPUMaxPercent int
+	// IOPath is a path on the block device that IO limits apply to
+	IOPath      string
+	IOReadBps   int
+	IOWriteBps  int
+	IOReadIOPS  int
+	IOWriteIOPS int
 }
 
 type CGroupData struct {
@@ -140,6 +218,77 @@ type CGroupData struct {
 	MemoryBytes int
 	IOBytes     int
 	IOs         int
+	// Counts of times the cgroup ran into its limits
+	MemoryHighEvents int
+	MemoryMaxEvents  int
+	OOMKills         int
+	CPUThrottled     int
+	// MemoryPressureUsec is the total time some tasks were stalled on memory
+	MemoryPressureUsec int
+}
+
+// AppspaceQuota limits the resources an appspace can use.
+// Zero means no limit.
+// DiskMaxMb is a soft limit checked when a sandbox starts:
+// an appspace over it has its files made read-only for that run.
+type AppspaceQuota struct {
+	MemoryMaxMb   int `db:"memory_max_mb" json:"memory_max_mb"`
+	CPUMaxPercent int `db:"cpu_max_percent" json:"cpu_max_percent"`
+	IOReadBps     int `db:"io_read_bps" json:"io_read_bps"`
+	IOWriteBps    int `db:"io_write_bps" json:"io_write_bps"`
+	IOReadIOPS    int `db:"io_read_iops" json:"io_read_iops"`
+	IOWriteIOPS   int `db:"io_write_iops" json:"io_write_iops"`
+	DiskMaxMb     int `db:"disk_max_mb" json:"disk_max_mb"`
+	OutReqPerMin  int `db:"out_req_per_min" json:"out_req_per_min"`
+	OutMbPerDay   int `db:"out_mb_per_day" json:"out_mb_per_day"`
+}
+
+// Tighter returns a quota with the tighter of each limit of q and o
+func (q AppspaceQuota) Tighter(o AppspaceQuota) AppspaceQuota {
+	return AppspaceQuota{
+		MemoryMaxMb:   tighterLimit(q.MemoryMaxMb, o.MemoryMaxMb),
+		CPUMaxPercent: tighterLimit(q.CPUMaxPercent, o.CPUMaxPercent),
+		IOReadBps:     tighterLimit(q.IOReadBps, o.IOReadBps),
+		IOWriteBps:    tighterLimit(q.IOWriteBps, o.IOWriteBps),
+		IOReadIOPS:    tighterLimit(q.IOReadIOPS, o.IOReadIOPS),
+		IOWriteIOPS:   tighterLimit(q.IOWriteIOPS, o.IOWriteIOPS),
+		DiskMaxMb:     tighterLimit(q.DiskMaxMb, o.DiskMaxMb),
+		OutReqPerMin:  tighterLimit(q.OutReqPerMin, o.OutReqPerMin),
+		OutMbPerDay:   tighterLimit(q.OutMbPerDay, o.OutMbPerDay),
+	}
+}
+
+func tighterLimit(a, b int) int {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// Appspace quotas can be set by the owner and by the admin.
+// The tighter of the two applies.
+const (
+	QuotaSetByOwner = "owner"
+	QuotaSetByAdmin = "admin"
+)
+
+// Names of the limits an appspace can run into
+const (
+	LimitMemoryHigh = "memory_high"
+	LimitMemoryMax  = "memory_max"
+	LimitOOMKill    = "oom_kill"
+	LimitCPUMax     = "cpu_max"
+	LimitDiskMax    = "disk_max"
+	LimitOutRate    = "out_rate"
+	LimitOutDaily   = "out_daily"
+)
+
+// AppspaceLimitHit records how often an appspace ran into one of its limits
+type AppspaceLimitHit struct {
+	AppspaceID AppspaceID `db:"appspace_id" json:"appspace_id"`
+	Limit      string     `db:"limit_name" json:"limit"`
+	Count      int        `db:"count" json:"count"`
+	LastHit    time.Time  `db:"last_hit" json:"last_hit_dt"`
 }
 
 // SandboxRunData contains the metrics of a sandbox run
@@ -149,6 +298,31 @@ type SandboxRunData struct {
 	MemoryByteSec int `db:"memory_byte_sec" json:"memory_byte_sec"`
 	IOBytes       int `db:"io_bytes" json:"io_bytes"`
 	IOs           int `db:"io_ops" json:"io_ops"`
+	// Outbound requests and bytes that went through the sandbox's OutProxy
+	OutRequests      int `db:"out_requests" json:"out_requests"`
+	OutBytesSent     int `db:"out_bytes_sent" json:"out_bytes_sent"`
+	OutBytesReceived int `db:"out_bytes_received" json:"out_bytes_received"`
+}
+
+// Usage rollup periods
+const (
+	UsageDay   = "day"
+	UsageWeek  = "week"
+	UsageMonth = "month"
+)
+
+// UsageRollup is the sum of sandbox runs over a period
+// for an owner, appspace and app.
+// AppspaceID is zero for runs that are not for an appspace.
+// In per-user totals AppspaceID and AppID are zero.
+type UsageRollup struct {
+	Period      string     `db:"period" json:"period"`
+	PeriodStart time.Time  `db:"period_start" json:"period_start"`
+	OwnerID     UserID     `db:"owner_id" json:"owner_id"`
+	AppspaceID  AppspaceID `db:"appspace_id" json:"appspace_id"`
+	AppID       AppID      `db:"app_id" json:"app_id"`
+	NumRuns     int        `db:"num_runs" json:"num_runs"`
+	SandboxRunData
 }
 
 type SandboxRun struct {
@@ -264,10 +438,43 @@ type V0LoginTokenResponse struct {
 	Ref      string `json:"ref"`
 }
 
+// V0TransferHello opens an appspace transfer session.
+// It is sent by the host pushing the appspace.
+// Proof shows that the sending host knows the ticket secret.
+// SHA256 identifies the bundle to be uploaded. It is empty
+// when the sender only checks the receiver before making the bundle.
+type V0TransferHello struct {
+	Nonce  string `json:"nonce"`
+	Proof  string `json:"proof"`
+	SHA256 string `json:"sha256"`
+}
+
+// V0TransferHelloResp is the receiving host's response to V0TransferHello.
+// Proof shows that the receiving host knows the ticket secret.
+type V0TransferHelloResp struct {
+	Nonce string `json:"nonce"`
+	Proof string `json:"proof"`
+}
+
+// V0TransferImport is sent once the bundle is fully uploaded
+type V0TransferImport struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// V0TransferStatus is the state of the transfer on the receiving host
+type V0TransferStatus struct {
+	State       string `json:"state"`
+	AppspaceURL string `json:"appspace_url"`
+	Error       string `json:"error"`
+}
+
 // LoginViewData is used to pass messages and parameters to the login page
 type LoginViewData struct {
-	Message string
-	Email   string
+	Message          string
+	Email            string
+	CSRFToken        string
+	CanResetPassword bool // mail is configured so reset links can be sent
 }
 
 // SignupViewData is used to pass messages and parameters to the login page
@@ -277,6 +484,66 @@ type SignupViewData struct {
 	FormAction       string
 	Message          string
 	Email            string
+	CSRFToken        string
+}
+
+// ResetPasswordViewData is used to render the page to ask for a password reset link,
+// and the page to choose a new password once the link is followed.
+type ResetPasswordViewData struct {
+	Message   string
+	CSRFToken string
+	Email     string
+	Sent      bool   // the reset link was sent if the account exists
+	Token     string // set when choosing a new password
+}
+
+// SecondFactorViewData is used to render the second factor login page
+type SecondFactorViewData struct {
+	Message     string
+	CSRFToken   string
+	HasTOTP     bool
+	HasPasskeys bool
+}
+
+// SetupTwoFactorViewData is used to render the page where users
+// who are required to have a second factor set up TOTP
+type SetupTwoFactorViewData struct {
+	Message       string
+	CSRFToken     string
+	Secret        string
+	URI           string
+	RecoveryCodes []string // shown once TOTP is confirmed
+}
+
+// Kinds of keys the login limiter throttles
+const (
+	LoginLimitIP      = "ip"
+	LoginLimitAccount = "account"
+)
+
+// LoginBlock is an IP or account that is throttled
+// because of failed attempts
+type LoginBlock struct {
+	Kind     string    `json:"kind"`
+	Key      string    `json:"key"`
+	Failures int       `json:"failures"`
+	Until    time.Time `json:"until"`
+	Lockout  bool      `json:"lockout"` // false if it's just backoff
+}
+
+// BlockedLoginAttempt is a request that was turned away by the login limiter
+type BlockedLoginAttempt struct {
+	Time    time.Time `json:"time"`
+	Route   string    `json:"route"`
+	IP      string    `json:"ip"`
+	Account string    `json:"account"`
+	Kind    string    `json:"kind"` // which key was throttled
+}
+
+// LoginLimitStatus is what admins see of the login limiter
+type LoginLimitStatus struct {
+	Blocks  []LoginBlock          `json:"blocks"`
+	Blocked []BlockedLoginAttempt `json:"blocked"` // most recent first
 }
 
 ///////////////////////////////////
@@ -286,6 +553,8 @@ type SignupViewData struct {
 // Some other parameters like tsnet must be fetched separately
 type Settings struct {
 	RegistrationOpen bool `json:"registration_open" db:"registration_open"`
+	// Require2FA makes users set up a second factor to log in with a password
+	Require2FA bool `json:"require_2fa" db:"require_2fa"`
 }
 
 // UserID represents the user ID
@@ -338,6 +607,110 @@ type Cookie struct {
 	// DomainName is the domain that the cookie is set to
 	// kind of redundant but simplifies sending cookie with updated expiration
 	DomainName string `db:"domain"`
+
+	// SecondFactorPending is set on user account cookies for users
+	// who entered their password but not yet their second factor.
+	// These cookies do not authenticate the user.
+	SecondFactorPending bool `db:"second_factor_pending"`
+
+	// UserAgent and IP are those of the request that created the cookie.
+	// They help users recognize their sessions.
+	UserAgent string    `db:"user_agent"`
+	IP        string    `db:"ip"`
+	Created   time.Time `db:"created"`
+	LastSeen  time.Time `db:"last_seen"`
+}
+
+// Session is what users see of a cookie.
+// SessionID is derived from the cookie id so that the cookie itself is never exposed.
+type Session struct {
+	SessionID  string     `json:"session_id"`
+	AppspaceID AppspaceID `json:"appspace_id,omitempty"`
+	ProxyID    ProxyID    `json:"proxy_id,omitempty"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	Created    time.Time  `json:"created"`
+	LastSeen   time.Time  `json:"last_seen"`
+	Expires    time.Time  `json:"expires"`
+	Current    bool       `json:"current"`
+}
+
+// PasswordReset is a pending request to reset a user's password
+type PasswordReset struct {
+	UserID  UserID    `db:"user_id"`
+	Created time.Time `db:"created"`
+	Expires time.Time `db:"expires"`
+}
+
+// UserTOTP is a user's time-based one-time password secret
+type UserTOTP struct {
+	UserID  UserID    `db:"user_id"`
+	Secret  string    `db:"secret"`
+	Enabled bool      `db:"enabled"` // false until the user confirms a code
+	Created time.Time `db:"created"`
+	// LastStep is the time step of the last code used, to prevent replays
+	LastStep int64 `db:"last_step"`
+}
+
+// Passkey is a WebAuthn credential registered by a user
+type Passkey struct {
+	CredentialID string             `db:"credential_id" json:"credential_id"` // base64url
+	UserID       UserID             `db:"user_id" json:"user_id"`
+	Name         string             `db:"name" json:"name"`
+	PublicKey    []byte             `db:"public_key" json:"-"` // COSE key
+	SignCount    uint32             `db:"sign_count" json:"-"`
+	Created      time.Time          `db:"created" json:"created_dt"`
+	LastUsed     nulltypes.NullTime `db:"last_used" json:"last_used_dt"`
+}
+
+// TwoFactorStatus is a user's second factor setup
+type TwoFactorStatus struct {
+	TOTP          bool      `json:"totp"`
+	RecoveryCodes int       `json:"recovery_codes"` // unused codes left
+	Passkeys      []Passkey `json:"passkeys"`
+	Required      bool      `json:"required"`
+}
+
+// WebAuthnCredential identifies a credential in WebAuthn options
+type WebAuthnCredential struct {
+	Type string `json:"type"` // always "public-key"
+	ID   string `json:"id"`   // base64url
+}
+
+// WebAuthnCreationOptions are sent to the browser to register a passkey.
+// Binary values are base64url encoded.
+type WebAuthnCreationOptions struct {
+	Challenge string `json:"challenge"`
+	RP        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	PubKeyCredParams []struct {
+		Type string `json:"type"`
+		Alg  int    `json:"alg"`
+	} `json:"pubKeyCredParams"`
+	Timeout                int                  `json:"timeout"`
+	ExcludeCredentials     []WebAuthnCredential `json:"excludeCredentials"`
+	AuthenticatorSelection struct {
+		ResidentKey      string `json:"residentKey"`
+		UserVerification string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+	Attestation string `json:"attestation"`
+}
+
+// WebAuthnRequestOptions are sent to the browser to log in with a passkey.
+// Binary values are base64url encoded.
+type WebAuthnRequestOptions struct {
+	Challenge        string               `json:"challenge"`
+	RPID             string               `json:"rpId"`
+	Timeout          int                  `json:"timeout"`
+	AllowCredentials []WebAuthnCredential `json:"allowCredentials"`
+	UserVerification string               `json:"userVerification"`
 }
 
 // DomainData tells how a domain name can be used
@@ -458,6 +831,7 @@ type AppVersionUI struct {
 	Funding          string           `db:"funding" json:"funding"`
 	ReleaseDate      string           `db:"release_date" json:"release_date"`
 	License          string           `db:"license" json:"license"`
+	Outbound         []string         `json:"outbound"` // hosts the app needs to connect to
 }
 
 type AppGetKey string
@@ -522,6 +896,8 @@ type Appspace struct {
 	Created     time.Time  `db:"created"`
 	Paused      bool       `db:"paused"`
 	LocationKey string     `db:"location_key"`
+	// Redirect is the address of the appspace after it was transferred away
+	Redirect string `db:"redirect"`
 
 	// Config AppspaceConfig ..this one is harder
 }
@@ -546,6 +922,41 @@ type TSNetCreateConfig struct {
 	AuthKey    string `json:"auth_key" validate:"tsnetauthkey"`
 }
 
+// BackupSchedule is an appspace's automatic backup schedule
+// and the retention policy applied to its backups.
+// Schedule is "daily", "weekly", "monthly" or a 5-field cron expression.
+// Retention: the KeepLast most recent backups are kept,
+// plus the most recent backup of each of the last KeepDaily days,
+// KeepWeekly weeks and KeepMonthly months.
+// If all Keep values are zero no backups are pruned.
+type BackupSchedule struct {
+	AppspaceID  AppspaceID         `db:"appspace_id" json:"-"`
+	Enabled     bool               `db:"enabled" json:"enabled"`
+	Schedule    string             `db:"schedule" json:"schedule"`
+	KeepLast    int                `db:"keep_last" json:"keep_last" validate:"min=0,max=1000"`
+	KeepDaily   int                `db:"keep_daily" json:"keep_daily" validate:"min=0,max=1000"`
+	KeepWeekly  int                `db:"keep_weekly" json:"keep_weekly" validate:"min=0,max=1000"`
+	KeepMonthly int                `db:"keep_monthly" json:"keep_monthly" validate:"min=0,max=1000"`
+	Created     time.Time          `db:"created" json:"created"`
+	LastRun     nulltypes.NullTime `db:"last_run" json:"last_run"`
+}
+
+// BackupKey is the public key that an appspace's exported backups are encrypted to.
+// The owner's passphrase is needed to decrypt them.
+type BackupKey struct {
+	AppspaceID AppspaceID `db:"appspace_id" json:"-"`
+	Recipient  string     `db:"recipient" json:"-"`
+	Created    time.Time  `db:"created" json:"created"`
+}
+
+// RemoteBackupList is the list of an appspace's backups on a backup remote
+// Error is set if the remote could not be listed.
+type RemoteBackupList struct {
+	Remote  string   `json:"remote"`
+	Backups []string `json:"backups"`
+	Error   string   `json:"error,omitempty"`
+}
+
 type RemoteAppspace struct {
 	UserID      UserID    `db:"user_id"`
 	DomainName  string    `db:"domain_name"`
@@ -562,6 +973,54 @@ type AppspaceMetaInfo struct {
 	// Or not!
 }
 
+// AppspaceBundleMeta describes the contents of an appspace export bundle.
+// It is stored as bundle.json alongside the app package and the appspace data.
+type AppspaceBundleMeta struct {
+	BundleVersion int       `json:"bundle_version"`
+	Created       time.Time `json:"created_dt"`
+	DomainName    string    `json:"domain_name"`
+	// DropID of the appspace owner on the source host
+	DropID        string         `json:"dropid"`
+	AppName       string         `json:"app_name"`
+	AppVersion    Version        `json:"app_version"`
+	Schema        int            `json:"schema"`
+	PackageSHA256 string         `json:"package_sha256"`
+	Encrypted     bool           `json:"encrypted"`
+	Users         []AppspaceUser `json:"users"`
+	TSNet         *TSNetCommon   `json:"tsnet,omitempty"`
+	// Outbound hosts the owner approved on the source host.
+	// Nil if they were never approved.
+	Outbound []string `json:"outbound"`
+}
+
+// AppspaceTransferTicket allows a remote host to push an appspace to this host.
+// The appspace is created with the domain and dropid set in the ticket.
+// Code is only available when the ticket is created.
+type AppspaceTransferTicket struct {
+	TicketID   string     `json:"ticket_id"`
+	Code       string     `json:"code,omitempty"`
+	DomainName string     `json:"domain_name"`
+	DropID     string     `json:"dropid"`
+	Created    time.Time  `json:"created_dt"`
+	Expires    time.Time  `json:"expires_dt"`
+	State      string     `json:"state"`
+	AppspaceID AppspaceID `json:"appspace_id,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// AppspaceTransferStatus is the state of an outgoing appspace transfer
+type AppspaceTransferStatus struct {
+	AppspaceID  AppspaceID `json:"appspace_id"`
+	Step        string     `json:"step"`
+	BytesSent   int64      `json:"bytes_sent"`
+	BytesTotal  int64      `json:"bytes_total"`
+	Redirect    bool       `json:"redirect"`
+	AppspaceURL string     `json:"appspace_url,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	Started     time.Time  `json:"started_dt"`
+	Finished    time.Time  `json:"finished_dt"`
+}
+
 // AppspaceUserPermission describes a permission that can be granted to
 // a user, or via other means. The name and description are user-facing,
 // the key is used internally.
@@ -645,7 +1104,7 @@ type AppRoute struct {
 	Method  string            `json:"method"`
 	Path    AppRoutePath      `json:"path"` // Path is the request path to match
 	Auth    AppspaceRouteAuth `json:"auth"`
-	Type    string            `json:"type"`    // Type of handler: "function" or "static" for now
+	Type    string            `json:"type"`    // Type of handler: "function", "static", "redirect" or "proxy"
 	Options AppRouteOptions   `json:"options"` // Options for the route handler
 }
 
@@ -660,6 +1119,28 @@ type AppRoutePath struct {
 type AppRouteOptions struct {
 	Name string `json:"name,omitempty"` // this is called "location" downstream. (but why?)
 	Path string `json:"path,omitempty"`
+
+	// Function route options:
+	// Cache lets the host store responses and serve them without the sandbox
+	// as allowed by the response's Cache-Control and ETag headers.
+	Cache bool `json:"cache,omitempty"`
+
+	// Static route options:
+	// Index is the file served for a directory. Defaults to index.html
+	Index string `json:"index,omitempty"`
+	// DirListing serves a listing of a directory that has no index file
+	DirListing bool `json:"dir_listing,omitempty"`
+	// ETag sets an ETag on served files so clients can revalidate
+	ETag bool `json:"etag,omitempty"`
+	// CacheControl is the value of the Cache-Control header for served files
+	CacheControl string `json:"cache_control,omitempty"`
+
+	// Redirect and proxy route options:
+	// Target is the redirect location, or the appspace path a proxy route forwards to.
+	// The remainder of the request path on wildcard routes is appended.
+	Target string `json:"target,omitempty"`
+	// Status is the redirect status code. Defaults to 302
+	Status int `json:"status,omitempty"`
 }
 
 // DropID represents a golbally unique identification
@@ -865,16 +1346,60 @@ type LoggerI interface {
 	GetLastBytes(n int64) (LogChunk, error)
 	SubscribeEntries(n int64) (LogChunk, <-chan string, error)
 	UnsubscribeEntries(ch <-chan string)
+	Query(q LogQuery) (LogQueryResult, error)
 }
 
 // AppspaceLogChunk contains a part of an appspace Log as a string
 // and the from and to bytes that this string represents in the log
+// From is negative when the chunk begins in archived log segments.
 type LogChunk struct {
 	From    int64  `json:"from"`
 	To      int64  `json:"to"`
 	Content string `json:"content"`
 }
 
+// LogEntry is a log line split into its parts
+type LogEntry struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Message string    `json:"message"`
+}
+
+// LogQuery filters log entries. Zero values match everything.
+// Source matches entries whose source starts with it,
+// so "sandbox" matches "sandbox-3-stdout".
+// Text is matched case-insensitively against the message.
+type LogQuery struct {
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"` // exclusive
+	Source string    `json:"source"`
+	Text   string    `json:"text"`
+	Limit  int       `json:"limit"`
+	// Before is the Next cursor of a previous result
+	Before string `json:"before"`
+}
+
+// LogQueryResult has matching entries, newest first.
+// Next is a cursor to get older entries, empty if there are none.
+type LogQueryResult struct {
+	Entries []LogEntry `json:"entries"`
+	Next    string     `json:"next"`
+}
+
+// LogArchive is a rotated and compressed segment of a log
+type LogArchive struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	Rotated time.Time `json:"rotated"`
+}
+
+// LogRetention sets how many archived log segments are kept
+// and for how long. Zero values use the host's defaults.
+type LogRetention struct {
+	KeepArchives int `db:"keep_archives" json:"keep_archives"`
+	KeepDays     int `db:"keep_days" json:"keep_days"`
+}
+
 // AppspaceRouteHitEvent contains the route that was matched with the request
 // Is this versioned or not? It would be easier if not.
 // Or at least have basic data unversioned, and more details versioned?
@@ -893,6 +1418,85 @@ type AppspaceRouteHitEvent struct {
 	Status     int
 }
 
+// OutboundExchange is an outbound request made by app code
+// and its response, as seen by the sandbox's OutProxy.
+// Bodies are truncated. Error is set if no response was received.
+type OutboundExchange struct {
+	Timestamp         time.Time     `json:"timestamp"`
+	AppspaceID        AppspaceID    `json:"appspace_id"`
+	Method            string        `json:"method"`
+	URL               string        `json:"url"`
+	RequestHeader     http.Header   `json:"request_header"`
+	RequestBody       string        `json:"request_body"`
+	RequestTruncated  bool          `json:"request_truncated"`
+	Status            int           `json:"status"`
+	ResponseHeader    http.Header   `json:"response_header"`
+	ResponseBody      string        `json:"response_body"`
+	ResponseTruncated bool          `json:"response_truncated"`
+	Duration          time.Duration `json:"duration"`
+	Error             string        `json:"error"`
+}
+
+// AccessLogSettings are an appspace's access log settings.
+// KeepDays of 0 uses the host default.
+type AccessLogSettings struct {
+	Enabled  bool `db:"enabled" json:"enabled"`
+	KeepDays int  `db:"keep_days" json:"keep_days"`
+}
+
+// AccessLogEntry is a request to an appspace that was recorded.
+// Route fields are empty if no route matched.
+type AccessLogEntry struct {
+	EntryID    int64      `db:"entry_id" json:"entry_id"`
+	AppspaceID AppspaceID `db:"appspace_id" json:"appspace_id"`
+	Time       time.Time  `db:"time" json:"time"`
+	Method     string     `db:"method" json:"method"`
+	Path       string     `db:"path" json:"path"`
+	RouteID    string     `db:"route_id" json:"route_id"`
+	RoutePath  string     `db:"route_path" json:"route_path"`
+	RouteType  string     `db:"route_type" json:"route_type"`
+	ProxyID    ProxyID    `db:"proxy_id" json:"proxy_id"`
+	Authorized bool       `db:"authorized" json:"authorized"`
+	Status     int        `db:"status" json:"status"`
+}
+
+// AccessLogQuery filters access log entries. Zero values match everything.
+// Status of 4 or 5 matches the whole class, like 4xx.
+// Before is an entry ID to get older entries.
+type AccessLogQuery struct {
+	From      time.Time
+	To        time.Time
+	RoutePath string
+	ProxyID   ProxyID
+	Status    int
+	Limit     int
+	Before    int64
+}
+
+// AccessLogStats summarizes an appspace's access log over a period
+type AccessLogStats struct {
+	Total  int                   `json:"total"`
+	Errors int                   `json:"errors"` // 4xx and 5xx responses
+	Routes []AccessLogRouteStats `json:"routes"`
+	Users  []AccessLogUserStats  `json:"users"`
+}
+
+// AccessLogRouteStats counts requests to a route
+type AccessLogRouteStats struct {
+	RoutePath    string `db:"route_path" json:"route_path"`
+	Hits         int    `db:"hits" json:"hits"`
+	ClientErrors int    `db:"client_errors" json:"client_errors"`
+	ServerErrors int    `db:"server_errors" json:"server_errors"`
+}
+
+// AccessLogUserStats counts requests by a user to a route
+// ProxyID is empty for requests without a user.
+type AccessLogUserStats struct {
+	ProxyID   ProxyID `db:"proxy_id" json:"proxy_id"`
+	RoutePath string  `db:"route_path" json:"route_path"`
+	Hits      int     `db:"hits" json:"hits"`
+}
+
 // cli stuff
 
 // StdInput gives ability to read from the command line
@@ -49,3 +49,31 @@ type BadRestoreZip interface {
 	MissingFiles() []string
 	ZipFiles() []string
 }
+
+// ErrRemoteBackupNotFound is returned when a backup remote
+// or a backup on that remote does not exist
+var ErrRemoteBackupNotFound = errors.New("remote backup not found")
+
+// ErrBackupEncrypted is returned when an encrypted backup
+// is used before it has been decrypted
+var ErrBackupEncrypted = errors.New("backup is encrypted")
+
+// ErrBadBundle is returned when an appspace export bundle
+// is missing parts or its parts do not match its metadata
+var ErrBadBundle = errors.New("bad input: invalid appspace bundle")
+
+// ErrTransferOffset is returned when a chunk of a transfer upload
+// does not start where the previous one ended
+var ErrTransferOffset = errors.New("bad input: transfer upload offset mismatch")
+
+// ErrLogCursor is returned when a log query's
+// "before" cursor can't be parsed
+var ErrLogCursor = errors.New("bad input: invalid log cursor")
+
+// ErrOutboundNotDeclared is returned when an owner approves
+// an outbound host that the app version does not declare
+var ErrOutboundNotDeclared = errors.New("bad input: outbound host not declared by app")
+
+// ErrLastSecondFactor is returned when a user tries to remove
+// their only second factor while the instance requires 2FA
+var ErrLastSecondFactor = errors.New("bad input: can not remove last second factor while 2FA is required")
@@ -49,6 +49,11 @@ type AppVersionManifest struct {
 	// Migrations is list of migrations provided by this app version
 	Migrations []MigrationStep `json:"migrations"`
 
+	// Outbound is the list of hosts the app needs to connect to.
+	// Hosts can be prefixed with "*." to match any subdomain, and can specify a port.
+	// The appspace owner must approve these before the app can reach them.
+	Outbound []string `json:"outbound"`
+
 	// Icon is a package-relative path to an icon file to display within the installer instance UI.
 	Icon string `json:"icon"`
 	//AccentColor is a CSS color used to differentiate the app in the Dropserver UI
@@ -12,6 +12,7 @@ import (
 	"runtime"
 	"syscall"
 
+	"github.com/teleclimber/DropServer/cmd/ds-host/accesslog"
 	"github.com/teleclimber/DropServer/cmd/ds-host/appops"
 	"github.com/teleclimber/DropServer/cmd/ds-host/appspacelogger"
 	"github.com/teleclimber/DropServer/cmd/ds-host/appspacelogin"
@@ -25,27 +26,41 @@ import (
 	"github.com/teleclimber/DropServer/cmd/ds-host/domaincontroller"
 	"github.com/teleclimber/DropServer/cmd/ds-host/ds2ds"
 	"github.com/teleclimber/DropServer/cmd/ds-host/events"
+	"github.com/teleclimber/DropServer/cmd/ds-host/mailer"
 	"github.com/teleclimber/DropServer/cmd/ds-host/migrate"
+	"github.com/teleclimber/DropServer/cmd/ds-host/models/accesslogmodel"
 	"github.com/teleclimber/DropServer/cmd/ds-host/models/appfilesmodel"
 	"github.com/teleclimber/DropServer/cmd/ds-host/models/appmodel"
 	"github.com/teleclimber/DropServer/cmd/ds-host/models/appspacefilesmodel"
+	"github.com/teleclimber/DropServer/cmd/ds-host/models/appspacelogmodel"
 	"github.com/teleclimber/DropServer/cmd/ds-host/models/appspacemodel"
+	"github.com/teleclimber/DropServer/cmd/ds-host/models/appspaceoutboundmodel"
+	"github.com/teleclimber/DropServer/cmd/ds-host/models/appspacequotamodel"
 	"github.com/teleclimber/DropServer/cmd/ds-host/models/appspacetsnetmodel"
+	"github.com/teleclimber/DropServer/cmd/ds-host/models/backupkeymodel"
+	"github.com/teleclimber/DropServer/cmd/ds-host/models/backupschedulemodel"
 	"github.com/teleclimber/DropServer/cmd/ds-host/models/contactmodel"
 	"github.com/teleclimber/DropServer/cmd/ds-host/models/cookiemodel"
 	"github.com/teleclimber/DropServer/cmd/ds-host/models/dropidmodel"
 	"github.com/teleclimber/DropServer/cmd/ds-host/models/migrationjobmodel"
+	"github.com/teleclimber/DropServer/cmd/ds-host/models/passwordresetmodel"
 	"github.com/teleclimber/DropServer/cmd/ds-host/models/remoteappspacemodel"
 	"github.com/teleclimber/DropServer/cmd/ds-host/models/sandboxruns"
 	"github.com/teleclimber/DropServer/cmd/ds-host/models/settingsmodel"
+	"github.com/teleclimber/DropServer/cmd/ds-host/models/twofactormodel"
+	"github.com/teleclimber/DropServer/cmd/ds-host/models/usagemodel"
 	"github.com/teleclimber/DropServer/cmd/ds-host/models/userinvitationmodel"
 	"github.com/teleclimber/DropServer/cmd/ds-host/models/usermodel"
+	"github.com/teleclimber/DropServer/cmd/ds-host/ratelimit"
 	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+	"github.com/teleclimber/DropServer/cmd/ds-host/responsecache"
 	"github.com/teleclimber/DropServer/cmd/ds-host/runtimeconfig"
 	"github.com/teleclimber/DropServer/cmd/ds-host/sandbox"
 	"github.com/teleclimber/DropServer/cmd/ds-host/sandboxproxy"
 	"github.com/teleclimber/DropServer/cmd/ds-host/sandboxservices"
 	"github.com/teleclimber/DropServer/cmd/ds-host/server"
+	"github.com/teleclimber/DropServer/cmd/ds-host/twofactor"
+	"github.com/teleclimber/DropServer/cmd/ds-host/usage"
 	"github.com/teleclimber/DropServer/cmd/ds-host/userroutes"
 	"github.com/teleclimber/DropServer/cmd/ds-host/views"
 	"github.com/teleclimber/DropServer/internal/checkinject"
@@ -87,6 +102,8 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	logLevel, _ := record.ParseLogLevel(runtimeConfig.LogLevel) // validated with config
+	record.SetLogFormat(logLevel, runtimeConfig.LogFormat)
 
 	record.NewDsLogger().Log(fmt.Sprintf("ds-host version: %s on %s", cmd_version, runtime.GOOS))
 
@@ -97,6 +114,8 @@ func main() {
 		record.NewDsLogger().Log(fmt.Sprintf("ds-host is running as: %s %s (%s)", currentUser.Username, currentUser.Name, currentUser.Uid))
 	}
 
+	metrics := &record.Metrics{}
+	metrics.Init()
 	if runtimeConfig.Prometheus.Enable && !*migrateFlag {
 		record.ExposePromMetrics(*runtimeConfig)
 	}
@@ -143,6 +162,7 @@ func main() {
 	migrationJobEvents := &events.MigrationJobEvents{}
 	appGetterEvents := &events.AppGetterEvents{}
 	appUrlDataEvents := &events.AppUrlDataEvents{}
+	routeHitEvents := &events.AppspaceRouteHitEvents{}
 
 	// models
 	settingsModel := &settingsmodel.SettingsModel{
@@ -161,6 +181,19 @@ func main() {
 		DB: db}
 	cookieModel.PrepareStatements()
 
+	twoFactorModel := &twofactormodel.TwoFactorModel{
+		DB: db}
+	twoFactorModel.PrepareStatements()
+
+	passwordResetModel := &passwordresetmodel.PasswordResetModel{
+		DB: db}
+	passwordResetModel.PrepareStatements()
+
+	mailer := &mailer.Mailer{
+		Config:    runtimeConfig,
+		UserModel: userModel}
+	mailer.Init()
+
 	contactModel := &contactmodel.ContactModel{
 		DB: db}
 	contactModel.PrepareStatements()
@@ -200,12 +233,50 @@ func main() {
 		DB: db}
 	sandboxRunsModel.PrepareStatements()
 
+	backupScheduleModel := &backupschedulemodel.BackupScheduleModel{
+		DB: db}
+	backupScheduleModel.PrepareStatements()
+
+	backupKeyModel := &backupkeymodel.BackupKeyModel{
+		DB: db}
+	backupKeyModel.PrepareStatements()
+
+	appspaceQuotaModel := &appspacequotamodel.AppspaceQuotaModel{
+		DB: db}
+	appspaceQuotaModel.PrepareStatements()
+
+	usageModel := &usagemodel.UsageModel{
+		DB: db}
+	usageModel.PrepareStatements()
+
+	usageAccounting := &usage.Accounting{
+		Config:      runtimeConfig,
+		UsageModel:  usageModel,
+		SandboxRuns: sandboxRunsModel,
+	}
+
+	accessLogModel := &accesslogmodel.AccessLogModel{
+		DB: db}
+	accessLogModel.PrepareStatements()
+
+	accessLog := &accesslog.AccessLog{
+		Config:         runtimeConfig,
+		RouteHitEvents: routeHitEvents,
+		AccessLogModel: accessLogModel,
+	}
+
 	appLogger := &appspacelogger.AppLogger{
-		AppLocation2Path: appLocation2Path}
+		AppLocation2Path: appLocation2Path,
+		Config:           runtimeConfig}
 	appLogger.Init()
 
+	appspaceLogModel := &appspacelogmodel.AppspaceLogModel{
+		DB: db}
+	appspaceLogModel.PrepareStatements()
+
 	appspaceLogger := &appspacelogger.AppspaceLogger{
-		AppspaceModel: appspaceModel,
+		AppspaceModel:    appspaceModel,
+		AppspaceLogModel: appspaceLogModel,
 		//AppspaceStatus: see below,
 		Config: runtimeConfig}
 	appspaceLogger.Init()
@@ -244,18 +315,34 @@ func main() {
 		if err != nil {
 			panic(err)
 		}
+		metrics.CGroups = cGroups
 	}
 
+	appspaceOutboundModel := &appspaceoutboundmodel.AppspaceOutboundModel{
+		DB: db}
+	appspaceOutboundModel.PrepareStatements()
+
+	outboundPolicy := &appspaceops.OutboundPolicy{
+		AppModel:              appModel,
+		AppspaceOutboundModel: appspaceOutboundModel,
+		//SandboxManager: see below,
+		AppspaceLogger: appspaceLogger}
+
 	sandboxManager := &sandbox.Manager{
 		SandboxRuns:           sandboxRunsModel,
+		OutboundPolicy:        outboundPolicy,
 		CGroups:               cGroups,
+		AppspaceQuota:         appspaceQuotaModel,
 		AppLogger:             appLogger,
 		AppspaceLogger:        appspaceLogger,
 		AppLocation2Path:      appLocation2Path,
 		AppspaceLocation2Path: appspaceLocation2Path,
 		Config:                runtimeConfig,
+		Metrics:               metrics,
 	}
 
+	outboundPolicy.SandboxManager = sandboxManager
+
 	domainController := &domaincontroller.DomainController{
 		Config:        runtimeConfig,
 		AppspaceModel: appspaceModel,
@@ -271,6 +358,15 @@ func main() {
 		SandboxManager: sandboxManager,
 		AppspaceLogger: appspaceLogger,
 	}
+	remoteBackups := &appspaceops.RemoteBackups{
+		Config:                runtimeConfig,
+		AppspaceModel:         appspaceModel,
+		AppspaceLogger:        appspaceLogger,
+		AppspaceLocation2Path: appspaceLocation2Path,
+		AppspaceFilesModel:    appspaceFilesModel,
+		BackupKeyModel:        backupKeyModel,
+	}
+	remoteBackups.Init()
 	backupAppspace := &appspaceops.BackupAppspace{
 		AppspaceModel:         appspaceModel,
 		SandboxManager:        sandboxManager,
@@ -278,6 +374,7 @@ func main() {
 		AppspaceMetaDB:        appspaceMetaDb,
 		AppspaceLogger:        appspaceLogger,
 		AppspaceLocation2Path: appspaceLocation2Path,
+		RemoteBackups:         remoteBackups,
 	}
 	restoreAppspace := &appspaceops.RestoreAppspace{
 		InfoModel:             appspaceInfoModel,
@@ -288,9 +385,18 @@ func main() {
 		AppspaceMetaDB:        appspaceMetaDb,
 		AppspaceLogger:        appspaceLogger,
 		AppspaceLocation2Path: appspaceLocation2Path,
+		RemoteBackups:         remoteBackups,
 	}
 	restoreAppspace.Init()
 
+	backupScheduler := &appspaceops.BackupScheduler{
+		BackupScheduleModel: backupScheduleModel,
+		AppspaceModel:       appspaceModel,
+		AppspaceFilesModel:  appspaceFilesModel,
+		BackupAppspace:      backupAppspace,
+		AppspaceLogger:      appspaceLogger,
+	}
+
 	migrationJobCtl := &appspaceops.MigrationJobController{
 		AppspaceModel:     appspaceModel,
 		AppModel:          appModel,
@@ -300,7 +406,9 @@ func main() {
 		RestoreAppspace:   restoreAppspace,
 		AppspaceLogger:    appspaceLogger,
 		AppspaceStatus:    nil, // added below
-		MigrationJobModel: migrationJobModel}
+		MigrationJobModel: migrationJobModel,
+		Metrics:           metrics,
+		Mailer:            mailer}
 
 	createAppspace := &appspaceops.CreateAppspace{
 		AppspaceModel:          appspaceModel,
@@ -310,17 +418,25 @@ func main() {
 		UserModel:              userModel,
 		DomainController:       domainController,
 		MigrationJobModel:      migrationJobModel,
-		MigrationJobController: migrationJobCtl}
+		MigrationJobController: migrationJobCtl,
+		OutboundPolicy:         outboundPolicy}
 
 	deleteAppspace := &appspaceops.DeleteAppspace{
-		AppspaceStatus:     nil,
-		AppspaceModel:      appspaceModel,
-		AppspaceFilesModel: appspaceFilesModel,
-		AppspaceTSNetModel: appspaceTSNetModel,
-		DomainController:   domainController,
-		MigrationJobModel:  migrationJobModel,
-		SandboxManager:     sandboxManager,
-		AppspaceLogger:     appspaceLogger,
+		AppspaceStatus:        nil,
+		AppspaceModel:         appspaceModel,
+		AppspaceFilesModel:    appspaceFilesModel,
+		AppspaceTSNetModel:    appspaceTSNetModel,
+		BackupScheduleModel:   backupScheduleModel,
+		BackupKeyModel:        backupKeyModel,
+		AppspaceQuotaModel:    appspaceQuotaModel,
+		AppspaceLogModel:      appspaceLogModel,
+		AccessLogModel:        accessLogModel,
+		AppspaceOutboundModel: appspaceOutboundModel,
+		DomainController:      domainController,
+		MigrationJobModel:     migrationJobModel,
+		SandboxManager:        sandboxManager,
+		AppspaceLogger:        appspaceLogger,
+		Metrics:               metrics,
 	}
 
 	manageAppspaceUsers := &appspaceops.ManageUsers{
@@ -346,19 +462,63 @@ func main() {
 		SandboxManager:   sandboxManager,
 		AppRoutes:        AppRoutes,
 		AppGetterEvents:  appGetterEvents,
+		Metrics:          metrics,
 	}
 	appGetter.Init()
 
+	exportAppspace := &appspaceops.ExportAppspace{
+		AppspaceModel:         appspaceModel,
+		AppModel:              appModel,
+		AppFilesModel:         appFilesModel,
+		AppspaceUserModel:     appspaceUserModel,
+		AppspaceTSNetModel:    appspaceTSNetModel,
+		AppspaceOutboundModel: appspaceOutboundModel,
+		BackupKeyModel:        backupKeyModel,
+		AppspaceFilesModel:    appspaceFilesModel,
+	}
+	importAppspace := &appspaceops.ImportAppspace{
+		AppModel:           appModel,
+		AppFilesModel:      appFilesModel,
+		AppGetter:          appGetter,
+		CreateAppspace:     createAppspace,
+		DeleteAppspace:     deleteAppspace,
+		RestoreAppspace:    restoreAppspace,
+		UserModel:          userModel,
+		AppspaceUserModel:  appspaceUserModel,
+		AppspaceTSNetModel: appspaceTSNetModel,
+		DomainController:   domainController,
+		OutboundPolicy:     outboundPolicy,
+	}
+	importAppspace.Init()
+
 	// auth
 	authenticator := &authenticator.Authenticator{
 		CookieModel: cookieModel,
 		Config:      runtimeConfig}
+	// ClientAddr: below
 
 	ds2ds := &ds2ds.DS2DS{
 		Config: runtimeConfig,
 	}
 	ds2ds.Init()
 
+	transferAppspace := &appspaceops.TransferAppspace{
+		AppspaceModel: appspaceModel,
+		//AppspaceStatus: see below
+		BackupAppspace: backupAppspace,
+		ExportAppspace: exportAppspace,
+		PauseAppspace:  pauseAppspace,
+		AppspaceLogger: appspaceLogger,
+		DS2DS:          ds2ds,
+	}
+	transferAppspace.Init()
+	receiveTransfer := &appspaceops.ReceiveTransfer{
+		Config:           runtimeConfig,
+		DomainController: domainController,
+		ImportAppspace:   importAppspace,
+	}
+	receiveTransfer.Init()
+
 	v0tokenManager := &appspacelogin.V0TokenManager{
 		Config:            *runtimeConfig,
 		DS2DS:             ds2ds,
@@ -407,6 +567,7 @@ func main() {
 	appspaceMetaDb.AppspaceStatus = appspaceStatus
 	appspaceLogger.AppspaceStatus = appspaceStatus
 	deleteAppspace.AppspaceStatus = appspaceStatus
+	transferAppspace.AppspaceStatus = appspaceStatus
 
 	migrationMinder := &appspacestatus.MigrationMinder{
 		AppModel: appModel,
@@ -416,6 +577,7 @@ func main() {
 	if runtimeConfig.ManageTLSCertificates.Enable {
 		certificateManager = &certificatemanager.CertficateManager{
 			Config: runtimeConfig,
+			Mailer: mailer,
 		}
 		certificateManager.Init()
 		domainController.CertificateManager = certificateManager
@@ -430,13 +592,30 @@ func main() {
 		Config: runtimeConfig}
 	views.PrepareTemplates()
 
+	loginLimiter := &ratelimit.Limiter{
+		Config: runtimeConfig}
+	loginLimiter.Init()
+	authenticator.ClientAddr = loginLimiter
+
+	twoFactor := &twofactor.TwoFactor{
+		Config:         runtimeConfig,
+		SettingsModel:  settingsModel,
+		UserModel:      userModel,
+		TwoFactorModel: twoFactorModel}
+
 	// Create routes
 	authRoutes := &userroutes.AuthRoutes{
+		Config:              runtimeConfig,
+		LoginLimiter:        loginLimiter,
 		Views:               views,
 		SettingsModel:       settingsModel,
 		UserModel:           userModel,
 		UserInvitationModel: userInvitationModel,
 		Authenticator:       authenticator,
+		TwoFactor:           twoFactor,
+		PasswordResetModel:  passwordResetModel,
+		CookieModel:         cookieModel,
+		Mailer:              mailer,
 		SetupKey:            setupKey}
 
 	appspaceLoginRoutes := &userroutes.AppspaceLoginRoutes{
@@ -452,6 +631,13 @@ func main() {
 		UserModel:           userModel,
 		SettingsModel:       settingsModel,
 		UserInvitationModel: userInvitationModel,
+		AppspaceModel:       appspaceModel,
+		AppspaceQuotaModel:  appspaceQuotaModel,
+		SandboxManager:      sandboxManager,
+		UsageModel:          usageModel,
+		LoginLimiter:        loginLimiter,
+		Mailer:              mailer,
+		CookieModel:         cookieModel,
 		//UserTSNet: below
 	}
 
@@ -473,26 +659,71 @@ func main() {
 		AppspaceFilesModel:    appspaceFilesModel,
 		BackupAppspace:        backupAppspace,
 		AppspaceLocation2Path: appspaceLocation2Path,
+		BackupScheduleModel:   backupScheduleModel,
+		BackupScheduler:       backupScheduler,
+		RemoteBackups:         remoteBackups,
+		BackupKeyModel:        backupKeyModel,
+		ExportAppspace:        exportAppspace,
 	}
 	restoreAppspaceRoutes := &userroutes.AppspaceRestoreRoutes{
 		RestoreAppspace: restoreAppspace,
+		LoginLimiter:    loginLimiter,
+	}
+	importAppspaceRoutes := &userroutes.AppspaceImportRoutes{
+		ImportAppspace: importAppspace,
+		DropIDModel:    dropIDModel,
+		LoginLimiter:   loginLimiter,
+	}
+	appspaceTransferRoutes := &userroutes.AppspaceTransferRoutes{
+		TransferAppspace: transferAppspace,
+		AppspaceModel:    appspaceModel,
+	}
+	transferTicketRoutes := &userroutes.TransferTicketRoutes{
+		ReceiveTransfer: receiveTransfer,
+		DropIDModel:     dropIDModel,
+	}
+	appspaceQuotaRoutes := &userroutes.AppspaceQuotaRoutes{
+		AppspaceQuotaModel: appspaceQuotaModel,
+		SandboxManager:     sandboxManager,
+	}
+	appspaceLogRoutes := &userroutes.AppspaceLogRoutes{
+		Config:           runtimeConfig,
+		AppspaceLogger:   appspaceLogger,
+		AppspaceLogModel: appspaceLogModel,
+	}
+	accessLogRoutes := &userroutes.AppspaceAccessLogRoutes{
+		Config:         runtimeConfig,
+		AccessLog:      accessLog,
+		AccessLogModel: accessLogModel,
+	}
+	appspaceOutboundRoutes := &userroutes.AppspaceOutboundRoutes{
+		OutboundPolicy:        outboundPolicy,
+		AppspaceOutboundModel: appspaceOutboundModel,
 	}
 	userAppspaceRoutes := &userroutes.AppspaceRoutes{
-		Config:                *runtimeConfig,
-		AppspaceUserRoutes:    userAppspaceUserRoutes,
-		AppspaceModel:         appspaceModel,
-		AppspaceTSNetModel:    appspaceTSNetModel,
-		AppspaceStatus:        appspaceStatus,
-		AppspaceExportRoutes:  exportAppspaceRoutes,
-		AppspaceRestoreRoutes: restoreAppspaceRoutes,
-		DropIDModel:           dropIDModel,
-		MigrationMinder:       migrationMinder,
-		CreateAppspace:        createAppspace,
-		PauseAppspace:         pauseAppspace,
-		DeleteAppspace:        deleteAppspace,
-		AppspaceLogger:        appspaceLogger,
-		SandboxRunsModel:      sandboxRunsModel,
-		AppModel:              appModel}
+		Config:                 *runtimeConfig,
+		AppspaceUserRoutes:     userAppspaceUserRoutes,
+		AppspaceModel:          appspaceModel,
+		AppspaceTSNetModel:     appspaceTSNetModel,
+		AppspaceStatus:         appspaceStatus,
+		AppspaceExportRoutes:   exportAppspaceRoutes,
+		AppspaceRestoreRoutes:  restoreAppspaceRoutes,
+		AppspaceImportRoutes:   importAppspaceRoutes,
+		AppspaceTransferRoutes: appspaceTransferRoutes,
+		AppspaceQuotaRoutes:    appspaceQuotaRoutes,
+		AppspaceLogRoutes:      appspaceLogRoutes,
+		AccessLogRoutes:        accessLogRoutes,
+		AppspaceOutboundRoutes: appspaceOutboundRoutes,
+		AppspaceSessionRoutes:  &userroutes.AppspaceSessionRoutes{CookieModel: cookieModel},
+		TransferTicketRoutes:   transferTicketRoutes,
+		DropIDModel:            dropIDModel,
+		MigrationMinder:        migrationMinder,
+		CreateAppspace:         createAppspace,
+		PauseAppspace:          pauseAppspace,
+		DeleteAppspace:         deleteAppspace,
+		SandboxRunsModel:       sandboxRunsModel,
+		OutboundPolicy:         outboundPolicy,
+		AppModel:               appModel}
 
 	remoteAppspaceRoutes := &userroutes.RemoteAppspaceRoutes{
 		RemoteAppspaceModel: remoteAppspaceModel,
@@ -518,6 +749,7 @@ func main() {
 		AppspaceModel:          appspaceModel,
 		MigrationJobModel:      migrationJobModel,
 		MigrationJobController: migrationJobCtl,
+		OutboundPolicy:         outboundPolicy,
 	}
 
 	userRoutes := &userroutes.UserRoutes{
@@ -531,12 +763,17 @@ func main() {
 		DomainRoutes:              domainNameRoutes,
 		DropIDRoutes:              dropIDRoutes,
 		MigrationJobRoutes:        migrationJobRoutes,
+		V0TransferRoutes:          &userroutes.V0TransferRoutes{ReceiveTransfer: receiveTransfer},
+		UsageRoutes:               &userroutes.UsageRoutes{UsageModel: usageModel},
+		TwoFactorRoutes:           &userroutes.TwoFactorRoutes{TwoFactor: twoFactor},
+		SessionRoutes:             &userroutes.SessionRoutes{CookieModel: cookieModel},
 		AppspaceStatusEvents:      appspaceStatusEvents,
 		AppspaceTSNetStatusEvents: appspaceTSNetStatusEvents,
 		AppspaceTSNetPeersEvents:  appspaceTSNetPeersEvents,
 		MigrationJobEvents:        migrationJobEvents,
 		AppGetterEvents:           appGetterEvents,
 		UserModel:                 userModel,
+		CookieModel:               cookieModel,
 		UserTSNetStatusEvents:     userTSNetEvents,
 		UserTSNetPeersEvents:      userTSNetPeersEvents,
 		Views:                     views}
@@ -559,9 +796,15 @@ func main() {
 			Authenticator:  authenticator,
 			V0RequestToken: v0requestToken,
 			V0TokenManager: v0tokenManager,
+			LoginLimiter:   loginLimiter,
 		},
 	}
 
+	responseCache := &responsecache.ResponseCache{
+		AppspaceStatusEvents: appspaceStatusEvents,
+		AppspaceFilesEvents:  appspaceFilesEvents}
+	responseCache.Init()
+
 	appspaceRouter := &appspacerouter.AppspaceRouter{
 		AppModel:              appModel,
 		AppspaceStatus:        appspaceStatus,
@@ -569,9 +812,12 @@ func main() {
 		AppRoutes:             AppRoutes,
 		AppspaceUserModel:     appspaceUserModel,
 		SandboxProxy:          sandboxProxy,
+		ResponseCache:         responseCache,
 		Config:                runtimeConfig,
 		AppLocation2Path:      appLocation2Path,
-		AppspaceLocation2Path: appspaceLocation2Path}
+		AppspaceLocation2Path: appspaceLocation2Path,
+		RouteHitEvents:        routeHitEvents,
+		Metrics:               metrics}
 	appspaceRouter.Init()
 	appspaceStatus.AppspaceRouter = appspaceRouter
 
@@ -610,6 +856,7 @@ func main() {
 	}
 	userTSNet.Connect(false)
 	adminRoutes.UserTSNet = userTSNet
+	metrics.UserTSNet = userTSNet
 
 	appspaceTSNet := &server.AppspaceTSNet{
 		Config:                    runtimeConfig,
@@ -624,6 +871,7 @@ func main() {
 	userAppspaceRoutes.AppspaceTSNet = appspaceTSNet
 	manageAppspaceUsers.AppspaceTSNet = appspaceTSNet
 	deleteAppspace.AppspaceTSNet = appspaceTSNet
+	metrics.AppspaceTSNet = appspaceTSNet
 
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
@@ -639,7 +887,17 @@ func main() {
 
 		migrationJobCtl.Stop() // We should make all stop things async and have a waitgroup for them.
 
+		backupScheduler.Stop()
+		remoteBackups.Stop()
+
+		usageAccounting.Stop()
+		responseCache.Stop()
+		loginLimiter.Stop()
+		authenticator.Stop()
+
 		restoreAppspace.DeleteAll()
+		importAppspace.DeleteAll()
+		receiveTransfer.DeleteAll()
 
 		remoteAppGetter.Stop()
 		appGetter.Stop()
@@ -649,6 +907,8 @@ func main() {
 		appspaceTSNet.StopAll()
 
 		mainServer.Shutdown()
+		// after the server so that hits of the last requests are recorded
+		accessLog.Stop()
 
 		record.StopPromMetrics()
 
@@ -671,6 +931,13 @@ func main() {
 	// start things up
 	migrationJobCtl.Start() // TODO: add delay, maybe set in runtimeconfig for first job to run
 
+	backupScheduler.Start()
+	remoteBackups.Start()
+
+	usageAccounting.Start()
+	accessLog.Start()
+	authenticator.Start()
+
 	mainServer.Start()
 
 	manageAppspaceUsers.Init()
@@ -1,6 +1,7 @@
 package events
 
 import (
+	"sync"
 	"time"
 
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
@@ -198,39 +199,53 @@ func (e *AppspaceTSNetPeersEvents) Send(appspaceID domain.AppspaceID) {
 // Appspace Route Event
 // TODO: Shouldn't subscribers be for specific appspaces?
 
-// AppspaceRouteHitEvents handles appspace pause and unpause events
+// AppspaceRouteHitEvents sends route hit events
+// Subscribers should use a buffered channel:
+// events are dropped for subscribers that are not keeping up,
+// so that a slow subscriber never holds up a request.
 type AppspaceRouteHitEvents struct {
+	subsMux     sync.Mutex
 	subscribers []chan<- *domain.AppspaceRouteHitEvent
 }
 
-// Send sends an appspace paused or unpaused event
+// Send sends a route hit event without blocking
 // Event's timestamp is set if needed
 func (e *AppspaceRouteHitEvents) Send(routeEvent *domain.AppspaceRouteHitEvent) {
 	if routeEvent.Timestamp.IsZero() {
 		routeEvent.Timestamp = time.Now()
 	}
+	e.subsMux.Lock()
+	defer e.subsMux.Unlock()
 	for _, ch := range e.subscribers {
-		ch <- routeEvent
+		select {
+		case ch <- routeEvent:
+		default:
+		}
 	}
 }
 
-// Subscribe to an event for when an appspace is paused or unpaused
+// Subscribe to route hit events
 func (e *AppspaceRouteHitEvents) Subscribe(ch chan<- *domain.AppspaceRouteHitEvent) {
+	e.subsMux.Lock()
+	defer e.subsMux.Unlock()
 	e.removeSubscriber(ch)
 	e.subscribers = append(e.subscribers, ch)
 }
 
-// Unsubscribe to an event for when an appspace is paused or unpaused
+// Unsubscribe from route hit events
+// Once it returns no more events are sent on the channel.
 func (e *AppspaceRouteHitEvents) Unsubscribe(ch chan<- *domain.AppspaceRouteHitEvent) {
+	e.subsMux.Lock()
+	defer e.subsMux.Unlock()
 	e.removeSubscriber(ch)
 }
 
 func (e *AppspaceRouteHitEvents) removeSubscriber(ch chan<- *domain.AppspaceRouteHitEvent) {
-	// get a feeling you'll need a mutex to cover subscribers?
 	for i, c := range e.subscribers {
 		if c == ch {
 			e.subscribers[i] = e.subscribers[len(e.subscribers)-1]
 			e.subscribers = e.subscribers[:len(e.subscribers)-1]
+			return
 		}
 	}
 }
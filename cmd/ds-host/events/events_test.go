@@ -0,0 +1,29 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+func TestRouteHitSendDropsWhenFull(t *testing.T) {
+	e := &AppspaceRouteHitEvents{}
+	ch := make(chan *domain.AppspaceRouteHitEvent, 1)
+	e.Subscribe(ch)
+
+	// the second event would block, it is dropped instead
+	e.Send(&domain.AppspaceRouteHitEvent{AppspaceID: domain.AppspaceID(1)})
+	e.Send(&domain.AppspaceRouteHitEvent{AppspaceID: domain.AppspaceID(2)})
+	if len(ch) != 1 {
+		t.Fatalf("expected one event, got %v", len(ch))
+	}
+	if ev := <-ch; ev.AppspaceID != domain.AppspaceID(1) || ev.Timestamp.IsZero() {
+		t.Errorf("unexpected event: %v", ev)
+	}
+
+	e.Unsubscribe(ch)
+	e.Send(&domain.AppspaceRouteHitEvent{AppspaceID: domain.AppspaceID(3)})
+	if len(ch) != 0 {
+		t.Error("expected no event after unsubscribe")
+	}
+}
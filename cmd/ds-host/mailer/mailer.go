@@ -0,0 +1,229 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+)
+
+// Each template defines a "subject" and the rest of the file is the body.
+//
+//go:embed templates/*.txt
+var templateFiles embed.FS
+
+// ErrNotConfigured is returned when sending while no mail type is configured
+var ErrNotConfigured = errors.New("mail is not configured")
+
+// sender delivers a fully formed message
+type sender interface {
+	send(from string, to string, msg []byte) error
+}
+
+// Mailer sends templated email messages
+// using the transport set in the runtime config.
+type Mailer struct {
+	Config    *domain.RuntimeConfig `checkinject:"required"`
+	UserModel interface {
+		GetFromID(domain.UserID) (domain.User, error)
+		GetAllAdmins() ([]domain.UserID, error)
+	} `checkinject:"required"`
+
+	from      *mail.Address
+	sender    sender
+	templates *template.Template
+}
+
+// Init parses the templates and sets up the configured sender
+func (m *Mailer) Init() {
+	m.templates = template.New("")
+	entries, err := templateFiles.ReadDir("templates")
+	if err != nil {
+		panic(err)
+	}
+	for _, e := range entries {
+		b, err := templateFiles.ReadFile("templates/" + e.Name())
+		if err != nil {
+			panic(err)
+		}
+		name := strings.TrimSuffix(e.Name(), ".txt")
+		t := template.Must(template.New(name).Parse(string(b)))
+		if t.Lookup("subject") == nil {
+			panic("mail template has no subject: " + name)
+		}
+		template.Must(m.templates.AddParseTree(name, t.Tree))
+		template.Must(m.templates.AddParseTree(name+"-subject", t.Lookup("subject").Tree))
+	}
+
+	cfg := m.Config.Mail
+	switch cfg.Type {
+	case "smtp":
+		m.sender = &smtpSender{Config: m.Config}
+	case "maildir":
+		s := &maildirSender{dir: cfg.Dir}
+		err := s.init()
+		if err != nil {
+			m.getLogger("Init() maildir").Error(err)
+			panic(err)
+		}
+		m.sender = s
+	default:
+		return
+	}
+	m.from, err = mail.ParseAddress(cfg.From)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Enabled is true if email can be sent
+func (m *Mailer) Enabled() bool {
+	return m.sender != nil
+}
+
+// templateData is passed to templates.
+// Data is whatever the caller passed.
+type templateData struct {
+	SiteURL string
+	Domain  string
+	Data    any
+}
+
+// Send renders the named template with data and sends it to the address
+func (m *Mailer) Send(to string, name string, data any) error {
+	if !m.Enabled() {
+		return ErrNotConfigured
+	}
+	addr, err := mail.ParseAddress(to)
+	if err != nil {
+		return err
+	}
+	subject, body, err := m.render(name, data)
+	if err != nil {
+		m.getLogger("Send() render " + name).Error(err)
+		return err
+	}
+	msg, err := m.buildMessage(addr, subject, body)
+	if err != nil {
+		return err
+	}
+	err = m.sender.send(m.from.Address, addr.Address, msg)
+	if err != nil {
+		m.getLogger("Send() " + name).AddNote(m.Config.Mail.Type).Error(err)
+		return err
+	}
+	m.getLogger("Send()").Debug(fmt.Sprintf("sent %s to %s", name, addr.Address))
+	return nil
+}
+
+// SendToUser sends the message to the user's email, if they have one.
+// Like SendToAdmins it is meant for notifications, so it does nothing if mail is not configured.
+func (m *Mailer) SendToUser(userID domain.UserID, name string, data any) error {
+	if !m.Enabled() {
+		return nil
+	}
+	user, err := m.UserModel.GetFromID(userID)
+	if err != nil {
+		return err
+	}
+	if user.Email == "" {
+		return nil
+	}
+	return m.Send(user.Email, name, data)
+}
+
+// SendToAdmins sends the message to every admin that has an email.
+// It returns the last error encountered.
+func (m *Mailer) SendToAdmins(name string, data any) error {
+	if !m.Enabled() {
+		return nil
+	}
+	adminIDs, err := m.UserModel.GetAllAdmins()
+	if err != nil {
+		return err
+	}
+	var retErr error
+	for _, id := range adminIDs {
+		err = m.SendToUser(id, name, data)
+		if err != nil {
+			retErr = err
+		}
+	}
+	return retErr
+}
+
+func (m *Mailer) render(name string, data any) (string, string, error) {
+	t := m.templates.Lookup(name)
+	if t == nil {
+		return "", "", fmt.Errorf("no mail template named %s", name)
+	}
+	d := templateData{
+		SiteURL: fmt.Sprintf("%s://%s%s", m.Config.ExternalAccess.Scheme, m.Config.Exec.UserRoutesDomain, m.Config.Exec.PortString),
+		Domain:  m.Config.ExternalAccess.Domain,
+		Data:    data}
+
+	var subject, body bytes.Buffer
+	err := m.templates.ExecuteTemplate(&subject, name+"-subject", d)
+	if err != nil {
+		return "", "", err
+	}
+	err = t.Execute(&body, d)
+	if err != nil {
+		return "", "", err
+	}
+	// subject must stay on one line
+	return strings.Join(strings.Fields(subject.String()), " "), strings.TrimSpace(body.String()), nil
+}
+
+func (m *Mailer) buildMessage(to *mail.Address, subject string, body string) ([]byte, error) {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	header := func(k, v string) {
+		b.WriteString(k + ": " + v + "\r\n")
+	}
+	header("From", m.from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), m.Config.Exec.UserRoutesDomain))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	b.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&b)
+	_, err = qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n")))
+	if err != nil {
+		return nil, err
+	}
+	err = qp.Close()
+	if err != nil {
+		return nil, err
+	}
+	b.WriteString("\r\n")
+	return b.Bytes(), nil
+}
+
+func (m *Mailer) getLogger(note string) *record.DsLogger {
+	r := record.NewDsLogger().AddNote("Mailer")
+	if note != "" {
+		r.AddNote(note)
+	}
+	return r
+}
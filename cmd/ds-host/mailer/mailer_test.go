@@ -0,0 +1,165 @@
+package mailer
+
+import (
+	"bytes"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+)
+
+func getConfig(dir string) *domain.RuntimeConfig {
+	rtc := &domain.RuntimeConfig{}
+	rtc.ExternalAccess.Scheme = "https"
+	rtc.ExternalAccess.Domain = "example.com"
+	rtc.Exec.UserRoutesDomain = "dropid.example.com"
+	rtc.Mail.Type = "maildir"
+	rtc.Mail.From = "Dropserver <ds@example.com>"
+	rtc.Mail.Dir = dir
+	return rtc
+}
+
+func readMessages(t *testing.T, dir string) []*mail.Message {
+	entries, err := os.ReadDir(filepath.Join(dir, "new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := []*mail.Message{}
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(dir, "new", e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := mail.ReadMessage(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func TestNotConfigured(t *testing.T) {
+	m := &Mailer{Config: &domain.RuntimeConfig{}}
+	m.Init()
+	if m.Enabled() {
+		t.Error("expected mailer to be disabled")
+	}
+	if err := m.Send("a@b.c", "invitation", nil); err != ErrNotConfigured {
+		t.Errorf("expected not configured, got %v", err)
+	}
+	if err := m.SendToAdmins("certificate-failed", nil); err != nil {
+		t.Errorf("expected notifications to do nothing, got %v", err)
+	}
+}
+
+func TestRenderAll(t *testing.T) {
+	m := &Mailer{Config: getConfig(t.TempDir())}
+	m.Init()
+
+	data := map[string]any{
+		"invitation": struct{ Email string }{"new@example.com"},
+		"password-reset": struct {
+			Link           string
+			ExpiresMinutes int
+		}{"https://dropid.example.com/login/new-password?token=abc", 60},
+		"migration-failed": struct {
+			AppspaceID domain.AppspaceID
+			Appspace   string
+			ToVersion  domain.Version
+			Error      string
+		}{3, "as.example.com", "1.2.3", "oops"},
+		"certificate-failed": struct{ Domain, Remaining, Error string }{"as.example.com", "10 days", "oops"},
+	}
+	for _, t2 := range m.templates.Templates() {
+		name := t2.Name()
+		if strings.HasSuffix(name, "-subject") || name == "subject" || name == "" {
+			continue
+		}
+		d, ok := data[name]
+		if !ok {
+			t.Errorf("no test data for template %s", name)
+			continue
+		}
+		subject, body, err := m.render(name, d)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if subject == "" || strings.Contains(subject, "\n") || body == "" {
+			t.Errorf("%s: bad subject or body: %q %q", name, subject, body)
+		}
+	}
+}
+
+func TestSendMaildir(t *testing.T) {
+	dir := t.TempDir()
+	m := &Mailer{Config: getConfig(dir)}
+	m.Init()
+
+	err := m.Send("new@example.com", "invitation", struct{ Email string }{"new@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := readMessages(t, dir)
+	if len(msgs) != 1 {
+		t.Fatalf("expected one message, got %v", len(msgs))
+	}
+	msg := msgs[0]
+	if msg.Header.Get("To") != "<new@example.com>" {
+		t.Errorf("unexpected To: %v", msg.Header.Get("To"))
+	}
+	if msg.Header.Get("Subject") != "You are invited to Dropserver at example.com" {
+		t.Errorf("unexpected Subject: %v", msg.Header.Get("Subject"))
+	}
+	body := new(bytes.Buffer)
+	body.ReadFrom(msg.Body)
+	if !strings.Contains(body.String(), "https://dropid.example.com/signup") {
+		t.Errorf("expected signup link in body: %v", body.String())
+	}
+}
+
+func TestSendBadAddress(t *testing.T) {
+	dir := t.TempDir()
+	m := &Mailer{Config: getConfig(dir)}
+	m.Init()
+
+	err := m.Send("a@b.c\r\nBcc: evil@example.com", "invitation", struct{ Email string }{"a@b.c"})
+	if err == nil {
+		t.Error("expected error on bad address")
+	}
+	if len(readMessages(t, dir)) != 0 {
+		t.Error("expected no message")
+	}
+}
+
+func TestSendToAdmins(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	userModel := testmocks.NewMockUserModel(mockCtrl)
+	userModel.EXPECT().GetAllAdmins().Return([]domain.UserID{1, 2}, nil)
+	userModel.EXPECT().GetFromID(domain.UserID(1)).Return(domain.User{UserID: 1, Email: "admin@example.com"}, nil)
+	userModel.EXPECT().GetFromID(domain.UserID(2)).Return(domain.User{UserID: 2}, nil) // tailnet-only admin
+
+	dir := t.TempDir()
+	m := &Mailer{
+		Config:    getConfig(dir),
+		UserModel: userModel}
+	m.Init()
+
+	err := m.SendToAdmins("certificate-failed", struct{ Domain, Remaining, Error string }{"as.example.com", "", "oops"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := readMessages(t, dir)
+	if len(msgs) != 1 || msgs[0].Header.Get("To") != "<admin@example.com>" {
+		t.Errorf("expected one message to the admin with an email, got %v", msgs)
+	}
+}
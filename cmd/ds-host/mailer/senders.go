@@ -0,0 +1,122 @@
+package mailer
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+const smtpTimeout = time.Minute
+
+// smtpSender sends each message over a new connection to the SMTP server
+type smtpSender struct {
+	Config *domain.RuntimeConfig
+}
+
+func (s *smtpSender) send(from string, to string, msg []byte) error {
+	cfg := s.Config.Mail.SMTP
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(int(cfg.Port)))
+	tlsConfig := &tls.Config{ServerName: cfg.Host}
+
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	var conn net.Conn
+	var err error
+	if cfg.TLS == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	err = c.Hello(s.Config.Exec.UserRoutesDomain)
+	if err != nil {
+		return err
+	}
+	if cfg.TLS == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("smtp server does not support STARTTLS")
+		}
+		err = c.StartTLS(tlsConfig)
+		if err != nil {
+			return err
+		}
+	}
+	if cfg.Username != "" {
+		err = c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host))
+		if err != nil {
+			return err
+		}
+	}
+	err = c.Mail(from)
+	if err != nil {
+		return err
+	}
+	err = c.Rcpt(to)
+	if err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// maildirSender writes messages to a maildir instead of sending them.
+// Messages are written to tmp/ then moved to new/ so readers never see partial files.
+type maildirSender struct {
+	dir string
+}
+
+func (s *maildirSender) init() error {
+	for _, d := range []string{"tmp", "new", "cur"} {
+		err := os.MkdirAll(filepath.Join(s.dir, d), 0700)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *maildirSender) send(from string, to string, msg []byte) error {
+	r := make([]byte, 8)
+	_, err := rand.Read(r)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d.%s.dropserver", time.Now().UnixNano(), hex.EncodeToString(r))
+	tmp := filepath.Join(s.dir, "tmp", name)
+	err = os.WriteFile(tmp, msg, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, "new", name))
+}
@@ -0,0 +1,31 @@
+package migrate
+
+// accessLogUp adds tables for the optional per-appspace access log
+func accessLogUp(args *stepArgs) error {
+	args.dbExec(`CREATE TABLE "appspace_access_log_settings" (
+		"appspace_id" INTEGER PRIMARY KEY,
+		"enabled" INTEGER NOT NULL DEFAULT 0,
+		"keep_days" INTEGER NOT NULL DEFAULT 0
+	)`)
+	args.dbExec(`CREATE TABLE "appspace_access_log" (
+		"entry_id" INTEGER PRIMARY KEY AUTOINCREMENT,
+		"appspace_id" INTEGER NOT NULL,
+		"time" DATETIME NOT NULL,
+		"method" TEXT NOT NULL,
+		"path" TEXT NOT NULL,
+		"route_id" TEXT NOT NULL,
+		"route_path" TEXT NOT NULL,
+		"route_type" TEXT NOT NULL,
+		"proxy_id" TEXT NOT NULL,
+		"authorized" INTEGER NOT NULL,
+		"status" INTEGER NOT NULL
+	)`)
+	args.dbExec(`CREATE INDEX appspace_access_log_time ON appspace_access_log (appspace_id, time)`)
+	return args.dbErr
+}
+
+func accessLogDown(args *stepArgs) error {
+	args.dbExec(`DROP TABLE "appspace_access_log"`)
+	args.dbExec(`DROP TABLE "appspace_access_log_settings"`)
+	return args.dbErr
+}
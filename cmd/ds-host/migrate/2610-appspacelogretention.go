@@ -0,0 +1,17 @@
+package migrate
+
+// appspaceLogRetentionUp adds a table for per-appspace
+// retention of archived log segments.
+func appspaceLogRetentionUp(args *stepArgs) error {
+	args.dbExec(`CREATE TABLE "appspace_log_retention" (
+		"appspace_id" INTEGER PRIMARY KEY,
+		"keep_archives" INTEGER NOT NULL DEFAULT 0,
+		"keep_days" INTEGER NOT NULL DEFAULT 0
+	)`)
+	return args.dbErr
+}
+
+func appspaceLogRetentionDown(args *stepArgs) error {
+	args.dbExec(`DROP TABLE "appspace_log_retention"`)
+	return args.dbErr
+}
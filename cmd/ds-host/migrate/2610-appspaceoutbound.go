@@ -0,0 +1,24 @@
+package migrate
+
+// appspaceOutboundUp adds a table for the outbound hosts
+// the owner approved for each appspace.
+// appspace_outbound_set records the appspaces whose owner has set
+// the approved hosts. Others, like those created before this migration,
+// can reach all hosts their app declares.
+func appspaceOutboundUp(args *stepArgs) error {
+	args.dbExec(`CREATE TABLE "appspace_outbound" (
+		"appspace_id" INTEGER NOT NULL,
+		"host" TEXT NOT NULL,
+		PRIMARY KEY (appspace_id, host)
+	)`)
+	args.dbExec(`CREATE TABLE "appspace_outbound_set" (
+		"appspace_id" INTEGER PRIMARY KEY
+	)`)
+	return args.dbErr
+}
+
+func appspaceOutboundDown(args *stepArgs) error {
+	args.dbExec(`DROP TABLE "appspace_outbound_set"`)
+	args.dbExec(`DROP TABLE "appspace_outbound"`)
+	return args.dbErr
+}
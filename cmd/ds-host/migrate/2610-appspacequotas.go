@@ -0,0 +1,32 @@
+package migrate
+
+// appspaceQuotasUp adds tables for per-appspace resource quotas
+// and for recording when appspaces run into their limits.
+func appspaceQuotasUp(args *stepArgs) error {
+	args.dbExec(`CREATE TABLE "appspace_quotas" (
+		"appspace_id" INTEGER NOT NULL,
+		"set_by" TEXT NOT NULL,
+		"memory_max_mb" INTEGER NOT NULL DEFAULT 0,
+		"cpu_max_percent" INTEGER NOT NULL DEFAULT 0,
+		"io_read_bps" INTEGER NOT NULL DEFAULT 0,
+		"io_write_bps" INTEGER NOT NULL DEFAULT 0,
+		"io_read_iops" INTEGER NOT NULL DEFAULT 0,
+		"io_write_iops" INTEGER NOT NULL DEFAULT 0,
+		"disk_max_mb" INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (appspace_id, set_by)
+	)`)
+	args.dbExec(`CREATE TABLE "appspace_limit_hits" (
+		"appspace_id" INTEGER NOT NULL,
+		"limit_name" TEXT NOT NULL,
+		"count" INTEGER NOT NULL,
+		"last_hit" DATETIME NOT NULL,
+		PRIMARY KEY (appspace_id, limit_name)
+	)`)
+	return args.dbErr
+}
+
+func appspaceQuotasDown(args *stepArgs) error {
+	args.dbExec(`DROP TABLE "appspace_limit_hits"`)
+	args.dbExec(`DROP TABLE "appspace_quotas"`)
+	return args.dbErr
+}
@@ -0,0 +1,13 @@
+package migrate
+
+// appspaceRedirectUp adds a column to store the address an appspace
+// was transferred to, so that its old domain can redirect visitors there.
+func appspaceRedirectUp(args *stepArgs) error {
+	args.dbExec(`ALTER TABLE appspaces ADD COLUMN redirect TEXT NOT NULL DEFAULT ''`)
+	return args.dbErr
+}
+
+func appspaceRedirectDown(args *stepArgs) error {
+	args.dbExec(`ALTER TABLE appspaces DROP COLUMN redirect`)
+	return args.dbErr
+}
@@ -0,0 +1,17 @@
+package migrate
+
+// backupKeysUp adds the table for appspace backup encryption keys.
+// Only the public part of the owner's key is stored.
+func backupKeysUp(args *stepArgs) error {
+	args.dbExec(`CREATE TABLE "appspace_backup_keys" (
+		"appspace_id" INTEGER PRIMARY KEY,
+		"recipient" TEXT NOT NULL,
+		"created" DATETIME NOT NULL
+	)`)
+	return args.dbErr
+}
+
+func backupKeysDown(args *stepArgs) error {
+	args.dbExec(`DROP TABLE "appspace_backup_keys"`)
+	return args.dbErr
+}
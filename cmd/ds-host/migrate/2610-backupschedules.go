@@ -0,0 +1,23 @@
+package migrate
+
+// backupSchedulesUp adds the table for automatic appspace backup schedules
+// and their retention policies.
+func backupSchedulesUp(args *stepArgs) error {
+	args.dbExec(`CREATE TABLE "appspace_backup_schedules" (
+		"appspace_id" INTEGER PRIMARY KEY,
+		"enabled" INTEGER NOT NULL,
+		"schedule" TEXT NOT NULL,
+		"keep_last" INTEGER NOT NULL DEFAULT 0,
+		"keep_daily" INTEGER NOT NULL DEFAULT 0,
+		"keep_weekly" INTEGER NOT NULL DEFAULT 0,
+		"keep_monthly" INTEGER NOT NULL DEFAULT 0,
+		"created" DATETIME NOT NULL,
+		"last_run" DATETIME
+	)`)
+	return args.dbErr
+}
+
+func backupSchedulesDown(args *stepArgs) error {
+	args.dbExec(`DROP TABLE "appspace_backup_schedules"`)
+	return args.dbErr
+}
@@ -0,0 +1,25 @@
+package migrate
+
+// outboundUsageUp adds columns for outbound traffic to sandbox runs
+// and usage rollups, and outbound limits to appspace quotas.
+func outboundUsageUp(args *stepArgs) error {
+	for _, t := range []string{"sandbox_runs", "usage_rollups"} {
+		args.dbExec(`ALTER TABLE ` + t + ` ADD COLUMN out_requests INTEGER NOT NULL DEFAULT 0`)
+		args.dbExec(`ALTER TABLE ` + t + ` ADD COLUMN out_bytes_sent INTEGER NOT NULL DEFAULT 0`)
+		args.dbExec(`ALTER TABLE ` + t + ` ADD COLUMN out_bytes_received INTEGER NOT NULL DEFAULT 0`)
+	}
+	args.dbExec(`ALTER TABLE appspace_quotas ADD COLUMN out_req_per_min INTEGER NOT NULL DEFAULT 0`)
+	args.dbExec(`ALTER TABLE appspace_quotas ADD COLUMN out_mb_per_day INTEGER NOT NULL DEFAULT 0`)
+	return args.dbErr
+}
+
+func outboundUsageDown(args *stepArgs) error {
+	args.dbExec(`ALTER TABLE appspace_quotas DROP COLUMN out_mb_per_day`)
+	args.dbExec(`ALTER TABLE appspace_quotas DROP COLUMN out_req_per_min`)
+	for _, t := range []string{"usage_rollups", "sandbox_runs"} {
+		args.dbExec(`ALTER TABLE ` + t + ` DROP COLUMN out_bytes_received`)
+		args.dbExec(`ALTER TABLE ` + t + ` DROP COLUMN out_bytes_sent`)
+		args.dbExec(`ALTER TABLE ` + t + ` DROP COLUMN out_requests`)
+	}
+	return args.dbErr
+}
@@ -0,0 +1,19 @@
+package migrate
+
+// passwordResetUp adds a table for password reset tokens.
+// Only a hash of the token is stored; the token itself is emailed to the user.
+func passwordResetUp(args *stepArgs) error {
+	args.dbExec(`CREATE TABLE "password_resets" (
+		"token_hash" TEXT PRIMARY KEY,
+		"user_id" INTEGER NOT NULL,
+		"created" DATETIME NOT NULL,
+		"expires" DATETIME NOT NULL
+	)`)
+	args.dbExec(`CREATE INDEX password_resets_user_id ON password_resets (user_id)`)
+	return args.dbErr
+}
+
+func passwordResetDown(args *stepArgs) error {
+	args.dbExec(`DROP TABLE "password_resets"`)
+	return args.dbErr
+}
@@ -0,0 +1,28 @@
+package migrate
+
+// sessionsUp adds what users need to recognize their sessions
+// (user agent, IP, creation and last seen) to cookies,
+// and indexes cookies by user and appspace so they can be listed and revoked.
+func sessionsUp(args *stepArgs) error {
+	args.dbExec(`ALTER TABLE cookies ADD COLUMN user_agent TEXT NOT NULL DEFAULT ''`)
+	args.dbExec(`ALTER TABLE cookies ADD COLUMN ip TEXT NOT NULL DEFAULT ''`)
+	args.dbExec(`ALTER TABLE cookies ADD COLUMN created DATETIME`)
+	args.dbExec(`ALTER TABLE cookies ADD COLUMN last_seen DATETIME`)
+	// existing cookies have no history, so count them from now
+	args.dbExec(`UPDATE cookies SET created = datetime('now'), last_seen = datetime('now')`)
+	args.dbExec(`CREATE INDEX cookies_user_id ON cookies (user_id)`)
+	args.dbExec(`CREATE INDEX cookies_appspace_id ON cookies (appspace_id)`)
+	args.dbExec(`CREATE INDEX cookies_expires ON cookies (expires)`)
+	return args.dbErr
+}
+
+func sessionsDown(args *stepArgs) error {
+	args.dbExec(`DROP INDEX cookies_expires`)
+	args.dbExec(`DROP INDEX cookies_appspace_id`)
+	args.dbExec(`DROP INDEX cookies_user_id`)
+	args.dbExec(`ALTER TABLE cookies DROP COLUMN last_seen`)
+	args.dbExec(`ALTER TABLE cookies DROP COLUMN created`)
+	args.dbExec(`ALTER TABLE cookies DROP COLUMN ip`)
+	args.dbExec(`ALTER TABLE cookies DROP COLUMN user_agent`)
+	return args.dbErr
+}
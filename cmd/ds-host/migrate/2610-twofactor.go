@@ -0,0 +1,43 @@
+package migrate
+
+// twoFactorUp adds tables for second factors (TOTP, recovery codes, passkeys),
+// the instance-wide setting to require them,
+// and a flag for cookies of users that have yet to pass their second factor.
+func twoFactorUp(args *stepArgs) error {
+	args.dbExec(`ALTER TABLE settings ADD COLUMN require_2fa INTEGER NOT NULL DEFAULT 0`)
+	args.dbExec(`ALTER TABLE cookies ADD COLUMN second_factor_pending INTEGER NOT NULL DEFAULT 0`)
+
+	args.dbExec(`CREATE TABLE "user_totp" (
+		"user_id" INTEGER PRIMARY KEY,
+		"secret" TEXT NOT NULL,
+		"enabled" INTEGER NOT NULL DEFAULT 0,
+		"last_step" INTEGER NOT NULL DEFAULT 0,
+		"created" DATETIME NOT NULL
+	)`)
+	args.dbExec(`CREATE TABLE "user_recovery_codes" (
+		"user_id" INTEGER NOT NULL,
+		"code_hash" TEXT NOT NULL,
+		"used" DATETIME
+	)`)
+	args.dbExec(`CREATE INDEX user_recovery_codes_user_id ON user_recovery_codes (user_id)`)
+	args.dbExec(`CREATE TABLE "user_passkeys" (
+		"credential_id" TEXT PRIMARY KEY,
+		"user_id" INTEGER NOT NULL,
+		"name" TEXT NOT NULL,
+		"public_key" BLOB NOT NULL,
+		"sign_count" INTEGER NOT NULL DEFAULT 0,
+		"created" DATETIME NOT NULL,
+		"last_used" DATETIME
+	)`)
+	args.dbExec(`CREATE INDEX user_passkeys_user_id ON user_passkeys (user_id)`)
+	return args.dbErr
+}
+
+func twoFactorDown(args *stepArgs) error {
+	args.dbExec(`DROP TABLE "user_passkeys"`)
+	args.dbExec(`DROP TABLE "user_recovery_codes"`)
+	args.dbExec(`DROP TABLE "user_totp"`)
+	args.dbExec(`ALTER TABLE cookies DROP COLUMN second_factor_pending`)
+	args.dbExec(`ALTER TABLE settings DROP COLUMN require_2fa`)
+	return args.dbErr
+}
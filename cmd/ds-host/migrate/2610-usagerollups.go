@@ -0,0 +1,28 @@
+package migrate
+
+// usageRollupsUp adds a table for sandbox run sums per period,
+// so that old sandbox runs can be pruned.
+func usageRollupsUp(args *stepArgs) error {
+	args.dbExec(`CREATE TABLE "usage_rollups" (
+		"period" TEXT NOT NULL,
+		"period_start" DATETIME NOT NULL,
+		"owner_id" INTEGER NOT NULL,
+		"appspace_id" INTEGER NOT NULL,
+		"app_id" INTEGER NOT NULL,
+		"num_runs" INTEGER NOT NULL DEFAULT 0,
+		"tied_up_ms" INTEGER NOT NULL DEFAULT 0,
+		"cpu_usec" INTEGER NOT NULL DEFAULT 0,
+		"memory_byte_sec" INTEGER NOT NULL DEFAULT 0,
+		"io_bytes" INTEGER NOT NULL DEFAULT 0,
+		"io_ops" INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (period, period_start, owner_id, appspace_id, app_id)
+	)`)
+	args.dbExec(`CREATE INDEX usage_rollups_owner ON usage_rollups ( owner_id, period, period_start )`)
+	args.dbExec(`CREATE INDEX usage_rollups_appspace ON usage_rollups ( appspace_id, period, period_start )`)
+	return args.dbErr
+}
+
+func usageRollupsDown(args *stepArgs) error {
+	args.dbExec(`DROP TABLE "usage_rollups"`)
+	return args.dbErr
+}
@@ -60,5 +60,65 @@ var MigrationSteps []MigrationStep = []MigrationStep{{
 	up:                   tsnetIntegrationUp,
 	down:                 tsnetIntegrationDown,
 	appspaceMetaDBSchema: 1,
+}, {
+	name:                 "2610-backupschedules",
+	up:                   backupSchedulesUp,
+	down:                 backupSchedulesDown,
+	appspaceMetaDBSchema: 1,
+}, {
+	name:                 "2610-backupkeys",
+	up:                   backupKeysUp,
+	down:                 backupKeysDown,
+	appspaceMetaDBSchema: 1,
+}, {
+	name:                 "2610-appspaceredirect",
+	up:                   appspaceRedirectUp,
+	down:                 appspaceRedirectDown,
+	appspaceMetaDBSchema: 1,
+}, {
+	name:                 "2610-appspacequotas",
+	up:                   appspaceQuotasUp,
+	down:                 appspaceQuotasDown,
+	appspaceMetaDBSchema: 1,
+}, {
+	name:                 "2610-usagerollups",
+	up:                   usageRollupsUp,
+	down:                 usageRollupsDown,
+	appspaceMetaDBSchema: 1,
+}, {
+	name:                 "2610-appspacelogretention",
+	up:                   appspaceLogRetentionUp,
+	down:                 appspaceLogRetentionDown,
+	appspaceMetaDBSchema: 1,
+}, {
+	name:                 "2610-accesslog",
+	up:                   accessLogUp,
+	down:                 accessLogDown,
+	appspaceMetaDBSchema: 1,
+}, {
+	name:                 "2610-appspaceoutbound",
+	up:                   appspaceOutboundUp,
+	down:                 appspaceOutboundDown,
+	appspaceMetaDBSchema: 1,
+}, {
+	name:                 "2610-outboundusage",
+	up:                   outboundUsageUp,
+	down:                 outboundUsageDown,
+	appspaceMetaDBSchema: 1,
+}, {
+	name:                 "2610-twofactor",
+	up:                   twoFactorUp,
+	down:                 twoFactorDown,
+	appspaceMetaDBSchema: 1,
+}, {
+	name:                 "2610-passwordreset",
+	up:                   passwordResetUp,
+	down:                 passwordResetDown,
+	appspaceMetaDBSchema: 1,
+}, {
+	name:                 "2610-sessions",
+	up:                   sessionsUp,
+	down:                 sessionsDown,
+	appspaceMetaDBSchema: 1,
 },
 }
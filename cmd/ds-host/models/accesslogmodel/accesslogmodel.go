@@ -0,0 +1,232 @@
+package accesslogmodel
+
+import (
+	"database/sql"
+	"math"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+	"github.com/teleclimber/DropServer/internal/sqlxprepper"
+)
+
+// maxStatsRows limits the number of route and user rows in stats
+const maxStatsRows = 50
+
+// AccessLogModel stores appspace access log settings and entries.
+// Times are stored in UTC.
+type AccessLogModel struct {
+	DB *domain.DB
+
+	stmt struct {
+		getSettings       *sqlx.Stmt
+		setSettings       *sqlx.Stmt
+		getAllKeepDays    *sqlx.Stmt
+		insert            *sqlx.Stmt
+		query             *sqlx.Stmt
+		totals            *sqlx.Stmt
+		routeStats        *sqlx.Stmt
+		userStats         *sqlx.Stmt
+		deleteBefore      *sqlx.Stmt
+		deleteBeforeOther *sqlx.Stmt
+		deleteEntries     *sqlx.Stmt
+		deleteSettings    *sqlx.Stmt
+	}
+}
+
+func (m *AccessLogModel) PrepareStatements() {
+	p := sqlxprepper.NewPrepper(m.DB.Handle)
+
+	m.stmt.getSettings = p.Prep(`SELECT enabled, keep_days FROM appspace_access_log_settings WHERE appspace_id = ?`)
+
+	m.stmt.setSettings = p.Prep(`INSERT INTO appspace_access_log_settings
+		("appspace_id", "enabled", "keep_days") VALUES (?, ?, ?)
+		ON CONFLICT(appspace_id) DO UPDATE
+		SET enabled = excluded.enabled, keep_days = excluded.keep_days`)
+
+	m.stmt.getAllKeepDays = p.Prep(`SELECT appspace_id, keep_days FROM appspace_access_log_settings WHERE keep_days != 0`)
+
+	m.stmt.insert = p.Prep(`INSERT INTO appspace_access_log
+		("appspace_id", "time", "method", "path", "route_id", "route_path", "route_type",
+		"proxy_id", "authorized", "status")
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+
+	m.stmt.query = p.Prep(`SELECT * FROM appspace_access_log
+		WHERE appspace_id = ? AND time >= ? AND time < ? AND entry_id < ?
+		AND (? = "" OR route_path = ?)
+		AND (? = "" OR proxy_id = ?)
+		AND (? = 0 OR status = ? OR (? < 10 AND status / 100 = ?))
+		ORDER BY entry_id DESC LIMIT ?`)
+
+	m.stmt.totals = p.Prep(`SELECT COUNT(*) AS total, IFNULL(SUM(status >= 400), 0) AS errors
+		FROM appspace_access_log
+		WHERE appspace_id = ? AND time >= ? AND time < ?`)
+
+	m.stmt.routeStats = p.Prep(`SELECT route_path, COUNT(*) AS hits,
+		SUM(status >= 400 AND status < 500) AS client_errors, SUM(status >= 500) AS server_errors
+		FROM appspace_access_log
+		WHERE appspace_id = ? AND time >= ? AND time < ?
+		GROUP BY route_path ORDER BY hits DESC LIMIT ?`)
+
+	m.stmt.userStats = p.Prep(`SELECT proxy_id, route_path, COUNT(*) AS hits
+		FROM appspace_access_log
+		WHERE appspace_id = ? AND time >= ? AND time < ?
+		GROUP BY proxy_id, route_path ORDER BY hits DESC LIMIT ?`)
+
+	m.stmt.deleteBefore = p.Prep(`DELETE FROM appspace_access_log WHERE appspace_id = ? AND time < ?`)
+
+	m.stmt.deleteBeforeOther = p.Prep(`DELETE FROM appspace_access_log WHERE time < ?
+		AND appspace_id NOT IN (SELECT appspace_id FROM appspace_access_log_settings WHERE keep_days != 0)`)
+
+	m.stmt.deleteEntries = p.Prep(`DELETE FROM appspace_access_log WHERE appspace_id = ?`)
+
+	m.stmt.deleteSettings = p.Prep(`DELETE FROM appspace_access_log_settings WHERE appspace_id = ?`)
+}
+
+// GetSettings returns the access log settings of the appspace.
+// If none were set the access log is disabled.
+func (m *AccessLogModel) GetSettings(appspaceID domain.AppspaceID) (domain.AccessLogSettings, error) {
+	var ret domain.AccessLogSettings
+	err := m.stmt.getSettings.Get(&ret, appspaceID)
+	if err == sql.ErrNoRows {
+		return ret, nil
+	}
+	if err != nil {
+		m.getLogger("GetSettings()").AppspaceID(appspaceID).Error(err)
+	}
+	return ret, err
+}
+
+// SetSettings creates or replaces the access log settings of the appspace
+func (m *AccessLogModel) SetSettings(appspaceID domain.AppspaceID, settings domain.AccessLogSettings) error {
+	_, err := m.stmt.setSettings.Exec(appspaceID, settings.Enabled, settings.KeepDays)
+	if err != nil {
+		m.getLogger("SetSettings()").AppspaceID(appspaceID).Error(err)
+	}
+	return err
+}
+
+// Insert adds entries to the access log in one transaction
+func (m *AccessLogModel) Insert(entries []domain.AccessLogEntry) error {
+	tx, err := m.DB.Handle.Beginx()
+	if err != nil {
+		m.getLogger("Insert() Beginx()").Error(err)
+		return err
+	}
+	stmt := tx.Stmtx(m.stmt.insert)
+	for _, e := range entries {
+		_, err = stmt.Exec(e.AppspaceID, e.Time.UTC(), e.Method, e.Path, e.RouteID, e.RoutePath, e.RouteType,
+			e.ProxyID, e.Authorized, e.Status)
+		if err != nil {
+			tx.Rollback()
+			m.getLogger("Insert() Exec()").AppspaceID(e.AppspaceID).Error(err)
+			return err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		m.getLogger("Insert() Commit()").Error(err)
+	}
+	return err
+}
+
+// Query returns the entries of the appspace that match q, newest first
+func (m *AccessLogModel) Query(appspaceID domain.AppspaceID, q domain.AccessLogQuery) ([]domain.AccessLogEntry, error) {
+	from, to := timeRange(q.From, q.To)
+	before := q.Before
+	if before == 0 {
+		before = math.MaxInt64
+	}
+	ret := []domain.AccessLogEntry{}
+	err := m.stmt.query.Select(&ret, appspaceID, from, to, before,
+		q.RoutePath, q.RoutePath,
+		q.ProxyID, q.ProxyID,
+		q.Status, q.Status, q.Status, q.Status,
+		q.Limit)
+	if err != nil {
+		m.getLogger("Query()").AppspaceID(appspaceID).Error(err)
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Stats returns the totals, top routes and top users of the appspace's access log
+func (m *AccessLogModel) Stats(appspaceID domain.AppspaceID, from time.Time, to time.Time) (domain.AccessLogStats, error) {
+	from, to = timeRange(from, to)
+	ret := domain.AccessLogStats{
+		Routes: []domain.AccessLogRouteStats{},
+		Users:  []domain.AccessLogUserStats{}}
+	err := m.stmt.totals.QueryRowx(appspaceID, from, to).Scan(&ret.Total, &ret.Errors)
+	if err != nil {
+		m.getLogger("Stats() totals").AppspaceID(appspaceID).Error(err)
+		return ret, err
+	}
+	err = m.stmt.routeStats.Select(&ret.Routes, appspaceID, from, to, maxStatsRows)
+	if err != nil {
+		m.getLogger("Stats() routeStats").AppspaceID(appspaceID).Error(err)
+		return ret, err
+	}
+	err = m.stmt.userStats.Select(&ret.Users, appspaceID, from, to, maxStatsRows)
+	if err != nil {
+		m.getLogger("Stats() userStats").AppspaceID(appspaceID).Error(err)
+		return ret, err
+	}
+	return ret, nil
+}
+
+// DeleteExpired removes entries older than each appspace's keep days,
+// or defaultKeepDays for appspaces that did not set it
+func (m *AccessLogModel) DeleteExpired(now time.Time, defaultKeepDays int) error {
+	var keep []struct {
+		AppspaceID domain.AppspaceID `db:"appspace_id"`
+		KeepDays   int               `db:"keep_days"`
+	}
+	err := m.stmt.getAllKeepDays.Select(&keep)
+	if err != nil {
+		m.getLogger("DeleteExpired() getAllKeepDays").Error(err)
+		return err
+	}
+	for _, k := range keep {
+		_, err = m.stmt.deleteBefore.Exec(k.AppspaceID, now.AddDate(0, 0, -k.KeepDays).UTC())
+		if err != nil {
+			m.getLogger("DeleteExpired() deleteBefore").AppspaceID(k.AppspaceID).Error(err)
+			return err
+		}
+	}
+	_, err = m.stmt.deleteBeforeOther.Exec(now.AddDate(0, 0, -defaultKeepDays).UTC())
+	if err != nil {
+		m.getLogger("DeleteExpired() deleteBeforeOther").Error(err)
+	}
+	return err
+}
+
+// Delete removes the access log and settings of the appspace
+func (m *AccessLogModel) Delete(appspaceID domain.AppspaceID) error {
+	_, err := m.stmt.deleteEntries.Exec(appspaceID)
+	if err != nil {
+		m.getLogger("Delete() entries").AppspaceID(appspaceID).Error(err)
+		return err
+	}
+	_, err = m.stmt.deleteSettings.Exec(appspaceID)
+	if err != nil {
+		m.getLogger("Delete() settings").AppspaceID(appspaceID).Error(err)
+	}
+	return err
+}
+
+// timeRange replaces zero times so that they match everything
+func timeRange(from time.Time, to time.Time) (time.Time, time.Time) {
+	if to.IsZero() {
+		to = time.Date(9999, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return from.UTC(), to.UTC()
+}
+
+func (m *AccessLogModel) getLogger(note string) *record.DsLogger {
+	r := record.NewDsLogger().AddNote("AccessLogModel")
+	if note != "" {
+		r.AddNote(note)
+	}
+	return r
+}
@@ -0,0 +1,149 @@
+package accesslogmodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/migrate"
+)
+
+func TestPrepareStatements(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	model := &AccessLogModel{
+		DB: &domain.DB{Handle: h}}
+
+	model.PrepareStatements()
+}
+
+func TestSettings(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	model := &AccessLogModel{
+		DB: &domain.DB{Handle: h}}
+	model.PrepareStatements()
+
+	aID := domain.AppspaceID(7)
+
+	s, err := model.GetSettings(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Enabled {
+		t.Error("expected disabled by default")
+	}
+	err = model.SetSettings(aID, domain.AccessLogSettings{Enabled: true, KeepDays: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ = model.GetSettings(aID)
+	if s != (domain.AccessLogSettings{Enabled: true, KeepDays: 5}) {
+		t.Errorf("unexpected settings %v", s)
+	}
+}
+
+func TestQueryStats(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	model := &AccessLogModel{
+		DB: &domain.DB{Handle: h}}
+	model.PrepareStatements()
+
+	aID := domain.AppspaceID(7)
+	now := time.Now()
+	entries := []domain.AccessLogEntry{
+		{AppspaceID: aID, Time: now.Add(-3 * time.Hour), Method: "GET", Path: "/", RoutePath: "/", Status: 200, ProxyID: "abc"},
+		{AppspaceID: aID, Time: now.Add(-2 * time.Hour), Method: "GET", Path: "/api/x", RoutePath: "/api/**", Status: 404, ProxyID: "abc"},
+		{AppspaceID: aID, Time: now.Add(-1 * time.Hour), Method: "POST", Path: "/api/y", RoutePath: "/api/**", Status: 500},
+		{AppspaceID: aID, Time: now, Method: "GET", Path: "/api/z", RoutePath: "/api/**", Status: 200, ProxyID: "abc"},
+		{AppspaceID: domain.AppspaceID(8), Time: now, Method: "GET", Path: "/", RoutePath: "/", Status: 200},
+	}
+	err := model.Insert(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := model.Query(aID, domain.AccessLogQuery{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 4 || res[0].Path != "/api/z" {
+		t.Errorf("unexpected entries: %v", res)
+	}
+
+	res, _ = model.Query(aID, domain.AccessLogQuery{Status: 4, Limit: 10})
+	if len(res) != 1 || res[0].Status != 404 {
+		t.Errorf("expected the 404: %v", res)
+	}
+	res, _ = model.Query(aID, domain.AccessLogQuery{RoutePath: "/api/**", ProxyID: "abc", Limit: 10})
+	if len(res) != 2 {
+		t.Errorf("expected 2 entries: %v", res)
+	}
+	res, _ = model.Query(aID, domain.AccessLogQuery{From: now.Add(-90 * time.Minute), To: now, Limit: 10})
+	if len(res) != 1 || res[0].Path != "/api/y" {
+		t.Errorf("expected entry in time range: %v", res)
+	}
+	page, _ := model.Query(aID, domain.AccessLogQuery{Limit: 2})
+	res, _ = model.Query(aID, domain.AccessLogQuery{Limit: 2, Before: page[1].EntryID})
+	if len(res) != 2 || res[0].Path != "/api/x" {
+		t.Errorf("unexpected second page: %v", res)
+	}
+
+	stats, err := model.Stats(aID, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 4 || stats.Errors != 2 {
+		t.Errorf("unexpected totals: %v", stats)
+	}
+	if len(stats.Routes) != 2 || stats.Routes[0] != (domain.AccessLogRouteStats{RoutePath: "/api/**", Hits: 3, ClientErrors: 1, ServerErrors: 1}) {
+		t.Errorf("unexpected routes: %v", stats.Routes)
+	}
+	if len(stats.Users) != 3 || stats.Users[0].Hits != 2 {
+		t.Errorf("unexpected users: %v", stats.Users)
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	model := &AccessLogModel{
+		DB: &domain.DB{Handle: h}}
+	model.PrepareStatements()
+
+	now := time.Now()
+	model.SetSettings(domain.AppspaceID(7), domain.AccessLogSettings{Enabled: true, KeepDays: 30})
+	model.Insert([]domain.AccessLogEntry{
+		{AppspaceID: domain.AppspaceID(7), Time: now.AddDate(0, 0, -20)},
+		{AppspaceID: domain.AppspaceID(7), Time: now.AddDate(0, 0, -40)},
+		{AppspaceID: domain.AppspaceID(8), Time: now.AddDate(0, 0, -20)},
+		{AppspaceID: domain.AppspaceID(8), Time: now.AddDate(0, 0, -1)},
+	})
+
+	err := model.DeleteExpired(now, 14)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, _ := model.Query(domain.AppspaceID(7), domain.AccessLogQuery{Limit: 10})
+	if len(res) != 1 {
+		t.Errorf("expected 1 entry for appspace 7: %v", res)
+	}
+	res, _ = model.Query(domain.AppspaceID(8), domain.AccessLogQuery{Limit: 10})
+	if len(res) != 1 {
+		t.Errorf("expected 1 entry for appspace 8: %v", res)
+	}
+
+	err = model.Delete(domain.AppspaceID(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, _ = model.Query(domain.AppspaceID(7), domain.AccessLogQuery{Limit: 10})
+	if len(res) != 0 {
+		t.Errorf("expected no entries after delete: %v", res)
+	}
+}
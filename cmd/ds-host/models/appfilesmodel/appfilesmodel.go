@@ -155,6 +155,16 @@ func ExtractPackageLow(r io.Reader, appFilesPath string, maxSize int64) error {
 	return nil
 }
 
+// OpenPackage opens the package file as it was originally saved.
+func (a *AppFilesModel) OpenPackage(locationKey string) (*os.File, error) {
+	f, err := os.Open(a.getPackagePath(locationKey))
+	if err != nil {
+		a.getLogger("OpenPackage()").Error(err)
+		return nil, err
+	}
+	return f, nil
+}
+
 func (a *AppFilesModel) getPackagePath(locationKey string) string {
 	return filepath.Join(a.AppLocation2Path.Base(locationKey), "package.tar.gz")
 }
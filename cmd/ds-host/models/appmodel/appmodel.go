@@ -95,6 +95,7 @@ func (m *AppModel) PrepareStatements() {
 		ifnull(json_extract(manifest, '$.funding'), "") AS funding,
 		ifnull(json_extract(manifest, '$.release-date'), "") AS release_date,
 		ifnull(json_extract(manifest, '$.license'), "") AS license,
+		ifnull(json_extract(manifest, '$.outbound'), "") AS outbound,
 		created
 		FROM app_versions WHERE app_id = ? AND version = ?`)
 
@@ -110,6 +111,7 @@ func (m *AppModel) PrepareStatements() {
 		ifnull(json_extract(manifest, '$.funding'), "") AS funding,
 		ifnull(json_extract(manifest, '$.release-date'), "") AS release_date,
 		ifnull(json_extract(manifest, '$.license'), "") AS license,
+		ifnull(json_extract(manifest, '$.outbound'), "") AS outbound,
 		created
 		FROM app_versions WHERE app_id = ?`)
 
@@ -627,7 +629,8 @@ func (m *AppModel) GetVersion(appID domain.AppID, version domain.Version) (domai
 
 type AppVersionUIDB struct {
 	domain.AppVersionUI
-	AuthorsDB string `db:"authors"`
+	AuthorsDB  string `db:"authors"`
+	OutboundDB string `db:"outbound"`
 }
 
 func (m *AppModel) GetVersionForUI(appID domain.AppID, version domain.Version) (domain.AppVersionUI, error) {
@@ -826,6 +829,13 @@ func makeAppVersionUI(row AppVersionUIDB) (domain.AppVersionUI, error) {
 			return domain.AppVersionUI{}, err
 		}
 	}
+	outbound := make([]string, 0)
+	if row.OutboundDB != "" {
+		err := json.Unmarshal([]byte(row.OutboundDB), &outbound)
+		if err != nil {
+			return domain.AppVersionUI{}, err
+		}
+	}
 	return domain.AppVersionUI{
 		AppID:            row.AppID,
 		Name:             row.Name,
@@ -840,6 +850,7 @@ func makeAppVersionUI(row AppVersionUIDB) (domain.AppVersionUI, error) {
 		Funding:          row.Funding,
 		ReleaseDate:      row.ReleaseDate,
 		License:          row.License,
+		Outbound:         outbound,
 	}, nil
 }
 
@@ -781,7 +781,8 @@ func TestGetVersionUI(t *testing.T) {
 		Code:             "https://code",
 		Funding:          "https://finding",
 		ReleaseDate:      "2023-06-21",
-		License:          "MIT"})
+		License:          "MIT",
+		Outbound:         []string{"api.example.com"}})
 	if err != nil {
 		t.Error(err)
 	}
@@ -814,6 +815,7 @@ func TestGetVersionUI(t *testing.T) {
 		Funding:          "https://finding",
 		ReleaseDate:      "2023-06-21",
 		License:          "MIT",
+		Outbound:         []string{"api.example.com"},
 	}
 	if !cmp.Equal(expectedVerUI, appVersionUIOut) {
 		t.Errorf("app version out unexpected: %v, %v", expectedVerUI, appVersionUIOut)
@@ -826,6 +828,9 @@ func TestGetVersionUI(t *testing.T) {
 	if appVersionUIOut.Authors == nil {
 		t.Error("authors should not be nil")
 	}
+	if appVersionUIOut.Outbound == nil {
+		t.Error("outbound should not be nil")
+	}
 }
 
 func TestGetVersionForApp(t *testing.T) {
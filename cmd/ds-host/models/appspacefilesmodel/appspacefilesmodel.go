@@ -2,6 +2,7 @@ package appspacefilesmodel
 
 import (
 	"errors"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -12,12 +13,14 @@ import (
 
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
 	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+	"github.com/teleclimber/DropServer/internal/snapshot"
 	"github.com/teleclimber/DropServer/internal/validator"
 )
 
 // dir structure:
 // - asXYZLOCATION/
 //   - backups/
+//   - backup-chunks/ (content of snapshot backups)
 //   - data/
 //     - files/
 //     - logs/
@@ -286,7 +289,74 @@ func (a *AppspaceFilesModel) DeleteBackup(locationKey string, filename string) e
 	}
 
 	err = os.Remove(filepath.Join(a.Config.Exec.AppspacesPath, locationKey, "backups", filename))
+	if err != nil {
+		return err
+	}
+
+	if strings.HasSuffix(filename, ".snapshot") {
+		a.gcBackupChunks(locationKey)
+	}
+
+	return nil
+}
+
+// WriteBackupZip writes a backup to w as a zip file
+// Zip backups are copied as-is, snapshots are assembled from their chunks.
+func (a *AppspaceFilesModel) WriteBackupZip(locationKey string, filename string, w io.Writer) error {
+	if !a.locationKeyExists(locationKey) {
+		return errors.New("location key does not exist")
+	}
+	err := validator.AppspaceBackupFile(filename)
+	if err != nil {
+		return err
+	}
+	backupPath := filepath.Join(a.Config.Exec.AppspacesPath, locationKey, "backups", filename)
 
+	if strings.HasSuffix(filename, ".snapshot") {
+		manifest, err := snapshot.ReadManifest(backupPath)
+		if err != nil {
+			a.getLogger("WriteBackupZip, snapshot.ReadManifest").Error(err)
+			return err
+		}
+		return a.chunkStore(locationKey).WriteZip(manifest, w)
+	}
+
+	f, err := os.Open(backupPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func (a *AppspaceFilesModel) chunkStore(locationKey string) snapshot.Store {
+	return snapshot.Store{Dir: filepath.Join(a.Config.Exec.AppspacesPath, locationKey, "backup-chunks")}
+}
+
+// gcBackupChunks removes chunks no longer used by any snapshot
+// If any snapshot manifest can not be read, nothing is removed.
+func (a *AppspaceFilesModel) gcBackupChunks(locationKey string) error {
+	backups, err := a.GetBackups(locationKey)
+	if err != nil {
+		return err
+	}
+	manifests := []snapshot.Manifest{}
+	for _, b := range backups {
+		if !strings.HasSuffix(b, ".snapshot") {
+			continue
+		}
+		m, err := snapshot.ReadManifest(filepath.Join(a.Config.Exec.AppspacesPath, locationKey, "backups", b))
+		if err != nil {
+			a.getLogger("gcBackupChunks, snapshot.ReadManifest").AddNote(b).Error(err)
+			return err
+		}
+		manifests = append(manifests, m)
+	}
+	_, err = a.chunkStore(locationKey).GC(manifests)
+	if err != nil {
+		a.getLogger("gcBackupChunks, GC").Error(err)
+	}
 	return err
 }
 
@@ -1,6 +1,7 @@
 package appspacefilesmodel
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -9,6 +10,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
 	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+	"github.com/teleclimber/DropServer/internal/snapshot"
 )
 
 func TestDelete(t *testing.T) {
@@ -242,3 +244,62 @@ func TestGetBackups(t *testing.T) {
 	}
 
 }
+
+func TestDeleteSnapshotBackup(t *testing.T) {
+	dir := t.TempDir()
+
+	cfg := &domain.RuntimeConfig{}
+	cfg.DataDir = dir
+	cfg.Exec.AppspacesPath = dir
+
+	m := AppspaceFilesModel{
+		Config: cfg}
+
+	loc := "abcLOC"
+	backupsDir := filepath.Join(dir, loc, "backups")
+	dataDir := filepath.Join(dir, loc, "data")
+	err := os.MkdirAll(backupsDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.MkdirAll(dataDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dataDir, "hello.txt"), []byte("hello"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := m.chunkStore(loc)
+	manifest, err := store.Create(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := "1234-56-78_7890.snapshot"
+	err = snapshot.WriteManifest(manifest, filepath.Join(backupsDir, file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	err = m.WriteBackupZip(loc, file, buf)
+	if err != nil {
+		t.Error(err)
+	}
+	if buf.Len() == 0 {
+		t.Error("expected zip data")
+	}
+
+	err = m.DeleteBackup(loc, file)
+	if err != nil {
+		t.Error(err)
+	}
+	entries, err := m.GetBackups(loc)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(entries) != 0 {
+		t.Error("expected zero entries")
+	}
+}
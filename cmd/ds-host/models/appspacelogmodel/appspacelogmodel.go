@@ -0,0 +1,75 @@
+package appspacelogmodel
+
+import (
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+	"github.com/teleclimber/DropServer/internal/sqlxprepper"
+)
+
+// AppspaceLogModel stores per-appspace settings for appspace logs
+type AppspaceLogModel struct {
+	DB *domain.DB
+
+	stmt struct {
+		getRetention    *sqlx.Stmt
+		setRetention    *sqlx.Stmt
+		deleteRetention *sqlx.Stmt
+	}
+}
+
+func (m *AppspaceLogModel) PrepareStatements() {
+	p := sqlxprepper.NewPrepper(m.DB.Handle)
+
+	m.stmt.getRetention = p.Prep(`SELECT keep_archives, keep_days
+		FROM appspace_log_retention WHERE appspace_id = ?`)
+
+	m.stmt.setRetention = p.Prep(`INSERT INTO appspace_log_retention
+		("appspace_id", "keep_archives", "keep_days") VALUES (?, ?, ?)
+		ON CONFLICT(appspace_id) DO UPDATE
+		SET keep_archives = excluded.keep_archives, keep_days = excluded.keep_days`)
+
+	m.stmt.deleteRetention = p.Prep(`DELETE FROM appspace_log_retention WHERE appspace_id = ?`)
+}
+
+// GetRetention returns the log retention set for the appspace.
+// If none was set it returns zero values.
+func (m *AppspaceLogModel) GetRetention(appspaceID domain.AppspaceID) (domain.LogRetention, error) {
+	var ret domain.LogRetention
+	err := m.stmt.getRetention.Get(&ret, appspaceID)
+	if err == sql.ErrNoRows {
+		return ret, nil
+	}
+	if err != nil {
+		m.getLogger("GetRetention()").AppspaceID(appspaceID).Error(err)
+	}
+	return ret, err
+}
+
+// SetRetention creates or replaces the log retention of the appspace
+func (m *AppspaceLogModel) SetRetention(appspaceID domain.AppspaceID, ret domain.LogRetention) error {
+	_, err := m.stmt.setRetention.Exec(appspaceID, ret.KeepArchives, ret.KeepDays)
+	if err != nil {
+		m.getLogger("SetRetention()").AppspaceID(appspaceID).Error(err)
+	}
+	return err
+}
+
+// Delete removes the log settings of the appspace
+func (m *AppspaceLogModel) Delete(appspaceID domain.AppspaceID) error {
+	_, err := m.stmt.deleteRetention.Exec(appspaceID)
+	if err != nil {
+		m.getLogger("Delete()").AppspaceID(appspaceID).Error(err)
+	}
+	return err
+}
+
+func (m *AppspaceLogModel) getLogger(note string) *record.DsLogger {
+	r := record.NewDsLogger().AddNote("AppspaceLogModel")
+	if note != "" {
+		r.AddNote(note)
+	}
+	return r
+}
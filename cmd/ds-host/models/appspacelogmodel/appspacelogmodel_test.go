@@ -0,0 +1,65 @@
+package appspacelogmodel
+
+import (
+	"testing"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/migrate"
+)
+
+func TestPrepareStatements(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	model := &AppspaceLogModel{
+		DB: &domain.DB{Handle: h}}
+
+	model.PrepareStatements()
+}
+
+func TestRetention(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	model := &AppspaceLogModel{
+		DB: &domain.DB{Handle: h}}
+	model.PrepareStatements()
+
+	aID := domain.AppspaceID(7)
+
+	ret, err := model.GetRetention(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret != (domain.LogRetention{}) {
+		t.Errorf("expected zero retention: %v", ret)
+	}
+
+	err = model.SetRetention(aID, domain.LogRetention{KeepArchives: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = model.SetRetention(aID, domain.LogRetention{KeepArchives: 5, KeepDays: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err = model.GetRetention(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret != (domain.LogRetention{KeepArchives: 5, KeepDays: 20}) {
+		t.Errorf("unexpected retention: %v", ret)
+	}
+
+	err = model.Delete(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err = model.GetRetention(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret != (domain.LogRetention{}) {
+		t.Errorf("expected zero retention after delete: %v", ret)
+	}
+}
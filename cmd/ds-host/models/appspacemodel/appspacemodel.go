@@ -26,6 +26,7 @@ type AppspaceModel struct {
 		insert           *sqlx.Stmt
 		pause            *sqlx.Stmt
 		setVersion       *sqlx.Stmt
+		setRedirect      *sqlx.Stmt
 		delete           *sqlx.Stmt
 		selectAllDomains *sqlx.Stmt
 	}
@@ -63,6 +64,8 @@ func (m *AppspaceModel) PrepareStatements() {
 
 	m.stmt.setVersion = p.Prep(`UPDATE appspaces SET app_version = ? WHERE appspace_id = ?`)
 
+	m.stmt.setRedirect = p.Prep(`UPDATE appspaces SET redirect = ? WHERE appspace_id = ?`)
+
 	m.stmt.delete = p.Prep(`DELETE FROM appspaces WHERE appspace_id = ?`)
 
 	m.stmt.selectAllDomains = p.Prep(`SELECT domain_name FROM appspaces`)
@@ -204,6 +207,23 @@ func (m *AppspaceModel) Pause(appspaceID domain.AppspaceID, pause bool) error {
 	return nil
 }
 
+// SetRedirect sets the address that requests to the appspace are redirected to.
+// An empty string removes the redirect.
+func (m *AppspaceModel) SetRedirect(appspaceID domain.AppspaceID, redirect string) error {
+	result, err := m.stmt.setRedirect.Exec(redirect, appspaceID)
+	if err != nil {
+		m.getLogger("SetRedirect").Error(err)
+		return err
+	}
+	err = checkOneRowAffected(result)
+	if err != nil {
+		m.getLogger("SetRedirect, checkOneRowAffected").Error(err)
+		return err
+	}
+
+	return nil
+}
+
 // SetVersion changes the active version of the application for tha tappspace
 func (m *AppspaceModel) SetVersion(appspaceID domain.AppspaceID, version domain.Version) error {
 	result, err := m.stmt.setVersion.Exec(version, appspaceID)
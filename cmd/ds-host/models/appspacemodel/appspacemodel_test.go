@@ -354,6 +354,48 @@ func TestPause(t *testing.T) {
 	}
 }
 
+func TestSetRedirect(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	db := &domain.DB{
+		Handle: h}
+
+	model := &AppspaceModel{
+		DB: db}
+
+	model.PrepareStatements()
+
+	inAppspace := domain.Appspace{
+		OwnerID:     domain.UserID(7),
+		AppID:       domain.AppID(11),
+		AppVersion:  domain.Version("0.0.1"),
+		DomainName:  "test-appspace",
+		LocationKey: "as123",
+	}
+
+	appspace, err := model.Create(inAppspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if appspace.Redirect != "" {
+		t.Error("expected no redirect")
+	}
+
+	err = model.SetRedirect(appspace.AppspaceID, "https://new.example.com")
+	if err != nil {
+		t.Error(err)
+	}
+
+	appspace, err = model.GetFromID(appspace.AppspaceID)
+	if err != nil {
+		t.Error(err)
+	}
+	if appspace.Redirect != "https://new.example.com" {
+		t.Errorf("unexpected redirect: %v", appspace.Redirect)
+	}
+}
+
 func TestSetVersion(t *testing.T) {
 	h := migrate.MakeSqliteDummyDB()
 	defer h.Close()
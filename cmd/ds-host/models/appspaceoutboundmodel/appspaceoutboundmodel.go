@@ -0,0 +1,123 @@
+package appspaceoutboundmodel
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+	"github.com/teleclimber/DropServer/internal/sqlxprepper"
+)
+
+// AppspaceOutboundModel stores the outbound hosts
+// the owner has approved for each appspace.
+// An appspace whose hosts were never set has no restriction.
+type AppspaceOutboundModel struct {
+	DB *domain.DB
+
+	stmt struct {
+		isSet     *sqlx.Stmt
+		get       *sqlx.Stmt
+		setSet    *sqlx.Stmt
+		insert    *sqlx.Stmt
+		delete    *sqlx.Stmt
+		deleteSet *sqlx.Stmt
+	}
+}
+
+func (m *AppspaceOutboundModel) PrepareStatements() {
+	p := sqlxprepper.NewPrepper(m.DB.Handle)
+
+	m.stmt.isSet = p.Prep(`SELECT count(*) FROM appspace_outbound_set WHERE appspace_id = ?`)
+
+	m.stmt.get = p.Prep(`SELECT host FROM appspace_outbound WHERE appspace_id = ? ORDER BY host`)
+
+	m.stmt.setSet = p.Prep(`INSERT OR IGNORE INTO appspace_outbound_set ("appspace_id") VALUES (?)`)
+
+	m.stmt.insert = p.Prep(`INSERT INTO appspace_outbound ("appspace_id", "host") VALUES (?, ?)`)
+
+	m.stmt.delete = p.Prep(`DELETE FROM appspace_outbound WHERE appspace_id = ?`)
+
+	m.stmt.deleteSet = p.Prep(`DELETE FROM appspace_outbound_set WHERE appspace_id = ?`)
+}
+
+// Get returns the approved outbound hosts of the appspace
+// It returns nil if the approved hosts were never set.
+func (m *AppspaceOutboundModel) Get(appspaceID domain.AppspaceID) ([]string, error) {
+	var count int
+	err := m.stmt.isSet.Get(&count, appspaceID)
+	if err != nil {
+		m.getLogger("Get() isSet").AppspaceID(appspaceID).Error(err)
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	ret := []string{}
+	err = m.stmt.get.Select(&ret, appspaceID)
+	if err != nil {
+		m.getLogger("Get()").AppspaceID(appspaceID).Error(err)
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Set replaces the approved outbound hosts of the appspace
+func (m *AppspaceOutboundModel) Set(appspaceID domain.AppspaceID, hosts []string) error {
+	tx, err := m.DB.Handle.Beginx()
+	if err != nil {
+		m.getLogger("Set() Beginx()").Error(err)
+		return err
+	}
+	_, err = tx.Stmtx(m.stmt.setSet).Exec(appspaceID)
+	if err != nil {
+		tx.Rollback()
+		m.getLogger("Set() setSet").AppspaceID(appspaceID).Error(err)
+		return err
+	}
+	_, err = tx.Stmtx(m.stmt.delete).Exec(appspaceID)
+	if err != nil {
+		tx.Rollback()
+		m.getLogger("Set() delete").AppspaceID(appspaceID).Error(err)
+		return err
+	}
+	stmt := tx.Stmtx(m.stmt.insert)
+	seen := map[string]bool{}
+	for _, h := range hosts {
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+		_, err = stmt.Exec(appspaceID, h)
+		if err != nil {
+			tx.Rollback()
+			m.getLogger("Set() insert").AppspaceID(appspaceID).Error(err)
+			return err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		m.getLogger("Set() Commit()").Error(err)
+	}
+	return err
+}
+
+// Delete removes all approved hosts of the appspace
+func (m *AppspaceOutboundModel) Delete(appspaceID domain.AppspaceID) error {
+	_, err := m.stmt.delete.Exec(appspaceID)
+	if err != nil {
+		m.getLogger("Delete()").AppspaceID(appspaceID).Error(err)
+		return err
+	}
+	_, err = m.stmt.deleteSet.Exec(appspaceID)
+	if err != nil {
+		m.getLogger("Delete() deleteSet").AppspaceID(appspaceID).Error(err)
+	}
+	return err
+}
+
+func (m *AppspaceOutboundModel) getLogger(note string) *record.DsLogger {
+	r := record.NewDsLogger().AddNote("AppspaceOutboundModel")
+	if note != "" {
+		r.AddNote(note)
+	}
+	return r
+}
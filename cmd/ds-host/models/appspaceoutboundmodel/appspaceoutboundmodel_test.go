@@ -0,0 +1,79 @@
+package appspaceoutboundmodel
+
+import (
+	"testing"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/migrate"
+)
+
+func TestPrepareStatements(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	model := &AppspaceOutboundModel{
+		DB: &domain.DB{Handle: h}}
+
+	model.PrepareStatements()
+}
+
+func TestSetGet(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	model := &AppspaceOutboundModel{
+		DB: &domain.DB{Handle: h}}
+	model.PrepareStatements()
+
+	aID := domain.AppspaceID(7)
+
+	hosts, err := model.Get(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hosts != nil {
+		t.Errorf("expected nil hosts when never set: %v", hosts)
+	}
+
+	err = model.Set(aID, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts, err = model.Get(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hosts == nil || len(hosts) != 0 {
+		t.Errorf("expected empty hosts once set: %v", hosts)
+	}
+
+	err = model.Set(aID, []string{"api.example.com", "*.example.org", "api.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts, err = model.Get(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 2 || hosts[0] != "*.example.org" || hosts[1] != "api.example.com" {
+		t.Errorf("unexpected hosts: %v", hosts)
+	}
+
+	err = model.Set(aID, []string{"other.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts, _ = model.Get(aID)
+	if len(hosts) != 1 || hosts[0] != "other.com" {
+		t.Errorf("unexpected hosts after set: %v", hosts)
+	}
+
+	err = model.Delete(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts, _ = model.Get(aID)
+	if hosts != nil {
+		t.Errorf("expected hosts deleted: %v", hosts)
+	}
+}
@@ -0,0 +1,155 @@
+package appspacequotamodel
+
+import (
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+	"github.com/teleclimber/DropServer/internal/sqlxprepper"
+)
+
+// AppspaceQuotaModel stores the resource quotas of appspaces
+// and the times appspaces ran into their limits
+type AppspaceQuotaModel struct {
+	DB *domain.DB
+
+	stmt struct {
+		get         *sqlx.Stmt
+		upsert      *sqlx.Stmt
+		recordHit   *sqlx.Stmt
+		getHits     *sqlx.Stmt
+		getAllHits  *sqlx.Stmt
+		clearHits   *sqlx.Stmt
+		deleteQuota *sqlx.Stmt
+	}
+}
+
+func (m *AppspaceQuotaModel) PrepareStatements() {
+	p := sqlxprepper.NewPrepper(m.DB.Handle)
+
+	m.stmt.get = p.Prep(`SELECT memory_max_mb, cpu_max_percent, io_read_bps, io_write_bps,
+		io_read_iops, io_write_iops, disk_max_mb, out_req_per_min, out_mb_per_day
+		FROM appspace_quotas WHERE appspace_id = ? AND set_by = ?`)
+
+	m.stmt.upsert = p.Prep(`INSERT INTO appspace_quotas
+		("appspace_id", "set_by", "memory_max_mb", "cpu_max_percent", "io_read_bps", "io_write_bps",
+		"io_read_iops", "io_write_iops", "disk_max_mb", "out_req_per_min", "out_mb_per_day")
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(appspace_id, set_by) DO UPDATE
+		SET memory_max_mb = excluded.memory_max_mb, cpu_max_percent = excluded.cpu_max_percent,
+		io_read_bps = excluded.io_read_bps, io_write_bps = excluded.io_write_bps,
+		io_read_iops = excluded.io_read_iops, io_write_iops = excluded.io_write_iops,
+		disk_max_mb = excluded.disk_max_mb, out_req_per_min = excluded.out_req_per_min,
+		out_mb_per_day = excluded.out_mb_per_day`)
+
+	m.stmt.recordHit = p.Prep(`INSERT INTO appspace_limit_hits
+		("appspace_id", "limit_name", "count", "last_hit")
+		VALUES (?, ?, ?, datetime("now"))
+		ON CONFLICT(appspace_id, limit_name) DO UPDATE
+		SET count = count + excluded.count, last_hit = excluded.last_hit`)
+
+	m.stmt.getHits = p.Prep(`SELECT * FROM appspace_limit_hits WHERE appspace_id = ? ORDER BY limit_name`)
+
+	m.stmt.getAllHits = p.Prep(`SELECT * FROM appspace_limit_hits ORDER BY last_hit DESC`)
+
+	m.stmt.clearHits = p.Prep(`DELETE FROM appspace_limit_hits WHERE appspace_id = ?`)
+
+	m.stmt.deleteQuota = p.Prep(`DELETE FROM appspace_quotas WHERE appspace_id = ?`)
+}
+
+// Get returns the quota set by the owner or the admin.
+// If no quota was set the returned quota has no limits.
+func (m *AppspaceQuotaModel) Get(appspaceID domain.AppspaceID, setBy string) (domain.AppspaceQuota, error) {
+	var ret domain.AppspaceQuota
+	err := m.stmt.get.Get(&ret, appspaceID, setBy)
+	if err == sql.ErrNoRows {
+		return ret, nil
+	}
+	if err != nil {
+		m.getLogger("Get()").AppspaceID(appspaceID).Error(err)
+	}
+	return ret, err
+}
+
+// GetEffective returns the limits that apply to the appspace,
+// which are the tighter of the owner's and the admin's
+func (m *AppspaceQuotaModel) GetEffective(appspaceID domain.AppspaceID) (domain.AppspaceQuota, error) {
+	owner, err := m.Get(appspaceID, domain.QuotaSetByOwner)
+	if err != nil {
+		return owner, err
+	}
+	admin, err := m.Get(appspaceID, domain.QuotaSetByAdmin)
+	if err != nil {
+		return admin, err
+	}
+	return owner.Tighter(admin), nil
+}
+
+// Set creates or replaces the owner's or admin's quota for the appspace
+func (m *AppspaceQuotaModel) Set(appspaceID domain.AppspaceID, setBy string, q domain.AppspaceQuota) error {
+	_, err := m.stmt.upsert.Exec(appspaceID, setBy, q.MemoryMaxMb, q.CPUMaxPercent, q.IOReadBps, q.IOWriteBps,
+		q.IOReadIOPS, q.IOWriteIOPS, q.DiskMaxMb, q.OutReqPerMin, q.OutMbPerDay)
+	if err != nil {
+		m.getLogger("Set()").AppspaceID(appspaceID).Error(err)
+	}
+	return err
+}
+
+// RecordHit adds count to the number of times the appspace ran into the limit
+func (m *AppspaceQuotaModel) RecordHit(appspaceID domain.AppspaceID, limit string, count int) error {
+	_, err := m.stmt.recordHit.Exec(appspaceID, limit, count)
+	if err != nil {
+		m.getLogger("RecordHit()").AppspaceID(appspaceID).Error(err)
+	}
+	return err
+}
+
+// GetHits returns the limits the appspace ran into
+func (m *AppspaceQuotaModel) GetHits(appspaceID domain.AppspaceID) ([]domain.AppspaceLimitHit, error) {
+	ret := []domain.AppspaceLimitHit{}
+	err := m.stmt.getHits.Select(&ret, appspaceID)
+	if err != nil {
+		m.getLogger("GetHits()").AppspaceID(appspaceID).Error(err)
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetAllHits returns the limits all appspaces ran into, most recent first
+func (m *AppspaceQuotaModel) GetAllHits() ([]domain.AppspaceLimitHit, error) {
+	ret := []domain.AppspaceLimitHit{}
+	err := m.stmt.getAllHits.Select(&ret)
+	if err != nil {
+		m.getLogger("GetAllHits()").Error(err)
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ClearHits forgets the limits the appspace ran into
+func (m *AppspaceQuotaModel) ClearHits(appspaceID domain.AppspaceID) error {
+	_, err := m.stmt.clearHits.Exec(appspaceID)
+	if err != nil {
+		m.getLogger("ClearHits()").AppspaceID(appspaceID).Error(err)
+	}
+	return err
+}
+
+// Delete removes the quotas and recorded hits of the appspace
+func (m *AppspaceQuotaModel) Delete(appspaceID domain.AppspaceID) error {
+	_, err := m.stmt.deleteQuota.Exec(appspaceID)
+	if err != nil {
+		m.getLogger("Delete()").AppspaceID(appspaceID).Error(err)
+		return err
+	}
+	return m.ClearHits(appspaceID)
+}
+
+func (m *AppspaceQuotaModel) getLogger(note string) *record.DsLogger {
+	r := record.NewDsLogger().AddNote("AppspaceQuotaModel")
+	if note != "" {
+		r.AddNote(note)
+	}
+	return r
+}
@@ -0,0 +1,141 @@
+package appspacequotamodel
+
+import (
+	"testing"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/migrate"
+)
+
+func TestPrepareStatements(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	model := &AppspaceQuotaModel{
+		DB: &domain.DB{Handle: h}}
+
+	model.PrepareStatements()
+}
+
+func TestSetGetEffective(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	model := &AppspaceQuotaModel{
+		DB: &domain.DB{Handle: h}}
+	model.PrepareStatements()
+
+	aID := domain.AppspaceID(7)
+
+	q, err := model.GetEffective(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q != (domain.AppspaceQuota{}) {
+		t.Errorf("expected no limits: %v", q)
+	}
+
+	err = model.Set(aID, domain.QuotaSetByOwner, domain.AppspaceQuota{MemoryMaxMb: 256, CPUMaxPercent: 50, OutReqPerMin: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = model.Set(aID, domain.QuotaSetByAdmin, domain.AppspaceQuota{MemoryMaxMb: 512, DiskMaxMb: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = model.Set(aID, domain.QuotaSetByAdmin, domain.AppspaceQuota{MemoryMaxMb: 128, DiskMaxMb: 100, OutReqPerMin: 120, OutMbPerDay: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q, err = model.Get(aID, domain.QuotaSetByOwner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.MemoryMaxMb != 256 || q.CPUMaxPercent != 50 {
+		t.Errorf("unexpected owner quota: %v", q)
+	}
+
+	q, err = model.GetEffective(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := domain.AppspaceQuota{MemoryMaxMb: 128, CPUMaxPercent: 50, DiskMaxMb: 100, OutReqPerMin: 60, OutMbPerDay: 50}
+	if q != expected {
+		t.Errorf("unexpected effective quota: %v", q)
+	}
+
+	err = model.Delete(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err = model.GetEffective(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q != (domain.AppspaceQuota{}) {
+		t.Errorf("expected no limits after delete: %v", q)
+	}
+}
+
+func TestRecordHits(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	model := &AppspaceQuotaModel{
+		DB: &domain.DB{Handle: h}}
+	model.PrepareStatements()
+
+	aID := domain.AppspaceID(7)
+
+	err := model.RecordHit(aID, domain.LimitMemoryMax, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = model.RecordHit(aID, domain.LimitMemoryMax, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = model.RecordHit(aID, domain.LimitCPUMax, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = model.RecordHit(domain.AppspaceID(8), domain.LimitDiskMax, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hits, err := model.GetHits(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("expected 2 hits, got %v", hits)
+	}
+	if hits[0].Limit != domain.LimitCPUMax || hits[1].Limit != domain.LimitMemoryMax || hits[1].Count != 5 {
+		t.Errorf("unexpected hits: %v", hits)
+	}
+	if hits[1].LastHit.IsZero() {
+		t.Error("expected last hit to be set")
+	}
+
+	all, err := model.GetAllHits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Errorf("expected 3 hits, got %v", all)
+	}
+
+	err = model.ClearHits(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits, err = model.GetHits(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 0 {
+		t.Errorf("expected hits to be cleared: %v", hits)
+	}
+}
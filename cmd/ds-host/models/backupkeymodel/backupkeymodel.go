@@ -0,0 +1,94 @@
+package backupkeymodel
+
+import (
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+	"github.com/teleclimber/DropServer/internal/backupcrypt"
+	"github.com/teleclimber/DropServer/internal/sqlxprepper"
+)
+
+// BackupKeyModel stores the keys that appspace backups are encrypted to
+type BackupKeyModel struct {
+	DB *domain.DB
+
+	stmt struct {
+		get    *sqlx.Stmt
+		upsert *sqlx.Stmt
+		delete *sqlx.Stmt
+	}
+}
+
+func (m *BackupKeyModel) PrepareStatements() {
+	p := sqlxprepper.NewPrepper(m.DB.Handle)
+
+	m.stmt.get = p.Prep(`SELECT * FROM appspace_backup_keys WHERE appspace_id = ?`)
+
+	m.stmt.upsert = p.Prep(`INSERT INTO appspace_backup_keys
+		("appspace_id", "recipient", "created")
+		VALUES (?, ?, datetime("now"))
+		ON CONFLICT(appspace_id) DO UPDATE
+		SET recipient = excluded.recipient, created = excluded.created`)
+
+	m.stmt.delete = p.Prep(`DELETE FROM appspace_backup_keys WHERE appspace_id = ?`)
+}
+
+// Get returns the backup key of the appspace
+// It returns domain.ErrNoRowsInResultSet if backups are not encrypted
+func (m *BackupKeyModel) Get(appspaceID domain.AppspaceID) (domain.BackupKey, error) {
+	var ret domain.BackupKey
+	err := m.stmt.get.Get(&ret, appspaceID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ret, domain.ErrNoRowsInResultSet
+		}
+		m.getLogger("Get()").AppspaceID(appspaceID).Error(err)
+	}
+	return ret, err
+}
+
+// GetRecipient returns the key that the appspace's backups are encrypted to.
+// The bool is false if backups are not encrypted.
+func (m *BackupKeyModel) GetRecipient(appspaceID domain.AppspaceID) (backupcrypt.Recipient, bool, error) {
+	key, err := m.Get(appspaceID)
+	if err == domain.ErrNoRowsInResultSet {
+		return backupcrypt.Recipient{}, false, nil
+	}
+	if err != nil {
+		return backupcrypt.Recipient{}, false, err
+	}
+	recipient, err := backupcrypt.ParseRecipient(key.Recipient)
+	if err != nil {
+		m.getLogger("GetRecipient(), ParseRecipient").AppspaceID(appspaceID).Error(err)
+		return backupcrypt.Recipient{}, false, err
+	}
+	return recipient, true, nil
+}
+
+// Set creates or replaces the appspace's backup key
+func (m *BackupKeyModel) Set(appspaceID domain.AppspaceID, recipient string) error {
+	_, err := m.stmt.upsert.Exec(appspaceID, recipient)
+	if err != nil {
+		m.getLogger("Set()").AppspaceID(appspaceID).Error(err)
+	}
+	return err
+}
+
+// Delete removes the appspace's backup key
+func (m *BackupKeyModel) Delete(appspaceID domain.AppspaceID) error {
+	_, err := m.stmt.delete.Exec(appspaceID)
+	if err != nil {
+		m.getLogger("Delete()").AppspaceID(appspaceID).Error(err)
+	}
+	return err
+}
+
+func (m *BackupKeyModel) getLogger(note string) *record.DsLogger {
+	r := record.NewDsLogger().AddNote("BackupKeyModel")
+	if note != "" {
+		r.AddNote(note)
+	}
+	return r
+}
@@ -0,0 +1,104 @@
+package backupkeymodel
+
+import (
+	"testing"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/migrate"
+	"github.com/teleclimber/DropServer/internal/backupcrypt"
+)
+
+func TestPrepareStatements(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	model := &BackupKeyModel{
+		DB: &domain.DB{Handle: h}}
+
+	model.PrepareStatements()
+}
+
+func TestSetGetDelete(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	model := &BackupKeyModel{
+		DB: &domain.DB{Handle: h}}
+	model.PrepareStatements()
+
+	aID := domain.AppspaceID(7)
+
+	_, err := model.Get(aID)
+	if err != domain.ErrNoRowsInResultSet {
+		t.Error("expected domain.ErrNoRowsInResultSet")
+	}
+
+	err = model.Set(aID, "dsbk1:abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = model.Set(aID, "dsbk1:def")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := model.Get(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Recipient != "dsbk1:def" || k.Created.IsZero() {
+		t.Errorf("unexpected key: %v", k)
+	}
+
+	err = model.Delete(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = model.Get(aID)
+	if err != domain.ErrNoRowsInResultSet {
+		t.Error("expected domain.ErrNoRowsInResultSet")
+	}
+}
+
+func TestGetRecipient(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	model := &BackupKeyModel{
+		DB: &domain.DB{Handle: h}}
+	model.PrepareStatements()
+
+	aID := domain.AppspaceID(7)
+
+	_, ok, err := model.GetRecipient(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("expected no recipient")
+	}
+
+	r, err := backupcrypt.NewRecipient("secret", backupcrypt.Params{Time: 1, Memory: 64, Threads: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = model.Set(aID, r.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := model.GetRecipient(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || got.String() != r.String() {
+		t.Error("expected recipient to round-trip")
+	}
+
+	err = model.Set(aID, "garbage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = model.GetRecipient(aID)
+	if err == nil {
+		t.Error("expected error for invalid recipient")
+	}
+}
@@ -0,0 +1,103 @@
+package backupschedulemodel
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+	"github.com/teleclimber/DropServer/internal/sqlxprepper"
+)
+
+// BackupScheduleModel stores appspace automatic backup schedules
+type BackupScheduleModel struct {
+	DB *domain.DB
+
+	stmt struct {
+		get           *sqlx.Stmt
+		selectEnabled *sqlx.Stmt
+		upsert        *sqlx.Stmt
+		setLastRun    *sqlx.Stmt
+		delete        *sqlx.Stmt
+	}
+}
+
+func (m *BackupScheduleModel) PrepareStatements() {
+	p := sqlxprepper.NewPrepper(m.DB.Handle)
+
+	m.stmt.get = p.Prep(`SELECT * FROM appspace_backup_schedules WHERE appspace_id = ?`)
+
+	m.stmt.selectEnabled = p.Prep(`SELECT * FROM appspace_backup_schedules WHERE enabled = true`)
+
+	m.stmt.upsert = p.Prep(`INSERT INTO appspace_backup_schedules
+		("appspace_id", "enabled", "schedule", "keep_last", "keep_daily", "keep_weekly", "keep_monthly", "created")
+		VALUES (?, ?, ?, ?, ?, ?, ?, datetime("now"))
+		ON CONFLICT(appspace_id) DO UPDATE
+		SET enabled = excluded.enabled, schedule = excluded.schedule,
+		keep_last = excluded.keep_last, keep_daily = excluded.keep_daily,
+		keep_weekly = excluded.keep_weekly, keep_monthly = excluded.keep_monthly`)
+
+	m.stmt.setLastRun = p.Prep(`UPDATE appspace_backup_schedules SET last_run = ? WHERE appspace_id = ?`)
+
+	m.stmt.delete = p.Prep(`DELETE FROM appspace_backup_schedules WHERE appspace_id = ?`)
+}
+
+// Get returns the backup schedule of the appspace
+// It returns domain.ErrNoRowsInResultSet if the appspace has no schedule
+func (m *BackupScheduleModel) Get(appspaceID domain.AppspaceID) (domain.BackupSchedule, error) {
+	var ret domain.BackupSchedule
+	err := m.stmt.get.Get(&ret, appspaceID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ret, domain.ErrNoRowsInResultSet
+		}
+		m.getLogger("Get()").AppspaceID(appspaceID).Error(err)
+	}
+	return ret, err
+}
+
+// GetAllEnabled returns all enabled backup schedules
+func (m *BackupScheduleModel) GetAllEnabled() (schedules []domain.BackupSchedule, err error) {
+	err = m.stmt.selectEnabled.Select(&schedules)
+	if err != nil {
+		m.getLogger("GetAllEnabled()").Error(err)
+	}
+	return
+}
+
+// Set creates or updates the appspace's schedule.
+// Created and LastRun are ignored.
+func (m *BackupScheduleModel) Set(s domain.BackupSchedule) error {
+	_, err := m.stmt.upsert.Exec(s.AppspaceID, s.Enabled, s.Schedule, s.KeepLast, s.KeepDaily, s.KeepWeekly, s.KeepMonthly)
+	if err != nil {
+		m.getLogger("Set()").AppspaceID(s.AppspaceID).Error(err)
+	}
+	return err
+}
+
+// SetLastRun records the time of the last scheduled backup
+func (m *BackupScheduleModel) SetLastRun(appspaceID domain.AppspaceID, t time.Time) error {
+	_, err := m.stmt.setLastRun.Exec(t, appspaceID)
+	if err != nil {
+		m.getLogger("SetLastRun()").AppspaceID(appspaceID).Error(err)
+	}
+	return err
+}
+
+// Delete removes the appspace's schedule
+func (m *BackupScheduleModel) Delete(appspaceID domain.AppspaceID) error {
+	_, err := m.stmt.delete.Exec(appspaceID)
+	if err != nil {
+		m.getLogger("Delete()").AppspaceID(appspaceID).Error(err)
+	}
+	return err
+}
+
+func (m *BackupScheduleModel) getLogger(note string) *record.DsLogger {
+	r := record.NewDsLogger().AddNote("BackupScheduleModel")
+	if note != "" {
+		r.AddNote(note)
+	}
+	return r
+}
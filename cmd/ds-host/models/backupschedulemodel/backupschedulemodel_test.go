@@ -0,0 +1,120 @@
+package backupschedulemodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/migrate"
+)
+
+func TestPrepareStatements(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	model := &BackupScheduleModel{
+		DB: &domain.DB{Handle: h}}
+
+	model.PrepareStatements()
+}
+
+func TestSetGet(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	model := &BackupScheduleModel{
+		DB: &domain.DB{Handle: h}}
+	model.PrepareStatements()
+
+	aID := domain.AppspaceID(7)
+
+	_, err := model.Get(aID)
+	if err != domain.ErrNoRowsInResultSet {
+		t.Error("expected domain.ErrNoRowsInResultSet")
+	}
+
+	err = model.Set(domain.BackupSchedule{AppspaceID: aID, Enabled: true, Schedule: "daily", KeepLast: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := model.Get(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Enabled || s.Schedule != "daily" || s.KeepLast != 3 || s.LastRun.Valid {
+		t.Errorf("unexpected schedule: %v", s)
+	}
+	created := s.Created
+	if created.IsZero() {
+		t.Error("expected created to be set")
+	}
+
+	err = model.Set(domain.BackupSchedule{AppspaceID: aID, Enabled: false, Schedule: "weekly", KeepWeekly: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = model.Get(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Enabled || s.Schedule != "weekly" || s.KeepLast != 0 || s.KeepWeekly != 4 {
+		t.Errorf("unexpected schedule: %v", s)
+	}
+	if !s.Created.Equal(created) {
+		t.Error("created should not change on update")
+	}
+}
+
+func TestGetAllEnabled(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	model := &BackupScheduleModel{
+		DB: &domain.DB{Handle: h}}
+	model.PrepareStatements()
+
+	model.Set(domain.BackupSchedule{AppspaceID: 7, Enabled: true, Schedule: "daily"})
+	model.Set(domain.BackupSchedule{AppspaceID: 11, Enabled: false, Schedule: "daily"})
+
+	schedules, err := model.GetAllEnabled()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedules) != 1 || schedules[0].AppspaceID != 7 {
+		t.Errorf("unexpected schedules: %v", schedules)
+	}
+}
+
+func TestSetLastRunDelete(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	model := &BackupScheduleModel{
+		DB: &domain.DB{Handle: h}}
+	model.PrepareStatements()
+
+	aID := domain.AppspaceID(7)
+	model.Set(domain.BackupSchedule{AppspaceID: aID, Enabled: true, Schedule: "daily"})
+
+	lastRun := time.Date(2024, 3, 13, 3, 0, 0, 0, time.UTC)
+	err := model.SetLastRun(aID, lastRun)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := model.Get(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.LastRun.Valid || !s.LastRun.Time.Equal(lastRun) {
+		t.Errorf("unexpected last run: %v", s.LastRun)
+	}
+
+	err = model.Delete(aID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = model.Get(aID)
+	if err != domain.ErrNoRowsInResultSet {
+		t.Error("expected domain.ErrNoRowsInResultSet")
+	}
+}
@@ -23,9 +23,15 @@ type CookieModel struct {
 
 	stmt struct {
 		selectCookieID *sqlx.Stmt
+		selectUser     *sqlx.Stmt
+		selectAppspace *sqlx.Stmt
 		create         *sqlx.Stmt
 		refresh        *sqlx.Stmt
 		delete         *sqlx.Stmt
+		deleteUser     *sqlx.Stmt
+		deleteAppspace *sqlx.Stmt
+		deleteProxy    *sqlx.Stmt
+		deleteExpired  *sqlx.Stmt
 	}
 }
 
@@ -59,9 +65,21 @@ func (m *CookieModel) PrepareStatements() {
 	p := prepper{handle: m.DB.Handle}
 
 	m.stmt.selectCookieID = p.prep(`SELECT * FROM cookies WHERE cookie_id = ?`)
-	m.stmt.create = p.prep(`INSERT INTO cookies VALUES (?, ?, ?, ?, ?, ?, ?)`)
-	m.stmt.refresh = p.prep(`UPDATE cookies SET expires = ? WHERE cookie_id = ?`)
+	m.stmt.selectUser = p.prep(`SELECT * FROM cookies
+		WHERE user_id = ? AND user_account = 1 AND second_factor_pending = 0 AND expires > ?
+		ORDER BY last_seen DESC`)
+	m.stmt.selectAppspace = p.prep(`SELECT * FROM cookies
+		WHERE appspace_id = ? AND user_account = 0 AND expires > ?
+		ORDER BY last_seen DESC`)
+	m.stmt.create = p.prep(`INSERT INTO cookies
+		(cookie_id, user_id, expires, user_account, appspace_id, proxy_id, domain, second_factor_pending, user_agent, ip, created, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	m.stmt.refresh = p.prep(`UPDATE cookies SET expires = ?, last_seen = ? WHERE cookie_id = ?`)
 	m.stmt.delete = p.prep(`DELETE FROM cookies WHERE cookie_id = ?`)
+	m.stmt.deleteUser = p.prep(`DELETE FROM cookies WHERE user_id = ? AND user_account = 1 AND cookie_id != ?`)
+	m.stmt.deleteAppspace = p.prep(`DELETE FROM cookies WHERE appspace_id = ? AND user_account = 0`)
+	m.stmt.deleteProxy = p.prep(`DELETE FROM cookies WHERE appspace_id = ? AND proxy_id = ? AND user_account = 0`)
+	m.stmt.deleteExpired = p.prep(`DELETE FROM cookies WHERE expires <= ?`)
 
 	p.checkErrors()
 }
@@ -80,7 +98,9 @@ func (m *CookieModel) Create(cookie domain.Cookie) (string, error) { // maybe we
 	}
 	cookieID := UUID.String()
 
-	_, err = m.stmt.create.Exec(cookieID, cookie.UserID, cookie.Expires, cookie.UserAccount, cookie.AppspaceID, cookie.ProxyID, cookie.DomainName)
+	now := time.Now()
+	_, err = m.stmt.create.Exec(cookieID, cookie.UserID, cookie.Expires, cookie.UserAccount, cookie.AppspaceID, cookie.ProxyID, cookie.DomainName, cookie.SecondFactorPending,
+		cookie.UserAgent, cookie.IP, now, now)
 	if err != nil {
 		m.getLogger("Create()").Error(err)
 		return "", err
@@ -104,9 +124,34 @@ func (m *CookieModel) Get(cookieID string) (domain.Cookie, error) {
 	return cookie, nil
 }
 
+// GetForUser returns the unexpired account cookies of the user,
+// most recently seen first.
+func (m *CookieModel) GetForUser(userID domain.UserID) ([]domain.Cookie, error) {
+	cookies := []domain.Cookie{}
+	err := m.stmt.selectUser.Select(&cookies, userID, time.Now())
+	if err != nil {
+		m.getLogger("GetForUser()").UserID(userID).Error(err)
+		return nil, err
+	}
+	return cookies, nil
+}
+
+// GetForAppspace returns the unexpired cookies of all users of the appspace,
+// most recently seen first.
+func (m *CookieModel) GetForAppspace(appspaceID domain.AppspaceID) ([]domain.Cookie, error) {
+	cookies := []domain.Cookie{}
+	err := m.stmt.selectAppspace.Select(&cookies, appspaceID, time.Now())
+	if err != nil {
+		m.getLogger("GetForAppspace()").AppspaceID(appspaceID).Error(err)
+		return nil, err
+	}
+	return cookies, nil
+}
+
 // UpdateExpires sets the expiration date on the cooke
+// and marks it as seen now.
 func (m *CookieModel) UpdateExpires(cookieID string, expires time.Time) error {
-	_, err := m.stmt.refresh.Exec(expires, cookieID)
+	_, err := m.stmt.refresh.Exec(expires, time.Now(), cookieID)
 	if err != nil {
 		m.getLogger("UpdateExpires()").Error(err)
 		return err
@@ -129,6 +174,47 @@ func (m *CookieModel) Delete(cookieID string) error {
 	return nil
 }
 
+// DeleteForUser removes all the user's account cookies except keepCookieID,
+// which can be "" to remove them all.
+func (m *CookieModel) DeleteForUser(userID domain.UserID, keepCookieID string) error {
+	_, err := m.stmt.deleteUser.Exec(userID, keepCookieID)
+	if err != nil {
+		m.getLogger("DeleteForUser()").UserID(userID).Error(err)
+		return err
+	}
+	return nil
+}
+
+// DeleteForAppspace removes the cookies of all users of the appspace
+func (m *CookieModel) DeleteForAppspace(appspaceID domain.AppspaceID) error {
+	_, err := m.stmt.deleteAppspace.Exec(appspaceID)
+	if err != nil {
+		m.getLogger("DeleteForAppspace()").AppspaceID(appspaceID).Error(err)
+		return err
+	}
+	return nil
+}
+
+// DeleteForProxy removes the cookies of one user of the appspace
+func (m *CookieModel) DeleteForProxy(appspaceID domain.AppspaceID, proxyID domain.ProxyID) error {
+	_, err := m.stmt.deleteProxy.Exec(appspaceID, proxyID)
+	if err != nil {
+		m.getLogger("DeleteForProxy()").AppspaceID(appspaceID).Error(err)
+		return err
+	}
+	return nil
+}
+
+// DeleteExpired removes expired cookies
+func (m *CookieModel) DeleteExpired() error {
+	_, err := m.stmt.deleteExpired.Exec(time.Now())
+	if err != nil {
+		m.getLogger("DeleteExpired()").Error(err)
+		return err
+	}
+	return nil
+}
+
 func (m *CookieModel) getLogger(note string) *record.DsLogger {
 	r := record.NewDsLogger().AddNote("CookieModel")
 	if note != "" {
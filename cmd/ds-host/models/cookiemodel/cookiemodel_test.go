@@ -83,6 +83,9 @@ func TestCRUD(t *testing.T) {
 	if cookie.DomainName != dom {
 		t.Error("mismatched cookie domain")
 	}
+	if cookie.SecondFactorPending {
+		t.Error("expected second factor pending to be false")
+	}
 
 	// can't compare expires times directly because something in the way timezones are represented changes.
 	dt := cookie.Expires.Sub(c.Expires)
@@ -168,6 +171,98 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestSessions(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	cookieModel := &CookieModel{
+		DB: &domain.DB{Handle: h}}
+	cookieModel.PrepareStatements()
+
+	userID := domain.UserID(100)
+	appspaceID := domain.AppspaceID(7)
+	expires := time.Now().Add(time.Hour)
+
+	create := func(c domain.Cookie) string {
+		c.Expires = expires
+		cookieID, err := cookieModel.Create(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cookieID
+	}
+	current := create(domain.Cookie{UserID: userID, UserAccount: true, UserAgent: "Firefox", IP: "192.0.2.1"})
+	create(domain.Cookie{UserID: userID, UserAccount: true})
+	create(domain.Cookie{UserID: userID, UserAccount: true, SecondFactorPending: true})
+	create(domain.Cookie{UserID: domain.UserID(200), UserAccount: true})
+	create(domain.Cookie{AppspaceID: appspaceID, ProxyID: "abc"})
+	create(domain.Cookie{AppspaceID: appspaceID, ProxyID: "def"})
+
+	expired, err := cookieModel.Create(domain.Cookie{UserID: userID, UserAccount: true, Expires: time.Now().Add(-time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cookies, err := cookieModel.GetForUser(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 2 {
+		t.Fatalf("expected 2 account cookies, got %v", cookies)
+	}
+	cookie, _ := cookieModel.Get(current)
+	if cookie.UserAgent != "Firefox" || cookie.IP != "192.0.2.1" || cookie.Created.IsZero() || cookie.LastSeen.IsZero() {
+		t.Errorf("expected session details to be stored: %v", cookie)
+	}
+
+	cookies, err = cookieModel.GetForAppspace(appspaceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 2 {
+		t.Errorf("expected 2 appspace cookies, got %v", cookies)
+	}
+
+	err = cookieModel.DeleteExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cookieModel.Get(expired)
+	if err != sql.ErrNoRows {
+		t.Error("expected expired cookie to be deleted")
+	}
+
+	err = cookieModel.DeleteForUser(userID, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies, _ = cookieModel.GetForUser(userID)
+	if len(cookies) != 1 || cookies[0].CookieID != current {
+		t.Errorf("expected only the kept cookie, got %v", cookies)
+	}
+	cookies, _ = cookieModel.GetForUser(domain.UserID(200))
+	if len(cookies) != 1 {
+		t.Error("expected other user's cookie to remain")
+	}
+
+	err = cookieModel.DeleteForProxy(appspaceID, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies, _ = cookieModel.GetForAppspace(appspaceID)
+	if len(cookies) != 1 || cookies[0].ProxyID != "def" {
+		t.Errorf("expected only def's cookie, got %v", cookies)
+	}
+	err = cookieModel.DeleteForAppspace(appspaceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies, _ = cookieModel.GetForAppspace(appspaceID)
+	if len(cookies) != 0 {
+		t.Errorf("expected no appspace cookies, got %v", cookies)
+	}
+}
+
 // more things to test:
 // - appspace_id
 // - bad input of appspace_id + user_account?
@@ -0,0 +1,90 @@
+package passwordresetmodel
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+	"github.com/teleclimber/DropServer/internal/sqlxprepper"
+)
+
+// PasswordResetModel stores hashes of password reset tokens
+type PasswordResetModel struct {
+	DB *domain.DB
+
+	stmt struct {
+		create        *sqlx.Stmt
+		get           *sqlx.Stmt
+		deleteForUser *sqlx.Stmt
+		deleteExpired *sqlx.Stmt
+	}
+}
+
+// PrepareStatements prepares the statements
+func (m *PasswordResetModel) PrepareStatements() {
+	p := sqlxprepper.NewPrepper(m.DB.Handle)
+
+	m.stmt.create = p.Prep(`INSERT INTO password_resets (token_hash, user_id, created, expires) VALUES (?, ?, ?, ?)`)
+	m.stmt.get = p.Prep(`SELECT user_id, created, expires FROM password_resets WHERE token_hash = ? AND expires > ?`)
+	m.stmt.deleteForUser = p.Prep(`DELETE FROM password_resets WHERE user_id = ?`)
+	m.stmt.deleteExpired = p.Prep(`DELETE FROM password_resets WHERE expires <= ?`)
+}
+
+// Create stores the token hash for the user.
+// Any previous token for the user is removed so only the latest link works.
+func (m *PasswordResetModel) Create(userID domain.UserID, tokenHash string, expires time.Time) error {
+	err := m.DeleteForUser(userID)
+	if err != nil {
+		return err
+	}
+	_, err = m.stmt.create.Exec(tokenHash, userID, time.Now(), expires)
+	if err != nil {
+		m.getLogger("Create()").UserID(userID).Error(err)
+		return err
+	}
+	return nil
+}
+
+// Get returns the password reset for the token hash.
+// It returns sql.ErrNoRows if there is none or it expired.
+func (m *PasswordResetModel) Get(tokenHash string) (domain.PasswordReset, error) {
+	var reset domain.PasswordReset
+	err := m.stmt.get.QueryRowx(tokenHash, time.Now()).StructScan(&reset)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			m.getLogger("Get()").Error(err)
+		}
+		return domain.PasswordReset{}, err
+	}
+	return reset, nil
+}
+
+// DeleteForUser removes all of the user's tokens
+func (m *PasswordResetModel) DeleteForUser(userID domain.UserID) error {
+	_, err := m.stmt.deleteForUser.Exec(userID)
+	if err != nil {
+		m.getLogger("DeleteForUser()").UserID(userID).Error(err)
+		return err
+	}
+	return nil
+}
+
+// DeleteExpired removes tokens that can no longer be used
+func (m *PasswordResetModel) DeleteExpired() error {
+	_, err := m.stmt.deleteExpired.Exec(time.Now())
+	if err != nil {
+		m.getLogger("DeleteExpired()").Error(err)
+		return err
+	}
+	return nil
+}
+
+func (m *PasswordResetModel) getLogger(note string) *record.DsLogger {
+	r := record.NewDsLogger().AddNote("PasswordResetModel")
+	if note != "" {
+		r.AddNote(note)
+	}
+	return r
+}
@@ -0,0 +1,83 @@
+package passwordresetmodel
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/migrate"
+)
+
+func getModel(t *testing.T) *PasswordResetModel {
+	h := migrate.MakeSqliteDummyDB()
+	t.Cleanup(func() { h.Close() })
+	model := &PasswordResetModel{
+		DB: &domain.DB{Handle: h}}
+	model.PrepareStatements()
+	return model
+}
+
+func TestCreateGet(t *testing.T) {
+	model := getModel(t)
+	userID := domain.UserID(7)
+
+	_, err := model.Get("abc")
+	if err != sql.ErrNoRows {
+		t.Errorf("expected no rows, got %v", err)
+	}
+
+	err = model.Create(userID, "abc", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reset, err := model.Get("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reset.UserID != userID {
+		t.Errorf("unexpected reset %v", reset)
+	}
+
+	// a new token replaces the previous one
+	err = model.Create(userID, "def", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = model.Get("abc")
+	if err != sql.ErrNoRows {
+		t.Errorf("expected old token to be gone, got %v", err)
+	}
+
+	err = model.DeleteForUser(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = model.Get("def")
+	if err != sql.ErrNoRows {
+		t.Errorf("expected token to be deleted, got %v", err)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	model := getModel(t)
+
+	err := model.Create(domain.UserID(7), "abc", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = model.Get("abc")
+	if err != sql.ErrNoRows {
+		t.Errorf("expected expired token to not be returned, got %v", err)
+	}
+
+	err = model.DeleteExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	model.DB.Handle.Get(&count, `SELECT COUNT(*) FROM password_resets`)
+	if count != 0 {
+		t.Errorf("expected expired token to be deleted, got %v rows", count)
+	}
+}
@@ -30,6 +30,8 @@ type SandboxRunsModel struct {
 		update         *sqlx.Stmt
 		end            *sqlx.Stmt
 		sumAppspace    *sqlx.Stmt
+		firstStart     *sqlx.Stmt
+		deleteBefore   *sqlx.Stmt
 	}
 }
 
@@ -48,17 +50,25 @@ func (m *SandboxRunsModel) PrepareStatements() {
 	m.stmt.insert = p.Prep(`INSERT INTO sandbox_runs
 		(instance, local_id, owner_id, app_id, version, appspace_id, operation, cgroup, start ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 
-	m.stmt.update = p.Prep(`UPDATE sandbox_runs SET end = ?, tied_up_ms = ?, cpu_usec = ?, memory_byte_sec = ?, io_bytes = ?, io_ops = ? WHERE sandbox_id = ?`)
+	m.stmt.update = p.Prep(`UPDATE sandbox_runs SET end = ?, tied_up_ms = ?, cpu_usec = ?, memory_byte_sec = ?, io_bytes = ?, io_ops = ?,
+		out_requests = ?, out_bytes_sent = ?, out_bytes_received = ? WHERE sandbox_id = ?`)
 
 	m.stmt.sumAppspace = p.Prep(`SELECT 
 		IFNULL(SUM(tied_up_ms), 0) as tied_up_ms,
 		IFNULL(SUM(cpu_usec), 0) as cpu_usec,
 		IFNULL(SUM(memory_byte_sec), 0) as memory_byte_sec,
 		IFNULL(SUM(io_bytes), 0) as io_bytes,
-		IFNULL(SUM(io_ops), 0) as io_ops
+		IFNULL(SUM(io_ops), 0) as io_ops,
+		IFNULL(SUM(out_requests), 0) as out_requests,
+		IFNULL(SUM(out_bytes_sent), 0) as out_bytes_sent,
+		IFNULL(SUM(out_bytes_received), 0) as out_bytes_received
 		FROM sandbox_runs 
 		WHERE owner_id = ? AND appspace_id = ?
 		AND start >= ? AND start < ?`)
+
+	m.stmt.firstStart = p.Prep(`SELECT start FROM sandbox_runs ORDER BY start LIMIT 1`)
+
+	m.stmt.deleteBefore = p.Prep(`DELETE FROM sandbox_runs WHERE end IS NOT NULL AND start < ?`)
 }
 
 func (m *SandboxRunsModel) Create(run domain.SandboxRunIDs, start time.Time) (int, error) {
@@ -100,7 +110,8 @@ func (m *SandboxRunsModel) update(sandboxID int, end interface{}, data domain.Sa
 		}
 		return err
 	}
-	_, err = m.stmt.update.Exec(end, data.TiedUpMs, data.CpuUsec, data.MemoryByteSec, data.IOBytes, data.IOs, sandboxID)
+	_, err = m.stmt.update.Exec(end, data.TiedUpMs, data.CpuUsec, data.MemoryByteSec, data.IOBytes, data.IOs,
+		data.OutRequests, data.OutBytesSent, data.OutBytesReceived, sandboxID)
 	if err != nil {
 		return err
 	}
@@ -146,6 +157,32 @@ func (m *SandboxRunsModel) AppspaceSums(ownerID domain.UserID, appspaceID domain
 	return ret, nil
 }
 
+// FirstStart returns the start time of the oldest run.
+// It returns false if there are no runs.
+func (m *SandboxRunsModel) FirstStart() (time.Time, bool, error) {
+	var start time.Time
+	err := m.stmt.firstStart.QueryRowx().Scan(&start)
+	if err == sql.ErrNoRows {
+		return start, false, nil
+	}
+	if err != nil {
+		m.getLogger("FirstStart()").Error(err)
+		return start, false, err
+	}
+	return start, true, nil
+}
+
+// DeleteBefore removes the runs that ended and started before the time.
+// It returns the number of runs deleted.
+func (m *SandboxRunsModel) DeleteBefore(before time.Time) (int64, error) {
+	result, err := m.stmt.deleteBefore.Exec(before)
+	if err != nil {
+		m.getLogger("DeleteBefore()").Error(err)
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 func (m *SandboxRunsModel) getLogger(note string) *record.DsLogger {
 	r := record.NewDsLogger().AddNote("SandboxRunsModel")
 	if note != "" {
@@ -164,10 +164,10 @@ func TestAppspaceSum(t *testing.T) {
 		CGroup:     "test-cgroup"}
 
 	start1 := time.Date(2022, time.March, 18, 17, 0, 0, 0, time.UTC)
-	createRun(m, t, id1, start1, domain.SandboxRunData{TiedUpMs: 200, CpuUsec: 2000, MemoryByteSec: 2000})
+	createRun(m, t, id1, start1, domain.SandboxRunData{TiedUpMs: 200, CpuUsec: 2000, MemoryByteSec: 2000, OutRequests: 2, OutBytesSent: 20, OutBytesReceived: 200})
 
 	start2 := time.Date(2022, time.April, 12, 12, 41, 0, 0, time.UTC)
-	createRun(m, t, id1, start2, domain.SandboxRunData{TiedUpMs: 300, CpuUsec: 3000, MemoryByteSec: 3000})
+	createRun(m, t, id1, start2, domain.SandboxRunData{TiedUpMs: 300, CpuUsec: 3000, MemoryByteSec: 3000, OutRequests: 3, OutBytesSent: 30, OutBytesReceived: 300})
 
 	id2 := id1
 	id2.AppspaceID = domain.NewNullAppspaceID(domain.AppspaceID(999))
@@ -194,9 +194,12 @@ func TestAppspaceSum(t *testing.T) {
 		t.Error(err)
 	}
 	expected = domain.SandboxRunData{
-		TiedUpMs:      200,
-		CpuUsec:       2000,
-		MemoryByteSec: 2000,
+		TiedUpMs:         200,
+		CpuUsec:          2000,
+		MemoryByteSec:    2000,
+		OutRequests:      2,
+		OutBytesSent:     20,
+		OutBytesReceived: 200,
 	}
 	if !cmp.Equal(sums, expected) {
 		t.Log(cmp.Diff(sums, expected))
@@ -209,9 +212,12 @@ func TestAppspaceSum(t *testing.T) {
 		t.Error(err)
 	}
 	expected = domain.SandboxRunData{
-		TiedUpMs:      500,
-		CpuUsec:       5000,
-		MemoryByteSec: 5000,
+		TiedUpMs:         500,
+		CpuUsec:          5000,
+		MemoryByteSec:    5000,
+		OutRequests:      5,
+		OutBytesSent:     50,
+		OutBytesReceived: 500,
 	}
 	if !cmp.Equal(sums, expected) {
 		t.Log(cmp.Diff(sums, expected))
@@ -233,3 +239,58 @@ func createRun(m *SandboxRunsModel, t *testing.T, ids domain.SandboxRunIDs, star
 func firstOf(m time.Month) time.Time {
 	return time.Date(2022, m, 1, 0, 0, 0, 0, time.UTC)
 }
+
+func TestFirstStartDeleteBefore(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	m := &SandboxRunsModel{
+		DB: &domain.DB{Handle: h}}
+	m.PrepareStatements()
+
+	_, ok, err := m.FirstStart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("expected no first start")
+	}
+
+	ids := domain.SandboxRunIDs{
+		Instance:  "ds-test",
+		OwnerID:   domain.UserID(123),
+		AppID:     domain.AppID(456),
+		Version:   domain.Version("0.0.1"),
+		Operation: "op",
+	}
+	createRun(m, t, ids, firstOf(time.March), domain.SandboxRunData{})
+	createRun(m, t, ids, firstOf(time.May), domain.SandboxRunData{})
+	// still running:
+	_, err = m.Create(ids, firstOf(time.February))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, ok, err := m.FirstStart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || !first.Equal(firstOf(time.February)) {
+		t.Errorf("unexpected first start: %v", first)
+	}
+
+	num, err := m.DeleteBefore(firstOf(time.April))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if num != 1 {
+		t.Errorf("expected one run deleted, got %v", num)
+	}
+	runs, err := m.GetApp(ids.OwnerID, ids.AppID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 {
+		t.Errorf("expected two runs left, got %v", len(runs))
+	}
+}
@@ -15,6 +15,7 @@ type SettingsModel struct {
 		getAll          *sqlx.Stmt // not sure about this one...
 		set             *sqlx.Stmt
 		setReg          *sqlx.Stmt
+		setRequire2FA   *sqlx.Stmt
 		getTSNet        *sqlx.Stmt
 		setTSNet        *sqlx.Stmt
 		setTSNetConnect *sqlx.Stmt
@@ -27,8 +28,9 @@ func (m *SettingsModel) PrepareStatements() {
 
 	// We are using the params table, which is also used by the db migration system to stash the current schema
 
-	m.stmt.getAll = p.Prep(`SELECT registration_open FROM settings WHERE id = 1`)
+	m.stmt.getAll = p.Prep(`SELECT registration_open, require_2fa FROM settings WHERE id = 1`)
 	m.stmt.setReg = p.Prep(`UPDATE settings SET registration_open = ? WHERE id = 1`)
+	m.stmt.setRequire2FA = p.Prep(`UPDATE settings SET require_2fa = ? WHERE id = 1`)
 
 	m.stmt.getTSNet = p.Prep(`SELECT tsnet_control_url, tsnet_hostname, tsnet_connect FROM settings WHERE id = 1`)
 	m.stmt.setTSNet = p.Prep(`UPDATE settings SET tsnet_control_url = ?, tsnet_hostname = ?, tsnet_connect = ? WHERE id = 1`)
@@ -72,6 +74,17 @@ func (m *SettingsModel) SetRegistrationOpen(open bool) error {
 	return nil
 }
 
+// SetRequire2FA sets whether users must have a second factor
+func (m *SettingsModel) SetRequire2FA(require bool) error {
+	_, err := m.stmt.setRequire2FA.Exec(require)
+	if err != nil {
+		m.getLogger("SetRequire2FA()").Error(err)
+		return err
+	}
+
+	return nil
+}
+
 // TSNetConfig is domain.TSNetCommon
 // but with different db struct tags
 type TSNetConfig struct {
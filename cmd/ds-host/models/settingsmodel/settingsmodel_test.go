@@ -60,6 +60,35 @@ func TestSetRegistrationOpen(t *testing.T) {
 	}
 }
 
+func TestSetRequire2FA(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	settingsModel := &SettingsModel{
+		DB: &domain.DB{Handle: h}}
+	settingsModel.PrepareStatements()
+
+	settings, err := settingsModel.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Require2FA {
+		t.Error("2FA should not be required by default")
+	}
+
+	err = settingsModel.SetRequire2FA(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings, err = settingsModel.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !settings.Require2FA {
+		t.Error("2FA should be required")
+	}
+}
+
 func TestSetTSNet(t *testing.T) {
 	h := migrate.MakeSqliteDummyDB()
 	defer h.Close()
@@ -0,0 +1,251 @@
+package twofactormodel
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+	"github.com/teleclimber/DropServer/internal/sqlxprepper"
+)
+
+// TwoFactorModel stores users' second factors:
+// TOTP secrets, recovery codes and passkeys
+type TwoFactorModel struct {
+	DB *domain.DB
+
+	stmt struct {
+		getTOTP            *sqlx.Stmt
+		setTOTP            *sqlx.Stmt
+		enableTOTP         *sqlx.Stmt
+		setTOTPStep        *sqlx.Stmt
+		deleteTOTP         *sqlx.Stmt
+		useRecoveryCode    *sqlx.Stmt
+		countRecoveryCodes *sqlx.Stmt
+		createPasskey      *sqlx.Stmt
+		getPasskey         *sqlx.Stmt
+		getUserPasskeys    *sqlx.Stmt
+		updatePasskeyUse   *sqlx.Stmt
+		deletePasskey      *sqlx.Stmt
+	}
+}
+
+// PrepareStatements prepares the statements
+func (m *TwoFactorModel) PrepareStatements() {
+	p := sqlxprepper.NewPrepper(m.DB.Handle)
+
+	m.stmt.getTOTP = p.Prep(`SELECT user_id, secret, enabled, last_step, created FROM user_totp WHERE user_id = ?`)
+	m.stmt.setTOTP = p.Prep(`INSERT OR REPLACE INTO user_totp (user_id, secret, enabled, last_step, created) VALUES (?, ?, 0, 0, ?)`)
+	m.stmt.enableTOTP = p.Prep(`UPDATE user_totp SET enabled = 1 WHERE user_id = ?`)
+	m.stmt.setTOTPStep = p.Prep(`UPDATE user_totp SET last_step = ? WHERE user_id = ? AND last_step < ?`)
+	m.stmt.deleteTOTP = p.Prep(`DELETE FROM user_totp WHERE user_id = ?`)
+
+	m.stmt.useRecoveryCode = p.Prep(`UPDATE user_recovery_codes SET used = ? WHERE user_id = ? AND code_hash = ? AND used IS NULL`)
+	m.stmt.countRecoveryCodes = p.Prep(`SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = ? AND used IS NULL`)
+
+	m.stmt.createPasskey = p.Prep(`INSERT INTO user_passkeys
+		(credential_id, user_id, name, public_key, sign_count, created)
+		VALUES (?, ?, ?, ?, ?, ?)`)
+	m.stmt.getPasskey = p.Prep(`SELECT * FROM user_passkeys WHERE credential_id = ?`)
+	m.stmt.getUserPasskeys = p.Prep(`SELECT * FROM user_passkeys WHERE user_id = ? ORDER BY created`)
+	m.stmt.updatePasskeyUse = p.Prep(`UPDATE user_passkeys SET sign_count = ?, last_used = ? WHERE credential_id = ?`)
+	m.stmt.deletePasskey = p.Prep(`DELETE FROM user_passkeys WHERE user_id = ? AND credential_id = ?`)
+}
+
+// GetTOTP returns the user's TOTP secret.
+// It returns sql.ErrNoRows if there is none.
+func (m *TwoFactorModel) GetTOTP(userID domain.UserID) (domain.UserTOTP, error) {
+	var totp domain.UserTOTP
+	err := m.stmt.getTOTP.QueryRowx(userID).StructScan(&totp)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			m.getLogger("GetTOTP()").UserID(userID).Error(err)
+		}
+		return domain.UserTOTP{}, err
+	}
+	return totp, nil
+}
+
+// SetTOTP stores a new secret for the user, replacing any existing one.
+// The new secret is not enabled.
+func (m *TwoFactorModel) SetTOTP(userID domain.UserID, secret string) error {
+	_, err := m.stmt.setTOTP.Exec(userID, secret, time.Now())
+	if err != nil {
+		m.getLogger("SetTOTP()").UserID(userID).Error(err)
+		return err
+	}
+	return nil
+}
+
+// EnableTOTP enables the user's TOTP secret
+func (m *TwoFactorModel) EnableTOTP(userID domain.UserID) error {
+	result, err := m.stmt.enableTOTP.Exec(userID)
+	if err != nil {
+		m.getLogger("EnableTOTP()").UserID(userID).Error(err)
+		return err
+	}
+	numRows, err := result.RowsAffected()
+	if err != nil {
+		m.getLogger("EnableTOTP()").UserID(userID).Error(err)
+		return err
+	}
+	if numRows != 1 {
+		return domain.ErrNoRowsAffected
+	}
+	return nil
+}
+
+// SetTOTPStep records the time step of a code that was used.
+// It returns false if that step or a later one was already used.
+func (m *TwoFactorModel) SetTOTPStep(userID domain.UserID, step int64) (bool, error) {
+	result, err := m.stmt.setTOTPStep.Exec(step, userID, step)
+	if err != nil {
+		m.getLogger("SetTOTPStep()").UserID(userID).Error(err)
+		return false, err
+	}
+	numRows, err := result.RowsAffected()
+	if err != nil {
+		m.getLogger("SetTOTPStep()").UserID(userID).Error(err)
+		return false, err
+	}
+	return numRows == 1, nil
+}
+
+// DeleteTOTP removes the user's TOTP secret
+func (m *TwoFactorModel) DeleteTOTP(userID domain.UserID) error {
+	_, err := m.stmt.deleteTOTP.Exec(userID)
+	if err != nil {
+		m.getLogger("DeleteTOTP()").UserID(userID).Error(err)
+		return err
+	}
+	return nil
+}
+
+// SetRecoveryCodes replaces the user's recovery codes
+func (m *TwoFactorModel) SetRecoveryCodes(userID domain.UserID, hashes []string) error {
+	tx, err := m.DB.Handle.Beginx()
+	if err != nil {
+		m.getLogger("SetRecoveryCodes(), Beginx()").UserID(userID).Error(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM user_recovery_codes WHERE user_id = ?`, userID)
+	if err != nil {
+		m.getLogger("SetRecoveryCodes(), delete").UserID(userID).Error(err)
+		return err
+	}
+	for _, h := range hashes {
+		_, err = tx.Exec(`INSERT INTO user_recovery_codes (user_id, code_hash) VALUES (?, ?)`, userID, h)
+		if err != nil {
+			m.getLogger("SetRecoveryCodes(), insert").UserID(userID).Error(err)
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		m.getLogger("SetRecoveryCodes(), Commit()").UserID(userID).Error(err)
+		return err
+	}
+	return nil
+}
+
+// UseRecoveryCode marks the code as used.
+// It returns false if the code does not exist or was already used.
+func (m *TwoFactorModel) UseRecoveryCode(userID domain.UserID, hash string) (bool, error) {
+	result, err := m.stmt.useRecoveryCode.Exec(time.Now(), userID, hash)
+	if err != nil {
+		m.getLogger("UseRecoveryCode()").UserID(userID).Error(err)
+		return false, err
+	}
+	numRows, err := result.RowsAffected()
+	if err != nil {
+		m.getLogger("UseRecoveryCode()").UserID(userID).Error(err)
+		return false, err
+	}
+	return numRows == 1, nil
+}
+
+// CountRecoveryCodes returns the number of unused recovery codes
+func (m *TwoFactorModel) CountRecoveryCodes(userID domain.UserID) (int, error) {
+	var count int
+	err := m.stmt.countRecoveryCodes.Get(&count, userID)
+	if err != nil {
+		m.getLogger("CountRecoveryCodes()").UserID(userID).Error(err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// CreatePasskey stores a new passkey
+func (m *TwoFactorModel) CreatePasskey(passkey domain.Passkey) error {
+	_, err := m.stmt.createPasskey.Exec(passkey.CredentialID, passkey.UserID, passkey.Name, passkey.PublicKey, passkey.SignCount, time.Now())
+	if err != nil {
+		m.getLogger("CreatePasskey()").UserID(passkey.UserID).Error(err)
+		return err
+	}
+	return nil
+}
+
+// GetPasskey returns the passkey with the credential id.
+// It returns sql.ErrNoRows if there is none.
+func (m *TwoFactorModel) GetPasskey(credentialID string) (domain.Passkey, error) {
+	var passkey domain.Passkey
+	err := m.stmt.getPasskey.QueryRowx(credentialID).StructScan(&passkey)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			m.getLogger("GetPasskey()").Error(err)
+		}
+		return domain.Passkey{}, err
+	}
+	return passkey, nil
+}
+
+// GetUserPasskeys returns all of a user's passkeys
+func (m *TwoFactorModel) GetUserPasskeys(userID domain.UserID) ([]domain.Passkey, error) {
+	ret := []domain.Passkey{}
+	err := m.stmt.getUserPasskeys.Select(&ret, userID)
+	if err != nil {
+		m.getLogger("GetUserPasskeys()").UserID(userID).Error(err)
+		return nil, err
+	}
+	return ret, nil
+}
+
+// UpdatePasskeyUse stores the signature counter and time of last use
+func (m *TwoFactorModel) UpdatePasskeyUse(credentialID string, signCount uint32) error {
+	_, err := m.stmt.updatePasskeyUse.Exec(signCount, time.Now(), credentialID)
+	if err != nil {
+		m.getLogger("UpdatePasskeyUse()").Error(err)
+		return err
+	}
+	return nil
+}
+
+// DeletePasskey removes a user's passkey
+func (m *TwoFactorModel) DeletePasskey(userID domain.UserID, credentialID string) error {
+	result, err := m.stmt.deletePasskey.Exec(userID, credentialID)
+	if err != nil {
+		m.getLogger("DeletePasskey()").UserID(userID).Error(err)
+		return err
+	}
+	numRows, err := result.RowsAffected()
+	if err != nil {
+		m.getLogger("DeletePasskey()").UserID(userID).Error(err)
+		return err
+	}
+	if numRows != 1 {
+		return domain.ErrNoRowsAffected
+	}
+	return nil
+}
+
+func (m *TwoFactorModel) getLogger(note string) *record.DsLogger {
+	r := record.NewDsLogger().AddNote("TwoFactorModel")
+	if note != "" {
+		r.AddNote(note)
+	}
+	return r
+}
@@ -0,0 +1,159 @@
+package twofactormodel
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/migrate"
+)
+
+func getModel(t *testing.T) *TwoFactorModel {
+	h := migrate.MakeSqliteDummyDB()
+	t.Cleanup(func() { h.Close() })
+	model := &TwoFactorModel{
+		DB: &domain.DB{Handle: h}}
+	model.PrepareStatements()
+	return model
+}
+
+func TestTOTP(t *testing.T) {
+	model := getModel(t)
+	userID := domain.UserID(7)
+
+	_, err := model.GetTOTP(userID)
+	if err != sql.ErrNoRows {
+		t.Errorf("expected no rows, got %v", err)
+	}
+
+	err = model.SetTOTP(userID, "ABCDEF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	totp, err := model.GetTOTP(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totp.Secret != "ABCDEF" || totp.Enabled {
+		t.Errorf("unexpected totp %v", totp)
+	}
+
+	err = model.EnableTOTP(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := model.SetTOTPStep(userID, 100)
+	if err != nil || !ok {
+		t.Errorf("expected step to be set: %v %v", ok, err)
+	}
+	ok, err = model.SetTOTPStep(userID, 100)
+	if err != nil || ok {
+		t.Errorf("expected step to be rejected: %v %v", ok, err)
+	}
+	totp, _ = model.GetTOTP(userID)
+	if !totp.Enabled || totp.LastStep != 100 {
+		t.Errorf("unexpected totp %v", totp)
+	}
+
+	// new secret replaces the old one and is not enabled
+	err = model.SetTOTP(userID, "GHIJKL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	totp, _ = model.GetTOTP(userID)
+	if totp.Secret != "GHIJKL" || totp.Enabled || totp.LastStep != 0 {
+		t.Errorf("unexpected totp %v", totp)
+	}
+
+	err = model.DeleteTOTP(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = model.EnableTOTP(userID)
+	if err != domain.ErrNoRowsAffected {
+		t.Errorf("expected no rows affected, got %v", err)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	model := getModel(t)
+	userID := domain.UserID(7)
+
+	err := model.SetRecoveryCodes(userID, []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err := model.UseRecoveryCode(userID, "b")
+	if err != nil || !ok {
+		t.Errorf("expected code to be used: %v %v", ok, err)
+	}
+	ok, _ = model.UseRecoveryCode(userID, "b")
+	if ok {
+		t.Error("expected code not to be used twice")
+	}
+	ok, _ = model.UseRecoveryCode(domain.UserID(8), "a")
+	if ok {
+		t.Error("expected code not to be used by other user")
+	}
+	count, err := model.CountRecoveryCodes(userID)
+	if err != nil || count != 2 {
+		t.Errorf("expected 2 codes left: %v %v", count, err)
+	}
+
+	err = model.SetRecoveryCodes(userID, []string{"d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, _ = model.CountRecoveryCodes(userID)
+	if count != 1 {
+		t.Errorf("expected codes to be replaced, got %v", count)
+	}
+}
+
+func TestPasskeys(t *testing.T) {
+	model := getModel(t)
+	userID := domain.UserID(7)
+
+	err := model.CreatePasskey(domain.Passkey{
+		CredentialID: "cred1",
+		UserID:       userID,
+		Name:         "laptop",
+		PublicKey:    []byte{1, 2, 3},
+		SignCount:    4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := model.GetPasskey("cred1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.UserID != userID || p.Name != "laptop" || len(p.PublicKey) != 3 || p.SignCount != 4 || p.LastUsed.Valid {
+		t.Errorf("unexpected passkey %v", p)
+	}
+
+	err = model.UpdatePasskeyUse("cred1", 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passkeys, err := model.GetUserPasskeys(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(passkeys) != 1 || passkeys[0].SignCount != 9 || !passkeys[0].LastUsed.Valid {
+		t.Errorf("unexpected passkeys %v", passkeys)
+	}
+
+	err = model.DeletePasskey(domain.UserID(8), "cred1")
+	if err != domain.ErrNoRowsAffected {
+		t.Errorf("expected other user not to delete passkey, got %v", err)
+	}
+	err = model.DeletePasskey(userID, "cred1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = model.GetPasskey("cred1")
+	if err != sql.ErrNoRows {
+		t.Errorf("expected no rows, got %v", err)
+	}
+}
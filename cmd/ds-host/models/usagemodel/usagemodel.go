@@ -0,0 +1,166 @@
+package usagemodel
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+	"github.com/teleclimber/DropServer/internal/sqlxprepper"
+)
+
+// UsageModel stores sums of sandbox runs per day, week and month
+type UsageModel struct {
+	DB *domain.DB
+
+	stmt struct {
+		rollupDay      *sqlx.Stmt
+		rollupPeriod   *sqlx.Stmt
+		lastDay        *sqlx.Stmt
+		deleteBefore   *sqlx.Stmt
+		getForOwner    *sqlx.Stmt
+		getForAppspace *sqlx.Stmt
+		getUserTotals  *sqlx.Stmt
+	}
+}
+
+func (m *UsageModel) PrepareStatements() {
+	p := sqlxprepper.NewPrepper(m.DB.Handle)
+
+	// Runs are attributed to the period they started in.
+	m.stmt.rollupDay = p.Prep(`INSERT OR REPLACE INTO usage_rollups
+		(period, period_start, owner_id, appspace_id, app_id, num_runs,
+		tied_up_ms, cpu_usec, memory_byte_sec, io_bytes, io_ops,
+		out_requests, out_bytes_sent, out_bytes_received)
+		SELECT "day", ?, owner_id, IFNULL(appspace_id, 0), app_id, COUNT(*),
+		SUM(tied_up_ms), SUM(cpu_usec), SUM(memory_byte_sec), SUM(io_bytes), SUM(io_ops),
+		SUM(out_requests), SUM(out_bytes_sent), SUM(out_bytes_received)
+		FROM sandbox_runs
+		WHERE start >= ? AND start < ?
+		GROUP BY owner_id, IFNULL(appspace_id, 0), app_id`)
+
+	// Weeks and months are summed from days so that they survive pruned runs.
+	m.stmt.rollupPeriod = p.Prep(`INSERT OR REPLACE INTO usage_rollups
+		(period, period_start, owner_id, appspace_id, app_id, num_runs,
+		tied_up_ms, cpu_usec, memory_byte_sec, io_bytes, io_ops,
+		out_requests, out_bytes_sent, out_bytes_received)
+		SELECT ?, ?, owner_id, appspace_id, app_id, SUM(num_runs),
+		SUM(tied_up_ms), SUM(cpu_usec), SUM(memory_byte_sec), SUM(io_bytes), SUM(io_ops),
+		SUM(out_requests), SUM(out_bytes_sent), SUM(out_bytes_received)
+		FROM usage_rollups
+		WHERE period = "day" AND period_start >= ? AND period_start < ?
+		GROUP BY owner_id, appspace_id, app_id`)
+
+	m.stmt.lastDay = p.Prep(`SELECT period_start FROM usage_rollups
+		WHERE period = "day" ORDER BY period_start DESC LIMIT 1`)
+
+	m.stmt.deleteBefore = p.Prep(`DELETE FROM usage_rollups WHERE period = ? AND period_start < ?`)
+
+	m.stmt.getForOwner = p.Prep(`SELECT * FROM usage_rollups
+		WHERE owner_id = ? AND period = ? AND period_start >= ? AND period_start < ?
+		ORDER BY period_start, appspace_id, app_id`)
+
+	m.stmt.getForAppspace = p.Prep(`SELECT * FROM usage_rollups
+		WHERE appspace_id = ? AND period = ? AND period_start >= ? AND period_start < ?
+		ORDER BY period_start, app_id`)
+
+	m.stmt.getUserTotals = p.Prep(`SELECT period, owner_id, 0 AS appspace_id, 0 AS app_id,
+		SUM(num_runs) AS num_runs, SUM(tied_up_ms) AS tied_up_ms, SUM(cpu_usec) AS cpu_usec,
+		SUM(memory_byte_sec) AS memory_byte_sec, SUM(io_bytes) AS io_bytes, SUM(io_ops) AS io_ops,
+		SUM(out_requests) AS out_requests, SUM(out_bytes_sent) AS out_bytes_sent,
+		SUM(out_bytes_received) AS out_bytes_received
+		FROM usage_rollups
+		WHERE period = ? AND period_start >= ? AND period_start < ?
+		GROUP BY owner_id
+		ORDER BY cpu_usec DESC`)
+}
+
+// RollupDay sums the sandbox runs that started on the day that starts at dayStart.
+// It can be run again for the same day as more runs come in.
+func (m *UsageModel) RollupDay(dayStart time.Time) error {
+	// sandbox run times are stored in local time
+	end := dayStart.AddDate(0, 0, 1)
+	_, err := m.stmt.rollupDay.Exec(dayStart, dayStart.Local(), end.Local())
+	if err != nil {
+		m.getLogger("RollupDay()").Error(err)
+	}
+	return err
+}
+
+// RollupPeriod sums the day rollups between start and end
+// into a rollup for the period
+func (m *UsageModel) RollupPeriod(period string, start time.Time, end time.Time) error {
+	_, err := m.stmt.rollupPeriod.Exec(period, start, start, end)
+	if err != nil {
+		m.getLogger("RollupPeriod()").AddNote(period).Error(err)
+	}
+	return err
+}
+
+// LastDay returns the start of the most recent day rolled up.
+// It returns false if no day was rolled up yet.
+func (m *UsageModel) LastDay() (time.Time, bool, error) {
+	var day time.Time
+	err := m.stmt.lastDay.QueryRowx().Scan(&day)
+	if err == sql.ErrNoRows {
+		return day, false, nil
+	}
+	if err != nil {
+		m.getLogger("LastDay()").Error(err)
+		return day, false, err
+	}
+	return day, true, nil
+}
+
+// DeleteBefore removes the rollups of a period type
+// that start before the time
+func (m *UsageModel) DeleteBefore(period string, before time.Time) error {
+	_, err := m.stmt.deleteBefore.Exec(period, before)
+	if err != nil {
+		m.getLogger("DeleteBefore()").AddNote(period).Error(err)
+	}
+	return err
+}
+
+// GetForOwner returns the owner's rollups per appspace and app
+func (m *UsageModel) GetForOwner(ownerID domain.UserID, period string, from time.Time, to time.Time) ([]domain.UsageRollup, error) {
+	ret := []domain.UsageRollup{}
+	err := m.stmt.getForOwner.Select(&ret, ownerID, period, from, to)
+	if err != nil {
+		m.getLogger("GetForOwner()").UserID(ownerID).Error(err)
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetForAppspace returns the rollups of an appspace
+func (m *UsageModel) GetForAppspace(appspaceID domain.AppspaceID, period string, from time.Time, to time.Time) ([]domain.UsageRollup, error) {
+	ret := []domain.UsageRollup{}
+	err := m.stmt.getForAppspace.Select(&ret, appspaceID, period, from, to)
+	if err != nil {
+		m.getLogger("GetForAppspace()").AppspaceID(appspaceID).Error(err)
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetUserTotals returns the usage of each user between from and to,
+// heaviest CPU users first. PeriodStart is not set.
+func (m *UsageModel) GetUserTotals(period string, from time.Time, to time.Time) ([]domain.UsageRollup, error) {
+	ret := []domain.UsageRollup{}
+	err := m.stmt.getUserTotals.Select(&ret, period, from, to)
+	if err != nil {
+		m.getLogger("GetUserTotals()").Error(err)
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (m *UsageModel) getLogger(note string) *record.DsLogger {
+	r := record.NewDsLogger().AddNote("UsageModel")
+	if note != "" {
+		r.AddNote(note)
+	}
+	return r
+}
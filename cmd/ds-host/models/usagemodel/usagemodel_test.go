@@ -0,0 +1,144 @@
+package usagemodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/migrate"
+	"github.com/teleclimber/DropServer/cmd/ds-host/models/sandboxruns"
+)
+
+func TestPrepareStatements(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	m := &UsageModel{
+		DB: &domain.DB{Handle: h}}
+
+	m.PrepareStatements()
+}
+
+func TestRollups(t *testing.T) {
+	h := migrate.MakeSqliteDummyDB()
+	defer h.Close()
+
+	db := &domain.DB{Handle: h}
+	m := &UsageModel{DB: db}
+	m.PrepareStatements()
+	runs := &sandboxruns.SandboxRunsModel{DB: db}
+	runs.PrepareStatements()
+
+	_, ok, err := m.LastDay()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("expected no last day")
+	}
+
+	owner1 := domain.UserID(1)
+	owner2 := domain.UserID(2)
+	ids := domain.SandboxRunIDs{
+		Instance:   "ds-test",
+		OwnerID:    owner1,
+		AppID:      domain.AppID(10),
+		Version:    domain.Version("0.0.1"),
+		AppspaceID: domain.NewNullAppspaceID(domain.AppspaceID(100)),
+		Operation:  "op",
+	}
+	appOnly := ids
+	appOnly.AppspaceID = domain.NewNullAppspaceID()
+	other := ids
+	other.OwnerID = owner2
+	other.AppspaceID = domain.NewNullAppspaceID(domain.AppspaceID(200))
+
+	day1 := day(3)
+	day2 := day(4)
+	createRun(t, runs, ids, day1.Add(time.Hour), 1000)
+	createRun(t, runs, ids, day1.Add(2*time.Hour), 2000)
+	createRun(t, runs, appOnly, day1.Add(3*time.Hour), 50)
+	createRun(t, runs, other, day1.Add(time.Hour), 10000)
+	createRun(t, runs, ids, day2.Add(time.Hour), 500)
+
+	for _, d := range []time.Time{day1, day2} {
+		err = m.RollupDay(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// rolling up again does not double count
+	err = m.RollupDay(day1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	last, ok, err := m.LastDay()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || !last.Equal(day2) {
+		t.Errorf("unexpected last day: %v", last)
+	}
+
+	rollups, err := m.GetForOwner(owner1, domain.UsageDay, day1, day(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rollups) != 3 {
+		t.Fatalf("expected 3 rollups, got %v", rollups)
+	}
+	if rollups[0].AppspaceID != 0 || rollups[0].CpuUsec != 50 {
+		t.Errorf("unexpected app-only rollup: %v", rollups[0])
+	}
+	if rollups[1].AppspaceID != 100 || rollups[1].NumRuns != 2 || rollups[1].CpuUsec != 3000 || rollups[1].OutBytesSent != 300 {
+		t.Errorf("unexpected appspace rollup: %v", rollups[1])
+	}
+
+	err = m.RollupPeriod(domain.UsageMonth, day(1), day(1).AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rollups, err = m.GetForAppspace(domain.AppspaceID(100), domain.UsageMonth, day(1), day(1).AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rollups) != 1 || rollups[0].NumRuns != 3 || rollups[0].CpuUsec != 3500 || rollups[0].OutBytesSent != 350 || !rollups[0].PeriodStart.Equal(day(1)) {
+		t.Errorf("unexpected month rollup: %v", rollups)
+	}
+
+	totals, err := m.GetUserTotals(domain.UsageMonth, day(1), day(1).AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(totals) != 2 || totals[0].OwnerID != owner2 || totals[1].OwnerID != owner1 || totals[1].CpuUsec != 3550 || totals[1].OutBytesSent != 355 {
+		t.Errorf("unexpected totals: %v", totals)
+	}
+
+	err = m.DeleteBefore(domain.UsageDay, day2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rollups, err = m.GetForOwner(owner1, domain.UsageDay, day1, day(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rollups) != 1 {
+		t.Errorf("expected one rollup left, got %v", rollups)
+	}
+}
+
+func createRun(t *testing.T, m *sandboxruns.SandboxRunsModel, ids domain.SandboxRunIDs, start time.Time, cpu int) {
+	id, err := m.Create(ids, start.Local())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.End(id, start.Local(), domain.SandboxRunData{CpuUsec: cpu, OutBytesSent: cpu / 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func day(d int) time.Time {
+	return time.Date(2026, time.March, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,459 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+)
+
+// Policy sets how failed attempts are throttled for one kind of key
+type Policy struct {
+	// FreeFailures is the number of failures allowed before any delay
+	FreeFailures int
+	// BaseDelay is the delay after the first failure past FreeFailures.
+	// It doubles with each further failure, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutFailures is the number of failures after which the key is locked out
+	LockoutFailures int
+	Lockout         time.Duration
+	// Forget is how long after the last failure the count is reset
+	Forget time.Duration
+}
+
+// delay returns how long the key is blocked after its nth failure,
+// and whether it's a lockout.
+func (p Policy) delay(failures int) (time.Duration, bool) {
+	if failures >= p.LockoutFailures {
+		return p.Lockout, true
+	}
+	if failures <= p.FreeFailures {
+		return 0, false
+	}
+	d := p.BaseDelay
+	for i := p.FreeFailures + 1; i < failures && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d, false
+}
+
+// An IP can be shared by many users so it gets more leeway than an account.
+var policies = map[string]Policy{
+	domain.LoginLimitIP: {
+		FreeFailures:    10,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutFailures: 100,
+		Lockout:         time.Hour,
+		Forget:          time.Hour},
+	domain.LoginLimitAccount: {
+		FreeFailures:    5,
+		BaseDelay:       time.Second,
+		MaxDelay:        5 * time.Minute,
+		LockoutFailures: 20,
+		Lockout:         15 * time.Minute,
+		Forget:          time.Hour},
+}
+
+// maxBlocked is the number of blocked attempts kept for admins to see
+const maxBlocked = 200
+
+var pruneInterval = 10 * time.Minute
+
+type counterKey struct {
+	kind string
+	key  string
+}
+
+type counter struct {
+	failures int
+	last     time.Time
+	until    time.Time
+	lockout  bool
+}
+
+// Limiter throttles attempts at guessing credentials.
+// Failures are counted per client IP and per account.
+// Past a few failures a key must wait before trying again,
+// with the wait doubling on each failure, and eventually it is locked out.
+// State is kept in memory only.
+type Limiter struct {
+	Config *domain.RuntimeConfig `checkinject:"required"`
+
+	now            func() time.Time
+	trustedProxies []netip.Prefix
+
+	mux      sync.Mutex
+	counters map[counterKey]*counter
+	blocked  []domain.BlockedLoginAttempt
+
+	stop chan struct{}
+}
+
+// Init reads the config and starts pruning old counters
+func (l *Limiter) Init() {
+	if l.now == nil {
+		l.now = time.Now
+	}
+	l.counters = make(map[counterKey]*counter)
+	for _, s := range l.Config.LoginLimits.TrustedProxies {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			a, err := netip.ParseAddr(s)
+			if err != nil {
+				l.getLogger("Init").AddNote(s).Error(err)
+				continue
+			}
+			p = netip.PrefixFrom(a, a.BitLen())
+		}
+		l.trustedProxies = append(l.trustedProxies, p)
+	}
+
+	l.stop = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(pruneInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				l.prune()
+			case <-l.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops pruning
+func (l *Limiter) Stop() {
+	close(l.stop)
+}
+
+type attemptCtxKey struct{}
+
+type attempt struct {
+	failed  bool
+	ignored bool
+}
+
+// Fail marks the request as a failed attempt.
+// It does nothing if the route is not throttled.
+func Fail(r *http.Request) {
+	if a, ok := r.Context().Value(attemptCtxKey{}).(*attempt); ok {
+		a.failed = true
+	}
+}
+
+// Ignore marks the request as not being an attempt at a credential,
+// like a malformed request, so it is not counted even if it gets an error response.
+// It does nothing if the route is not throttled.
+func Ignore(r *http.Request) {
+	if a, ok := r.Context().Value(attemptCtxKey{}).(*attempt); ok {
+		a.ignored = true
+	}
+}
+
+// Middleware throttles a route.
+// account returns the account a request is trying to get at, or ""
+// to throttle by IP only. It can be nil.
+// A request fails if the handler calls Fail or responds with
+// 400, 401 or 403, unless the handler calls Ignore.
+// A request that does not fail clears its account's failures.
+// blocked sends the response to throttled requests. If nil a 429 is sent.
+func (l *Limiter) Middleware(route string, account func(*http.Request) string, blocked func(http.ResponseWriter, *http.Request, time.Duration)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := l.clientIP(r)
+			acct := ""
+			if account != nil {
+				acct = account(r)
+			}
+
+			kind, retry := l.begin(ip, acct)
+			if retry > 0 {
+				l.addBlocked(domain.BlockedLoginAttempt{
+					Time:    l.now(),
+					Route:   route,
+					IP:      ip,
+					Account: acct,
+					Kind:    kind})
+				w.Header().Set("Retry-After", strconv.Itoa(int((retry+time.Second-1)/time.Second)))
+				if blocked == nil {
+					http.Error(w, "too many attempts", http.StatusTooManyRequests)
+				} else {
+					blocked(w, r, retry)
+				}
+				return
+			}
+
+			a := &attempt{}
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), attemptCtxKey{}, a)))
+
+			switch sw.status {
+			case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden:
+				a.failed = true
+			}
+			l.end(ip, acct, a)
+		})
+	}
+}
+
+// begin returns the time left before ip or account can try again,
+// and which of the two is blocked.
+// If neither is blocked the attempt is counted as a failure until end is called,
+// so that concurrent attempts can not get past the throttling.
+func (l *Limiter) begin(ip, account string) (string, time.Duration) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	now := l.now()
+	keys := attemptKeys(ip, account)
+	kind := ""
+	var retry time.Duration
+	for _, k := range keys {
+		c, ok := l.counters[k]
+		if !ok {
+			continue
+		}
+		if d := c.until.Sub(now); d > retry {
+			kind = k.kind
+			retry = d
+		}
+	}
+	if retry > 0 {
+		return kind, retry
+	}
+	for _, k := range keys {
+		p := policies[k.kind]
+		c, ok := l.counters[k]
+		if !ok || now.Sub(c.last) > p.Forget {
+			c = &counter{}
+			l.counters[k] = c
+		}
+		c.failures++
+		c.last = now
+		d, lockout := p.delay(c.failures)
+		if d > 0 {
+			c.until = now.Add(d)
+			c.lockout = lockout
+		}
+	}
+	return "", 0
+}
+
+// end settles an attempt counted by begin.
+// A failed attempt stays counted. A successful one clears the account's failures.
+// Otherwise the attempt is no longer counted.
+func (l *Limiter) end(ip, account string, a *attempt) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if a.failed && !a.ignored {
+		for _, k := range attemptKeys(ip, account) {
+			c, ok := l.counters[k]
+			if ok && c.lockout && c.failures >= policies[k.kind].LockoutFailures {
+				l.getLogger("end").AddNote(k.kind).Log("locked out " + k.key + " after " + strconv.Itoa(c.failures) + " failures")
+			}
+		}
+		return
+	}
+	for _, k := range attemptKeys(ip, account) {
+		if k.kind == domain.LoginLimitAccount && !a.ignored {
+			delete(l.counters, k)
+			continue
+		}
+		c, ok := l.counters[k]
+		if !ok {
+			continue
+		}
+		c.failures--
+		if c.failures <= 0 {
+			delete(l.counters, k)
+			continue
+		}
+		d, lockout := policies[k.kind].delay(c.failures)
+		c.until = time.Time{}
+		if d > 0 {
+			c.until = c.last.Add(d)
+		}
+		c.lockout = lockout
+	}
+}
+
+func attemptKeys(ip, account string) []counterKey {
+	keys := make([]counterKey, 0, 2)
+	if ip != "" {
+		keys = append(keys, counterKey{domain.LoginLimitIP, ip})
+	}
+	if account != "" {
+		keys = append(keys, counterKey{domain.LoginLimitAccount, account})
+	}
+	return keys
+}
+
+// Clear removes the failures of an IP or account,
+// which lifts any block on it.
+func (l *Limiter) Clear(kind, key string) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	delete(l.counters, counterKey{kind, key})
+}
+
+func (l *Limiter) addBlocked(b domain.BlockedLoginAttempt) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.blocked = append(l.blocked, b)
+	if len(l.blocked) > maxBlocked {
+		l.blocked = l.blocked[len(l.blocked)-maxBlocked:]
+	}
+}
+
+// Status returns the keys that are currently blocked
+// and the most recent blocked attempts.
+func (l *Limiter) Status() domain.LoginLimitStatus {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	now := l.now()
+	ret := domain.LoginLimitStatus{
+		Blocks:  []domain.LoginBlock{},
+		Blocked: make([]domain.BlockedLoginAttempt, len(l.blocked))}
+	for k, c := range l.counters {
+		if !c.until.After(now) {
+			continue
+		}
+		ret.Blocks = append(ret.Blocks, domain.LoginBlock{
+			Kind:     k.kind,
+			Key:      k.key,
+			Failures: c.failures,
+			Until:    c.until,
+			Lockout:  c.lockout})
+	}
+	sort.Slice(ret.Blocks, func(i, j int) bool { return ret.Blocks[i].Until.After(ret.Blocks[j].Until) })
+	for i, b := range l.blocked {
+		ret.Blocked[len(l.blocked)-1-i] = b
+	}
+	return ret
+}
+
+// prune removes counters that are no longer blocking and would be forgotten
+func (l *Limiter) prune() {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	now := l.now()
+	for k, c := range l.counters {
+		if now.Before(c.until) {
+			continue
+		}
+		if now.Sub(c.last) > policies[k.kind].Forget {
+			delete(l.counters, k)
+		}
+	}
+}
+
+// clientIP returns the IP key of the request.
+// IPv6 addresses are grouped by /64 since a single client usually has all of it.
+func (l *Limiter) clientIP(r *http.Request) string {
+	addr, ok := l.clientAddr(r)
+	if !ok {
+		return r.RemoteAddr
+	}
+	if addr.Is6() {
+		if p, err := addr.WithZone("").Prefix(64); err == nil {
+			return p.String()
+		}
+	}
+	return addr.String()
+}
+
+// ClientAddr returns the IP address of the client that made the request
+func (l *Limiter) ClientAddr(r *http.Request) string {
+	addr, ok := l.clientAddr(r)
+	if !ok {
+		return r.RemoteAddr
+	}
+	return addr.String()
+}
+
+// clientAddr returns the address of the client.
+// X-Forwarded-For is only used when the request comes through a trusted proxy.
+func (l *Limiter) clientAddr(r *http.Request) (netip.Addr, bool) {
+	addr, ok := parseAddr(r.RemoteAddr)
+	if !ok {
+		return addr, false
+	}
+	if l.trusted(addr) {
+		hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, ok := parseAddr(strings.TrimSpace(hops[i]))
+			if !ok {
+				break
+			}
+			addr = hop
+			if !l.trusted(hop) {
+				break
+			}
+		}
+	}
+	return addr.Unmap(), true
+}
+
+func (l *Limiter) trusted(addr netip.Addr) bool {
+	for _, p := range l.trustedProxies {
+		if p.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseAddr(s string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(s)
+	return addr, err == nil
+}
+
+// statusWriter keeps the response status
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if sw.status == 0 {
+		sw.status = code
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+func (l *Limiter) getLogger(note string) *record.DsLogger {
+	r := record.NewDsLogger().AddNote("Limiter")
+	if note != "" {
+		r.AddNote(note)
+	}
+	return r
+}
@@ -0,0 +1,281 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func getLimiter(t *testing.T, trusted ...string) (*Limiter, *clock) {
+	c := &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	config := &domain.RuntimeConfig{}
+	config.LoginLimits.TrustedProxies = trusted
+	l := &Limiter{Config: config, now: c.now}
+	l.Init()
+	t.Cleanup(l.Stop)
+	return l, c
+}
+
+// loginHandler fails unless the password is "good"
+var loginHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("password") != "good" {
+		Fail(r)
+	}
+	w.Write([]byte("ok"))
+})
+
+func accountFromQuery(r *http.Request) string {
+	return r.URL.Query().Get("email")
+}
+
+func try(h http.Handler, ip, email, password string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/login?email="+email+"&password="+password, nil)
+	req.RemoteAddr = ip + ":1234"
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestPolicyDelay(t *testing.T) {
+	p := Policy{FreeFailures: 2, BaseDelay: time.Second, MaxDelay: 5 * time.Second, LockoutFailures: 10, Lockout: time.Hour}
+	cases := []struct {
+		failures int
+		delay    time.Duration
+		lockout  bool
+	}{
+		{1, 0, false},
+		{2, 0, false},
+		{3, time.Second, false},
+		{4, 2 * time.Second, false},
+		{5, 4 * time.Second, false},
+		{6, 5 * time.Second, false},
+		{9, 5 * time.Second, false},
+		{10, time.Hour, true},
+	}
+	for _, c := range cases {
+		d, lockout := p.delay(c.failures)
+		if d != c.delay || lockout != c.lockout {
+			t.Errorf("%v failures: got %v %v", c.failures, d, lockout)
+		}
+	}
+}
+
+func TestMiddlewareAccountBackoff(t *testing.T) {
+	l, c := getLimiter(t)
+	h := l.Middleware("login", accountFromQuery, nil)(loginHandler)
+
+	free := policies[domain.LoginLimitAccount].FreeFailures
+	for i := 0; i <= free; i++ {
+		rr := try(h, "10.0.0.1", "a@b.c", "bad")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("attempt %v: expected 200, got %v", i, rr.Code)
+		}
+	}
+	// account is now blocked, even from another IP, even with the right password
+	rr := try(h, "10.0.0.2", "a@b.c", "good")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429, got %v", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected Retry-After 1, got %v", rr.Header().Get("Retry-After"))
+	}
+	// other accounts are fine
+	rr = try(h, "10.0.0.2", "x@y.z", "good")
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected 200, got %v", rr.Code)
+	}
+
+	c.t = c.t.Add(time.Second)
+	rr = try(h, "10.0.0.2", "a@b.c", "good")
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected 200 after waiting, got %v", rr.Code)
+	}
+	// success cleared the account
+	rr = try(h, "10.0.0.2", "a@b.c", "bad")
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected 200, got %v", rr.Code)
+	}
+	if len(l.Status().Blocks) != 0 {
+		t.Errorf("expected no blocks, got %v", l.Status().Blocks)
+	}
+}
+
+func TestMiddlewareIPLockout(t *testing.T) {
+	l, c := getLimiter(t)
+	h := l.Middleware("token", nil, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad", http.StatusBadRequest)
+	}))
+
+	p := policies[domain.LoginLimitIP]
+	for i := 0; i < p.LockoutFailures; i++ {
+		try(h, "10.0.0.1", "", "")
+		c.t = c.t.Add(p.MaxDelay)
+	}
+	status := l.Status()
+	if len(status.Blocks) != 1 || !status.Blocks[0].Lockout || status.Blocks[0].Key != "10.0.0.1" {
+		t.Fatalf("expected IP lockout, got %v", status.Blocks)
+	}
+	c.t = c.t.Add(p.Lockout - p.MaxDelay - time.Second)
+	rr := try(h, "10.0.0.1", "", "")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429, got %v", rr.Code)
+	}
+	status = l.Status()
+	if len(status.Blocked) != 1 || status.Blocked[0].Route != "token" || status.Blocked[0].Kind != domain.LoginLimitIP {
+		t.Errorf("expected blocked attempt, got %v", status.Blocked)
+	}
+
+	l.Clear(domain.LoginLimitIP, "10.0.0.1")
+	rr = try(h, "10.0.0.1", "", "")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected request through after clear, got %v", rr.Code)
+	}
+}
+
+func TestMiddlewareConcurrent(t *testing.T) {
+	l, _ := getLimiter(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := l.Middleware("login", accountFromQuery, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		loginHandler(w, r)
+	}))
+
+	// attempts in progress count as failures
+	free := policies[domain.LoginLimitAccount].FreeFailures
+	done := make(chan int)
+	for i := 0; i <= free; i++ {
+		go func() {
+			done <- try(h, "10.0.0.1", "a@b.c", "good").Code
+		}()
+		<-started
+	}
+	rr := try(h, "10.0.0.2", "a@b.c", "bad")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 while attempts are in progress, got %v", rr.Code)
+	}
+
+	close(release)
+	for i := 0; i <= free; i++ {
+		if code := <-done; code != http.StatusOK {
+			t.Errorf("expected 200, got %v", code)
+		}
+	}
+	if len(l.Status().Blocks) != 0 {
+		t.Errorf("expected successful attempts to lift the block, got %v", l.Status().Blocks)
+	}
+}
+
+func TestMiddlewareIgnore(t *testing.T) {
+	l, _ := getLimiter(t)
+	h := l.Middleware("login", accountFromQuery, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("password") == "" {
+			Ignore(r)
+			http.Error(w, "malformed", http.StatusBadRequest)
+			return
+		}
+		loginHandler(w, r)
+	}))
+
+	try(h, "10.0.0.1", "a@b.c", "bad")
+	p := policies[domain.LoginLimitAccount]
+	for i := 0; i < p.LockoutFailures; i++ {
+		rr := try(h, "10.0.0.1", "a@b.c", "")
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected malformed requests to not be throttled, got %v", rr.Code)
+		}
+	}
+	// the earlier failure is still counted
+	if c := l.counters[counterKey{domain.LoginLimitAccount, "a@b.c"}]; c == nil || c.failures != 1 {
+		t.Errorf("expected one account failure, got %v", c)
+	}
+}
+
+func TestMiddlewareBlockedHandler(t *testing.T) {
+	l, _ := getLimiter(t)
+	var gotRetry time.Duration
+	h := l.Middleware("login", accountFromQuery, func(w http.ResponseWriter, r *http.Request, retry time.Duration) {
+		gotRetry = retry
+		w.Write([]byte("wait"))
+	})(loginHandler)
+
+	for i := 0; i <= policies[domain.LoginLimitAccount].FreeFailures; i++ {
+		try(h, "10.0.0.1", "a@b.c", "bad")
+	}
+	rr := try(h, "10.0.0.1", "a@b.c", "bad")
+	if rr.Body.String() != "wait" || gotRetry != time.Second {
+		t.Errorf("expected blocked handler, got %v %v", rr.Body.String(), gotRetry)
+	}
+}
+
+func TestForget(t *testing.T) {
+	l, c := getLimiter(t)
+	h := l.Middleware("login", accountFromQuery, nil)(loginHandler)
+	p := policies[domain.LoginLimitAccount]
+	for i := 0; i < p.FreeFailures; i++ {
+		try(h, "10.0.0.1", "a@b.c", "bad")
+	}
+	c.t = c.t.Add(p.Forget + time.Second)
+	try(h, "10.0.0.1", "a@b.c", "bad")
+	if len(l.Status().Blocks) != 0 {
+		t.Error("expected earlier failures to be forgotten")
+	}
+
+	c.t = c.t.Add(p.Forget + time.Second)
+	l.prune()
+	if len(l.counters) != 0 {
+		t.Errorf("expected counters to be pruned, got %v", len(l.counters))
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	l, _ := getLimiter(t, "10.0.0.0/8", "::1")
+	cases := []struct {
+		remote string
+		xff    string
+		ip     string
+	}{
+		{"1.2.3.4:5678", "", "1.2.3.4"},
+		{"1.2.3.4:5678", "5.6.7.8", "1.2.3.4"}, // untrusted remote
+		{"10.0.0.1:5678", "5.6.7.8", "5.6.7.8"},
+		{"10.0.0.1:5678", "9.9.9.9, 5.6.7.8, 10.0.0.2", "5.6.7.8"},
+		{"10.0.0.1:5678", "", "10.0.0.1"},
+		{"10.0.0.1:5678", "garbage", "10.0.0.1"},
+		{"[::1]:5678", "2001:db8:1:2:3:4:5:6", "2001:db8:1:2::/64"},
+		{"[2001:db8:1:2::9]:5678", "", "2001:db8:1:2::/64"},
+		{"[::ffff:1.2.3.4]:5678", "", "1.2.3.4"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = c.remote
+		if c.xff != "" {
+			req.Header.Set("X-Forwarded-For", c.xff)
+		}
+		ip := l.clientIP(req)
+		if ip != c.ip {
+			t.Errorf("%v %v: expected %v, got %v", c.remote, c.xff, c.ip, ip)
+		}
+	}
+}
+
+func TestClientAddr(t *testing.T) {
+	l, _ := getLimiter(t, "::1")
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "[::1]:5678"
+	req.Header.Set("X-Forwarded-For", "2001:db8:1:2:3:4:5:6")
+	if addr := l.ClientAddr(req); addr != "2001:db8:1:2:3:4:5:6" {
+		t.Errorf("expected full address, got %v", addr)
+	}
+}
@@ -1,12 +1,16 @@
 package record
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
 )
@@ -14,6 +18,64 @@ import (
 var multiWriter io.Writer
 var logFile *os.File
 
+var logLevel = domain.INFO
+
+// jsonLogger is set when logs are written as JSON lines
+var jsonLogger *slog.Logger
+
+// ParseLogLevel returns the level for one of debug, info, warn, error
+func ParseLogLevel(level string) (domain.LogLevel, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return domain.DEBUG, nil
+	case "info", "":
+		return domain.INFO, nil
+	case "warn":
+		return domain.WARN, nil
+	case "error":
+		return domain.ERROR, nil
+	}
+	return domain.INFO, errors.New("log level must be debug, info, warn or error")
+}
+
+// SetLogFormat sets the lowest level that gets logged
+// and the format of log lines, either "text" or "json".
+// Setting the DEBUG env var always logs debug messages.
+func SetLogFormat(level domain.LogLevel, format string) {
+	logLevel = level
+	jsonLogger = nil
+	if format == "json" {
+		// Write through the standard logger's writer so that
+		// SetLogOutput applies to JSON logs too.
+		jsonLogger = slog.New(slog.NewJSONHandler(stdLogWriter{}, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+}
+
+type stdLogWriter struct{}
+
+func (stdLogWriter) Write(p []byte) (int, error) {
+	return log.Writer().Write(p)
+}
+
+func logEnabled(level domain.LogLevel) bool {
+	if level == domain.DEBUG && os.Getenv("DEBUG") != "" {
+		return true
+	}
+	return level >= logLevel && logLevel != domain.DISABLE
+}
+
+func slogLevel(level domain.LogLevel) slog.Level {
+	switch level {
+	case domain.DEBUG:
+		return slog.LevelDebug
+	case domain.WARN:
+		return slog.LevelWarn
+	case domain.ERROR:
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
 // InitDsLogger sets flags on the default logger
 func InitDsLogger() {
 	log.SetFlags(log.Ldate | log.Ltime)
@@ -53,14 +115,12 @@ func CloseLogOutput() error {
 
 // Debug just logs the message if in debug mode
 func Debug(message string) {
-	if os.Getenv("DEBUG") != "" {
-		log.Print("DEBUG: " + message)
-	}
+	NewDsLogger().output(domain.DEBUG, message)
 }
 
 // Log just logs the message
 func Log(message string) {
-	log.Print(message)
+	NewDsLogger().output(domain.INFO, message)
 }
 
 // DsLogger wraps the standard log or anoything else we use late
@@ -73,6 +133,7 @@ type DsLogger struct {
 	hasAppspaceID bool
 	userID        domain.UserID
 	hasUserID     bool
+	requestID     string
 	note          string // note is extra info that is not a predetermined variable, but not the actual log message
 }
 
@@ -139,6 +200,21 @@ func (l *DsLogger) UserID(userID domain.UserID) *DsLogger {
 	return l
 }
 
+// RequestID is set on all uses of the returned logger
+// so that the logs of a request can be traced
+func (l *DsLogger) RequestID(requestID string) *DsLogger {
+	l.requestID = requestID
+	return l
+}
+
+// Ctx sets the request ID from the context, if there is one
+func (l *DsLogger) Ctx(ctx context.Context) *DsLogger {
+	if requestID, ok := domain.CtxRequestID(ctx); ok {
+		l.requestID = requestID
+	}
+	return l
+}
+
 // AddNote appends a note to the not string
 func (l *DsLogger) AddNote(note string) *DsLogger {
 	if l.note != "" {
@@ -151,29 +227,86 @@ func (l *DsLogger) AddNote(note string) *DsLogger {
 
 // Debug writes the message to the log if debug mode is on
 func (l *DsLogger) Debug(message string) {
-	if os.Getenv("DEBUG") != "" {
-		log.Print("DEBUG: " + l.contextStr() + message)
-	}
+	l.output(domain.DEBUG, message)
+}
+
+// Warn writes the message to the log as a warning
+func (l *DsLogger) Warn(message string) {
+	l.output(domain.WARN, message)
 }
 
 // Log writes the message to the log
 func (l *DsLogger) Log(message string) {
-	log.Print(l.contextStr() + message)
+	l.output(domain.INFO, message)
 }
 
 // Error writes the error to the log
 func (l *DsLogger) Error(err error) {
-	log.Print(l.contextStr() + "Error: " + err.Error())
+	l.output(domain.ERROR, err.Error())
 }
 
-func (l *DsLogger) contextStr() string {
-	str := ""
+// output writes the log line in the configured format.
+// It must be called directly by the exported log functions
+// so that the caller's file and line are found.
+func (l *DsLogger) output(level domain.LogLevel, message string) {
+	if !logEnabled(level) {
+		return
+	}
+	source := ""
 	_, file, line, ok := runtime.Caller(2)
-	f := filepath.Base(file)
-	p := filepath.Base(filepath.Dir(file))
 	if ok {
-		str += fmt.Sprintf("%v/%v:%v ", p, f, line)
+		source = fmt.Sprintf("%v/%v:%v", filepath.Base(filepath.Dir(file)), filepath.Base(file), line)
+	}
+
+	if jsonLogger != nil {
+		jsonLogger.LogAttrs(context.Background(), slogLevel(level), message, l.attrs(source)...)
+		return
+	}
+
+	str := ""
+	if source != "" {
+		str = source + " "
+	}
+	str += l.contextStr()
+	switch level {
+	case domain.DEBUG:
+		str = "DEBUG: " + str
+	case domain.WARN:
+		str += "Warning: "
+	case domain.ERROR:
+		str += "Error: "
+	}
+	log.Print(str + message)
+}
+
+func (l *DsLogger) attrs(source string) []slog.Attr {
+	ret := []slog.Attr{}
+	if source != "" {
+		ret = append(ret, slog.String("source", source))
+	}
+	if l.hasAppID {
+		ret = append(ret, slog.Int("app_id", int(l.appID)))
 	}
+	if l.appVersion != domain.Version("") {
+		ret = append(ret, slog.String("app_version", string(l.appVersion)))
+	}
+	if l.hasAppspaceID {
+		ret = append(ret, slog.Int("appspace_id", int(l.appspaceID)))
+	}
+	if l.hasUserID {
+		ret = append(ret, slog.Int("user_id", int(l.userID)))
+	}
+	if l.requestID != "" {
+		ret = append(ret, slog.String("request_id", l.requestID))
+	}
+	if l.note != "" {
+		ret = append(ret, slog.String("note", l.note))
+	}
+	return ret
+}
+
+func (l *DsLogger) contextStr() string {
+	str := ""
 	if l.hasAppID {
 		str += fmt.Sprintf("a:%v ", l.appID)
 	}
@@ -186,6 +319,9 @@ func (l *DsLogger) contextStr() string {
 	if l.hasUserID {
 		str += fmt.Sprintf("u:%v ", l.userID)
 	}
+	if l.requestID != "" {
+		str += fmt.Sprintf("r:%v ", l.requestID)
+	}
 	if l.note != "" {
 		str += "(" + l.note + ") "
 	}
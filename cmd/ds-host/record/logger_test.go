@@ -1,7 +1,13 @@
 package record
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"os"
 	"strings"
 	"testing"
 
@@ -86,10 +92,10 @@ func TestUserID(t *testing.T) {
 func TestContextStr(t *testing.T) {
 	l := NewDsLogger()
 
-	l.AppspaceID(domain.AppspaceID(7)).AppID(domain.AppID(13)).AppVersion(domain.Version("0.0.9")).UserID(domain.UserID(77)).AddNote("hello")
+	l.AppspaceID(domain.AppspaceID(7)).AppID(domain.AppID(13)).AppVersion(domain.Version("0.0.9")).UserID(domain.UserID(77)).RequestID("abc123").AddNote("hello")
 	str := l.contextStr()
 
-	for _, s := range []string{"as:7", "a:13", "v:0.0.9", "u:77", "hello"} {
+	for _, s := range []string{"as:7", "a:13", "v:0.0.9", "u:77", "r:abc123", "hello"} {
 		if !strings.Contains(str, s) {
 			t.Error(fmt.Sprintf("expected %v in context string: %v", s, str))
 		}
@@ -115,3 +121,89 @@ func TestClone(t *testing.T) {
 		t.Error("contenxt strings should be different")
 	}
 }
+
+func TestCtx(t *testing.T) {
+	l := NewDsLogger().Ctx(context.Background())
+	if l.requestID != "" {
+		t.Error("expected no request id")
+	}
+	l.Ctx(domain.CtxWithRequestID(context.Background(), "abc123"))
+	if l.requestID != "abc123" {
+		t.Error("expected request id from context")
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	cases := []struct {
+		str   string
+		level domain.LogLevel
+		err   bool
+	}{
+		{"", domain.INFO, false},
+		{"debug", domain.DEBUG, false},
+		{"WARN", domain.WARN, false},
+		{"error", domain.ERROR, false},
+		{"verbose", domain.INFO, true},
+	}
+	for _, c := range cases {
+		level, err := ParseLogLevel(c.str)
+		if (err != nil) != c.err {
+			t.Errorf("%v: unexpected error: %v", c.str, err)
+		}
+		if level != c.level {
+			t.Errorf("%v: expected level %v, got %v", c.str, c.level, level)
+		}
+	}
+}
+
+func TestLogLevelFilter(t *testing.T) {
+	os.Unsetenv("DEBUG")
+	buf := captureLog(t, domain.WARN, "text")
+
+	l := NewDsLogger().AppspaceID(domain.AppspaceID(7))
+	l.Debug("debug message")
+	l.Log("info message")
+	l.Warn("warn message")
+	l.Error(errors.New("error message"))
+
+	out := buf.String()
+	if strings.Contains(out, "debug message") || strings.Contains(out, "info message") {
+		t.Errorf("expected debug and info to be filtered: %v", out)
+	}
+	if !strings.Contains(out, "Warning: warn message") || !strings.Contains(out, "as:7 Error: error message") {
+		t.Errorf("expected warn and error: %v", out)
+	}
+}
+
+func TestJSONLog(t *testing.T) {
+	buf := captureLog(t, domain.INFO, "json")
+
+	NewDsLogger().AppspaceID(domain.AppspaceID(7)).RequestID("abc123").AddNote("hello").Error(errors.New("oops"))
+
+	var line map[string]interface{}
+	err := json.Unmarshal(buf.Bytes(), &line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line["level"] != "ERROR" || line["msg"] != "oops" {
+		t.Errorf("unexpected level or message: %v", line)
+	}
+	if line["appspace_id"] != float64(7) || line["request_id"] != "abc123" || line["note"] != "hello" {
+		t.Errorf("unexpected attributes: %v", line)
+	}
+	if src, _ := line["source"].(string); !strings.HasPrefix(src, "record/logger_test.go:") {
+		t.Errorf("unexpected source: %v", line["source"])
+	}
+}
+
+func captureLog(t *testing.T, level domain.LogLevel, format string) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	origOut := log.Writer()
+	log.SetOutput(buf)
+	SetLogFormat(level, format)
+	t.Cleanup(func() {
+		log.SetOutput(origOut)
+		SetLogFormat(domain.INFO, "text")
+	})
+	return buf
+}
@@ -0,0 +1,183 @@
+package record
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+// Metrics collects ds-host metrics for Prometheus.
+// Labels never include request paths, domains or user data
+// so that the number of series stays bounded.
+// Appspace IDs are used as labels since there are only so many
+// appspaces on a host, and their series are removed on delete.
+type Metrics struct {
+	CGroups interface {
+		SandboxesMemoryPressure() (someUsec int, fullUsec int, err error)
+	} `checkinject:"optional"`
+	UserTSNet interface {
+		NodeStates() map[string]int
+	} `checkinject:"optional"`
+	AppspaceTSNet interface {
+		NodeStates() map[string]int
+	} `checkinject:"optional"`
+
+	appspaceRequests        *prometheus.CounterVec
+	appspaceRequestDuration *prometheus.HistogramVec
+	sandboxStartDuration    *prometheus.HistogramVec
+	sandboxes               *prometheus.GaugeVec
+	migrationJobDuration    *prometheus.HistogramVec
+	appGets                 *prometheus.CounterVec
+
+	tsnetNodesDesc     *prometheus.Desc
+	memoryPressureDesc *prometheus.Desc
+}
+
+// Init creates the metrics and registers them
+// with the default Prometheus registry
+func (m *Metrics) Init() {
+	m.init(prometheus.DefaultRegisterer)
+}
+
+func (m *Metrics) init(reg prometheus.Registerer) {
+	m.appspaceRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dropserver",
+		Name:      "appspace_requests_total",
+		Help:      "Requests handled by the appspace router by response code class.",
+	}, []string{"appspace_id", "code"})
+	m.appspaceRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "dropserver",
+		Name:      "appspace_request_duration_seconds",
+		Help:      "Time taken to respond to appspace requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"appspace_id"})
+	m.sandboxStartDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "dropserver",
+		Name:      "sandbox_start_duration_seconds",
+		Help:      "Time from requesting a sandbox to it being ready or failing to start.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"operation", "result"})
+	m.sandboxes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "dropserver",
+		Name:      "sandboxes",
+		Help:      "Number of sandboxes by status.",
+	}, []string{"status"})
+	m.migrationJobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "dropserver",
+		Name:      "migration_job_duration_seconds",
+		Help:      "Time taken to run appspace migration jobs.",
+		Buckets:   []float64{.1, .5, 1, 5, 10, 30, 60, 300, 900},
+	}, []string{"result"})
+	m.appGets = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dropserver",
+		Name:      "app_gets_total",
+		Help:      "App installs and version fetches by outcome.",
+	}, []string{"outcome"})
+
+	m.tsnetNodesDesc = prometheus.NewDesc("dropserver_tsnet_nodes",
+		"Number of tsnet nodes by kind and state.",
+		[]string{"kind", "state"}, nil)
+	m.memoryPressureDesc = prometheus.NewDesc("dropserver_sandboxes_memory_pressure_seconds_total",
+		"Total time sandboxes were stalled waiting on memory, from the sandboxes cgroup.",
+		[]string{"kind"}, nil)
+
+	reg.MustRegister(
+		m.appspaceRequests,
+		m.appspaceRequestDuration,
+		m.sandboxStartDuration,
+		m.sandboxes,
+		m.migrationJobDuration,
+		m.appGets,
+		&sourcesCollector{m})
+}
+
+// AppspaceRequest records a request handled for an appspace
+func (m *Metrics) AppspaceRequest(appspaceID domain.AppspaceID, status int, dur time.Duration) {
+	id := appspaceLabel(appspaceID)
+	m.appspaceRequests.WithLabelValues(id, codeClass(status)).Inc()
+	m.appspaceRequestDuration.WithLabelValues(id).Observe(dur.Seconds())
+}
+
+// ForgetAppspace removes the series of a deleted appspace
+func (m *Metrics) ForgetAppspace(appspaceID domain.AppspaceID) {
+	labels := prometheus.Labels{"appspace_id": appspaceLabel(appspaceID)}
+	m.appspaceRequests.DeletePartialMatch(labels)
+	m.appspaceRequestDuration.DeletePartialMatch(labels)
+}
+
+// SandboxStart records the time it took a sandbox to start
+// and whether it started successfully
+func (m *Metrics) SandboxStart(operation string, ok bool, dur time.Duration) {
+	m.sandboxStartDuration.WithLabelValues(operation, okResult(ok)).Observe(dur.Seconds())
+}
+
+// SandboxStatuses sets the number of sandboxes in each status.
+// Statuses not in counts are set to zero.
+func (m *Metrics) SandboxStatuses(counts map[string]int) {
+	m.sandboxes.Reset()
+	for status, c := range counts {
+		m.sandboxes.WithLabelValues(status).Set(float64(c))
+	}
+}
+
+// MigrationJob records the duration of a migration job
+func (m *Metrics) MigrationJob(ok bool, dur time.Duration) {
+	m.migrationJobDuration.WithLabelValues(okResult(ok)).Observe(dur.Seconds())
+}
+
+// AppGet records the outcome of an app get process
+func (m *Metrics) AppGet(outcome string) {
+	m.appGets.WithLabelValues(outcome).Inc()
+}
+
+func appspaceLabel(appspaceID domain.AppspaceID) string {
+	return strconv.Itoa(int(appspaceID))
+}
+
+func codeClass(status int) string {
+	if status < 100 || status > 599 {
+		return "other"
+	}
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+func okResult(ok bool) string {
+	if ok {
+		return "ok"
+	}
+	return "error"
+}
+
+// sourcesCollector reads values from other components at scrape time
+type sourcesCollector struct {
+	m *Metrics
+}
+
+func (c *sourcesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.m.tsnetNodesDesc
+	ch <- c.m.memoryPressureDesc
+}
+
+func (c *sourcesCollector) Collect(ch chan<- prometheus.Metric) {
+	m := c.m
+	if m.UserTSNet != nil {
+		for state, n := range m.UserTSNet.NodeStates() {
+			ch <- prometheus.MustNewConstMetric(m.tsnetNodesDesc, prometheus.GaugeValue, float64(n), "user", state)
+		}
+	}
+	if m.AppspaceTSNet != nil {
+		for state, n := range m.AppspaceTSNet.NodeStates() {
+			ch <- prometheus.MustNewConstMetric(m.tsnetNodesDesc, prometheus.GaugeValue, float64(n), "appspace", state)
+		}
+	}
+	if m.CGroups != nil {
+		some, full, err := m.CGroups.SandboxesMemoryPressure()
+		if err == nil {
+			ch <- prometheus.MustNewConstMetric(m.memoryPressureDesc, prometheus.CounterValue, float64(some)/1e6, "some")
+			ch <- prometheus.MustNewConstMetric(m.memoryPressureDesc, prometheus.CounterValue, float64(full)/1e6, "full")
+		}
+	}
+}
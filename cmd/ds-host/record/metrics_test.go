@@ -0,0 +1,116 @@
+package record
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+func TestCodeClass(t *testing.T) {
+	cases := []struct {
+		status int
+		class  string
+	}{
+		{200, "2xx"},
+		{304, "3xx"},
+		{404, "4xx"},
+		{503, "5xx"},
+		{0, "other"},
+		{999, "other"},
+	}
+	for _, c := range cases {
+		if got := codeClass(c.status); got != c.class {
+			t.Errorf("%v: expected %v, got %v", c.status, c.class, got)
+		}
+	}
+}
+
+func TestAppspaceRequest(t *testing.T) {
+	m := &Metrics{}
+	m.init(prometheus.NewRegistry())
+
+	m.AppspaceRequest(domain.AppspaceID(7), 200, time.Millisecond)
+	m.AppspaceRequest(domain.AppspaceID(7), 201, time.Millisecond)
+	m.AppspaceRequest(domain.AppspaceID(7), 404, time.Millisecond)
+	m.AppspaceRequest(domain.AppspaceID(8), 200, time.Millisecond)
+
+	if c := testutil.ToFloat64(m.appspaceRequests.WithLabelValues("7", "2xx")); c != 2 {
+		t.Errorf("expected 2 requests, got %v", c)
+	}
+	if c := testutil.CollectAndCount(m.appspaceRequests); c != 3 {
+		t.Errorf("expected 3 series, got %v", c)
+	}
+
+	m.ForgetAppspace(domain.AppspaceID(7))
+	if c := testutil.CollectAndCount(m.appspaceRequests); c != 1 {
+		t.Errorf("expected 1 series after forget, got %v", c)
+	}
+	if c := testutil.CollectAndCount(m.appspaceRequestDuration); c != 1 {
+		t.Errorf("expected 1 duration series after forget, got %v", c)
+	}
+}
+
+func TestSandboxStatuses(t *testing.T) {
+	m := &Metrics{}
+	m.init(prometheus.NewRegistry())
+
+	m.SandboxStatuses(map[string]int{"ready": 2, "starting": 1})
+	m.SandboxStatuses(map[string]int{"ready": 1})
+
+	if c := testutil.CollectAndCount(m.sandboxes); c != 1 {
+		t.Errorf("expected only the ready series, got %v", c)
+	}
+	if c := testutil.ToFloat64(m.sandboxes.WithLabelValues("ready")); c != 1 {
+		t.Errorf("expected 1 ready sandbox, got %v", c)
+	}
+}
+
+func TestSourcesCollector(t *testing.T) {
+	m := &Metrics{
+		CGroups:       &testPressure{some: 1500000, full: 500000},
+		AppspaceTSNet: &testNodeStates{"Running": 2, "NeedsLogin": 1},
+	}
+	m.init(prometheus.NewRegistry())
+
+	expected := `
+# HELP dropserver_sandboxes_memory_pressure_seconds_total Total time sandboxes were stalled waiting on memory, from the sandboxes cgroup.
+# TYPE dropserver_sandboxes_memory_pressure_seconds_total counter
+dropserver_sandboxes_memory_pressure_seconds_total{kind="full"} 0.5
+dropserver_sandboxes_memory_pressure_seconds_total{kind="some"} 1.5
+# HELP dropserver_tsnet_nodes Number of tsnet nodes by kind and state.
+# TYPE dropserver_tsnet_nodes gauge
+dropserver_tsnet_nodes{kind="appspace",state="NeedsLogin"} 1
+dropserver_tsnet_nodes{kind="appspace",state="Running"} 2
+`
+	err := testutil.CollectAndCompare(&sourcesCollector{m}, strings.NewReader(expected))
+	if err != nil {
+		t.Error(err)
+	}
+
+	// no pressure metrics if cgroups can't be read
+	m.CGroups = &testPressure{err: errors.New("no psi")}
+	if c := testutil.CollectAndCount(&sourcesCollector{m}, "dropserver_sandboxes_memory_pressure_seconds_total"); c != 0 {
+		t.Errorf("expected no pressure metrics, got %v", c)
+	}
+}
+
+type testPressure struct {
+	some int
+	full int
+	err  error
+}
+
+func (p *testPressure) SandboxesMemoryPressure() (int, int, error) {
+	return p.some, p.full, p.err
+}
+
+type testNodeStates map[string]int
+
+func (s *testNodeStates) NodeStates() map[string]int {
+	return *s
+}
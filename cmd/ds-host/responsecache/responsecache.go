@@ -0,0 +1,403 @@
+package responsecache
+
+import (
+	"container/list"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+// maxEntrySize is the largest response body that gets cached
+var maxEntrySize = 1 << 20
+
+// maxSize is the total size of cached bodies after which
+// least recently used entries are evicted
+var maxSize = 64 << 20
+
+var cacheableStatuses = map[int]bool{
+	http.StatusOK:               true,
+	http.StatusMovedPermanently: true,
+	http.StatusNotFound:         true,
+	http.StatusGone:             true,
+}
+
+// hop-by-hop and per-response headers that are not stored
+var skipHeaders = []string{"Connection", "Keep-Alive", "Transfer-Encoding", "Upgrade", "Date", "Age"}
+
+type cacheKey struct {
+	appspaceID domain.AppspaceID
+	version    domain.Version
+	proxyID    domain.ProxyID // empty for public routes
+	uri        string
+}
+
+type entry struct {
+	key     cacheKey
+	status  int
+	header  http.Header
+	body    []byte
+	vary    map[string]string // request header values named in Vary
+	stored  time.Time
+	expires time.Time
+}
+
+func (e *entry) etag() string {
+	return e.header.Get("ETag")
+}
+
+// ResponseCache is an HTTP cache in front of the sandbox
+// for function routes that opt in.
+// It honors Cache-Control and ETag set by the app.
+// Responses to routes that require auth are cached per user.
+// All of an appspace's entries are dropped when its status or files change.
+type ResponseCache struct {
+	AppspaceStatusEvents interface {
+		Subscribe() <-chan domain.AppspaceStatusEvent
+		Unsubscribe(ch <-chan domain.AppspaceStatusEvent)
+	} `checkinject:"required"`
+	AppspaceFilesEvents interface {
+		Subscribe() <-chan domain.AppspaceID
+		Unsubscribe(ch <-chan domain.AppspaceID)
+	} `checkinject:"required"`
+
+	mux     sync.Mutex
+	entries map[cacheKey]*list.Element
+	lru     *list.List
+	size    int
+
+	statusCh <-chan domain.AppspaceStatusEvent
+	filesCh  <-chan domain.AppspaceID
+}
+
+// Init sets up the cache and subscribes to events that invalidate it
+func (c *ResponseCache) Init() {
+	c.entries = make(map[cacheKey]*list.Element)
+	c.lru = list.New()
+
+	c.statusCh = c.AppspaceStatusEvents.Subscribe()
+	go func() {
+		for e := range c.statusCh {
+			c.Purge(e.AppspaceID)
+		}
+	}()
+	c.filesCh = c.AppspaceFilesEvents.Subscribe()
+	go func() {
+		for appspaceID := range c.filesCh {
+			c.Purge(appspaceID)
+		}
+	}()
+}
+
+// Stop unsubscribes from events
+func (c *ResponseCache) Stop() {
+	c.AppspaceStatusEvents.Unsubscribe(c.statusCh)
+	c.AppspaceFilesEvents.Unsubscribe(c.filesCh)
+}
+
+// Serve responds from cache if possible, otherwise it calls next
+// and stores the response if it is cacheable.
+func (c *ResponseCache) Serve(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.Header.Get("Upgrade") != "" {
+		next.ServeHTTP(w, r)
+		return
+	}
+	key, public, ok := getKey(r)
+	if !ok {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	now := time.Now()
+	e := c.get(key, r)
+	if e != nil && now.Before(e.expires) {
+		serveEntry(w, r, e, now)
+		return
+	}
+
+	// Ask the app for a full response so it can be stored,
+	// or for a 304 if we have a stale entry with an ETag.
+	fwd := r.Clone(r.Context())
+	fwd.Header.Del("If-None-Match")
+	fwd.Header.Del("If-Modified-Since")
+	rec := &recorder{ResponseWriter: w, header: make(http.Header)}
+	if e != nil && e.etag() != "" {
+		fwd.Header.Set("If-None-Match", e.etag())
+		rec.intercept304 = true
+	}
+	if r.Method == http.MethodHead {
+		// get the body so it can be stored. The server drops it for HEAD.
+		fwd.Method = http.MethodGet
+	}
+
+	next.ServeHTTP(rec, fwd)
+
+	if rec.intercepted {
+		e = c.refresh(e, rec.Header(), now)
+		serveEntry(w, r, e, now)
+		return
+	}
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if rec.tooBig || !cacheableStatuses[rec.status] {
+		return
+	}
+	expires, ok := freshness(rec.Header(), public, now)
+	if !ok {
+		return
+	}
+	vary, ok := varyValues(rec.Header(), r)
+	if !ok {
+		return
+	}
+	c.store(&entry{
+		key:     key,
+		status:  rec.status,
+		header:  storedHeader(rec.Header()),
+		body:    rec.body,
+		vary:    vary,
+		stored:  now,
+		expires: expires})
+}
+
+// Purge removes all entries for an appspace
+func (c *ResponseCache) Purge(appspaceID domain.AppspaceID) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for k, el := range c.entries {
+		if k.appspaceID == appspaceID {
+			c.remove(el)
+		}
+	}
+}
+
+func (c *ResponseCache) get(key cacheKey, r *http.Request) *entry {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*entry)
+	for h, v := range e.vary {
+		if r.Header.Get(h) != v {
+			return nil
+		}
+	}
+	c.lru.MoveToFront(el)
+	return e
+}
+
+func (c *ResponseCache) store(e *entry) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if el, ok := c.entries[e.key]; ok {
+		c.remove(el)
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+	c.size += len(e.body)
+	for c.size > maxSize {
+		c.remove(c.lru.Back())
+	}
+}
+
+// refresh updates a stale entry after the app confirmed it is unchanged
+func (c *ResponseCache) refresh(e *entry, header http.Header, now time.Time) *entry {
+	updated := *e
+	updated.header = e.header.Clone()
+	for _, h := range []string{"Cache-Control", "Expires", "ETag", "Last-Modified"} {
+		if v := header.Values(h); len(v) != 0 {
+			updated.header[h] = v
+		}
+	}
+	updated.stored = now
+	updated.expires = now
+	if expires, ok := freshness(updated.header, updated.key.proxyID == "", now); ok {
+		updated.expires = expires
+	}
+	c.store(&updated)
+	return &updated
+}
+
+// remove must be called with the lock held
+func (c *ResponseCache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*entry)
+	delete(c.entries, e.key)
+	c.size -= len(e.body)
+}
+
+func getKey(r *http.Request) (cacheKey, bool, bool) {
+	ctx := r.Context()
+	appspace, ok := domain.CtxAppspaceData(ctx)
+	if !ok {
+		return cacheKey{}, false, false
+	}
+	routeConfig, _ := domain.CtxRouteConfig(ctx)
+	key := cacheKey{
+		appspaceID: appspace.AppspaceID,
+		version:    appspace.AppVersion,
+		uri:        r.URL.RequestURI()}
+	public := routeConfig.Auth.Allow == "public"
+	if !public {
+		proxyID, ok := domain.CtxAppspaceUserProxyID(ctx)
+		if !ok {
+			return cacheKey{}, false, false
+		}
+		key.proxyID = proxyID
+	}
+	return key, public, true
+}
+
+// freshness returns when a response expires based on its headers.
+// It returns false if the response must not be stored.
+func freshness(header http.Header, public bool, now time.Time) (time.Time, bool) {
+	if header.Get("Set-Cookie") != "" {
+		return time.Time{}, false
+	}
+	maxAge := -1
+	sMaxAge := -1
+	noCache := false
+	for _, d := range strings.Split(strings.Join(header.Values("Cache-Control"), ","), ",") {
+		name, val, _ := strings.Cut(strings.TrimSpace(d), "=")
+		switch strings.ToLower(name) {
+		case "no-store":
+			return time.Time{}, false
+		case "private":
+			if public {
+				return time.Time{}, false
+			}
+		case "no-cache":
+			noCache = true
+		case "max-age":
+			maxAge = parseSeconds(val)
+		case "s-maxage":
+			sMaxAge = parseSeconds(val)
+		}
+	}
+	hasValidator := header.Get("ETag") != ""
+	switch {
+	case noCache:
+		return now, hasValidator
+	case sMaxAge >= 0:
+		return now.Add(time.Duration(sMaxAge) * time.Second), sMaxAge > 0 || hasValidator
+	case maxAge >= 0:
+		return now.Add(time.Duration(maxAge) * time.Second), maxAge > 0 || hasValidator
+	case hasValidator:
+		return now, true
+	}
+	return time.Time{}, false
+}
+
+func parseSeconds(v string) int {
+	s, err := strconv.Atoi(strings.Trim(v, `"`))
+	if err != nil || s < 0 {
+		return -1
+	}
+	return s
+}
+
+// varyValues collects the request headers named in the response's Vary header.
+// It returns false if the response varies on everything.
+func varyValues(header http.Header, r *http.Request) (map[string]string, bool) {
+	ret := make(map[string]string)
+	for _, v := range header.Values("Vary") {
+		for _, h := range strings.Split(v, ",") {
+			h = strings.TrimSpace(h)
+			if h == "*" {
+				return nil, false
+			}
+			if h != "" {
+				ret[http.CanonicalHeaderKey(h)] = r.Header.Get(h)
+			}
+		}
+	}
+	return ret, true
+}
+
+func storedHeader(header http.Header) http.Header {
+	ret := header.Clone()
+	for _, h := range skipHeaders {
+		ret.Del(h)
+	}
+	return ret
+}
+
+func serveEntry(w http.ResponseWriter, r *http.Request, e *entry, now time.Time) {
+	h := w.Header()
+	for k, v := range e.header {
+		h[k] = v
+	}
+	h.Set("Age", strconv.Itoa(int(now.Sub(e.stored).Seconds())))
+	if etag := e.etag(); etag != "" && r.Header.Get("If-None-Match") == etag {
+		h.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Length", strconv.Itoa(len(e.body)))
+	w.WriteHeader(e.status)
+	if r.Method != http.MethodHead {
+		w.Write(e.body)
+	}
+}
+
+// recorder passes the response through while keeping a copy of the body.
+// It has its own header map so that only the app's headers are stored,
+// not those already set on w by ds-host, like the request ID.
+// With intercept304 a 304 response is not passed through
+// so that the cached entry can be served instead.
+type recorder struct {
+	http.ResponseWriter
+	header       http.Header
+	status       int
+	body         []byte
+	tooBig       bool
+	intercept304 bool
+	intercepted  bool
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(code int) {
+	if rec.status != 0 {
+		return
+	}
+	rec.status = code
+	if rec.intercept304 && code == http.StatusNotModified {
+		rec.intercepted = true
+		return
+	}
+	h := rec.ResponseWriter.Header()
+	for k, v := range rec.header {
+		h[k] = v
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	if rec.intercepted {
+		return len(b), nil
+	}
+	if !rec.tooBig {
+		if len(rec.body)+len(b) > maxEntrySize {
+			rec.tooBig = true
+			rec.body = nil
+		} else {
+			rec.body = append(rec.body, b...)
+		}
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,213 @@
+package responsecache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/events"
+)
+
+type appHandler struct {
+	calls     int
+	header    map[string]string
+	status    int
+	body      string
+	reqHeader http.Header
+}
+
+func (h *appHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.calls++
+	h.reqHeader = r.Header.Clone()
+	for k, v := range h.header {
+		w.Header().Set(k, v)
+	}
+	if h.status != 0 {
+		w.WriteHeader(h.status)
+	}
+	if h.status != http.StatusNotModified {
+		w.Write([]byte(h.body))
+	}
+}
+
+func getCache(t *testing.T) *ResponseCache {
+	c := &ResponseCache{
+		AppspaceStatusEvents: &events.AppspaceStatusEvents{},
+		AppspaceFilesEvents:  &events.AppspaceFilesEvents{}}
+	c.Init()
+	t.Cleanup(c.Stop)
+	return c
+}
+
+func getReq(appspaceID domain.AppspaceID, allow string, proxyID domain.ProxyID) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/abc?d=e", nil)
+	ctx := domain.CtxWithAppspaceData(r.Context(), domain.Appspace{AppspaceID: appspaceID, AppVersion: domain.Version("0.1.0")})
+	ctx = domain.CtxWithRouteConfig(ctx, domain.AppRoute{Auth: domain.AppspaceRouteAuth{Allow: allow}, Options: domain.AppRouteOptions{Cache: true}})
+	if proxyID != "" {
+		ctx = domain.CtxWithAppspaceUserProxyID(ctx, proxyID)
+	}
+	return r.WithContext(ctx)
+}
+
+func serve(c *ResponseCache, r *http.Request, h http.Handler) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	c.Serve(rr, r, h)
+	return rr
+}
+
+func TestServeCached(t *testing.T) {
+	c := getCache(t)
+	h := &appHandler{header: map[string]string{"Cache-Control": "max-age=60"}, body: "hello"}
+
+	rr := serve(c, getReq(7, "public", ""), h)
+	if rr.Body.String() != "hello" {
+		t.Errorf("unexpected body %v", rr.Body.String())
+	}
+	rr = serve(c, getReq(7, "public", ""), h)
+	if rr.Body.String() != "hello" || rr.Code != http.StatusOK {
+		t.Errorf("unexpected cached response %v %v", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Age") == "" {
+		t.Error("expected Age header")
+	}
+	if h.calls != 1 {
+		t.Errorf("expected app to be called once, got %v", h.calls)
+	}
+}
+
+func TestServeCachedKeepsRequestHeaders(t *testing.T) {
+	c := getCache(t)
+	h := &appHandler{header: map[string]string{"Cache-Control": "max-age=60", "X-App": "yes"}, body: "hello"}
+
+	rr := httptest.NewRecorder()
+	rr.Header().Set("X-Request-ID", "first")
+	c.Serve(rr, getReq(7, "public", ""), h)
+	if rr.Header().Get("X-Request-ID") != "first" || rr.Header().Get("X-App") != "yes" {
+		t.Errorf("unexpected headers %v", rr.Header())
+	}
+
+	rr = httptest.NewRecorder()
+	rr.Header().Set("X-Request-ID", "second")
+	c.Serve(rr, getReq(7, "public", ""), h)
+	if h.calls != 1 {
+		t.Errorf("expected a cached response, app called %v times", h.calls)
+	}
+	if rr.Header().Get("X-Request-ID") != "second" {
+		t.Errorf("expected current request ID, got %v", rr.Header().Values("X-Request-ID"))
+	}
+	if rr.Header().Get("X-App") != "yes" {
+		t.Error("expected cached app header")
+	}
+}
+
+func TestServeNotCacheable(t *testing.T) {
+	cases := []map[string]string{
+		{},
+		{"Cache-Control": "no-store, max-age=60"},
+		{"Cache-Control": "private, max-age=60"},
+		{"Cache-Control": "max-age=60", "Set-Cookie": "a=b"},
+		{"Cache-Control": "max-age=60", "Vary": "*"},
+	}
+	for _, header := range cases {
+		c := getCache(t)
+		h := &appHandler{header: header, body: "hello"}
+		serve(c, getReq(7, "public", ""), h)
+		serve(c, getReq(7, "public", ""), h)
+		if h.calls != 2 {
+			t.Errorf("%v: expected app to be called twice, got %v", header, h.calls)
+		}
+	}
+}
+
+func TestServeVaryProxyID(t *testing.T) {
+	c := getCache(t)
+	h := &appHandler{header: map[string]string{"Cache-Control": "private, max-age=60"}, body: "hello"}
+
+	serve(c, getReq(7, "authorized", "abc"), h)
+	serve(c, getReq(7, "authorized", "abc"), h)
+	if h.calls != 1 {
+		t.Errorf("expected app to be called once, got %v", h.calls)
+	}
+	serve(c, getReq(7, "authorized", "def"), h)
+	if h.calls != 2 {
+		t.Errorf("expected app to be called for other user, got %v", h.calls)
+	}
+	// no user on authorized route is not cached
+	serve(c, getReq(7, "authorized", ""), h)
+	serve(c, getReq(7, "authorized", ""), h)
+	if h.calls != 4 {
+		t.Errorf("expected app to be called without user, got %v", h.calls)
+	}
+}
+
+func TestServeRevalidate(t *testing.T) {
+	c := getCache(t)
+	h := &appHandler{header: map[string]string{"Cache-Control": "no-cache", "ETag": `"v1"`}, body: "hello"}
+	serve(c, getReq(7, "public", ""), h)
+
+	h.status = http.StatusNotModified
+	rr := serve(c, getReq(7, "public", ""), h)
+	if h.calls != 2 {
+		t.Errorf("expected app to be asked to revalidate, got %v", h.calls)
+	}
+	if h.reqHeader.Get("If-None-Match") != `"v1"` {
+		t.Errorf("expected If-None-Match, got %v", h.reqHeader.Get("If-None-Match"))
+	}
+	if rr.Code != http.StatusOK || rr.Body.String() != "hello" {
+		t.Errorf("expected cached response, got %v %v", rr.Code, rr.Body.String())
+	}
+}
+
+func TestServeClientNotModified(t *testing.T) {
+	c := getCache(t)
+	h := &appHandler{header: map[string]string{"Cache-Control": "max-age=60", "ETag": `"v1"`}, body: "hello"}
+	serve(c, getReq(7, "public", ""), h)
+
+	r := getReq(7, "public", "")
+	r.Header.Set("If-None-Match", `"v1"`)
+	rr := serve(c, r, h)
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("expected 304, got %v %v", rr.Code, rr.Body.String())
+	}
+}
+
+func TestPurgeOnStatusEvent(t *testing.T) {
+	c := getCache(t)
+	h := &appHandler{header: map[string]string{"Cache-Control": "max-age=60"}, body: "hello"}
+	serve(c, getReq(7, "public", ""), h)
+	serve(c, getReq(8, "public", ""), h)
+
+	c.AppspaceStatusEvents.(*events.AppspaceStatusEvents).Send(domain.AppspaceStatusEvent{AppspaceID: 7, Paused: true})
+	time.Sleep(10 * time.Millisecond)
+
+	serve(c, getReq(7, "public", ""), h)
+	serve(c, getReq(8, "public", ""), h)
+	if h.calls != 3 {
+		t.Errorf("expected only appspace 7 to be purged, got %v calls", h.calls)
+	}
+}
+
+func TestFreshness(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		header  http.Header
+		public  bool
+		expires time.Time
+		ok      bool
+	}{
+		{http.Header{"Cache-Control": {"max-age=60"}}, true, now.Add(time.Minute), true},
+		{http.Header{"Cache-Control": {"max-age=60, s-maxage=10"}}, true, now.Add(10 * time.Second), true},
+		{http.Header{"Cache-Control": {"max-age=0"}}, true, now, false},
+		{http.Header{"Cache-Control": {"max-age=0"}, "Etag": {`"x"`}}, true, now, true},
+		{http.Header{"Cache-Control": {"private, max-age=60"}}, false, now.Add(time.Minute), true},
+		{http.Header{"Cache-Control": {"private, max-age=60"}}, true, time.Time{}, false},
+	}
+	for _, c := range cases {
+		expires, ok := freshness(c.header, c.public, now)
+		if ok != c.ok || (ok && !expires.Equal(c.expires)) {
+			t.Errorf("%v: got %v %v", c.header, expires, ok)
+		}
+	}
+}
@@ -53,6 +53,9 @@ func (s *AppspaceLocation2Path) Backup(locationKey string, backupFile string) st
 	checkNotEmpty(backupFile, "backup file")
 	return filepath.Join(s.Backups(locationKey), backupFile)
 }
+func (s *AppspaceLocation2Path) BackupChunks(locationKey string) string {
+	return filepath.Join(s.Base(locationKey), "backup-chunks")
+}
 func (s *AppspaceLocation2Path) DenoDir(locationKey string) string {
 	return filepath.Join(s.Base(locationKey), "deno-dir")
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/mail"
 	"net/netip"
 	"os"
 	"os/exec"
@@ -41,7 +42,22 @@ var configDefault = []byte(`{
 		"cgroup-mount": "/sys/fs/cgroup",
 		"memory-high-mb": 512,
 		"num": 3
-	}
+	},
+	"usage": {
+		"keep-runs-days": 90
+	},
+	"access-log": {
+		"default-keep-days": 14,
+		"max-keep-days": 90
+	},
+	"appspace-logs": {
+		"max-size-kb": 1024,
+		"max-age-days": 30,
+		"keep-archives": 10,
+		"keep-days": 180
+	},
+	"log-level": "info",
+	"log-format": "text"
 }`)
 
 // Load opens the json passed and merges it with defaults
@@ -172,6 +188,13 @@ func validateConfig(rtc *domain.RuntimeConfig) {
 			panic("allowed IP does not parse: " + s)
 		}
 	}
+	for _, s := range rtc.LoginLimits.TrustedProxies {
+		_, errA := netip.ParseAddr(s)
+		_, errP := netip.ParsePrefix(s)
+		if errA != nil && errP != nil {
+			panic("trusted proxy does not parse: " + s)
+		}
+	}
 
 	// ManageTLSCertificates
 	if rtc.ManageTLSCertificates.Enable {
@@ -198,6 +221,101 @@ func validateConfig(rtc *domain.RuntimeConfig) {
 	if rtc.Sandbox.Num == 0 {
 		panic("you need at least one sandbox")
 	}
+
+	// Backup remotes:
+	names := map[string]bool{}
+	for i, r := range rtc.Backups.Remotes {
+		if r.Name == "" {
+			panic("backup remote name can not be blank")
+		}
+		if names[r.Name] {
+			panic("duplicate backup remote name: " + r.Name)
+		}
+		if strings.ContainsAny(r.Name, "/ ") {
+			panic("backup remote name can not contain a / or a space: " + r.Name)
+		}
+		names[r.Name] = true
+		switch r.Type {
+		case "dir":
+			p := filepath.Clean(r.Dir)
+			if r.Dir == "" || !filepath.IsAbs(p) {
+				panic(fmt.Sprintf("backup remote %s: dir must be an absolute path", r.Name))
+			}
+			rtc.Backups.Remotes[i].Dir = p
+		case "s3":
+			if r.S3.Endpoint == "" || r.S3.Bucket == "" || r.S3.Region == "" {
+				panic(fmt.Sprintf("backup remote %s: s3 endpoint, region and bucket are required", r.Name))
+			}
+			if r.S3.AccessKeyID == "" || r.S3.SecretAccessKey == "" {
+				panic(fmt.Sprintf("backup remote %s: s3 credentials are required", r.Name))
+			}
+		default:
+			panic(fmt.Sprintf("backup remote %s: type must be dir or s3", r.Name))
+		}
+	}
+
+	// Usage: runs are re-rolled for a few days, so they need to be kept a bit.
+	if k := rtc.Usage.KeepRunsDays; k < 0 || (k > 0 && k < 14) {
+		panic("usage.keep-runs-days must be 0 or at least 14")
+	}
+
+	if rtc.AccessLog.DefaultKeepDays < 1 || rtc.AccessLog.MaxKeepDays < rtc.AccessLog.DefaultKeepDays {
+		panic("access-log.default-keep-days must be at least 1 and not more than max-keep-days")
+	}
+
+	al := rtc.AppspaceLogs
+	if al.MaxSizeKb < 0 || al.MaxAgeDays < 0 || al.KeepArchives < 0 || al.KeepDays < 0 {
+		panic("appspace-logs values can not be negative")
+	}
+
+	validateMail(rtc)
+
+	if _, err := record.ParseLogLevel(rtc.LogLevel); err != nil {
+		panic(err.Error())
+	}
+	if rtc.LogFormat != "text" && rtc.LogFormat != "json" {
+		panic("log-format must be text or json")
+	}
+}
+
+func validateMail(rtc *domain.RuntimeConfig) {
+	m := &rtc.Mail
+	if m.Type == "" {
+		return
+	}
+	if _, err := mail.ParseAddress(m.From); err != nil {
+		panic("mail.from must be a valid email address")
+	}
+	switch m.Type {
+	case "smtp":
+		if m.SMTP.Host == "" {
+			panic("mail.smtp.host can not be blank")
+		}
+		if m.SMTP.TLS == "" {
+			m.SMTP.TLS = "starttls"
+		}
+		if m.SMTP.Port == 0 {
+			switch m.SMTP.TLS {
+			case "tls":
+				m.SMTP.Port = 465
+			case "none":
+				m.SMTP.Port = 25
+			default:
+				m.SMTP.Port = 587
+			}
+		}
+		if m.SMTP.TLS != "starttls" && m.SMTP.TLS != "tls" && m.SMTP.TLS != "none" {
+			panic("mail.smtp.tls must be starttls, tls or none")
+		}
+	case "maildir":
+		p := filepath.Clean(m.Dir)
+		if m.Dir == "" || !filepath.IsAbs(p) {
+			panic("mail.dir must be an absolute path")
+		}
+		m.Dir = p
+	default:
+		panic("mail.type must be smtp or maildir")
+	}
 }
 
 func checkDirExists(dir string, name string) {
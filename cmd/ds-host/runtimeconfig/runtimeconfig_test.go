@@ -113,6 +113,97 @@ func TestValidateCertManageEnable(t *testing.T) {
 
 }
 
+func TestValidateBackupRemotes(t *testing.T) {
+	rtc := getPassingDefault()
+	rtc.Backups.Remotes = []domain.BackupRemote{{Name: "nas", Type: "dir", Dir: "/mnt/nas/backups/"}}
+	tv(t, rtc, "dir remote", false)
+	assertEqStr(t, "/mnt/nas/backups", rtc.Backups.Remotes[0].Dir)
+
+	rtc.Backups.Remotes[0].Dir = "backups"
+	tv(t, rtc, "relative dir remote", true)
+
+	rtc = getPassingDefault()
+	s3 := domain.BackupRemote{Name: "offsite", Type: "s3"}
+	s3.S3.Endpoint = "https://s3.example.com"
+	s3.S3.Region = "us-east-1"
+	s3.S3.Bucket = "backups"
+	rtc.Backups.Remotes = []domain.BackupRemote{s3}
+	tv(t, rtc, "s3 remote without credentials", true)
+
+	rtc.Backups.Remotes[0].S3.AccessKeyID = "abc"
+	rtc.Backups.Remotes[0].S3.SecretAccessKey = "def"
+	tv(t, rtc, "s3 remote", false)
+
+	rtc.Backups.Remotes = append(rtc.Backups.Remotes, rtc.Backups.Remotes[0])
+	tv(t, rtc, "duplicate remote names", true)
+
+	rtc.Backups.Remotes = []domain.BackupRemote{{Name: "ftp", Type: "ftp"}}
+	tv(t, rtc, "unknown remote type", true)
+}
+
+func TestValidateUsageKeepRuns(t *testing.T) {
+	rtc := getPassingDefault()
+	if rtc.Usage.KeepRunsDays != 90 {
+		t.Errorf("expected default of 90 days, got %v", rtc.Usage.KeepRunsDays)
+	}
+	rtc.Usage.KeepRunsDays = 0
+	tv(t, rtc, "keep runs forever", false)
+	rtc.Usage.KeepRunsDays = 3
+	tv(t, rtc, "keep runs too short", true)
+}
+
+func TestValidateAccessLog(t *testing.T) {
+	rtc := getPassingDefault()
+	rtc.AccessLog.DefaultKeepDays = 0
+	tv(t, rtc, "access log default keep days zero", true)
+	rtc = getPassingDefault()
+	rtc.AccessLog.MaxKeepDays = 7
+	tv(t, rtc, "access log max keep days less than default", true)
+}
+
+func TestValidateLog(t *testing.T) {
+	rtc := getPassingDefault()
+	rtc.LogLevel = "debug"
+	tv(t, rtc, "debug level", false)
+	rtc.LogLevel = "verbose"
+	tv(t, rtc, "bad level", true)
+
+	rtc = getPassingDefault()
+	rtc.LogFormat = "json"
+	tv(t, rtc, "json format", false)
+	rtc.LogFormat = "xml"
+	tv(t, rtc, "bad format", true)
+}
+
+func TestValidateMail(t *testing.T) {
+	rtc := getPassingDefault()
+	rtc.Mail.Type = "smtp"
+	rtc.Mail.SMTP.Host = "mail.example.com"
+	tv(t, rtc, "smtp without from", true)
+
+	rtc.Mail.From = "Dropserver <ds@example.com>"
+	tv(t, rtc, "smtp", false)
+	assertEqStr(t, "starttls", rtc.Mail.SMTP.TLS)
+	if rtc.Mail.SMTP.Port != 587 {
+		t.Errorf("expected default port 587, got %v", rtc.Mail.SMTP.Port)
+	}
+
+	rtc.Mail.SMTP.TLS = "ssl"
+	tv(t, rtc, "bad tls", true)
+
+	rtc = getPassingDefault()
+	rtc.Mail.Type = "maildir"
+	rtc.Mail.From = "ds@example.com"
+	rtc.Mail.Dir = "mail"
+	tv(t, rtc, "relative maildir", true)
+	rtc.Mail.Dir = "/var/mail/ds/"
+	tv(t, rtc, "maildir", false)
+	assertEqStr(t, "/var/mail/ds", rtc.Mail.Dir)
+
+	rtc.Mail.Type = "sendmail"
+	tv(t, rtc, "unknown type", true)
+}
+
 func TestSetExec(t *testing.T) {
 	rtc := getPassingDefault()
 	rtc.ExternalAccess.Domain = "somedomain.com"
@@ -16,6 +16,7 @@ import (
 
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
 	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+	"golang.org/x/sys/unix"
 )
 
 var hostCGroup = "host"
@@ -25,6 +26,7 @@ type CGroups struct {
 	Config *domain.RuntimeConfig `checkinject:"required"`
 
 	rootCGroupPath string
+	cpuController  bool
 
 	idMux      sync.Mutex
 	nextID     int
@@ -89,7 +91,18 @@ func (c *CGroups) Init() error {
 	}
 
 	// then populate subtree_control at root and sandboxes
+	// The cpu controller is needed for cpu.max but may not be delegated to us.
+	// In that case carry on without CPU limits.
+	c.cpuController = true
 	for _, p := range []string{"", sandboxesCGroup} {
+		if c.cpuController {
+			err = c.setSubtreeControl(p, []string{"memory", "io", "cpu"})
+			if err == nil {
+				continue
+			}
+			c.getLogger("Init").Log("unable to enable cpu controller, CPU limits will not be applied")
+			c.cpuController = false
+		}
 		err = c.setSubtreeControl(p, []string{"memory", "io"})
 		if err != nil {
 			return err
@@ -122,20 +135,91 @@ func (c *CGroups) CreateCGroup(limits domain.CGroupLimits) (string, error) {
 		return "", err
 	}
 
-	ctls := []struct {
-		controller string
-		value      string
-	}{
+	for _, ctl := range c.limitControllers(limits) {
+		err = c.setController(filepath.Join(sandboxesCGroup, cGroup, ctl.controller), ctl.value)
+		if err != nil {
+			return "", err
+		}
+	}
+	return cGroup, nil
+}
+
+type controllerValue struct {
+	controller string
+	value      string
+}
+
+// limitControllers returns the values to write to set limits.
+// Limits that are zero are left alone.
+func (c *CGroups) limitControllers(limits domain.CGroupLimits) []controllerValue {
+	ctls := []controllerValue{
 		//{controller: "cpu.weight", value: "100"},
 		{controller: "memory.high", value: fmt.Sprintf("%v", limits.MemoryHigh)},
 	}
-	for _, ctl := range ctls {
-		err = c.setController(filepath.Join(sandboxesCGroup, cGroup, ctl.controller), ctl.value)
+	if limits.MemoryMax != 0 {
+		ctls = append(ctls, controllerValue{"memory.max", fmt.Sprintf("%v", limits.MemoryMax)})
+	}
+	if limits.CPUMaxPercent != 0 {
+		if c.cpuController {
+			ctls = append(ctls, controllerValue{"cpu.max", cpuMaxValue(limits.CPUMaxPercent)})
+		} else {
+			c.getLogger("limitControllers").Log("cpu controller not enabled, ignoring CPU limit")
+		}
+	}
+	if limits.IOPath != "" && (limits.IOReadBps != 0 || limits.IOWriteBps != 0 || limits.IOReadIOPS != 0 || limits.IOWriteIOPS != 0) {
+		dev, err := ioDevice(limits.IOPath)
+		if err != nil {
+			c.getLogger("limitControllers ioDevice").AddNote(limits.IOPath).Error(err)
+		} else {
+			ctls = append(ctls, controllerValue{"io.max", ioMaxValue(dev, limits)})
+		}
+	}
+	return ctls
+}
+
+const cpuPeriodUsec = 100000
+
+// cpuMaxValue returns the cpu.max value for a percentage of one CPU
+func cpuMaxValue(percent int) string {
+	return fmt.Sprintf("%v %v", percent*cpuPeriodUsec/100, cpuPeriodUsec)
+}
+
+func ioMaxValue(dev string, limits domain.CGroupLimits) string {
+	v := func(l int) string {
+		if l == 0 {
+			return "max"
+		}
+		return strconv.Itoa(l)
+	}
+	return fmt.Sprintf("%v rbps=%v wbps=%v riops=%v wiops=%v", dev,
+		v(limits.IOReadBps), v(limits.IOWriteBps), v(limits.IOReadIOPS), v(limits.IOWriteIOPS))
+}
+
+// ioDevice returns the "major:minor" of the block device that holds p.
+// io.max only accepts whole disks, so partitions are resolved to their disk.
+func ioDevice(p string) (string, error) {
+	var st unix.Stat_t
+	err := unix.Stat(p, &st)
+	if err != nil {
+		return "", err
+	}
+	dev := fmt.Sprintf("%v:%v", unix.Major(uint64(st.Dev)), unix.Minor(uint64(st.Dev)))
+	if unix.Major(uint64(st.Dev)) == 0 {
+		return "", fmt.Errorf("%v is not on a block device (%v)", p, dev)
+	}
+	sysPath := filepath.Join("/sys/dev/block", dev)
+	if _, err := os.Stat(filepath.Join(sysPath, "partition")); err == nil {
+		real, err := filepath.EvalSymlinks(sysPath)
+		if err != nil {
+			return "", err
+		}
+		parent, err := os.ReadFile(filepath.Join(filepath.Dir(real), "dev"))
 		if err != nil {
 			return "", err
 		}
+		dev = strings.TrimSpace(string(parent))
 	}
-	return cGroup, nil
+	return dev, nil
 }
 
 func (c *CGroups) SetLimits(cGroup string, limits domain.CGroupLimits) error {
@@ -143,13 +227,7 @@ func (c *CGroups) SetLimits(cGroup string, limits domain.CGroupLimits) error {
 	if err != nil {
 		return err
 	}
-	ctls := []struct {
-		controller string
-		value      string
-	}{
-		{controller: "memory.high", value: fmt.Sprintf("%v", limits.MemoryHigh)},
-	}
-	for _, ctl := range ctls {
+	for _, ctl := range c.limitControllers(limits) {
 		err = c.setController(filepath.Join(sandboxesCGroup, cGroup, ctl.controller), ctl.value)
 		if err != nil {
 			return err
@@ -257,9 +335,78 @@ func (c *CGroups) GetMetrics(cGroup string) (data domain.CGroupData, err error)
 	data.IOBytes = ioBytes
 	data.IOs = ioOps
 
+	data.CPUThrottled = parseFlatKeyed(cpuStr)["nr_throttled"]
+
+	eventsStr, err := c.readFile(filepath.Join(cGroup, "memory.events"))
+	if err != nil {
+		return
+	}
+	events := parseFlatKeyed(eventsStr)
+	data.MemoryHighEvents = events["high"]
+	data.MemoryMaxEvents = events["max"]
+	data.OOMKills = events["oom_kill"]
+
+	// memory.pressure is missing if the kernel does not have PSI,
+	// which should not prevent collecting the other metrics.
+	pressureStr, pErr := c.readFile(filepath.Join(cGroup, "memory.pressure"))
+	if pErr == nil {
+		data.MemoryPressureUsec = parsePressureTotal(pressureStr)
+	}
+
 	return
 }
 
+// parseFlatKeyed parses cgroup files made of "key value" lines.
+// Lines that don't parse are ignored.
+func parseFlatKeyed(str string) map[string]int {
+	ret := make(map[string]int)
+	for _, line := range strings.Split(str, "\n") {
+		k, v, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		val, err := strconv.Atoi(v)
+		if err != nil {
+			continue
+		}
+		ret[k] = val
+	}
+	return ret
+}
+
+// SandboxesMemoryPressure returns the total time in microseconds
+// that some or all sandbox processes were stalled on memory
+func (c *CGroups) SandboxesMemoryPressure() (someUsec int, fullUsec int, err error) {
+	// Not logged: this is called on every scrape, and the file
+	// is missing on kernels without PSI.
+	pressureBytes, err := ioutil.ReadFile(filepath.Join(c.rootCGroupPath, sandboxesCGroup, "memory.pressure"))
+	if err != nil {
+		return
+	}
+	pressureStr := string(pressureBytes)
+	return pressureTotalOf(pressureStr, "some"), pressureTotalOf(pressureStr, "full"), nil
+}
+
+// parsePressureTotal returns the "some" total stall time in a pressure file
+func parsePressureTotal(str string) int {
+	return pressureTotalOf(str, "some")
+}
+
+func pressureTotalOf(str string, kind string) int {
+	for _, line := range strings.Split(str, "\n") {
+		if !strings.HasPrefix(line, kind+" ") {
+			continue
+		}
+		for _, p := range strings.Fields(line)[1:] {
+			if t, ok := strings.CutPrefix(p, "total="); ok {
+				val, _ := strconv.Atoi(t)
+				return val
+			}
+		}
+	}
+	return 0
+}
+
 func (c *CGroups) parseCpuTime(cpuStr string) (int, error) {
 	cpuLines := strings.Split(cpuStr, "\n")
 	// if len(cpuLines) != 3 {
@@ -2,7 +2,12 @@
 
 package sandbox
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
 
 func TestGetNewCGroups(t *testing.T) {
 	c := &CGroups{
@@ -64,3 +69,56 @@ func TestReadIOStats(t *testing.T) {
 		t.Error("Got Wrong io ops")
 	}
 }
+
+func TestParseFlatKeyed(t *testing.T) {
+	str := `low 0
+high 12
+max 3
+oom 1
+oom_kill 1
+`
+	events := parseFlatKeyed(str)
+	if events["high"] != 12 || events["max"] != 3 || events["oom_kill"] != 1 {
+		t.Errorf("unexpected events: %v", events)
+	}
+	if _, ok := events[""]; ok {
+		t.Error("empty line should be ignored")
+	}
+}
+
+func TestParsePressureTotal(t *testing.T) {
+	str := `some avg10=0.00 avg60=1.20 avg300=0.40 total=123456
+full avg10=0.00 avg60=0.00 avg300=0.00 total=789`
+	if total := parsePressureTotal(str); total != 123456 {
+		t.Errorf("unexpected total: %v", total)
+	}
+	if total := pressureTotalOf(str, "full"); total != 789 {
+		t.Errorf("unexpected full total: %v", total)
+	}
+}
+
+func TestLimitControllers(t *testing.T) {
+	c := &CGroups{cpuController: true}
+	ctls := c.limitControllers(domain.CGroupLimits{MemoryHigh: 100, MemoryMax: 200, CPUMaxPercent: 50})
+	expected := []controllerValue{
+		{"memory.high", "100"},
+		{"memory.max", "200"},
+		{"cpu.max", "50000 100000"},
+	}
+	if !reflect.DeepEqual(ctls, expected) {
+		t.Errorf("unexpected controllers: %v", ctls)
+	}
+
+	c.cpuController = false
+	ctls = c.limitControllers(domain.CGroupLimits{MemoryHigh: 100, CPUMaxPercent: 50})
+	if len(ctls) != 1 {
+		t.Errorf("expected cpu.max to be skipped: %v", ctls)
+	}
+}
+
+func TestIOMaxValue(t *testing.T) {
+	v := ioMaxValue("8:0", domain.CGroupLimits{IOReadBps: 1000, IOWriteIOPS: 20})
+	if v != "8:0 rbps=1000 wbps=max riops=max wiops=20" {
+		t.Errorf("unexpected io.max value: %v", v)
+	}
+}
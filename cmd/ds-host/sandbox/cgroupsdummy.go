@@ -33,3 +33,6 @@ func (c *CGroups) GetMetrics(string) (domain.CGroupData, error) {
 func (c *CGroups) RemoveCGroup(string) error {
 	return errNoSupport
 }
+func (c *CGroups) SandboxesMemoryPressure() (int, int, error) {
+	return 0, 0, errNoSupport
+}
@@ -0,0 +1,89 @@
+package sandbox
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+)
+
+// diskUsageTTL is how long a measured size is used before it is measured again
+var diskUsageTTL = 10 * time.Minute
+
+// diskUsage caches the size of appspace data directories.
+// Measuring walks the whole directory, so it is done in the background
+// and sandbox starts use the last measured size.
+type diskUsage struct {
+	mux     sync.Mutex
+	entries map[string]*diskUsageEntry
+}
+
+type diskUsageEntry struct {
+	size      int64
+	measured  time.Time
+	measuring bool
+}
+
+// Get returns the last measured size of the directory.
+// If it was never measured or the measurement is stale,
+// the directory is measured in the background.
+// ok is false if the directory was never measured.
+func (d *diskUsage) Get(p string) (size int64, ok bool) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	e := d.getEntry(p)
+	if !e.measuring && time.Since(e.measured) > diskUsageTTL {
+		e.measuring = true
+		go d.measure(p)
+	}
+	return e.size, !e.measured.IsZero()
+}
+
+func (d *diskUsage) measure(p string) {
+	size, err := dirSize(p)
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	e := d.getEntry(p)
+	e.measuring = false
+	if err != nil {
+		// try again next time
+		record.NewDsLogger().AddNote("diskUsage measure() dirSize").Error(err)
+		return
+	}
+	e.size = size
+	e.measured = time.Now()
+}
+
+// getEntry expects the lock to be held
+func (d *diskUsage) getEntry(p string) *diskUsageEntry {
+	if d.entries == nil {
+		d.entries = make(map[string]*diskUsageEntry)
+	}
+	e, ok := d.entries[p]
+	if !ok {
+		e = &diskUsageEntry{}
+		d.entries[p] = e
+	}
+	return e
+}
+
+func dirSize(p string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(p, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,51 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiskUsage(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "a"), make([]byte, 1000), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &diskUsage{}
+	_, ok := d.Get(dir)
+	if ok {
+		t.Error("expected no size before the first measurement")
+	}
+	var size int64
+	for i := 0; i < 100 && !ok; i++ {
+		time.Sleep(10 * time.Millisecond)
+		size, ok = d.Get(dir)
+	}
+	if !ok || size != 1000 {
+		t.Fatalf("expected size to be measured in the background: %v %v", size, ok)
+	}
+
+	// the measured size is used until it is stale
+	err = os.WriteFile(filepath.Join(dir, "b"), make([]byte, 500), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, _ = d.Get(dir)
+	if size != 1000 {
+		t.Errorf("expected cached size, got %v", size)
+	}
+	d.mux.Lock()
+	d.entries[dir].measured = time.Now().Add(-diskUsageTTL - time.Second)
+	d.mux.Unlock()
+	d.Get(dir)
+	for i := 0; i < 100 && size != 1500; i++ {
+		time.Sleep(10 * time.Millisecond)
+		size, _ = d.Get(dir)
+	}
+	if size != 1500 {
+		t.Errorf("expected stale size to be measured again, got %v", size)
+	}
+}
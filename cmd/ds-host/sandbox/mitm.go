@@ -0,0 +1,150 @@
+package sandbox
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/elazarl/goproxy"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+// captureBodyMax is the most bytes of a body kept in a captured exchange
+const captureBodyMax = 64 * 1024
+
+// MITMCA is a certificate authority that lets OutProxy intercept
+// HTTPS requests made by app code. It is meant to be created for
+// a single session and never to be trusted outside the sandbox.
+type MITMCA struct {
+	cert    tls.Certificate
+	certPEM []byte
+}
+
+// NewMITMCA generates a CA with a fresh key
+func NewMITMCA() (*MITMCA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "Dropserver sandbox MITM CA", Organization: []string{"Dropserver"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(30 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &MITMCA{
+		cert: tls.Certificate{
+			Certificate: [][]byte{der},
+			PrivateKey:  key,
+			Leaf:        leaf},
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}, nil
+}
+
+// CertPEM returns the CA certificate in PEM format
+func (c *MITMCA) CertPEM() []byte {
+	return c.certPEM
+}
+
+// WriteCert writes the CA certificate so Deno can be told to trust it
+func (c *MITMCA) WriteCert(p string) error {
+	return os.WriteFile(p, c.certPEM, 0644)
+}
+
+func (c *MITMCA) connectAction() *goproxy.ConnectAction {
+	return &goproxy.ConnectAction{
+		Action:    goproxy.ConnectMitm,
+		TLSConfig: goproxy.TLSConfigFromCA(&c.cert)}
+}
+
+// captureRequest starts an exchange from the request.
+// The request body is replaced so it can still be sent upstream.
+func captureRequest(req *http.Request) *domain.OutboundExchange {
+	e := &domain.OutboundExchange{
+		Timestamp:     time.Now(),
+		Method:        req.Method,
+		URL:           req.URL.String(),
+		RequestHeader: req.Header.Clone()}
+	if req.Body != nil && req.Body != http.NoBody {
+		buf, truncated, rest := readPrefix(req.Body)
+		e.RequestBody = string(buf)
+		e.RequestTruncated = truncated
+		req.Body = rest
+	}
+	return e
+}
+
+// readPrefix reads up to captureBodyMax bytes of body
+// and returns a body that yields the full original content.
+func readPrefix(body io.ReadCloser) ([]byte, bool, io.ReadCloser) {
+	buf, _ := io.ReadAll(io.LimitReader(body, captureBodyMax+1))
+	truncated := len(buf) > captureBodyMax
+	rest := struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), body), body}
+	if truncated {
+		buf = buf[:captureBodyMax]
+	}
+	return buf, truncated, rest
+}
+
+// captureBody keeps the first bytes read from the response body,
+// and calls done when the body is closed.
+type captureBody struct {
+	io.ReadCloser
+	buf       bytes.Buffer
+	truncated bool
+	once      sync.Once
+	done      func(body []byte, truncated bool)
+}
+
+func (b *captureBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		room := captureBodyMax - b.buf.Len()
+		if room > n {
+			room = n
+		}
+		if room < n {
+			b.truncated = true
+		}
+		b.buf.Write(p[:room])
+	}
+	return n, err
+}
+
+func (b *captureBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		b.done(b.buf.Bytes(), b.truncated)
+	})
+	return err
+}
@@ -0,0 +1,151 @@
+package sandbox
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+func TestNewMITMCA(t *testing.T) {
+	ca, err := NewMITMCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ca.cert.Leaf.IsCA {
+		t.Error("expected a CA cert")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca.CertPEM()) {
+		t.Error("failed to parse cert PEM")
+	}
+}
+
+func TestOutProxyMITMCapture(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Test", "yes")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("got " + string(body)))
+	}))
+	defer ts.Close()
+
+	ca, err := NewMITMCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := domain.RuntimeConfig{}
+	config.LocalNetwork.AllowedIPs = []string{"127.0.0.1"}
+
+	captured := make(chan domain.OutboundExchange, 1)
+	o := &OutProxy{
+		Log:         func(string) {},
+		CA:          ca,
+		Capture:     func(e domain.OutboundExchange) { captured <- e },
+		upstreamTLS: ts.Client().Transport.(*http.Transport).TLSClientConfig}
+	err = o.Start(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca.CertPEM())
+	proxyURL, _ := url.Parse(fmt.Sprintf("http://localhost:%d", o.Port()))
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{RootCAs: pool}}}
+
+	resp, err := client.Post(ts.URL+"/abc", "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "got hello" {
+		t.Errorf("unexpected body: %s", body)
+	}
+
+	select {
+	case e := <-captured:
+		if e.Method != http.MethodPost || !strings.HasSuffix(e.URL, "/abc") || !strings.HasPrefix(e.URL, "https://") {
+			t.Errorf("unexpected request: %s %s", e.Method, e.URL)
+		}
+		if e.RequestBody != "hello" {
+			t.Errorf("unexpected request body: %s", e.RequestBody)
+		}
+		if e.Status != http.StatusCreated || e.ResponseHeader.Get("X-Test") != "yes" {
+			t.Errorf("unexpected response: %v %v", e.Status, e.ResponseHeader)
+		}
+		if e.ResponseBody != "got hello" {
+			t.Errorf("unexpected response body: %s", e.ResponseBody)
+		}
+	case <-time.After(time.Second):
+		t.Error("exchange not captured")
+	}
+
+	if o.Usage().Requests != 1 {
+		t.Errorf("expected one request metered, got %v", o.Usage().Requests)
+	}
+}
+
+func TestOutProxyMITMVerifiesUpstream(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("should not get here"))
+	}))
+	defer ts.Close()
+
+	ca, err := NewMITMCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := domain.RuntimeConfig{}
+	config.LocalNetwork.AllowedIPs = []string{"127.0.0.1"}
+
+	o := &OutProxy{
+		Log: func(string) {},
+		CA:  ca}
+	err = o.Start(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca.CertPEM())
+	proxyURL, _ := url.Parse(fmt.Sprintf("http://localhost:%d", o.Port()))
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{RootCAs: pool}}}
+
+	resp, err := client.Get(ts.URL)
+	if err == nil {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) == "should not get here" {
+			t.Error("expected upstream with untrusted certificate to be rejected")
+		}
+	}
+}
+
+func TestReadPrefix(t *testing.T) {
+	long := strings.Repeat("a", captureBodyMax+10)
+	buf, truncated, rest := readPrefix(io.NopCloser(strings.NewReader(long)))
+	if !truncated || len(buf) != captureBodyMax {
+		t.Errorf("expected truncated prefix, got %v %v", truncated, len(buf))
+	}
+	all, _ := io.ReadAll(rest)
+	if string(all) != long {
+		t.Error("expected full body to be readable")
+	}
+}
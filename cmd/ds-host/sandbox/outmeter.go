@@ -0,0 +1,155 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+var errOutboundCap = errors.New("daily outbound traffic cap reached")
+
+// OutLimits are the limits applied to outbound traffic of a sandbox.
+// Zero values mean no limit.
+type OutLimits struct {
+	ReqPerMin   int
+	BytesPerDay int64
+	// UsedToday is the outbound bytes used earlier today by other runs
+	UsedToday int64
+}
+
+// outUsage is the outbound traffic of a sandbox run
+type outUsage struct {
+	Requests      int
+	BytesSent     int
+	BytesReceived int
+	RateDenied    int  // requests denied by the rate limit
+	CapHit        bool // the daily cap was reached
+}
+
+// outMeter counts outbound requests and bytes and applies OutLimits
+type outMeter struct {
+	limits OutLimits
+	now    func() time.Time
+
+	mux         sync.Mutex
+	usage       outUsage
+	windowStart time.Time
+	windowCount int
+	day         time.Time
+	dayBase     int // bytes of this run before day started
+}
+
+func newOutMeter(limits OutLimits) *outMeter {
+	m := &outMeter{
+		limits: limits,
+		now:    time.Now}
+	m.day = dayStart(m.now())
+	return m
+}
+
+// request counts an outbound request or CONNECT.
+// It returns the name of the limit if the request must be denied.
+func (m *outMeter) request() string {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.overCap() {
+		return domain.LimitOutDaily
+	}
+	if m.limits.ReqPerMin != 0 {
+		now := m.now()
+		if now.Sub(m.windowStart) >= time.Minute {
+			m.windowStart = now
+			m.windowCount = 0
+		}
+		if m.windowCount >= m.limits.ReqPerMin {
+			m.usage.RateDenied++
+			return domain.LimitOutRate
+		}
+		m.windowCount++
+	}
+	m.usage.Requests++
+	return ""
+}
+
+// overCap returns true if the bytes used today reached the daily cap.
+// mux must be locked.
+func (m *outMeter) overCap() bool {
+	if m.limits.BytesPerDay == 0 {
+		return false
+	}
+	total := m.usage.BytesSent + m.usage.BytesReceived
+	if d := dayStart(m.now()); !d.Equal(m.day) {
+		m.day = d
+		m.dayBase = total
+		m.limits.UsedToday = 0
+	}
+	over := m.limits.UsedToday+int64(total-m.dayBase) >= m.limits.BytesPerDay
+	if over {
+		m.usage.CapHit = true
+	}
+	return over
+}
+
+func (m *outMeter) add(sent, received int) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.usage.BytesSent += sent
+	m.usage.BytesReceived += received
+}
+
+func (m *outMeter) get() outUsage {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.usage
+}
+
+// dial wraps dial so that bytes of the connection are counted
+func (m *outMeter) dial(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		c, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &meteredConn{Conn: c, meter: m}, nil
+	}
+}
+
+// meteredConn counts bytes read and written,
+// and fails once the daily cap is reached.
+type meteredConn struct {
+	net.Conn
+	meter *outMeter
+}
+
+func (c *meteredConn) Read(b []byte) (int, error) {
+	if c.capped() {
+		return 0, errOutboundCap
+	}
+	n, err := c.Conn.Read(b)
+	c.meter.add(0, n)
+	return n, err
+}
+
+func (c *meteredConn) Write(b []byte) (int, error) {
+	if c.capped() {
+		return 0, errOutboundCap
+	}
+	n, err := c.Conn.Write(b)
+	c.meter.add(n, 0)
+	return n, err
+}
+
+func (c *meteredConn) capped() bool {
+	c.meter.mux.Lock()
+	defer c.meter.mux.Unlock()
+	return c.meter.overCap()
+}
+
+func dayStart(t time.Time) time.Time {
+	y, mo, d := t.Date()
+	return time.Date(y, mo, d, 0, 0, 0, 0, t.Location())
+}
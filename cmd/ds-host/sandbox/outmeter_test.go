@@ -0,0 +1,90 @@
+package sandbox
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+func TestOutMeterRate(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local)
+	m := newOutMeter(OutLimits{ReqPerMin: 2})
+	m.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if l := m.request(); l != "" {
+			t.Fatalf("request %v should be allowed, got %v", i, l)
+		}
+	}
+	if l := m.request(); l != domain.LimitOutRate {
+		t.Errorf("expected rate limit, got %v", l)
+	}
+	now = now.Add(time.Minute)
+	if l := m.request(); l != "" {
+		t.Errorf("expected request allowed in next window, got %v", l)
+	}
+	u := m.get()
+	if u.Requests != 3 || u.RateDenied != 1 {
+		t.Errorf("unexpected usage: %v", u)
+	}
+}
+
+func TestOutMeterCap(t *testing.T) {
+	now := time.Date(2026, 10, 17, 23, 0, 0, 0, time.Local)
+	m := newOutMeter(OutLimits{BytesPerDay: 100, UsedToday: 60})
+	m.now = func() time.Time { return now }
+	m.day = dayStart(now)
+
+	if l := m.request(); l != "" {
+		t.Fatalf("expected request allowed, got %v", l)
+	}
+	m.add(30, 10)
+	if l := m.request(); l != domain.LimitOutDaily {
+		t.Errorf("expected daily cap, got %v", l)
+	}
+	if !m.get().CapHit {
+		t.Error("expected cap hit")
+	}
+
+	now = now.Add(2 * time.Hour)
+	if l := m.request(); l != "" {
+		t.Errorf("expected request allowed on next day, got %v", l)
+	}
+}
+
+func TestMeteredConn(t *testing.T) {
+	m := newOutMeter(OutLimits{BytesPerDay: 8})
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	c := &meteredConn{Conn: a, meter: m}
+
+	go func() {
+		buf := make([]byte, 16)
+		n, _ := b.Read(buf)
+		b.Write(buf[:n])
+	}()
+
+	_, err := c.Write([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	n, err := c.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Errorf("expected 5 bytes, got %v", n)
+	}
+	u := m.get()
+	if u.BytesSent != 5 || u.BytesReceived != 5 {
+		t.Errorf("unexpected usage: %v", u)
+	}
+	_, err = c.Write([]byte("more"))
+	if err != errOutboundCap {
+		t.Errorf("expected cap error, got %v", err)
+	}
+}
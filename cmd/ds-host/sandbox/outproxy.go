@@ -2,9 +2,12 @@ package sandbox
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/elazarl/goproxy"
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
@@ -12,35 +15,106 @@ import (
 )
 
 type OutProxy struct {
-	Log    func(string)
-	server *http.Server
-	port   int
+	Log func(string)
+	// Allowed is the list of host patterns the sandbox can connect to.
+	// If nil all hosts are allowed.
+	Allowed []string
+	// Limits on outbound traffic
+	Limits OutLimits
+	// CA is used to intercept HTTPS requests. If nil TLS connections are tunneled.
+	CA *MITMCA
+	// Capture is called with each completed request if set.
+	// With a CA set this includes HTTPS requests.
+	Capture func(domain.OutboundExchange)
+	meter   *outMeter
+	// upstreamTLS overrides the TLS config used to connect to remote hosts
+	upstreamTLS *tls.Config
+	server      *http.Server
+	port        int
 }
 
-func (o *OutProxy) Start(config domain.RuntimeConfig, mitm bool) error {
+func (o *OutProxy) Start(config domain.RuntimeConfig) error {
+	o.meter = newOutMeter(o.Limits)
 
 	proxy := goproxy.NewProxyHttpServer()
 
 	proxy.OnRequest().DoFunc(
 		func(req *http.Request, ctx *goproxy.ProxyCtx) (*http.Request, *http.Response) {
+			if !o.allowed(requestHostPort(req)) {
+				o.Log(fmt.Sprintf("Denied request: %s %s %s %s", req.Proto, req.Method, req.Host, req.URL))
+				return req, goproxy.NewResponse(req, goproxy.ContentTypeText, http.StatusForbidden, "Outbound host not allowed")
+			}
+			if limit := o.meter.request(); limit != "" {
+				o.Log(fmt.Sprintf("Denied request (%s): %s %s %s %s", limit, req.Proto, req.Method, req.Host, req.URL))
+				return req, goproxy.NewResponse(req, goproxy.ContentTypeText, http.StatusTooManyRequests, "Outbound limit reached")
+			}
 			o.Log(fmt.Sprintf("Request: %s %s %s %s", req.Proto, req.Method, req.Host, req.URL))
+			if o.Capture != nil {
+				ctx.UserData = captureRequest(req)
+			}
 			return req, nil
 		})
+	proxy.OnResponse().DoFunc(
+		func(resp *http.Response, ctx *goproxy.ProxyCtx) *http.Response {
+			e, ok := ctx.UserData.(*domain.OutboundExchange)
+			if !ok {
+				return resp
+			}
+			ctx.UserData = nil
+			if resp == nil {
+				e.Duration = time.Since(e.Timestamp)
+				if ctx.Error != nil {
+					e.Error = ctx.Error.Error()
+				}
+				o.Capture(*e)
+				return resp
+			}
+			e.Status = resp.StatusCode
+			e.ResponseHeader = resp.Header.Clone()
+			body := resp.Body
+			if body == nil {
+				body = http.NoBody
+			}
+			resp.Body = &captureBody{
+				ReadCloser: body,
+				done: func(b []byte, truncated bool) {
+					e.ResponseBody = string(b)
+					e.ResponseTruncated = truncated
+					e.Duration = time.Since(e.Timestamp)
+					o.Capture(*e)
+				}}
+			return resp
+		})
 	proxy.OnRequest().HandleConnectFunc(func(host string, ctx *goproxy.ProxyCtx) (*goproxy.ConnectAction, string) {
+		if !o.allowed(host) {
+			o.Log(fmt.Sprintf("Denied TLS Connect to: %s", host))
+			return goproxy.RejectConnect, host
+		}
+		if o.CA != nil {
+			// requests inside the tunnel are metered individually
+			o.Log(fmt.Sprintf("TLS Connect (MITM) to: %s", host))
+			return o.CA.connectAction(), host
+		}
+		if limit := o.meter.request(); limit != "" {
+			o.Log(fmt.Sprintf("Denied TLS Connect (%s) to: %s", limit, host))
+			return goproxy.RejectConnect, host
+		}
 		o.Log(fmt.Sprintf("TLS Connect to: %s", host))
 		return goproxy.OkConnect, host
 	})
 
-	if mitm { //not used yet
-		proxy.OnRequest().HandleConnect(goproxy.AlwaysMitm)
-	}
-
 	s := runtimeconfig.GetSSRFGuardian(config)
 
 	dialer := &net.Dialer{
 		Control: s.Safe,
 	}
-	proxy.Tr.DialContext = dialer.DialContext
+	proxy.Tr.DialContext = o.meter.dial(dialer.DialContext)
+	// Intercepted requests must only reach hosts with a valid certificate.
+	// Don't rely on goproxy's default, some versions skip verification.
+	proxy.Tr.TLSClientConfig = &tls.Config{}
+	if o.upstreamTLS != nil {
+		proxy.Tr.TLSClientConfig = o.upstreamTLS
+	}
 
 	listener, err := net.Listen("tcp", "localhost:0")
 	if err != nil {
@@ -62,6 +136,65 @@ func (o *OutProxy) Start(config domain.RuntimeConfig, mitm bool) error {
 	return nil
 }
 
+func (o *OutProxy) allowed(hostPort string) bool {
+	if o.Allowed == nil {
+		return true
+	}
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host = hostPort
+		port = ""
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, a := range o.Allowed {
+		if matchOutboundHost(a, host, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOutboundHost returns true if host and port match the pattern.
+// A pattern without a port matches any port.
+// A pattern that starts with "*." matches subdomains only.
+func matchOutboundHost(pattern, host, port string) bool {
+	pattern = strings.ToLower(pattern)
+	if i := strings.LastIndex(pattern, ":"); i != -1 {
+		if pattern[i+1:] != port {
+			return false
+		}
+		pattern = pattern[:i]
+	}
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == pattern
+}
+
+// requestHostPort returns the host and port of a proxied request
+func requestHostPort(req *http.Request) string {
+	host := req.URL.Hostname()
+	if host == "" {
+		host = req.Host
+	}
+	port := req.URL.Port()
+	if port == "" {
+		port = "80"
+		if req.URL.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// Usage returns the outbound traffic so far
+func (o *OutProxy) Usage() outUsage {
+	if o.meter == nil {
+		return outUsage{}
+	}
+	return o.meter.get()
+}
+
 func (o *OutProxy) Port() int {
 	return o.port
 }
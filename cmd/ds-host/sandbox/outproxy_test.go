@@ -0,0 +1,99 @@
+package sandbox
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+func TestMatchOutboundHost(t *testing.T) {
+	cases := []struct {
+		pattern string
+		host    string
+		port    string
+		match   bool
+	}{
+		{"api.example.com", "api.example.com", "443", true},
+		{"API.example.com", "api.example.com", "443", true},
+		{"api.example.com", "www.example.com", "443", false},
+		{"api.example.com:443", "api.example.com", "443", true},
+		{"api.example.com:443", "api.example.com", "80", false},
+		{"*.example.com", "api.example.com", "443", true},
+		{"*.example.com", "a.b.example.com", "443", true},
+		{"*.example.com", "example.com", "443", false},
+		{"*.example.com", "badexample.com", "443", false},
+		{"*.example.com:8080", "api.example.com", "443", false},
+	}
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%s %s:%s", c.pattern, c.host, c.port), func(t *testing.T) {
+			if matchOutboundHost(c.pattern, c.host, c.port) != c.match {
+				t.Errorf("expected match to be %v", c.match)
+			}
+		})
+	}
+}
+
+func TestOutProxyAllowed(t *testing.T) {
+	o := &OutProxy{}
+	if !o.allowed("anything.com:443") {
+		t.Error("nil allowlist should allow all")
+	}
+	o.Allowed = []string{}
+	if o.allowed("anything.com:443") {
+		t.Error("empty allowlist should deny all")
+	}
+	o.Allowed = []string{"anything.com"}
+	if !o.allowed("Anything.com.:443") {
+		t.Error("expected host to be allowed")
+	}
+}
+
+func TestOutProxyDeny(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	config := domain.RuntimeConfig{}
+	config.LocalNetwork.AllowedIPs = []string{"127.0.0.1"}
+
+	cases := []struct {
+		allowed []string
+		status  int
+	}{
+		{nil, http.StatusOK},
+		{[]string{"127.0.0.1"}, http.StatusOK},
+		{[]string{"example.com"}, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%v", c.allowed), func(t *testing.T) {
+			logged := ""
+			o := &OutProxy{
+				Log:     func(s string) { logged = s },
+				Allowed: c.allowed}
+			err := o.Start(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer o.Stop()
+
+			proxyURL, _ := url.Parse(fmt.Sprintf("http://localhost:%d", o.Port()))
+			client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+			resp, err := client.Get(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != c.status {
+				t.Errorf("expected status %v, got %v", c.status, resp.StatusCode)
+			}
+			if logged == "" {
+				t.Error("expected request to be logged")
+			}
+		})
+	}
+}
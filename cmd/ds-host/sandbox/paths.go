@@ -33,6 +33,9 @@ type paths struct {
 	appLoc      string
 	appspaceLoc string // if "" then no appspace.
 	sockets     string // set by sandbox while starting up
+	// appspaceFilesReadOnly prevents writes to appspace files,
+	// for example when the appspace is over its disk quota
+	appspaceFilesReadOnly bool
 
 	Config           *domain.RuntimeConfig
 	AppLocation2Path interface {
@@ -132,7 +135,8 @@ func (p *paths) init() {
 			host:      trailingSlash(p.AppspaceLocation2Path.Files(p.appspaceLoc)),
 			bwrap:     "/appspace-data/files/",
 			importMap: true,
-			denoWrite: true,
+			denoRead:  p.appspaceFilesReadOnly,
+			denoWrite: !p.appspaceFilesReadOnly,
 		})
 		p.pDatas = append(p.pDatas, pathData{
 			host:     trailingSlash(p.AppspaceLocation2Path.Avatars(p.appspaceLoc)),
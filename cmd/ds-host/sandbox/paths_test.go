@@ -109,6 +109,31 @@ func TestAppspaceDenoAllow(t *testing.T) {
 	assertEqStr(t, strings.Join(expected, ","), p.denoAllowWrite())
 }
 
+func TestAppspaceFilesReadOnly(t *testing.T) {
+	c := &domain.RuntimeConfig{}
+	c.Exec.AppsPath = "/app-f1les-base"
+	c.Exec.AppspacesPath = "/appspace-f1les-base"
+	c.Sandbox.UseBubblewrap = true
+	p := &paths{
+		appLoc:                "av-loc-77",
+		appspaceLoc:           "as-loc-13",
+		sockets:               "/s0ckets/",
+		appspaceFilesReadOnly: true,
+		Config:                c,
+		AppLocation2Path:      &runtimeconfig.AppLocation2Path{Config: c},
+		AppspaceLocation2Path: &runtimeconfig.AppspaceLocation2Path{Config: c},
+	}
+	p.init()
+
+	assertEqStr(t, "/sockets/", p.denoAllowWrite())
+	if !strings.Contains(p.denoAllowRead(), "/appspace-data/files/") {
+		t.Error("expected appspace files to be readable")
+	}
+	if !strings.Contains(strings.Join(p.getBwrapPathMaps(), " "), "--ro-bind /appspace-f1les-base/as-loc-13/data/files/ /appspace-data/files/") {
+		t.Error("expected appspace files to be bound read-only")
+	}
+}
+
 func TestAppBwrapMaps(t *testing.T) {
 	c := &domain.RuntimeConfig{}
 	c.Sandbox.UseBubblewrap = true
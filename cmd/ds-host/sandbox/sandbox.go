@@ -139,6 +139,7 @@ type Sandbox struct {
 	SandboxRuns interface {
 		Create(run domain.SandboxRunIDs, start time.Time) (int, error)
 		End(int, time.Time, domain.SandboxRunData) error
+		AppspaceSums(ownerID domain.UserID, appspaceID domain.AppspaceID, from time.Time, to time.Time) (domain.SandboxRunData, error)
 	}
 	CGroups interface {
 		CreateCGroup(domain.CGroupLimits) (string, error)
@@ -147,15 +148,27 @@ type Sandbox struct {
 		GetMetrics(string) (domain.CGroupData, error)
 		RemoveCGroup(string) error
 	}
+	AppspaceQuota interface {
+		GetEffective(appspaceID domain.AppspaceID) (domain.AppspaceQuota, error)
+		RecordHit(appspaceID domain.AppspaceID, limit string, count int) error
+	}
+	OutboundPolicy interface {
+		GetAllowed(appVersion domain.AppVersion, appspace *domain.Appspace) ([]string, error)
+	}
+	DiskUsage interface {
+		Get(p string) (int64, bool)
+	}
 	Logger   interface{ Log(string, string) }
 	cmd      *exec.Cmd
 	twine    *twine.Twine
 	Services domain.ReverseServiceI
 	outProxy interface {
 		Port() int
+		Usage() outUsage
 		Stop()
 	}
-	outProxyMITM     bool // not used yet
+	mitmCA           *MITMCA
+	mitmCapture      func(domain.OutboundExchange)
 	statusMux        sync.Mutex
 	status           domain.SandboxStatus
 	statusSub        []chan domain.SandboxStatus
@@ -179,6 +192,7 @@ type Sandbox struct {
 
 	paths  *paths
 	cGroup string
+	quota  domain.AppspaceQuota
 }
 
 func NewSandbox(id int, operation string, ownerID domain.UserID, appVersion *domain.AppVersion, appspace *domain.Appspace) *Sandbox {
@@ -217,6 +231,14 @@ func (s *Sandbox) SetInspect(inspect bool) {
 	s.inspect = inspect
 }
 
+// SetMITM makes the sandbox trust ca and intercept outbound HTTPS requests.
+// The CA cert must be written to the runtime files goproxy cert path.
+// capture is called with each outbound exchange.
+func (s *Sandbox) SetMITM(ca *MITMCA, capture func(domain.OutboundExchange)) {
+	s.mitmCA = ca
+	s.mitmCapture = capture
+}
+
 // SetImportMap sets items to include in the generated import map
 func (s *Sandbox) SetImportMapExtras(extras map[string]string) {
 	s.paths.importMapExtras = extras
@@ -262,6 +284,11 @@ func (s *Sandbox) doStart() error {
 		MemoryHigh: memHighMb * 1024 * 1024,
 	}
 
+	err := s.applyQuota(&limits)
+	if err != nil {
+		return err
+	}
+
 	if s.Config.Sandbox.UseCGroups {
 		cGroup, err := s.CGroups.CreateCGroup(limits)
 		if err != nil {
@@ -337,7 +364,7 @@ func (s *Sandbox) doStart() error {
 	denoEnvs = append(denoEnvs,
 		envkv{"HTTP_PROXY", fmt.Sprintf("localhost:%d", s.outProxy.Port())},
 		envkv{"HTTPS_PROXY", fmt.Sprintf("localhost:%d", s.outProxy.Port())})
-	if s.outProxyMITM {
+	if s.mitmCA != nil {
 		denoArgs = append(denoArgs, "--cert="+s.paths.sandboxPath("goproxy-cert"))
 	}
 
@@ -494,8 +521,29 @@ func (s *Sandbox) startOutProxy() error {
 			}
 		},
 	}
+	if s.OutboundPolicy != nil && !reflect.ValueOf(s.OutboundPolicy).IsNil() {
+		allowed, err := s.OutboundPolicy.GetAllowed(*s.appVersion, s.appspace)
+		if err != nil {
+			return err
+		}
+		outProxy.Allowed = allowed
+	}
+	limits, err := s.getOutLimits()
+	if err != nil {
+		return err
+	}
+	outProxy.Limits = limits
+	outProxy.CA = s.mitmCA
+	if s.mitmCapture != nil {
+		outProxy.Capture = func(e domain.OutboundExchange) {
+			if s.appspace != nil {
+				e.AppspaceID = s.appspace.AppspaceID
+			}
+			s.mitmCapture(e)
+		}
+	}
 	s.outProxy = outProxy
-	return outProxy.Start(*s.Config, s.outProxyMITM)
+	return outProxy.Start(*s.Config)
 }
 
 // monitor waits for cmd to end or an error gets sent
@@ -689,15 +737,24 @@ func (s *Sandbox) cleanup(runDBIDCh chan runDBIDData) {
 	}
 
 	cGroupData := s.collectRunData()
+	s.recordLimitHits(cGroupData)
+	var outData outUsage
+	if s.outProxy != nil {
+		outData = s.outProxy.Usage()
+	}
+	s.recordOutLimitHits(outData)
 	memByteSec := calcMemByteSec(cGroupData.MemoryBytes, tiedUpDuration)
 	dbIDData := <-runDBIDCh
 	if dbIDData.ok {
 		s.SandboxRuns.End(dbIDData.id, time.Now(), domain.SandboxRunData{
-			TiedUpMs:      int(tiedUpDuration.Milliseconds()),
-			CpuUsec:       cGroupData.CpuUsec,
-			MemoryByteSec: memByteSec,
-			IOBytes:       cGroupData.IOBytes,
-			IOs:           cGroupData.IOs})
+			TiedUpMs:         int(tiedUpDuration.Milliseconds()),
+			CpuUsec:          cGroupData.CpuUsec,
+			MemoryByteSec:    memByteSec,
+			IOBytes:          cGroupData.IOBytes,
+			IOs:              cGroupData.IOs,
+			OutRequests:      outData.Requests,
+			OutBytesSent:     outData.BytesSent,
+			OutBytesReceived: outData.BytesReceived})
 	}
 
 	if s.Config.Sandbox.UseCGroups {
@@ -809,6 +866,116 @@ func (s *Sandbox) collectRunData() domain.CGroupData {
 	return metrics
 }
 
+// applyQuota adds the appspace's quota to the cgroup limits.
+// If the appspace data is over the disk quota, appspace files are made read-only.
+// The disk quota is a soft limit: it is checked against the last measured size
+// when the sandbox starts, and not while it runs. Only the appspace files
+// are made read-only, the appspace databases remain writable.
+func (s *Sandbox) applyQuota(limits *domain.CGroupLimits) error {
+	if s.appspace == nil || s.AppspaceQuota == nil {
+		return nil
+	}
+	quota, err := s.AppspaceQuota.GetEffective(s.appspace.AppspaceID)
+	if err != nil {
+		return err
+	}
+	s.quota = quota
+
+	limits.MemoryMax = quota.MemoryMaxMb * 1024 * 1024
+	if limits.MemoryMax != 0 && limits.MemoryHigh > limits.MemoryMax {
+		limits.MemoryHigh = limits.MemoryMax
+	}
+	limits.CPUMaxPercent = quota.CPUMaxPercent
+	dataPath := s.AppspaceLocation2Path.Data(s.appspace.LocationKey)
+	limits.IOPath = dataPath
+	limits.IOReadBps = quota.IOReadBps
+	limits.IOWriteBps = quota.IOWriteBps
+	limits.IOReadIOPS = quota.IOReadIOPS
+	limits.IOWriteIOPS = quota.IOWriteIOPS
+
+	if quota.DiskMaxMb != 0 && s.DiskUsage != nil {
+		size, ok := s.DiskUsage.Get(dataPath)
+		if ok && size > int64(quota.DiskMaxMb)*1024*1024 {
+			s.paths.appspaceFilesReadOnly = true
+			s.log(fmt.Sprintf("Appspace data uses %v MB, over its disk quota of %v MB. Appspace files are read-only.", size/1024/1024, quota.DiskMaxMb))
+			s.recordLimitHit(domain.LimitDiskMax, 1)
+		}
+	}
+	return nil
+}
+
+// getOutLimits returns the limits on outbound traffic from the appspace quota.
+// For the daily cap it includes the traffic of earlier runs today.
+func (s *Sandbox) getOutLimits() (OutLimits, error) {
+	limits := OutLimits{
+		ReqPerMin:   s.quota.OutReqPerMin,
+		BytesPerDay: int64(s.quota.OutMbPerDay) * 1024 * 1024}
+	if s.appspace == nil || limits.BytesPerDay == 0 {
+		return limits, nil
+	}
+	now := time.Now()
+	sums, err := s.SandboxRuns.AppspaceSums(s.ownerID, s.appspace.AppspaceID, dayStart(now), now)
+	if err != nil {
+		return limits, err
+	}
+	limits.UsedToday = int64(sums.OutBytesSent + sums.OutBytesReceived)
+	return limits, nil
+}
+
+// memPressureHitUsec is the time stalled on memory during a run
+// above which the run counts as having hit the memory limits
+const memPressureHitUsec = 1000 * 1000
+
+// recordLimitHits records the limits the sandbox ran into
+// according to the cgroup data collected at the end of the run.
+func (s *Sandbox) recordLimitHits(data domain.CGroupData) {
+	if s.appspace == nil || s.AppspaceQuota == nil {
+		return
+	}
+	hits := []struct {
+		limit string
+		count int
+	}{
+		{domain.LimitMemoryHigh, data.MemoryHighEvents},
+		{domain.LimitMemoryMax, data.MemoryMaxEvents},
+		{domain.LimitOOMKill, data.OOMKills},
+		{domain.LimitCPUMax, data.CPUThrottled},
+	}
+	if data.MemoryHighEvents == 0 && data.MemoryPressureUsec > memPressureHitUsec {
+		hits[0].count = 1
+	}
+	for _, h := range hits {
+		if h.count != 0 {
+			s.recordLimitHit(h.limit, h.count)
+		}
+	}
+	if data.OOMKills != 0 {
+		s.log("Sandbox ran out of memory and was killed")
+	}
+}
+
+// recordOutLimitHits records the outbound limits the sandbox ran into
+func (s *Sandbox) recordOutLimitHits(data outUsage) {
+	if s.appspace == nil || s.AppspaceQuota == nil {
+		return
+	}
+	if data.RateDenied != 0 {
+		s.recordLimitHit(domain.LimitOutRate, data.RateDenied)
+		s.log(fmt.Sprintf("%v outbound requests were denied by the rate limit", data.RateDenied))
+	}
+	if data.CapHit {
+		s.recordLimitHit(domain.LimitOutDaily, 1)
+		s.log("Daily outbound traffic cap was reached")
+	}
+}
+
+func (s *Sandbox) recordLimitHit(limit string, count int) {
+	err := s.AppspaceQuota.RecordHit(s.appspace.AppspaceID, limit, count)
+	if err != nil {
+		s.getLogger("recordLimitHit()").Error(err)
+	}
+}
+
 func calcMemByteSec(memBytes int, tiedUp time.Duration) int {
 	return memBytes * int(tiedUp.Milliseconds()) / 1000
 }
@@ -677,3 +677,74 @@ type testLogger2 struct {
 func (l *testLogger2) Log(source string, message string) {
 	l.log(source, message)
 }
+
+func TestApplyQuota(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	dir := t.TempDir()
+	cfg := &domain.RuntimeConfig{}
+	cfg.Exec.AppspacesPath = dir
+	asl2p := &runtimeconfig.AppspaceLocation2Path{Config: cfg}
+
+	appspaceID := domain.AppspaceID(11)
+	dataDir := asl2p.Data("as-loc")
+	err := os.MkdirAll(dataDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dataDir, "big"), make([]byte, 2*1024*1024), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quotaModel := testmocks.NewMockAppspaceQuotaModel(mockCtrl)
+	quotaModel.EXPECT().GetEffective(appspaceID).Return(domain.AppspaceQuota{MemoryMaxMb: 64, CPUMaxPercent: 20, IOWriteBps: 1000, DiskMaxMb: 1}, nil)
+	quotaModel.EXPECT().RecordHit(appspaceID, domain.LimitDiskMax, 1).Return(nil)
+
+	du := &diskUsage{}
+	du.measure(dataDir)
+
+	s := &Sandbox{
+		id:                    7,
+		appspace:              &domain.Appspace{AppspaceID: appspaceID, LocationKey: "as-loc"},
+		paths:                 &paths{},
+		AppspaceQuota:         quotaModel,
+		DiskUsage:             du,
+		AppspaceLocation2Path: asl2p,
+	}
+	limits := domain.CGroupLimits{MemoryHigh: 400 * 1024 * 1024}
+	err = s.applyQuota(&limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := domain.CGroupLimits{
+		MemoryHigh:    64 * 1024 * 1024,
+		MemoryMax:     64 * 1024 * 1024,
+		CPUMaxPercent: 20,
+		IOPath:        dataDir,
+		IOWriteBps:    1000,
+	}
+	if limits != expected {
+		t.Errorf("unexpected limits: %v", limits)
+	}
+	if !s.paths.appspaceFilesReadOnly {
+		t.Error("expected appspace files to be read-only")
+	}
+}
+
+func TestRecordLimitHits(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	appspaceID := domain.AppspaceID(11)
+	quotaModel := testmocks.NewMockAppspaceQuotaModel(mockCtrl)
+	quotaModel.EXPECT().RecordHit(appspaceID, domain.LimitMemoryHigh, 1).Return(nil)
+	quotaModel.EXPECT().RecordHit(appspaceID, domain.LimitCPUMax, 4).Return(nil)
+
+	s := &Sandbox{
+		appspace:      &domain.Appspace{AppspaceID: appspaceID},
+		AppspaceQuota: quotaModel,
+	}
+	s.recordLimitHits(domain.CGroupData{CPUThrottled: 4, MemoryPressureUsec: 3 * memPressureHitUsec})
+}
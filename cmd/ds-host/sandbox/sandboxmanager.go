@@ -21,6 +21,7 @@ type Manager struct {
 	SandboxRuns interface {
 		Create(run domain.SandboxRunIDs, start time.Time) (int, error)
 		End(sandboxID int, end time.Time, data domain.SandboxRunData) error
+		AppspaceSums(ownerID domain.UserID, appspaceID domain.AppspaceID, from time.Time, to time.Time) (domain.SandboxRunData, error)
 	} `checkinject:"required"`
 	CGroups interface {
 		CreateCGroup(domain.CGroupLimits) (string, error)
@@ -29,9 +30,20 @@ type Manager struct {
 		GetMetrics(string) (domain.CGroupData, error)
 		RemoveCGroup(string) error
 	} `checkinject:"optional"`
+	AppspaceQuota interface {
+		GetEffective(appspaceID domain.AppspaceID) (domain.AppspaceQuota, error)
+		RecordHit(appspaceID domain.AppspaceID, limit string, count int) error
+	} `checkinject:"optional"`
+	OutboundPolicy interface {
+		GetAllowed(appVersion domain.AppVersion, appspace *domain.Appspace) ([]string, error)
+	} `checkinject:"optional"`
 	AppLogger interface {
 		Get(string) domain.LoggerI
 	} `checkinject:"required"`
+	Metrics interface {
+		SandboxStart(operation string, ok bool, dur time.Duration)
+		SandboxStatuses(counts map[string]int)
+	} `checkinject:"optional"`
 	AppspaceLogger interface {
 		Open(domain.AppspaceID) domain.LoggerI
 	} `checkinject:"required"`
@@ -57,6 +69,8 @@ type Manager struct {
 	sandboxesMux sync.Mutex
 	sandboxes    []domain.SandboxI
 
+	diskUsage diskUsage
+
 	ticker *time.Ticker
 }
 
@@ -222,6 +236,9 @@ func (m *Manager) startSandbox(s *Sandbox) {
 	// expects poolmux to be locked
 
 	s.CGroups = m.CGroups
+	s.AppspaceQuota = m.AppspaceQuota
+	s.OutboundPolicy = m.OutboundPolicy
+	s.DiskUsage = &m.diskUsage
 	s.Config = m.Config
 	s.SandboxRuns = m.SandboxRuns
 
@@ -229,6 +246,14 @@ func (m *Manager) startSandbox(s *Sandbox) {
 
 	go m.startStopSandboxes()
 
+	if m.Metrics != nil {
+		start := time.Now()
+		go func() {
+			s.WaitFor(domain.SandboxReady)
+			m.Metrics.SandboxStart(s.Operation(), s.Status() == domain.SandboxReady, time.Since(start))
+		}()
+	}
+
 	go func() {
 		s.WaitFor(domain.SandboxDead)
 		m.removeSandbox(s)
@@ -261,30 +286,38 @@ func (m *Manager) startStopSandboxes() {
 	m.sandboxesMux.Lock()
 	defer m.sandboxesMux.Unlock()
 	m.startStopSandboxesInner(m.Config, m.sandboxes)
+	m.recordSandboxStatusMetric()
 }
 
+// recordSandboxStatusMetric counts sandboxes by status.
+// Expects sandboxesMux to be locked.
 func (m *Manager) recordSandboxStatusMetric() {
-	// var s = &record.SandboxStatuses{ //TODO nope do not use imported record. inject instead
-	// 	Starting:   0,
-	// 	Ready:      0,
-	// 	Committing: 0,
-	// 	Committed:  0,
-	// 	Recycling:  0}
-	// for _, c := range sM.sandboxes {
-	// 	switch c.Status {
-	// 	case "starting":
-	// 		s.Starting++
-	// 	case "ready":
-	// 		s.Ready++
-	// 	case "committing":
-	// 		s.Committing++
-	// 	case "committed":
-	// 		s.Committed++
-	// 	case "recycling":
-	// 		s.Recycling++
-	// 	}
-	// }
-	// record.SandboxStatusCounts(s) //TODO nope
+	if m.Metrics == nil {
+		return
+	}
+	counts := make(map[string]int)
+	for _, s := range m.sandboxes {
+		counts[statusLabel(s.Status())]++
+	}
+	m.Metrics.SandboxStatuses(counts)
+}
+
+func statusLabel(status domain.SandboxStatus) string {
+	switch status {
+	case domain.SandboxPrepared:
+		return "prepared"
+	case domain.SandboxStarting:
+		return "starting"
+	case domain.SandboxReady:
+		return "ready"
+	case domain.SandboxKilling:
+		return "killing"
+	case domain.SandboxDead:
+		return "dead"
+	case domain.SandboxCleanedUp:
+		return "cleaned-up"
+	}
+	return "unknown"
 }
 
 // PrintSandboxes outputs containersa and status
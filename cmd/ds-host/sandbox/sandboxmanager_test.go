@@ -62,6 +62,38 @@ func makeFindableSandbox(mockCtrl *gomock.Controller, appspaceID domain.NullApps
 	return s
 }
 
+func TestRecordSandboxStatusMetric(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	a1 := domain.NewNullAppspaceID(domain.AppspaceID(12))
+	v1 := &domain.AppVersion{AppID: domain.AppID(45), Version: domain.Version("0.1.0")}
+
+	metrics := &testMetrics{}
+	m := Manager{
+		Metrics: metrics,
+		sandboxes: []domain.SandboxI{
+			makeFindableSandbox(mockCtrl, a1, v1, domain.SandboxReady, opAppspaceRun),
+			makeFindableSandbox(mockCtrl, a1, v1, domain.SandboxReady, opAppspaceRun),
+			makeFindableSandbox(mockCtrl, a1, v1, domain.SandboxStarting, opAppspaceMigration),
+		},
+	}
+	m.recordSandboxStatusMetric()
+
+	if len(metrics.counts) != 2 || metrics.counts["ready"] != 2 || metrics.counts["starting"] != 1 {
+		t.Errorf("unexpected counts: %v", metrics.counts)
+	}
+}
+
+type testMetrics struct {
+	counts map[string]int
+}
+
+func (m *testMetrics) SandboxStart(operation string, ok bool, dur time.Duration) {}
+func (m *testMetrics) SandboxStatuses(counts map[string]int) {
+	m.counts = counts
+}
+
 func TestGetStartStoppablesEmpty(t *testing.T) {
 	sandboxes := make([]domain.SandboxI, 0)
 
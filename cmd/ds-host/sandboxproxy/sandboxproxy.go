@@ -1,7 +1,10 @@
 package sandboxproxy
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"strings"
@@ -31,6 +34,7 @@ func (s *SandboxProxy) ServeHTTP(oRes http.ResponseWriter, oReq *http.Request) {
 
 	sb.WaitFor(domain.SandboxReady)
 	if sb.Status() != domain.SandboxReady {
+		s.getLogger("ServeHTTP()").AppspaceID(appspace.AppspaceID).Ctx(ctx).Log("sandbox failed to start")
 		oRes.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -42,15 +46,20 @@ func (s *SandboxProxy) ServeHTTP(oRes http.ResponseWriter, oReq *http.Request) {
 	header := oReq.Header.Clone()
 	header.Set("X-Dropserver-Request-URL", getURLString(*oReq.URL))
 	header.Set("X-Dropserver-Route-ID", routeConfig.ID)
+	if requestID, ok := domain.CtxRequestID(ctx); ok {
+		header.Set("X-Dropserver-Request-ID", requestID)
+	}
 
 	proxyID, ok := domain.CtxAppspaceUserProxyID(ctx)
 	if ok {
 		header.Set("X-Dropserver-User-ProxyID", string(proxyID))
 	}
 
-	cReq, err := http.NewRequest(oReq.Method, "http://unix/", oReq.Body)
+	// Use the incoming request's context so that streams to the sandbox
+	// are cancelled when the client goes away.
+	cReq, err := http.NewRequestWithContext(ctx, oReq.Method, "http://unix/", oReq.Body)
 	if err != nil {
-		s.getLogger("ServeHTTP(), http.NewRequest()").Error(err)
+		s.getLogger("ServeHTTP(), http.NewRequest()").AppspaceID(appspace.AppspaceID).Ctx(ctx).Error(err)
 		// Maybe add app id and appspace id?
 		oRes.WriteHeader(http.StatusInternalServerError)
 		return
@@ -62,14 +71,95 @@ func (s *SandboxProxy) ServeHTTP(oRes http.ResponseWriter, oReq *http.Request) {
 
 	cRes, err := sbTransport.RoundTrip(cReq)
 	if err != nil {
-		s.getLogger("ServeHTTP(), sbTransport.RoundTrip()").Error(err)
+		s.getLogger("ServeHTTP(), sbTransport.RoundTrip()").AppspaceID(appspace.AppspaceID).Ctx(ctx).Error(err)
+		oRes.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if cRes.StatusCode == http.StatusSwitchingProtocols {
+		// The task stays open for as long as the connection,
+		// so the sandbox is not considered idle while it is in use.
+		s.handleUpgrade(oRes, oReq, cRes)
+		return
+	}
+	defer cRes.Body.Close()
+
+	copyResponseHeader(oRes.Header(), cRes.Header)
+	oRes.WriteHeader(cRes.StatusCode)
+
+	if isStreamed(cRes) {
+		err = copyFlush(oRes, cRes.Body)
+	} else {
+		_, err = io.Copy(oRes, cRes.Body)
+	}
+	if err != nil && ctx.Err() == nil {
+		s.getLogger("ServeHTTP(), copy body").AppspaceID(appspace.AppspaceID).Ctx(ctx).Error(err)
+	}
+}
+
+// handleUpgrade takes over the client connection and pipes it
+// to the sandbox connection until either side closes.
+func (s *SandboxProxy) handleUpgrade(oRes http.ResponseWriter, oReq *http.Request, cRes *http.Response) {
+	ctx := oReq.Context()
+	appspace, _ := domain.CtxAppspaceData(ctx)
+	log := s.getLogger("handleUpgrade()").AppspaceID(appspace.AppspaceID).Ctx(ctx).Clone
+
+	reqUpType := upgradeType(oReq.Header)
+	resUpType := upgradeType(cRes.Header)
+	if reqUpType == "" || !strings.EqualFold(reqUpType, resUpType) {
+		cRes.Body.Close()
+		log().Log(fmt.Sprintf("sandbox switched to protocol %q when %q was requested", resUpType, reqUpType))
+		oRes.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	backConn, ok := cRes.Body.(io.ReadWriteCloser)
+	if !ok {
+		cRes.Body.Close()
+		log().Log("sandbox response body is not writable")
 		oRes.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	defer backConn.Close()
 
+	conn, brw, err := http.NewResponseController(oRes).Hijack()
+	if err != nil {
+		log().AddNote("Hijack()").Error(err)
+		oRes.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+
+	copyResponseHeader(oRes.Header(), cRes.Header)
+	cRes.Header = oRes.Header()
+	cRes.Body = nil // only write the status and headers
+	err = cRes.Write(brw)
+	if err == nil {
+		err = brw.Flush()
+	}
+	if err != nil {
+		log().AddNote("write response").Error(err)
+		return
+	}
+
+	errCh := make(chan error, 2)
+	go func() {
+		// read through brw in case the client sent data along with the upgrade request
+		_, err := io.Copy(backConn, brw)
+		errCh <- err
+	}()
+	go func() {
+		_, err := io.Copy(conn, backConn)
+		errCh <- err
+	}()
+	<-errCh
+}
+
+// copyResponseHeader copies sandbox response headers
+// except those the sandbox is not allowed to set.
+func copyResponseHeader(dst http.Header, src http.Header) {
 	cspKey := http.CanonicalHeaderKey("Content-Security-Policy")
-	resHeader := oRes.Header()
-	for k, vv := range cRes.Header {
+	for k, vv := range src {
 		k = http.CanonicalHeaderKey(k)
 		// filter out CSP, CORS, etc...
 		// Furthermore we could compare against a list of known and acceptable headers,
@@ -78,14 +168,64 @@ func (s *SandboxProxy) ServeHTTP(oRes http.ResponseWriter, oReq *http.Request) {
 			continue
 		}
 		for _, v := range vv {
-			resHeader.Add(k, v)
+			dst.Add(k, v)
 		}
 	}
+}
 
-	oRes.WriteHeader(cRes.StatusCode)
+// isStreamed returns true if the response should be passed on
+// as it is produced rather than buffered.
+func isStreamed(res *http.Response) bool {
+	if res.ContentLength == -1 {
+		return true
+	}
+	ct, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	return ct == "text/event-stream"
+}
 
-	io.Copy(oRes, cRes.Body)
-	cRes.Body.Close()
+// copyFlush copies the body, flushing after each write
+func copyFlush(w http.ResponseWriter, body io.Reader) error {
+	rc := http.NewResponseController(w)
+	flush := func() error {
+		err := rc.Flush()
+		if errors.Is(err, http.ErrNotSupported) {
+			return nil
+		}
+		return err
+	}
+	// send headers right away, which matters for event streams
+	if err := flush(); err != nil {
+		return err
+	}
+	buf := make([]byte, 32*1024)
+	for {
+		n, rErr := body.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if rErr == io.EOF {
+			return nil
+		}
+		if rErr != nil {
+			return rErr
+		}
+	}
+}
+
+func upgradeType(h http.Header) string {
+	for _, v := range h.Values("Connection") {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), "upgrade") {
+				return h.Get("Upgrade")
+			}
+		}
+	}
+	return ""
 }
 
 func (s *SandboxProxy) getLogger(note string) *record.DsLogger {
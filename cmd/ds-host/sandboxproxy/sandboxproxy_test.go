@@ -1,8 +1,10 @@
 package sandboxproxy
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -116,6 +118,10 @@ func TestServeHTTP200(t *testing.T) {
 		if routeID != "test-route-id" {
 			t.Error("wrong route id: " + routeID)
 		}
+		requestID := r.Header.Get("X-Dropserver-Request-ID")
+		if requestID != "abc123" {
+			t.Error("wrong request id: " + requestID)
+		}
 		w.WriteHeader(200)            // parametrize
 		fmt.Fprintf(w, "Hello World") // return w? Or parametrize the handler.
 	})
@@ -128,6 +134,7 @@ func TestServeHTTP200(t *testing.T) {
 		t.Fatal(err)
 	}
 	req = req.WithContext(domain.CtxWithRouteConfig(req.Context(), tm.routeConfig))
+	req = req.WithContext(domain.CtxWithRequestID(req.Context(), "abc123"))
 
 	// We create a ResponseRecorder (which satisfies http.ResponseWriter) to record the response.
 	rr := httptest.NewRecorder()
@@ -219,6 +226,125 @@ func TestResponseCSPHeaders(t *testing.T) {
 	}
 }
 
+func TestStreamedResponse(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	release := make(chan struct{})
+	tm := createMocks(mockCtrl, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(200)
+		fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-release
+		fmt.Fprint(w, "data: second\n\n")
+	})
+	defer closeMocks(tm)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tm.sandboxProxy.ServeHTTP(w, r.WithContext(domain.CtxWithRouteConfig(r.Context(), tm.routeConfig)))
+	}))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	// first event arrives while the sandbox is still writing
+	r := bufio.NewReader(res.Body)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "data: first\n" {
+		t.Errorf("unexpected first line: %q", line)
+	}
+	close(release)
+
+	rest, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rest) != "\ndata: second\n\n" {
+		t.Errorf("unexpected rest of body: %q", rest)
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	tm := createMocks(mockCtrl, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "test-proto" {
+			t.Error("expected upgrade header")
+		}
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test-proto\r\n\r\n")
+		brw.Flush()
+		// echo one line
+		line, err := brw.ReadString('\n')
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		brw.WriteString("echo " + line)
+		brw.Flush()
+	})
+	defer closeMocks(tm)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tm.sandboxProxy.ServeHTTP(w, r.WithContext(domain.CtxWithRouteConfig(r.Context(), tm.routeConfig)))
+	}))
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: as1.ds.dev\r\nConnection: Upgrade\r\nUpgrade: test-proto\r\n\r\n")
+
+	r := bufio.NewReader(conn)
+	res, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected switching protocols, got %v", res.Status)
+	}
+
+	fmt.Fprint(conn, "hello\n")
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "echo hello\n" {
+		t.Errorf("unexpected reply: %q", line)
+	}
+}
+
+func TestUpgradeType(t *testing.T) {
+	h := http.Header{}
+	if upgradeType(h) != "" {
+		t.Error("expected no upgrade")
+	}
+	h.Set("Upgrade", "websocket")
+	if upgradeType(h) != "" {
+		t.Error("expected no upgrade without connection header")
+	}
+	h.Set("Connection", "keep-alive, Upgrade")
+	if upgradeType(h) != "websocket" {
+		t.Error("expected websocket upgrade")
+	}
+}
+
 func createMocks(mockCtrl *gomock.Controller, sbHandler func(http.ResponseWriter, *http.Request)) *testMocks {
 	tempDir, err := ioutil.TempDir("", "")
 	if err != nil {
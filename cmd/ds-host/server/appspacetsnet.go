@@ -226,6 +226,17 @@ func (a *AppspaceTSNet) GetStatus(appspaceID domain.AppspaceID) domain.TSNetApps
 	}
 }
 
+// NodeStates counts appspace nodes by tailscale backend state
+func (a *AppspaceTSNet) NodeStates() map[string]int {
+	a.serversMux.Lock()
+	defer a.serversMux.Unlock()
+	ret := make(map[string]int)
+	for _, node := range a.servers {
+		ret[node.getState()]++
+	}
+	return ret
+}
+
 func (a *AppspaceTSNet) GetPeerUsers(appspaceID domain.AppspaceID) []domain.TSNetPeerUser {
 	node := a.get(appspaceID)
 	if node != nil {
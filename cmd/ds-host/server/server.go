@@ -2,7 +2,9 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/tls"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"time"
@@ -117,6 +119,8 @@ func (s *Server) Shutdown() {
 }
 
 func (s *Server) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	req = withRequestID(res, req)
+
 	host, err := getcleanhost.GetCleanHost(req.Host)
 	if err != nil {
 		http.Error(res, err.Error(), http.StatusBadRequest)
@@ -136,6 +140,21 @@ func (s *Server) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// withRequestID gives the request an ID that is carried in the context
+// so that everything logged while handling it can be traced.
+// The ID is also returned to the client in a response header.
+func withRequestID(res http.ResponseWriter, req *http.Request) *http.Request {
+	requestID := newRequestID()
+	res.Header().Set("X-Request-ID", requestID)
+	return req.WithContext(domain.CtxWithRequestID(req.Context(), requestID))
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 func (s *Server) getLogger(note string) *record.DsLogger {
 	r := record.NewDsLogger().AddNote("Server")
 	if note != "" {
@@ -1,5 +1,12 @@
 package server
 
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
 // TODO Frontend is no longer in server. Move this tedt to user routes.
 // func TestFrontend(t *testing.T) {
 // 	rtc := domain.RuntimeConfig{}
@@ -63,3 +70,22 @@ package server
 // 	// 	t.Fatal("expected index html")
 // 	// }
 // }
+
+func TestWithRequestID(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req = withRequestID(rr, req)
+
+	requestID, ok := domain.CtxRequestID(req.Context())
+	if !ok || len(requestID) != 16 {
+		t.Errorf("unexpected request id: %v", requestID)
+	}
+	if rr.Header().Get("X-Request-ID") != requestID {
+		t.Error("expected request id in response header")
+	}
+
+	req2 := withRequestID(rr, httptest.NewRequest("GET", "/", nil))
+	if id2, _ := domain.CtxRequestID(req2.Context()); id2 == requestID {
+		t.Error("expected a different request id")
+	}
+}
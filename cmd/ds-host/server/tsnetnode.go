@@ -288,7 +288,8 @@ func (n *TSNetNode) listeningTLS() bool {
 
 func (n *TSNetNode) handler(ln net.Listener, isTLS bool) {
 	err := http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log := n.getLogger("http.Serve").Clone
+		r = withRequestID(w, r)
+		log := n.getLogger("http.Serve").Ctx(r.Context()).Clone
 
 		status := n.getStatus()
 		if !status.Usable {
@@ -476,6 +477,15 @@ func (n *TSNetNode) getStatus() domain.TSNetStatus {
 	return stat
 }
 
+// getState returns the tailscale backend state of the node
+func (n *TSNetNode) getState() string {
+	state := n.nodeStatus.state
+	if state == "" {
+		return "NoState"
+	}
+	return state
+}
+
 func (n *TSNetNode) buildURL() string {
 	proto := "http"
 	if n.listeningTLS() {
@@ -104,6 +104,16 @@ func (u *UserTSNet) GetStatus() domain.TSNetStatus {
 	}
 }
 
+// NodeStates returns the state of the user node, if it exists
+func (u *UserTSNet) NodeStates() map[string]int {
+	u.serverMux.Lock()
+	defer u.serverMux.Unlock()
+	if u.server == nil {
+		return map[string]int{}
+	}
+	return map[string]int{u.server.getState(): 1}
+}
+
 func (u *UserTSNet) GetPeerUsers() []domain.TSNetPeerUser {
 	u.serverMux.Lock()
 	defer u.serverMux.Unlock()
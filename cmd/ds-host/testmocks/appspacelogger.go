@@ -11,6 +11,7 @@ type LoggerI interface {
 	GetLastBytes(n int64) (domain.LogChunk, error)
 	SubscribeEntries(n int64) (domain.LogChunk, <-chan string, error)
 	UnsubscribeEntries(ch <-chan string)
+	Query(q domain.LogQuery) (domain.LogQueryResult, error)
 }
 
 type AppLogger interface {
@@ -27,4 +28,7 @@ type AppspaceLogger interface {
 	Open(appspaceID domain.AppspaceID) domain.LoggerI
 	Close(appspaceID domain.AppspaceID)
 	Forget(appspaceID domain.AppspaceID)
+	SetRetention(appspaceID domain.AppspaceID, ret domain.LogRetention) error
+	Archives(appspaceID domain.AppspaceID) ([]domain.LogArchive, error)
+	ArchivePath(appspaceID domain.AppspaceID, name string) (string, error)
 }
@@ -60,6 +60,21 @@ func (mr *MockLoggerIMockRecorder) Log(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Log", reflect.TypeOf((*MockLoggerI)(nil).Log), arg0, arg1)
 }
 
+// Query mocks base method
+func (m *MockLoggerI) Query(arg0 domain.LogQuery) (domain.LogQueryResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Query", arg0)
+	ret0, _ := ret[0].(domain.LogQueryResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Query indicates an expected call of Query
+func (mr *MockLoggerIMockRecorder) Query(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*MockLoggerI)(nil).Query), arg0)
+}
+
 // SubscribeEntries mocks base method
 func (m *MockLoggerI) SubscribeEntries(arg0 int64) (domain.LogChunk, <-chan string, error) {
 	m.ctrl.T.Helper()
@@ -225,6 +240,36 @@ func (m *MockAppspaceLogger) EXPECT() *MockAppspaceLoggerMockRecorder {
 	return m.recorder
 }
 
+// ArchivePath mocks base method
+func (m *MockAppspaceLogger) ArchivePath(arg0 domain.AppspaceID, arg1 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ArchivePath", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ArchivePath indicates an expected call of ArchivePath
+func (mr *MockAppspaceLoggerMockRecorder) ArchivePath(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ArchivePath", reflect.TypeOf((*MockAppspaceLogger)(nil).ArchivePath), arg0, arg1)
+}
+
+// Archives mocks base method
+func (m *MockAppspaceLogger) Archives(arg0 domain.AppspaceID) ([]domain.LogArchive, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Archives", arg0)
+	ret0, _ := ret[0].([]domain.LogArchive)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Archives indicates an expected call of Archives
+func (mr *MockAppspaceLoggerMockRecorder) Archives(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Archives", reflect.TypeOf((*MockAppspaceLogger)(nil).Archives), arg0)
+}
+
 // Close mocks base method
 func (m *MockAppspaceLogger) Close(arg0 domain.AppspaceID) {
 	m.ctrl.T.Helper()
@@ -288,3 +333,17 @@ func (mr *MockAppspaceLoggerMockRecorder) Open(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Open", reflect.TypeOf((*MockAppspaceLogger)(nil).Open), arg0)
 }
+
+// SetRetention mocks base method
+func (m *MockAppspaceLogger) SetRetention(arg0 domain.AppspaceID, arg1 domain.LogRetention) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRetention", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetRetention indicates an expected call of SetRetention
+func (mr *MockAppspaceLoggerMockRecorder) SetRetention(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRetention", reflect.TypeOf((*MockAppspaceLogger)(nil).SetRetention), arg0, arg1)
+}
@@ -26,6 +26,7 @@ type AppspaceUserModel interface {
 	Create(appspaceID domain.AppspaceID, displayName string, avatar string, auths []domain.EditAppspaceUserAuth) (domain.ProxyID, error)
 	Update(appspaceID domain.AppspaceID, proxyID domain.ProxyID, displayName string, avatar string, auths []domain.EditAppspaceUserAuth) error
 	UpdateAvatar(appspaceID domain.AppspaceID, proxyID domain.ProxyID, avatar string) error
+	UpdateAuth(appspaceID domain.AppspaceID, proxyID domain.ProxyID, auth domain.EditAppspaceUserAuth) error
 	Get(appspaceID domain.AppspaceID, proxyID domain.ProxyID) (domain.AppspaceUser, error)
 	GetByAuth(appspaceID domain.AppspaceID, authType string, identifier string) (domain.AppspaceUser, error)
 	GetAll(appspaceID domain.AppspaceID) ([]domain.AppspaceUser, error)
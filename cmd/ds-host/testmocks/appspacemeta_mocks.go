@@ -283,6 +283,20 @@ func (mr *MockAppspaceUserModelMockRecorder) Update(arg0, arg1, arg2, arg3, arg4
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockAppspaceUserModel)(nil).Update), arg0, arg1, arg2, arg3, arg4)
 }
 
+// UpdateAuth mocks base method
+func (m *MockAppspaceUserModel) UpdateAuth(arg0 domain.AppspaceID, arg1 domain.ProxyID, arg2 domain.EditAppspaceUserAuth) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAuth", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAuth indicates an expected call of UpdateAuth
+func (mr *MockAppspaceUserModelMockRecorder) UpdateAuth(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAuth", reflect.TypeOf((*MockAppspaceUserModel)(nil).UpdateAuth), arg0, arg1, arg2)
+}
+
 // UpdateAvatar mocks base method
 func (m *MockAppspaceUserModel) UpdateAvatar(arg0 domain.AppspaceID, arg1 domain.ProxyID, arg2 string) error {
 	m.ctrl.T.Helper()
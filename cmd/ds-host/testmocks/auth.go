@@ -7,17 +7,38 @@ import (
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
 )
 
-//go:generate mockgen -destination=auth_mocks.go -package=testmocks -self_package=github.com/teleclimber/DropServer/cmd/ds-host/testmocks github.com/teleclimber/DropServer/cmd/ds-host/testmocks Authenticator,V0TokenManager,V0RequestToken,DS2DS
+//go:generate mockgen -destination=auth_mocks.go -package=testmocks -self_package=github.com/teleclimber/DropServer/cmd/ds-host/testmocks github.com/teleclimber/DropServer/cmd/ds-host/testmocks Authenticator,TwoFactor,V0TokenManager,V0RequestToken,DS2DS
 
 // Authenticator is an interface that can set and authenticate cookies
 // And in the future it will handle other forms of authentication
 type Authenticator interface {
-	SetForAccount(http.ResponseWriter, domain.UserID) error
-	SetForAppspace(http.ResponseWriter, domain.ProxyID, domain.AppspaceID, string) (string, error)
+	SetForAccount(http.ResponseWriter, *http.Request, domain.UserID) error
+	SetPendingSecondFactor(http.ResponseWriter, *http.Request, domain.UserID) error
+	PendingSecondFactor(*http.Request) (domain.UserID, bool)
+	CompleteSecondFactor(http.ResponseWriter, *http.Request) error
+	SetForAppspace(http.ResponseWriter, *http.Request, domain.ProxyID, domain.AppspaceID, string) (string, error)
 	Unset(http.ResponseWriter, *http.Request)
 	AppspaceUserProxyID(http.Handler) http.Handler
 }
 
+// TwoFactor manages users' second factors
+type TwoFactor interface {
+	Required() (bool, error)
+	Status(domain.UserID) (domain.TwoFactorStatus, error)
+	Has(domain.UserID) (bool, error)
+	BeginTOTP(domain.UserID) (string, string, error)
+	ConfirmTOTP(domain.UserID, string) ([]string, error)
+	DisableTOTP(domain.UserID) error
+	CheckTOTP(domain.UserID, string) (bool, error)
+	UseRecoveryCode(domain.UserID, string) (bool, error)
+	NewRecoveryCodes(domain.UserID) ([]string, error)
+	BeginPasskeyRegistration(domain.UserID) (domain.WebAuthnCreationOptions, error)
+	FinishPasskeyRegistration(domain.UserID, string, []byte) (domain.Passkey, error)
+	BeginPasskeyLogin(domain.UserID) (domain.WebAuthnRequestOptions, error)
+	FinishPasskeyLogin(domain.UserID, []byte) (domain.UserID, error)
+	DeletePasskey(domain.UserID, string) error
+}
+
 // V0TokenManager tracks and returns appspace login tokens
 type V0TokenManager interface {
 	GetForOwner(appspaceID domain.AppspaceID, dropID string) (string, error)
@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/teleclimber/DropServer/cmd/ds-host/testmocks (interfaces: Authenticator,V0TokenManager,V0RequestToken,DS2DS)
+// Source: github.com/teleclimber/DropServer/cmd/ds-host/testmocks (interfaces: Authenticator,TwoFactor,V0TokenManager,V0RequestToken,DS2DS)
 
 // Package testmocks is a generated GoMock package.
 package testmocks
@@ -49,33 +49,76 @@ func (mr *MockAuthenticatorMockRecorder) AppspaceUserProxyID(arg0 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppspaceUserProxyID", reflect.TypeOf((*MockAuthenticator)(nil).AppspaceUserProxyID), arg0)
 }
 
+// CompleteSecondFactor mocks base method
+func (m *MockAuthenticator) CompleteSecondFactor(arg0 http.ResponseWriter, arg1 *http.Request) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteSecondFactor", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteSecondFactor indicates an expected call of CompleteSecondFactor
+func (mr *MockAuthenticatorMockRecorder) CompleteSecondFactor(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteSecondFactor", reflect.TypeOf((*MockAuthenticator)(nil).CompleteSecondFactor), arg0, arg1)
+}
+
+// PendingSecondFactor mocks base method
+func (m *MockAuthenticator) PendingSecondFactor(arg0 *http.Request) (domain.UserID, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PendingSecondFactor", arg0)
+	ret0, _ := ret[0].(domain.UserID)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// PendingSecondFactor indicates an expected call of PendingSecondFactor
+func (mr *MockAuthenticatorMockRecorder) PendingSecondFactor(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PendingSecondFactor", reflect.TypeOf((*MockAuthenticator)(nil).PendingSecondFactor), arg0)
+}
+
 // SetForAccount mocks base method
-func (m *MockAuthenticator) SetForAccount(arg0 http.ResponseWriter, arg1 domain.UserID) error {
+func (m *MockAuthenticator) SetForAccount(arg0 http.ResponseWriter, arg1 *http.Request, arg2 domain.UserID) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SetForAccount", arg0, arg1)
+	ret := m.ctrl.Call(m, "SetForAccount", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SetForAccount indicates an expected call of SetForAccount
-func (mr *MockAuthenticatorMockRecorder) SetForAccount(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockAuthenticatorMockRecorder) SetForAccount(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetForAccount", reflect.TypeOf((*MockAuthenticator)(nil).SetForAccount), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetForAccount", reflect.TypeOf((*MockAuthenticator)(nil).SetForAccount), arg0, arg1, arg2)
 }
 
 // SetForAppspace mocks base method
-func (m *MockAuthenticator) SetForAppspace(arg0 http.ResponseWriter, arg1 domain.ProxyID, arg2 domain.AppspaceID, arg3 string) (string, error) {
+func (m *MockAuthenticator) SetForAppspace(arg0 http.ResponseWriter, arg1 *http.Request, arg2 domain.ProxyID, arg3 domain.AppspaceID, arg4 string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SetForAppspace", arg0, arg1, arg2, arg3)
+	ret := m.ctrl.Call(m, "SetForAppspace", arg0, arg1, arg2, arg3, arg4)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // SetForAppspace indicates an expected call of SetForAppspace
-func (mr *MockAuthenticatorMockRecorder) SetForAppspace(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+func (mr *MockAuthenticatorMockRecorder) SetForAppspace(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetForAppspace", reflect.TypeOf((*MockAuthenticator)(nil).SetForAppspace), arg0, arg1, arg2, arg3)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetForAppspace", reflect.TypeOf((*MockAuthenticator)(nil).SetForAppspace), arg0, arg1, arg2, arg3, arg4)
+}
+
+// SetPendingSecondFactor mocks base method
+func (m *MockAuthenticator) SetPendingSecondFactor(arg0 http.ResponseWriter, arg1 *http.Request, arg2 domain.UserID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPendingSecondFactor", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPendingSecondFactor indicates an expected call of SetPendingSecondFactor
+func (mr *MockAuthenticatorMockRecorder) SetPendingSecondFactor(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPendingSecondFactor", reflect.TypeOf((*MockAuthenticator)(nil).SetPendingSecondFactor), arg0, arg1, arg2)
 }
 
 // Unset mocks base method
@@ -90,6 +133,238 @@ func (mr *MockAuthenticatorMockRecorder) Unset(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unset", reflect.TypeOf((*MockAuthenticator)(nil).Unset), arg0, arg1)
 }
 
+// MockTwoFactor is a mock of TwoFactor interface
+type MockTwoFactor struct {
+	ctrl     *gomock.Controller
+	recorder *MockTwoFactorMockRecorder
+}
+
+// MockTwoFactorMockRecorder is the mock recorder for MockTwoFactor
+type MockTwoFactorMockRecorder struct {
+	mock *MockTwoFactor
+}
+
+// NewMockTwoFactor creates a new mock instance
+func NewMockTwoFactor(ctrl *gomock.Controller) *MockTwoFactor {
+	mock := &MockTwoFactor{ctrl: ctrl}
+	mock.recorder = &MockTwoFactorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockTwoFactor) EXPECT() *MockTwoFactorMockRecorder {
+	return m.recorder
+}
+
+// BeginPasskeyLogin mocks base method
+func (m *MockTwoFactor) BeginPasskeyLogin(arg0 domain.UserID) (domain.WebAuthnRequestOptions, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginPasskeyLogin", arg0)
+	ret0, _ := ret[0].(domain.WebAuthnRequestOptions)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeginPasskeyLogin indicates an expected call of BeginPasskeyLogin
+func (mr *MockTwoFactorMockRecorder) BeginPasskeyLogin(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginPasskeyLogin", reflect.TypeOf((*MockTwoFactor)(nil).BeginPasskeyLogin), arg0)
+}
+
+// BeginPasskeyRegistration mocks base method
+func (m *MockTwoFactor) BeginPasskeyRegistration(arg0 domain.UserID) (domain.WebAuthnCreationOptions, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginPasskeyRegistration", arg0)
+	ret0, _ := ret[0].(domain.WebAuthnCreationOptions)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeginPasskeyRegistration indicates an expected call of BeginPasskeyRegistration
+func (mr *MockTwoFactorMockRecorder) BeginPasskeyRegistration(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginPasskeyRegistration", reflect.TypeOf((*MockTwoFactor)(nil).BeginPasskeyRegistration), arg0)
+}
+
+// BeginTOTP mocks base method
+func (m *MockTwoFactor) BeginTOTP(arg0 domain.UserID) (string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginTOTP", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// BeginTOTP indicates an expected call of BeginTOTP
+func (mr *MockTwoFactorMockRecorder) BeginTOTP(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginTOTP", reflect.TypeOf((*MockTwoFactor)(nil).BeginTOTP), arg0)
+}
+
+// CheckTOTP mocks base method
+func (m *MockTwoFactor) CheckTOTP(arg0 domain.UserID, arg1 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckTOTP", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CheckTOTP indicates an expected call of CheckTOTP
+func (mr *MockTwoFactorMockRecorder) CheckTOTP(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckTOTP", reflect.TypeOf((*MockTwoFactor)(nil).CheckTOTP), arg0, arg1)
+}
+
+// ConfirmTOTP mocks base method
+func (m *MockTwoFactor) ConfirmTOTP(arg0 domain.UserID, arg1 string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTOTP", arg0, arg1)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmTOTP indicates an expected call of ConfirmTOTP
+func (mr *MockTwoFactorMockRecorder) ConfirmTOTP(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTOTP", reflect.TypeOf((*MockTwoFactor)(nil).ConfirmTOTP), arg0, arg1)
+}
+
+// DeletePasskey mocks base method
+func (m *MockTwoFactor) DeletePasskey(arg0 domain.UserID, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletePasskey", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeletePasskey indicates an expected call of DeletePasskey
+func (mr *MockTwoFactorMockRecorder) DeletePasskey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePasskey", reflect.TypeOf((*MockTwoFactor)(nil).DeletePasskey), arg0, arg1)
+}
+
+// DisableTOTP mocks base method
+func (m *MockTwoFactor) DisableTOTP(arg0 domain.UserID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableTOTP", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableTOTP indicates an expected call of DisableTOTP
+func (mr *MockTwoFactorMockRecorder) DisableTOTP(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableTOTP", reflect.TypeOf((*MockTwoFactor)(nil).DisableTOTP), arg0)
+}
+
+// FinishPasskeyLogin mocks base method
+func (m *MockTwoFactor) FinishPasskeyLogin(arg0 domain.UserID, arg1 []byte) (domain.UserID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishPasskeyLogin", arg0, arg1)
+	ret0, _ := ret[0].(domain.UserID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FinishPasskeyLogin indicates an expected call of FinishPasskeyLogin
+func (mr *MockTwoFactorMockRecorder) FinishPasskeyLogin(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishPasskeyLogin", reflect.TypeOf((*MockTwoFactor)(nil).FinishPasskeyLogin), arg0, arg1)
+}
+
+// FinishPasskeyRegistration mocks base method
+func (m *MockTwoFactor) FinishPasskeyRegistration(arg0 domain.UserID, arg1 string, arg2 []byte) (domain.Passkey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishPasskeyRegistration", arg0, arg1, arg2)
+	ret0, _ := ret[0].(domain.Passkey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FinishPasskeyRegistration indicates an expected call of FinishPasskeyRegistration
+func (mr *MockTwoFactorMockRecorder) FinishPasskeyRegistration(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishPasskeyRegistration", reflect.TypeOf((*MockTwoFactor)(nil).FinishPasskeyRegistration), arg0, arg1, arg2)
+}
+
+// Has mocks base method
+func (m *MockTwoFactor) Has(arg0 domain.UserID) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Has", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Has indicates an expected call of Has
+func (mr *MockTwoFactorMockRecorder) Has(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Has", reflect.TypeOf((*MockTwoFactor)(nil).Has), arg0)
+}
+
+// NewRecoveryCodes mocks base method
+func (m *MockTwoFactor) NewRecoveryCodes(arg0 domain.UserID) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NewRecoveryCodes", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NewRecoveryCodes indicates an expected call of NewRecoveryCodes
+func (mr *MockTwoFactorMockRecorder) NewRecoveryCodes(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewRecoveryCodes", reflect.TypeOf((*MockTwoFactor)(nil).NewRecoveryCodes), arg0)
+}
+
+// Required mocks base method
+func (m *MockTwoFactor) Required() (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Required")
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Required indicates an expected call of Required
+func (mr *MockTwoFactorMockRecorder) Required() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Required", reflect.TypeOf((*MockTwoFactor)(nil).Required))
+}
+
+// Status mocks base method
+func (m *MockTwoFactor) Status(arg0 domain.UserID) (domain.TwoFactorStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Status", arg0)
+	ret0, _ := ret[0].(domain.TwoFactorStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Status indicates an expected call of Status
+func (mr *MockTwoFactorMockRecorder) Status(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Status", reflect.TypeOf((*MockTwoFactor)(nil).Status), arg0)
+}
+
+// UseRecoveryCode mocks base method
+func (m *MockTwoFactor) UseRecoveryCode(arg0 domain.UserID, arg1 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode
+func (mr *MockTwoFactorMockRecorder) UseRecoveryCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockTwoFactor)(nil).UseRecoveryCode), arg0, arg1)
+}
+
 // MockV0TokenManager is a mock of V0TokenManager interface
 type MockV0TokenManager struct {
 	ctrl     *gomock.Controller
@@ -7,7 +7,7 @@ import (
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
 )
 
-//go:generate mockgen -destination=controllers_mocks.go -package=testmocks github.com/teleclimber/DropServer/cmd/ds-host/testmocks SetupKey,RemoteAppGetter,DeleteApp,BackupAppspace,RestoreAppspace,MigrationJobController,AppspaceStatus,AppspaceTSNet,AppspaceRouter
+//go:generate mockgen -destination=controllers_mocks.go -package=testmocks github.com/teleclimber/DropServer/cmd/ds-host/testmocks SetupKey,RemoteAppGetter,DeleteApp,BackupAppspace,RestoreAppspace,ExportAppspace,ImportAppspace,PauseAppspace,DomainController,MigrationJobController,AppspaceStatus,AppspaceTSNet,AppspaceRouter,OutboundPolicy,Mailer
 
 type SetupKey interface {
 	Has() (bool, error)
@@ -27,15 +27,40 @@ type DeleteApp interface {
 
 type BackupAppspace interface {
 	CreateBackup(appspaceID domain.AppspaceID) (string, error)
+	CreateScheduledBackup(appspaceID domain.AppspaceID) (string, error)
 	BackupNoPause(appspaceID domain.AppspaceID) (string, error)
 }
 
 type RestoreAppspace interface {
 	Prepare(reader io.Reader) (string, error)
 	PrepareBackup(appspaceID domain.AppspaceID, backupFile string) (string, error)
+	PrepareRemoteBackup(appspaceID domain.AppspaceID, remote string, backupFile string) (string, error)
+	IsEncrypted(tok string) (bool, error)
+	Decrypt(tok string, passphrase string) error
 	CheckAppspaceDataValid(tok string) error
 	GetMetaInfo(tok string) (domain.AppspaceMetaInfo, error)
 	ReplaceData(tok string, appspaceID domain.AppspaceID) error
+	Delete(tok string)
+}
+
+type ExportAppspace interface {
+	WriteBundle(appspaceID domain.AppspaceID, backupFile string, w io.Writer) error
+	WriteTransferBundle(appspaceID domain.AppspaceID, backupFile string, w io.Writer) error
+}
+
+type ImportAppspace interface {
+	Prepare(userID domain.UserID, reader io.Reader) (string, domain.AppspaceBundleMeta, error)
+	GetMeta(userID domain.UserID, tok string) (domain.AppspaceBundleMeta, error)
+	Import(tok string, dropID domain.DropID, baseDomain, subDomain string, passphrase string) (domain.AppspaceID, error)
+}
+
+type PauseAppspace interface {
+	Pause(appspaceID domain.AppspaceID, pause bool) error
+}
+
+type DomainController interface {
+	CheckAppspaceDomain(userID domain.UserID, dom string, subdomain string) (domain.DomainCheckResult, error)
+	StartManaging(dom string) error
 }
 
 // MigrationJobController controls and tracks appspace migration jobs
@@ -73,3 +98,17 @@ type AppspaceRouter interface {
 	SubscribeLiveCount(domain.AppspaceID, chan<- int) int
 	UnsubscribeLiveCount(domain.AppspaceID, chan<- int)
 }
+
+type OutboundPolicy interface {
+	GetAllowed(appVersion domain.AppVersion, appspace *domain.Appspace) ([]string, error)
+	GetDeclared(appID domain.AppID, version domain.Version) ([]string, error)
+	Validate(appID domain.AppID, version domain.Version, hosts []string) ([]string, error)
+	Approve(appspace domain.Appspace, version domain.Version, hosts []string) error
+}
+
+type Mailer interface {
+	Enabled() bool
+	Send(to string, name string, data any) error
+	SendToUser(userID domain.UserID, name string, data any) error
+	SendToAdmins(name string, data any) error
+}
@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/teleclimber/DropServer/cmd/ds-host/testmocks (interfaces: SetupKey,RemoteAppGetter,DeleteApp,BackupAppspace,RestoreAppspace,MigrationJobController,AppspaceStatus,AppspaceTSNet,AppspaceRouter)
+// Source: github.com/teleclimber/DropServer/cmd/ds-host/testmocks (interfaces: SetupKey,RemoteAppGetter,DeleteApp,BackupAppspace,RestoreAppspace,ExportAppspace,ImportAppspace,PauseAppspace,DomainController,MigrationJobController,AppspaceStatus,AppspaceTSNet,AppspaceRouter,OutboundPolicy,Mailer)
 
 // Package testmocks is a generated GoMock package.
 package testmocks
@@ -236,6 +236,21 @@ func (mr *MockBackupAppspaceMockRecorder) CreateBackup(arg0 interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBackup", reflect.TypeOf((*MockBackupAppspace)(nil).CreateBackup), arg0)
 }
 
+// CreateScheduledBackup mocks base method
+func (m *MockBackupAppspace) CreateScheduledBackup(arg0 domain.AppspaceID) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateScheduledBackup", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateScheduledBackup indicates an expected call of CreateScheduledBackup
+func (mr *MockBackupAppspaceMockRecorder) CreateScheduledBackup(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateScheduledBackup", reflect.TypeOf((*MockBackupAppspace)(nil).CreateScheduledBackup), arg0)
+}
+
 // MockRestoreAppspace is a mock of RestoreAppspace interface
 type MockRestoreAppspace struct {
 	ctrl     *gomock.Controller
@@ -273,6 +288,32 @@ func (mr *MockRestoreAppspaceMockRecorder) CheckAppspaceDataValid(arg0 interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckAppspaceDataValid", reflect.TypeOf((*MockRestoreAppspace)(nil).CheckAppspaceDataValid), arg0)
 }
 
+// Decrypt mocks base method
+func (m *MockRestoreAppspace) Decrypt(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Decrypt", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Decrypt indicates an expected call of Decrypt
+func (mr *MockRestoreAppspaceMockRecorder) Decrypt(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Decrypt", reflect.TypeOf((*MockRestoreAppspace)(nil).Decrypt), arg0, arg1)
+}
+
+// Delete mocks base method
+func (m *MockRestoreAppspace) Delete(arg0 string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Delete", arg0)
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockRestoreAppspaceMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockRestoreAppspace)(nil).Delete), arg0)
+}
+
 // GetMetaInfo mocks base method
 func (m *MockRestoreAppspace) GetMetaInfo(arg0 string) (domain.AppspaceMetaInfo, error) {
 	m.ctrl.T.Helper()
@@ -288,6 +329,21 @@ func (mr *MockRestoreAppspaceMockRecorder) GetMetaInfo(arg0 interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMetaInfo", reflect.TypeOf((*MockRestoreAppspace)(nil).GetMetaInfo), arg0)
 }
 
+// IsEncrypted mocks base method
+func (m *MockRestoreAppspace) IsEncrypted(arg0 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsEncrypted", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsEncrypted indicates an expected call of IsEncrypted
+func (mr *MockRestoreAppspaceMockRecorder) IsEncrypted(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsEncrypted", reflect.TypeOf((*MockRestoreAppspace)(nil).IsEncrypted), arg0)
+}
+
 // Prepare mocks base method
 func (m *MockRestoreAppspace) Prepare(arg0 io.Reader) (string, error) {
 	m.ctrl.T.Helper()
@@ -318,6 +374,21 @@ func (mr *MockRestoreAppspaceMockRecorder) PrepareBackup(arg0, arg1 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PrepareBackup", reflect.TypeOf((*MockRestoreAppspace)(nil).PrepareBackup), arg0, arg1)
 }
 
+// PrepareRemoteBackup mocks base method
+func (m *MockRestoreAppspace) PrepareRemoteBackup(arg0 domain.AppspaceID, arg1, arg2 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PrepareRemoteBackup", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PrepareRemoteBackup indicates an expected call of PrepareRemoteBackup
+func (mr *MockRestoreAppspaceMockRecorder) PrepareRemoteBackup(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PrepareRemoteBackup", reflect.TypeOf((*MockRestoreAppspace)(nil).PrepareRemoteBackup), arg0, arg1, arg2)
+}
+
 // ReplaceData mocks base method
 func (m *MockRestoreAppspace) ReplaceData(arg0 string, arg1 domain.AppspaceID) error {
 	m.ctrl.T.Helper()
@@ -332,6 +403,215 @@ func (mr *MockRestoreAppspaceMockRecorder) ReplaceData(arg0, arg1 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceData", reflect.TypeOf((*MockRestoreAppspace)(nil).ReplaceData), arg0, arg1)
 }
 
+// MockExportAppspace is a mock of ExportAppspace interface
+type MockExportAppspace struct {
+	ctrl     *gomock.Controller
+	recorder *MockExportAppspaceMockRecorder
+}
+
+// MockExportAppspaceMockRecorder is the mock recorder for MockExportAppspace
+type MockExportAppspaceMockRecorder struct {
+	mock *MockExportAppspace
+}
+
+// NewMockExportAppspace creates a new mock instance
+func NewMockExportAppspace(ctrl *gomock.Controller) *MockExportAppspace {
+	mock := &MockExportAppspace{ctrl: ctrl}
+	mock.recorder = &MockExportAppspaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockExportAppspace) EXPECT() *MockExportAppspaceMockRecorder {
+	return m.recorder
+}
+
+// WriteBundle mocks base method
+func (m *MockExportAppspace) WriteBundle(arg0 domain.AppspaceID, arg1 string, arg2 io.Writer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteBundle", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteBundle indicates an expected call of WriteBundle
+func (mr *MockExportAppspaceMockRecorder) WriteBundle(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteBundle", reflect.TypeOf((*MockExportAppspace)(nil).WriteBundle), arg0, arg1, arg2)
+}
+
+// WriteTransferBundle mocks base method
+func (m *MockExportAppspace) WriteTransferBundle(arg0 domain.AppspaceID, arg1 string, arg2 io.Writer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteTransferBundle", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteTransferBundle indicates an expected call of WriteTransferBundle
+func (mr *MockExportAppspaceMockRecorder) WriteTransferBundle(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteTransferBundle", reflect.TypeOf((*MockExportAppspace)(nil).WriteTransferBundle), arg0, arg1, arg2)
+}
+
+// MockImportAppspace is a mock of ImportAppspace interface
+type MockImportAppspace struct {
+	ctrl     *gomock.Controller
+	recorder *MockImportAppspaceMockRecorder
+}
+
+// MockImportAppspaceMockRecorder is the mock recorder for MockImportAppspace
+type MockImportAppspaceMockRecorder struct {
+	mock *MockImportAppspace
+}
+
+// NewMockImportAppspace creates a new mock instance
+func NewMockImportAppspace(ctrl *gomock.Controller) *MockImportAppspace {
+	mock := &MockImportAppspace{ctrl: ctrl}
+	mock.recorder = &MockImportAppspaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockImportAppspace) EXPECT() *MockImportAppspaceMockRecorder {
+	return m.recorder
+}
+
+// GetMeta mocks base method
+func (m *MockImportAppspace) GetMeta(arg0 domain.UserID, arg1 string) (domain.AppspaceBundleMeta, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMeta", arg0, arg1)
+	ret0, _ := ret[0].(domain.AppspaceBundleMeta)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMeta indicates an expected call of GetMeta
+func (mr *MockImportAppspaceMockRecorder) GetMeta(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMeta", reflect.TypeOf((*MockImportAppspace)(nil).GetMeta), arg0, arg1)
+}
+
+// Import mocks base method
+func (m *MockImportAppspace) Import(arg0 string, arg1 domain.DropID, arg2, arg3, arg4 string) (domain.AppspaceID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Import", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(domain.AppspaceID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Import indicates an expected call of Import
+func (mr *MockImportAppspaceMockRecorder) Import(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Import", reflect.TypeOf((*MockImportAppspace)(nil).Import), arg0, arg1, arg2, arg3, arg4)
+}
+
+// Prepare mocks base method
+func (m *MockImportAppspace) Prepare(arg0 domain.UserID, arg1 io.Reader) (string, domain.AppspaceBundleMeta, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Prepare", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(domain.AppspaceBundleMeta)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Prepare indicates an expected call of Prepare
+func (mr *MockImportAppspaceMockRecorder) Prepare(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Prepare", reflect.TypeOf((*MockImportAppspace)(nil).Prepare), arg0, arg1)
+}
+
+// MockPauseAppspace is a mock of PauseAppspace interface
+type MockPauseAppspace struct {
+	ctrl     *gomock.Controller
+	recorder *MockPauseAppspaceMockRecorder
+}
+
+// MockPauseAppspaceMockRecorder is the mock recorder for MockPauseAppspace
+type MockPauseAppspaceMockRecorder struct {
+	mock *MockPauseAppspace
+}
+
+// NewMockPauseAppspace creates a new mock instance
+func NewMockPauseAppspace(ctrl *gomock.Controller) *MockPauseAppspace {
+	mock := &MockPauseAppspace{ctrl: ctrl}
+	mock.recorder = &MockPauseAppspaceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockPauseAppspace) EXPECT() *MockPauseAppspaceMockRecorder {
+	return m.recorder
+}
+
+// Pause mocks base method
+func (m *MockPauseAppspace) Pause(arg0 domain.AppspaceID, arg1 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Pause", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Pause indicates an expected call of Pause
+func (mr *MockPauseAppspaceMockRecorder) Pause(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pause", reflect.TypeOf((*MockPauseAppspace)(nil).Pause), arg0, arg1)
+}
+
+// MockDomainController is a mock of DomainController interface
+type MockDomainController struct {
+	ctrl     *gomock.Controller
+	recorder *MockDomainControllerMockRecorder
+}
+
+// MockDomainControllerMockRecorder is the mock recorder for MockDomainController
+type MockDomainControllerMockRecorder struct {
+	mock *MockDomainController
+}
+
+// NewMockDomainController creates a new mock instance
+func NewMockDomainController(ctrl *gomock.Controller) *MockDomainController {
+	mock := &MockDomainController{ctrl: ctrl}
+	mock.recorder = &MockDomainControllerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockDomainController) EXPECT() *MockDomainControllerMockRecorder {
+	return m.recorder
+}
+
+// CheckAppspaceDomain mocks base method
+func (m *MockDomainController) CheckAppspaceDomain(arg0 domain.UserID, arg1, arg2 string) (domain.DomainCheckResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckAppspaceDomain", arg0, arg1, arg2)
+	ret0, _ := ret[0].(domain.DomainCheckResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CheckAppspaceDomain indicates an expected call of CheckAppspaceDomain
+func (mr *MockDomainControllerMockRecorder) CheckAppspaceDomain(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckAppspaceDomain", reflect.TypeOf((*MockDomainController)(nil).CheckAppspaceDomain), arg0, arg1, arg2)
+}
+
+// StartManaging mocks base method
+func (m *MockDomainController) StartManaging(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartManaging", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StartManaging indicates an expected call of StartManaging
+func (mr *MockDomainControllerMockRecorder) StartManaging(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartManaging", reflect.TypeOf((*MockDomainController)(nil).StartManaging), arg0)
+}
+
 // MockMigrationJobController is a mock of MigrationJobController interface
 type MockMigrationJobController struct {
 	ctrl     *gomock.Controller
@@ -688,3 +968,164 @@ func (mr *MockAppspaceRouterMockRecorder) UnsubscribeLiveCount(arg0, arg1 interf
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnsubscribeLiveCount", reflect.TypeOf((*MockAppspaceRouter)(nil).UnsubscribeLiveCount), arg0, arg1)
 }
+
+// MockOutboundPolicy is a mock of OutboundPolicy interface
+type MockOutboundPolicy struct {
+	ctrl     *gomock.Controller
+	recorder *MockOutboundPolicyMockRecorder
+}
+
+// MockOutboundPolicyMockRecorder is the mock recorder for MockOutboundPolicy
+type MockOutboundPolicyMockRecorder struct {
+	mock *MockOutboundPolicy
+}
+
+// NewMockOutboundPolicy creates a new mock instance
+func NewMockOutboundPolicy(ctrl *gomock.Controller) *MockOutboundPolicy {
+	mock := &MockOutboundPolicy{ctrl: ctrl}
+	mock.recorder = &MockOutboundPolicyMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockOutboundPolicy) EXPECT() *MockOutboundPolicyMockRecorder {
+	return m.recorder
+}
+
+// Approve mocks base method
+func (m *MockOutboundPolicy) Approve(arg0 domain.Appspace, arg1 domain.Version, arg2 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Approve", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Approve indicates an expected call of Approve
+func (mr *MockOutboundPolicyMockRecorder) Approve(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Approve", reflect.TypeOf((*MockOutboundPolicy)(nil).Approve), arg0, arg1, arg2)
+}
+
+// GetAllowed mocks base method
+func (m *MockOutboundPolicy) GetAllowed(arg0 domain.AppVersion, arg1 *domain.Appspace) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllowed", arg0, arg1)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllowed indicates an expected call of GetAllowed
+func (mr *MockOutboundPolicyMockRecorder) GetAllowed(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllowed", reflect.TypeOf((*MockOutboundPolicy)(nil).GetAllowed), arg0, arg1)
+}
+
+// GetDeclared mocks base method
+func (m *MockOutboundPolicy) GetDeclared(arg0 domain.AppID, arg1 domain.Version) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeclared", arg0, arg1)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeclared indicates an expected call of GetDeclared
+func (mr *MockOutboundPolicyMockRecorder) GetDeclared(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeclared", reflect.TypeOf((*MockOutboundPolicy)(nil).GetDeclared), arg0, arg1)
+}
+
+// Validate mocks base method
+func (m *MockOutboundPolicy) Validate(arg0 domain.AppID, arg1 domain.Version, arg2 []string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Validate", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Validate indicates an expected call of Validate
+func (mr *MockOutboundPolicyMockRecorder) Validate(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Validate", reflect.TypeOf((*MockOutboundPolicy)(nil).Validate), arg0, arg1, arg2)
+}
+
+// MockMailer is a mock of Mailer interface
+type MockMailer struct {
+	ctrl     *gomock.Controller
+	recorder *MockMailerMockRecorder
+}
+
+// MockMailerMockRecorder is the mock recorder for MockMailer
+type MockMailerMockRecorder struct {
+	mock *MockMailer
+}
+
+// NewMockMailer creates a new mock instance
+func NewMockMailer(ctrl *gomock.Controller) *MockMailer {
+	mock := &MockMailer{ctrl: ctrl}
+	mock.recorder = &MockMailerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockMailer) EXPECT() *MockMailerMockRecorder {
+	return m.recorder
+}
+
+// Enabled mocks base method
+func (m *MockMailer) Enabled() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enabled")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Enabled indicates an expected call of Enabled
+func (mr *MockMailerMockRecorder) Enabled() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enabled", reflect.TypeOf((*MockMailer)(nil).Enabled))
+}
+
+// Send mocks base method
+func (m *MockMailer) Send(arg0, arg1 string, arg2 interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Send", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Send indicates an expected call of Send
+func (mr *MockMailerMockRecorder) Send(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockMailer)(nil).Send), arg0, arg1, arg2)
+}
+
+// SendToAdmins mocks base method
+func (m *MockMailer) SendToAdmins(arg0 string, arg1 interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendToAdmins", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendToAdmins indicates an expected call of SendToAdmins
+func (mr *MockMailerMockRecorder) SendToAdmins(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendToAdmins", reflect.TypeOf((*MockMailer)(nil).SendToAdmins), arg0, arg1)
+}
+
+// SendToUser mocks base method
+func (m *MockMailer) SendToUser(arg0 domain.UserID, arg1 string, arg2 interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendToUser", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendToUser indicates an expected call of SendToUser
+func (mr *MockMailerMockRecorder) SendToUser(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendToUser", reflect.TypeOf((*MockMailer)(nil).SendToUser), arg0, arg1, arg2)
+}
@@ -2,19 +2,27 @@ package testmocks
 
 import (
 	"io"
+	"os"
 	"time"
 
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/internal/backupcrypt"
 	"github.com/teleclimber/DropServer/internal/nulltypes"
 )
 
-//go:generate mockgen -destination=models_mocks.go -package=testmocks -self_package=github.com/teleclimber/DropServer/cmd/ds-host/testmocks github.com/teleclimber/DropServer/cmd/ds-host/testmocks CookieModel,UserModel,SettingsModel,UserInvitationModel,AppFilesModel,AppModel,AppspaceModel,RemoteAppspaceModel,AppspaceFilesModel,AppspaceTSNetModel,ContactModel,DropIDModel,MigrationJobModel,SandboxRuns
+//go:generate mockgen -destination=models_mocks.go -package=testmocks -self_package=github.com/teleclimber/DropServer/cmd/ds-host/testmocks github.com/teleclimber/DropServer/cmd/ds-host/testmocks CookieModel,UserModel,SettingsModel,UserInvitationModel,PasswordResetModel,AppFilesModel,AppModel,AppspaceModel,RemoteAppspaceModel,AppspaceFilesModel,AppspaceTSNetModel,ContactModel,DropIDModel,MigrationJobModel,SandboxRuns,BackupScheduleModel,BackupKeyModel,AppspaceQuotaModel,UsageModel,AppspaceLogModel,AccessLogModel,AppspaceOutboundModel
 
 type CookieModel interface {
 	Get(cookieID string) (domain.Cookie, error)
 	Create(domain.Cookie) (string, error)
 	UpdateExpires(cookieID string, exp time.Time) error
 	Delete(cookieID string) error
+	GetForUser(userID domain.UserID) ([]domain.Cookie, error)
+	GetForAppspace(appspaceID domain.AppspaceID) ([]domain.Cookie, error)
+	DeleteForUser(userID domain.UserID, keepCookieID string) error
+	DeleteForAppspace(appspaceID domain.AppspaceID) error
+	DeleteForProxy(appspaceID domain.AppspaceID, proxyID domain.ProxyID) error
+	DeleteExpired() error
 }
 
 type UserModel interface {
@@ -39,6 +47,7 @@ type UserModel interface {
 type SettingsModel interface {
 	Get() (domain.Settings, error)
 	SetRegistrationOpen(bool) error
+	SetRequire2FA(bool) error
 	GetTSNet() (domain.TSNetCommon, error)
 	SetTSNet(domain.TSNetCommon) error
 	SetTSNetConnect(bool) error
@@ -54,6 +63,13 @@ type UserInvitationModel interface {
 	Delete(email string) error
 }
 
+type PasswordResetModel interface {
+	Create(userID domain.UserID, tokenHash string, expires time.Time) error
+	Get(tokenHash string) (domain.PasswordReset, error)
+	DeleteForUser(userID domain.UserID) error
+	DeleteExpired() error
+}
+
 // AppFilesModel represents the application's files saved to disk
 type AppFilesModel interface {
 	SavePackage(r io.Reader) (string, error)
@@ -61,6 +77,7 @@ type AppFilesModel interface {
 	ReadManifest(string) (*domain.AppVersionManifest, error)
 	WriteRoutes(locationKey string, routesData []byte) error
 	ReadRoutes(locationKey string) ([]byte, error)
+	OpenPackage(locationKey string) (*os.File, error)
 	Delete(string) error
 }
 
@@ -81,6 +98,7 @@ type AppModel interface {
 	GetCurrentVersion(appID domain.AppID) (domain.Version, error)
 	GetVersion(domain.AppID, domain.Version) (domain.AppVersion, error)
 	GetVersionForUI(appID domain.AppID, version domain.Version) (domain.AppVersionUI, error)
+	GetVersionManifest(appID domain.AppID, version domain.Version) (domain.AppVersionManifest, error)
 	GetVersionsForApp(domain.AppID) ([]*domain.AppVersion, error)
 	GetVersionsForUIForApp(domain.AppID) ([]domain.AppVersionUI, error)
 	CreateVersion(domain.AppID, string, domain.AppVersionManifest) (domain.AppVersion, error)
@@ -98,6 +116,7 @@ type AppspaceModel interface {
 	Create(domain.Appspace) (*domain.Appspace, error)
 	Pause(domain.AppspaceID, bool) error
 	SetVersion(domain.AppspaceID, domain.Version) error
+	SetRedirect(domain.AppspaceID, string) error
 	Delete(domain.AppspaceID) error
 }
 
@@ -114,6 +133,9 @@ type AppspaceFilesModel interface {
 	CreateLocation() (string, error)
 	CheckDataFiles(dataDir string) error
 	ReplaceData(domain.Appspace, string) error
+	GetBackups(locationKey string) ([]string, error)
+	DeleteBackup(locationKey string, filename string) error
+	WriteBackupZip(locationKey string, filename string, w io.Writer) error
 	//DeleteLocation(string) error
 }
 
@@ -125,6 +147,35 @@ type AppspaceTSNetModel interface {
 	Delete(domain.AppspaceID) error
 }
 
+// BackupScheduleModel stores appspace backup schedules
+type BackupScheduleModel interface {
+	Get(domain.AppspaceID) (domain.BackupSchedule, error)
+	GetAllEnabled() ([]domain.BackupSchedule, error)
+	Set(domain.BackupSchedule) error
+	SetLastRun(domain.AppspaceID, time.Time) error
+	Delete(domain.AppspaceID) error
+}
+
+// BackupKeyModel stores the keys appspace backups are encrypted to
+type BackupKeyModel interface {
+	Get(domain.AppspaceID) (domain.BackupKey, error)
+	GetRecipient(domain.AppspaceID) (backupcrypt.Recipient, bool, error)
+	Set(domain.AppspaceID, string) error
+	Delete(domain.AppspaceID) error
+}
+
+// AppspaceQuotaModel stores appspace quotas and limit hits
+type AppspaceQuotaModel interface {
+	Get(appspaceID domain.AppspaceID, setBy string) (domain.AppspaceQuota, error)
+	GetEffective(appspaceID domain.AppspaceID) (domain.AppspaceQuota, error)
+	Set(appspaceID domain.AppspaceID, setBy string, q domain.AppspaceQuota) error
+	RecordHit(appspaceID domain.AppspaceID, limit string, count int) error
+	GetHits(appspaceID domain.AppspaceID) ([]domain.AppspaceLimitHit, error)
+	GetAllHits() ([]domain.AppspaceLimitHit, error)
+	ClearHits(appspaceID domain.AppspaceID) error
+	Delete(appspaceID domain.AppspaceID) error
+}
+
 // ContactModel stores a user's contacts
 type ContactModel interface {
 	Create(userID domain.UserID, name string, displayName string) (domain.Contact, error)
@@ -164,4 +215,43 @@ type MigrationJobModel interface {
 type SandboxRuns interface {
 	Create(run domain.SandboxRunIDs, start time.Time) (int, error)
 	End(sandboxID int, end time.Time, data domain.SandboxRunData) error
+	AppspaceSums(ownerID domain.UserID, appspaceID domain.AppspaceID, from time.Time, to time.Time) (domain.SandboxRunData, error)
+	FirstStart() (time.Time, bool, error)
+	DeleteBefore(before time.Time) (int64, error)
+}
+
+// UsageModel stores rollups of sandbox runs
+type UsageModel interface {
+	RollupDay(dayStart time.Time) error
+	RollupPeriod(period string, start time.Time, end time.Time) error
+	LastDay() (time.Time, bool, error)
+	DeleteBefore(period string, before time.Time) error
+	GetForOwner(ownerID domain.UserID, period string, from time.Time, to time.Time) ([]domain.UsageRollup, error)
+	GetForAppspace(appspaceID domain.AppspaceID, period string, from time.Time, to time.Time) ([]domain.UsageRollup, error)
+	GetUserTotals(period string, from time.Time, to time.Time) ([]domain.UsageRollup, error)
+}
+
+// AppspaceLogModel stores per-appspace log settings
+type AppspaceLogModel interface {
+	GetRetention(appspaceID domain.AppspaceID) (domain.LogRetention, error)
+	SetRetention(appspaceID domain.AppspaceID, ret domain.LogRetention) error
+	Delete(appspaceID domain.AppspaceID) error
+}
+
+// AccessLogModel stores appspace access log settings and entries
+type AccessLogModel interface {
+	GetSettings(appspaceID domain.AppspaceID) (domain.AccessLogSettings, error)
+	SetSettings(appspaceID domain.AppspaceID, settings domain.AccessLogSettings) error
+	Insert(entries []domain.AccessLogEntry) error
+	Query(appspaceID domain.AppspaceID, q domain.AccessLogQuery) ([]domain.AccessLogEntry, error)
+	Stats(appspaceID domain.AppspaceID, from time.Time, to time.Time) (domain.AccessLogStats, error)
+	DeleteExpired(now time.Time, defaultKeepDays int) error
+	Delete(appspaceID domain.AppspaceID) error
+}
+
+// AppspaceOutboundModel stores outbound hosts approved for appspaces
+type AppspaceOutboundModel interface {
+	Get(appspaceID domain.AppspaceID) ([]string, error)
+	Set(appspaceID domain.AppspaceID, hosts []string) error
+	Delete(appspaceID domain.AppspaceID) error
 }
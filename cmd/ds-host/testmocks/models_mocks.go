@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/teleclimber/DropServer/cmd/ds-host/testmocks (interfaces: CookieModel,UserModel,SettingsModel,UserInvitationModel,AppFilesModel,AppModel,AppspaceModel,RemoteAppspaceModel,AppspaceFilesModel,AppspaceTSNetModel,ContactModel,DropIDModel,MigrationJobModel,SandboxRuns)
+// Source: github.com/teleclimber/DropServer/cmd/ds-host/testmocks (interfaces: CookieModel,UserModel,SettingsModel,UserInvitationModel,PasswordResetModel,AppFilesModel,AppModel,AppspaceModel,RemoteAppspaceModel,AppspaceFilesModel,AppspaceTSNetModel,ContactModel,DropIDModel,MigrationJobModel,SandboxRuns,BackupScheduleModel,BackupKeyModel,AppspaceQuotaModel,UsageModel,AppspaceLogModel,AccessLogModel,AppspaceOutboundModel)
 
 // Package testmocks is a generated GoMock package.
 package testmocks
@@ -7,8 +7,10 @@ package testmocks
 import (
 	gomock "github.com/golang/mock/gomock"
 	domain "github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	backupcrypt "github.com/teleclimber/DropServer/internal/backupcrypt"
 	nulltypes "github.com/teleclimber/DropServer/internal/nulltypes"
 	io "io"
+	os "os"
 	reflect "reflect"
 	time "time"
 )
@@ -65,6 +67,62 @@ func (mr *MockCookieModelMockRecorder) Delete(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockCookieModel)(nil).Delete), arg0)
 }
 
+// DeleteExpired mocks base method
+func (m *MockCookieModel) DeleteExpired() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpired")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpired indicates an expected call of DeleteExpired
+func (mr *MockCookieModelMockRecorder) DeleteExpired() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpired", reflect.TypeOf((*MockCookieModel)(nil).DeleteExpired))
+}
+
+// DeleteForAppspace mocks base method
+func (m *MockCookieModel) DeleteForAppspace(arg0 domain.AppspaceID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteForAppspace", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteForAppspace indicates an expected call of DeleteForAppspace
+func (mr *MockCookieModelMockRecorder) DeleteForAppspace(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteForAppspace", reflect.TypeOf((*MockCookieModel)(nil).DeleteForAppspace), arg0)
+}
+
+// DeleteForProxy mocks base method
+func (m *MockCookieModel) DeleteForProxy(arg0 domain.AppspaceID, arg1 domain.ProxyID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteForProxy", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteForProxy indicates an expected call of DeleteForProxy
+func (mr *MockCookieModelMockRecorder) DeleteForProxy(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteForProxy", reflect.TypeOf((*MockCookieModel)(nil).DeleteForProxy), arg0, arg1)
+}
+
+// DeleteForUser mocks base method
+func (m *MockCookieModel) DeleteForUser(arg0 domain.UserID, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteForUser", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteForUser indicates an expected call of DeleteForUser
+func (mr *MockCookieModelMockRecorder) DeleteForUser(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteForUser", reflect.TypeOf((*MockCookieModel)(nil).DeleteForUser), arg0, arg1)
+}
+
 // Get mocks base method
 func (m *MockCookieModel) Get(arg0 string) (domain.Cookie, error) {
 	m.ctrl.T.Helper()
@@ -80,6 +138,36 @@ func (mr *MockCookieModelMockRecorder) Get(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockCookieModel)(nil).Get), arg0)
 }
 
+// GetForAppspace mocks base method
+func (m *MockCookieModel) GetForAppspace(arg0 domain.AppspaceID) ([]domain.Cookie, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetForAppspace", arg0)
+	ret0, _ := ret[0].([]domain.Cookie)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetForAppspace indicates an expected call of GetForAppspace
+func (mr *MockCookieModelMockRecorder) GetForAppspace(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetForAppspace", reflect.TypeOf((*MockCookieModel)(nil).GetForAppspace), arg0)
+}
+
+// GetForUser mocks base method
+func (m *MockCookieModel) GetForUser(arg0 domain.UserID) ([]domain.Cookie, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetForUser", arg0)
+	ret0, _ := ret[0].([]domain.Cookie)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetForUser indicates an expected call of GetForUser
+func (mr *MockCookieModelMockRecorder) GetForUser(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetForUser", reflect.TypeOf((*MockCookieModel)(nil).GetForUser), arg0)
+}
+
 // UpdateExpires mocks base method
 func (m *MockCookieModel) UpdateExpires(arg0 string, arg1 time.Time) error {
 	m.ctrl.T.Helper()
@@ -416,6 +504,20 @@ func (mr *MockSettingsModelMockRecorder) SetRegistrationOpen(arg0 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRegistrationOpen", reflect.TypeOf((*MockSettingsModel)(nil).SetRegistrationOpen), arg0)
 }
 
+// SetRequire2FA mocks base method
+func (m *MockSettingsModel) SetRequire2FA(arg0 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRequire2FA", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetRequire2FA indicates an expected call of SetRequire2FA
+func (mr *MockSettingsModelMockRecorder) SetRequire2FA(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRequire2FA", reflect.TypeOf((*MockSettingsModel)(nil).SetRequire2FA), arg0)
+}
+
 // SetTSNet mocks base method
 func (m *MockSettingsModel) SetTSNet(arg0 domain.TSNetCommon) error {
 	m.ctrl.T.Helper()
@@ -537,6 +639,86 @@ func (mr *MockUserInvitationModelMockRecorder) PrepareStatements() *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PrepareStatements", reflect.TypeOf((*MockUserInvitationModel)(nil).PrepareStatements))
 }
 
+// MockPasswordResetModel is a mock of PasswordResetModel interface
+type MockPasswordResetModel struct {
+	ctrl     *gomock.Controller
+	recorder *MockPasswordResetModelMockRecorder
+}
+
+// MockPasswordResetModelMockRecorder is the mock recorder for MockPasswordResetModel
+type MockPasswordResetModelMockRecorder struct {
+	mock *MockPasswordResetModel
+}
+
+// NewMockPasswordResetModel creates a new mock instance
+func NewMockPasswordResetModel(ctrl *gomock.Controller) *MockPasswordResetModel {
+	mock := &MockPasswordResetModel{ctrl: ctrl}
+	mock.recorder = &MockPasswordResetModelMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockPasswordResetModel) EXPECT() *MockPasswordResetModelMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method
+func (m *MockPasswordResetModel) Create(arg0 domain.UserID, arg1 string, arg2 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create
+func (mr *MockPasswordResetModelMockRecorder) Create(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockPasswordResetModel)(nil).Create), arg0, arg1, arg2)
+}
+
+// DeleteExpired mocks base method
+func (m *MockPasswordResetModel) DeleteExpired() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpired")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpired indicates an expected call of DeleteExpired
+func (mr *MockPasswordResetModelMockRecorder) DeleteExpired() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpired", reflect.TypeOf((*MockPasswordResetModel)(nil).DeleteExpired))
+}
+
+// DeleteForUser mocks base method
+func (m *MockPasswordResetModel) DeleteForUser(arg0 domain.UserID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteForUser", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteForUser indicates an expected call of DeleteForUser
+func (mr *MockPasswordResetModelMockRecorder) DeleteForUser(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteForUser", reflect.TypeOf((*MockPasswordResetModel)(nil).DeleteForUser), arg0)
+}
+
+// Get mocks base method
+func (m *MockPasswordResetModel) Get(arg0 string) (domain.PasswordReset, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", arg0)
+	ret0, _ := ret[0].(domain.PasswordReset)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get
+func (mr *MockPasswordResetModelMockRecorder) Get(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockPasswordResetModel)(nil).Get), arg0)
+}
+
 // MockAppFilesModel is a mock of AppFilesModel interface
 type MockAppFilesModel struct {
 	ctrl     *gomock.Controller
@@ -588,6 +770,21 @@ func (mr *MockAppFilesModelMockRecorder) ExtractPackage(arg0 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExtractPackage", reflect.TypeOf((*MockAppFilesModel)(nil).ExtractPackage), arg0)
 }
 
+// OpenPackage mocks base method
+func (m *MockAppFilesModel) OpenPackage(arg0 string) (*os.File, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OpenPackage", arg0)
+	ret0, _ := ret[0].(*os.File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OpenPackage indicates an expected call of OpenPackage
+func (mr *MockAppFilesModelMockRecorder) OpenPackage(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenPackage", reflect.TypeOf((*MockAppFilesModel)(nil).OpenPackage), arg0)
+}
+
 // ReadManifest mocks base method
 func (m *MockAppFilesModel) ReadManifest(arg0 string) (*domain.AppVersionManifest, error) {
 	m.ctrl.T.Helper()
@@ -849,6 +1046,21 @@ func (mr *MockAppModelMockRecorder) GetVersionForUI(arg0, arg1 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVersionForUI", reflect.TypeOf((*MockAppModel)(nil).GetVersionForUI), arg0, arg1)
 }
 
+// GetVersionManifest mocks base method
+func (m *MockAppModel) GetVersionManifest(arg0 domain.AppID, arg1 domain.Version) (domain.AppVersionManifest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVersionManifest", arg0, arg1)
+	ret0, _ := ret[0].(domain.AppVersionManifest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVersionManifest indicates an expected call of GetVersionManifest
+func (mr *MockAppModelMockRecorder) GetVersionManifest(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVersionManifest", reflect.TypeOf((*MockAppModel)(nil).GetVersionManifest), arg0, arg1)
+}
+
 // GetVersionsForApp mocks base method
 func (m *MockAppModel) GetVersionsForApp(arg0 domain.AppID) ([]*domain.AppVersion, error) {
 	m.ctrl.T.Helper()
@@ -1105,6 +1317,20 @@ func (mr *MockAppspaceModelMockRecorder) Pause(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pause", reflect.TypeOf((*MockAppspaceModel)(nil).Pause), arg0, arg1)
 }
 
+// SetRedirect mocks base method
+func (m *MockAppspaceModel) SetRedirect(arg0 domain.AppspaceID, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRedirect", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetRedirect indicates an expected call of SetRedirect
+func (mr *MockAppspaceModelMockRecorder) SetRedirect(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRedirect", reflect.TypeOf((*MockAppspaceModel)(nil).SetRedirect), arg0, arg1)
+}
+
 // SetVersion mocks base method
 func (m *MockAppspaceModel) SetVersion(arg0 domain.AppspaceID, arg1 domain.Version) error {
 	m.ctrl.T.Helper()
@@ -1252,6 +1478,35 @@ func (mr *MockAppspaceFilesModelMockRecorder) CreateLocation() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLocation", reflect.TypeOf((*MockAppspaceFilesModel)(nil).CreateLocation))
 }
 
+// DeleteBackup mocks base method
+func (m *MockAppspaceFilesModel) DeleteBackup(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBackup", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteBackup indicates an expected call of DeleteBackup
+func (mr *MockAppspaceFilesModelMockRecorder) DeleteBackup(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBackup", reflect.TypeOf((*MockAppspaceFilesModel)(nil).DeleteBackup), arg0, arg1)
+}
+
+// GetBackups mocks base method
+func (m *MockAppspaceFilesModel) GetBackups(arg0 string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBackups", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBackups indicates an expected call of GetBackups
+func (mr *MockAppspaceFilesModelMockRecorder) GetBackups(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBackups", reflect.TypeOf((*MockAppspaceFilesModel)(nil).GetBackups), arg0)
+}
+
 // ReplaceData mocks base method
 func (m *MockAppspaceFilesModel) ReplaceData(arg0 domain.Appspace, arg1 string) error {
 	m.ctrl.T.Helper()
@@ -1266,6 +1521,20 @@ func (mr *MockAppspaceFilesModelMockRecorder) ReplaceData(arg0, arg1 interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceData", reflect.TypeOf((*MockAppspaceFilesModel)(nil).ReplaceData), arg0, arg1)
 }
 
+// WriteBackupZip mocks base method
+func (m *MockAppspaceFilesModel) WriteBackupZip(arg0, arg1 string, arg2 io.Writer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteBackupZip", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteBackupZip indicates an expected call of WriteBackupZip
+func (mr *MockAppspaceFilesModelMockRecorder) WriteBackupZip(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteBackupZip", reflect.TypeOf((*MockAppspaceFilesModel)(nil).WriteBackupZip), arg0, arg1, arg2)
+}
+
 // MockAppspaceTSNetModel is a mock of AppspaceTSNetModel interface
 type MockAppspaceTSNetModel struct {
 	ctrl     *gomock.Controller
@@ -1718,6 +1987,21 @@ func (m *MockSandboxRuns) EXPECT() *MockSandboxRunsMockRecorder {
 	return m.recorder
 }
 
+// AppspaceSums mocks base method
+func (m *MockSandboxRuns) AppspaceSums(arg0 domain.UserID, arg1 domain.AppspaceID, arg2, arg3 time.Time) (domain.SandboxRunData, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AppspaceSums", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(domain.SandboxRunData)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AppspaceSums indicates an expected call of AppspaceSums
+func (mr *MockSandboxRunsMockRecorder) AppspaceSums(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppspaceSums", reflect.TypeOf((*MockSandboxRuns)(nil).AppspaceSums), arg0, arg1, arg2, arg3)
+}
+
 // Create mocks base method
 func (m *MockSandboxRuns) Create(arg0 domain.SandboxRunIDs, arg1 time.Time) (int, error) {
 	m.ctrl.T.Helper()
@@ -1733,6 +2017,21 @@ func (mr *MockSandboxRunsMockRecorder) Create(arg0, arg1 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSandboxRuns)(nil).Create), arg0, arg1)
 }
 
+// DeleteBefore mocks base method
+func (m *MockSandboxRuns) DeleteBefore(arg0 time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBefore", arg0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteBefore indicates an expected call of DeleteBefore
+func (mr *MockSandboxRunsMockRecorder) DeleteBefore(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBefore", reflect.TypeOf((*MockSandboxRuns)(nil).DeleteBefore), arg0)
+}
+
 // End mocks base method
 func (m *MockSandboxRuns) End(arg0 int, arg1 time.Time, arg2 domain.SandboxRunData) error {
 	m.ctrl.T.Helper()
@@ -1746,3 +2045,717 @@ func (mr *MockSandboxRunsMockRecorder) End(arg0, arg1, arg2 interface{}) *gomock
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "End", reflect.TypeOf((*MockSandboxRuns)(nil).End), arg0, arg1, arg2)
 }
+
+// FirstStart mocks base method
+func (m *MockSandboxRuns) FirstStart() (time.Time, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FirstStart")
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// FirstStart indicates an expected call of FirstStart
+func (mr *MockSandboxRunsMockRecorder) FirstStart() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FirstStart", reflect.TypeOf((*MockSandboxRuns)(nil).FirstStart))
+}
+
+// MockBackupScheduleModel is a mock of BackupScheduleModel interface
+type MockBackupScheduleModel struct {
+	ctrl     *gomock.Controller
+	recorder *MockBackupScheduleModelMockRecorder
+}
+
+// MockBackupScheduleModelMockRecorder is the mock recorder for MockBackupScheduleModel
+type MockBackupScheduleModelMockRecorder struct {
+	mock *MockBackupScheduleModel
+}
+
+// NewMockBackupScheduleModel creates a new mock instance
+func NewMockBackupScheduleModel(ctrl *gomock.Controller) *MockBackupScheduleModel {
+	mock := &MockBackupScheduleModel{ctrl: ctrl}
+	mock.recorder = &MockBackupScheduleModelMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockBackupScheduleModel) EXPECT() *MockBackupScheduleModelMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method
+func (m *MockBackupScheduleModel) Delete(arg0 domain.AppspaceID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockBackupScheduleModelMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockBackupScheduleModel)(nil).Delete), arg0)
+}
+
+// Get mocks base method
+func (m *MockBackupScheduleModel) Get(arg0 domain.AppspaceID) (domain.BackupSchedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", arg0)
+	ret0, _ := ret[0].(domain.BackupSchedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get
+func (mr *MockBackupScheduleModelMockRecorder) Get(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockBackupScheduleModel)(nil).Get), arg0)
+}
+
+// GetAllEnabled mocks base method
+func (m *MockBackupScheduleModel) GetAllEnabled() ([]domain.BackupSchedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllEnabled")
+	ret0, _ := ret[0].([]domain.BackupSchedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllEnabled indicates an expected call of GetAllEnabled
+func (mr *MockBackupScheduleModelMockRecorder) GetAllEnabled() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllEnabled", reflect.TypeOf((*MockBackupScheduleModel)(nil).GetAllEnabled))
+}
+
+// Set mocks base method
+func (m *MockBackupScheduleModel) Set(arg0 domain.BackupSchedule) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Set", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Set indicates an expected call of Set
+func (mr *MockBackupScheduleModelMockRecorder) Set(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockBackupScheduleModel)(nil).Set), arg0)
+}
+
+// SetLastRun mocks base method
+func (m *MockBackupScheduleModel) SetLastRun(arg0 domain.AppspaceID, arg1 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetLastRun", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetLastRun indicates an expected call of SetLastRun
+func (mr *MockBackupScheduleModelMockRecorder) SetLastRun(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLastRun", reflect.TypeOf((*MockBackupScheduleModel)(nil).SetLastRun), arg0, arg1)
+}
+
+// MockBackupKeyModel is a mock of BackupKeyModel interface
+type MockBackupKeyModel struct {
+	ctrl     *gomock.Controller
+	recorder *MockBackupKeyModelMockRecorder
+}
+
+// MockBackupKeyModelMockRecorder is the mock recorder for MockBackupKeyModel
+type MockBackupKeyModelMockRecorder struct {
+	mock *MockBackupKeyModel
+}
+
+// NewMockBackupKeyModel creates a new mock instance
+func NewMockBackupKeyModel(ctrl *gomock.Controller) *MockBackupKeyModel {
+	mock := &MockBackupKeyModel{ctrl: ctrl}
+	mock.recorder = &MockBackupKeyModelMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockBackupKeyModel) EXPECT() *MockBackupKeyModelMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method
+func (m *MockBackupKeyModel) Delete(arg0 domain.AppspaceID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockBackupKeyModelMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockBackupKeyModel)(nil).Delete), arg0)
+}
+
+// Get mocks base method
+func (m *MockBackupKeyModel) Get(arg0 domain.AppspaceID) (domain.BackupKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", arg0)
+	ret0, _ := ret[0].(domain.BackupKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get
+func (mr *MockBackupKeyModelMockRecorder) Get(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockBackupKeyModel)(nil).Get), arg0)
+}
+
+// GetRecipient mocks base method
+func (m *MockBackupKeyModel) GetRecipient(arg0 domain.AppspaceID) (backupcrypt.Recipient, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRecipient", arg0)
+	ret0, _ := ret[0].(backupcrypt.Recipient)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetRecipient indicates an expected call of GetRecipient
+func (mr *MockBackupKeyModelMockRecorder) GetRecipient(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRecipient", reflect.TypeOf((*MockBackupKeyModel)(nil).GetRecipient), arg0)
+}
+
+// Set mocks base method
+func (m *MockBackupKeyModel) Set(arg0 domain.AppspaceID, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Set", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Set indicates an expected call of Set
+func (mr *MockBackupKeyModelMockRecorder) Set(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockBackupKeyModel)(nil).Set), arg0, arg1)
+}
+
+// MockAppspaceQuotaModel is a mock of AppspaceQuotaModel interface
+type MockAppspaceQuotaModel struct {
+	ctrl     *gomock.Controller
+	recorder *MockAppspaceQuotaModelMockRecorder
+}
+
+// MockAppspaceQuotaModelMockRecorder is the mock recorder for MockAppspaceQuotaModel
+type MockAppspaceQuotaModelMockRecorder struct {
+	mock *MockAppspaceQuotaModel
+}
+
+// NewMockAppspaceQuotaModel creates a new mock instance
+func NewMockAppspaceQuotaModel(ctrl *gomock.Controller) *MockAppspaceQuotaModel {
+	mock := &MockAppspaceQuotaModel{ctrl: ctrl}
+	mock.recorder = &MockAppspaceQuotaModelMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockAppspaceQuotaModel) EXPECT() *MockAppspaceQuotaModelMockRecorder {
+	return m.recorder
+}
+
+// ClearHits mocks base method
+func (m *MockAppspaceQuotaModel) ClearHits(arg0 domain.AppspaceID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClearHits", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ClearHits indicates an expected call of ClearHits
+func (mr *MockAppspaceQuotaModelMockRecorder) ClearHits(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClearHits", reflect.TypeOf((*MockAppspaceQuotaModel)(nil).ClearHits), arg0)
+}
+
+// Delete mocks base method
+func (m *MockAppspaceQuotaModel) Delete(arg0 domain.AppspaceID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockAppspaceQuotaModelMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockAppspaceQuotaModel)(nil).Delete), arg0)
+}
+
+// Get mocks base method
+func (m *MockAppspaceQuotaModel) Get(arg0 domain.AppspaceID, arg1 string) (domain.AppspaceQuota, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", arg0, arg1)
+	ret0, _ := ret[0].(domain.AppspaceQuota)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get
+func (mr *MockAppspaceQuotaModelMockRecorder) Get(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockAppspaceQuotaModel)(nil).Get), arg0, arg1)
+}
+
+// GetAllHits mocks base method
+func (m *MockAppspaceQuotaModel) GetAllHits() ([]domain.AppspaceLimitHit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllHits")
+	ret0, _ := ret[0].([]domain.AppspaceLimitHit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllHits indicates an expected call of GetAllHits
+func (mr *MockAppspaceQuotaModelMockRecorder) GetAllHits() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllHits", reflect.TypeOf((*MockAppspaceQuotaModel)(nil).GetAllHits))
+}
+
+// GetEffective mocks base method
+func (m *MockAppspaceQuotaModel) GetEffective(arg0 domain.AppspaceID) (domain.AppspaceQuota, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEffective", arg0)
+	ret0, _ := ret[0].(domain.AppspaceQuota)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEffective indicates an expected call of GetEffective
+func (mr *MockAppspaceQuotaModelMockRecorder) GetEffective(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEffective", reflect.TypeOf((*MockAppspaceQuotaModel)(nil).GetEffective), arg0)
+}
+
+// GetHits mocks base method
+func (m *MockAppspaceQuotaModel) GetHits(arg0 domain.AppspaceID) ([]domain.AppspaceLimitHit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHits", arg0)
+	ret0, _ := ret[0].([]domain.AppspaceLimitHit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHits indicates an expected call of GetHits
+func (mr *MockAppspaceQuotaModelMockRecorder) GetHits(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHits", reflect.TypeOf((*MockAppspaceQuotaModel)(nil).GetHits), arg0)
+}
+
+// RecordHit mocks base method
+func (m *MockAppspaceQuotaModel) RecordHit(arg0 domain.AppspaceID, arg1 string, arg2 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordHit", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordHit indicates an expected call of RecordHit
+func (mr *MockAppspaceQuotaModelMockRecorder) RecordHit(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordHit", reflect.TypeOf((*MockAppspaceQuotaModel)(nil).RecordHit), arg0, arg1, arg2)
+}
+
+// Set mocks base method
+func (m *MockAppspaceQuotaModel) Set(arg0 domain.AppspaceID, arg1 string, arg2 domain.AppspaceQuota) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Set", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Set indicates an expected call of Set
+func (mr *MockAppspaceQuotaModelMockRecorder) Set(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockAppspaceQuotaModel)(nil).Set), arg0, arg1, arg2)
+}
+
+// MockUsageModel is a mock of UsageModel interface
+type MockUsageModel struct {
+	ctrl     *gomock.Controller
+	recorder *MockUsageModelMockRecorder
+}
+
+// MockUsageModelMockRecorder is the mock recorder for MockUsageModel
+type MockUsageModelMockRecorder struct {
+	mock *MockUsageModel
+}
+
+// NewMockUsageModel creates a new mock instance
+func NewMockUsageModel(ctrl *gomock.Controller) *MockUsageModel {
+	mock := &MockUsageModel{ctrl: ctrl}
+	mock.recorder = &MockUsageModelMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockUsageModel) EXPECT() *MockUsageModelMockRecorder {
+	return m.recorder
+}
+
+// DeleteBefore mocks base method
+func (m *MockUsageModel) DeleteBefore(arg0 string, arg1 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBefore", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteBefore indicates an expected call of DeleteBefore
+func (mr *MockUsageModelMockRecorder) DeleteBefore(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBefore", reflect.TypeOf((*MockUsageModel)(nil).DeleteBefore), arg0, arg1)
+}
+
+// GetForAppspace mocks base method
+func (m *MockUsageModel) GetForAppspace(arg0 domain.AppspaceID, arg1 string, arg2, arg3 time.Time) ([]domain.UsageRollup, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetForAppspace", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]domain.UsageRollup)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetForAppspace indicates an expected call of GetForAppspace
+func (mr *MockUsageModelMockRecorder) GetForAppspace(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetForAppspace", reflect.TypeOf((*MockUsageModel)(nil).GetForAppspace), arg0, arg1, arg2, arg3)
+}
+
+// GetForOwner mocks base method
+func (m *MockUsageModel) GetForOwner(arg0 domain.UserID, arg1 string, arg2, arg3 time.Time) ([]domain.UsageRollup, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetForOwner", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]domain.UsageRollup)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetForOwner indicates an expected call of GetForOwner
+func (mr *MockUsageModelMockRecorder) GetForOwner(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetForOwner", reflect.TypeOf((*MockUsageModel)(nil).GetForOwner), arg0, arg1, arg2, arg3)
+}
+
+// GetUserTotals mocks base method
+func (m *MockUsageModel) GetUserTotals(arg0 string, arg1, arg2 time.Time) ([]domain.UsageRollup, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserTotals", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]domain.UsageRollup)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserTotals indicates an expected call of GetUserTotals
+func (mr *MockUsageModelMockRecorder) GetUserTotals(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserTotals", reflect.TypeOf((*MockUsageModel)(nil).GetUserTotals), arg0, arg1, arg2)
+}
+
+// LastDay mocks base method
+func (m *MockUsageModel) LastDay() (time.Time, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LastDay")
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// LastDay indicates an expected call of LastDay
+func (mr *MockUsageModelMockRecorder) LastDay() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastDay", reflect.TypeOf((*MockUsageModel)(nil).LastDay))
+}
+
+// RollupDay mocks base method
+func (m *MockUsageModel) RollupDay(arg0 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RollupDay", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RollupDay indicates an expected call of RollupDay
+func (mr *MockUsageModelMockRecorder) RollupDay(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RollupDay", reflect.TypeOf((*MockUsageModel)(nil).RollupDay), arg0)
+}
+
+// RollupPeriod mocks base method
+func (m *MockUsageModel) RollupPeriod(arg0 string, arg1, arg2 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RollupPeriod", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RollupPeriod indicates an expected call of RollupPeriod
+func (mr *MockUsageModelMockRecorder) RollupPeriod(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RollupPeriod", reflect.TypeOf((*MockUsageModel)(nil).RollupPeriod), arg0, arg1, arg2)
+}
+
+// MockAppspaceLogModel is a mock of AppspaceLogModel interface
+type MockAppspaceLogModel struct {
+	ctrl     *gomock.Controller
+	recorder *MockAppspaceLogModelMockRecorder
+}
+
+// MockAppspaceLogModelMockRecorder is the mock recorder for MockAppspaceLogModel
+type MockAppspaceLogModelMockRecorder struct {
+	mock *MockAppspaceLogModel
+}
+
+// NewMockAppspaceLogModel creates a new mock instance
+func NewMockAppspaceLogModel(ctrl *gomock.Controller) *MockAppspaceLogModel {
+	mock := &MockAppspaceLogModel{ctrl: ctrl}
+	mock.recorder = &MockAppspaceLogModelMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockAppspaceLogModel) EXPECT() *MockAppspaceLogModelMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method
+func (m *MockAppspaceLogModel) Delete(arg0 domain.AppspaceID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockAppspaceLogModelMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockAppspaceLogModel)(nil).Delete), arg0)
+}
+
+// GetRetention mocks base method
+func (m *MockAppspaceLogModel) GetRetention(arg0 domain.AppspaceID) (domain.LogRetention, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRetention", arg0)
+	ret0, _ := ret[0].(domain.LogRetention)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRetention indicates an expected call of GetRetention
+func (mr *MockAppspaceLogModelMockRecorder) GetRetention(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRetention", reflect.TypeOf((*MockAppspaceLogModel)(nil).GetRetention), arg0)
+}
+
+// SetRetention mocks base method
+func (m *MockAppspaceLogModel) SetRetention(arg0 domain.AppspaceID, arg1 domain.LogRetention) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRetention", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetRetention indicates an expected call of SetRetention
+func (mr *MockAppspaceLogModelMockRecorder) SetRetention(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRetention", reflect.TypeOf((*MockAppspaceLogModel)(nil).SetRetention), arg0, arg1)
+}
+
+// MockAccessLogModel is a mock of AccessLogModel interface
+type MockAccessLogModel struct {
+	ctrl     *gomock.Controller
+	recorder *MockAccessLogModelMockRecorder
+}
+
+// MockAccessLogModelMockRecorder is the mock recorder for MockAccessLogModel
+type MockAccessLogModelMockRecorder struct {
+	mock *MockAccessLogModel
+}
+
+// NewMockAccessLogModel creates a new mock instance
+func NewMockAccessLogModel(ctrl *gomock.Controller) *MockAccessLogModel {
+	mock := &MockAccessLogModel{ctrl: ctrl}
+	mock.recorder = &MockAccessLogModelMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockAccessLogModel) EXPECT() *MockAccessLogModelMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method
+func (m *MockAccessLogModel) Delete(arg0 domain.AppspaceID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockAccessLogModelMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockAccessLogModel)(nil).Delete), arg0)
+}
+
+// DeleteExpired mocks base method
+func (m *MockAccessLogModel) DeleteExpired(arg0 time.Time, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpired", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpired indicates an expected call of DeleteExpired
+func (mr *MockAccessLogModelMockRecorder) DeleteExpired(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpired", reflect.TypeOf((*MockAccessLogModel)(nil).DeleteExpired), arg0, arg1)
+}
+
+// GetSettings mocks base method
+func (m *MockAccessLogModel) GetSettings(arg0 domain.AppspaceID) (domain.AccessLogSettings, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSettings", arg0)
+	ret0, _ := ret[0].(domain.AccessLogSettings)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSettings indicates an expected call of GetSettings
+func (mr *MockAccessLogModelMockRecorder) GetSettings(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSettings", reflect.TypeOf((*MockAccessLogModel)(nil).GetSettings), arg0)
+}
+
+// Insert mocks base method
+func (m *MockAccessLogModel) Insert(arg0 []domain.AccessLogEntry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Insert", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Insert indicates an expected call of Insert
+func (mr *MockAccessLogModelMockRecorder) Insert(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockAccessLogModel)(nil).Insert), arg0)
+}
+
+// Query mocks base method
+func (m *MockAccessLogModel) Query(arg0 domain.AppspaceID, arg1 domain.AccessLogQuery) ([]domain.AccessLogEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Query", arg0, arg1)
+	ret0, _ := ret[0].([]domain.AccessLogEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Query indicates an expected call of Query
+func (mr *MockAccessLogModelMockRecorder) Query(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*MockAccessLogModel)(nil).Query), arg0, arg1)
+}
+
+// SetSettings mocks base method
+func (m *MockAccessLogModel) SetSettings(arg0 domain.AppspaceID, arg1 domain.AccessLogSettings) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSettings", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetSettings indicates an expected call of SetSettings
+func (mr *MockAccessLogModelMockRecorder) SetSettings(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSettings", reflect.TypeOf((*MockAccessLogModel)(nil).SetSettings), arg0, arg1)
+}
+
+// Stats mocks base method
+func (m *MockAccessLogModel) Stats(arg0 domain.AppspaceID, arg1, arg2 time.Time) (domain.AccessLogStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats", arg0, arg1, arg2)
+	ret0, _ := ret[0].(domain.AccessLogStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Stats indicates an expected call of Stats
+func (mr *MockAccessLogModelMockRecorder) Stats(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockAccessLogModel)(nil).Stats), arg0, arg1, arg2)
+}
+
+// MockAppspaceOutboundModel is a mock of AppspaceOutboundModel interface
+type MockAppspaceOutboundModel struct {
+	ctrl     *gomock.Controller
+	recorder *MockAppspaceOutboundModelMockRecorder
+}
+
+// MockAppspaceOutboundModelMockRecorder is the mock recorder for MockAppspaceOutboundModel
+type MockAppspaceOutboundModelMockRecorder struct {
+	mock *MockAppspaceOutboundModel
+}
+
+// NewMockAppspaceOutboundModel creates a new mock instance
+func NewMockAppspaceOutboundModel(ctrl *gomock.Controller) *MockAppspaceOutboundModel {
+	mock := &MockAppspaceOutboundModel{ctrl: ctrl}
+	mock.recorder = &MockAppspaceOutboundModelMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockAppspaceOutboundModel) EXPECT() *MockAppspaceOutboundModelMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method
+func (m *MockAppspaceOutboundModel) Delete(arg0 domain.AppspaceID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockAppspaceOutboundModelMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockAppspaceOutboundModel)(nil).Delete), arg0)
+}
+
+// Get mocks base method
+func (m *MockAppspaceOutboundModel) Get(arg0 domain.AppspaceID) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get
+func (mr *MockAppspaceOutboundModelMockRecorder) Get(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockAppspaceOutboundModel)(nil).Get), arg0)
+}
+
+// Set mocks base method
+func (m *MockAppspaceOutboundModel) Set(arg0 domain.AppspaceID, arg1 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Set", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Set indicates an expected call of Set
+func (mr *MockAppspaceOutboundModelMockRecorder) Set(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockAppspaceOutboundModel)(nil).Set), arg0, arg1)
+}
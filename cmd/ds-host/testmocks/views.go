@@ -14,4 +14,7 @@ type Views interface {
 	GetStaticFS() fs.FS
 	Login(http.ResponseWriter, domain.LoginViewData)
 	Signup(http.ResponseWriter, domain.SignupViewData)
+	SecondFactor(http.ResponseWriter, domain.SecondFactorViewData)
+	SetupTwoFactor(http.ResponseWriter, domain.SetupTwoFactorViewData)
+	ResetPassword(http.ResponseWriter, domain.ResetPasswordViewData)
 }
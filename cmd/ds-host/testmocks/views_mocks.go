@@ -73,6 +73,42 @@ func (mr *MockViewsMockRecorder) PrepareTemplates() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PrepareTemplates", reflect.TypeOf((*MockViews)(nil).PrepareTemplates))
 }
 
+// ResetPassword mocks base method
+func (m *MockViews) ResetPassword(arg0 http.ResponseWriter, arg1 domain.ResetPasswordViewData) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ResetPassword", arg0, arg1)
+}
+
+// ResetPassword indicates an expected call of ResetPassword
+func (mr *MockViewsMockRecorder) ResetPassword(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockViews)(nil).ResetPassword), arg0, arg1)
+}
+
+// SecondFactor mocks base method
+func (m *MockViews) SecondFactor(arg0 http.ResponseWriter, arg1 domain.SecondFactorViewData) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SecondFactor", arg0, arg1)
+}
+
+// SecondFactor indicates an expected call of SecondFactor
+func (mr *MockViewsMockRecorder) SecondFactor(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SecondFactor", reflect.TypeOf((*MockViews)(nil).SecondFactor), arg0, arg1)
+}
+
+// SetupTwoFactor mocks base method
+func (m *MockViews) SetupTwoFactor(arg0 http.ResponseWriter, arg1 domain.SetupTwoFactorViewData) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetupTwoFactor", arg0, arg1)
+}
+
+// SetupTwoFactor indicates an expected call of SetupTwoFactor
+func (mr *MockViewsMockRecorder) SetupTwoFactor(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetupTwoFactor", reflect.TypeOf((*MockViews)(nil).SetupTwoFactor), arg0, arg1)
+}
+
 // Signup mocks base method
 func (m *MockViews) Signup(arg0 http.ResponseWriter, arg1 domain.SignupViewData) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,63 @@
+package twofactor
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B, SHA1, truncated to 6 digits
+func TestTOTPCode(t *testing.T) {
+	key := []byte("12345678901234567890")
+	cases := []struct {
+		time int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, c := range cases {
+		code := totpCode(key, c.time/totpPeriod)
+		if code != c.code {
+			t.Errorf("time %v: expected %v, got %v", c.time, c.code, code)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	secret := b32.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111111, 0)
+
+	step, ok := matchTOTP(secret, "050471", now)
+	if !ok || step != 1111111111/totpPeriod {
+		t.Errorf("expected match, got %v %v", step, ok)
+	}
+	// previous step is accepted
+	_, ok = matchTOTP(strings.ToLower(secret), "081804", now)
+	if !ok {
+		t.Error("expected match of previous step")
+	}
+	_, ok = matchTOTP(secret, "050471", now.Add(3*totpPeriod*time.Second))
+	if ok {
+		t.Error("expected no match three steps later")
+	}
+	_, ok = matchTOTP(secret, "050 471", now)
+	if !ok {
+		t.Error("expected spaces to be ignored")
+	}
+	_, ok = matchTOTP(secret, "50471", now)
+	if ok {
+		t.Error("expected short code not to match")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := totpURI("ds.example.com", "me@example.com", "ABC")
+	expected := "otpauth://totp/ds.example.com:me@example.com?algorithm=SHA1&digits=6&issuer=ds.example.com&period=30&secret=ABC"
+	if uri != expected {
+		t.Errorf("unexpected uri %v", uri)
+	}
+}
@@ -0,0 +1,602 @@
+package twofactor
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+)
+
+const numRecoveryCodes = 10
+
+const challengeTTL = 5 * time.Minute
+
+// maxChallenges bounds the memory used by outstanding challenges.
+// Only users who are signed in or who passed the password step can create these.
+const maxChallenges = 1000
+
+// challenge purposes
+const (
+	purposeRegister = "register"
+	purposeLogin    = "login"
+)
+
+type challenge struct {
+	userID  domain.UserID // zero for passwordless login
+	purpose string
+	expires time.Time
+}
+
+// TwoFactor manages users' second factors:
+// TOTP, recovery codes and passkeys.
+type TwoFactor struct {
+	Config        *domain.RuntimeConfig `checkinject:"required"`
+	SettingsModel interface {
+		Get() (domain.Settings, error)
+	} `checkinject:"required"`
+	UserModel interface {
+		GetFromID(userID domain.UserID) (domain.User, error)
+	} `checkinject:"required"`
+	TwoFactorModel interface {
+		GetTOTP(userID domain.UserID) (domain.UserTOTP, error)
+		SetTOTP(userID domain.UserID, secret string) error
+		EnableTOTP(userID domain.UserID) error
+		SetTOTPStep(userID domain.UserID, step int64) (bool, error)
+		DeleteTOTP(userID domain.UserID) error
+		SetRecoveryCodes(userID domain.UserID, hashes []string) error
+		UseRecoveryCode(userID domain.UserID, hash string) (bool, error)
+		CountRecoveryCodes(userID domain.UserID) (int, error)
+		CreatePasskey(passkey domain.Passkey) error
+		GetPasskey(credentialID string) (domain.Passkey, error)
+		GetUserPasskeys(userID domain.UserID) ([]domain.Passkey, error)
+		UpdatePasskeyUse(credentialID string, signCount uint32) error
+		DeletePasskey(userID domain.UserID, credentialID string) error
+	} `checkinject:"required"`
+
+	now func() time.Time
+
+	challengeMux sync.Mutex
+	challenges   map[string]challenge
+
+	// Passwordless login challenges can be requested by anyone,
+	// so they are signed instead of stored. Only the ones that
+	// were used are remembered, until they expire.
+	anonKey  []byte
+	anonUsed map[string]time.Time
+}
+
+func (t *TwoFactor) getNow() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
+
+// Required returns true if the instance requires users to have a second factor
+func (t *TwoFactor) Required() (bool, error) {
+	settings, err := t.SettingsModel.Get()
+	if err != nil {
+		return false, err
+	}
+	return settings.Require2FA, nil
+}
+
+// Status returns the user's second factors
+func (t *TwoFactor) Status(userID domain.UserID) (domain.TwoFactorStatus, error) {
+	ret := domain.TwoFactorStatus{}
+	var err error
+	ret.TOTP, err = t.hasTOTP(userID)
+	if err != nil {
+		return ret, err
+	}
+	ret.RecoveryCodes, err = t.TwoFactorModel.CountRecoveryCodes(userID)
+	if err != nil {
+		return ret, err
+	}
+	ret.Passkeys, err = t.TwoFactorModel.GetUserPasskeys(userID)
+	if err != nil {
+		return ret, err
+	}
+	ret.Required, err = t.Required()
+	return ret, err
+}
+
+// Has returns true if the user has set up any second factor
+func (t *TwoFactor) Has(userID domain.UserID) (bool, error) {
+	status, err := t.Status(userID)
+	if err != nil {
+		return false, err
+	}
+	return status.TOTP || len(status.Passkeys) != 0, nil
+}
+
+func (t *TwoFactor) hasTOTP(userID domain.UserID) (bool, error) {
+	totp, err := t.TwoFactorModel.GetTOTP(userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return totp.Enabled, nil
+}
+
+// BeginTOTP creates a TOTP secret for the user.
+// It is not used until the user confirms a code with ConfirmTOTP.
+// An unconfirmed secret is returned again so that a user who already
+// added it to their app can retry the confirmation.
+func (t *TwoFactor) BeginTOTP(userID domain.UserID) (secret string, uri string, err error) {
+	totp, err := t.TwoFactorModel.GetTOTP(userID)
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+	if err == nil && totp.Enabled {
+		err = errors.New("bad input: TOTP is already enabled")
+		return
+	}
+	user, err := t.UserModel.GetFromID(userID)
+	if err != nil {
+		return
+	}
+	secret = totp.Secret
+	if secret == "" {
+		secret, err = newTOTPSecret()
+		if err != nil {
+			t.getLogger("BeginTOTP() newTOTPSecret()").Error(err)
+			return
+		}
+		err = t.TwoFactorModel.SetTOTP(userID, secret)
+		if err != nil {
+			return
+		}
+	}
+	account := user.Email
+	if account == "" {
+		account = fmt.Sprintf("user %v", userID)
+	}
+	uri = totpURI(t.Config.Exec.UserRoutesDomain, account, secret)
+	return
+}
+
+// ConfirmTOTP enables the user's TOTP secret if the code is valid.
+// If the user has no recovery codes left, new ones are created and returned.
+// It returns domain.ErrBadAuth if the code is invalid.
+func (t *TwoFactor) ConfirmTOTP(userID domain.UserID, code string) ([]string, error) {
+	totp, err := t.TwoFactorModel.GetTOTP(userID)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrBadAuth
+	}
+	if err != nil {
+		return nil, err
+	}
+	if totp.Enabled {
+		return nil, errors.New("bad input: TOTP is already enabled")
+	}
+	step, ok := matchTOTP(totp.Secret, code, t.getNow())
+	if !ok {
+		return nil, domain.ErrBadAuth
+	}
+	err = t.TwoFactorModel.EnableTOTP(userID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = t.TwoFactorModel.SetTOTPStep(userID, step)
+	if err != nil {
+		return nil, err
+	}
+	return t.ensureRecoveryCodes(userID)
+}
+
+// DisableTOTP removes the user's TOTP secret
+func (t *TwoFactor) DisableTOTP(userID domain.UserID) error {
+	status, err := t.Status(userID)
+	if err != nil {
+		return err
+	}
+	if status.Required && status.TOTP && len(status.Passkeys) == 0 {
+		return domain.ErrLastSecondFactor
+	}
+	return t.TwoFactorModel.DeleteTOTP(userID)
+}
+
+// CheckTOTP returns true if the code is valid and was not used before
+func (t *TwoFactor) CheckTOTP(userID domain.UserID, code string) (bool, error) {
+	totp, err := t.TwoFactorModel.GetTOTP(userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !totp.Enabled {
+		return false, nil
+	}
+	step, ok := matchTOTP(totp.Secret, code, t.getNow())
+	if !ok {
+		return false, nil
+	}
+	// codes can't be replayed, nor can earlier codes be used once a later one was
+	return t.TwoFactorModel.SetTOTPStep(userID, step)
+}
+
+// UseRecoveryCode returns true if the code is one of the user's unused codes.
+// The code can not be used again.
+func (t *TwoFactor) UseRecoveryCode(userID domain.UserID, code string) (bool, error) {
+	return t.TwoFactorModel.UseRecoveryCode(userID, hashRecoveryCode(code))
+}
+
+// NewRecoveryCodes replaces the user's recovery codes
+func (t *TwoFactor) NewRecoveryCodes(userID domain.UserID) ([]string, error) {
+	has, err := t.Has(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, errors.New("bad input: no second factor set up")
+	}
+	return t.newRecoveryCodes(userID)
+}
+
+func (t *TwoFactor) ensureRecoveryCodes(userID domain.UserID) ([]string, error) {
+	count, err := t.TwoFactorModel.CountRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	if count != 0 {
+		return nil, nil
+	}
+	return t.newRecoveryCodes(userID)
+}
+
+func (t *TwoFactor) newRecoveryCodes(userID domain.UserID) ([]string, error) {
+	codes := make([]string, numRecoveryCodes)
+	hashes := make([]string, numRecoveryCodes)
+	for i := range codes {
+		b := make([]byte, 10)
+		_, err := rand.Read(b)
+		if err != nil {
+			t.getLogger("newRecoveryCodes() rand.Read()").Error(err)
+			return nil, err
+		}
+		c := strings.ToLower(b32.EncodeToString(b))
+		codes[i] = c[:4] + "-" + c[4:8] + "-" + c[8:12] + "-" + c[12:]
+		hashes[i] = hashRecoveryCode(c)
+	}
+	err := t.TwoFactorModel.SetRecoveryCodes(userID, hashes)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode normalizes the code as the user might type it and hashes it.
+// Codes are random enough that a plain hash is fine.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	code = strings.ReplaceAll(code, " ", "")
+	h := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(h[:])
+}
+
+// BeginPasskeyRegistration returns the options for the browser
+// to create a new passkey for the user.
+func (t *TwoFactor) BeginPasskeyRegistration(userID domain.UserID) (domain.WebAuthnCreationOptions, error) {
+	opts := domain.WebAuthnCreationOptions{}
+	user, err := t.UserModel.GetFromID(userID)
+	if err != nil {
+		return opts, err
+	}
+	passkeys, err := t.TwoFactorModel.GetUserPasskeys(userID)
+	if err != nil {
+		return opts, err
+	}
+	opts.Challenge, err = t.newChallenge(userID, purposeRegister)
+	if err != nil {
+		return opts, err
+	}
+	opts.RP.ID = t.rpID()
+	opts.RP.Name = "Dropserver"
+	opts.User.ID = userHandle(userID)
+	opts.User.Name = user.Email
+	if opts.User.Name == "" {
+		opts.User.Name = fmt.Sprintf("user %v", userID)
+	}
+	opts.User.DisplayName = opts.User.Name
+	for _, alg := range []int{algES256, algEdDSA, algRS256} {
+		opts.PubKeyCredParams = append(opts.PubKeyCredParams, struct {
+			Type string `json:"type"`
+			Alg  int    `json:"alg"`
+		}{"public-key", alg})
+	}
+	opts.Timeout = int(challengeTTL.Milliseconds())
+	opts.ExcludeCredentials = credentialList(passkeys)
+	opts.AuthenticatorSelection.ResidentKey = "preferred"
+	opts.AuthenticatorSelection.UserVerification = "preferred"
+	opts.Attestation = "none"
+	return opts, nil
+}
+
+// FinishPasskeyRegistration verifies the browser's response
+// and stores the new passkey.
+func (t *TwoFactor) FinishPasskeyRegistration(userID domain.UserID, name string, data []byte) (domain.Passkey, error) {
+	var c credentialCreation
+	err := json.Unmarshal(data, &c)
+	if err != nil {
+		return domain.Passkey{}, errors.New("bad input: invalid credential")
+	}
+	chal, ad, err := verifyRegistration(c, t.rpID(), t.origin())
+	if err != nil {
+		t.getLogger("FinishPasskeyRegistration() verifyRegistration()").UserID(userID).Log(err.Error())
+		return domain.Passkey{}, domain.ErrBadAuth
+	}
+	if !t.takeChallenge(chal, purposeRegister, userID) {
+		return domain.Passkey{}, domain.ErrBadAuth
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	passkey := domain.Passkey{
+		CredentialID: b64.EncodeToString(ad.credentialID),
+		UserID:       userID,
+		Name:         name,
+		PublicKey:    ad.publicKey,
+		SignCount:    ad.signCount}
+	err = t.TwoFactorModel.CreatePasskey(passkey)
+	if err != nil {
+		return domain.Passkey{}, err
+	}
+	return t.TwoFactorModel.GetPasskey(passkey.CredentialID)
+}
+
+// BeginPasskeyLogin returns the options for the browser to sign in with a passkey.
+// Pass a zero userID for a passwordless login with any of the user's passkeys.
+func (t *TwoFactor) BeginPasskeyLogin(userID domain.UserID) (domain.WebAuthnRequestOptions, error) {
+	opts := domain.WebAuthnRequestOptions{
+		RPID:             t.rpID(),
+		Timeout:          int(challengeTTL.Milliseconds()),
+		AllowCredentials: []domain.WebAuthnCredential{},
+		UserVerification: "required"}
+	if userID != 0 {
+		passkeys, err := t.TwoFactorModel.GetUserPasskeys(userID)
+		if err != nil {
+			return opts, err
+		}
+		if len(passkeys) == 0 {
+			return opts, errors.New("bad input: no passkeys")
+		}
+		opts.AllowCredentials = credentialList(passkeys)
+		opts.UserVerification = "preferred"
+	}
+	var err error
+	opts.Challenge, err = t.newChallenge(userID, purposeLogin)
+	return opts, err
+}
+
+// FinishPasskeyLogin verifies the browser's assertion and returns the user it belongs to.
+// The userID must match the one passed to BeginPasskeyLogin.
+// It returns domain.ErrBadAuth if the assertion is not valid.
+func (t *TwoFactor) FinishPasskeyLogin(userID domain.UserID, data []byte) (domain.UserID, error) {
+	log := t.getLogger("FinishPasskeyLogin()")
+	var a credentialAssertion
+	err := json.Unmarshal(data, &a)
+	if err != nil {
+		return 0, domain.ErrBadAuth
+	}
+	passkey, err := t.TwoFactorModel.GetPasskey(a.ID)
+	if err == sql.ErrNoRows {
+		return 0, domain.ErrBadAuth
+	}
+	if err != nil {
+		return 0, err
+	}
+	if userID != 0 && passkey.UserID != userID {
+		return 0, domain.ErrBadAuth
+	}
+	if a.Response.UserHandle != "" && a.Response.UserHandle != userHandle(passkey.UserID) {
+		return 0, domain.ErrBadAuth
+	}
+	chal, ad, err := verifyAssertion(a, passkey.PublicKey, t.rpID(), t.origin())
+	if err != nil {
+		log.UserID(passkey.UserID).Log(err.Error())
+		return 0, domain.ErrBadAuth
+	}
+	if !t.takeChallenge(chal, purposeLogin, userID) {
+		return 0, domain.ErrBadAuth
+	}
+	// Without a password the passkey has to verify the user (PIN, biometrics...)
+	if userID == 0 && ad.flags&flagUserVerified == 0 {
+		return 0, domain.ErrBadAuth
+	}
+	// A counter that does not increase suggests a cloned authenticator.
+	// Authenticators that don't implement counters always send zero.
+	if (ad.signCount != 0 || passkey.SignCount != 0) && ad.signCount <= passkey.SignCount {
+		log.UserID(passkey.UserID).AddNote(passkey.CredentialID).Log("sign counter did not increase")
+		return 0, domain.ErrBadAuth
+	}
+	err = t.TwoFactorModel.UpdatePasskeyUse(passkey.CredentialID, ad.signCount)
+	if err != nil {
+		return 0, err
+	}
+	return passkey.UserID, nil
+}
+
+// DeletePasskey removes the user's passkey
+func (t *TwoFactor) DeletePasskey(userID domain.UserID, credentialID string) error {
+	status, err := t.Status(userID)
+	if err != nil {
+		return err
+	}
+	if status.Required && !status.TOTP && len(status.Passkeys) == 1 {
+		return domain.ErrLastSecondFactor
+	}
+	return t.TwoFactorModel.DeletePasskey(userID, credentialID)
+}
+
+func (t *TwoFactor) rpID() string {
+	return t.Config.Exec.UserRoutesDomain
+}
+
+func (t *TwoFactor) origin() string {
+	return fmt.Sprintf("%s://%s%s", t.Config.ExternalAccess.Scheme, t.Config.Exec.UserRoutesDomain, t.Config.Exec.PortString)
+}
+
+func userHandle(userID domain.UserID) string {
+	return b64.EncodeToString([]byte(strconv.Itoa(int(userID))))
+}
+
+func credentialList(passkeys []domain.Passkey) []domain.WebAuthnCredential {
+	ret := make([]domain.WebAuthnCredential, len(passkeys))
+	for i, p := range passkeys {
+		ret[i] = domain.WebAuthnCredential{Type: "public-key", ID: p.CredentialID}
+	}
+	return ret
+}
+
+func (t *TwoFactor) newChallenge(userID domain.UserID, purpose string) (string, error) {
+	if userID == 0 {
+		return t.newAnonChallenge(purpose)
+	}
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		t.getLogger("newChallenge() rand.Read()").Error(err)
+		return "", err
+	}
+	c := b64.EncodeToString(b)
+
+	t.challengeMux.Lock()
+	defer t.challengeMux.Unlock()
+	if t.challenges == nil {
+		t.challenges = make(map[string]challenge)
+	}
+	now := t.getNow()
+	if len(t.challenges) >= maxChallenges {
+		for k, ch := range t.challenges {
+			if now.After(ch.expires) {
+				delete(t.challenges, k)
+			}
+		}
+	}
+	if len(t.challenges) >= maxChallenges {
+		return "", errors.New("too many outstanding challenges")
+	}
+	t.challenges[c] = challenge{
+		userID:  userID,
+		purpose: purpose,
+		expires: now.Add(challengeTTL)}
+	return c, nil
+}
+
+// takeChallenge returns true if the challenge was issued for the purpose and user.
+// A challenge can only be taken once.
+func (t *TwoFactor) takeChallenge(c string, purpose string, userID domain.UserID) bool {
+	if userID == 0 {
+		return t.takeAnonChallenge(c, purpose)
+	}
+	t.challengeMux.Lock()
+	defer t.challengeMux.Unlock()
+	ch, ok := t.challenges[c]
+	if !ok {
+		return false
+	}
+	delete(t.challenges, c)
+	return ch.purpose == purpose && ch.userID == userID && !t.getNow().After(ch.expires)
+}
+
+// newAnonChallenge returns a challenge made of random bytes,
+// its expiry and a MAC over both.
+func (t *TwoFactor) newAnonChallenge(purpose string) (string, error) {
+	key, err := t.getAnonKey()
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, 24, 24+sha256.Size)
+	_, err = rand.Read(b[:16])
+	if err != nil {
+		t.getLogger("newAnonChallenge() rand.Read()").Error(err)
+		return "", err
+	}
+	binary.BigEndian.PutUint64(b[16:], uint64(t.getNow().Add(challengeTTL).Unix()))
+	b = append(b, anonMAC(key, purpose, b)...)
+	return b64.EncodeToString(b), nil
+}
+
+// takeAnonChallenge returns true if the challenge was signed for the purpose
+// and is not expired. A challenge can only be taken once.
+func (t *TwoFactor) takeAnonChallenge(c string, purpose string) bool {
+	key, err := t.getAnonKey()
+	if err != nil {
+		return false
+	}
+	b, err := b64.DecodeString(c)
+	if err != nil || len(b) != 24+sha256.Size {
+		return false
+	}
+	if !hmac.Equal(b[24:], anonMAC(key, purpose, b[:24])) {
+		return false
+	}
+	expires := time.Unix(int64(binary.BigEndian.Uint64(b[16:24])), 0)
+
+	t.challengeMux.Lock()
+	defer t.challengeMux.Unlock()
+	now := t.getNow()
+	for k, exp := range t.anonUsed {
+		if now.After(exp) {
+			delete(t.anonUsed, k)
+		}
+	}
+	if now.After(expires) {
+		return false
+	}
+	if _, ok := t.anonUsed[c]; ok {
+		return false
+	}
+	t.anonUsed[c] = expires
+	return true
+}
+
+func (t *TwoFactor) getAnonKey() ([]byte, error) {
+	t.challengeMux.Lock()
+	defer t.challengeMux.Unlock()
+	if t.anonKey == nil {
+		key := make([]byte, 32)
+		_, err := rand.Read(key)
+		if err != nil {
+			t.getLogger("getAnonKey() rand.Read()").Error(err)
+			return nil, err
+		}
+		t.anonKey = key
+		t.anonUsed = make(map[string]time.Time)
+	}
+	return t.anonKey, nil
+}
+
+func anonMAC(key []byte, purpose string, b []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	mac.Write([]byte{0})
+	mac.Write(b)
+	return mac.Sum(nil)
+}
+
+func (t *TwoFactor) getLogger(note string) *record.DsLogger {
+	r := record.NewDsLogger().AddNote("TwoFactor")
+	if note != "" {
+		r.AddNote(note)
+	}
+	return r
+}
@@ -0,0 +1,375 @@
+package twofactor
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/migrate"
+	"github.com/teleclimber/DropServer/cmd/ds-host/models/twofactormodel"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+)
+
+const userID = domain.UserID(7)
+
+func getTwoFactor(t *testing.T, required bool) *TwoFactor {
+	mockCtrl := gomock.NewController(t)
+	t.Cleanup(mockCtrl.Finish)
+
+	h := migrate.MakeSqliteDummyDB()
+	t.Cleanup(func() { h.Close() })
+	model := &twofactormodel.TwoFactorModel{DB: &domain.DB{Handle: h}}
+	model.PrepareStatements()
+
+	settingsModel := testmocks.NewMockSettingsModel(mockCtrl)
+	settingsModel.EXPECT().Get().Return(domain.Settings{Require2FA: required}, nil).AnyTimes()
+	userModel := testmocks.NewMockUserModel(mockCtrl)
+	userModel.EXPECT().GetFromID(userID).Return(domain.User{UserID: userID, Email: "me@example.com"}, nil).AnyTimes()
+
+	config := &domain.RuntimeConfig{}
+	config.ExternalAccess.Scheme = "https"
+	config.Exec.UserRoutesDomain = "dropid.example.com"
+	config.Exec.PortString = ""
+
+	return &TwoFactor{
+		Config:         config,
+		SettingsModel:  settingsModel,
+		UserModel:      userModel,
+		TwoFactorModel: model}
+}
+
+func TestTOTPFlow(t *testing.T) {
+	tf := getTwoFactor(t, false)
+	now := time.Unix(1700000000, 0)
+	tf.now = func() time.Time { return now }
+
+	secret, uri, err := tf.BeginTOTP(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri == "" {
+		t.Error("expected uri")
+	}
+	again, _, _ := tf.BeginTOTP(userID)
+	if again != secret {
+		t.Error("expected unconfirmed secret to be reused")
+	}
+	key, _ := b32.DecodeString(secret)
+	step := now.Unix() / totpPeriod
+
+	// not enabled yet
+	ok, _ := tf.CheckTOTP(userID, totpCode(key, step))
+	if ok {
+		t.Error("expected unconfirmed TOTP not to be accepted")
+	}
+	_, err = tf.ConfirmTOTP(userID, "000000")
+	if err != domain.ErrBadAuth {
+		t.Errorf("expected bad auth, got %v", err)
+	}
+	codes, err := tf.ConfirmTOTP(userID, totpCode(key, step))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != numRecoveryCodes {
+		t.Errorf("expected recovery codes, got %v", codes)
+	}
+
+	// confirmation code can't be used again
+	ok, _ = tf.CheckTOTP(userID, totpCode(key, step))
+	if ok {
+		t.Error("expected code not to be accepted twice")
+	}
+	now = now.Add(totpPeriod * time.Second)
+	ok, err = tf.CheckTOTP(userID, totpCode(key, step+1))
+	if err != nil || !ok {
+		t.Errorf("expected next code to be accepted: %v %v", ok, err)
+	}
+
+	has, _ := tf.Has(userID)
+	if !has {
+		t.Error("expected user to have a second factor")
+	}
+
+	ok, _ = tf.UseRecoveryCode(userID, codes[3])
+	if !ok {
+		t.Error("expected recovery code to be accepted")
+	}
+	ok, _ = tf.UseRecoveryCode(userID, codes[3])
+	if ok {
+		t.Error("expected recovery code not to be accepted twice")
+	}
+	status, _ := tf.Status(userID)
+	if !status.TOTP || status.RecoveryCodes != numRecoveryCodes-1 {
+		t.Errorf("unexpected status %v", status)
+	}
+
+	err = tf.DisableTOTP(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	has, _ = tf.Has(userID)
+	if has {
+		t.Error("expected user to have no second factor")
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	if hashRecoveryCode("abcd-efgh") != hashRecoveryCode(" ABCD EFGH") {
+		t.Error("expected codes to be normalized")
+	}
+}
+
+func TestDisableLastFactorRequired(t *testing.T) {
+	tf := getTwoFactor(t, true)
+	now := time.Unix(1700000000, 0)
+	tf.now = func() time.Time { return now }
+
+	secret, _, _ := tf.BeginTOTP(userID)
+	key, _ := b32.DecodeString(secret)
+	_, err := tf.ConfirmTOTP(userID, totpCode(key, now.Unix()/totpPeriod))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tf.DisableTOTP(userID)
+	if err != domain.ErrLastSecondFactor {
+		t.Errorf("expected last factor error, got %v", err)
+	}
+}
+
+// authenticator is a fake ES256 security key
+type authenticator struct {
+	t         *testing.T
+	key       *ecdsa.PrivateKey
+	credID    []byte
+	signCount uint32
+	rpID      string
+	origin    string
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &authenticator{
+		t:      t,
+		key:    key,
+		credID: []byte("credential-one"),
+		rpID:   "dropid.example.com",
+		origin: "https://dropid.example.com"}
+}
+
+func (a *authenticator) authData(flags byte, attested []byte) []byte {
+	h := sha256.Sum256([]byte(a.rpID))
+	ret := append([]byte{}, h[:]...)
+	ret = append(ret, flags)
+	ret = binary.BigEndian.AppendUint32(ret, a.signCount)
+	return append(ret, attested...)
+}
+
+func (a *authenticator) clientData(typ, challenge string) []byte {
+	cd, _ := json.Marshal(clientData{Type: typ, Challenge: challenge, Origin: a.origin})
+	return cd
+}
+
+func (a *authenticator) create(challenge string) []byte {
+	pub, err := a.key.PublicKey.Bytes()
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	coseKey, err := cbor.Marshal(map[int]any{1: 2, 3: algES256, -1: 1, -2: pub[1:33], -3: pub[33:]})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	attested := make([]byte, 16)
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credID)))
+	attested = append(attested, a.credID...)
+	attested = append(attested, coseKey...)
+	att, err := cbor.Marshal(attestationObject{
+		Fmt:      "none",
+		AuthData: a.authData(flagUserPresent|flagUserVerified|flagAttested, attested),
+		AttStmt:  cbor.RawMessage{0xa0}})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	var c credentialCreation
+	c.ID = b64.EncodeToString(a.credID)
+	c.Response.ClientDataJSON = b64.EncodeToString(a.clientData("webauthn.create", challenge))
+	c.Response.AttestationObject = b64.EncodeToString(att)
+	ret, _ := json.Marshal(c)
+	return ret
+}
+
+func (a *authenticator) get(challenge string, flags byte) []byte {
+	a.signCount++
+	ad := a.authData(flags, nil)
+	cd := a.clientData("webauthn.get", challenge)
+	cdHash := sha256.Sum256(cd)
+	h := sha256.Sum256(append(append([]byte{}, ad...), cdHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, h[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	var as credentialAssertion
+	as.ID = b64.EncodeToString(a.credID)
+	as.Response.ClientDataJSON = b64.EncodeToString(cd)
+	as.Response.AuthenticatorData = b64.EncodeToString(ad)
+	as.Response.Signature = b64.EncodeToString(sig)
+	as.Response.UserHandle = userHandle(userID)
+	ret, _ := json.Marshal(as)
+	return ret
+}
+
+func TestPasskeyFlow(t *testing.T) {
+	tf := getTwoFactor(t, false)
+	a := newAuthenticator(t)
+
+	opts, err := tf.BeginPasskeyRegistration(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.RP.ID != "dropid.example.com" || opts.User.Name != "me@example.com" {
+		t.Errorf("unexpected options %v", opts)
+	}
+	passkey, err := tf.FinishPasskeyRegistration(userID, "my key", a.create(opts.Challenge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passkey.Name != "my key" || passkey.CredentialID != b64.EncodeToString(a.credID) {
+		t.Errorf("unexpected passkey %v", passkey)
+	}
+	// challenge is used up
+	_, err = tf.FinishPasskeyRegistration(userID, "again", a.create(opts.Challenge))
+	if err != domain.ErrBadAuth {
+		t.Errorf("expected bad auth, got %v", err)
+	}
+
+	// second factor login
+	reqOpts, err := tf.BeginPasskeyLogin(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reqOpts.AllowCredentials) != 1 {
+		t.Errorf("expected allowed credential, got %v", reqOpts.AllowCredentials)
+	}
+	gotUserID, err := tf.FinishPasskeyLogin(userID, a.get(reqOpts.Challenge, flagUserPresent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotUserID != userID {
+		t.Errorf("unexpected user id %v", gotUserID)
+	}
+
+	// passwordless login requires user verification
+	reqOpts, _ = tf.BeginPasskeyLogin(0)
+	_, err = tf.FinishPasskeyLogin(0, a.get(reqOpts.Challenge, flagUserPresent))
+	if err != domain.ErrBadAuth {
+		t.Errorf("expected bad auth without user verification, got %v", err)
+	}
+	reqOpts, _ = tf.BeginPasskeyLogin(0)
+	gotUserID, err = tf.FinishPasskeyLogin(0, a.get(reqOpts.Challenge, flagUserPresent|flagUserVerified))
+	if err != nil || gotUserID != userID {
+		t.Errorf("expected passwordless login: %v %v", gotUserID, err)
+	}
+
+	// a challenge issued for another user is rejected
+	reqOpts, _ = tf.BeginPasskeyLogin(0)
+	_, err = tf.FinishPasskeyLogin(userID, a.get(reqOpts.Challenge, flagUserPresent))
+	if err != domain.ErrBadAuth {
+		t.Errorf("expected bad auth for mismatched challenge, got %v", err)
+	}
+
+	// cloned authenticator: counter goes backwards
+	reqOpts, _ = tf.BeginPasskeyLogin(userID)
+	a.signCount = 0
+	_, err = tf.FinishPasskeyLogin(userID, a.get(reqOpts.Challenge, flagUserPresent))
+	if err != domain.ErrBadAuth {
+		t.Errorf("expected bad auth for old sign count, got %v", err)
+	}
+
+	// tampered signature
+	a.signCount = 100
+	reqOpts, _ = tf.BeginPasskeyLogin(userID)
+	var as credentialAssertion
+	json.Unmarshal(a.get(reqOpts.Challenge, flagUserPresent), &as)
+	as.Response.ClientDataJSON = b64.EncodeToString(a.clientData("webauthn.get", reqOpts.Challenge+"x"))
+	data, _ := json.Marshal(as)
+	_, err = tf.FinishPasskeyLogin(userID, data)
+	if err != domain.ErrBadAuth {
+		t.Errorf("expected bad auth for tampered data, got %v", err)
+	}
+
+	err = tf.DeletePasskey(userID, passkey.CredentialID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tf.BeginPasskeyLogin(userID)
+	if err == nil {
+		t.Error("expected error with no passkeys")
+	}
+}
+
+func TestChallengeExpires(t *testing.T) {
+	tf := getTwoFactor(t, false)
+	now := time.Unix(1700000000, 0)
+	tf.now = func() time.Time { return now }
+
+	c, _ := tf.newChallenge(userID, purposeLogin)
+	if tf.takeChallenge(c, purposeRegister, userID) {
+		t.Error("expected challenge for other purpose to be rejected")
+	}
+	c, _ = tf.newChallenge(userID, purposeLogin)
+	now = now.Add(challengeTTL + time.Second)
+	if tf.takeChallenge(c, purposeLogin, userID) {
+		t.Error("expected expired challenge to be rejected")
+	}
+}
+
+func TestAnonChallenge(t *testing.T) {
+	tf := getTwoFactor(t, false)
+	now := time.Unix(1700000000, 0)
+	tf.now = func() time.Time { return now }
+
+	c, err := tf.newChallenge(0, purposeLogin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tf.challenges) != 0 {
+		t.Error("expected anonymous challenge not to be stored")
+	}
+	if tf.takeChallenge(c, purposeRegister, 0) {
+		t.Error("expected challenge for other purpose to be rejected")
+	}
+	if tf.takeChallenge(c, purposeLogin, userID) {
+		t.Error("expected challenge for other user to be rejected")
+	}
+	if !tf.takeChallenge(c, purposeLogin, 0) {
+		t.Error("expected challenge to be accepted")
+	}
+	if tf.takeChallenge(c, purposeLogin, 0) {
+		t.Error("expected challenge to be taken only once")
+	}
+
+	b, _ := b64.DecodeString(c)
+	b[0] ^= 1
+	if tf.takeChallenge(b64.EncodeToString(b), purposeLogin, 0) {
+		t.Error("expected tampered challenge to be rejected")
+	}
+
+	c, _ = tf.newChallenge(0, purposeLogin)
+	now = now.Add(challengeTTL + time.Second)
+	if tf.takeChallenge(c, purposeLogin, 0) {
+		t.Error("expected expired challenge to be rejected")
+	}
+	if len(tf.anonUsed) != 0 {
+		t.Error("expected expired used challenges to be forgotten")
+	}
+}
@@ -0,0 +1,169 @@
+package usage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+)
+
+// reRollDays is the number of days before the last rolled up day
+// that are rolled up again, to catch runs that ended late.
+const reRollDays = 3
+
+// keepDayRollups is how long day rollups are kept.
+// Weeks and months are kept forever.
+var keepDayRollups = 400 * 24 * time.Hour
+
+// Accounting periodically sums sandbox runs into day, week and month
+// rollups, then prunes sandbox runs that are no longer needed.
+type Accounting struct {
+	Config     *domain.RuntimeConfig `checkinject:"required"`
+	UsageModel interface {
+		RollupDay(dayStart time.Time) error
+		RollupPeriod(period string, start time.Time, end time.Time) error
+		LastDay() (time.Time, bool, error)
+		DeleteBefore(period string, before time.Time) error
+	} `checkinject:"required"`
+	SandboxRuns interface {
+		FirstStart() (time.Time, bool, error)
+		DeleteBefore(before time.Time) (int64, error)
+	} `checkinject:"required"`
+
+	ticker  *time.Ticker
+	stop    chan struct{}
+	stopped chan struct{}
+	runMux  sync.Mutex
+}
+
+// Start rolls up usage now and every hour after that
+func (a *Accounting) Start() {
+	a.stop = make(chan struct{})
+	a.stopped = make(chan struct{})
+	a.ticker = time.NewTicker(time.Hour)
+
+	go func() {
+		defer close(a.stopped)
+		a.Rollup(time.Now())
+		for {
+			select {
+			case <-a.stop:
+				return
+			case <-a.ticker.C:
+				a.Rollup(time.Now())
+			}
+		}
+	}()
+}
+
+// Stop the periodic rollups and wait for an ongoing one to finish
+func (a *Accounting) Stop() {
+	a.ticker.Stop()
+	close(a.stop)
+	<-a.stopped
+}
+
+// Rollup sums sandbox runs up to now, including the current
+// partial day, week and month. Then it prunes old runs.
+func (a *Accounting) Rollup(now time.Time) error {
+	a.runMux.Lock()
+	defer a.runMux.Unlock()
+
+	today := dayStart(now)
+
+	from, ok, err := a.UsageModel.LastDay()
+	if err != nil {
+		return err
+	}
+	if ok {
+		from = from.AddDate(0, 0, -reRollDays)
+		// don't roll up days whose runs were pruned
+		if cutoff := a.runsCutoff(today); !cutoff.IsZero() && from.Before(cutoff) {
+			from = cutoff
+		}
+	} else {
+		first, ok, err := a.SandboxRuns.FirstStart()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		from = dayStart(first)
+	}
+
+	weeks := map[time.Time]bool{}
+	months := map[time.Time]bool{}
+	for d := from; !d.After(today); d = d.AddDate(0, 0, 1) {
+		err = a.UsageModel.RollupDay(d)
+		if err != nil {
+			return err
+		}
+		weeks[weekStart(d)] = true
+		months[monthStart(d)] = true
+	}
+	for w := range weeks {
+		err = a.UsageModel.RollupPeriod(domain.UsageWeek, w, w.AddDate(0, 0, 7))
+		if err != nil {
+			return err
+		}
+	}
+	for m := range months {
+		err = a.UsageModel.RollupPeriod(domain.UsageMonth, m, m.AddDate(0, 1, 0))
+		if err != nil {
+			return err
+		}
+	}
+
+	return a.prune(today)
+}
+
+func (a *Accounting) prune(today time.Time) error {
+	if cutoff := a.runsCutoff(today); !cutoff.IsZero() {
+		num, err := a.SandboxRuns.DeleteBefore(cutoff)
+		if err != nil {
+			return err
+		}
+		if num != 0 {
+			a.getLogger("prune()").Log(fmt.Sprintf("deleted %v sandbox runs from before %v", num, cutoff))
+		}
+	}
+	// keep whole months of day rollups
+	return a.UsageModel.DeleteBefore(domain.UsageDay, monthStart(today.Add(-keepDayRollups)))
+}
+
+// runsCutoff returns the time before which sandbox runs are pruned,
+// or zero time if they are kept forever
+func (a *Accounting) runsCutoff(today time.Time) time.Time {
+	if a.Config.Usage.KeepRunsDays == 0 {
+		return time.Time{}
+	}
+	return today.AddDate(0, 0, -a.Config.Usage.KeepRunsDays)
+}
+
+func (a *Accounting) getLogger(note string) *record.DsLogger {
+	l := record.NewDsLogger().AddNote("Accounting")
+	if note != "" {
+		l.AddNote(note)
+	}
+	return l
+}
+
+// Periods are in UTC and weeks start on Monday.
+
+func dayStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func weekStart(t time.Time) time.Time {
+	d := dayStart(t)
+	return d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7))
+}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
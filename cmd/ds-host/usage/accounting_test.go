@@ -0,0 +1,94 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+)
+
+func TestPeriodStarts(t *testing.T) {
+	// Thursday afternoon
+	tm := time.Date(2026, time.October, 15, 15, 4, 5, 0, time.UTC)
+	if d := dayStart(tm); !d.Equal(time.Date(2026, time.October, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected day start: %v", d)
+	}
+	if w := weekStart(tm); !w.Equal(time.Date(2026, time.October, 12, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected week start: %v", w)
+	}
+	// Sunday belongs to the week that started the Monday before
+	if w := weekStart(time.Date(2026, time.October, 18, 1, 0, 0, 0, time.UTC)); !w.Equal(time.Date(2026, time.October, 12, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected week start for sunday: %v", w)
+	}
+	if m := monthStart(tm); !m.Equal(time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected month start: %v", m)
+	}
+}
+
+func TestRollupFirstTime(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	cfg := &domain.RuntimeConfig{}
+	cfg.Usage.KeepRunsDays = 30
+
+	now := time.Date(2026, time.October, 1, 12, 0, 0, 0, time.UTC)
+	sep30 := time.Date(2026, time.September, 30, 0, 0, 0, 0, time.UTC)
+	oct1 := time.Date(2026, time.October, 1, 0, 0, 0, 0, time.UTC)
+
+	usageModel := testmocks.NewMockUsageModel(mockCtrl)
+	usageModel.EXPECT().LastDay().Return(time.Time{}, false, nil)
+	usageModel.EXPECT().RollupDay(sep30).Return(nil)
+	usageModel.EXPECT().RollupDay(oct1).Return(nil)
+	usageModel.EXPECT().RollupPeriod(domain.UsageWeek, time.Date(2026, time.September, 28, 0, 0, 0, 0, time.UTC), time.Date(2026, time.October, 5, 0, 0, 0, 0, time.UTC)).Return(nil)
+	usageModel.EXPECT().RollupPeriod(domain.UsageMonth, time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC), oct1).Return(nil)
+	usageModel.EXPECT().RollupPeriod(domain.UsageMonth, oct1, time.Date(2026, time.November, 1, 0, 0, 0, 0, time.UTC)).Return(nil)
+	usageModel.EXPECT().DeleteBefore(domain.UsageDay, gomock.Any()).Return(nil)
+
+	sandboxRuns := testmocks.NewMockSandboxRuns(mockCtrl)
+	sandboxRuns.EXPECT().FirstStart().Return(sep30.Add(23*time.Hour), true, nil)
+	sandboxRuns.EXPECT().DeleteBefore(time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)).Return(int64(0), nil)
+
+	a := &Accounting{
+		Config:      cfg,
+		UsageModel:  usageModel,
+		SandboxRuns: sandboxRuns,
+	}
+	err := a.Rollup(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRollupKeepRuns(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	cfg := &domain.RuntimeConfig{}
+
+	now := time.Date(2026, time.October, 15, 12, 0, 0, 0, time.UTC)
+	oct15 := time.Date(2026, time.October, 15, 0, 0, 0, 0, time.UTC)
+
+	usageModel := testmocks.NewMockUsageModel(mockCtrl)
+	usageModel.EXPECT().LastDay().Return(oct15, true, nil)
+	// re-rolls the last few days
+	usageModel.EXPECT().RollupDay(gomock.Any()).Return(nil).Times(reRollDays + 1)
+	usageModel.EXPECT().RollupPeriod(domain.UsageWeek, gomock.Any(), gomock.Any()).Return(nil)
+	usageModel.EXPECT().RollupPeriod(domain.UsageMonth, gomock.Any(), gomock.Any()).Return(nil)
+	usageModel.EXPECT().DeleteBefore(domain.UsageDay, gomock.Any()).Return(nil)
+
+	// KeepRunsDays of 0 means runs are never deleted
+	sandboxRuns := testmocks.NewMockSandboxRuns(mockCtrl)
+
+	a := &Accounting{
+		Config:      cfg,
+		UsageModel:  usageModel,
+		SandboxRuns: sandboxRuns,
+	}
+	err := a.Rollup(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
@@ -27,6 +28,7 @@ type AdminRoutes struct {
 	SettingsModel interface {
 		Get() (domain.Settings, error)
 		SetRegistrationOpen(bool) error
+		SetRequire2FA(bool) error
 		GetTSNet() (domain.TSNetCommon, error)
 		SetTSNet(domain.TSNetCommon) error
 		SetTSNetConnect(bool) error
@@ -46,6 +48,34 @@ type AdminRoutes struct {
 		GetStatus() domain.TSNetStatus
 		GetPeerUsers() []domain.TSNetPeerUser
 	} `checkinject:"required"`
+	AppspaceModel interface {
+		GetFromID(domain.AppspaceID) (*domain.Appspace, error)
+	} `checkinject:"required"`
+	AppspaceQuotaModel interface {
+		Get(appspaceID domain.AppspaceID, setBy string) (domain.AppspaceQuota, error)
+		Set(appspaceID domain.AppspaceID, setBy string, q domain.AppspaceQuota) error
+		GetHits(appspaceID domain.AppspaceID) ([]domain.AppspaceLimitHit, error)
+		GetAllHits() ([]domain.AppspaceLimitHit, error)
+	} `checkinject:"required"`
+	SandboxManager interface {
+		StopAppspace(domain.AppspaceID)
+	} `checkinject:"required"`
+	UsageModel interface {
+		GetForOwner(ownerID domain.UserID, period string, from time.Time, to time.Time) ([]domain.UsageRollup, error)
+		GetUserTotals(period string, from time.Time, to time.Time) ([]domain.UsageRollup, error)
+	} `checkinject:"required"`
+	LoginLimiter interface {
+		Status() domain.LoginLimitStatus
+		Clear(kind, key string)
+	} `checkinject:"required"`
+	Mailer interface {
+		Enabled() bool
+		Send(to string, name string, data any) error
+	} `checkinject:"required"`
+	CookieModel interface {
+		GetForUser(userID domain.UserID) ([]domain.Cookie, error)
+		DeleteForUser(userID domain.UserID, keepCookieID string) error
+	} `checkinject:"required"`
 }
 
 func (a *AdminRoutes) subRouter() http.Handler {
@@ -57,8 +87,11 @@ func (a *AdminRoutes) subRouter() http.Handler {
 	r.Post("/user/", a.postUser)
 	r.Post("/user/{user_id}/tsnet", a.postUserTSNet)
 	r.Delete("/user/{user_id}/tsnet", a.deleteUserTSNet)
+	r.Get("/user/{user_id}/session", a.getUserSessions)
+	r.Delete("/user/{user_id}/session", a.deleteUserSessions)
 	r.Get("/settings", a.getSettings)
 	r.Post("/settings/registration", a.postRegistration)
+	r.Post("/settings/require-2fa", a.postRequire2FA)
 	r.Post("/settings/tsnet/connect", a.postTSNetConnect)
 	r.Post("/settings/tsnet", a.postTSNet)
 	r.Delete("/settings/tsnet", a.deleteTSNet)
@@ -67,6 +100,13 @@ func (a *AdminRoutes) subRouter() http.Handler {
 	r.Delete("/invitation/{email}", a.deleteInvitation)
 	r.Get("/tsnet", a.getTSNetStatus)
 	r.Get("/tsnet/peerusers", a.getTSNetPeerUsers)
+	r.Get("/appspace-quota/hits", a.getAllLimitHits)
+	r.Get("/appspace-quota/{appspace_id}", a.getAppspaceQuota)
+	r.Post("/appspace-quota/{appspace_id}", a.postAppspaceQuota)
+	r.Get("/usage", a.getUsageTotals)
+	r.Get("/usage/{user_id}", a.getUserUsage)
+	r.Get("/login-limits", a.getLoginLimits)
+	r.Delete("/login-limits/{kind}/{key}", a.deleteLoginLimit)
 
 	return r
 }
@@ -234,6 +274,41 @@ func (a *AdminRoutes) deleteUserTSNet(w http.ResponseWriter, r *http.Request) {
 	writeOK(w)
 }
 
+// getUserSessions returns the account sessions of the user
+func (a *AdminRoutes) getUserSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromRequest(r)
+	if err != nil {
+		writeBadRequest(w, "user_id", err.Error())
+		return
+	}
+	cookies, err := a.CookieModel.GetForUser(userID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, sessionsFromCookies(cookies, ""))
+}
+
+// deleteUserSessions logs the user out everywhere.
+// If admins target themselves, they keep the session they are using.
+func (a *AdminRoutes) deleteUserSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromRequest(r)
+	if err != nil {
+		writeBadRequest(w, "user_id", err.Error())
+		return
+	}
+	keepID := ""
+	if authUserID, _ := domain.CtxAuthUserID(r.Context()); authUserID == userID {
+		keepID, _ = domain.CtxSessionID(r.Context())
+	}
+	err = a.CookieModel.DeleteForUser(userID, keepID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func userIDFromRequest(r *http.Request) (domain.UserID, error) {
 	userIDStr, err := url.QueryUnescape(chi.URLParam(r, "user_id"))
 	if err != nil {
@@ -249,6 +324,7 @@ func userIDFromRequest(r *http.Request) (domain.UserID, error) {
 // settingsResp represents admin settings
 type settingsResp struct {
 	RegistrationOpen bool               `json:"registration_open"`
+	Require2FA       bool               `json:"require_2fa"`
 	TSNet            domain.TSNetCommon `json:"tsnet"`
 }
 
@@ -267,6 +343,7 @@ func (a *AdminRoutes) getSettings(w http.ResponseWriter, r *http.Request) {
 
 	respData := settingsResp{
 		RegistrationOpen: settings.RegistrationOpen,
+		Require2FA:       settings.Require2FA,
 		TSNet:            tsnetConfig}
 
 	writeJSON(w, respData)
@@ -293,6 +370,29 @@ func (a *AdminRoutes) postRegistration(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+type require2FAPost struct {
+	Require bool `json:"require"`
+}
+
+// postRequire2FA sets whether users must have a second factor to log in with a password.
+// Users without one are asked to set up TOTP on their next login.
+func (a *AdminRoutes) postRequire2FA(w http.ResponseWriter, r *http.Request) {
+	reqData := &require2FAPost{}
+	err := readJSON(r, reqData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = a.SettingsModel.SetRequire2FA(reqData.Require)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
 func (a *AdminRoutes) getTSNetStatus(w http.ResponseWriter, r *http.Request) {
 	status := a.UserTSNet.GetStatus()
 	writeJSON(w, status)
@@ -419,6 +519,11 @@ func (a *AdminRoutes) postInvitation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if a.Mailer.Enabled() {
+		data := struct{ Email string }{reqData.Email}
+		go a.Mailer.Send(reqData.Email, "invitation", data) // errors are logged by the mailer
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -441,6 +546,139 @@ func (a *AdminRoutes) deleteInvitation(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// getAllLimitHits returns the limits appspaces ran into, most recent first
+func (a *AdminRoutes) getAllLimitHits(w http.ResponseWriter, r *http.Request) {
+	hits, err := a.AppspaceQuotaModel.GetAllHits()
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, hits)
+}
+
+func (a *AdminRoutes) getAppspaceQuota(w http.ResponseWriter, r *http.Request) {
+	appspaceID, ok := a.appspaceIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+	resp, err := getQuotaResp(a.AppspaceQuotaModel, appspaceID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// postAppspaceQuota sets the admin's quota on an appspace
+func (a *AdminRoutes) postAppspaceQuota(w http.ResponseWriter, r *http.Request) {
+	appspaceID, ok := a.appspaceIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+	var q domain.AppspaceQuota
+	err := readJSON(r, &q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if field, msg := validateQuota(q); field != "" {
+		writeBadRequest(w, field, msg)
+		return
+	}
+	err = a.AppspaceQuotaModel.Set(appspaceID, domain.QuotaSetByAdmin, q)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	// the sandbox picks up the new quota when it next starts
+	a.SandboxManager.StopAppspace(appspaceID)
+	resp, err := getQuotaResp(a.AppspaceQuotaModel, appspaceID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// appspaceIDFromRequest returns the appspace id in the params
+// and checks that the appspace exists.
+func (a *AdminRoutes) appspaceIDFromRequest(w http.ResponseWriter, r *http.Request) (domain.AppspaceID, bool) {
+	appspaceIDInt, err := strconv.Atoi(chi.URLParam(r, "appspace_id"))
+	if err != nil {
+		http.Error(w, "invalid appspace_id", http.StatusBadRequest)
+		return 0, false
+	}
+	appspaceID := domain.AppspaceID(appspaceIDInt)
+	_, err = a.AppspaceModel.GetFromID(appspaceID)
+	if err == domain.ErrNoRowsInResultSet {
+		returnError(w, errNotFound)
+		return 0, false
+	}
+	if err != nil {
+		returnError(w, err)
+		return 0, false
+	}
+	return appspaceID, true
+}
+
+// getUsageTotals returns the usage of each user,
+// heaviest CPU users first. Takes the same query as the user's usage route.
+func (a *AdminRoutes) getUsageTotals(w http.ResponseWriter, r *http.Request) {
+	period, from, to, err := usageQuery(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	totals, err := a.UsageModel.GetUserTotals(period, from, to)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, totals)
+}
+
+// getUserUsage returns the usage rollups of a user
+func (a *AdminRoutes) getUserUsage(w http.ResponseWriter, r *http.Request) {
+	userID, err := userIDFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	period, from, to, err := usageQuery(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rollups, err := a.UsageModel.GetForOwner(userID, period, from, to)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, rollups)
+}
+
+// getLoginLimits returns the IPs and accounts that are blocked
+// and recent attempts that were turned away
+func (a *AdminRoutes) getLoginLimits(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, a.LoginLimiter.Status())
+}
+
+// deleteLoginLimit lifts the block on an IP or account
+func (a *AdminRoutes) deleteLoginLimit(w http.ResponseWriter, r *http.Request) {
+	kind := chi.URLParam(r, "kind")
+	if kind != domain.LoginLimitIP && kind != domain.LoginLimitAccount {
+		writeBadRequest(w, "kind", "unknown kind")
+		return
+	}
+	key, err := url.PathUnescape(chi.URLParam(r, "key"))
+	if err != nil || key == "" {
+		writeBadRequest(w, "key", "invalid key")
+		return
+	}
+	a.LoginLimiter.Clear(kind, key)
+	w.WriteHeader(http.StatusOK)
+}
+
 func (a *AdminRoutes) getLogger(note string) *record.DsLogger {
 	l := record.NewDsLogger().AddNote("AdminRoutes")
 	if note != "" {
@@ -3,6 +3,7 @@ package userroutes
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/go-chi/chi/v5"
@@ -58,3 +59,128 @@ func TestMustBeAdminForbidden(t *testing.T) {
 		t.Errorf("expected Forbidden got status %v", rr.Result().Status)
 	}
 }
+
+type clearedLimit struct {
+	kind string
+	key  string
+}
+
+func (c *clearedLimit) Status() domain.LoginLimitStatus {
+	return domain.LoginLimitStatus{}
+}
+func (c *clearedLimit) Clear(kind, key string) {
+	c.kind = kind
+	c.key = key
+}
+
+func TestDeleteLoginLimit(t *testing.T) {
+	cases := []struct {
+		path   string
+		status int
+		kind   string
+		key    string
+	}{
+		{"/login-limits/account/a%40b.c", http.StatusOK, domain.LoginLimitAccount, "a@b.c"},
+		{"/login-limits/ip/2001:db8::%2F64", http.StatusOK, domain.LoginLimitIP, "2001:db8::/64"},
+		{"/login-limits/bogus/a%40b.c", http.StatusBadRequest, "", ""},
+	}
+	for _, c := range cases {
+		limiter := &clearedLimit{}
+		a := AdminRoutes{LoginLimiter: limiter}
+		router := chi.NewMux()
+		router.Delete("/login-limits/{kind}/{key}", a.deleteLoginLimit)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, c.path, nil))
+		if rr.Code != c.status {
+			t.Errorf("%v: expected status %v, got %v", c.path, c.status, rr.Code)
+		}
+		if limiter.kind != c.kind || limiter.key != c.key {
+			t.Errorf("%v: unexpected clear %v %v", c.path, limiter.kind, limiter.key)
+		}
+	}
+}
+
+func TestDeleteUserSessions(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	cm := testmocks.NewMockCookieModel(mockCtrl)
+	cm.EXPECT().DeleteForUser(domain.UserID(7), "").Return(nil)
+	cm.EXPECT().DeleteForUser(domain.UserID(1), "admin-cookie").Return(nil)
+
+	a := AdminRoutes{CookieModel: cm}
+	router := chi.NewMux()
+	router.Delete("/user/{user_id}/session", a.deleteUserSessions)
+
+	for _, path := range []string{"/user/7/session", "/user/1/session"} {
+		req := sessionRequest(http.MethodDelete, path, domain.UserID(1), "admin-cookie")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusNoContent {
+			t.Errorf("%v: unexpected status %v", path, rr.Code)
+		}
+	}
+}
+
+func TestPostInvitationSendsEmail(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	invitationModel := testmocks.NewMockUserInvitationModel(mockCtrl)
+	invitationModel.EXPECT().Create("new@example.com").Return(nil)
+
+	sent := make(chan string)
+	mailer := testmocks.NewMockMailer(mockCtrl)
+	mailer.EXPECT().Enabled().Return(true)
+	mailer.EXPECT().Send("new@example.com", "invitation", gomock.Any()).DoAndReturn(func(to, name string, data any) error {
+		sent <- to
+		return nil
+	})
+
+	a := AdminRoutes{
+		UserInvitationModel: invitationModel,
+		Mailer:              mailer}
+
+	req := httptest.NewRequest(http.MethodPost, "/invitation", strings.NewReader(`{"email":"new@example.com"}`))
+	rr := httptest.NewRecorder()
+	a.postInvitation(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("expected no content, got %v", rr.Code)
+	}
+	if to := <-sent; to != "new@example.com" {
+		t.Errorf("unexpected recipient %v", to)
+	}
+}
+
+func TestPostAppspaceQuota(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	appspaceID := domain.AppspaceID(7)
+	admin := domain.AppspaceQuota{MemoryMaxMb: 128}
+
+	appspaceModel := testmocks.NewMockAppspaceModel(mockCtrl)
+	appspaceModel.EXPECT().GetFromID(appspaceID).Return(&domain.Appspace{AppspaceID: appspaceID}, nil)
+	quotaModel := testmocks.NewMockAppspaceQuotaModel(mockCtrl)
+	quotaModel.EXPECT().Set(appspaceID, domain.QuotaSetByAdmin, admin).Return(nil)
+	quotaModel.EXPECT().Get(appspaceID, domain.QuotaSetByOwner).Return(domain.AppspaceQuota{}, nil)
+	quotaModel.EXPECT().Get(appspaceID, domain.QuotaSetByAdmin).Return(admin, nil)
+	quotaModel.EXPECT().GetHits(appspaceID).Return([]domain.AppspaceLimitHit{}, nil)
+	sandboxManager := testmocks.NewMockSandboxManager(mockCtrl)
+	sandboxManager.EXPECT().StopAppspace(appspaceID)
+
+	a := AdminRoutes{
+		AppspaceModel:      appspaceModel,
+		AppspaceQuotaModel: quotaModel,
+		SandboxManager:     sandboxManager}
+	router := chi.NewMux()
+	router.Post("/appspace-quota/{appspace_id}", a.postAppspaceQuota)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/appspace-quota/7", strings.NewReader(`{"memory_max_mb":128}`)))
+	if rr.Code != http.StatusOK {
+		t.Errorf("unexpected status %v: %v", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,172 @@
+package userroutes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+const defaultAccessLogLimit = 100
+const maxAccessLogLimit = 1000
+
+// AccessLogResp is a page of access log entries, newest first.
+// Next is passed as "before" to get older entries. It is 0 if there are none.
+type AccessLogResp struct {
+	Entries []domain.AccessLogEntry `json:"entries"`
+	Next    int64                   `json:"next"`
+}
+
+// AccessLogSettingsResp has the appspace's access log settings
+// and the host's limits on keep days
+type AccessLogSettingsResp struct {
+	Settings        domain.AccessLogSettings `json:"settings"`
+	DefaultKeepDays int                      `json:"default_keep_days"`
+	MaxKeepDays     int                      `json:"max_keep_days"`
+}
+
+// AppspaceAccessLogRoutes let owners turn on the access log of their appspace
+// and look at requests it received
+type AppspaceAccessLogRoutes struct {
+	Config    *domain.RuntimeConfig `checkinject:"required"`
+	AccessLog interface {
+		SetSettings(appspaceID domain.AppspaceID, settings domain.AccessLogSettings) error
+	} `checkinject:"required"`
+	AccessLogModel interface {
+		GetSettings(appspaceID domain.AppspaceID) (domain.AccessLogSettings, error)
+		Query(appspaceID domain.AppspaceID, q domain.AccessLogQuery) ([]domain.AccessLogEntry, error)
+		Stats(appspaceID domain.AppspaceID, from time.Time, to time.Time) (domain.AccessLogStats, error)
+	} `checkinject:"required"`
+}
+
+// - GET /?from=&to=&route=&proxy_id=&status=&limit=&before=
+//   :entries, newest first. Times are RFC3339.
+//   status can be a code like 404 or a class like 4 for all 4xx.
+// - GET /stats?from=&to= :totals, top routes and users
+// - GET /settings
+// - POST /settings :enable or disable, set keep days
+
+func (a *AppspaceAccessLogRoutes) subRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/", a.getEntries)
+	r.Get("/stats", a.getStats)
+	r.Get("/settings", a.getSettings)
+	r.Post("/settings", a.postSettings)
+	return r
+}
+
+func (a *AppspaceAccessLogRoutes) getEntries(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+
+	q, err := accessLogQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := a.AccessLogModel.Query(appspace.AppspaceID, q)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	resp := AccessLogResp{Entries: entries}
+	if len(entries) == q.Limit {
+		resp.Next = entries[len(entries)-1].EntryID
+	}
+	writeJSON(w, resp)
+}
+
+func (a *AppspaceAccessLogRoutes) getStats(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+
+	from, err := queryTime(r, "from")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := queryTime(r, "to")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	stats, err := a.AccessLogModel.Stats(appspace.AppspaceID, from, to)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, stats)
+}
+
+func (a *AppspaceAccessLogRoutes) getSettings(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+	settings, err := a.AccessLogModel.GetSettings(appspace.AppspaceID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, AccessLogSettingsResp{
+		Settings:        settings,
+		DefaultKeepDays: a.Config.AccessLog.DefaultKeepDays,
+		MaxKeepDays:     a.Config.AccessLog.MaxKeepDays})
+}
+
+func (a *AppspaceAccessLogRoutes) postSettings(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+
+	var settings domain.AccessLogSettings
+	err := readJSON(r, &settings)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if settings.KeepDays < 0 || settings.KeepDays > a.Config.AccessLog.MaxKeepDays {
+		writeBadRequest(w, "keep_days", "must be between 0 and "+strconv.Itoa(a.Config.AccessLog.MaxKeepDays))
+		return
+	}
+
+	err = a.AccessLog.SetSettings(appspace.AppspaceID, settings)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeOK(w)
+}
+
+func accessLogQuery(r *http.Request) (q domain.AccessLogQuery, err error) {
+	v := r.URL.Query()
+	q.From, err = queryTime(r, "from")
+	if err != nil {
+		return
+	}
+	q.To, err = queryTime(r, "to")
+	if err != nil {
+		return
+	}
+	q.RoutePath = v.Get("route")
+	q.ProxyID = domain.ProxyID(v.Get("proxy_id"))
+	if s := v.Get("status"); s != "" {
+		q.Status, err = strconv.Atoi(s)
+		if err != nil || q.Status < 0 {
+			err = errors.New("invalid status")
+			return
+		}
+	}
+	q.Limit = defaultAccessLogLimit
+	if s := v.Get("limit"); s != "" {
+		q.Limit, err = strconv.Atoi(s)
+		if err != nil || q.Limit < 1 || q.Limit > maxAccessLogLimit {
+			err = errors.New("invalid limit")
+			return
+		}
+	}
+	if s := v.Get("before"); s != "" {
+		q.Before, err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			err = errors.New("invalid before")
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,72 @@
+package userroutes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+)
+
+func TestAccessLogQuery(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/?route=/api/**&proxy_id=abc&status=4&before=55", nil)
+	q, err := accessLogQuery(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := domain.AccessLogQuery{
+		RoutePath: "/api/**",
+		ProxyID:   domain.ProxyID("abc"),
+		Status:    4,
+		Limit:     defaultAccessLogLimit,
+		Before:    55}
+	if q != expected {
+		t.Errorf("unexpected query: %v", q)
+	}
+
+	for _, qs := range []string{"status=x", "limit=0", "limit=5000", "before=x", "from=x"} {
+		req, _ = http.NewRequest(http.MethodGet, "/?"+qs, nil)
+		_, err = accessLogQuery(req)
+		if err == nil {
+			t.Errorf("expected error for %v", qs)
+		}
+	}
+}
+
+func TestPostAccessLogSettings(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	cfg := &domain.RuntimeConfig{}
+	cfg.AccessLog.MaxKeepDays = 30
+
+	appspaceID := domain.AppspaceID(7)
+
+	accessLogModel := testmocks.NewMockAccessLogModel(mockCtrl)
+	accessLogModel.EXPECT().SetSettings(appspaceID, domain.AccessLogSettings{Enabled: true, KeepDays: 7}).Return(nil)
+
+	a := &AppspaceAccessLogRoutes{
+		Config:    cfg,
+		AccessLog: accessLogModel,
+	}
+
+	cases := []struct {
+		body string
+		code int
+	}{
+		{`{"enabled":true,"keep_days":7}`, http.StatusOK},
+		{`{"enabled":true,"keep_days":60}`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest(http.MethodPost, "/settings", strings.NewReader(c.body))
+		req = req.WithContext(domain.CtxWithAppspaceData(req.Context(), domain.Appspace{AppspaceID: appspaceID}))
+		rr := httptest.NewRecorder()
+		a.subRouter().ServeHTTP(rr, req)
+		if rr.Code != c.code {
+			t.Errorf("%v: expected status %v, got %v", c.body, c.code, rr.Code)
+		}
+	}
+}
@@ -1,13 +1,19 @@
 package userroutes
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
 	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+	"github.com/teleclimber/DropServer/internal/backupcrypt"
+	"github.com/teleclimber/DropServer/internal/nulltypes"
 	"github.com/teleclimber/DropServer/internal/validator"
 )
 
@@ -15,10 +21,18 @@ type BackupFile struct {
 	Filename string `json:"filename"`
 }
 
+// BackupScheduleResp is the appspace's backup schedule
+// along with the time of the next scheduled backup
+type BackupScheduleResp struct {
+	domain.BackupSchedule
+	NextRun nulltypes.NullTime `json:"next_run"`
+}
+
 type AppspaceBackupRoutes struct {
 	AppspaceFilesModel interface {
 		GetBackups(locationKey string) ([]string, error)
 		DeleteBackup(locationKey string, filename string) error
+		WriteBackupZip(locationKey string, filename string, w io.Writer) error
 	} `checkinject:"required"`
 	BackupAppspace interface {
 		CreateBackup(appspaceID domain.AppspaceID) (string, error)
@@ -26,6 +40,38 @@ type AppspaceBackupRoutes struct {
 	AppspaceLocation2Path interface {
 		Backup(string, string) string
 	} `checkinject:"required"`
+	BackupScheduleModel interface {
+		Get(domain.AppspaceID) (domain.BackupSchedule, error)
+		Set(domain.BackupSchedule) error
+		Delete(domain.AppspaceID) error
+	} `checkinject:"required"`
+	BackupScheduler interface {
+		NextRun(domain.BackupSchedule) (time.Time, error)
+	} `checkinject:"required"`
+	RemoteBackups interface {
+		List(appspaceID domain.AppspaceID) ([]domain.RemoteBackupList, error)
+		WriteZip(appspaceID domain.AppspaceID, remote string, backupFile string, w io.Writer) error
+	} `checkinject:"required"`
+	BackupKeyModel interface {
+		Get(domain.AppspaceID) (domain.BackupKey, error)
+		GetRecipient(domain.AppspaceID) (backupcrypt.Recipient, bool, error)
+		Set(domain.AppspaceID, string) error
+		Delete(domain.AppspaceID) error
+	} `checkinject:"required"`
+	ExportAppspace interface {
+		WriteBundle(appspaceID domain.AppspaceID, backupFile string, w io.Writer) error
+	} `checkinject:"required"`
+}
+
+// BackupEncryption is the state of backup encryption for an appspace
+type BackupEncryption struct {
+	Enabled bool               `json:"enabled"`
+	Created nulltypes.NullTime `json:"created"`
+}
+
+// SetBackupEncryption sets the passphrase that exported backups are encrypted with
+type SetBackupEncryption struct {
+	Passphrase string `json:"passphrase"`
 }
 
 // not 100% sure what the api is here.
@@ -33,7 +79,11 @@ type AppspaceBackupRoutes struct {
 // - POST / :trigger creation of export archive
 // - GET /<archive> :download an archive
 // - DELETE /<archive> :delete that one
-// - [Set automatic backup schedule and backups rotation? (1/day at 2am keep last 7)] //maybe another route altogether
+// - GET, PUT, DELETE /schedule :automatic backup schedule and retention
+// - GET /remote :list archives on backup remotes
+// - GET, PUT, DELETE /encryption :encryption of downloaded and uploaded archives
+// - GET /remote/<remote>/<archive> :download an archive from a remote
+// - GET /bundle/<archive> :download an export bundle of the archive, app and users for import elsewhere
 
 func (e *AppspaceBackupRoutes) subRouter() http.Handler {
 	r := chi.NewRouter()
@@ -42,6 +92,19 @@ func (e *AppspaceBackupRoutes) subRouter() http.Handler {
 	r.Get("/", e.getArchives)
 	r.Post("/", e.createArchive)
 
+	r.Get("/schedule", e.getSchedule)
+	r.Put("/schedule", e.setSchedule)
+	r.Delete("/schedule", e.deleteSchedule)
+
+	r.Get("/encryption", e.getEncryption)
+	r.Put("/encryption", e.setEncryption)
+	r.Delete("/encryption", e.deleteEncryption)
+
+	r.Get("/remote", e.getRemoteArchives)
+	r.Get("/remote/{remote}/{archive}", e.downloadRemoteArchive)
+
+	r.Get("/bundle/{archive}", e.downloadBundle)
+
 	r.Route("/{archive}", func(r chi.Router) {
 		r.Get("/", e.downloadArchive)
 		r.Delete("/", e.deleteArchive)
@@ -85,13 +148,131 @@ func (e *AppspaceBackupRoutes) downloadArchive(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	splitDomain := strings.SplitN(appspace.DomainName, ".", 2)
-	downloadFileName := splitDomain[0] + "-" + archive
+	recipient, encrypt, err := e.BackupKeyModel.GetRecipient(appspace.AppspaceID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
 
-	w.Header().Set("Content-Type", "application/zip")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", downloadFileName))
+	if !encrypt && strings.HasSuffix(archive, ".zip") {
+		setDownloadHeaders(w, appspace.DomainName, archive, false)
+		http.ServeFile(w, r, e.AppspaceLocation2Path.Backup(appspace.LocationKey, archive))
+		return
+	}
+
+	// Snapshots are assembled into a zip as they are sent.
+	// Delay headers until the first write so that a missing snapshot
+	// can still be reported with an error status.
+	closeOut := func() error { return nil }
+	bw := &firstWriteWriter{onFirst: func() (io.Writer, error) {
+		setDownloadHeaders(w, appspace.DomainName, archive, encrypt)
+		out, c, err := exportWriter(w, recipient, encrypt)
+		closeOut = c
+		return out, err
+	}}
+	err = e.AppspaceFilesModel.WriteBackupZip(appspace.LocationKey, archive, bw)
+	if err == nil {
+		err = closeOut()
+	}
+	if err != nil && !bw.written {
+		if errors.Is(err, os.ErrNotExist) {
+			returnError(w, errNotFound)
+			return
+		}
+		returnError(w, err)
+		return
+	}
+	if err != nil {
+		e.getLogger("downloadArchive, WriteBackupZip").AppspaceID(appspace.AppspaceID).Error(err)
+	}
+}
+
+func (e *AppspaceBackupRoutes) getRemoteArchives(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+
+	lists, err := e.RemoteBackups.List(appspace.AppspaceID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+
+	writeJSON(w, lists)
+}
+
+func (e *AppspaceBackupRoutes) downloadRemoteArchive(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+
+	archive, err := getArchiveFromPath(r)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	remote := chi.URLParam(r, "remote")
 
-	http.ServeFile(w, r, e.AppspaceLocation2Path.Backup(appspace.LocationKey, archive))
+	recipient, encrypt, err := e.BackupKeyModel.GetRecipient(appspace.AppspaceID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	if strings.HasSuffix(archive, ".enc") {
+		encrypt = false // already encrypted
+	}
+
+	// Delay headers until the first write so that a missing backup
+	// can still be reported with an error status.
+	closeOut := func() error { return nil }
+	bw := &firstWriteWriter{onFirst: func() (io.Writer, error) {
+		setDownloadHeaders(w, appspace.DomainName, archive, encrypt)
+		out, c, err := exportWriter(w, recipient, encrypt)
+		closeOut = c
+		return out, err
+	}}
+	err = e.RemoteBackups.WriteZip(appspace.AppspaceID, remote, archive, bw)
+	if err == nil {
+		err = closeOut()
+	}
+	if err != nil && !bw.written {
+		if err == domain.ErrRemoteBackupNotFound {
+			returnError(w, errNotFound)
+			return
+		}
+		returnError(w, err)
+		return
+	}
+	if err != nil {
+		e.getLogger("downloadRemoteArchive, WriteZip").AppspaceID(appspace.AppspaceID).Error(err)
+	}
+}
+
+func (e *AppspaceBackupRoutes) downloadBundle(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+
+	archive, err := getArchiveFromPath(r)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	_, err = os.Stat(e.AppspaceLocation2Path.Backup(appspace.LocationKey, archive))
+	if os.IsNotExist(err) {
+		returnError(w, errNotFound)
+		return
+	}
+
+	bw := &firstWriteWriter{onFirst: func() (io.Writer, error) {
+		splitDomain := strings.SplitN(appspace.DomainName, ".", 2)
+		base := strings.TrimSuffix(strings.TrimSuffix(archive, ".snapshot"), ".zip")
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-%s-bundle.zip\"", splitDomain[0], base))
+		return w, nil
+	}}
+	err = e.ExportAppspace.WriteBundle(appspace.AppspaceID, archive, bw)
+	if err != nil && !bw.written {
+		handleError(w, err)
+		return
+	}
+	if err != nil {
+		e.getLogger("downloadBundle, WriteBundle").AppspaceID(appspace.AppspaceID).Error(err)
+	}
 }
 
 func (e *AppspaceBackupRoutes) deleteArchive(w http.ResponseWriter, r *http.Request) {
@@ -110,6 +291,137 @@ func (e *AppspaceBackupRoutes) deleteArchive(w http.ResponseWriter, r *http.Requ
 	}
 }
 
+// getSchedule returns the appspace's backup schedule
+// If none is set a disabled daily schedule is returned.
+func (e *AppspaceBackupRoutes) getSchedule(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+
+	schedule, err := e.BackupScheduleModel.Get(appspace.AppspaceID)
+	if err == domain.ErrNoRowsInResultSet {
+		writeJSON(w, BackupScheduleResp{
+			BackupSchedule: domain.BackupSchedule{
+				AppspaceID: appspace.AppspaceID,
+				Schedule:   "daily"}})
+		return
+	}
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+
+	resp := BackupScheduleResp{BackupSchedule: schedule}
+	if schedule.Enabled {
+		next, err := e.BackupScheduler.NextRun(schedule)
+		if err == nil && !next.IsZero() {
+			resp.NextRun = nulltypes.NewTime(next, true)
+		}
+	}
+
+	writeJSON(w, resp)
+}
+
+func (e *AppspaceBackupRoutes) setSchedule(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+
+	schedule := domain.BackupSchedule{}
+	err := readJSON(r, &schedule)
+	if err != nil {
+		writeBadRequest(w, "schedule", err.Error())
+		return
+	}
+	schedule.AppspaceID = appspace.AppspaceID
+
+	err = validator.BackupSchedule(schedule)
+	if err != nil {
+		writeBadRequest(w, "schedule", err.Error())
+		return
+	}
+
+	err = e.BackupScheduleModel.Set(schedule)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+
+	writeOK(w)
+}
+
+func (e *AppspaceBackupRoutes) deleteSchedule(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+
+	err := e.BackupScheduleModel.Delete(appspace.AppspaceID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+
+	writeOK(w)
+}
+
+func (e *AppspaceBackupRoutes) getEncryption(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+
+	key, err := e.BackupKeyModel.Get(appspace.AppspaceID)
+	if err == domain.ErrNoRowsInResultSet {
+		writeJSON(w, BackupEncryption{})
+		return
+	}
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+
+	writeJSON(w, BackupEncryption{
+		Enabled: true,
+		Created: nulltypes.NewTime(key.Created, true)})
+}
+
+// setEncryption derives the backup key from the passphrase.
+// Only the public part of the key is stored,
+// so the passphrase is needed to decrypt the backups.
+func (e *AppspaceBackupRoutes) setEncryption(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+
+	reqData := SetBackupEncryption{}
+	err := readJSON(r, &reqData)
+	if err != nil {
+		writeBadRequest(w, "passphrase", err.Error())
+		return
+	}
+	err = validator.BackupPassphrase(reqData.Passphrase)
+	if err != nil {
+		writeBadRequest(w, "passphrase", err.Error())
+		return
+	}
+
+	recipient, err := backupcrypt.NewRecipient(reqData.Passphrase, backupcrypt.DefaultParams)
+	if err != nil {
+		e.getLogger("setEncryption, NewRecipient").Error(err)
+		returnError(w, err)
+		return
+	}
+
+	err = e.BackupKeyModel.Set(appspace.AppspaceID, recipient.String())
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+
+	writeOK(w)
+}
+
+func (e *AppspaceBackupRoutes) deleteEncryption(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+
+	err := e.BackupKeyModel.Delete(appspace.AppspaceID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+
+	writeOK(w)
+}
+
 func (e *AppspaceBackupRoutes) getLogger(note string) *record.DsLogger {
 	r := record.NewDsLogger().AddNote("AppspaceBackupRoutes")
 	if note != "" {
@@ -128,3 +440,54 @@ func getArchiveFromPath(r *http.Request) (string, error) {
 
 	return archive, nil
 }
+
+func setDownloadHeaders(w http.ResponseWriter, domainName string, archive string, encrypt bool) {
+	splitDomain := strings.SplitN(domainName, ".", 2)
+	downloadFileName := splitDomain[0] + "-" + strings.TrimSuffix(archive, ".snapshot")
+	if !strings.HasSuffix(downloadFileName, ".zip") && !strings.HasSuffix(downloadFileName, ".zip.enc") {
+		downloadFileName += ".zip"
+	}
+	if encrypt {
+		downloadFileName += ".enc"
+	}
+
+	if strings.HasSuffix(downloadFileName, ".enc") {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	} else {
+		w.Header().Set("Content-Type", "application/zip")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", downloadFileName))
+}
+
+// exportWriter returns a writer that encrypts to w if encrypt is true
+// The returned close function must be called after writing.
+func exportWriter(w io.Writer, recipient backupcrypt.Recipient, encrypt bool) (io.Writer, func() error, error) {
+	if !encrypt {
+		return w, func() error { return nil }, nil
+	}
+	ew, err := backupcrypt.Encrypt(w, recipient)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ew, ew.Close, nil
+}
+
+// firstWriteWriter calls onFirst before the first write
+// to get the writer to write to.
+type firstWriteWriter struct {
+	w       io.Writer
+	onFirst func() (io.Writer, error)
+	written bool
+}
+
+func (f *firstWriteWriter) Write(p []byte) (int, error) {
+	if !f.written {
+		f.written = true
+		w, err := f.onFirst()
+		if err != nil {
+			return 0, err
+		}
+		f.w = w
+	}
+	return f.w.Write(p)
+}
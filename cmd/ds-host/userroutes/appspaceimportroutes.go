@@ -0,0 +1,142 @@
+package userroutes
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/ratelimit"
+	"github.com/teleclimber/DropServer/internal/backupcrypt"
+	"github.com/teleclimber/DropServer/internal/validator"
+)
+
+// ImportBundleResp is the response to the upload of an export bundle
+type ImportBundleResp struct {
+	Token string                    `json:"token"`
+	Meta  domain.AppspaceBundleMeta `json:"meta"`
+}
+
+// PostImportReq is the data needed to create the appspace from the bundle
+type PostImportReq struct {
+	DomainName string `json:"domain_name"`
+	Subdomain  string `json:"subdomain"`
+	DropID     string `json:"dropid"`
+	// Passphrase is required if the bundle's data is encrypted
+	Passphrase string `json:"passphrase"`
+}
+
+// AppspaceImportRoutes create appspaces from export bundles
+// made on this or another host.
+type AppspaceImportRoutes struct {
+	ImportAppspace interface {
+		Prepare(userID domain.UserID, reader io.Reader) (string, domain.AppspaceBundleMeta, error)
+		GetMeta(userID domain.UserID, tok string) (domain.AppspaceBundleMeta, error)
+		Import(tok string, dropID domain.DropID, baseDomain, subDomain string, passphrase string) (domain.AppspaceID, error)
+	} `checkinject:"required"`
+	DropIDModel interface {
+		Get(handle string, dom string) (domain.DropID, error)
+	} `checkinject:"required"`
+	LoginLimiter interface {
+		Middleware(route string, account func(*http.Request) string, blocked func(http.ResponseWriter, *http.Request, time.Duration)) func(http.Handler) http.Handler
+	} `checkinject:"required"`
+}
+
+// - POST /upload :upload a bundle, returns a token and the bundle's metadata
+// - GET /<token> :get the metadata again
+// - POST /<token> :create the appspace, installing the app if needed
+
+func (i *AppspaceImportRoutes) subRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Use(mustBeAuthenticated)
+
+	r.Post("/upload", i.upload)
+	r.Get("/{token}", i.getMeta)
+	r.With(i.LoginLimiter.Middleware("backup-passphrase", passphraseAccount, nil)).Post("/{token}", i.importBundle)
+
+	return r
+}
+
+func (i *AppspaceImportRoutes) upload(w http.ResponseWriter, r *http.Request) {
+	userID, _ := domain.CtxAuthUserID(r.Context())
+
+	f, _, err := r.FormFile("bundle")
+	if err != nil {
+		http.Error(w, "unable to get bundle file from multipart: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer f.Close()
+
+	tok, meta, err := i.ImportAppspace.Prepare(userID, f)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, ImportBundleResp{Token: tok, Meta: meta})
+}
+
+func (i *AppspaceImportRoutes) getMeta(w http.ResponseWriter, r *http.Request) {
+	userID, _ := domain.CtxAuthUserID(r.Context())
+	token := chi.URLParam(r, "token")
+
+	meta, err := i.ImportAppspace.GetMeta(userID, token)
+	if err == domain.ErrTokenNotFound {
+		returnError(w, errNotFound)
+		return
+	}
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+
+	writeJSON(w, ImportBundleResp{Token: token, Meta: meta})
+}
+
+func (i *AppspaceImportRoutes) importBundle(w http.ResponseWriter, r *http.Request) {
+	userID, _ := domain.CtxAuthUserID(r.Context())
+	token := chi.URLParam(r, "token")
+
+	reqData := PostImportReq{}
+	err := readJSON(r, &reqData)
+	if err != nil {
+		ratelimit.Ignore(r)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = validator.DropIDFull(reqData.DropID)
+	if err != nil {
+		writeBadRequest(w, "dropid", err.Error())
+		return
+	}
+	dropIDHandle, dropIDDomain := validator.SplitDropID(validator.NormalizeDropIDFull(reqData.DropID))
+	dropID, err := i.DropIDModel.Get(dropIDHandle, dropIDDomain)
+	if err == domain.ErrNoRowsInResultSet {
+		http.Error(w, "DropID not found", http.StatusGone)
+		return
+	}
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	if dropID.UserID != userID {
+		returnError(w, errForbidden)
+		return
+	}
+
+	appspaceID, err := i.ImportAppspace.Import(token, dropID, reqData.DomainName, reqData.Subdomain, reqData.Passphrase)
+	switch err {
+	case nil:
+		writeJSON(w, PostAppspaceResp{AppspaceID: appspaceID})
+	case domain.ErrTokenNotFound:
+		returnError(w, errNotFound)
+	case domain.ErrBackupEncrypted:
+		writeBadRequest(w, "passphrase", "the bundle data is encrypted")
+	case backupcrypt.ErrBadPassphrase:
+		writeBadRequest(w, "passphrase", err.Error())
+	default:
+		handleError(w, err)
+	}
+}
@@ -0,0 +1,187 @@
+package userroutes
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+// AppspaceLogRetentionResp has the retention set on the appspace's log
+// and the host defaults that apply where it is zero.
+type AppspaceLogRetentionResp struct {
+	Retention domain.LogRetention `json:"retention"`
+	Default   domain.LogRetention `json:"default"`
+}
+
+// AppspaceLogRoutes let the owner see the appspace log
+// and download its archived segments
+type AppspaceLogRoutes struct {
+	Config         *domain.RuntimeConfig `checkinject:"required"`
+	AppspaceLogger interface {
+		Open(appspaceID domain.AppspaceID) domain.LoggerI
+		SetRetention(appspaceID domain.AppspaceID, ret domain.LogRetention) error
+		Archives(appspaceID domain.AppspaceID) ([]domain.LogArchive, error)
+		ArchivePath(appspaceID domain.AppspaceID, name string) (string, error)
+	} `checkinject:"required"`
+	AppspaceLogModel interface {
+		GetRetention(appspaceID domain.AppspaceID) (domain.LogRetention, error)
+	} `checkinject:"required"`
+}
+
+// - GET / :last entries of the log
+// - GET /query?from=&to=&source=&text=&limit=&before=
+//   :entries matching filters, newest first. Times are RFC3339.
+//   Pass the returned "next" as "before" to get older entries.
+// - GET /archives :list of archived segments
+// - GET /archives/{archive} :download gzipped segment
+// - GET /retention
+// - POST /retention :set how long archives are kept
+
+func (a *AppspaceLogRoutes) subRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/", a.getLog)
+	r.Get("/query", a.queryLog)
+	r.Get("/archives", a.getArchives)
+	r.Get("/archives/{archive}", a.downloadArchive)
+	r.Get("/retention", a.getRetention)
+	r.Post("/retention", a.postRetention)
+	return r
+}
+
+func (a *AppspaceLogRoutes) getLog(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+	logger := a.AppspaceLogger.Open(appspace.AppspaceID)
+	if logger == nil {
+		writeJSON(w, domain.LogChunk{})
+		return
+	}
+	chunk, err := logger.GetLastBytes(4 * 1024)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, chunk)
+}
+
+func (a *AppspaceLogRoutes) queryLog(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+
+	q, err := logQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	logger := a.AppspaceLogger.Open(appspace.AppspaceID)
+	if logger == nil {
+		writeJSON(w, domain.LogQueryResult{Entries: []domain.LogEntry{}})
+		return
+	}
+	res, err := logger.Query(q)
+	if err == domain.ErrLogCursor {
+		writeBadRequest(w, "before", err.Error())
+		return
+	}
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, res)
+}
+
+func logQuery(r *http.Request) (q domain.LogQuery, err error) {
+	v := r.URL.Query()
+	q.From, err = queryTime(r, "from")
+	if err != nil {
+		return
+	}
+	q.To, err = queryTime(r, "to")
+	if err != nil {
+		return
+	}
+	if s := v.Get("limit"); s != "" {
+		q.Limit, err = strconv.Atoi(s)
+		if err != nil || q.Limit < 0 {
+			err = errors.New("invalid limit")
+			return
+		}
+	}
+	q.Source = v.Get("source")
+	q.Text = v.Get("text")
+	q.Before = v.Get("before")
+	return
+}
+
+func (a *AppspaceLogRoutes) getArchives(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+	archives, err := a.AppspaceLogger.Archives(appspace.AppspaceID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, archives)
+}
+
+func (a *AppspaceLogRoutes) downloadArchive(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+	archive := chi.URLParam(r, "archive")
+	p, err := a.AppspaceLogger.ArchivePath(appspace.AppspaceID, archive)
+	if os.IsNotExist(err) {
+		returnError(w, errNotFound)
+		return
+	}
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	splitDomain := strings.SplitN(appspace.DomainName, ".", 2)
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-%s\"", splitDomain[0], archive))
+	http.ServeFile(w, r, p)
+}
+
+func (a *AppspaceLogRoutes) getRetention(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+	ret, err := a.AppspaceLogModel.GetRetention(appspace.AppspaceID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, AppspaceLogRetentionResp{
+		Retention: ret,
+		Default: domain.LogRetention{
+			KeepArchives: a.Config.AppspaceLogs.KeepArchives,
+			KeepDays:     a.Config.AppspaceLogs.KeepDays}})
+}
+
+func (a *AppspaceLogRoutes) postRetention(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+
+	var ret domain.LogRetention
+	err := readJSON(r, &ret)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if ret.KeepArchives < 0 {
+		writeBadRequest(w, "keep_archives", "can not be negative")
+		return
+	}
+	if ret.KeepDays < 0 {
+		writeBadRequest(w, "keep_days", "can not be negative")
+		return
+	}
+
+	err = a.AppspaceLogger.SetRetention(appspace.AppspaceID, ret)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeOK(w)
+}
@@ -0,0 +1,92 @@
+package userroutes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+)
+
+func TestPostLogRetention(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	appspaceID := domain.AppspaceID(7)
+
+	appspaceLogger := testmocks.NewMockAppspaceLogger(mockCtrl)
+	appspaceLogger.EXPECT().SetRetention(appspaceID, domain.LogRetention{KeepArchives: 4}).Return(nil)
+
+	a := &AppspaceLogRoutes{
+		AppspaceLogger: appspaceLogger,
+	}
+
+	cases := []struct {
+		body string
+		code int
+	}{
+		{`{"keep_archives":4}`, http.StatusOK},
+		{`{"keep_days":-1}`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest(http.MethodPost, "/retention", strings.NewReader(c.body))
+		req = req.WithContext(domain.CtxWithAppspaceData(req.Context(), domain.Appspace{AppspaceID: appspaceID}))
+		rr := httptest.NewRecorder()
+		a.subRouter().ServeHTTP(rr, req)
+		if rr.Code != c.code {
+			t.Errorf("%v: expected status %v, got %v", c.body, c.code, rr.Code)
+		}
+	}
+}
+
+func TestDownloadLogArchiveNotFound(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	appspaceID := domain.AppspaceID(7)
+
+	appspaceLogger := testmocks.NewMockAppspaceLogger(mockCtrl)
+	appspaceLogger.EXPECT().ArchivePath(appspaceID, "log-x.txt.gz").Return("", os.ErrNotExist)
+
+	a := &AppspaceLogRoutes{
+		AppspaceLogger: appspaceLogger,
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/archives/log-x.txt.gz", nil)
+	req = req.WithContext(domain.CtxWithAppspaceData(req.Context(), domain.Appspace{AppspaceID: appspaceID, DomainName: "as.example.com"}))
+	rr := httptest.NewRecorder()
+	a.subRouter().ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected not found, got %v", rr.Code)
+	}
+}
+
+func TestLogQuery(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/query?from=2026-10-17T01:00:00Z&source=sandbox&text=err&limit=20&before=abc", nil)
+	q, err := logQuery(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := domain.LogQuery{
+		From:   time.Date(2026, time.October, 17, 1, 0, 0, 0, time.UTC),
+		Source: "sandbox",
+		Text:   "err",
+		Limit:  20,
+		Before: "abc"}
+	if q != expected {
+		t.Errorf("unexpected query: %v", q)
+	}
+
+	for _, qs := range []string{"from=yesterday", "to=1", "limit=-3", "limit=x"} {
+		req, _ = http.NewRequest(http.MethodGet, "/query?"+qs, nil)
+		_, err = logQuery(req)
+		if err == nil {
+			t.Errorf("expected error for %v", qs)
+		}
+	}
+}
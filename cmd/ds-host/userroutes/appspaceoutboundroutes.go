@@ -0,0 +1,90 @@
+package userroutes
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+// AppspaceOutboundResp lists the outbound hosts declared by the
+// appspace's app version and those approved by the owner.
+// Approved is null if the owner never approved hosts,
+// in which case the appspace can connect to any host.
+type AppspaceOutboundResp struct {
+	Declared []string `json:"declared"`
+	Approved []string `json:"approved"`
+}
+
+// PostAppspaceOutboundReq sets the approved outbound hosts
+type PostAppspaceOutboundReq struct {
+	Hosts []string `json:"hosts"`
+}
+
+// AppspaceOutboundRoutes let the owner approve
+// the hosts the appspace can connect to
+type AppspaceOutboundRoutes struct {
+	OutboundPolicy interface {
+		GetDeclared(appID domain.AppID, version domain.Version) ([]string, error)
+		Approve(appspace domain.Appspace, version domain.Version, hosts []string) error
+	} `checkinject:"required"`
+	AppspaceOutboundModel interface {
+		Get(appspaceID domain.AppspaceID) ([]string, error)
+	} `checkinject:"required"`
+}
+
+// - GET / :declared and approved hosts
+// - POST / :replace approved hosts
+
+func (a *AppspaceOutboundRoutes) subRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/", a.getOutbound)
+	r.Post("/", a.postOutbound)
+	return r
+}
+
+func (a *AppspaceOutboundRoutes) getOutbound(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+	declared := []string{}
+	if appspace.AppVersion != domain.Version("") {
+		var err error
+		declared, err = a.OutboundPolicy.GetDeclared(appspace.AppID, appspace.AppVersion)
+		if err != nil {
+			returnError(w, err)
+			return
+		}
+	}
+	approved, err := a.AppspaceOutboundModel.Get(appspace.AppspaceID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, AppspaceOutboundResp{
+		Declared: declared,
+		Approved: approved})
+}
+
+func (a *AppspaceOutboundRoutes) postOutbound(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+
+	var reqData PostAppspaceOutboundReq
+	err := readJSON(r, &reqData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if reqData.Hosts == nil {
+		reqData.Hosts = []string{}
+	}
+
+	err = a.OutboundPolicy.Approve(appspace, appspace.AppVersion, reqData.Hosts)
+	if err == domain.ErrOutboundNotDeclared {
+		writeBadRequest(w, "hosts", err.Error())
+		return
+	}
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeOK(w)
+}
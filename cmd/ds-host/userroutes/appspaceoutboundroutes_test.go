@@ -0,0 +1,44 @@
+package userroutes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+)
+
+func TestPostOutbound(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	appspace := domain.Appspace{AppspaceID: domain.AppspaceID(7), AppID: domain.AppID(11), AppVersion: domain.Version("0.1.0")}
+
+	outboundPolicy := testmocks.NewMockOutboundPolicy(mockCtrl)
+	outboundPolicy.EXPECT().Approve(appspace, appspace.AppVersion, []string{"api.example.com"}).Return(nil)
+	outboundPolicy.EXPECT().Approve(appspace, appspace.AppVersion, []string{"evil.example.com"}).Return(domain.ErrOutboundNotDeclared)
+
+	a := &AppspaceOutboundRoutes{
+		OutboundPolicy: outboundPolicy,
+	}
+
+	cases := []struct {
+		body string
+		code int
+	}{
+		{`{"hosts":["api.example.com"]}`, http.StatusOK},
+		{`{"hosts":["evil.example.com"]}`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(c.body))
+		req = req.WithContext(domain.CtxWithAppspaceData(req.Context(), appspace))
+		rr := httptest.NewRecorder()
+		a.subRouter().ServeHTTP(rr, req)
+		if rr.Code != c.code {
+			t.Errorf("%v: expected status %v, got %v", c.body, c.code, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package userroutes
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+// AppspaceQuotaResp has the quotas set on an appspace
+// and the limits it ran into
+type AppspaceQuotaResp struct {
+	Owner     domain.AppspaceQuota      `json:"owner"`
+	Admin     domain.AppspaceQuota      `json:"admin"`
+	Effective domain.AppspaceQuota      `json:"effective"`
+	Hits      []domain.AppspaceLimitHit `json:"hits"`
+}
+
+// AppspaceQuotaRoutes let the owner set resource quotas on their appspace.
+// The admin's quota still applies if it is tighter.
+type AppspaceQuotaRoutes struct {
+	AppspaceQuotaModel interface {
+		Get(appspaceID domain.AppspaceID, setBy string) (domain.AppspaceQuota, error)
+		Set(appspaceID domain.AppspaceID, setBy string, q domain.AppspaceQuota) error
+		GetHits(appspaceID domain.AppspaceID) ([]domain.AppspaceLimitHit, error)
+		ClearHits(appspaceID domain.AppspaceID) error
+	} `checkinject:"required"`
+	SandboxManager interface {
+		StopAppspace(domain.AppspaceID)
+	} `checkinject:"required"`
+}
+
+// - GET / :quotas and limit hits
+// - POST / :set the owner's quota
+// - DELETE /hits :forget limit hits
+
+func (a *AppspaceQuotaRoutes) subRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/", a.getQuota)
+	r.Post("/", a.postQuota)
+	r.Delete("/hits", a.deleteHits)
+	return r
+}
+
+func (a *AppspaceQuotaRoutes) getQuota(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+	resp, err := getQuotaResp(a.AppspaceQuotaModel, appspace.AppspaceID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func (a *AppspaceQuotaRoutes) postQuota(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+
+	var q domain.AppspaceQuota
+	err := readJSON(r, &q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if field, msg := validateQuota(q); field != "" {
+		writeBadRequest(w, field, msg)
+		return
+	}
+
+	err = a.AppspaceQuotaModel.Set(appspace.AppspaceID, domain.QuotaSetByOwner, q)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	// the sandbox picks up the new quota when it next starts
+	a.SandboxManager.StopAppspace(appspace.AppspaceID)
+
+	resp, err := getQuotaResp(a.AppspaceQuotaModel, appspace.AppspaceID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func (a *AppspaceQuotaRoutes) deleteHits(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+	err := a.AppspaceQuotaModel.ClearHits(appspace.AppspaceID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeOK(w)
+}
+
+type quotaGetter interface {
+	Get(appspaceID domain.AppspaceID, setBy string) (domain.AppspaceQuota, error)
+	GetHits(appspaceID domain.AppspaceID) ([]domain.AppspaceLimitHit, error)
+}
+
+func getQuotaResp(model quotaGetter, appspaceID domain.AppspaceID) (resp AppspaceQuotaResp, err error) {
+	resp.Owner, err = model.Get(appspaceID, domain.QuotaSetByOwner)
+	if err != nil {
+		return
+	}
+	resp.Admin, err = model.Get(appspaceID, domain.QuotaSetByAdmin)
+	if err != nil {
+		return
+	}
+	resp.Effective = resp.Owner.Tighter(resp.Admin)
+	resp.Hits, err = model.GetHits(appspaceID)
+	return
+}
+
+// minQuotaMemoryMb is the smallest memory quota that lets a sandbox start
+const minQuotaMemoryMb = 32
+
+// validateQuota returns the offending field and a message if the quota is invalid
+func validateQuota(q domain.AppspaceQuota) (string, string) {
+	if q.MemoryMaxMb < 0 || (q.MemoryMaxMb != 0 && q.MemoryMaxMb < minQuotaMemoryMb) {
+		return "memory_max_mb", "memory quota must be 0 or at least 32 MB"
+	}
+	if q.CPUMaxPercent < 0 {
+		return "cpu_max_percent", "CPU quota can not be negative"
+	}
+	for _, l := range []struct {
+		field string
+		v     int
+	}{
+		{"io_read_bps", q.IOReadBps},
+		{"io_write_bps", q.IOWriteBps},
+		{"io_read_iops", q.IOReadIOPS},
+		{"io_write_iops", q.IOWriteIOPS},
+		{"disk_max_mb", q.DiskMaxMb},
+		{"out_req_per_min", q.OutReqPerMin},
+		{"out_mb_per_day", q.OutMbPerDay}} {
+		if l.v < 0 {
+			return l.field, "quota can not be negative"
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,73 @@
+package userroutes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+)
+
+func TestValidateQuota(t *testing.T) {
+	cases := []struct {
+		q     domain.AppspaceQuota
+		field string
+	}{
+		{domain.AppspaceQuota{}, ""},
+		{domain.AppspaceQuota{MemoryMaxMb: 64, CPUMaxPercent: 150, DiskMaxMb: 10}, ""},
+		{domain.AppspaceQuota{MemoryMaxMb: 8}, "memory_max_mb"},
+		{domain.AppspaceQuota{CPUMaxPercent: -1}, "cpu_max_percent"},
+		{domain.AppspaceQuota{IOWriteBps: -1}, "io_write_bps"},
+		{domain.AppspaceQuota{DiskMaxMb: -5}, "disk_max_mb"},
+	}
+	for _, c := range cases {
+		field, _ := validateQuota(c.q)
+		if field != c.field {
+			t.Errorf("%v: expected field %v, got %v", c.q, c.field, field)
+		}
+	}
+}
+
+func TestPostQuota(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	appspaceID := domain.AppspaceID(7)
+	owner := domain.AppspaceQuota{MemoryMaxMb: 256, CPUMaxPercent: 50}
+	admin := domain.AppspaceQuota{MemoryMaxMb: 128}
+
+	quotaModel := testmocks.NewMockAppspaceQuotaModel(mockCtrl)
+	quotaModel.EXPECT().Set(appspaceID, domain.QuotaSetByOwner, owner).Return(nil)
+	quotaModel.EXPECT().Get(appspaceID, domain.QuotaSetByOwner).Return(owner, nil)
+	quotaModel.EXPECT().Get(appspaceID, domain.QuotaSetByAdmin).Return(admin, nil)
+	quotaModel.EXPECT().GetHits(appspaceID).Return([]domain.AppspaceLimitHit{}, nil)
+
+	sandboxManager := testmocks.NewMockSandboxManager(mockCtrl)
+	sandboxManager.EXPECT().StopAppspace(appspaceID)
+
+	a := &AppspaceQuotaRoutes{
+		AppspaceQuotaModel: quotaModel,
+		SandboxManager:     sandboxManager,
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(`{"memory_max_mb":256,"cpu_max_percent":50}`))
+	req = req.WithContext(domain.CtxWithAppspaceData(req.Context(), domain.Appspace{AppspaceID: appspaceID}))
+	rr := httptest.NewRecorder()
+	a.subRouter().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status %v: %v", rr.Code, rr.Body.String())
+	}
+	var resp AppspaceQuotaResp
+	err := json.Unmarshal(rr.Body.Bytes(), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Effective != (domain.AppspaceQuota{MemoryMaxMb: 128, CPUMaxPercent: 50}) {
+		t.Errorf("unexpected effective quota: %v", resp.Effective)
+	}
+}
@@ -1,17 +1,24 @@
 package userroutes
 
 import (
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/ratelimit"
+	"github.com/teleclimber/DropServer/internal/backupcrypt"
 	"github.com/teleclimber/DropServer/internal/validator"
 )
 
+// SelectBackup selects a backup to restore.
+// If Remote is set the backup is fetched from that backup remote.
 type SelectBackup struct {
 	BackupFilename string `json:"backup_file"`
+	Remote         string `json:"remote"`
 }
 
 type RestoreError struct {
@@ -24,25 +31,41 @@ type RestoreError struct {
 
 //  RestoreData is the response to the selection / upload of an appspace archive
 type RestoreData struct {
-	Token  string        `json:"token"`
-	Error  *RestoreError `json:"err"`
-	Schema int           `json:"schema"`
+	Token string `json:"token"`
+	// Encrypted is true if the archive needs a passphrase
+	// before it can be checked and restored
+	Encrypted bool          `json:"encrypted"`
+	Error     *RestoreError `json:"err"`
+	Schema    int           `json:"schema"`
 	// more stuff...
 }
 
+// DecryptBackup provides the passphrase of an encrypted archive
+type DecryptBackup struct {
+	Passphrase string `json:"passphrase"`
+}
+
 type AppspaceRestoreRoutes struct {
 	RestoreAppspace interface {
 		Prepare(reader io.Reader) (string, error)
 		PrepareBackup(appspaceID domain.AppspaceID, backupFile string) (string, error)
+		PrepareRemoteBackup(appspaceID domain.AppspaceID, remote string, backupFile string) (string, error)
+		IsEncrypted(tok string) (bool, error)
+		Decrypt(tok string, passphrase string) error
 		CheckAppspaceDataValid(tok string) error
 		GetMetaInfo(tok string) (domain.AppspaceMetaInfo, error)
 		ReplaceData(tok string, appspaceID domain.AppspaceID) error
 	} `checkinject:"required"`
+	LoginLimiter interface {
+		Middleware(route string, account func(*http.Request) string, blocked func(http.ResponseWriter, *http.Request, time.Duration)) func(http.Handler) http.Handler
+	} `checkinject:"required"`
 }
 
 // There are basically two routes:
 // post /
 // post /<token>
+// And if the archive is encrypted:
+// post /<token>/decrypt
 
 func (e *AppspaceRestoreRoutes) subRouter() http.Handler {
 	r := chi.NewRouter()
@@ -51,6 +74,7 @@ func (e *AppspaceRestoreRoutes) subRouter() http.Handler {
 	r.Post("/", e.useBackup)
 	r.Post("/upload", e.upload)
 	r.Post("/{token}", e.commit)
+	r.With(e.LoginLimiter.Middleware("backup-passphrase", passphraseAccount, nil)).Post("/{token}/decrypt", e.decrypt)
 
 	return r
 }
@@ -71,55 +95,98 @@ func (e *AppspaceRestoreRoutes) useBackup(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	tok, err := e.RestoreAppspace.PrepareBackup(appspace.AppspaceID, reqData.BackupFilename)
+	var tok string
+	if reqData.Remote != "" {
+		tok, err = e.RestoreAppspace.PrepareRemoteBackup(appspace.AppspaceID, reqData.Remote, reqData.BackupFilename)
+	} else {
+		tok, err = e.RestoreAppspace.PrepareBackup(appspace.AppspaceID, reqData.BackupFilename)
+	}
+	if err == domain.ErrRemoteBackupNotFound {
+		returnError(w, errNotFound)
+		return
+	}
 	if err != nil {
 		handleError(w, err)
 		return
 	}
 
-	resp := RestoreData{
-		Token: tok}
+	e.writeRestoreData(w, tok)
+}
 
-	err = e.RestoreAppspace.CheckAppspaceDataValid(tok)
+func (e *AppspaceRestoreRoutes) upload(w http.ResponseWriter, r *http.Request) {
+	f, _, err := r.FormFile("zip")
 	if err != nil {
-		if badZipErr, ok := err.(domain.BadRestoreZip); ok {
-			resp.Error = &RestoreError{
-				MissingFiles: badZipErr.MissingFiles(),
-				ZipFiles:     badZipErr.ZipFiles()}
-			writeJSON(w, resp)
-		} else {
-			handleError(w, err)
-		}
+		http.Error(w, "unable to get zip file from multipart: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	info, err := e.RestoreAppspace.GetMetaInfo(tok)
+	tok, err := e.RestoreAppspace.Prepare(f)
 	if err != nil {
 		handleError(w, err)
 		return
 	}
 
-	resp.Schema = info.Schema
-
-	writeJSON(w, resp)
+	e.writeRestoreData(w, tok)
 }
 
-func (e *AppspaceRestoreRoutes) upload(w http.ResponseWriter, r *http.Request) {
-	f, _, err := r.FormFile("zip")
+// decrypt an encrypted archive with the passphrase,
+// then respond as if it had just been uploaded.
+func (e *AppspaceRestoreRoutes) decrypt(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	reqData := DecryptBackup{}
+	err := readJSON(r, &reqData)
 	if err != nil {
-		http.Error(w, "unable to get zip file from multipart: "+err.Error(), http.StatusBadRequest)
+		ratelimit.Ignore(r)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	tok, err := e.RestoreAppspace.Prepare(f)
+	err = e.RestoreAppspace.Decrypt(token, reqData.Passphrase)
+	if err == backupcrypt.ErrBadPassphrase {
+		writeBadRequest(w, "passphrase", err.Error())
+		return
+	}
+	if err == domain.ErrTokenNotFound {
+		returnError(w, errNotFound)
+		return
+	}
 	if err != nil {
 		handleError(w, err)
 		return
 	}
 
+	e.writeRestoreData(w, token)
+}
+
+// passphraseAccount throttles passphrase guesses per user.
+// Each guess costs a lot of memory to check so it is limited like a login.
+func passphraseAccount(r *http.Request) string {
+	userID, ok := domain.CtxAuthUserID(r.Context())
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("user %d (backup passphrase)", userID)
+}
+
+// writeRestoreData checks the prepared archive
+// and responds with its info or with what is wrong with it
+func (e *AppspaceRestoreRoutes) writeRestoreData(w http.ResponseWriter, tok string) {
 	resp := RestoreData{
 		Token: tok}
 
+	encrypted, err := e.RestoreAppspace.IsEncrypted(tok)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	if encrypted {
+		// ask for the passphrase before anything else
+		resp.Encrypted = true
+		writeJSON(w, resp)
+		return
+	}
+
 	// Note that checking this here an bailing if incorrect is not right?
 	// This needs to be versioned according to ds-api
 	// And we need to try and get ds-api of zip, then process the full zip according to that API.
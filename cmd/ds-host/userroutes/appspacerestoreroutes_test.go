@@ -0,0 +1,45 @@
+package userroutes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+	"github.com/teleclimber/DropServer/internal/backupcrypt"
+)
+
+func TestDecryptThrottled(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	calls := 0
+	restore := testmocks.NewMockRestoreAppspace(mockCtrl)
+	restore.EXPECT().Decrypt("tok", "guess").AnyTimes().DoAndReturn(func(tok, passphrase string) error {
+		calls++
+		return backupcrypt.ErrBadPassphrase
+	})
+
+	e := &AppspaceRestoreRoutes{
+		RestoreAppspace: restore,
+		LoginLimiter:    getLoginLimiter(t)}
+	router := e.subRouter()
+
+	code := 0
+	for i := 0; i < 10; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/tok/decrypt", strings.NewReader(`{"passphrase":"guess"}`))
+		req = req.WithContext(domain.CtxWithAuthUserID(req.Context(), domain.UserID(7)))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		code = rr.Code
+	}
+	if code != http.StatusTooManyRequests {
+		t.Errorf("expected guesses to be throttled, got %v", code)
+	}
+	if calls > 6 {
+		t.Errorf("expected throttled guesses to not be checked, got %v checks", calls)
+	}
+}
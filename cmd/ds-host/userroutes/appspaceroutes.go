@@ -23,6 +23,7 @@ type AppspaceResp struct {
 	DropID         string                     `json:"dropid"`
 	Created        time.Time                  `json:"created_dt"`
 	Paused         bool                       `json:"paused"`
+	Redirect       string                     `json:"redirect,omitempty"`
 	Status         domain.AppspaceStatusEvent `json:"status"`
 	TSNetStatus    domain.TSNetAppspaceStatus `json:"tsnet_status"`
 	UpgradeVersion domain.Version             `json:"upgrade_version,omitempty"`
@@ -32,11 +33,19 @@ type AppspaceResp struct {
 
 // AppspaceRoutes handles routes for appspace uploading, creating, deleting.
 type AppspaceRoutes struct {
-	Config                domain.RuntimeConfig `checkinject:"required"`
-	AppspaceUserRoutes    subRoutes            `checkinject:"required"`
-	AppspaceExportRoutes  subRoutes            `checkinject:"required"`
-	AppspaceRestoreRoutes subRoutes            `checkinject:"required"`
-	AppModel              interface {
+	Config                 domain.RuntimeConfig `checkinject:"required"`
+	AppspaceUserRoutes     subRoutes            `checkinject:"required"`
+	AppspaceExportRoutes   subRoutes            `checkinject:"required"`
+	AppspaceRestoreRoutes  subRoutes            `checkinject:"required"`
+	AppspaceImportRoutes   subRoutes            `checkinject:"required"`
+	AppspaceTransferRoutes subRoutes            `checkinject:"required"`
+	AppspaceQuotaRoutes    subRoutes            `checkinject:"required"`
+	AppspaceLogRoutes      subRoutes            `checkinject:"required"`
+	AccessLogRoutes        subRoutes            `checkinject:"required"`
+	AppspaceOutboundRoutes subRoutes            `checkinject:"required"`
+	AppspaceSessionRoutes  subRoutes            `checkinject:"required"`
+	TransferTicketRoutes   subRoutes            `checkinject:"required"`
+	AppModel               interface {
 		GetFromID(domain.AppID) (domain.App, error)
 		GetVersion(domain.AppID, domain.Version) (domain.AppVersion, error)
 		GetVersionForUI(appID domain.AppID, version domain.Version) (domain.AppVersionUI, error)
@@ -64,7 +73,7 @@ type AppspaceRoutes struct {
 		GetPeerUsers(domain.AppspaceID) []domain.TSNetPeerUser
 	} `checkinject:"required"`
 	CreateAppspace interface {
-		Create(domain.DropID, domain.AppVersion, string, string) (domain.AppspaceID, domain.JobID, error)
+		Create(domain.DropID, domain.AppVersion, string, string, []string) (domain.AppspaceID, domain.JobID, error)
 	} `checkinject:"required"`
 	PauseAppspace interface {
 		Pause(appspaceID domain.AppspaceID, pause bool) error
@@ -72,9 +81,6 @@ type AppspaceRoutes struct {
 	DeleteAppspace interface {
 		Delete(domain.Appspace) error
 	} `checkinject:"required"`
-	AppspaceLogger interface {
-		Open(appspaceID domain.AppspaceID) domain.LoggerI
-	} `checkinject:"required"`
 	SandboxRunsModel interface {
 		AppspaceSums(ownerID domain.UserID, appspaceID domain.AppspaceID, from time.Time, to time.Time) (domain.SandboxRunData, error)
 	} `checkinject:"required"`
@@ -84,6 +90,9 @@ type AppspaceRoutes struct {
 	MigrationMinder interface {
 		GetForAppspace(domain.Appspace) (domain.Version, bool, error)
 	} `checkinject:"required"`
+	OutboundPolicy interface {
+		Validate(appID domain.AppID, version domain.Version, hosts []string) ([]string, error)
+	} `checkinject:"required"`
 }
 
 func (a *AppspaceRoutes) subRouter() http.Handler {
@@ -92,12 +101,13 @@ func (a *AppspaceRoutes) subRouter() http.Handler {
 
 	r.Get("/", a.getAppspaces) // return app vers for eah
 	r.Post("/", a.postNewAppspace)
+	r.Mount("/import", a.AppspaceImportRoutes.subRouter())
+	r.Mount("/transfer-ticket", a.TransferTicketRoutes.subRouter())
 
 	r.Route("/{appspace}", func(r chi.Router) {
 		r.Use(a.appspaceCtx)
 		r.Get("/", a.getAppspace) // reutnr app vers UI
 		r.Delete("/", a.deleteAppspace)
-		r.Get("/log", a.getLog)
 		r.Get("/usage", a.getUsage)
 		r.Post("/pause", a.changeAppspacePause)
 		r.Get("/tsnet/peerusers", a.getTSNetPeerUsers)
@@ -107,6 +117,12 @@ func (a *AppspaceRoutes) subRouter() http.Handler {
 		r.Mount("/user", a.AppspaceUserRoutes.subRouter())
 		r.Mount("/export", a.AppspaceExportRoutes.subRouter())
 		r.Mount("/restore", a.AppspaceRestoreRoutes.subRouter())
+		r.Mount("/transfer", a.AppspaceTransferRoutes.subRouter())
+		r.Mount("/quota", a.AppspaceQuotaRoutes.subRouter())
+		r.Mount("/log", a.AppspaceLogRoutes.subRouter())
+		r.Mount("/access-log", a.AccessLogRoutes.subRouter())
+		r.Mount("/outbound", a.AppspaceOutboundRoutes.subRouter())
+		r.Mount("/session", a.AppspaceSessionRoutes.subRouter())
 	})
 
 	return r
@@ -270,6 +286,7 @@ type PostAppspaceReq struct {
 	DomainName string         `json:"domain_name"`
 	Subdomain  string         `json:"subdomain"`
 	DropID     string         `json:"dropid"`
+	Outbound   []string       `json:"outbound"` // outbound hosts approved by owner
 }
 
 // PostAppspaceResp is the return data after creating a new appspace
@@ -336,10 +353,21 @@ func (a *AppspaceRoutes) postNewAppspace(w http.ResponseWriter, r *http.Request)
 
 	// Here we should check validity of requested domain?
 
+	outbound, err := a.OutboundPolicy.Validate(app.AppID, version.Version, reqData.Outbound)
+	if err == domain.ErrOutboundNotDeclared {
+		writeBadRequest(w, "outbound", err.Error())
+		return
+	}
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+
 	// TODO replace dropid with userID, make dropid optional
-	appspaceID, jobID, err := a.CreateAppspace.Create(dropID, version, reqData.DomainName, reqData.Subdomain)
+	appspaceID, jobID, err := a.CreateAppspace.Create(dropID, version, reqData.DomainName, reqData.Subdomain, outbound)
 	if err != nil {
 		returnError(w, err)
+		return
 	}
 
 	resp := PostAppspaceResp{
@@ -468,21 +496,6 @@ func (a *AppspaceRoutes) deleteAppspace(w http.ResponseWriter, r *http.Request)
 	}
 }
 
-func (a *AppspaceRoutes) getLog(w http.ResponseWriter, r *http.Request) {
-	appspace, _ := domain.CtxAppspaceData(r.Context())
-	logger := a.AppspaceLogger.Open(appspace.AppspaceID)
-	if logger == nil {
-		writeJSON(w, domain.LogChunk{})
-		return
-	}
-	chunk, err := logger.GetLastBytes(4 * 1024)
-	if err != nil {
-		returnError(w, err)
-		return
-	}
-	writeJSON(w, chunk)
-}
-
 func (a *AppspaceRoutes) getUsage(w http.ResponseWriter, r *http.Request) {
 	appspace, _ := domain.CtxAppspaceData(r.Context())
 
@@ -508,6 +521,7 @@ func (a *AppspaceRoutes) makeAppspaceMeta(appspace domain.Appspace) AppspaceResp
 		PortString: a.Config.Exec.PortString,
 		DropID:     appspace.DropID,
 		Paused:     appspace.Paused,
+		Redirect:   appspace.Redirect,
 		Created:    appspace.Created}
 }
 
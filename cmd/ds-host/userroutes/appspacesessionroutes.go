@@ -0,0 +1,77 @@
+package userroutes
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+// AppspaceSessionRoutes let owners see who is logged in to their appspace
+// and log users out.
+type AppspaceSessionRoutes struct {
+	CookieModel interface {
+		GetForAppspace(appspaceID domain.AppspaceID) ([]domain.Cookie, error)
+		Delete(cookieID string) error
+		DeleteForAppspace(appspaceID domain.AppspaceID) error
+		DeleteForProxy(appspaceID domain.AppspaceID, proxyID domain.ProxyID) error
+	} `checkinject:"required"`
+}
+
+// - GET / :sessions of all appspace users
+// - DELETE /?proxy_id= :log out one appspace user, or everyone if no proxy_id
+// - DELETE /{session_id} :log out of one session
+
+func (a *AppspaceSessionRoutes) subRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/", a.getSessions)
+	r.Delete("/", a.deleteSessions)
+	r.Delete("/{session_id}", a.deleteSession)
+	return r
+}
+
+func (a *AppspaceSessionRoutes) getSessions(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+	cookies, err := a.CookieModel.GetForAppspace(appspace.AppspaceID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, sessionsFromCookies(cookies, ""))
+}
+
+func (a *AppspaceSessionRoutes) deleteSessions(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+	var err error
+	proxyID := domain.ProxyID(r.URL.Query().Get("proxy_id"))
+	if proxyID == "" {
+		err = a.CookieModel.DeleteForAppspace(appspace.AppspaceID)
+	} else {
+		err = a.CookieModel.DeleteForProxy(appspace.AppspaceID, proxyID)
+	}
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *AppspaceSessionRoutes) deleteSession(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+	cookies, err := a.CookieModel.GetForAppspace(appspace.AppspaceID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	cookie, ok := findSession(cookies, chi.URLParam(r, "session_id"))
+	if !ok {
+		returnError(w, errNotFound)
+		return
+	}
+	err = a.CookieModel.Delete(cookie.CookieID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,32 @@
+package userroutes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+)
+
+func TestDeleteAppspaceSessions(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	appspaceID := domain.AppspaceID(7)
+
+	cm := testmocks.NewMockCookieModel(mockCtrl)
+	cm.EXPECT().DeleteForProxy(appspaceID, domain.ProxyID("abc")).Return(nil)
+	cm.EXPECT().DeleteForAppspace(appspaceID).Return(nil)
+
+	a := &AppspaceSessionRoutes{CookieModel: cm}
+	for _, target := range []string{"/?proxy_id=abc", "/"} {
+		req := httptest.NewRequest(http.MethodDelete, target, nil)
+		req = req.WithContext(domain.CtxWithAppspaceData(req.Context(), domain.Appspace{AppspaceID: appspaceID}))
+		rr := httptest.NewRecorder()
+		a.subRouter().ServeHTTP(rr, req)
+		if rr.Code != http.StatusNoContent {
+			t.Errorf("%v: unexpected status %v", target, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package userroutes
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/internal/validator"
+)
+
+// PostTransferReq starts the transfer of an appspace to another host
+type PostTransferReq struct {
+	// Code is the ticket code created on the receiving host
+	Code string `json:"code"`
+	// Redirect visitors to the new address once the transfer is done
+	Redirect bool `json:"redirect"`
+}
+
+// PostTransferTicketReq is the data needed to create the appspace
+// when it is received
+type PostTransferTicketReq struct {
+	DomainName string `json:"domain_name"`
+	Subdomain  string `json:"subdomain"`
+	DropID     string `json:"dropid"`
+}
+
+// AppspaceTransferRoutes push an appspace to another host
+type AppspaceTransferRoutes struct {
+	TransferAppspace interface {
+		Start(appspaceID domain.AppspaceID, code string, redirect bool) error
+		GetStatus(appspaceID domain.AppspaceID) (domain.AppspaceTransferStatus, bool)
+	} `checkinject:"required"`
+	AppspaceModel interface {
+		SetRedirect(appspaceID domain.AppspaceID, redirect string) error
+	} `checkinject:"required"`
+}
+
+// - GET / :status of the latest transfer
+// - POST / :start a transfer
+// - DELETE /redirect :stop redirecting to the transferred appspace
+
+func (a *AppspaceTransferRoutes) subRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/", a.getStatus)
+	r.Post("/", a.startTransfer)
+	r.Delete("/redirect", a.deleteRedirect)
+	return r
+}
+
+func (a *AppspaceTransferRoutes) getStatus(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+	status, ok := a.TransferAppspace.GetStatus(appspace.AppspaceID)
+	if !ok {
+		returnError(w, errNotFound)
+		return
+	}
+	writeJSON(w, status)
+}
+
+func (a *AppspaceTransferRoutes) startTransfer(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+
+	reqData := PostTransferReq{}
+	err := readJSON(r, &reqData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = a.TransferAppspace.Start(appspace.AppspaceID, reqData.Code, reqData.Redirect)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	status, _ := a.TransferAppspace.GetStatus(appspace.AppspaceID)
+	writeJSON(w, status)
+}
+
+func (a *AppspaceTransferRoutes) deleteRedirect(w http.ResponseWriter, r *http.Request) {
+	appspace, _ := domain.CtxAppspaceData(r.Context())
+	err := a.AppspaceModel.SetRedirect(appspace.AppspaceID, "")
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeOK(w)
+}
+
+// TransferTicketRoutes let the user receive appspaces from other hosts
+type TransferTicketRoutes struct {
+	ReceiveTransfer interface {
+		CreateTicket(dropID domain.DropID, baseDomain, subDomain string) (domain.AppspaceTransferTicket, error)
+		GetTickets(userID domain.UserID) []domain.AppspaceTransferTicket
+		DeleteTicket(userID domain.UserID, ticketID string) error
+	} `checkinject:"required"`
+	DropIDModel interface {
+		Get(handle string, dom string) (domain.DropID, error)
+	} `checkinject:"required"`
+}
+
+// - GET / :the user's tickets
+// - POST / :create a ticket. The response includes the code, which is not available later.
+// - DELETE /<ticket> :delete a ticket
+
+func (t *TransferTicketRoutes) subRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Use(mustBeAuthenticated)
+	r.Get("/", t.getTickets)
+	r.Post("/", t.createTicket)
+	r.Delete("/{ticket}", t.deleteTicket)
+	return r
+}
+
+func (t *TransferTicketRoutes) getTickets(w http.ResponseWriter, r *http.Request) {
+	userID, _ := domain.CtxAuthUserID(r.Context())
+	writeJSON(w, t.ReceiveTransfer.GetTickets(userID))
+}
+
+func (t *TransferTicketRoutes) createTicket(w http.ResponseWriter, r *http.Request) {
+	userID, _ := domain.CtxAuthUserID(r.Context())
+
+	reqData := PostTransferTicketReq{}
+	err := readJSON(r, &reqData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = validator.DropIDFull(reqData.DropID)
+	if err != nil {
+		writeBadRequest(w, "dropid", err.Error())
+		return
+	}
+	dropIDHandle, dropIDDomain := validator.SplitDropID(validator.NormalizeDropIDFull(reqData.DropID))
+	dropID, err := t.DropIDModel.Get(dropIDHandle, dropIDDomain)
+	if err == domain.ErrNoRowsInResultSet {
+		http.Error(w, "DropID not found", http.StatusGone)
+		return
+	}
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	if dropID.UserID != userID {
+		returnError(w, errForbidden)
+		return
+	}
+
+	ticket, err := t.ReceiveTransfer.CreateTicket(dropID, reqData.DomainName, reqData.Subdomain)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	writeJSON(w, ticket)
+}
+
+func (t *TransferTicketRoutes) deleteTicket(w http.ResponseWriter, r *http.Request) {
+	userID, _ := domain.CtxAuthUserID(r.Context())
+	err := t.ReceiveTransfer.DeleteTicket(userID, chi.URLParam(r, "ticket"))
+	if err == domain.ErrTokenNotFound {
+		returnError(w, errNotFound)
+		return
+	}
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeOK(w)
+}
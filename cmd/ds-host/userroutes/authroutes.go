@@ -4,16 +4,20 @@ package userroutes
 // Handle /login /appspace-login /logout
 import (
 	"database/sql"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/ratelimit"
 	"github.com/teleclimber/DropServer/internal/validator"
 )
 
 // AuthRoutes handles all routes related to authentication
 type AuthRoutes struct {
+	Config   *domain.RuntimeConfig `checkinject:"required"`
 	SetupKey interface {
 		Has() (bool, error)
 		Get() (string, error)
@@ -22,32 +26,74 @@ type AuthRoutes struct {
 	Views interface {
 		Login(http.ResponseWriter, domain.LoginViewData)
 		Signup(http.ResponseWriter, domain.SignupViewData)
+		SecondFactor(http.ResponseWriter, domain.SecondFactorViewData)
+		SetupTwoFactor(http.ResponseWriter, domain.SetupTwoFactorViewData)
+		ResetPassword(http.ResponseWriter, domain.ResetPasswordViewData)
 	} `checkinject:"required"`
 	SettingsModel interface {
 		Get() (domain.Settings, error)
 	} `checkinject:"required"`
 	UserModel interface {
 		CreateWithEmail(email, password string) (domain.User, error)
+		GetFromEmail(email string) (domain.User, error)
 		GetFromEmailPassword(email, password string) (domain.User, error)
+		GetFromID(userID domain.UserID) (domain.User, error)
+		UpdatePassword(userID domain.UserID, password string) error
 		MakeAdmin(userID domain.UserID) error
 	} `checkinject:"required"`
 	UserInvitationModel interface {
 		Get(email string) (domain.UserInvitation, error)
 	} `checkinject:"required"`
 	Authenticator interface {
-		SetForAccount(http.ResponseWriter, domain.UserID) error
+		SetForAccount(http.ResponseWriter, *http.Request, domain.UserID) error
+		SetPendingSecondFactor(http.ResponseWriter, *http.Request, domain.UserID) error
+		PendingSecondFactor(*http.Request) (domain.UserID, bool)
+		CompleteSecondFactor(http.ResponseWriter, *http.Request) error
 		Unset(http.ResponseWriter, *http.Request)
 	} `checkinject:"required"`
+	TwoFactor interface {
+		Required() (bool, error)
+		Status(domain.UserID) (domain.TwoFactorStatus, error)
+		Has(domain.UserID) (bool, error)
+		BeginTOTP(domain.UserID) (string, string, error)
+		ConfirmTOTP(domain.UserID, string) ([]string, error)
+		CheckTOTP(domain.UserID, string) (bool, error)
+		UseRecoveryCode(domain.UserID, string) (bool, error)
+		BeginPasskeyLogin(domain.UserID) (domain.WebAuthnRequestOptions, error)
+		FinishPasskeyLogin(domain.UserID, []byte) (domain.UserID, error)
+	} `checkinject:"required"`
+	PasswordResetModel interface {
+		Create(userID domain.UserID, tokenHash string, expires time.Time) error
+		Get(tokenHash string) (domain.PasswordReset, error)
+		DeleteForUser(userID domain.UserID) error
+		DeleteExpired() error
+	} `checkinject:"required"`
+	CookieModel interface {
+		DeleteForUser(userID domain.UserID, keepCookieID string) error
+	} `checkinject:"required"`
+	Mailer interface {
+		Enabled() bool
+		Send(to string, name string, data any) error
+	} `checkinject:"required"`
+	LoginLimiter interface {
+		Middleware(route string, account func(*http.Request) string, blocked func(http.ResponseWriter, *http.Request, time.Duration)) func(http.Handler) http.Handler
+		Clear(kind, key string)
+	} `checkinject:"required"`
 }
 
 func (a *AuthRoutes) routeGroup(r chi.Router) {
 	r.Group(func(r chi.Router) {
 		r.Use(mustNotBeAuthenticated)
-		r.Use(a.mustNotHaveSetupKey)    // disables the regular auth routes while setup key exists
-		r.Get("/signup", a.getSignup)   // include csrf
-		r.Post("/signup", a.postSignup) //check csrf
-		r.Get("/login", a.getLogin)     // include csrf
-		r.Post("/login", a.postLogin)   //check csrf
+		r.Use(a.mustNotHaveSetupKey) // disables the regular auth routes while setup key exists
+		r.Use(sameOriginOnly)
+		r.Get("/signup", a.getSignup)
+		r.With(a.LoginLimiter.Middleware("signup", nil, a.signupBlocked)).Post("/signup", a.postSignup)
+		r.Get("/login", a.getLogin)
+		r.With(a.LoginLimiter.Middleware("login", loginAccount, a.loginBlocked)).Post("/login", a.postLogin)
+		r.With(a.LoginLimiter.Middleware("passkey-options", nil, nil)).Post("/login/passkey-options", a.postPasskeyOptions)
+		r.With(a.LoginLimiter.Middleware("passkey", nil, nil)).Post("/login/passkey", a.postPasskey)
+		r.Group(a.secondFactorRoutes)
+		r.Group(a.passwordResetRoutes)
 	})
 	r.Get("/logout", a.handleLogout)
 
@@ -58,8 +104,9 @@ func (a *AuthRoutes) routeGroup(r chi.Router) {
 	r.Group(func(r chi.Router) {
 		r.Use(a.mustHaveSetupKey)
 		r.Use(mustNotBeAuthenticated)
-		r.Get("/"+key, a.getSignup)   // include csrf
-		r.Post("/"+key, a.postSignup) //check csrf
+		r.Use(sameOriginOnly)
+		r.Get("/"+key, a.getSignup)
+		r.With(a.LoginLimiter.Middleware("setup-key", nil, a.signupBlocked)).Post("/"+key, a.postSignup)
 	})
 }
 
@@ -94,22 +141,35 @@ func (a *AuthRoutes) mustNotHaveSetupKey(next http.Handler) http.Handler {
 }
 
 func (a *AuthRoutes) getLogin(w http.ResponseWriter, r *http.Request) {
-	a.Views.Login(w, domain.LoginViewData{})
+	a.loginView(w, domain.LoginViewData{
+		CSRFToken: getCSRFToken(w, r, a.Config)})
 }
 
-func (a *AuthRoutes) postLogin(w http.ResponseWriter, r *http.Request) {
-	// TODO: CSRF!!
+// loginView renders the login page, with a link to reset the password if mail is configured
+func (a *AuthRoutes) loginView(w http.ResponseWriter, viewData domain.LoginViewData) {
+	viewData.CanResetPassword = a.Mailer.Enabled()
+	a.Views.Login(w, viewData)
+}
 
+func (a *AuthRoutes) postLogin(w http.ResponseWriter, r *http.Request) {
 	r.ParseForm()
 
 	invalidLoginMessage := domain.LoginViewData{
-		Message: "Login incorrect"}
+		Message:   "Login incorrect",
+		CSRFToken: getCSRFToken(w, r, a.Config)}
+
+	if !validCSRFForm(r, a.Config) {
+		invalidLoginMessage.Message = csrfFailMessage
+		a.loginView(w, invalidLoginMessage)
+		return
+	}
 
 	email := strings.ToLower(r.Form.Get("email"))
 	dsErr := validator.Email(email)
 	if dsErr != nil {
 		// actually re-render page with generic error
-		a.Views.Login(w, invalidLoginMessage)
+		ratelimit.Fail(r)
+		a.loginView(w, invalidLoginMessage)
 		return
 	}
 
@@ -118,40 +178,95 @@ func (a *AuthRoutes) postLogin(w http.ResponseWriter, r *http.Request) {
 	password := r.Form.Get("password")
 	dsErr = validator.Password(password)
 	if dsErr != nil {
-		a.Views.Login(w, invalidLoginMessage)
+		ratelimit.Fail(r)
+		a.loginView(w, invalidLoginMessage)
 		return
 	}
 
 	user, err := a.UserModel.GetFromEmailPassword(email, password)
 	if err != nil {
 		if err == domain.ErrBadAuth || err == sql.ErrNoRows {
-			a.Views.Login(w, invalidLoginMessage)
+			ratelimit.Fail(r)
+			a.loginView(w, invalidLoginMessage)
 		} else {
 			returnError(w, err)
 		}
 	} else {
-		err := a.Authenticator.SetForAccount(w, user.UserID)
+		a.passwordLogin(w, r, user.UserID)
+	}
+}
+
+// passwordLogin logs in a user whose password checked out.
+// Users with a second factor, or who are required to have one,
+// get a pending session until they use or set up that factor.
+func (a *AuthRoutes) passwordLogin(w http.ResponseWriter, r *http.Request, userID domain.UserID) {
+	has, err := a.TwoFactor.Has(userID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	required := false
+	if !has {
+		required, err = a.TwoFactor.Required()
+		if err != nil {
+			returnError(w, err)
+			return
+		}
+	}
+	if !has && !required {
+		err = a.Authenticator.SetForAccount(w, r, userID)
 		if err != nil {
 			http.Error(w, "internal error", http.StatusInternalServerError)
 			return
 		}
 		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+
+	err = a.Authenticator.SetPendingSecondFactor(w, r, userID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if has {
+		http.Redirect(w, r, "/login/second-factor", http.StatusFound)
+	} else {
+		http.Redirect(w, r, "/login/setup-2fa", http.StatusFound)
 	}
 }
 
+// loginAccount returns the email a login attempt is for,
+// so that attempts can be throttled per account.
+func loginAccount(r *http.Request) string {
+	r.ParseForm()
+	email := strings.ToLower(r.PostForm.Get("email"))
+	if validator.Email(email) != nil {
+		return ""
+	}
+	return email
+}
+
+func (a *AuthRoutes) loginBlocked(w http.ResponseWriter, r *http.Request, retry time.Duration) {
+	viewData := domain.LoginViewData{
+		Message:   tooManyAttemptsMessage(retry),
+		Email:     loginAccount(r),
+		CSRFToken: getCSRFToken(w, r, a.Config)}
+	w.WriteHeader(http.StatusTooManyRequests)
+	a.loginView(w, viewData)
+}
+
 func (a *AuthRoutes) getSignup(w http.ResponseWriter, r *http.Request) {
 	viewData, err := a.getSignupViewData()
 	if err != nil {
 		returnError(w, err)
 		return
 	}
+	viewData.CSRFToken = getCSRFToken(w, r, a.Config)
 
 	a.Views.Signup(w, viewData)
 }
 
 func (a *AuthRoutes) postSignup(w http.ResponseWriter, r *http.Request) {
-	// TODO: CSRF!!
-
 	r.ParseForm()
 
 	viewData, err := a.getSignupViewData()
@@ -159,6 +274,13 @@ func (a *AuthRoutes) postSignup(w http.ResponseWriter, r *http.Request) {
 		returnError(w, err)
 		return
 	}
+	viewData.CSRFToken = getCSRFToken(w, r, a.Config)
+
+	if !validCSRFForm(r, a.Config) {
+		viewData.Message = csrfFailMessage
+		a.Views.Signup(w, viewData)
+		return
+	}
 
 	email := strings.ToLower(r.Form.Get("email"))
 	dsErr := validator.Email(email)
@@ -174,6 +296,7 @@ func (a *AuthRoutes) postSignup(w http.ResponseWriter, r *http.Request) {
 		_, err := a.UserInvitationModel.Get(email)
 		if err != nil {
 			if err == sql.ErrNoRows {
+				ratelimit.Fail(r) // probing the invitation list
 				viewData.Message = "Sorry, this email is not on the invitation list"
 				a.Views.Signup(w, viewData)
 				return
@@ -201,6 +324,7 @@ func (a *AuthRoutes) postSignup(w http.ResponseWriter, r *http.Request) {
 	user, err := a.UserModel.CreateWithEmail(email, password)
 	if err != nil {
 		if err == domain.ErrIdentifierExists {
+			ratelimit.Fail(r) // probing for accounts
 			viewData.Message = "Account already exists with that email"
 			a.Views.Signup(w, viewData)
 		} else {
@@ -209,7 +333,17 @@ func (a *AuthRoutes) postSignup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = a.Authenticator.SetForAccount(w, user.UserID)
+	require2FA, err := a.TwoFactor.Required()
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+
+	if require2FA {
+		err = a.Authenticator.SetPendingSecondFactor(w, r, user.UserID)
+	} else {
+		err = a.Authenticator.SetForAccount(w, r, user.UserID)
+	}
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
@@ -228,9 +362,33 @@ func (a *AuthRoutes) postSignup(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if require2FA {
+		http.Redirect(w, r, "/login/setup-2fa", http.StatusFound)
+		return
+	}
 	http.Redirect(w, r, "/", http.StatusMovedPermanently)
 }
 
+func (a *AuthRoutes) signupBlocked(w http.ResponseWriter, r *http.Request, retry time.Duration) {
+	viewData, err := a.getSignupViewData()
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	viewData.Message = tooManyAttemptsMessage(retry)
+	viewData.CSRFToken = getCSRFToken(w, r, a.Config)
+	w.WriteHeader(http.StatusTooManyRequests)
+	a.Views.Signup(w, viewData)
+}
+
+func tooManyAttemptsMessage(retry time.Duration) string {
+	secs := int((retry + time.Second - 1) / time.Second)
+	if secs <= 60 {
+		return fmt.Sprintf("Too many failed attempts. Please try again in %d seconds.", secs)
+	}
+	return fmt.Sprintf("Too many failed attempts. Please try again in %d minutes.", (secs+59)/60)
+}
+
 func (a *AuthRoutes) getSignupViewData() (domain.SignupViewData, error) {
 	d := domain.SignupViewData{FormAction: "/signup"}
 
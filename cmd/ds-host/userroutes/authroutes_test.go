@@ -14,9 +14,118 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/golang/mock/gomock"
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/ratelimit"
 	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
 )
 
+const testCSRFToken = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// csrfFormRequest returns a form POST request with a valid CSRF token
+func csrfFormRequest(form url.Values) *http.Request {
+	form.Set(csrfFormField, testCSRFToken)
+	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "csrf_token", Value: testCSRFToken})
+	return req
+}
+
+func getLoginLimiter(t *testing.T) *ratelimit.Limiter {
+	l := &ratelimit.Limiter{Config: &domain.RuntimeConfig{}}
+	l.Init()
+	t.Cleanup(l.Stop)
+	return l
+}
+
+// noMailer is a mailer for tests where mail is not configured
+func noMailer(mockCtrl *gomock.Controller) *testmocks.MockMailer {
+	m := testmocks.NewMockMailer(mockCtrl)
+	m.EXPECT().Enabled().AnyTimes().Return(false)
+	return m
+}
+
+func TestGetLoginCSRF(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	views := testmocks.NewMockViews(mockCtrl)
+	views.EXPECT().Login(gomock.Any(), gomock.Any()).Do(func(w http.ResponseWriter, d domain.LoginViewData) {
+		if len(d.CSRFToken) < 32 {
+			t.Error("expected a csrf token")
+		}
+	})
+
+	a := &AuthRoutes{
+		Mailer: noMailer(mockCtrl),
+		Config: &domain.RuntimeConfig{},
+		Views:  views}
+
+	rr := httptest.NewRecorder()
+	a.getLogin(rr, httptest.NewRequest(http.MethodGet, "/login", nil))
+
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "csrf_token" || !cookies[0].HttpOnly {
+		t.Errorf("expected csrf cookie, got %v", cookies)
+	}
+}
+
+func TestLoginPostBadCSRF(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	views := testmocks.NewMockViews(mockCtrl)
+	views.EXPECT().Login(gomock.Any(), gomock.Any()).Do(func(w http.ResponseWriter, d domain.LoginViewData) {
+		if d.Message != csrfFailMessage {
+			t.Error("expected csrf message, got " + d.Message)
+		}
+	})
+	userModel := testmocks.NewMockUserModel(mockCtrl)
+
+	a := &AuthRoutes{
+		Mailer:    noMailer(mockCtrl),
+		Config:    &domain.RuntimeConfig{},
+		Views:     views,
+		UserModel: userModel}
+
+	form := url.Values{}
+	form.Add("email", "oy@foo.bar")
+	form.Add("password", "password123")
+	form.Set(csrfFormField, "nope")
+	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "csrf_token", Value: testCSRFToken})
+
+	a.postLogin(httptest.NewRecorder(), req)
+}
+
+func TestSignupPostBadCSRF(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	views := testmocks.NewMockViews(mockCtrl)
+	views.EXPECT().Signup(gomock.Any(), gomock.Any()).Do(func(w http.ResponseWriter, d domain.SignupViewData) {
+		if d.Message != csrfFailMessage {
+			t.Error("expected csrf message, got " + d.Message)
+		}
+	})
+	sk := testmocks.NewMockSetupKey(mockCtrl)
+	sk.EXPECT().Has().Return(false, nil)
+	sm := testmocks.NewMockSettingsModel(mockCtrl)
+	sm.EXPECT().Get().Return(domain.Settings{RegistrationOpen: true}, nil)
+
+	a := &AuthRoutes{
+		Config:        &domain.RuntimeConfig{},
+		Views:         views,
+		SetupKey:      sk,
+		SettingsModel: sm}
+
+	form := url.Values{}
+	form.Add("email", "oy@foo.bar")
+	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	a.postSignup(httptest.NewRecorder(), req)
+}
+
 // login POST handling
 
 func TestLoginPostBadEmail(t *testing.T) {
@@ -29,14 +138,15 @@ func TestLoginPostBadEmail(t *testing.T) {
 	views.EXPECT().Login(gomock.Any(), gomock.Any())
 
 	a := &AuthRoutes{
-		Views: views}
+		Mailer: noMailer(mockCtrl),
+		Config: &domain.RuntimeConfig{},
+		Views:  views}
 
 	rr := httptest.NewRecorder()
 
 	form := url.Values{}
 	form.Add("email", email)
-	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req := csrfFormRequest(form)
 
 	a.postLogin(rr, req)
 }
@@ -52,15 +162,16 @@ func TestLoginPostBadPassword(t *testing.T) {
 	views.EXPECT().Login(gomock.Any(), gomock.Any())
 
 	a := &AuthRoutes{
-		Views: views}
+		Mailer: noMailer(mockCtrl),
+		Config: &domain.RuntimeConfig{},
+		Views:  views}
 
 	rr := httptest.NewRecorder()
 
 	form := url.Values{}
 	form.Add("email", email)
 	form.Add("password", password)
-	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req := csrfFormRequest(form)
 
 	a.postLogin(rr, req)
 }
@@ -79,6 +190,8 @@ func TestLoginPostNoRows(t *testing.T) {
 	userModel.EXPECT().GetFromEmailPassword(gomock.Any(), gomock.Any()).Return(domain.User{}, sql.ErrNoRows)
 
 	a := &AuthRoutes{
+		Mailer:    noMailer(mockCtrl),
+		Config:    &domain.RuntimeConfig{},
 		Views:     views,
 		UserModel: userModel}
 
@@ -87,8 +200,7 @@ func TestLoginPostNoRows(t *testing.T) {
 	form := url.Values{}
 	form.Add("email", email)
 	form.Add("password", password)
-	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req := csrfFormRequest(form)
 
 	a.postLogin(rr, req)
 }
@@ -107,10 +219,16 @@ func TestLoginPost(t *testing.T) {
 		Email:  email}, nil)
 
 	authenticator := testmocks.NewMockAuthenticator(mockCtrl)
-	authenticator.EXPECT().SetForAccount(gomock.Any(), userID)
+	authenticator.EXPECT().SetForAccount(gomock.Any(), gomock.Any(), userID)
+
+	twoFactor := testmocks.NewMockTwoFactor(mockCtrl)
+	twoFactor.EXPECT().Has(userID).Return(false, nil)
+	twoFactor.EXPECT().Required().Return(false, nil)
 
 	a := &AuthRoutes{
+		Config:        &domain.RuntimeConfig{},
 		Authenticator: authenticator,
+		TwoFactor:     twoFactor,
 		UserModel:     userModel}
 
 	rr := httptest.NewRecorder()
@@ -118,8 +236,7 @@ func TestLoginPost(t *testing.T) {
 	form := url.Values{}
 	form.Add("email", email)
 	form.Add("password", password)
-	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req := csrfFormRequest(form)
 
 	a.postLogin(rr, req)
 
@@ -128,6 +245,48 @@ func TestLoginPost(t *testing.T) {
 	}
 }
 
+func TestLoginPostSecondFactor(t *testing.T) {
+	cases := []struct {
+		has      bool
+		required bool
+		location string
+	}{
+		{true, false, "/login/second-factor"},
+		{true, true, "/login/second-factor"},
+		{false, true, "/login/setup-2fa"},
+	}
+	for _, c := range cases {
+		mockCtrl := gomock.NewController(t)
+
+		userID := domain.UserID(1)
+		userModel := testmocks.NewMockUserModel(mockCtrl)
+		userModel.EXPECT().GetFromEmailPassword(gomock.Any(), gomock.Any()).Return(domain.User{
+			UserID: userID}, nil)
+
+		authenticator := testmocks.NewMockAuthenticator(mockCtrl)
+		authenticator.EXPECT().SetPendingSecondFactor(gomock.Any(), gomock.Any(), userID)
+
+		twoFactor := testmocks.NewMockTwoFactor(mockCtrl)
+		twoFactor.EXPECT().Has(userID).Return(c.has, nil)
+		twoFactor.EXPECT().Required().AnyTimes().Return(c.required, nil)
+
+		a := &AuthRoutes{
+			Config:        &domain.RuntimeConfig{},
+			Authenticator: authenticator,
+			TwoFactor:     twoFactor,
+			UserModel:     userModel}
+
+		rr := httptest.NewRecorder()
+		req := csrfFormRequest(url.Values{"email": {"oy@foo.bar"}, "password": {"password123"}})
+		a.postLogin(rr, req)
+
+		if rr.Code != http.StatusFound || rr.Header().Get("Location") != c.location {
+			t.Errorf("%v %v: expected redirect to %v, got %v %v", c.has, c.required, c.location, rr.Code, rr.Header().Get("Location"))
+		}
+		mockCtrl.Finish()
+	}
+}
+
 // Signup post handling
 
 func TestSignupPostBadEmail(t *testing.T) {
@@ -146,6 +305,7 @@ func TestSignupPostBadEmail(t *testing.T) {
 	sm.EXPECT().Get().Return(domain.Settings{RegistrationOpen: true}, nil)
 
 	a := &AuthRoutes{
+		Config:        &domain.RuntimeConfig{},
 		SetupKey:      sk,
 		SettingsModel: sm,
 		Views:         views}
@@ -154,8 +314,7 @@ func TestSignupPostBadEmail(t *testing.T) {
 
 	form := url.Values{}
 	form.Add("email", email)
-	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req := csrfFormRequest(form)
 
 	a.postSignup(rr, req)
 }
@@ -179,6 +338,7 @@ func TestSignupPostNotInvited(t *testing.T) {
 	im.EXPECT().Get(email).Return(domain.UserInvitation{}, sql.ErrNoRows)
 
 	a := &AuthRoutes{
+		Config:              &domain.RuntimeConfig{},
 		SetupKey:            sk,
 		SettingsModel:       sm,
 		UserInvitationModel: im,
@@ -188,8 +348,7 @@ func TestSignupPostNotInvited(t *testing.T) {
 
 	form := url.Values{}
 	form.Add("email", email)
-	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req := csrfFormRequest(form)
 
 	a.postSignup(rr, req)
 }
@@ -211,6 +370,7 @@ func TestSignupPostBadPassword(t *testing.T) {
 	sm.EXPECT().Get().Return(domain.Settings{RegistrationOpen: true}, nil)
 
 	a := &AuthRoutes{
+		Config:        &domain.RuntimeConfig{},
 		SetupKey:      sk,
 		SettingsModel: sm,
 		Views:         views}
@@ -220,8 +380,7 @@ func TestSignupPostBadPassword(t *testing.T) {
 	form := url.Values{}
 	form.Add("email", email)
 	form.Add("password", password)
-	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req := csrfFormRequest(form)
 
 	a.postSignup(rr, req)
 }
@@ -243,6 +402,7 @@ func TestSignupPostPasswordMismatch(t *testing.T) {
 	sm.EXPECT().Get().Return(domain.Settings{RegistrationOpen: true}, nil)
 
 	a := &AuthRoutes{
+		Config:        &domain.RuntimeConfig{},
 		SetupKey:      sk,
 		SettingsModel: sm,
 		Views:         views}
@@ -253,8 +413,7 @@ func TestSignupPostPasswordMismatch(t *testing.T) {
 	form.Add("email", email)
 	form.Add("password", password)
 	form.Add("password2", password+"zzz")
-	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req := csrfFormRequest(form)
 
 	a.postSignup(rr, req)
 }
@@ -279,6 +438,7 @@ func TestSignupPostEmailExists(t *testing.T) {
 	userModel.EXPECT().CreateWithEmail(email, password).Return(domain.User{}, domain.ErrIdentifierExists)
 
 	a := &AuthRoutes{
+		Config:        &domain.RuntimeConfig{},
 		SetupKey:      sk,
 		Views:         views,
 		SettingsModel: sm,
@@ -290,8 +450,7 @@ func TestSignupPostEmailExists(t *testing.T) {
 	form.Add("email", email)
 	form.Add("password", password)
 	form.Add("password2", password)
-	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req := csrfFormRequest(form)
 
 	a.postSignup(rr, req)
 }
@@ -316,13 +475,18 @@ func TestSignupPost(t *testing.T) {
 		Email:  email}, nil)
 
 	authenticator := testmocks.NewMockAuthenticator(mockCtrl)
-	authenticator.EXPECT().SetForAccount(gomock.Any(), userID)
+	authenticator.EXPECT().SetForAccount(gomock.Any(), gomock.Any(), userID)
+
+	twoFactor := testmocks.NewMockTwoFactor(mockCtrl)
+	twoFactor.EXPECT().Required().Return(false, nil)
 
 	a := &AuthRoutes{
+		Config:        &domain.RuntimeConfig{},
 		SetupKey:      sk,
 		SettingsModel: sm,
 		UserModel:     userModel,
-		Authenticator: authenticator}
+		Authenticator: authenticator,
+		TwoFactor:     twoFactor}
 
 	rr := httptest.NewRecorder()
 
@@ -330,8 +494,7 @@ func TestSignupPost(t *testing.T) {
 	form.Add("email", email)
 	form.Add("password", password)
 	form.Add("password2", password)
-	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req := csrfFormRequest(form)
 
 	a.postSignup(rr, req)
 }
@@ -359,13 +522,18 @@ func TestSignupPostSetupKey(t *testing.T) {
 	userModel.EXPECT().MakeAdmin(userID).Return(nil)
 
 	authenticator := testmocks.NewMockAuthenticator(mockCtrl)
-	authenticator.EXPECT().SetForAccount(gomock.Any(), userID)
+	authenticator.EXPECT().SetForAccount(gomock.Any(), gomock.Any(), userID)
+
+	twoFactor := testmocks.NewMockTwoFactor(mockCtrl)
+	twoFactor.EXPECT().Required().Return(false, nil)
 
 	a := &AuthRoutes{
+		Config:        &domain.RuntimeConfig{},
 		SetupKey:      sk,
 		SettingsModel: sm,
 		UserModel:     userModel,
-		Authenticator: authenticator}
+		Authenticator: authenticator,
+		TwoFactor:     twoFactor}
 
 	rr := httptest.NewRecorder()
 
@@ -373,8 +541,7 @@ func TestSignupPostSetupKey(t *testing.T) {
 	form.Add("email", email)
 	form.Add("password", password)
 	form.Add("password2", password)
-	req := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req := csrfFormRequest(form)
 
 	a.postSignup(rr, req)
 }
@@ -399,9 +566,11 @@ func TestSignupRoutesWithSetupKey(t *testing.T) {
 	sm.EXPECT().Get().AnyTimes().Return(domain.Settings{RegistrationOpen: false}, nil)
 
 	a := &AuthRoutes{
+		Config:        &domain.RuntimeConfig{},
 		SetupKey:      sk,
 		SettingsModel: sm,
 		Views:         views,
+		LoginLimiter:  getLoginLimiter(t),
 	}
 
 	r := chi.NewRouter()
@@ -446,9 +615,11 @@ func TestSignupRoutesWithoutSetupKey(t *testing.T) {
 	sm.EXPECT().Get().Return(domain.Settings{RegistrationOpen: false}, nil)
 
 	a := &AuthRoutes{
+		Config:        &domain.RuntimeConfig{},
 		SetupKey:      sk,
 		SettingsModel: sm,
 		Views:         views,
+		LoginLimiter:  getLoginLimiter(t),
 	}
 
 	r := chi.NewRouter()
@@ -463,6 +634,50 @@ func TestSignupRoutesWithoutSetupKey(t *testing.T) {
 	}
 }
 
+func TestLoginThrottled(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	sk := testmocks.NewMockSetupKey(mockCtrl)
+	sk.EXPECT().Has().AnyTimes().Return(false, nil)
+	sk.EXPECT().Get().AnyTimes().Return("", nil)
+
+	messages := []string{}
+	views := testmocks.NewMockViews(mockCtrl)
+	views.EXPECT().Login(gomock.Any(), gomock.Any()).AnyTimes().Do(func(w http.ResponseWriter, d domain.LoginViewData) {
+		messages = append(messages, d.Message)
+	})
+
+	userModel := testmocks.NewMockUserModel(mockCtrl)
+	userModel.EXPECT().GetFromEmailPassword("a@b.c", "wrongpassword").AnyTimes().Return(domain.User{}, domain.ErrBadAuth)
+
+	a := &AuthRoutes{
+		Mailer:       noMailer(mockCtrl),
+		Config:       &domain.RuntimeConfig{},
+		SetupKey:     sk,
+		Views:        views,
+		UserModel:    userModel,
+		LoginLimiter: getLoginLimiter(t),
+	}
+
+	r := chi.NewRouter()
+	r.Group(a.routeGroup)
+
+	var rr *httptest.ResponseRecorder
+	for i := 0; i < 7; i++ {
+		req := csrfFormRequest(url.Values{"email": {"a@b.c"}, "password": {"wrongpassword"}})
+		req.URL.Path = "/login"
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+	}
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429, got %v", rr.Code)
+	}
+	if len(messages) != 7 || !strings.HasPrefix(messages[6], "Too many failed attempts") {
+		t.Errorf("expected too many attempts message, got %v", messages)
+	}
+}
+
 // borrowed from chi project: chi/middleware/middleware_test.go
 func testRequest(t *testing.T, ts *httptest.Server, method, path string, body io.Reader) (*http.Response, string) {
 	req, err := http.NewRequest(method, ts.URL+path, body)
@@ -0,0 +1,108 @@
+package userroutes
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+// CSRF defenses:
+// - forms rendered by the server (login, signup) carry a token
+//   that must match a cookie (double-submit)
+// - state-changing requests must come from the same origin,
+//   per Sec-Fetch-Site or Origin headers.
+// Appspaces are served from subdomains and can set cookies for the parent domain,
+// so the origin check is what stops a same-site appspace from submitting forms.
+
+const csrfFormField = "csrf_token"
+
+const csrfFailMessage = "This form has expired. Please try again."
+
+func csrfCookieName(config *domain.RuntimeConfig) string {
+	if config.ExternalAccess.Scheme == "https" {
+		// __Host- cookies can not be set by subdomains
+		return "__Host-csrf_token"
+	}
+	return "csrf_token"
+}
+
+// getCSRFToken returns the request's CSRF token,
+// or creates one and sets the cookie if there isn't one.
+func getCSRFToken(w http.ResponseWriter, r *http.Request, config *domain.RuntimeConfig) string {
+	c, err := r.Cookie(csrfCookieName(config))
+	if err == nil && len(c.Value) >= 32 {
+		return c.Value
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName(config),
+		Value:    token,
+		Path:     "/",
+		SameSite: http.SameSiteStrictMode,
+		Secure:   config.ExternalAccess.Scheme == "https",
+		HttpOnly: true,
+	})
+	return token
+}
+
+// validCSRFForm returns true if the form token matches the cookie token.
+// r.ParseForm must be called first.
+func validCSRFForm(r *http.Request, config *domain.RuntimeConfig) bool {
+	c, err := r.Cookie(csrfCookieName(config))
+	if err != nil || c.Value == "" {
+		return false
+	}
+	formToken := r.PostForm.Get(csrfFormField)
+	return subtle.ConstantTimeCompare([]byte(c.Value), []byte(formToken)) == 1
+}
+
+// sameOriginOnly rejects state-changing requests made from other origins
+func sameOriginOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !isSameOrigin(r) {
+			http.Error(w, "cross-origin request rejected", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isSameOrigin uses Sec-Fetch-Site if the browser sent it,
+// otherwise Origin, then Referer.
+// Requests with none of these are not from a browser, or from a very old one,
+// and are allowed.
+func isSameOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return true
+	case "":
+	default:
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+		if origin == "" {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
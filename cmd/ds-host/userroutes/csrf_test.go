@@ -0,0 +1,133 @@
+package userroutes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+)
+
+func TestIsSameOrigin(t *testing.T) {
+	cases := []struct {
+		header map[string]string
+		same   bool
+	}{
+		{map[string]string{}, true},
+		{map[string]string{"Sec-Fetch-Site": "same-origin"}, true},
+		{map[string]string{"Sec-Fetch-Site": "none"}, true},
+		{map[string]string{"Sec-Fetch-Site": "same-site"}, false},
+		{map[string]string{"Sec-Fetch-Site": "cross-site"}, false},
+		{map[string]string{"Sec-Fetch-Site": "same-site", "Origin": "https://dropserver.develop"}, false},
+		{map[string]string{"Origin": "https://dropserver.develop"}, true},
+		{map[string]string{"Origin": "https://as1.dropserver.develop"}, false},
+		{map[string]string{"Origin": "null"}, false},
+		{map[string]string{"Referer": "https://dropserver.develop/login"}, true},
+		{map[string]string{"Referer": "https://evil.example.com/"}, false},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "https://dropserver.develop/login", nil)
+		for k, v := range c.header {
+			req.Header.Set(k, v)
+		}
+		if isSameOrigin(req) != c.same {
+			t.Errorf("%v: expected %v", c.header, c.same)
+		}
+	}
+}
+
+// TestMutatingRoutesSameOrigin checks that every state-changing route
+// registered for authenticated users rejects cross-origin requests.
+func TestMutatingRoutesSameOrigin(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	views := testmocks.NewMockViews(mockCtrl)
+	views.EXPECT().GetStaticFS().Return(fstest.MapFS{})
+
+	u := &UserRoutes{
+		Config:              &domain.RuntimeConfig{},
+		Views:               views,
+		AppspaceLoginRoutes: &AppspaceLoginRoutes{},
+		ApplicationRoutes:   &ApplicationRoutes{},
+		AppspaceRoutes: &AppspaceRoutes{
+			AppspaceUserRoutes:     &AppspaceUserRoutes{},
+			AppspaceExportRoutes:   &AppspaceBackupRoutes{},
+			AppspaceRestoreRoutes:  &AppspaceRestoreRoutes{LoginLimiter: getLoginLimiter(t)},
+			AppspaceImportRoutes:   &AppspaceImportRoutes{LoginLimiter: getLoginLimiter(t)},
+			AppspaceTransferRoutes: &AppspaceTransferRoutes{},
+			AppspaceQuotaRoutes:    &AppspaceQuotaRoutes{},
+			AppspaceLogRoutes:      &AppspaceLogRoutes{},
+			AccessLogRoutes:        &AppspaceAccessLogRoutes{},
+			AppspaceOutboundRoutes: &AppspaceOutboundRoutes{},
+			AppspaceSessionRoutes:  &AppspaceSessionRoutes{},
+			TransferTicketRoutes:   &TransferTicketRoutes{}},
+		RemoteAppspaceRoutes: &RemoteAppspaceRoutes{},
+		ContactRoutes:        &ContactRoutes{},
+		DomainRoutes:         &DomainNameRoutes{},
+		DropIDRoutes:         &DropIDRoutes{},
+		MigrationJobRoutes:   &MigrationJobRoutes{},
+		AdminRoutes:          &AdminRoutes{},
+		V0TransferRoutes:     &V0TransferRoutes{},
+		UsageRoutes:          &UsageRoutes{},
+		TwoFactorRoutes:      &TwoFactorRoutes{},
+		SessionRoutes:        &SessionRoutes{},
+	}
+
+	mux := chi.NewRouter()
+	mux.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(domain.CtxWithAuthUserID(r.Context(), domain.UserID(1))))
+		})
+	})
+	u.BuildRoutes(mux)
+
+	params := regexp.MustCompile(`\{[^}]*\}`)
+	count := 0
+	err := chi.Walk(mux, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		switch method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			return nil
+		}
+		// ds2ds routes are authenticated by token, not cookie,
+		// and asset file servers are registered for all methods but are read-only.
+		for _, prefix := range []string{"/.dropserver/v0/transfer", "/static/", "/frontend-assets/"} {
+			if strings.HasPrefix(route, prefix) {
+				return nil
+			}
+		}
+		count++
+		path := params.ReplaceAllString(route, "1")
+		path = strings.ReplaceAll(path, "/*", "/")
+		for _, header := range []map[string]string{
+			{"Sec-Fetch-Site": "cross-site"},
+			{"Sec-Fetch-Site": "same-site"},
+			{"Origin": "https://evil.example.com"},
+		} {
+			req := httptest.NewRequest(method, "https://dropserver.develop"+path, nil)
+			for k, v := range header {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+			if rr.Code != http.StatusForbidden {
+				t.Errorf("%v %v with %v: expected 403, got %v", method, route, header, rr.Code)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count < 20 {
+		t.Errorf("expected to find mutating routes, found %v", count)
+	}
+}
@@ -2,10 +2,12 @@ package userroutes
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 func readSingleQueryParam(r *http.Request, key string) (string, bool) {
@@ -68,3 +70,17 @@ func writeBadRequest(w http.ResponseWriter, valName string, errStr string) {
 func writeNotFound(w http.ResponseWriter) {
 	http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 }
+
+// queryTime reads an RFC3339 time from the query.
+// It returns zero time if the key is absent.
+func queryTime(r *http.Request, key string) (time.Time, error) {
+	s := r.URL.Query().Get(key)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return t, errors.New("invalid " + key + ": " + err.Error())
+	}
+	return t, nil
+}
@@ -24,6 +24,10 @@ type MigrationJobRoutes struct {
 	MigrationJobController interface {
 		WakeUp()
 	} `checkinject:"required"`
+	OutboundPolicy interface {
+		Validate(appID domain.AppID, version domain.Version, hosts []string) ([]string, error)
+		Approve(appspace domain.Appspace, version domain.Version, hosts []string) error
+	} `checkinject:"required"`
 }
 
 func (j *MigrationJobRoutes) subRouter() http.Handler {
@@ -45,6 +49,9 @@ func (j *MigrationJobRoutes) subRouter() http.Handler {
 type PostAppspaceVersionReq struct {
 	AppspaceID domain.AppspaceID `json:"appspace_id"`
 	Version    domain.Version    `json:"to_version"` // could include app_id to future proof and to verify apples-apples
+	// Outbound replaces the approved outbound hosts if set.
+	// Hosts must be declared by the version migrated to.
+	Outbound *[]string `json:"outbound"`
 }
 
 func (j *MigrationJobRoutes) postNewJob(w http.ResponseWriter, r *http.Request) {
@@ -75,12 +82,32 @@ func (j *MigrationJobRoutes) postNewJob(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if reqData.Outbound != nil {
+		_, err = j.OutboundPolicy.Validate(appspace.AppID, reqData.Version, *reqData.Outbound)
+		if err == domain.ErrOutboundNotDeclared {
+			writeBadRequest(w, "outbound", err.Error())
+			return
+		}
+		if err != nil {
+			returnError(w, err)
+			return
+		}
+	}
+
 	job, err := j.MigrationJobModel.Create(userID, appspace.AppspaceID, reqData.Version, true)
 	if err != nil {
 		http.Error(w, "error creating job: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if reqData.Outbound != nil {
+		err = j.OutboundPolicy.Approve(*appspace, reqData.Version, *reqData.Outbound)
+		if err != nil {
+			returnError(w, err)
+			return
+		}
+	}
+
 	j.MigrationJobController.WakeUp()
 
 	writeJSON(w, *job)
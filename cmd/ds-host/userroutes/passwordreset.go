@@ -0,0 +1,232 @@
+package userroutes
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/ratelimit"
+	"github.com/teleclimber/DropServer/internal/validator"
+)
+
+const passwordResetTTL = time.Hour
+
+// passwordResetRoutes let users who forgot their password
+// get a link by email to choose a new one.
+// They are only available if mail is configured.
+func (a *AuthRoutes) passwordResetRoutes(r chi.Router) {
+	r.Use(a.mustHaveMail)
+	r.Get("/login/reset-password", a.getResetPassword)
+	r.With(a.LoginLimiter.Middleware("reset-password", resetAccount, a.resetPasswordBlocked)).Post("/login/reset-password", a.postResetPassword)
+	r.Get("/login/new-password", a.getNewPassword)
+	r.With(a.LoginLimiter.Middleware("new-password", nil, nil)).Post("/login/new-password", a.postNewPassword)
+}
+
+func (a *AuthRoutes) mustHaveMail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Mailer.Enabled() {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// resetAccount is the key used to throttle reset requests per account,
+// so that an account can not be flooded with emails.
+// It differs from the login key so that requests do not block logins.
+func resetAccount(r *http.Request) string {
+	email := loginAccount(r)
+	if email == "" {
+		return ""
+	}
+	return email + " (password reset)"
+}
+
+func (a *AuthRoutes) getResetPassword(w http.ResponseWriter, r *http.Request) {
+	a.Views.ResetPassword(w, domain.ResetPasswordViewData{
+		CSRFToken: getCSRFToken(w, r, a.Config)})
+}
+
+func (a *AuthRoutes) postResetPassword(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	viewData := domain.ResetPasswordViewData{
+		CSRFToken: getCSRFToken(w, r, a.Config)}
+
+	if !validCSRFForm(r, a.Config) {
+		viewData.Message = csrfFailMessage
+		a.Views.ResetPassword(w, viewData)
+		return
+	}
+
+	email := strings.ToLower(r.Form.Get("email"))
+	if validator.Email(email) != nil {
+		viewData.Message = "Please use a valid email"
+		a.Views.ResetPassword(w, viewData)
+		return
+	}
+	viewData.Email = email
+
+	// every request counts against the limit, whether or not the account exists
+	ratelimit.Fail(r)
+
+	// The response is the same whether or not the account exists,
+	// and the email is sent in the background so the timing does not tell either.
+	viewData.Sent = true
+
+	user, err := a.UserModel.GetFromEmail(email)
+	if err == sql.ErrNoRows {
+		a.Views.ResetPassword(w, viewData)
+		return
+	}
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+
+	token, err := newResetToken()
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	err = a.PasswordResetModel.Create(user.UserID, hashResetToken(token), time.Now().Add(passwordResetTTL))
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	a.PasswordResetModel.DeleteExpired()
+
+	data := struct {
+		Link           string
+		ExpiresMinutes int
+	}{
+		Link: fmt.Sprintf("%s://%s%s/login/new-password?token=%s",
+			a.Config.ExternalAccess.Scheme, a.Config.Exec.UserRoutesDomain, a.Config.Exec.PortString, url.QueryEscape(token)),
+		ExpiresMinutes: int(passwordResetTTL / time.Minute)}
+	go a.Mailer.Send(user.Email, "password-reset", data) // errors are logged by the mailer
+
+	a.Views.ResetPassword(w, viewData)
+}
+
+func (a *AuthRoutes) resetPasswordBlocked(w http.ResponseWriter, r *http.Request, retry time.Duration) {
+	viewData := domain.ResetPasswordViewData{
+		Message:   tooManyAttemptsMessage(retry),
+		Email:     loginAccount(r),
+		CSRFToken: getCSRFToken(w, r, a.Config)}
+	w.WriteHeader(http.StatusTooManyRequests)
+	a.Views.ResetPassword(w, viewData)
+}
+
+// invalidResetLink sends the user back to ask for a new link
+func (a *AuthRoutes) invalidResetLink(w http.ResponseWriter, r *http.Request) {
+	a.Views.ResetPassword(w, domain.ResetPasswordViewData{
+		Message:   "This link is invalid or has expired. You can ask for a new one.",
+		CSRFToken: getCSRFToken(w, r, a.Config)})
+}
+
+func (a *AuthRoutes) getNewPassword(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	_, err := a.PasswordResetModel.Get(hashResetToken(token))
+	if err == sql.ErrNoRows {
+		a.invalidResetLink(w, r)
+		return
+	}
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	a.Views.ResetPassword(w, domain.ResetPasswordViewData{
+		Token:     token,
+		CSRFToken: getCSRFToken(w, r, a.Config)})
+}
+
+func (a *AuthRoutes) postNewPassword(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	token := r.Form.Get("token")
+	viewData := domain.ResetPasswordViewData{
+		Token:     token,
+		CSRFToken: getCSRFToken(w, r, a.Config)}
+
+	if !validCSRFForm(r, a.Config) {
+		viewData.Message = csrfFailMessage
+		a.Views.ResetPassword(w, viewData)
+		return
+	}
+
+	reset, err := a.PasswordResetModel.Get(hashResetToken(token))
+	if err == sql.ErrNoRows {
+		ratelimit.Fail(r)
+		a.invalidResetLink(w, r)
+		return
+	}
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+
+	password := r.Form.Get("password")
+	if validator.Password(password) != nil {
+		viewData.Message = "Please use a valid password"
+		a.Views.ResetPassword(w, viewData)
+		return
+	}
+	if password != r.Form.Get("password2") {
+		viewData.Message = "Passwords did not match"
+		a.Views.ResetPassword(w, viewData)
+		return
+	}
+
+	user, err := a.UserModel.GetFromID(reset.UserID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	err = a.UserModel.UpdatePassword(user.UserID, password)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	err = a.PasswordResetModel.DeleteForUser(user.UserID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	// whoever had the old password is logged out
+	err = a.CookieModel.DeleteForUser(user.UserID, "")
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	// the user proved they own the account's email, so lift any lockout from bad passwords
+	a.LoginLimiter.Clear(domain.LoginLimitAccount, user.Email)
+
+	a.loginView(w, domain.LoginViewData{
+		Message:   "Your password was changed. Please log in.",
+		Email:     user.Email,
+		CSRFToken: getCSRFToken(w, r, a.Config)})
+}
+
+func newResetToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashResetToken returns what is stored in place of the token,
+// so that a leaked database does not give out working reset links.
+func hashResetToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
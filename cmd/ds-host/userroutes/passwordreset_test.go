@@ -0,0 +1,179 @@
+package userroutes
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+)
+
+func TestResetPasswordRoutesNeedMail(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	a := &AuthRoutes{
+		Config:       &domain.RuntimeConfig{},
+		Mailer:       noMailer(mockCtrl),
+		LoginLimiter: getLoginLimiter(t)}
+
+	r := chi.NewRouter()
+	r.Group(a.passwordResetRoutes)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/login/reset-password", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %v", rr.Code)
+	}
+}
+
+func TestPostResetPassword(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	userID := domain.UserID(7)
+
+	userModel := testmocks.NewMockUserModel(mockCtrl)
+	userModel.EXPECT().GetFromEmail("me@example.com").Return(domain.User{UserID: userID, Email: "me@example.com"}, nil)
+
+	var tokenHash string
+	resetModel := testmocks.NewMockPasswordResetModel(mockCtrl)
+	resetModel.EXPECT().Create(userID, gomock.Any(), gomock.Any()).DoAndReturn(func(u domain.UserID, h string, exp time.Time) error {
+		tokenHash = h
+		return nil
+	})
+	resetModel.EXPECT().DeleteExpired().Return(nil)
+
+	links := make(chan string)
+	mailer := testmocks.NewMockMailer(mockCtrl)
+	mailer.EXPECT().Send("me@example.com", "password-reset", gomock.Any()).DoAndReturn(func(to, name string, data any) error {
+		links <- data.(struct {
+			Link           string
+			ExpiresMinutes int
+		}).Link
+		return nil
+	})
+
+	views := testmocks.NewMockViews(mockCtrl)
+	views.EXPECT().ResetPassword(gomock.Any(), gomock.Any()).Do(func(w http.ResponseWriter, d domain.ResetPasswordViewData) {
+		if !d.Sent || d.Message != "" {
+			t.Errorf("unexpected view data %v", d)
+		}
+	})
+
+	a := &AuthRoutes{
+		Config:             &domain.RuntimeConfig{},
+		Views:              views,
+		UserModel:          userModel,
+		PasswordResetModel: resetModel,
+		Mailer:             mailer}
+	a.Config.ExternalAccess.Scheme = "http"
+	a.Config.Exec.UserRoutesDomain = "dropid.example.com"
+
+	rr := httptest.NewRecorder()
+	a.postResetPassword(rr, csrfFormRequest(url.Values{"email": {"Me@Example.com"}}))
+
+	link := <-links
+	prefix := "http://dropid.example.com/login/new-password?token="
+	if !strings.HasPrefix(link, prefix) {
+		t.Fatalf("unexpected link %v", link)
+	}
+	token, _ := url.QueryUnescape(strings.TrimPrefix(link, prefix))
+	if hashResetToken(token) != tokenHash {
+		t.Error("expected the hash of the emailed token to be stored")
+	}
+}
+
+func TestPostResetPasswordNoAccount(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	userModel := testmocks.NewMockUserModel(mockCtrl)
+	userModel.EXPECT().GetFromEmail("me@example.com").Return(domain.User{}, sql.ErrNoRows)
+
+	views := testmocks.NewMockViews(mockCtrl)
+	views.EXPECT().ResetPassword(gomock.Any(), gomock.Any()).Do(func(w http.ResponseWriter, d domain.ResetPasswordViewData) {
+		if !d.Sent {
+			t.Error("expected the same response as when the account exists")
+		}
+	})
+
+	a := &AuthRoutes{
+		Config:    &domain.RuntimeConfig{},
+		Views:     views,
+		UserModel: userModel}
+
+	rr := httptest.NewRecorder()
+	a.postResetPassword(rr, csrfFormRequest(url.Values{"email": {"me@example.com"}}))
+}
+
+func TestPostNewPassword(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	userID := domain.UserID(7)
+
+	resetModel := testmocks.NewMockPasswordResetModel(mockCtrl)
+	resetModel.EXPECT().Get(hashResetToken("good-token")).Return(domain.PasswordReset{UserID: userID}, nil)
+	resetModel.EXPECT().DeleteForUser(userID).Return(nil)
+
+	userModel := testmocks.NewMockUserModel(mockCtrl)
+	userModel.EXPECT().GetFromID(userID).Return(domain.User{UserID: userID, Email: "me@example.com"}, nil)
+	userModel.EXPECT().UpdatePassword(userID, "newpassword123").Return(nil)
+
+	cookieModel := testmocks.NewMockCookieModel(mockCtrl)
+	cookieModel.EXPECT().DeleteForUser(userID, "").Return(nil)
+
+	views := testmocks.NewMockViews(mockCtrl)
+	views.EXPECT().Login(gomock.Any(), gomock.Any()).Do(func(w http.ResponseWriter, d domain.LoginViewData) {
+		if d.Email != "me@example.com" || !strings.Contains(d.Message, "password was changed") {
+			t.Errorf("unexpected view data %v", d)
+		}
+	})
+
+	a := &AuthRoutes{
+		Config:             &domain.RuntimeConfig{},
+		Views:              views,
+		UserModel:          userModel,
+		PasswordResetModel: resetModel,
+		CookieModel:        cookieModel,
+		Mailer:             noMailer(mockCtrl),
+		LoginLimiter:       getLoginLimiter(t)}
+
+	rr := httptest.NewRecorder()
+	a.postNewPassword(rr, csrfFormRequest(url.Values{
+		"token":     {"good-token"},
+		"password":  {"newpassword123"},
+		"password2": {"newpassword123"}}))
+}
+
+func TestPostNewPasswordBadToken(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	resetModel := testmocks.NewMockPasswordResetModel(mockCtrl)
+	resetModel.EXPECT().Get(hashResetToken("old-token")).Return(domain.PasswordReset{}, sql.ErrNoRows)
+
+	views := testmocks.NewMockViews(mockCtrl)
+	views.EXPECT().ResetPassword(gomock.Any(), gomock.Any()).Do(func(w http.ResponseWriter, d domain.ResetPasswordViewData) {
+		if d.Token != "" || !strings.Contains(d.Message, "expired") {
+			t.Errorf("expected invalid link message, got %v", d)
+		}
+	})
+
+	a := &AuthRoutes{
+		Config:             &domain.RuntimeConfig{},
+		Views:              views,
+		PasswordResetModel: resetModel}
+
+	rr := httptest.NewRecorder()
+	a.postNewPassword(rr, csrfFormRequest(url.Values{
+		"token":     {"old-token"},
+		"password":  {"newpassword123"},
+		"password2": {"newpassword123"}}))
+}
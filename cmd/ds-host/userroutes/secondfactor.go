@@ -0,0 +1,318 @@
+package userroutes
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/ratelimit"
+)
+
+// maxCredentialSize limits the size of WebAuthn responses posted by browsers
+const maxCredentialSize = 64 << 10
+
+// secondFactorRoutes are for users who entered their password
+// and have a pending session until they use or set up a second factor.
+func (a *AuthRoutes) secondFactorRoutes(r chi.Router) {
+	r.Get("/login/second-factor", a.getSecondFactor)
+	r.With(a.LoginLimiter.Middleware("second-factor", a.pendingAccount, a.secondFactorBlocked)).Post("/login/second-factor", a.postSecondFactor)
+	r.Post("/login/second-factor/passkey-options", a.postSecondFactorPasskeyOptions)
+	r.With(a.LoginLimiter.Middleware("second-factor", a.pendingAccount, nil)).Post("/login/second-factor/passkey", a.postSecondFactorPasskey)
+	r.Get("/login/setup-2fa", a.getSetupTwoFactor)
+	r.With(a.LoginLimiter.Middleware("setup-2fa", a.pendingAccount, a.setupTwoFactorBlocked)).Post("/login/setup-2fa", a.postSetupTwoFactor)
+}
+
+// pendingUser returns the user with a pending session,
+// or redirects to the login page if there is none.
+func (a *AuthRoutes) pendingUser(w http.ResponseWriter, r *http.Request) (domain.UserID, bool) {
+	userID, ok := a.Authenticator.PendingSecondFactor(r)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}
+	return userID, ok
+}
+
+// pendingAccount returns the key used to throttle second factor attempts per account.
+// It differs from the login key so that entering the right password
+// does not reset the count of bad codes.
+func (a *AuthRoutes) pendingAccount(r *http.Request) string {
+	userID, ok := a.Authenticator.PendingSecondFactor(r)
+	if !ok {
+		return ""
+	}
+	user, err := a.UserModel.GetFromID(userID)
+	if err != nil {
+		return ""
+	}
+	return user.Email + " (second factor)"
+}
+
+func (a *AuthRoutes) completeSecondFactor(w http.ResponseWriter, r *http.Request) bool {
+	err := a.Authenticator.CompleteSecondFactor(w, r)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+func (a *AuthRoutes) getSecondFactorViewData(userID domain.UserID) (domain.SecondFactorViewData, error) {
+	status, err := a.TwoFactor.Status(userID)
+	if err != nil {
+		return domain.SecondFactorViewData{}, err
+	}
+	return domain.SecondFactorViewData{
+		HasTOTP:     status.TOTP,
+		HasPasskeys: len(status.Passkeys) != 0}, nil
+}
+
+func (a *AuthRoutes) getSecondFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.pendingUser(w, r)
+	if !ok {
+		return
+	}
+	viewData, err := a.getSecondFactorViewData(userID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	if !viewData.HasTOTP && !viewData.HasPasskeys {
+		http.Redirect(w, r, "/login/setup-2fa", http.StatusFound)
+		return
+	}
+	viewData.CSRFToken = getCSRFToken(w, r, a.Config)
+	a.Views.SecondFactor(w, viewData)
+}
+
+// postSecondFactor accepts a TOTP code or a recovery code
+func (a *AuthRoutes) postSecondFactor(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	userID, ok := a.pendingUser(w, r)
+	if !ok {
+		return
+	}
+	viewData, err := a.getSecondFactorViewData(userID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	viewData.CSRFToken = getCSRFToken(w, r, a.Config)
+
+	if !validCSRFForm(r, a.Config) {
+		viewData.Message = csrfFailMessage
+		a.Views.SecondFactor(w, viewData)
+		return
+	}
+
+	code := strings.TrimSpace(r.PostForm.Get("code"))
+	ok, err = a.TwoFactor.CheckTOTP(userID, code)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	if !ok {
+		ok, err = a.TwoFactor.UseRecoveryCode(userID, code)
+		if err != nil {
+			returnError(w, err)
+			return
+		}
+	}
+	if !ok {
+		ratelimit.Fail(r)
+		viewData.Message = "Code incorrect"
+		a.Views.SecondFactor(w, viewData)
+		return
+	}
+
+	if a.completeSecondFactor(w, r) {
+		http.Redirect(w, r, "/", http.StatusFound)
+	}
+}
+
+func (a *AuthRoutes) secondFactorBlocked(w http.ResponseWriter, r *http.Request, retry time.Duration) {
+	userID, ok := a.pendingUser(w, r)
+	if !ok {
+		return
+	}
+	viewData, err := a.getSecondFactorViewData(userID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	viewData.Message = tooManyAttemptsMessage(retry)
+	viewData.CSRFToken = getCSRFToken(w, r, a.Config)
+	w.WriteHeader(http.StatusTooManyRequests)
+	a.Views.SecondFactor(w, viewData)
+}
+
+func (a *AuthRoutes) postSecondFactorPasskeyOptions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.Authenticator.PendingSecondFactor(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	opts, err := a.TwoFactor.BeginPasskeyLogin(userID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, opts)
+}
+
+func (a *AuthRoutes) postSecondFactorPasskey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.Authenticator.PendingSecondFactor(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCredentialSize))
+	if err != nil {
+		ratelimit.Ignore(r)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	_, err = a.TwoFactor.FinishPasskeyLogin(userID, body)
+	if err == domain.ErrBadAuth {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	if a.completeSecondFactor(w, r) {
+		writeOK(w)
+	}
+}
+
+// postPasskeyOptions starts a passwordless login with a passkey
+func (a *AuthRoutes) postPasskeyOptions(w http.ResponseWriter, r *http.Request) {
+	opts, err := a.TwoFactor.BeginPasskeyLogin(0)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, opts)
+}
+
+// postPasskey logs in the user whose passkey signed the challenge.
+// The passkey must have verified the user so it stands in for both factors.
+func (a *AuthRoutes) postPasskey(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCredentialSize))
+	if err != nil {
+		ratelimit.Ignore(r)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	userID, err := a.TwoFactor.FinishPasskeyLogin(0, body)
+	if err == domain.ErrBadAuth {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	err = a.Authenticator.SetForAccount(w, r, userID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeOK(w)
+}
+
+func (a *AuthRoutes) getSetupTwoFactorViewData(w http.ResponseWriter, r *http.Request, userID domain.UserID) (domain.SetupTwoFactorViewData, error) {
+	secret, uri, err := a.TwoFactor.BeginTOTP(userID)
+	if err != nil {
+		return domain.SetupTwoFactorViewData{}, err
+	}
+	return domain.SetupTwoFactorViewData{
+		CSRFToken: getCSRFToken(w, r, a.Config),
+		Secret:    secret,
+		URI:       uri}, nil
+}
+
+// hasSecondFactor redirects users who already have a second factor
+// so that setup can not be used to get around it.
+func (a *AuthRoutes) hasSecondFactor(w http.ResponseWriter, r *http.Request, userID domain.UserID) bool {
+	has, err := a.TwoFactor.Has(userID)
+	if err != nil {
+		returnError(w, err)
+		return true
+	}
+	if has {
+		http.Redirect(w, r, "/login/second-factor", http.StatusFound)
+	}
+	return has
+}
+
+func (a *AuthRoutes) getSetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.pendingUser(w, r)
+	if !ok || a.hasSecondFactor(w, r, userID) {
+		return
+	}
+	viewData, err := a.getSetupTwoFactorViewData(w, r, userID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	a.Views.SetupTwoFactor(w, viewData)
+}
+
+func (a *AuthRoutes) postSetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	userID, ok := a.pendingUser(w, r)
+	if !ok || a.hasSecondFactor(w, r, userID) {
+		return
+	}
+	viewData, err := a.getSetupTwoFactorViewData(w, r, userID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+
+	if !validCSRFForm(r, a.Config) {
+		viewData.Message = csrfFailMessage
+		a.Views.SetupTwoFactor(w, viewData)
+		return
+	}
+
+	codes, err := a.TwoFactor.ConfirmTOTP(userID, strings.TrimSpace(r.PostForm.Get("code")))
+	if err == domain.ErrBadAuth {
+		ratelimit.Fail(r)
+		viewData.Message = "Code incorrect"
+		a.Views.SetupTwoFactor(w, viewData)
+		return
+	}
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+
+	if !a.completeSecondFactor(w, r) {
+		return
+	}
+	if len(codes) == 0 {
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+	a.Views.SetupTwoFactor(w, domain.SetupTwoFactorViewData{RecoveryCodes: codes})
+}
+
+func (a *AuthRoutes) setupTwoFactorBlocked(w http.ResponseWriter, r *http.Request, retry time.Duration) {
+	userID, ok := a.pendingUser(w, r)
+	if !ok {
+		return
+	}
+	viewData, err := a.getSetupTwoFactorViewData(w, r, userID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	viewData.Message = tooManyAttemptsMessage(retry)
+	w.WriteHeader(http.StatusTooManyRequests)
+	a.Views.SetupTwoFactor(w, viewData)
+}
@@ -0,0 +1,175 @@
+package userroutes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+)
+
+func TestSecondFactorNotPending(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	authenticator := testmocks.NewMockAuthenticator(mockCtrl)
+	authenticator.EXPECT().PendingSecondFactor(gomock.Any()).Return(domain.UserID(0), false)
+
+	a := &AuthRoutes{
+		Config:        &domain.RuntimeConfig{},
+		Authenticator: authenticator}
+
+	rr := httptest.NewRecorder()
+	a.postSecondFactor(rr, csrfFormRequest(url.Values{"code": {"123456"}}))
+
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/login" {
+		t.Errorf("expected redirect to login, got %v %v", rr.Code, rr.Header().Get("Location"))
+	}
+}
+
+func TestSecondFactorPost(t *testing.T) {
+	cases := []struct {
+		totpOK     bool
+		recoveryOK bool
+	}{
+		{true, false},
+		{false, true},
+	}
+	for _, c := range cases {
+		mockCtrl := gomock.NewController(t)
+		userID := domain.UserID(7)
+
+		authenticator := testmocks.NewMockAuthenticator(mockCtrl)
+		authenticator.EXPECT().PendingSecondFactor(gomock.Any()).Return(userID, true)
+		authenticator.EXPECT().CompleteSecondFactor(gomock.Any(), gomock.Any()).Return(nil)
+
+		twoFactor := testmocks.NewMockTwoFactor(mockCtrl)
+		twoFactor.EXPECT().Status(userID).Return(domain.TwoFactorStatus{TOTP: true}, nil)
+		twoFactor.EXPECT().CheckTOTP(userID, "abcd-efgh").Return(c.totpOK, nil)
+		if !c.totpOK {
+			twoFactor.EXPECT().UseRecoveryCode(userID, "abcd-efgh").Return(c.recoveryOK, nil)
+		}
+
+		a := &AuthRoutes{
+			Config:        &domain.RuntimeConfig{},
+			Authenticator: authenticator,
+			TwoFactor:     twoFactor}
+
+		rr := httptest.NewRecorder()
+		a.postSecondFactor(rr, csrfFormRequest(url.Values{"code": {" abcd-efgh "}}))
+
+		if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/" {
+			t.Errorf("expected redirect to home, got %v %v", rr.Code, rr.Header().Get("Location"))
+		}
+		mockCtrl.Finish()
+	}
+}
+
+func TestSecondFactorPostBadCode(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	userID := domain.UserID(7)
+
+	authenticator := testmocks.NewMockAuthenticator(mockCtrl)
+	authenticator.EXPECT().PendingSecondFactor(gomock.Any()).Return(userID, true)
+
+	twoFactor := testmocks.NewMockTwoFactor(mockCtrl)
+	twoFactor.EXPECT().Status(userID).Return(domain.TwoFactorStatus{TOTP: true}, nil)
+	twoFactor.EXPECT().CheckTOTP(userID, "000000").Return(false, nil)
+	twoFactor.EXPECT().UseRecoveryCode(userID, "000000").Return(false, nil)
+
+	views := testmocks.NewMockViews(mockCtrl)
+	views.EXPECT().SecondFactor(gomock.Any(), gomock.Any()).Do(func(w http.ResponseWriter, d domain.SecondFactorViewData) {
+		if d.Message != "Code incorrect" || !d.HasTOTP {
+			t.Errorf("unexpected view data %v", d)
+		}
+	})
+
+	a := &AuthRoutes{
+		Config:        &domain.RuntimeConfig{},
+		Authenticator: authenticator,
+		TwoFactor:     twoFactor,
+		Views:         views}
+
+	rr := httptest.NewRecorder()
+	a.postSecondFactor(rr, csrfFormRequest(url.Values{"code": {"000000"}}))
+}
+
+func TestSetupTwoFactorHasFactor(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	userID := domain.UserID(7)
+
+	authenticator := testmocks.NewMockAuthenticator(mockCtrl)
+	authenticator.EXPECT().PendingSecondFactor(gomock.Any()).Return(userID, true)
+
+	twoFactor := testmocks.NewMockTwoFactor(mockCtrl)
+	twoFactor.EXPECT().Has(userID).Return(true, nil)
+
+	a := &AuthRoutes{
+		Config:        &domain.RuntimeConfig{},
+		Authenticator: authenticator,
+		TwoFactor:     twoFactor}
+
+	rr := httptest.NewRecorder()
+	a.postSetupTwoFactor(rr, csrfFormRequest(url.Values{"code": {"123456"}}))
+
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/login/second-factor" {
+		t.Errorf("expected redirect to second factor, got %v %v", rr.Code, rr.Header().Get("Location"))
+	}
+}
+
+func TestSetupTwoFactorPost(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	userID := domain.UserID(7)
+
+	authenticator := testmocks.NewMockAuthenticator(mockCtrl)
+	authenticator.EXPECT().PendingSecondFactor(gomock.Any()).Return(userID, true)
+	authenticator.EXPECT().CompleteSecondFactor(gomock.Any(), gomock.Any()).Return(nil)
+
+	twoFactor := testmocks.NewMockTwoFactor(mockCtrl)
+	twoFactor.EXPECT().Has(userID).Return(false, nil)
+	twoFactor.EXPECT().BeginTOTP(userID).Return("ABC", "otpauth://totp/x", nil)
+	twoFactor.EXPECT().ConfirmTOTP(userID, "123456").Return([]string{"aaaa-bbbb"}, nil)
+
+	views := testmocks.NewMockViews(mockCtrl)
+	views.EXPECT().SetupTwoFactor(gomock.Any(), gomock.Any()).Do(func(w http.ResponseWriter, d domain.SetupTwoFactorViewData) {
+		if len(d.RecoveryCodes) != 1 || d.Secret != "" {
+			t.Errorf("expected recovery codes only, got %v", d)
+		}
+	})
+
+	a := &AuthRoutes{
+		Config:        &domain.RuntimeConfig{},
+		Authenticator: authenticator,
+		TwoFactor:     twoFactor,
+		Views:         views}
+
+	rr := httptest.NewRecorder()
+	a.postSetupTwoFactor(rr, csrfFormRequest(url.Values{"code": {"123456"}}))
+}
+
+func TestPasskeyLoginBadAuth(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	twoFactor := testmocks.NewMockTwoFactor(mockCtrl)
+	twoFactor.EXPECT().FinishPasskeyLogin(domain.UserID(0), []byte(`{"id":"abc"}`)).Return(domain.UserID(0), domain.ErrBadAuth)
+
+	a := &AuthRoutes{
+		Config:    &domain.RuntimeConfig{},
+		TwoFactor: twoFactor}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/login/passkey", strings.NewReader(`{"id":"abc"}`))
+	a.postPasskey(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %v", rr.Code)
+	}
+}
@@ -0,0 +1,132 @@
+package userroutes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/record"
+)
+
+// SessionRoutes let users see where they are logged in
+// and log out of other sessions.
+type SessionRoutes struct {
+	CookieModel interface {
+		GetForUser(userID domain.UserID) ([]domain.Cookie, error)
+		Delete(cookieID string) error
+		DeleteForUser(userID domain.UserID, keepCookieID string) error
+	} `checkinject:"required"`
+}
+
+// - GET / :the user's account sessions
+// - DELETE / :log out of all sessions except the current one
+// - DELETE /{session_id} :log out of one session
+
+func (s *SessionRoutes) subRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/", s.getSessions)
+	r.Delete("/", s.deleteOtherSessions)
+	r.Delete("/{session_id}", s.deleteSession)
+	return r
+}
+
+func (s *SessionRoutes) getSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.CtxAuthUserID(r.Context())
+	if !ok {
+		s.getLogger("getSessions").Error(errors.New("no auth user id"))
+		httpInternalServerError(w)
+		return
+	}
+	cookies, err := s.CookieModel.GetForUser(userID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	currentID, _ := domain.CtxSessionID(r.Context())
+	writeJSON(w, sessionsFromCookies(cookies, currentID))
+}
+
+func (s *SessionRoutes) deleteOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.CtxAuthUserID(r.Context())
+	if !ok {
+		s.getLogger("deleteOtherSessions").Error(errors.New("no auth user id"))
+		httpInternalServerError(w)
+		return
+	}
+	currentID, _ := domain.CtxSessionID(r.Context())
+	err := s.CookieModel.DeleteForUser(userID, currentID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *SessionRoutes) deleteSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.CtxAuthUserID(r.Context())
+	if !ok {
+		s.getLogger("deleteSession").Error(errors.New("no auth user id"))
+		httpInternalServerError(w)
+		return
+	}
+	cookies, err := s.CookieModel.GetForUser(userID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	cookie, ok := findSession(cookies, chi.URLParam(r, "session_id"))
+	if !ok {
+		returnError(w, errNotFound)
+		return
+	}
+	err = s.CookieModel.Delete(cookie.CookieID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *SessionRoutes) getLogger(note string) *record.DsLogger {
+	r := record.NewDsLogger().AddNote("SessionRoutes")
+	if note != "" {
+		r.AddNote(note)
+	}
+	return r
+}
+
+// sessionID is the id of the session shown to users.
+// The cookie id is what authenticates a request so it must not be sent out.
+func sessionID(cookieID string) string {
+	h := sha256.Sum256([]byte(cookieID))
+	return hex.EncodeToString(h[:16])
+}
+
+func sessionsFromCookies(cookies []domain.Cookie, currentCookieID string) []domain.Session {
+	sessions := make([]domain.Session, len(cookies))
+	for i, c := range cookies {
+		sessions[i] = domain.Session{
+			SessionID:  sessionID(c.CookieID),
+			AppspaceID: c.AppspaceID,
+			ProxyID:    c.ProxyID,
+			UserAgent:  c.UserAgent,
+			IP:         c.IP,
+			Created:    c.Created,
+			LastSeen:   c.LastSeen,
+			Expires:    c.Expires,
+			Current:    currentCookieID != "" && c.CookieID == currentCookieID}
+	}
+	return sessions
+}
+
+func findSession(cookies []domain.Cookie, id string) (domain.Cookie, bool) {
+	for _, c := range cookies {
+		if sessionID(c.CookieID) == id {
+			return c, true
+		}
+	}
+	return domain.Cookie{}, false
+}
@@ -0,0 +1,93 @@
+package userroutes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+)
+
+func sessionRequest(method, target string, userID domain.UserID, cookieID string) *http.Request {
+	req := httptest.NewRequest(method, target, nil)
+	ctx := domain.CtxWithAuthUserID(req.Context(), userID)
+	return req.WithContext(domain.CtxWithSessionID(ctx, cookieID))
+}
+
+func TestGetSessions(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	userID := domain.UserID(7)
+
+	cm := testmocks.NewMockCookieModel(mockCtrl)
+	cm.EXPECT().GetForUser(userID).Return([]domain.Cookie{
+		{CookieID: "abc", UserID: userID, UserAgent: "Firefox"},
+		{CookieID: "def", UserID: userID, UserAgent: "Safari"},
+	}, nil)
+
+	s := &SessionRoutes{CookieModel: cm}
+	rr := httptest.NewRecorder()
+	s.subRouter().ServeHTTP(rr, sessionRequest(http.MethodGet, "/", userID, "def"))
+
+	var sessions []domain.Session
+	err := json.Unmarshal(rr.Body.Bytes(), &sessions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %v", sessions)
+	}
+	if sessions[0].Current || !sessions[1].Current {
+		t.Error("expected the second session to be current")
+	}
+	for _, s := range sessions {
+		if s.SessionID == "abc" || s.SessionID == "def" {
+			t.Error("cookie id must not be exposed")
+		}
+	}
+	if sessions[0].SessionID != sessionID("abc") {
+		t.Error("unexpected session id")
+	}
+}
+
+func TestDeleteSession(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	userID := domain.UserID(7)
+
+	cm := testmocks.NewMockCookieModel(mockCtrl)
+	cm.EXPECT().GetForUser(userID).Return([]domain.Cookie{{CookieID: "abc"}, {CookieID: "def"}}, nil).Times(2)
+	cm.EXPECT().Delete("abc").Return(nil)
+
+	s := &SessionRoutes{CookieModel: cm}
+	rr := httptest.NewRecorder()
+	s.subRouter().ServeHTTP(rr, sessionRequest(http.MethodDelete, "/"+sessionID("abc"), userID, "def"))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("unexpected status %v", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	s.subRouter().ServeHTTP(rr, sessionRequest(http.MethodDelete, "/"+sessionID("xyz"), userID, "def"))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown session, got %v", rr.Code)
+	}
+}
+
+func TestDeleteOtherSessions(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	userID := domain.UserID(7)
+
+	cm := testmocks.NewMockCookieModel(mockCtrl)
+	cm.EXPECT().DeleteForUser(userID, "def").Return(nil)
+
+	s := &SessionRoutes{CookieModel: cm}
+	rr := httptest.NewRecorder()
+	s.subRouter().ServeHTTP(rr, sessionRequest(http.MethodDelete, "/", userID, "def"))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("unexpected status %v", rr.Code)
+	}
+}
@@ -0,0 +1,186 @@
+package userroutes
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+// TwoFactorRoutes let users manage their second factors
+type TwoFactorRoutes struct {
+	TwoFactor interface {
+		Status(domain.UserID) (domain.TwoFactorStatus, error)
+		BeginTOTP(domain.UserID) (string, string, error)
+		ConfirmTOTP(domain.UserID, string) ([]string, error)
+		DisableTOTP(domain.UserID) error
+		NewRecoveryCodes(domain.UserID) ([]string, error)
+		BeginPasskeyRegistration(domain.UserID) (domain.WebAuthnCreationOptions, error)
+		FinishPasskeyRegistration(domain.UserID, string, []byte) (domain.Passkey, error)
+		DeletePasskey(domain.UserID, string) error
+	} `checkinject:"required"`
+}
+
+// - GET / :status of the user's second factors
+// - POST /totp :start TOTP setup, returns the secret and otpauth uri
+// - POST /totp/confirm :enable TOTP with a code, returns new recovery codes if any
+// - DELETE /totp
+// - POST /recovery-codes :replace the recovery codes
+// - POST /passkey/options :options to create a passkey in the browser
+// - POST /passkey :register the passkey created by the browser
+// - DELETE /passkey/{credential-id}
+
+func (t *TwoFactorRoutes) subRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Use(mustBeAuthenticated)
+	r.Get("/", t.getStatus)
+	r.Post("/totp", t.postTOTP)
+	r.Post("/totp/confirm", t.postTOTPConfirm)
+	r.Delete("/totp", t.deleteTOTP)
+	r.Post("/recovery-codes", t.postRecoveryCodes)
+	r.Post("/passkey/options", t.postPasskeyOptions)
+	r.Post("/passkey", t.postPasskey)
+	r.Delete("/passkey/{credential-id}", t.deletePasskey)
+	return r
+}
+
+func (t *TwoFactorRoutes) getStatus(w http.ResponseWriter, r *http.Request) {
+	userID, _ := domain.CtxAuthUserID(r.Context())
+	status, err := t.TwoFactor.Status(userID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, status)
+}
+
+type totpResp struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+func (t *TwoFactorRoutes) postTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, _ := domain.CtxAuthUserID(r.Context())
+	secret, uri, err := t.TwoFactor.BeginTOTP(userID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, totpResp{Secret: secret, URI: uri})
+}
+
+type totpConfirmPost struct {
+	Code string `json:"code"`
+}
+
+type recoveryCodesResp struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func (t *TwoFactorRoutes) postTOTPConfirm(w http.ResponseWriter, r *http.Request) {
+	userID, _ := domain.CtxAuthUserID(r.Context())
+	reqData := totpConfirmPost{}
+	err := readJSON(r, &reqData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	codes, err := t.TwoFactor.ConfirmTOTP(userID, reqData.Code)
+	if err == domain.ErrBadAuth {
+		writeBadRequest(w, "code", "incorrect")
+		return
+	}
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, recoveryCodesResp{RecoveryCodes: codes})
+}
+
+func (t *TwoFactorRoutes) deleteTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, _ := domain.CtxAuthUserID(r.Context())
+	err := t.TwoFactor.DisableTOTP(userID)
+	if err == domain.ErrLastSecondFactor {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (t *TwoFactorRoutes) postRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, _ := domain.CtxAuthUserID(r.Context())
+	codes, err := t.TwoFactor.NewRecoveryCodes(userID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, recoveryCodesResp{RecoveryCodes: codes})
+}
+
+func (t *TwoFactorRoutes) postPasskeyOptions(w http.ResponseWriter, r *http.Request) {
+	userID, _ := domain.CtxAuthUserID(r.Context())
+	opts, err := t.TwoFactor.BeginPasskeyRegistration(userID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, opts)
+}
+
+type passkeyPost struct {
+	Name       string          `json:"name"`
+	Credential json.RawMessage `json:"credential"`
+}
+
+func (t *TwoFactorRoutes) postPasskey(w http.ResponseWriter, r *http.Request) {
+	userID, _ := domain.CtxAuthUserID(r.Context())
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCredentialSize))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	reqData := passkeyPost{}
+	err = json.Unmarshal(body, &reqData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(reqData.Name) > 100 {
+		writeBadRequest(w, "name", "too long")
+		return
+	}
+	passkey, err := t.TwoFactor.FinishPasskeyRegistration(userID, reqData.Name, reqData.Credential)
+	if err == domain.ErrBadAuth {
+		writeBadRequest(w, "credential", "not accepted")
+		return
+	}
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, passkey)
+}
+
+func (t *TwoFactorRoutes) deletePasskey(w http.ResponseWriter, r *http.Request) {
+	userID, _ := domain.CtxAuthUserID(r.Context())
+	err := t.TwoFactor.DeletePasskey(userID, chi.URLParam(r, "credential-id"))
+	if err == domain.ErrLastSecondFactor {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err == domain.ErrNoRowsAffected {
+		writeNotFound(w)
+		return
+	}
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,63 @@
+package userroutes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/cmd/ds-host/testmocks"
+)
+
+func twoFactorRequest(method, path, body string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	return req.WithContext(domain.CtxWithAuthUserID(req.Context(), domain.UserID(7)))
+}
+
+func TestPostTOTPConfirm(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	twoFactor := testmocks.NewMockTwoFactor(mockCtrl)
+	twoFactor.EXPECT().ConfirmTOTP(domain.UserID(7), "000000").Return(nil, domain.ErrBadAuth)
+	twoFactor.EXPECT().ConfirmTOTP(domain.UserID(7), "123456").Return([]string{"aaaa-bbbb"}, nil)
+
+	r := (&TwoFactorRoutes{TwoFactor: twoFactor}).subRouter()
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, twoFactorRequest(http.MethodPost, "/totp/confirm", `{"code":"000000"}`))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %v", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, twoFactorRequest(http.MethodPost, "/totp/confirm", `{"code":"123456"}`))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "aaaa-bbbb") {
+		t.Errorf("expected recovery codes, got %v %v", rr.Code, rr.Body.String())
+	}
+}
+
+func TestDeleteLastSecondFactor(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	twoFactor := testmocks.NewMockTwoFactor(mockCtrl)
+	twoFactor.EXPECT().DisableTOTP(domain.UserID(7)).Return(domain.ErrLastSecondFactor)
+	twoFactor.EXPECT().DeletePasskey(domain.UserID(7), "cred1").Return(domain.ErrLastSecondFactor)
+
+	r := (&TwoFactorRoutes{TwoFactor: twoFactor}).subRouter()
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, twoFactorRequest(http.MethodDelete, "/totp", ""))
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected 409, got %v", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, twoFactorRequest(http.MethodDelete, "/passkey/cred1", ""))
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected 409, got %v", rr.Code)
+	}
+}
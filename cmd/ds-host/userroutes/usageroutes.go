@@ -0,0 +1,104 @@
+package userroutes
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+// UsageRoutes let users see the resources used by their appspaces and apps
+type UsageRoutes struct {
+	UsageModel interface {
+		GetForOwner(ownerID domain.UserID, period string, from time.Time, to time.Time) ([]domain.UsageRollup, error)
+	} `checkinject:"required"`
+}
+
+// - GET /?period=day|week|month&from=<RFC3339>&to=<RFC3339>&appspace_id=<id>
+//   :rollups of the user's sandbox runs. Defaults to the last 12 months.
+//   Runs not for an appspace have an appspace_id of 0.
+
+func (u *UsageRoutes) subRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Use(mustBeAuthenticated)
+	r.Get("/", u.getUsage)
+	return r
+}
+
+func (u *UsageRoutes) getUsage(w http.ResponseWriter, r *http.Request) {
+	userID, _ := domain.CtxAuthUserID(r.Context())
+
+	period, from, to, err := usageQuery(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rollups, err := u.UsageModel.GetForOwner(userID, period, from, to)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+
+	if appspaceIDStr := r.URL.Query().Get("appspace_id"); appspaceIDStr != "" {
+		appspaceID, err := strconv.Atoi(appspaceIDStr)
+		if err != nil {
+			http.Error(w, "invalid appspace_id", http.StatusBadRequest)
+			return
+		}
+		filtered := []domain.UsageRollup{}
+		for _, ru := range rollups {
+			if ru.AppspaceID == domain.AppspaceID(appspaceID) {
+				filtered = append(filtered, ru)
+			}
+		}
+		rollups = filtered
+	}
+
+	writeJSON(w, rollups)
+}
+
+// usageQuery reads the period and time range of a usage request.
+// By default it returns the last 31 days, 12 weeks or 12 months.
+func usageQuery(r *http.Request, now time.Time) (period string, from time.Time, to time.Time, err error) {
+	q := r.URL.Query()
+	period = q.Get("period")
+	if period == "" {
+		period = domain.UsageMonth
+	}
+
+	to = now
+	if s := q.Get("to"); s != "" {
+		to, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			err = errors.New("invalid to: " + err.Error())
+			return
+		}
+	}
+
+	switch period {
+	case domain.UsageDay:
+		from = to.AddDate(0, 0, -31)
+	case domain.UsageWeek:
+		from = to.AddDate(0, 0, -12*7)
+	case domain.UsageMonth:
+		from = to.AddDate(0, -12, 0)
+	default:
+		err = errors.New("period must be day, week or month")
+		return
+	}
+	if s := q.Get("from"); s != "" {
+		from, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			err = errors.New("invalid from: " + err.Error())
+			return
+		}
+	}
+	if !from.Before(to) {
+		err = errors.New("from must be before to")
+	}
+	return
+}
@@ -0,0 +1,44 @@
+package userroutes
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+func TestUsageQuery(t *testing.T) {
+	now := time.Date(2026, time.October, 17, 12, 0, 0, 0, time.UTC)
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	period, from, to, err := usageQuery(req, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if period != domain.UsageMonth || !to.Equal(now) || !from.Equal(now.AddDate(-1, 0, 0)) {
+		t.Errorf("unexpected defaults: %v %v %v", period, from, to)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/?period=day&from=2026-10-01T00:00:00Z&to=2026-10-08T00:00:00Z", nil)
+	period, from, to, err = usageQuery(req, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if period != domain.UsageDay || from.Day() != 1 || to.Day() != 8 {
+		t.Errorf("unexpected values: %v %v %v", period, from, to)
+	}
+
+	bad := []string{
+		"/?period=year",
+		"/?from=yesterday",
+		"/?from=2026-10-08T00:00:00Z&to=2026-10-01T00:00:00Z",
+	}
+	for _, b := range bad {
+		req, _ = http.NewRequest(http.MethodGet, b, nil)
+		_, _, _, err = usageQuery(req, now)
+		if err == nil {
+			t.Errorf("expected error for %v", b)
+		}
+	}
+}
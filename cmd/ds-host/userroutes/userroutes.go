@@ -56,6 +56,10 @@ type UserRoutes struct {
 	DropIDRoutes          subRoutes  `checkinject:"required"`
 	MigrationJobRoutes    subRoutes  `checkinject:"required"`
 	AdminRoutes           subRoutes  `checkinject:"required"`
+	V0TransferRoutes      subRoutes  `checkinject:"required"`
+	UsageRoutes           subRoutes  `checkinject:"required"`
+	TwoFactorRoutes       subRoutes  `checkinject:"required"`
+	SessionRoutes         subRoutes  `checkinject:"required"`
 	UserTSNetStatusEvents interface {
 		Subscribe() <-chan domain.TSNetStatus
 		Unsubscribe(ch <-chan domain.TSNetStatus)
@@ -91,6 +95,9 @@ type UserRoutes struct {
 		GetFromEmailPassword(email, password string) (domain.User, error)
 		IsAdmin(userID domain.UserID) bool
 	} `checkinject:"required"`
+	CookieModel interface {
+		DeleteForUser(userID domain.UserID, keepCookieID string) error
+	} `checkinject:"required"`
 
 	mux *chi.Mux
 }
@@ -105,8 +112,12 @@ func (u *UserRoutes) BuildRoutes(r *chi.Mux) {
 	}
 	r.Handle("/frontend-assets/*", http.FileServer(http.FS(frontendFS)))
 
+	// ds2ds routes for appspaces pushed from other hosts
+	r.Mount("/.dropserver/v0/transfer", u.V0TransferRoutes.subRouter())
+
 	r.Group(func(r chi.Router) {
 		r.Use(mustBeAuthenticated)
+		r.Use(sameOriginOnly)
 
 		// add a "update cookie" as a trailing middleware. It'll only get called if request doesn't get aborted. (I think? Does it really matter?)
 		// ^^ but only if not tsnet
@@ -123,6 +134,8 @@ func (u *UserRoutes) BuildRoutes(r *chi.Mux) {
 			r.Get("/user/", u.getUserData)
 			r.Patch("/user/email/", u.changeUserEmail)
 			r.Patch("/user/password/", u.changeUserPassword)
+			r.Mount("/user/2fa", u.TwoFactorRoutes.subRouter())
+			r.Mount("/user/session", u.SessionRoutes.subRouter())
 
 			r.Mount("/domainname", u.DomainRoutes.subRouter())
 			r.Mount("/dropid", u.DropIDRoutes.subRouter())
@@ -131,6 +144,7 @@ func (u *UserRoutes) BuildRoutes(r *chi.Mux) {
 			r.Mount("/remoteappspace", u.RemoteAppspaceRoutes.subRouter())
 			r.Mount("/contact", u.ContactRoutes.subRouter())
 			r.Mount("/migration-job", u.MigrationJobRoutes.subRouter())
+			r.Mount("/usage", u.UsageRoutes.subRouter())
 		})
 	})
 
@@ -339,6 +353,14 @@ func (u *UserRoutes) changeUserPassword(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// log out everywhere else in case the old password was compromised
+	currentID, _ := domain.CtxSessionID(r.Context())
+	err = u.CookieModel.DeleteForUser(user.UserID, currentID)
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK) // TODO send no content.
 }
 
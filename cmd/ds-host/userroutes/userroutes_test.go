@@ -187,8 +187,12 @@ func TestChangePassword(t *testing.T) {
 	um.EXPECT().GetFromEmailPassword("abc@def", "secretsauce").Return(domain.User{}, nil)
 	um.EXPECT().UpdatePassword(uid, "secretspice").Return(nil)
 
+	cm := testmocks.NewMockCookieModel(mockCtrl)
+	cm.EXPECT().DeleteForUser(uid, "current-cookie").Return(nil)
+
 	u := UserRoutes{
-		UserModel: um}
+		UserModel:   um,
+		CookieModel: cm}
 
 	rr := httptest.NewRecorder()
 
@@ -198,7 +202,8 @@ func TestChangePassword(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	req = req.WithContext(domain.CtxWithAuthUserID(req.Context(), uid))
+	ctx := domain.CtxWithAuthUserID(req.Context(), uid)
+	req = req.WithContext(domain.CtxWithSessionID(ctx, "current-cookie"))
 	req.Header.Set("Content-Type", "application/json")
 
 	u.changeUserPassword(rr, req)
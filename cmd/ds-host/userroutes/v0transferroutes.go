@@ -0,0 +1,134 @@
+package userroutes
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+// V0TransferRoutes are the ds2ds routes used by another host
+// to push an appspace to this host. Requests are authorized by the
+// session opened with /hello, not by a user cookie.
+type V0TransferRoutes struct {
+	ReceiveTransfer interface {
+		Hello(ticketID string, hello domain.V0TransferHello) (domain.V0TransferHelloResp, error)
+		Authorize(ticketID string, session string) bool
+		GetOffset(ticketID string) (int64, error)
+		WriteChunk(ticketID string, offset int64, reader io.Reader) (int64, error)
+		Import(ticketID string, data domain.V0TransferImport) error
+		GetStatus(ticketID string) (domain.V0TransferStatus, error)
+	} `checkinject:"required"`
+}
+
+// - POST /<ticket>/hello :open a session
+// - HEAD /<ticket>/bundle :Upload-Offset header has the bytes received so far
+// - PATCH /<ticket>/bundle :append a chunk at Upload-Offset
+// - POST /<ticket>/import :import the uploaded bundle
+// - GET /<ticket>/status :state of the transfer
+
+func (v *V0TransferRoutes) subRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Route("/{ticket}", func(r chi.Router) {
+		r.Post("/hello", v.hello)
+		r.Group(func(r chi.Router) {
+			r.Use(v.authorize)
+			r.Head("/bundle", v.getOffset)
+			r.Patch("/bundle", v.writeChunk)
+			r.Post("/import", v.importBundle)
+			r.Get("/status", v.getStatus)
+		})
+	})
+	return r
+}
+
+func (v *V0TransferRoutes) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !v.ReceiveTransfer.Authorize(chi.URLParam(r, "ticket"), session) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (v *V0TransferRoutes) hello(w http.ResponseWriter, r *http.Request) {
+	var data domain.V0TransferHello
+	err := readJSON(r, &data)
+	if err != nil {
+		http.Error(w, "unable to parse JSON", http.StatusBadRequest)
+		return
+	}
+	resp, err := v.ReceiveTransfer.Hello(chi.URLParam(r, "ticket"), data)
+	if err == domain.ErrTokenNotFound {
+		returnError(w, errNotFound)
+		return
+	}
+	if err == domain.ErrBadAuth {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+func (v *V0TransferRoutes) getOffset(w http.ResponseWriter, r *http.Request) {
+	offset, err := v.ReceiveTransfer.GetOffset(chi.URLParam(r, "ticket"))
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (v *V0TransferRoutes) writeChunk(w http.ResponseWriter, r *http.Request) {
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid Upload-Offset", http.StatusBadRequest)
+		return
+	}
+	newOffset, err := v.ReceiveTransfer.WriteChunk(chi.URLParam(r, "ticket"), offset, r.Body)
+	w.Header().Set("Upload-Offset", strconv.FormatInt(newOffset, 10))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case domain.ErrTransferOffset:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case domain.ErrStorageExceeded:
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	default:
+		handleError(w, err)
+	}
+}
+
+func (v *V0TransferRoutes) importBundle(w http.ResponseWriter, r *http.Request) {
+	var data domain.V0TransferImport
+	err := readJSON(r, &data)
+	if err != nil {
+		http.Error(w, "unable to parse JSON", http.StatusBadRequest)
+		return
+	}
+	err = v.ReceiveTransfer.Import(chi.URLParam(r, "ticket"), data)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+	writeOK(w)
+}
+
+func (v *V0TransferRoutes) getStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := v.ReceiveTransfer.GetStatus(chi.URLParam(r, "ticket"))
+	if err != nil {
+		returnError(w, err)
+		return
+	}
+	writeJSON(w, status)
+}
@@ -0,0 +1,53 @@
+// Passkey sign-in for the login pages.
+// Binary values travel to and from the server as base64url strings.
+
+function b64urlToBuf(s) {
+	const b64 = s.replace(/-/g, '+').replace(/_/g, '/');
+	return Uint8Array.from(atob(b64), c => c.charCodeAt(0)).buffer;
+}
+
+function bufToB64url(buf) {
+	const s = String.fromCharCode(...new Uint8Array(buf));
+	return btoa(s).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+}
+
+async function passkeyLogin(optionsURL, loginURL) {
+	const optsResp = await fetch(optionsURL, {method: 'POST'});
+	if (!optsResp.ok) throw new Error('Could not start passkey sign-in.');
+	const opts = await optsResp.json();
+	opts.challenge = b64urlToBuf(opts.challenge);
+	opts.allowCredentials = opts.allowCredentials.map(c => Object.assign(c, {id: b64urlToBuf(c.id)}));
+
+	const cred = await navigator.credentials.get({publicKey: opts});
+	const r = cred.response;
+	const body = {
+		id: cred.id,
+		response: {
+			clientDataJSON: bufToB64url(r.clientDataJSON),
+			authenticatorData: bufToB64url(r.authenticatorData),
+			signature: bufToB64url(r.signature),
+			userHandle: r.userHandle ? bufToB64url(r.userHandle) : ''
+		}
+	};
+	const loginResp = await fetch(loginURL, {
+		method: 'POST',
+		headers: {'Content-Type': 'application/json'},
+		body: JSON.stringify(body)
+	});
+	if (!loginResp.ok) throw new Error('Passkey not accepted.');
+	window.location = '/';
+}
+
+document.querySelectorAll('[data-passkey-options]').forEach(button => {
+	if (!window.PublicKeyCredential) return;
+	button.hidden = false;
+	button.addEventListener('click', async () => {
+		const msg = document.querySelector('.passkey-message');
+		msg.textContent = '';
+		try {
+			await passkeyLogin(button.dataset.passkeyOptions, button.dataset.passkeyLogin);
+		} catch(e) {
+			msg.textContent = e.name === 'NotAllowedError' ? 'Passkey sign-in was cancelled.' : e.message;
+		}
+	});
+});
@@ -17,8 +17,11 @@ type Views struct {
 
 	base BaseData
 
-	loginTemplate  *template.Template
-	signupTemplate *template.Template
+	loginTemplate          *template.Template
+	signupTemplate         *template.Template
+	secondFactorTemplate   *template.Template
+	setupTwoFactorTemplate *template.Template
+	resetPasswordTemplate  *template.Template
 }
 
 // BaseData is the basic data that the page needs to render
@@ -32,6 +35,15 @@ var loginTemplateStr string
 //go:embed signup.html
 var signupTemplateStr string
 
+//go:embed second-factor.html
+var secondFactorTemplateStr string
+
+//go:embed setup-2fa.html
+var setupTwoFactorTemplateStr string
+
+//go:embed reset-password.html
+var resetPasswordTemplateStr string
+
 //go:embed static
 var StaticFiles embed.FS
 
@@ -49,6 +61,9 @@ func (v *Views) PrepareTemplates() {
 
 	v.loginTemplate = template.Must(template.New("login").Parse(loginTemplateStr))
 	v.signupTemplate = template.Must(template.New("signup").Parse(signupTemplateStr))
+	v.secondFactorTemplate = template.Must(template.New("second-factor").Parse(secondFactorTemplateStr))
+	v.setupTwoFactorTemplate = template.Must(template.New("setup-2fa").Parse(setupTwoFactorTemplateStr))
+	v.resetPasswordTemplate = template.Must(template.New("reset-password").Parse(resetPasswordTemplateStr))
 }
 
 type loginData struct {
@@ -89,6 +104,59 @@ func (v *Views) Signup(res http.ResponseWriter, viewData domain.SignupViewData)
 	}
 }
 
+type secondFactorData struct {
+	BaseData
+	SecondFactorViewData domain.SecondFactorViewData
+}
+
+// SecondFactor asks a user who entered their password for their second factor
+func (v *Views) SecondFactor(res http.ResponseWriter, viewData domain.SecondFactorViewData) {
+	d := secondFactorData{
+		BaseData:             v.base,
+		SecondFactorViewData: viewData}
+
+	err := v.secondFactorTemplate.Execute(res, d)
+	if err != nil {
+		v.getLogger("SecondFactor()").Error(err)
+	}
+}
+
+type setupTwoFactorData struct {
+	BaseData
+	SetupTwoFactorViewData domain.SetupTwoFactorViewData
+	URI                    template.URL // otpauth: links are not trusted by default
+}
+
+// SetupTwoFactor presents the TOTP setup page to users who are required to have 2FA
+func (v *Views) SetupTwoFactor(res http.ResponseWriter, viewData domain.SetupTwoFactorViewData) {
+	d := setupTwoFactorData{
+		BaseData:               v.base,
+		SetupTwoFactorViewData: viewData,
+		URI:                    template.URL(viewData.URI)}
+
+	err := v.setupTwoFactorTemplate.Execute(res, d)
+	if err != nil {
+		v.getLogger("SetupTwoFactor()").Error(err)
+	}
+}
+
+type resetPasswordData struct {
+	BaseData
+	ResetPasswordViewData domain.ResetPasswordViewData
+}
+
+// ResetPassword presents the pages to ask for a reset link and to set a new password
+func (v *Views) ResetPassword(res http.ResponseWriter, viewData domain.ResetPasswordViewData) {
+	d := resetPasswordData{
+		BaseData:              v.base,
+		ResetPasswordViewData: viewData}
+
+	err := v.resetPasswordTemplate.Execute(res, d)
+	if err != nil {
+		v.getLogger("ResetPassword()").Error(err)
+	}
+}
+
 func (v *Views) getLogger(note string) *record.DsLogger {
 	r := record.NewDsLogger().AddNote("Views")
 	if note != "" {
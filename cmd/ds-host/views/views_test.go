@@ -108,6 +108,75 @@ func TestSignupData(t *testing.T) {
 	}
 }
 
+func TestSecondFactor(t *testing.T) {
+	v := getV()
+
+	v.PrepareTemplates()
+
+	rr := httptest.NewRecorder()
+
+	v.SecondFactor(rr, domain.SecondFactorViewData{
+		Message:     "Code incorrect",
+		HasPasskeys: true})
+
+	bodyStr := rr.Body.String()
+	if !strings.Contains(bodyStr, "Code incorrect") {
+		t.Error("message didn't make it into html")
+	}
+	if !strings.Contains(bodyStr, "/login/second-factor/passkey") {
+		t.Error("expected passkey button")
+	}
+	if !strings.Contains(bodyStr, "</html>") {
+		t.Error("End of template disappeared from html")
+	}
+}
+
+func TestSetupTwoFactor(t *testing.T) {
+	v := getV()
+
+	v.PrepareTemplates()
+
+	rr := httptest.NewRecorder()
+
+	v.SetupTwoFactor(rr, domain.SetupTwoFactorViewData{
+		Secret: "ABCDEF",
+		URI:    "otpauth://totp/ds:me?secret=ABCDEF"})
+
+	bodyStr := rr.Body.String()
+	if !strings.Contains(bodyStr, `href="otpauth://totp/ds:me?secret=ABCDEF"`) {
+		t.Error("otpauth uri didn't make it into html")
+	}
+
+	rr = httptest.NewRecorder()
+	v.SetupTwoFactor(rr, domain.SetupTwoFactorViewData{
+		RecoveryCodes: []string{"aaaa-bbbb"}})
+
+	bodyStr = rr.Body.String()
+	if !strings.Contains(bodyStr, "aaaa-bbbb") || strings.Contains(bodyStr, "Turn On") {
+		t.Error("expected recovery codes instead of the form")
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	v := getV()
+
+	v.PrepareTemplates()
+
+	rr := httptest.NewRecorder()
+	v.ResetPassword(rr, domain.ResetPasswordViewData{Email: "me@example.com"})
+	bodyStr := rr.Body.String()
+	if !strings.Contains(bodyStr, `action="/login/reset-password"`) || !strings.Contains(bodyStr, "me@example.com") {
+		t.Error("expected form to ask for a reset link")
+	}
+
+	rr = httptest.NewRecorder()
+	v.ResetPassword(rr, domain.ResetPasswordViewData{Token: "abc123"})
+	bodyStr = rr.Body.String()
+	if !strings.Contains(bodyStr, `action="/login/new-password"`) || !strings.Contains(bodyStr, `value="abc123"`) {
+		t.Error("expected form to set new password with token")
+	}
+}
+
 // func TestUserHome(t *testing.T) {
 // 	v := getV()
 
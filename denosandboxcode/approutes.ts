@@ -8,9 +8,21 @@ import DsServices from './services/services.ts';
 
 type handlerOpts = {
 	name: string
+	cache?: boolean
 }
 type staticOpts = {
 	path: string
+	index?: string
+	dir_listing?: boolean
+	etag?: boolean
+	cache_control?: string
+}
+type redirectOpts = {
+	target: string
+	status?: number
+}
+type proxyOpts = {
+	target: string
 }
 
 interface RouteBase {
@@ -29,15 +41,24 @@ interface StaticRoute extends RouteBase {
 	type: RouteType.static
 	opts: staticOpts
 }
-type Route = SandboxRoute | StaticRoute
+// Declarative routes are handled by the host without calling into the sandbox
+interface RedirectRoute extends RouteBase {
+	type: "redirect"
+	opts: redirectOpts
+}
+interface ProxyRoute extends RouteBase {
+	type: "proxy"
+	opts: proxyOpts
+}
+type Route = SandboxRoute | StaticRoute | RedirectRoute | ProxyRoute
 
 export type RouteExport = {
 	id: string,
 	method: string,
 	path: Path,
 	auth: Auth,
-	type: RouteType,
-	options: staticOpts|handlerOpts
+	type: RouteType|"redirect"|"proxy",
+	options: staticOpts|handlerOpts|redirectOpts|proxyOpts
 }
 
 /**
@@ -73,7 +94,8 @@ export default class AppRoutes {
 					auth: r.auth,
 					type: r.type,
 					handler: r.handler,
-					opts: {name: r.handlerName}
+					// cache is not part of this version of lib support's route type
+					opts: {name: r.handlerName, cache: (<{cache?:boolean}><unknown>r).cache}
 				};
 			}
 			else if( r.type === RouteType.static ) {
@@ -86,6 +108,18 @@ export default class AppRoutes {
 					opts: r.opts
 				};
 			}
+			//@ts-ignore redirect and proxy are not in the RouteType of this version of lib support
+			else if( r.type === "redirect" || r.type === "proxy" ) {
+				const d = <{method:string, path:Path, auth:Auth, type:"redirect"|"proxy", opts:redirectOpts}><unknown>r;
+				stored = {
+					id: makeRouteIdentifier(d.method, d.path),
+					method: normalizeMethod(d.method),
+					path: d.path,
+					auth: d.auth,
+					type: d.type,
+					opts: d.opts
+				};
+			}
 			else {
 				//@ts-ignore r.type does not exist here according to TS, but TS doesn't know everything
 				throw new Error("Route type not recognized: "+r.type);
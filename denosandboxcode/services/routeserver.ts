@@ -117,11 +117,17 @@ export default class DsRouteServer {
 		}
 
 		const t1 = performance.now();
-		console.log(`request took ${t1 - t0} milliseconds.`);
+		console.log(`${requestPrefix(reqEvent)}request took ${t1 - t0} milliseconds.`);
 	}
 
 	replyError(reqEvent :RequestEvent, message :string) {
-		console.error(message);
+		console.error(requestPrefix(reqEvent) + message);
 		reqEvent.respondWith( new Response(message,{status: 500}));
 	}
 }
+
+// requestPrefix lets sandbox logs be matched with ds-host logs for the same request
+function requestPrefix(reqEvent :RequestEvent) :string {
+	const request_id = reqEvent.request.headers.get("X-Dropserver-Request-ID");
+	return request_id ? `[request ${request_id}] ` : "";
+}
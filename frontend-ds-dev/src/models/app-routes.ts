@@ -33,13 +33,20 @@ export type RouteHandler = {
 }
 export type RouteOptions = {
 	name?: string,	//used for JS handlers
-	path?: string	// used by static DS handler
+	cache?: boolean,
+	path?: string,	// used by static DS handler
+	index?: string,
+	dir_listing?: boolean,
+	etag?: boolean,
+	cache_control?: string,
+	target?: string,	// used by redirect and proxy handlers
+	status?: number
 }
 export type RouteConfig = {
 	method: string,
 	path: RoutePath,
 	auth: RouteAuth,
-	type: string,	// "function " or "static"
+	type: string,	// "function", "static", "redirect" or "proxy"
 	options: RouteOptions
 }
 
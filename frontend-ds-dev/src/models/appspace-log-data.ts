@@ -17,6 +17,7 @@ export type AppspaceLogEntry = {
 // outgoing commands:
 const subscribeAppspaceLogCmd = 11
 const subscribeAppLogCmd = 12
+const queryAppspaceLogCmd = 14
 
 // incoming commands, ref to 11:
 const statusSubCmd = 11
@@ -153,3 +154,39 @@ function parseEntry(entry :string) :AppspaceLogEntry {
 	//console.log("parsed entry: ", entry, ret);
 	return ret;
 }
+
+export type AppspaceLogQuery = {
+	from?: string,	// RFC3339
+	to?: string,
+	source?: string,
+	text?: string,
+	limit?: number,
+	before?: string	// "next" from previous result
+}
+
+export type AppspaceLogQueryResult = {
+	entries: AppspaceLogEntry[],
+	next: string
+}
+
+// queryAppspaceLog returns matching log entries, newest first
+export async function queryAppspaceLog(appspace_id :number, query :AppspaceLogQuery) :Promise<AppspaceLogQueryResult> {
+	await twineClient.ready();
+	const payload = new TextEncoder().encode(JSON.stringify(Object.assign({appspace_id}, query)));
+	const reply = await twineClient.twine.sendBlock(15, queryAppspaceLogCmd, payload);
+	if( reply.error ) {
+		throw reply.error;
+	}
+	const data = JSON.parse(new TextDecoder('utf-8').decode(reply.payload));
+	return {
+		next: data.next,
+		entries: data.entries.map( (e:any) => {
+			return {
+				appspace_id,
+				time: new Date(e.time),
+				source: e.source,
+				message: e.message.replaceAll('\\n', '\n').trim()
+			};
+		})
+	};
+}
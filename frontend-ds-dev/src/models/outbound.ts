@@ -0,0 +1,60 @@
+import {reactive} from 'vue';
+import twineClient from './twine-client';
+import {ReceivedMessageI} from 'twine-web';
+
+const outbound_commands = {
+	exchange: 11,
+};
+
+export type OutboundExchange = {
+	timestamp: string,
+	appspace_id: number,
+	method: string,
+	url: string,
+	request_header: Record<string, string[]>|null,
+	request_body: string,
+	request_truncated: boolean,
+	status: number,
+	response_header: Record<string, string[]>|null,
+	response_body: string,
+	response_truncated: boolean,
+	duration: number,	// nanoseconds
+	error: string
+}
+
+class OutboundEvents {
+	exchanges :OutboundExchange[];
+	constructor() {
+		twineClient.registerService(20, this);
+		this.exchanges = reactive([]);
+	}
+	handleMessage(m:ReceivedMessageI) {
+		switch(m.command){
+			case outbound_commands.exchange:
+				this.pushExchange(m);
+			break;
+			default:
+				m.sendError("unrecognized service");
+		}
+	}
+	pushExchange(m:ReceivedMessageI) {
+		try {
+			const e = <OutboundExchange>JSON.parse(new TextDecoder('utf-8').decode(m.payload));
+			this.exchanges.push(e);
+		}
+		catch(e) {
+			m.sendError("error processing outbound exchange "+e);
+			console.error(e);
+			return;
+		}
+
+		m.sendOK();
+	}
+	clear() {
+		this.exchanges.splice(0, this.exchanges.length);
+	}
+}
+
+const outboundEvents = new OutboundEvents();
+
+export default outboundEvents;
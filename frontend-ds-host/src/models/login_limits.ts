@@ -0,0 +1,36 @@
+import {get, del} from '../controllers/userapi';
+
+// Login limits throttle failed logins by IP and by account.
+
+export type LoginBlock = {
+	kind: 'ip' | 'account',
+	key: string,
+	failures: number,
+	until: Date,
+	lockout: boolean
+}
+
+export type BlockedLoginAttempt = {
+	time: Date,
+	route: string,
+	ip: string,
+	account: string,
+	kind: 'ip' | 'account'
+}
+
+export type LoginLimitStatus = {
+	blocks: LoginBlock[],
+	blocked: BlockedLoginAttempt[]
+}
+
+export async function fetchLoginLimits() :Promise<LoginLimitStatus> {
+	const data = await get('/admin/login-limits');
+	return {
+		blocks: data.blocks.map((b:any) => Object.assign(b, {until: new Date(b.until)})),
+		blocked: data.blocked.map((b:any) => Object.assign(b, {time: new Date(b.time)}))
+	};
+}
+
+export async function clearLoginLimit(kind: string, key: string) {
+	await del('/admin/login-limits/'+kind+'/'+encodeURIComponent(key));
+}
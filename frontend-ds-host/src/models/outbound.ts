@@ -0,0 +1,23 @@
+import {get, post} from '../controllers/userapi';
+
+// Outbound hosts are declared by the app in its manifest.
+// The owner approves those the appspace can connect to.
+// approved is null if the owner never approved hosts:
+// the appspace was created before hosts were declared and can reach all declared hosts.
+
+export type AppspaceOutbound = {
+	declared: string[],
+	approved: string[] | null
+}
+
+export async function fetchAppspaceOutbound(appspace_id: number) :Promise<AppspaceOutbound> {
+	const data = await get('/appspace/'+appspace_id+'/outbound');
+	return {
+		declared: data.declared.map( (h:any) => h+'' ),
+		approved: data.approved === null ? null : data.approved.map( (h:any) => h+'' )
+	};
+}
+
+export async function setAppspaceOutbound(appspace_id: number, hosts: string[]) {
+	await post('/appspace/'+appspace_id+'/outbound', {hosts});
+}
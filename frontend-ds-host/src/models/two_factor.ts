@@ -0,0 +1,103 @@
+import {get, ax} from '../controllers/userapi';
+
+// Second factors: TOTP from an authenticator app, recovery codes and passkeys.
+
+export type Passkey = {
+	credential_id: string,
+	name: string,
+	created_dt: Date,
+	last_used_dt: Date|undefined
+}
+
+export type TwoFactorStatus = {
+	totp: boolean,
+	recovery_codes: number,
+	passkeys: Passkey[],
+	required: boolean
+}
+
+export async function fetchTwoFactorStatus() :Promise<TwoFactorStatus> {
+	const data = await get('/user/2fa/');
+	data.passkeys = data.passkeys.map((p:any) => Object.assign(p, {
+		created_dt: new Date(p.created_dt),
+		last_used_dt: p.last_used_dt ? new Date(p.last_used_dt) : undefined
+	}));
+	return data;
+}
+
+// These requests can be rejected for reasons the user can fix,
+// so 4xx responses are returned rather than thrown.
+const userErrors = {validateStatus: (s:number) => s < 500};
+
+export async function beginTOTP() :Promise<{secret:string, uri:string}> {
+	const resp = await ax.post('/api/user/2fa/totp');
+	return resp.data;
+}
+
+// confirmTOTP returns new recovery codes, or an error message
+export async function confirmTOTP(code:string) :Promise<{codes?:string[], error?:string}> {
+	const resp = await ax.post('/api/user/2fa/totp/confirm', {code}, userErrors);
+	if( resp.status !== 200 ) return {error: 'Code incorrect. Please try again.'};
+	return {codes: resp.data.recovery_codes || []};
+}
+
+// disableTOTP returns an error message if TOTP can not be turned off
+export async function disableTOTP() :Promise<string> {
+	const resp = await ax.delete('/api/user/2fa/totp', userErrors);
+	if( resp.status === 409 ) return 'Two-factor authentication is required. Add a passkey before turning this off.';
+	return '';
+}
+
+export async function newRecoveryCodes() :Promise<string[]> {
+	const resp = await ax.post('/api/user/2fa/recovery-codes');
+	return resp.data.recovery_codes;
+}
+
+function b64urlToBuf(s:string) :ArrayBuffer {
+	const b64 = s.replace(/-/g, '+').replace(/_/g, '/');
+	return Uint8Array.from(atob(b64), c => c.charCodeAt(0)).buffer;
+}
+function bufToB64url(buf:ArrayBuffer) :string {
+	const s = String.fromCharCode(...new Uint8Array(buf));
+	return btoa(s).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+}
+
+export function passkeysSupported() :boolean {
+	return !!window.PublicKeyCredential;
+}
+
+// addPasskey has the browser create a passkey and registers it.
+// It returns an error message if that did not work.
+export async function addPasskey(name:string) :Promise<string> {
+	const opts = (await ax.post('/api/user/2fa/passkey/options')).data;
+	opts.challenge = b64urlToBuf(opts.challenge);
+	opts.user.id = b64urlToBuf(opts.user.id);
+	opts.excludeCredentials = opts.excludeCredentials.map((c:any) => Object.assign(c, {id: b64urlToBuf(c.id)}));
+
+	let cred :PublicKeyCredential;
+	try {
+		cred = <PublicKeyCredential>await navigator.credentials.create({publicKey: opts});
+	}
+	catch(e:any) {
+		if( e.name === 'InvalidStateError' ) return 'This passkey is already registered.';
+		return 'Passkey was not created.';
+	}
+	const r = <AuthenticatorAttestationResponse>cred.response;
+	const credential = {
+		id: cred.id,
+		response: {
+			clientDataJSON: bufToB64url(r.clientDataJSON),
+			attestationObject: bufToB64url(r.attestationObject)
+		}
+	};
+	const resp = await ax.post('/api/user/2fa/passkey', {name, credential}, userErrors);
+	if( resp.status !== 200 ) return 'Passkey was not accepted.';
+	return '';
+}
+
+// deletePasskey returns an error message if the passkey can not be deleted
+export async function deletePasskey(credential_id:string) :Promise<string> {
+	const resp = await ax.delete('/api/user/2fa/passkey/'+encodeURIComponent(credential_id), userErrors);
+	if( resp.status === 409 ) return 'Two-factor authentication is required. This is your last second factor.';
+	return '';
+}
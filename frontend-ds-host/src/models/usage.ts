@@ -8,7 +8,10 @@ export type SandboxSums = {
     cpu_usec: number,
     memory_byte_sec: number,
 	io_bytes: number,
-	io_ops: number
+	io_ops: number,
+	out_requests: number,
+	out_bytes_sent: number,
+	out_bytes_received: number
 }
 
 export async function fetchAppspaceSummary(appspace_id: number) :Promise<SandboxSums> {
@@ -9,12 +9,14 @@ export const useInstanceSettingsStore = defineStore('admin-instance-settings', (
 	const is_loaded = computed( () => load_state.value === LoadState.Loaded );
 
 	const registration_open = ref(false);
+	const require_2fa = ref(false);
 
 	const tsnet_data :Ref<TSNetData|undefined> = ref();
 
 	async function loadData() {
 		const resp_data = await ax.get('/api/admin/settings');
 		registration_open.value = !!(resp_data.data as any).registration_open;
+		require_2fa.value = !!(resp_data.data as any).require_2fa;
 		tsnet_data.value = tsnetDataFromRaw(resp_data.data.tsnet);
 		load_state.value = LoadState.Loaded;
 	}
@@ -24,6 +26,11 @@ export const useInstanceSettingsStore = defineStore('admin-instance-settings', (
 		registration_open.value = open;
 	}
 
+	async function setRequire2FA(require:boolean) {
+		await ax.post('/api/admin/settings/require-2fa', {require});
+		require_2fa.value = require;
+	}
+
 	async function setTSNetData(data : TSNetCreateConfig) {
 		await ax.post('/api/admin/settings/tsnet', data);
 		tsnet_data.value = {
@@ -45,6 +52,7 @@ export const useInstanceSettingsStore = defineStore('admin-instance-settings', (
 	return {
 		is_loaded, loadData,
 		registration_open, setRegistrationOpen,
+		require_2fa, setRequire2FA,
 		tsnet_data, setTSNetData, setTSNetConnect, deleteTSNetData
 	};
 });
\ No newline at end of file
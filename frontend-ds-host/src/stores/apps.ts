@@ -33,7 +33,8 @@ export function appVersionUIFromRaw(raw:any) :AppVersionUI {
 		code: raw.code + '',
 		funding: raw.funding + '',
 		release_date: raw.release_date+'',
-		license: raw.license+''
+		license: raw.license+'',
+		outbound: raw.outbound ? raw.outbound.map( (h:any) => h+'' ) : []
 	}
 }
 function appFromRaw(raw:any) :App {
@@ -11,7 +11,8 @@ type NewAppspaceData = {
 	app_version:string,
 	domain_name: string,
 	subdomain: string,
-	dropid: string
+	dropid: string,
+	outbound: string[]	// outbound hosts approved by the owner
 }
 
 function appspaceStatusFromRaw(raw:any) :AppspaceStatus {
@@ -88,8 +88,9 @@ export const useAppspaceMigrationJobsStore = defineStore('appspace-migration-job
 		});
 	}
 
-	async function createMigrationJob(appspace_id:number, to_version:string) :Promise<ShallowRef<AppspaceMigrationJob>> {
-		const resp = await ax.post('/api/migration-job', {appspace_id, to_version});
+	// outbound replaces the approved outbound hosts of the appspace if set
+	async function createMigrationJob(appspace_id:number, to_version:string, outbound?:string[]) :Promise<ShallowRef<AppspaceMigrationJob>> {
+		const resp = await ax.post('/api/migration-job', {appspace_id, to_version, outbound});
 		return setReplaceJob(migrationJobFromRaw(resp.data));
 	}
 
@@ -87,7 +87,8 @@ export interface AppVersionUI {
 	code: string,
 	funding: string,
 	release_date: string,
-	license: string
+	license: string,
+	outbound: string[]	// hosts the app needs to connect to
 }
 
 export interface App {
@@ -0,0 +1,365 @@
+// Package backupcrypt encrypts backup archives to a key derived from a passphrase.
+//
+// The passphrase is stretched with argon2id into an X25519 private key.
+// Only the matching public key (the Recipient) is needed to encrypt,
+// so a host can encrypt backups without ever holding the passphrase.
+//
+// Each encrypted file uses a fresh ephemeral X25519 key.
+// The payload is split into segments sealed with ChaCha20-Poly1305,
+// with the segment counter and a last-segment flag in the nonce
+// so that reordering or truncation is detected.
+package backupcrypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// magic is the start of every encrypted file
+const magic = "DSBKENC1"
+
+const segmentSize = 64 * 1024
+
+const saltSize = 16
+
+const recipientPrefix = "dsbk1:"
+
+// ErrBadPassphrase is returned when the passphrase does not match the file's recipient
+var ErrBadPassphrase = errors.New("incorrect passphrase")
+
+// ErrNotEncrypted is returned when the data does not start with the expected header
+var ErrNotEncrypted = errors.New("data is not encrypted")
+
+// ErrCorrupt is returned when the encrypted data was modified or truncated
+var ErrCorrupt = errors.New("encrypted data is corrupt")
+
+// Params are the argon2id parameters used to derive the key from a passphrase
+type Params struct {
+	Time    uint32
+	Memory  uint32 // in KiB
+	Threads uint8
+}
+
+// DefaultParams are used for new recipients
+var DefaultParams = Params{Time: 3, Memory: 64 * 1024, Threads: 4}
+
+// valid guards against params that would make key derivation
+// use unreasonable amounts of memory or time.
+// Params are read from untrusted files on decrypt,
+// so nothing costlier than DefaultParams is accepted.
+func (p Params) valid() bool {
+	return p.Time > 0 && p.Time <= DefaultParams.Time &&
+		p.Memory >= 8 && p.Memory <= DefaultParams.Memory &&
+		p.Threads > 0 && p.Threads <= DefaultParams.Threads
+}
+
+// Recipient is the public half of a passphrase-derived key,
+// along with what is needed to derive the key again from the passphrase.
+type Recipient struct {
+	Salt      [saltSize]byte
+	Params    Params
+	PublicKey [32]byte
+}
+
+const recipientSize = saltSize + 4 + 4 + 1 + 32
+
+// NewRecipient derives a new key from the passphrase, with a random salt
+func NewRecipient(passphrase string, params Params) (Recipient, error) {
+	var r Recipient
+	_, err := rand.Read(r.Salt[:])
+	if err != nil {
+		return r, err
+	}
+	r.Params = params
+	if !params.valid() {
+		return r, errors.New("invalid key derivation parameters")
+	}
+	priv, err := r.privateKey(passphrase)
+	if err != nil {
+		return r, err
+	}
+	copy(r.PublicKey[:], priv.PublicKey().Bytes())
+	return r, nil
+}
+
+func (r Recipient) privateKey(passphrase string) (*ecdh.PrivateKey, error) {
+	p := r.Params
+	k := argon2.IDKey([]byte(passphrase), r.Salt[:], p.Time, p.Memory, p.Threads, 32)
+	return ecdh.X25519().NewPrivateKey(k)
+}
+
+// Matches returns true if the passphrase derives this recipient's key
+func (r Recipient) Matches(passphrase string) bool {
+	priv, err := r.privateKey(passphrase)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(priv.PublicKey().Bytes(), r.PublicKey[:]) == 1
+}
+
+func (r Recipient) bytes() []byte {
+	b := make([]byte, 0, recipientSize)
+	b = append(b, r.Salt[:]...)
+	b = binary.BigEndian.AppendUint32(b, r.Params.Time)
+	b = binary.BigEndian.AppendUint32(b, r.Params.Memory)
+	b = append(b, r.Params.Threads)
+	b = append(b, r.PublicKey[:]...)
+	return b
+}
+
+func recipientFromBytes(b []byte) (r Recipient) {
+	copy(r.Salt[:], b[:saltSize])
+	b = b[saltSize:]
+	r.Params.Time = binary.BigEndian.Uint32(b)
+	r.Params.Memory = binary.BigEndian.Uint32(b[4:])
+	r.Params.Threads = b[8]
+	copy(r.PublicKey[:], b[9:])
+	return
+}
+
+// String encodes the recipient so it can be stored
+func (r Recipient) String() string {
+	return recipientPrefix + base64.RawURLEncoding.EncodeToString(r.bytes())
+}
+
+// ParseRecipient decodes a recipient created with String
+func ParseRecipient(s string) (Recipient, error) {
+	if !strings.HasPrefix(s, recipientPrefix) {
+		return Recipient{}, errors.New("invalid recipient")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, recipientPrefix))
+	if err != nil || len(b) != recipientSize {
+		return Recipient{}, errors.New("invalid recipient")
+	}
+	r := recipientFromBytes(b)
+	if !r.Params.valid() {
+		return Recipient{}, errors.New("invalid recipient")
+	}
+	return r, nil
+}
+
+// IsEncrypted returns true if the header looks like the start of an encrypted file.
+// Pass at least the first 8 bytes of the file.
+func IsEncrypted(header []byte) bool {
+	return bytes.HasPrefix(header, []byte(magic))
+}
+
+// header: magic | recipient | ephemeral public key
+const headerSize = len(magic) + recipientSize + 32
+
+func fileKey(header []byte, shared []byte) ([]byte, error) {
+	return hkdf.Key(sha256.New, shared, header, "dropserver backup", chacha20poly1305.KeySize)
+}
+
+// Encrypt returns a writer that encrypts to w.
+// Close must be called to write the final segment.
+func Encrypt(w io.Writer, r Recipient) (io.WriteCloser, error) {
+	recipientKey, err := ecdh.X25519().NewPublicKey(r.PublicKey[:])
+	if err != nil {
+		return nil, err
+	}
+	eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := eph.ECDH(recipientKey)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, headerSize)
+	header = append(header, magic...)
+	header = append(header, r.bytes()...)
+	header = append(header, eph.PublicKey().Bytes()...)
+
+	key, err := fileKey(header, shared)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(header)
+	if err != nil {
+		return nil, err
+	}
+	return &encWriter{w: w, s: segmenter{aead: aead}, buf: make([]byte, 0, segmentSize)}, nil
+}
+
+// Decrypt returns a reader of the decrypted contents of r.
+// It returns ErrNotEncrypted or ErrBadPassphrase before reading any payload.
+// Reads return ErrCorrupt if the data has been tampered with.
+func Decrypt(r io.Reader, passphrase string) (io.Reader, error) {
+	header := make([]byte, headerSize)
+	_, err := io.ReadFull(r, header)
+	if err == io.EOF || err == io.ErrUnexpectedEOF || (err == nil && !IsEncrypted(header)) {
+		return nil, ErrNotEncrypted
+	}
+	if err != nil {
+		return nil, err
+	}
+	recipient := recipientFromBytes(header[len(magic) : len(magic)+recipientSize])
+	if !recipient.Params.valid() {
+		return nil, ErrCorrupt
+	}
+	priv, err := recipient.privateKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(priv.PublicKey().Bytes(), recipient.PublicKey[:]) != 1 {
+		return nil, ErrBadPassphrase
+	}
+	ephKey, err := ecdh.X25519().NewPublicKey(header[len(magic)+recipientSize:])
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	shared, err := priv.ECDH(ephKey)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	key, err := fileKey(header, shared)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return &decReader{r: bufio.NewReader(r), s: segmenter{aead: aead}, buf: make([]byte, segmentSize+aead.Overhead())}, nil
+}
+
+// ReadRecipient reads the header of an encrypted file and returns its recipient
+func ReadRecipient(r io.Reader) (Recipient, error) {
+	header := make([]byte, headerSize)
+	_, err := io.ReadFull(r, header)
+	if err != nil || !IsEncrypted(header) {
+		return Recipient{}, ErrNotEncrypted
+	}
+	return recipientFromBytes(header[len(magic) : len(magic)+recipientSize]), nil
+}
+
+type segmenter struct {
+	aead    cipher.AEAD
+	counter uint64
+	done    bool
+}
+
+// nonce is the 11-byte big-endian counter followed by the last-segment flag
+func (s *segmenter) nonce(last bool) []byte {
+	n := make([]byte, chacha20poly1305.NonceSize)
+	binary.BigEndian.PutUint64(n[3:11], s.counter)
+	if last {
+		n[11] = 1
+	}
+	return n
+}
+
+type encWriter struct {
+	w   io.Writer
+	s   segmenter
+	buf []byte
+	err error
+}
+
+func (e *encWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n := 0
+	for len(p) > 0 {
+		// only seal a full buffer once more data arrives,
+		// because the last segment has to be flagged as such.
+		if len(e.buf) == segmentSize {
+			e.err = e.flush(false)
+			if e.err != nil {
+				return n, e.err
+			}
+		}
+		c := copy(e.buf[len(e.buf):segmentSize], p)
+		e.buf = e.buf[:len(e.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+func (e *encWriter) flush(last bool) error {
+	out := e.s.aead.Seal(nil, e.s.nonce(last), e.buf, nil)
+	e.s.counter++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(out)
+	return err
+}
+
+// Close writes the last segment. It does not close the underlying writer.
+func (e *encWriter) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	e.err = e.flush(true)
+	if e.err != nil {
+		return e.err
+	}
+	e.err = errors.New("write to closed encrypter")
+	return nil
+}
+
+type decReader struct {
+	r   *bufio.Reader
+	s   segmenter
+	buf []byte
+	out []byte
+}
+
+func (d *decReader) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.s.done {
+			return 0, io.EOF
+		}
+		err := d.readSegment()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
+
+func (d *decReader) readSegment() error {
+	n, err := io.ReadFull(d.r, d.buf)
+	if err == io.EOF {
+		return ErrCorrupt // missing last segment
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	last := err == io.ErrUnexpectedEOF
+	if !last {
+		_, err = d.r.Peek(1)
+		last = err == io.EOF
+	}
+	out, err := d.s.aead.Open(d.buf[:0], d.s.nonce(last), d.buf[:n], nil)
+	if err != nil {
+		return ErrCorrupt
+	}
+	d.s.counter++
+	d.s.done = last
+	d.out = out
+	return nil
+}
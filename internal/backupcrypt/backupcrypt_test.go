@@ -0,0 +1,152 @@
+package backupcrypt
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// testParams keep key derivation fast in tests
+var testParams = Params{Time: 1, Memory: 64, Threads: 1}
+
+func TestRoundTrip(t *testing.T) {
+	r, err := NewRecipient("correct horse", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes := []int{0, 1, segmentSize - 1, segmentSize, segmentSize + 1, 3*segmentSize + 17}
+	for _, size := range sizes {
+		data := bytes.Repeat([]byte{'x', 'y', 'z'}, size/3+1)[:size]
+		enc := encrypt(t, r, data)
+		if !IsEncrypted(enc) {
+			t.Error("expected encrypted header")
+		}
+		dr, err := Decrypt(bytes.NewReader(enc), "correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec, err := io.ReadAll(dr)
+		if err != nil {
+			t.Fatalf("size %v: %v", size, err)
+		}
+		if !bytes.Equal(dec, data) {
+			t.Errorf("size %v: decrypted data does not match", size)
+		}
+	}
+}
+
+func TestBadPassphrase(t *testing.T) {
+	r, _ := NewRecipient("correct horse", testParams)
+	if !r.Matches("correct horse") || r.Matches("wrong horse") {
+		t.Error("Matches gave unexpected result")
+	}
+	enc := encrypt(t, r, []byte("secret"))
+	_, err := Decrypt(bytes.NewReader(enc), "wrong horse")
+	if err != ErrBadPassphrase {
+		t.Errorf("expected ErrBadPassphrase, got %v", err)
+	}
+}
+
+func TestNotEncrypted(t *testing.T) {
+	_, err := Decrypt(bytes.NewReader([]byte("PK\x03\x04 this is a zip file honest. It needs to be long enough for a header....")), "abc")
+	if err != ErrNotEncrypted {
+		t.Errorf("expected ErrNotEncrypted, got %v", err)
+	}
+	if IsEncrypted([]byte("PK\x03\x04")) {
+		t.Error("zip header should not be detected as encrypted")
+	}
+}
+
+func TestTampered(t *testing.T) {
+	r, _ := NewRecipient("correct horse", testParams)
+	data := bytes.Repeat([]byte("a"), 2*segmentSize+10)
+	enc := encrypt(t, r, data)
+
+	cases := map[string][]byte{
+		"flipped bit":         flip(enc, len(enc)-5),
+		"truncated segment":   enc[:len(enc)-3],
+		"dropped last":        enc[:headerSize+2*(segmentSize+16)],
+		"swapped header pub":  flip(enc, headerSize-1),
+		"appended data":       append(append([]byte{}, enc...), 1, 2, 3),
+		"dropped whole body":  enc[:headerSize],
+		"flipped first block": flip(enc, headerSize+3),
+	}
+	for desc, c := range cases {
+		dr, err := Decrypt(bytes.NewReader(c), "correct horse")
+		if err == nil {
+			_, err = io.ReadAll(dr)
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", desc)
+		}
+	}
+}
+
+func TestCostlyParams(t *testing.T) {
+	r, _ := NewRecipient("correct horse", testParams)
+	r.Params = Params{Time: 16, Memory: 1024 * 1024, Threads: 1}
+	enc := encrypt(t, r, []byte("secret"))
+	_, err := Decrypt(bytes.NewReader(enc), "correct horse")
+	if err != ErrCorrupt {
+		t.Errorf("expected ErrCorrupt, got %v", err)
+	}
+	_, err = NewRecipient("correct horse", Params{Time: 4, Memory: 64, Threads: 1})
+	if err == nil {
+		t.Error("expected params above the defaults to be refused")
+	}
+}
+
+func TestRecipientString(t *testing.T) {
+	r, _ := NewRecipient("correct horse", testParams)
+	r2, err := ParseRecipient(r.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r2 != r {
+		t.Error("recipients differ")
+	}
+	enc := encrypt(t, r, []byte("hello"))
+	r3, err := ReadRecipient(bytes.NewReader(enc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r3 != r {
+		t.Error("recipient from header differs")
+	}
+
+	_, err = ParseRecipient("dsbk1:abc")
+	if err == nil {
+		t.Error("expected error")
+	}
+}
+
+func encrypt(t *testing.T, r Recipient, data []byte) []byte {
+	var buf bytes.Buffer
+	w, err := Encrypt(&buf, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// write in odd-sized pieces
+	for len(data) > 0 {
+		n := 1000
+		if n > len(data) {
+			n = len(data)
+		}
+		_, err = w.Write(data[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func flip(b []byte, i int) []byte {
+	c := append([]byte{}, b...)
+	c[i] ^= 1
+	return c
+}
@@ -0,0 +1,129 @@
+// Package objstore provides simple key-value blob stores
+// used as off-host destinations for backups.
+package objstore
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrNotFound is returned when a key does not exist in the store
+var ErrNotFound = errors.New("object not found")
+
+// Store is a blob store. Keys are slash-separated paths.
+type Store interface {
+	Put(key string, r io.Reader, size int64) error
+	Get(key string) (io.ReadCloser, error)
+	Exists(key string) (bool, error)
+	// List returns all keys that start with prefix, sorted.
+	List(prefix string) ([]string, error)
+	Delete(key string) error
+}
+
+// Dir is a store in a local directory.
+// The directory can be a mounted network file system (NFS, sshfs, ...)
+type Dir struct {
+	Path string
+}
+
+func (d Dir) keyPath(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || clean != "/"+key {
+		return "", errors.New("invalid key: " + key)
+	}
+	return filepath.Join(d.Path, filepath.FromSlash(clean)), nil
+}
+
+// Put writes the object atomically
+func (d Dir) Put(key string, r io.Reader, size int64) error {
+	p, err := d.keyPath(key)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(p), 0755)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after successful rename
+	_, err = io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (d Dir) Get(key string) (io.ReadCloser, error) {
+	p, err := d.keyPath(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (d Dir) Exists(key string) (bool, error) {
+	p, err := d.keyPath(key)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(p)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (d Dir) List(prefix string) ([]string, error) {
+	keys := []string{}
+	err := filepath.WalkDir(d.Path, func(p string, e os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == d.Path {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(d.Path, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
+
+func (d Dir) Delete(key string) error {
+	p, err := d.keyPath(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
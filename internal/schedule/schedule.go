@@ -0,0 +1,160 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron-like schedule
+// Fields are bit sets of allowed values.
+type Schedule struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	// domStar and dowStar record whether the day fields start with "*" (like "*" or "*/2")
+	// Like cron, if both are restricted a day matches if either matches.
+	domStar bool
+	dowStar bool
+}
+
+// Aliases for common schedules. They run at a quiet time of night.
+var aliases = map[string]string{
+	"daily":   "0 3 * * *",
+	"weekly":  "0 3 * * 0",
+	"monthly": "0 3 1 * *",
+}
+
+type fieldBounds struct {
+	name string
+	min  int
+	max  int
+}
+
+var bounds = []fieldBounds{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Parse parses a schedule string.
+// It accepts "daily", "weekly", "monthly", or a 5-field cron expression:
+// minute hour day-of-month month day-of-week
+// Each field can be "*", a number, a range "a-b", a step "*/n" or "a-b/n",
+// or a comma-separated list of these.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(strings.ToLower(spec))
+	if a, ok := aliases[spec]; ok {
+		spec = a
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return Schedule{}, errors.New("schedule must be daily, weekly, monthly or have 5 fields")
+	}
+
+	var sets [5]uint64
+	for i, f := range fields {
+		set, err := parseField(f, bounds[i])
+		if err != nil {
+			return Schedule{}, err
+		}
+		sets[i] = set
+	}
+
+	// Sunday can be 0 or 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+		sets[4] &^= 1 << 7
+	}
+
+	return Schedule{
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     sets[4],
+		domStar: strings.HasPrefix(fields[2], "*"),
+		dowStar: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+func parseField(field string, b fieldBounds) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i != -1 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s < 1 {
+				return 0, fmt.Errorf("invalid step in %s field: %s", b.name, part)
+			}
+			step = s
+			part = part[:i]
+		}
+		lo, hi := b.min, b.max
+		if part != "*" {
+			var err error
+			if i := strings.Index(part, "-"); i != -1 {
+				lo, err = strconv.Atoi(part[:i])
+				if err == nil {
+					hi, err = strconv.Atoi(part[i+1:])
+				}
+			} else {
+				lo, err = strconv.Atoi(part)
+				hi = lo
+			}
+			if err != nil {
+				return 0, fmt.Errorf("invalid value in %s field: %s", b.name, part)
+			}
+		}
+		if lo < b.min || hi > b.max || lo > hi {
+			return 0, fmt.Errorf("out of range value in %s field: %s", b.name, part)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// Next returns the first time strictly after t that matches the schedule.
+// Seconds are truncated. The location of t is used to evaluate the schedule.
+// If no time matches within five years a zero time is returned.
+func (s Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,76 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		spec string
+		er   bool
+	}{
+		{"daily", false},
+		{"Weekly", false},
+		{"monthly", false},
+		{"* * * * *", false},
+		{"30 2 * * 1-5", false},
+		{"*/15 0-6/2 1,15 * 7", false},
+		{"", true},
+		{"hourly", true},
+		{"* * * *", true},
+		{"60 * * * *", true},
+		{"* 24 * * *", true},
+		{"* * 0 * *", true},
+		{"* * * 13 *", true},
+		{"* * * * 8", true},
+		{"5-1 * * * *", true},
+		{"*/0 * * * *", true},
+		{"a * * * *", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.spec, func(t *testing.T) {
+			_, err := Parse(c.spec)
+			if err != nil && !c.er {
+				t.Error(err)
+			} else if err == nil && c.er {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	// 2024-03-13 is a Wednesday
+	from := time.Date(2024, 3, 13, 10, 20, 30, 0, time.UTC)
+	cases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"daily", time.Date(2024, 3, 14, 3, 0, 0, 0, time.UTC)},
+		{"weekly", time.Date(2024, 3, 17, 3, 0, 0, 0, time.UTC)},
+		{"monthly", time.Date(2024, 4, 1, 3, 0, 0, 0, time.UTC)},
+		{"* * * * *", time.Date(2024, 3, 13, 10, 21, 0, 0, time.UTC)},
+		{"20 10 * * *", time.Date(2024, 3, 14, 10, 20, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 3, 13, 10, 30, 0, 0, time.UTC)},
+		{"0 4 * * 7", time.Date(2024, 3, 17, 4, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 5", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)},   // dom or dow
+		{"0 0 */2 * 1", time.Date(2024, 3, 25, 0, 0, 0, 0, time.UTC)}, // starred dom: odd days that are mondays
+		{"0 0 31 2 *", time.Time{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.spec, func(t *testing.T) {
+			s, err := Parse(c.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next := s.Next(from)
+			if !next.Equal(c.next) {
+				t.Errorf("expected %v, got %v", c.next, next)
+			}
+		})
+	}
+}
@@ -0,0 +1,531 @@
+// Package snapshot implements content-addressed, deduplicated
+// snapshots of a directory tree.
+// Files are split into fixed-size chunks that are stored once
+// in a chunk store, keyed by their sha256 hash.
+// Each snapshot is a small JSON manifest listing the chunks of each file.
+package snapshot
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+// ChunkSize is the size of file chunks.
+// Fixed-size chunks dedupe unchanged files and in-place
+// modifications (like sqlite pages) well.
+const ChunkSize = 1 << 20
+
+// manifestVersion is bumped if the manifest format changes
+const manifestVersion = 1
+
+// gcGrace is the minimum age of an unreferenced chunk before it is removed.
+// It protects chunks written or reused by a snapshot in progress.
+const gcGrace = time.Hour
+
+// ErrBadChunk is returned when a chunk does not match its hash
+// or the hash itself is malformed
+var ErrBadChunk = errors.New("chunk content does not match hash")
+
+var hashRegexp = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// validHash is true if hash is a lowercase hex sha256.
+// Hashes become file names in the store so nothing else can be let through.
+func validHash(hash string) bool {
+	return hashRegexp.MatchString(hash)
+}
+
+// Manifest describes a snapshot
+type Manifest struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	Dirs    []string  `json:"dirs"`
+	Files   []File    `json:"files"`
+}
+
+// File is a file in the snapshot. Path is slash-separated and relative.
+type File struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Chunks  []string  `json:"chunks"`
+}
+
+// Size returns the total size of the files in the snapshot
+func (m Manifest) Size() (size int64) {
+	for _, f := range m.Files {
+		size += f.Size
+	}
+	return
+}
+
+// ChunkHashes returns the distinct chunks referenced by the snapshot
+func (m Manifest) ChunkHashes() []string {
+	seen := make(map[string]struct{})
+	hashes := []string{}
+	for _, f := range m.Files {
+		for _, c := range f.Chunks {
+			if _, ok := seen[c]; !ok {
+				seen[c] = struct{}{}
+				hashes = append(hashes, c)
+			}
+		}
+	}
+	return hashes
+}
+
+// Validate checks that every chunk hash is well-formed
+// and every path stays within the snapshot's directory.
+// Manifests that come from elsewhere must be validated before they are used.
+func (m Manifest) Validate() error {
+	for _, d := range m.Dirs {
+		if !validPath(d) {
+			return fmt.Errorf("illegal dir path: %s", d)
+		}
+	}
+	for _, f := range m.Files {
+		if !validPath(f.Path) {
+			return fmt.Errorf("illegal file path: %s", f.Path)
+		}
+		for _, c := range f.Chunks {
+			if !validHash(c) {
+				return ErrBadChunk
+			}
+		}
+	}
+	return nil
+}
+
+// validPath is true if p is a clean relative slash-separated path
+// that can not escape the directory it is joined to.
+func validPath(p string) bool {
+	if p == "" || path.IsAbs(p) {
+		return false
+	}
+	c := path.Clean(p)
+	return c == p && c != "." && c != ".." && !strings.HasPrefix(c, "../")
+}
+
+// Store is a directory of chunks
+type Store struct {
+	Dir string
+}
+
+// Create stores the contents of srcDir and returns its manifest.
+// Chunks already present in the store are not written again.
+func (s Store) Create(srcDir string) (Manifest, error) {
+	m := Manifest{
+		Version: manifestVersion,
+		Created: time.Now(),
+		Dirs:    []string{},
+		Files:   []File{}}
+
+	buf := make([]byte, ChunkSize)
+	err := filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		relPath = filepath.ToSlash(relPath)
+		if d.IsDir() {
+			m.Dirs = append(m.Dirs, relPath)
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil // skip symlinks and other special files
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		chunks, size, err := s.storeFile(path, buf)
+		if err != nil {
+			return err
+		}
+		m.Files = append(m.Files, File{
+			Path:    relPath,
+			Size:    size,
+			ModTime: info.ModTime(),
+			Chunks:  chunks})
+		return nil
+	})
+	if err != nil {
+		return Manifest{}, err
+	}
+	return m, nil
+}
+
+func (s Store) storeFile(path string, buf []byte) ([]string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	chunks := []string{}
+	size := int64(0)
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			hash, err := s.putChunk(buf[:n])
+			if err != nil {
+				return nil, 0, err
+			}
+			chunks = append(chunks, hash)
+			size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return chunks, size, nil
+}
+
+func (s Store) chunkPath(hash string) string {
+	return filepath.Join(s.Dir, hash[:2], hash)
+}
+
+// putChunk writes the chunk if it is not already in the store.
+// Existing chunks are touched so that a concurrent GC leaves them alone.
+func (s Store) putChunk(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	p := s.chunkPath(hash)
+
+	now := time.Now()
+	err := os.Chtimes(p, now, now)
+	if err == nil {
+		return hash, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	err = os.MkdirAll(filepath.Dir(p), 0755)
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), "tmp-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name()) // no-op after successful rename
+	gz := gzip.NewWriter(tmp)
+	_, err = gz.Write(data)
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err != nil {
+		return "", err
+	}
+	err = os.Rename(tmp.Name(), p)
+	if err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// copyChunk verifies the chunk's hash then writes its data to w.
+// Nothing is written if the chunk is bad.
+func (s Store) copyChunk(w io.Writer, hash string) (int64, error) {
+	if !validHash(hash) {
+		return 0, ErrBadChunk
+	}
+	f, err := os.Open(s.chunkPath(hash))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return 0, ErrBadChunk
+	}
+	data, err := io.ReadAll(io.LimitReader(gz, ChunkSize+1))
+	if err != nil {
+		return 0, ErrBadChunk
+	}
+	sum := sha256.Sum256(data)
+	if len(data) > ChunkSize || hex.EncodeToString(sum[:]) != hash {
+		return 0, ErrBadChunk
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// HasChunk returns true if the chunk is in the store
+func (s Store) HasChunk(hash string) bool {
+	if !validHash(hash) {
+		return false
+	}
+	_, err := os.Stat(s.chunkPath(hash))
+	return err == nil
+}
+
+// OpenChunk opens the stored (compressed) chunk file.
+// It's used to copy chunks to another store as-is.
+func (s Store) OpenChunk(hash string) (*os.File, int64, error) {
+	if !validHash(hash) {
+		return nil, 0, ErrBadChunk
+	}
+	f, err := os.Open(s.chunkPath(hash))
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
+
+// ImportChunk adds a compressed chunk as read from another store's OpenChunk.
+// The content is verified against the hash before it is added.
+func (s Store) ImportChunk(hash string, r io.Reader) error {
+	if !validHash(hash) {
+		return ErrBadChunk
+	}
+	p := s.chunkPath(hash)
+	err := os.MkdirAll(filepath.Dir(p), 0755)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), "tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after successful rename
+	_, err = io.Copy(tmp, r)
+	tmp.Close()
+	if err != nil {
+		return err
+	}
+	err = verifyChunkFile(tmp.Name(), hash)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func verifyChunkFile(p string, hash string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return ErrBadChunk
+	}
+	h := sha256.New()
+	_, err = io.Copy(h, gz)
+	if err != nil {
+		return ErrBadChunk
+	}
+	if hex.EncodeToString(h.Sum(nil)) != hash {
+		return ErrBadChunk
+	}
+	return nil
+}
+
+// Restore recreates the snapshot's files in destDir.
+// It returns domain.ErrStorageExceeded if the files exceed maxSize.
+func (s Store) Restore(m Manifest, destDir string, maxSize int64) error {
+	err := m.Validate()
+	if err != nil {
+		return err
+	}
+	if m.Size() > maxSize {
+		return domain.ErrStorageExceeded
+	}
+	destDir = filepath.Clean(destDir)
+	err = os.MkdirAll(destDir, 0755)
+	if err != nil {
+		return err
+	}
+	for _, d := range m.Dirs {
+		p, err := safeJoin(destDir, d)
+		if err != nil {
+			return err
+		}
+		err = os.MkdirAll(p, 0755)
+		if err != nil {
+			return err
+		}
+	}
+	for _, f := range m.Files {
+		err = s.restoreFile(f, destDir)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s Store) restoreFile(f File, destDir string) error {
+	p, err := safeJoin(destDir, f.Path)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(p), 0755)
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	for _, c := range f.Chunks {
+		_, err = s.copyChunk(out, c)
+		if err != nil {
+			out.Close()
+			return err
+		}
+	}
+	err = out.Close()
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(p, f.ModTime, f.ModTime)
+}
+
+// WriteZip writes the snapshot as a zip archive,
+// in the same layout as a zip backup of the directory.
+func (s Store) WriteZip(m Manifest, w io.Writer) error {
+	err := m.Validate()
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(w)
+	for _, d := range m.Dirs {
+		_, err := zw.Create(d + "/")
+		if err != nil {
+			return err
+		}
+	}
+	for _, f := range m.Files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     f.Path,
+			Method:   zip.Deflate,
+			Modified: f.ModTime})
+		if err != nil {
+			return err
+		}
+		for _, c := range f.Chunks {
+			_, err = s.copyChunk(fw, c)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return zw.Close()
+}
+
+// GC removes chunks that are not referenced by any of the manifests
+// and that have not been written or used recently.
+func (s Store) GC(manifests []Manifest) (removed int, err error) {
+	used := make(map[string]struct{})
+	for _, m := range manifests {
+		for _, f := range m.Files {
+			for _, c := range f.Chunks {
+				used[c] = struct{}{}
+			}
+		}
+	}
+	cutoff := time.Now().Add(-gcGrace)
+	err = filepath.WalkDir(s.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == s.Dir {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if _, ok := used[d.Name()]; ok {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(cutoff) {
+			return nil
+		}
+		err = os.Remove(path)
+		if err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return
+}
+
+// WriteManifest writes the manifest to path
+func WriteManifest(m Manifest, path string) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ReadManifest reads a manifest from path
+func ReadManifest(path string) (Manifest, error) {
+	var m Manifest
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		return m, err
+	}
+	if m.Version != manifestVersion {
+		return m, fmt.Errorf("unsupported snapshot manifest version: %d", m.Version)
+	}
+	err = m.Validate()
+	if err != nil {
+		return Manifest{}, err
+	}
+	return m, nil
+}
+
+// safeJoin joins a manifest path to dir and ensures it stays within dir
+func safeJoin(dir, p string) (string, error) {
+	joined := filepath.Join(dir, filepath.FromSlash(p))
+	if !strings.HasPrefix(joined, dir+string(os.PathSeparator)) {
+		return "", fmt.Errorf("illegal file path: %s", p)
+	}
+	return joined, nil
+}
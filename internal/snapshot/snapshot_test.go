@@ -0,0 +1,342 @@
+package snapshot
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+)
+
+func TestCreateRestore(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "src")
+	store := Store{Dir: filepath.Join(dir, "chunks")}
+
+	big := bytes.Repeat([]byte("abcdefgh"), ChunkSize/4) // two identical chunks
+	files := map[string][]byte{
+		"hello.txt":           []byte("Hello World!"),
+		"dir1/dir2/file2.txt": []byte("Hello again!"),
+		"big.bin":             big,
+		"empty.txt":           {},
+	}
+	writeFiles(t, srcDir, files)
+	err := os.MkdirAll(filepath.Join(srcDir, "empty/dir"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := store.Create(srcDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Size() != int64(len(big)+24) {
+		t.Errorf("unexpected size %v", m.Size())
+	}
+	// hello, file2, one chunk for big.bin
+	if c := countChunks(t, store); c != 3 {
+		t.Errorf("expected 3 chunks, got %v", c)
+	}
+
+	manifestFile := filepath.Join(dir, "test.snapshot")
+	err = WriteManifest(m, manifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = ReadManifest(manifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	destDir := filepath.Join(dir, "dest")
+	err = store.Restore(m, destDir, 10<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, content := range files {
+		data, err := os.ReadFile(filepath.Join(destDir, p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, content) {
+			t.Errorf("content mismatch for %v", p)
+		}
+	}
+	_, err = os.Stat(filepath.Join(destDir, "empty/dir"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = store.Restore(m, filepath.Join(dir, "dest2"), 100)
+	if err != domain.ErrStorageExceeded {
+		t.Errorf("expected storage exceeded, got %v", err)
+	}
+}
+
+func TestCreateDedupe(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "src")
+	store := Store{Dir: filepath.Join(dir, "chunks")}
+
+	writeFiles(t, srcDir, map[string][]byte{
+		"a.txt": []byte("aaa"),
+		"b.txt": []byte("bbb"),
+	})
+	_, err := store.Create(srcDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, srcDir, map[string][]byte{
+		"b.txt": []byte("bbbb"),
+	})
+	_, err = store.Create(srcDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := countChunks(t, store); c != 3 {
+		t.Errorf("expected 3 chunks, got %v", c)
+	}
+}
+
+func TestBadChunk(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "src")
+	store := Store{Dir: filepath.Join(dir, "chunks")}
+
+	writeFiles(t, srcDir, map[string][]byte{"a.txt": []byte("aaa")})
+	m, err := store.Create(srcDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// overwrite the chunk with another chunk's content
+	other, err := store.putChunk([]byte("zzz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(store.chunkPath(other))
+	os.WriteFile(store.chunkPath(m.Files[0].Chunks[0]), data, 0644)
+
+	err = store.Restore(m, filepath.Join(dir, "dest"), 1000)
+	if err != ErrBadChunk {
+		t.Errorf("expected ErrBadChunk, got %v", err)
+	}
+}
+
+func TestImportChunk(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "src")
+	store := Store{Dir: filepath.Join(dir, "chunks")}
+	other := Store{Dir: filepath.Join(dir, "other")}
+
+	writeFiles(t, srcDir, map[string][]byte{"a.txt": []byte("aaa"), "b.txt": []byte("bbb")})
+	m, err := store.Create(srcDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashes := m.ChunkHashes()
+	if len(hashes) != 2 {
+		t.Fatalf("expected 2 chunk hashes, got %v", hashes)
+	}
+	for _, h := range hashes {
+		if other.HasChunk(h) {
+			t.Error("expected chunk to be missing")
+		}
+		f, _, err := store.OpenChunk(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = other.ImportChunk(h, f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !other.HasChunk(h) {
+			t.Error("expected chunk to be imported")
+		}
+	}
+
+	// importing under the wrong hash fails
+	f, _, _ := store.OpenChunk(hashes[0])
+	defer f.Close()
+	err = other.ImportChunk(hashes[1], f)
+	if err != ErrBadChunk {
+		t.Errorf("expected ErrBadChunk, got %v", err)
+	}
+
+	err = other.Restore(m, filepath.Join(dir, "dest"), 1000)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIllegalPath(t *testing.T) {
+	dir := t.TempDir()
+	store := Store{Dir: filepath.Join(dir, "chunks")}
+	m := Manifest{Files: []File{{Path: "../escape.txt"}}}
+	err := store.Restore(m, filepath.Join(dir, "dest"), 1000)
+	if err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestBadHash(t *testing.T) {
+	dir := t.TempDir()
+	store := Store{Dir: filepath.Join(dir, "chunks")}
+	bad := "../../../../../../../../../../../../../../../../../etc/passwd_xx"
+	if len(bad) != 64 {
+		t.Fatal("test hash should be 64 chars")
+	}
+	if store.HasChunk(bad) {
+		t.Error("expected no chunk")
+	}
+	if err := store.ImportChunk(bad, bytes.NewReader(nil)); err != ErrBadChunk {
+		t.Errorf("expected ErrBadChunk, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "etc")); !os.IsNotExist(err) {
+		t.Error("expected nothing created outside the store")
+	}
+	var buf bytes.Buffer
+	if _, err := store.copyChunk(&buf, bad); err != ErrBadChunk || buf.Len() != 0 {
+		t.Errorf("expected ErrBadChunk and nothing written, got %v %v", err, buf.Len())
+	}
+}
+
+func TestReadManifestValidates(t *testing.T) {
+	dir := t.TempDir()
+	cases := []Manifest{
+		{Version: manifestVersion, Files: []File{{Path: "a.txt", Chunks: []string{"../" + string(bytes.Repeat([]byte("a"), 61))}}}},
+		{Version: manifestVersion, Files: []File{{Path: "a.txt", Chunks: []string{string(bytes.Repeat([]byte("A"), 64))}}}},
+		{Version: manifestVersion, Files: []File{{Path: "../a.txt"}}},
+		{Version: manifestVersion, Files: []File{{Path: "/a.txt"}}},
+		{Version: manifestVersion, Dirs: []string{"a/../../b"}},
+	}
+	for i, m := range cases {
+		p := filepath.Join(dir, "manifest.json")
+		err := WriteManifest(m, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = ReadManifest(p)
+		if err == nil {
+			t.Errorf("case %v: expected error", i)
+		}
+	}
+}
+
+func TestWriteZip(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "src")
+	store := Store{Dir: filepath.Join(dir, "chunks")}
+
+	writeFiles(t, srcDir, map[string][]byte{
+		"dir1/hello.txt": []byte("Hello World!"),
+	})
+	m, err := store.Create(srcDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	err = store.WriteZip(m, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, f := range r.File {
+		if f.Name != "dir1/hello.txt" {
+			continue
+		}
+		found = true
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		if string(data) != "Hello World!" {
+			t.Errorf("unexpected content: %s", data)
+		}
+	}
+	if !found {
+		t.Error("file not found in zip")
+	}
+}
+
+func TestGC(t *testing.T) {
+	dir := t.TempDir()
+	srcDir := filepath.Join(dir, "src")
+	store := Store{Dir: filepath.Join(dir, "chunks")}
+
+	writeFiles(t, srcDir, map[string][]byte{"a.txt": []byte("aaa")})
+	m1, err := store.Create(srcDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, srcDir, map[string][]byte{"a.txt": []byte("bbb")})
+	m2, err := store.Create(srcDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// recent chunks are not removed
+	removed, err := store.GC([]Manifest{m2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 0 {
+		t.Errorf("expected 0 removed, got %v", removed)
+	}
+
+	old := time.Now().Add(-2 * gcGrace)
+	for _, m := range []Manifest{m1, m2} {
+		p := store.chunkPath(m.Files[0].Chunks[0])
+		os.Chtimes(p, old, old)
+	}
+	removed, err = store.GC([]Manifest{m2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Errorf("expected 1 removed, got %v", removed)
+	}
+	_, err = os.Stat(store.chunkPath(m2.Files[0].Chunks[0]))
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = Store{Dir: filepath.Join(dir, "nonexistent")}.GC(nil)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string][]byte) {
+	t.Helper()
+	for p, content := range files {
+		fullPath := filepath.Join(dir, p)
+		err := os.MkdirAll(filepath.Dir(fullPath), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(fullPath, content, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func countChunks(t *testing.T, s Store) int {
+	t.Helper()
+	count := 0
+	filepath.Walk(s.Dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			count++
+		}
+		return nil
+	})
+	return count
+}
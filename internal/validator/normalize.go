@@ -22,6 +22,11 @@ func NormalizeDomainName(domainName string) string {
 	return strings.ToLower(domainName)
 }
 
+// NormalizeOutboundHost makes outbound host patterns comparable
+func NormalizeOutboundHost(host string) string {
+	return strings.ToLower(strings.TrimSpace(host))
+}
+
 // NormalizeDropIDHandle makes handles comparable
 func NormalizeDropIDHandle(h string) string {
 	return strings.ToLower(h)
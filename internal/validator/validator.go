@@ -3,10 +3,12 @@ package validator
 import (
 	"errors"
 	"regexp"
+	"strconv"
 	"strings"
 
 	goValidator "github.com/go-playground/validator/v10"
 	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
+	"github.com/teleclimber/DropServer/internal/schedule"
 )
 
 var goVal = func() *goValidator.Validate {
@@ -56,6 +58,11 @@ func Password(pw string) error {
 	return goVal.Var(pw, "min=10")
 }
 
+// BackupPassphrase validates the passphrase used to encrypt appspace backups
+func BackupPassphrase(p string) error {
+	return goVal.Var(p, "min=10,max=1024")
+}
+
 // Email validates an email address. Assumed to be required.
 func Email(email string) error {
 	return goVal.Var(email, "required,email")
@@ -89,6 +96,21 @@ func V0AppspaceLoginRef(ref string) error {
 	return goVal.Var(ref, "min=8,max=12,alphanum")
 }
 
+// OutboundHost validates a host an app can connect to.
+// It is a domain name optionally prefixed with "*." to match any subdomain,
+// and optionally followed by a port.
+func OutboundHost(host string) error {
+	h := strings.TrimPrefix(host, "*.")
+	if i := strings.LastIndex(h, ":"); i != -1 {
+		port, err := strconv.Atoi(h[i+1:])
+		if err != nil || port < 1 || port > 65535 {
+			return errors.New("invalid port")
+		}
+		h = h[:i]
+	}
+	return DomainName(h)
+}
+
 // DBName validates an appspace DB name
 func DBName(pw string) error {
 	return goVal.Var(pw, "min=1,max=30,alphanum") // super restrictive for now
@@ -186,9 +208,10 @@ func AppspaceAvatarFilename(f string) error {
 	return nil
 }
 
-var validBackupFile = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}_[0-9]{4}(?:_[1-9])?\.zip$`)
+var validBackupFile = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}_[0-9]{4}(?:_[1-9])?(?:_auto)?\.(?:zip|snapshot|zip\.enc)$`)
 
-// AppspaceBackupFile validates names of appspace backup files (sans .zip extension)
+// AppspaceBackupFile validates names of appspace backup files,
+// which are either zip files or snapshot manifests
 func AppspaceBackupFile(b string) error {
 	if !validBackupFile.MatchString(b) {
 		return errors.New("invalid format for appspace backup file name")
@@ -196,5 +219,15 @@ func AppspaceBackupFile(b string) error {
 	return nil
 }
 
+// BackupSchedule validates an appspace backup schedule
+// and the number of backups kept by its retention policy
+func BackupSchedule(s domain.BackupSchedule) error {
+	_, err := schedule.Parse(s.Schedule)
+	if err != nil {
+		return err
+	}
+	return goVal.Struct(s)
+}
+
 // it might be easier to force all inputs into structs, and set the validations as tags on structs.
 // Reason: "email" validation here implies email is required. But that is not properlynormalized.
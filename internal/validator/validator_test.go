@@ -2,6 +2,8 @@ package validator
 
 import (
 	"testing"
+
+	"github.com/teleclimber/DropServer/cmd/ds-host/domain"
 )
 
 func TestValidateAlphaNumDashRegex(t *testing.T) {
@@ -238,6 +240,35 @@ func TestDomainName(t *testing.T) {
 	}
 }
 
+func TestOutboundHost(t *testing.T) {
+	cases := []struct {
+		host string
+		err  bool
+	}{
+		{"api.example.com", false},
+		{"*.example.com", false},
+		{"api.example.com:8443", false},
+		{"*.example.com:443", false},
+		{"example", true},
+		{"*example.com", true},
+		{"api.*.example.com", true},
+		{"api.example.com:", true},
+		{"api.example.com:99999", true},
+		{"https://api.example.com", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.host, func(t *testing.T) {
+			err := OutboundHost(c.host)
+			if !c.err && err != nil {
+				t.Error("should not have gotten error", err)
+			} else if c.err && err == nil {
+				t.Error("should have gotten error")
+			}
+		})
+	}
+}
+
 func TestLocationKey(t *testing.T) {
 	cases := []struct {
 		loc string
@@ -366,6 +397,15 @@ func TestAppspaceBackupFile(t *testing.T) {
 	}{
 		{"1234-56-78_1234.zip", false},
 		{"1234-56-78_1234_5.zip", false},
+		{"1234-56-78_1234.snapshot", false},
+		{"1234-56-78_1234_5.snapshot", false},
+		{"1234-56-78_1234.zip.enc", false},
+		{"1234-56-78_1234_auto.snapshot", false},
+		{"1234-56-78_1234_5_auto.snapshot", false},
+		{"1234-56-78_1234_auto.zip.enc", false},
+		{"1234-56-78_1234_auto_5.snapshot", true},
+		{"1234-56-78_1234.snapshot.enc", true},
+		{"1234-56-78_1234.snap", true},
 		{"12EB-56-78_1234.zip", true},
 		{"1234-56-78_1234_.zip", true},
 		{"1234-56-78_1234_11.zip", true},
@@ -382,3 +422,26 @@ func TestAppspaceBackupFile(t *testing.T) {
 		}
 	}
 }
+
+func TestBackupSchedule(t *testing.T) {
+	cases := []struct {
+		s   domain.BackupSchedule
+		err bool
+	}{
+		{domain.BackupSchedule{Schedule: "daily", KeepLast: 3, KeepDaily: 7}, false},
+		{domain.BackupSchedule{Schedule: "30 2 * * 1-5"}, false},
+		{domain.BackupSchedule{Schedule: "hourly"}, true},
+		{domain.BackupSchedule{Schedule: ""}, true},
+		{domain.BackupSchedule{Schedule: "daily", KeepLast: -1}, true},
+		{domain.BackupSchedule{Schedule: "daily", KeepMonthly: 1001}, true},
+	}
+
+	for _, c := range cases {
+		err := BackupSchedule(c.s)
+		if !c.err && err != nil {
+			t.Error("should not have gotten error", err)
+		} else if c.err && err == nil {
+			t.Error("should have gotten error")
+		}
+	}
+}